@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"log/slog"
@@ -19,12 +18,16 @@ import (
 	"github.com/abgdnv/gocommerce/pkg/auth"
 	"github.com/abgdnv/gocommerce/pkg/bootstrap"
 	"github.com/abgdnv/gocommerce/pkg/client/grpc/interceptors"
+	pconfig "github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/abgdnv/gocommerce/pkg/config/configloader"
+	"github.com/abgdnv/gocommerce/pkg/grpctls"
+	"github.com/abgdnv/gocommerce/pkg/server"
 	"github.com/abgdnv/gocommerce/pkg/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
-	"golang.org/x/sync/errgroup"
+	"go.opentelemetry.io/otel"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
@@ -47,10 +50,9 @@ func run(ctx context.Context) error {
 	if cfgErr != nil {
 		return fmt.Errorf("failed to load configuration: %w", cfgErr)
 	}
-	log.Printf("Configuration loaded: %v", cfg)
-
-	logger := bootstrap.NewLogger(cfg.Log.Level)
+	logger := bootstrap.NewLogger(cfg.Log.Level, cfg.Log.Format, cfg.Log.AddSource)
 	slog.SetDefault(logger)
+	bootstrap.LogEffectiveConfig(logger, cfg)
 
 	// create tracer provider
 	tracerProvider, err := telemetry.NewTracerProvider(ctx, serviceName, cfg.Telemetry)
@@ -60,9 +62,13 @@ func run(ctx context.Context) error {
 	}
 
 	// Create a gRPC client connection to the User service
+	userCreds, err := grpctls.ClientCredentials(cfg.Services.User.Grpc.TLS)
+	if err != nil {
+		return fmt.Errorf("failed to load gRPC client TLS credentials: %w", err)
+	}
 	grpcClient, err := grpc.NewClient(
 		cfg.Services.User.Grpc.Addr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(userCreds),
 		grpc.WithUnaryInterceptor(
 			interceptors.UnaryClientTimeoutInterceptor(cfg.Services.User.Grpc.Timeout),
 		),
@@ -75,96 +81,77 @@ func run(ctx context.Context) error {
 	healthClient := healthpb.NewHealthClient(grpcClient)
 	userService := service.NewUserService(userClient, healthClient)
 
-	g, gCtx := errgroup.WithContext(ctx)
-
 	// Start the API Gateway
 	startupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
-	verifier, err := auth.NewJWTVerifier(startupCtx, cfg.IdP)
+	verifier, err := auth.NewJWTVerifier(startupCtx, cfg.IdP, otel.Meter("api-gateway"), logger)
 	if err != nil {
 		return fmt.Errorf("failed to create JWT verifier: %w", err)
 	}
 
-	gw := rest.NewGW(cfg.HTTPServer, userService, cfg.Services, cfg.IdP.JwksURL, logger)
+	gw := rest.NewGW(cfg.HTTPServer, userService, cfg.Services, cfg.Proxy, cfg.HTTPClient, cfg.IdP.JwksURL, logger)
 	httpServer, err := gw.SetupHTTPServer(verifier)
 	if err != nil {
 		return err
 	}
-	g.Go(func() error {
-		logger.Info("API Gateway started", slog.String("addr", httpServer.Addr))
-		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			return fmt.Errorf("API gateway failed: %w", err)
-		}
-		return nil
-	})
-	// gracefully shutdown the API Gateway
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down API Gateway...")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-		defer cancel()
-		return httpServer.Shutdown(shutdownCtx)
-	})
-
-	// Start the pprof server if enabled
-	pprofServer := &http.Server{
-		Addr: cfg.PProf.Addr,
+	drainer := server.NewDrainer(cfg.Shutdown.DrainDelay, logger)
+	httpServer.Handler = drainer.Middleware(httpServer.Handler)
+	pprofServer := server.NewPProfServer(cfg.PProf)
+
+	// components are shut down in reverse order: servers first, then clients and tracer provider
+	components := []bootstrap.Component{
+		&bootstrap.FuncComponent{
+			ComponentName: "tracer provider",
+			ShutdownFn:    tracerProvider.Shutdown,
+		},
+		&bootstrap.FuncComponent{
+			ComponentName: "gRPC client",
+			ShutdownFn: func(_ context.Context) error {
+				return grpcClient.Close()
+			},
+		},
+		&bootstrap.FuncComponent{
+			ComponentName: "JWKS refresher",
+			StartFn:       verifier.Run,
+		},
+		bootstrap.NewHTTPServerComponent("API Gateway", httpServer, logger),
 	}
 	if cfg.PProf.Enabled {
-		g.Go(func() error {
-			logger.Info("Pprof server listening", slog.String("addr", pprofServer.Addr))
-			if err := pprofServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				return fmt.Errorf("pprof server failed: %w", err)
-			}
-			return nil
-		})
-		// gracefully shutdown pprof server on context cancellation
-		g.Go(func() error {
-			<-gCtx.Done()
-			logger.Info("Shutting down pprof server...")
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-			defer cancel()
-			return pprofServer.Shutdown(shutdownCtx)
-		})
+		components = append(components, bootstrap.NewHTTPServerComponent("pprof server", pprofServer, logger))
 	}
-
-	// gracefully shutdown grpc client
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down grpc client")
-
-		closeDone := make(chan struct{})
-		go func() {
-			err := grpcClient.Close()
-			if err != nil {
-				logger.Error("failed to close gRPC client connection")
-			}
-			close(closeDone)
-		}()
-
-		select {
-		case <-closeDone:
-			logger.Info("gRPC client connection closed successfully")
-			return nil
-		case <-time.After(cfg.Shutdown.Timeout):
-			return fmt.Errorf("failed to close gRPC client connection")
+	if cfg.Telemetry.Metrics.Enabled {
+		metricsServer, err := setupMetricsServer(&cfg.Telemetry)
+		if err != nil {
+			return fmt.Errorf("failed to create metrics server")
 		}
-	})
+		components = append(components, bootstrap.NewHTTPServerComponent("metrics server", metricsServer, logger))
+	}
 
-	// gracefully shutdown tracer provider
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down tracer provider")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-		defer cancel()
-		if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
-			return fmt.Errorf("failed to shutdown tracer provider: %v", err)
-		}
-		return nil
+	// the drainer is shut down first, so the readiness probe fails while the servers still serve requests
+	components = append(components, &bootstrap.FuncComponent{
+		ComponentName: "drainer",
+		ShutdownFn:    drainer.Drain,
 	})
 
-	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
-		return fmt.Errorf("errgroup encountered an error: %w", err)
+	if err := bootstrap.RunServers(ctx, components, cfg.Shutdown.Timeout, logger); err != nil {
+		return fmt.Errorf("failed to run servers: %w", err)
 	}
 	return nil
 }
+
+// setupMetricsServer initializes the HTTP metrics server
+func setupMetricsServer(cfg *pconfig.TelemetryConfig) (*http.Server, error) {
+	if err := telemetry.NewMeterProvider(); err != nil {
+		return nil, err
+	}
+	metricsHandler := http.NewServeMux()
+	metricsHandler.Handle("/metrics", promhttp.HandlerFor(
+		prometheus.DefaultGatherer,
+		promhttp.HandlerOpts{},
+	))
+	metricsServer := &http.Server{
+		Addr:    cfg.Metrics.Addr,
+		Handler: metricsHandler,
+	}
+	return metricsServer, nil
+}
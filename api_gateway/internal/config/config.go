@@ -17,24 +17,48 @@ type Config struct {
 	Telemetry  config.TelemetryConfig `koanf:"telemetry"`
 	Shutdown   config.ShutdownConfig  `koanf:"shutdown"`
 	Services   Services               `koanf:"services"`
-	IdP        config.IdP             `koanf:"idp"`
+	Proxy      ProxyConfig            `koanf:"proxy"`
+	// HTTPClient is the client of the health checks and the reverse proxies to the upstream services.
+	HTTPClient config.HTTPClientConfig `koanf:"httpclient"`
+	IdP        config.IdP              `koanf:"idp"`
 }
 
+// Services holds the upstream services of the gateway.
+// Optional marks a non-critical dependency: the gateway stays ready while it's down.
+// The dependencies are critical by default.
+// A request of From is proxied to To, the base path of the upstream HTTP server followed by the resource, e.g. /api/v1/products.
 type Services struct {
 	Product struct {
-		Url  string `koanf:"url"`
-		From string `koanf:"from"`
-		To   string `koanf:"to"`
+		Url      string `koanf:"url"`
+		From     string `koanf:"from"`
+		To       string `koanf:"to"`
+		Optional bool   `koanf:"optional"`
 	} `koanf:"product"`
 	Order struct {
-		Url  string `koanf:"url"`
-		From string `koanf:"from"`
-		To   string `koanf:"to"`
+		Url      string `koanf:"url"`
+		From     string `koanf:"from"`
+		To       string `koanf:"to"`
+		Optional bool   `koanf:"optional"`
+		// AdminFrom and AdminTo route the admin order endpoints, which require the admin role.
+		// The admin routes are disabled if AdminFrom is empty.
+		AdminFrom string `koanf:"adminfrom"`
+		AdminTo   string `koanf:"adminto"`
 	} `koanf:"order"`
 	User struct {
-		From string                  `koanf:"from"`
-		Grpc config.GrpcClientConfig `koanf:"grpc"`
+		From     string                  `koanf:"from"`
+		Grpc     config.GrpcClientConfig `koanf:"grpc"`
+		Optional bool                    `koanf:"optional"`
 	} `koanf:"user"`
+	// Jwks is the key set of the identity provider, its URL is configured by IdP.
+	Jwks struct {
+		Optional bool `koanf:"optional"`
+	} `koanf:"jwks"`
+}
+
+// ProxyConfig holds the settings of the reverse proxies to the upstream services.
+type ProxyConfig struct {
+	// NormalizeErrors wraps upstream error responses with a non-JSON body into the standard JSON error shape.
+	NormalizeErrors bool `koanf:"normalizeerrors"`
 }
 
 func (c *Config) String() string {
@@ -45,13 +69,29 @@ func (c *Config) String() string {
 	b.WriteString(fmt.Sprintf("  product.url: %s\n", c.Services.Product.Url))
 	b.WriteString(fmt.Sprintf("  product.from: %s\n", c.Services.Product.From))
 	b.WriteString(fmt.Sprintf("  product.to: %s\n", c.Services.Product.To))
+	b.WriteString(fmt.Sprintf("  product.optional: %t\n", c.Services.Product.Optional))
 	b.WriteString("\n")
 	b.WriteString(fmt.Sprintf("  order.url: %s\n", c.Services.Order.Url))
 	b.WriteString(fmt.Sprintf("  order.from: %s\n", c.Services.Order.From))
 	b.WriteString(fmt.Sprintf("  order.to: %s\n", c.Services.Order.To))
+	b.WriteString(fmt.Sprintf("  order.adminfrom: %s\n", c.Services.Order.AdminFrom))
+	b.WriteString(fmt.Sprintf("  order.adminto: %s\n", c.Services.Order.AdminTo))
+	b.WriteString(fmt.Sprintf("  order.optional: %t\n", c.Services.Order.Optional))
 	b.WriteString("\n")
 	b.WriteString(fmt.Sprintf("  user.grpc.addr: %s\n", c.Services.User.Grpc.Addr))
 	b.WriteString(fmt.Sprintf("  user.grpc.timeout: %s\n", c.Services.User.Grpc.Timeout))
+	b.WriteString(fmt.Sprintf("  user.grpc.tls.insecure: %t\n", c.Services.User.Grpc.TLS.Insecure))
+	b.WriteString(fmt.Sprintf("  user.grpc.tls.certFile: %s\n", c.Services.User.Grpc.TLS.CertFile))
+	b.WriteString(fmt.Sprintf("  user.grpc.tls.keyFile: %s\n", c.Services.User.Grpc.TLS.KeyFile))
+	b.WriteString(fmt.Sprintf("  user.grpc.tls.caFile: %s\n", c.Services.User.Grpc.TLS.CAFile))
+	b.WriteString(fmt.Sprintf("  user.grpc.tls.serverName: %s\n", c.Services.User.Grpc.TLS.ServerName))
+	b.WriteString(fmt.Sprintf("  user.optional: %t\n", c.Services.User.Optional))
+	b.WriteString("\n")
+	b.WriteString(fmt.Sprintf("  jwks.optional: %t\n", c.Services.Jwks.Optional))
+
+	b.WriteString("\n--- Proxy Configuration ---\n")
+	b.WriteString(fmt.Sprintf("  normalizeErrors: %t\n", c.Proxy.NormalizeErrors))
+	b.WriteString(c.HTTPClient.String())
 
 	b.WriteString(c.IdP.String())
 	b.WriteString(c.Log.String())
@@ -78,6 +118,9 @@ func (c *Config) Validate() error {
 	if err := c.Shutdown.Validate(); err != nil {
 		return err
 	}
+	if err := c.HTTPClient.Validate(); err != nil {
+		return err
+	}
 	if c.Services.Product.Url == "" {
 		return fmt.Errorf("product service URL cannot be empty")
 	}
@@ -96,6 +139,9 @@ func (c *Config) Validate() error {
 	if c.Services.Order.To == "" {
 		return fmt.Errorf("order service 'to' field cannot be empty")
 	}
+	if c.Services.Order.AdminFrom != "" && c.Services.Order.AdminTo == "" {
+		return fmt.Errorf("order service 'adminto' field cannot be empty when 'adminfrom' is set")
+	}
 	if c.Services.User.From == "" {
 		return fmt.Errorf("user service 'from' field cannot be empty")
 	}
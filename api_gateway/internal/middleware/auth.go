@@ -2,10 +2,15 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/abgdnv/gocommerce/pkg/auth"
+	"github.com/abgdnv/gocommerce/pkg/web"
+	"github.com/lestrrat-go/jwx/v3/jwt"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -13,10 +18,28 @@ import (
 type contextKey string
 
 const UserIDContextKey = contextKey("userID")
+const EmailVerifiedContextKey = contextKey("emailVerified")
+const EmailContextKey = contextKey("email")
+const RolesContextKey = contextKey("roles")
+const OrgContextKey = contextKey("org")
+
+// OrgClaim is the token claim with the organization of the user, added by a protocol mapper of the identity provider.
+const OrgClaim = "org_id"
+
+// Error codes of 401 Unauthorized responses, they tell clients whether to log in again or to refresh the token.
+const (
+	// CodeTokenMissing means the request has no bearer token, the client has to log in.
+	CodeTokenMissing = "token_missing"
+	// CodeTokenInvalid means the token is malformed, has a wrong signature or claims, the client has to log in again.
+	CodeTokenInvalid = "token_invalid"
+	// CodeTokenExpired means the token is valid but expired, the client should refresh it.
+	CodeTokenExpired = "token_expired"
+)
 
 // AuthMiddleware is a middleware that verifies JWT tokens in the Authorization header.
 // It extracts the user ID from the token and adds it to the request context.
-// If the token is invalid or missing, it returns a 401 Unauthorized response.
+// If the token is invalid or missing, it returns a 401 Unauthorized response with a WWW-Authenticate header
+// and one of the CodeTokenMissing, CodeTokenInvalid or CodeTokenExpired error codes.
 // If the token is valid, it calls the next handler in the chain.
 // The user ID can be accessed in the next handlers via the context.
 func AuthMiddleware(verifier auth.Verifier) func(http.Handler) http.Handler {
@@ -24,26 +47,30 @@ func AuthMiddleware(verifier auth.Verifier) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+				respondUnauthorized(w, CodeTokenMissing, "Authorization header is required")
 				return
 			}
 
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 			if tokenString == authHeader { // Если префикса не было
-				http.Error(w, "Bearer token is required", http.StatusUnauthorized)
+				respondUnauthorized(w, CodeTokenMissing, "Bearer token is required")
 				return
 			}
 
 			token, err := verifier.Verify(r.Context(), tokenString)
 			if err != nil {
-				http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
+				if errors.Is(err, jwt.TokenExpiredError()) {
+					respondUnauthorized(w, CodeTokenExpired, "Token has expired")
+					return
+				}
+				respondUnauthorized(w, CodeTokenInvalid, "Invalid token: "+err.Error())
 				return
 			}
 
 			// get the user ID from the token claims
 			subject, ok := token.Subject()
 			if !ok {
-				http.Error(w, "no claim `sub`", http.StatusUnauthorized)
+				respondUnauthorized(w, CodeTokenInvalid, "no claim `sub`")
 				return
 			}
 
@@ -54,8 +81,26 @@ func AuthMiddleware(verifier auth.Verifier) func(http.Handler) http.Handler {
 				span.SetAttributes(attrs...)
 			}
 
-			// Enrich the request context with the user ID.
+			// get the email verification status, a missing claim means the email is not verified
+			var emailVerified bool
+			_ = token.Get("email_verified", &emailVerified)
+			// get the email address, it is optional and used for notifications only
+			var email string
+			_ = token.Get("email", &email)
+			// get the realm and client roles, they are used to authorize access to the admin routes
+			roles := TokenRoles(token)
+			// get the organization, it is optional and used to label the metrics only
+			var org string
+			_ = token.Get(OrgClaim, &org)
+
+			web.SetAccessLogUserID(r.Context(), subject)
+
+			// Enrich the request context with the user ID, email address, email verification status, roles and organization.
 			ctx := context.WithValue(r.Context(), UserIDContextKey, subject)
+			ctx = context.WithValue(ctx, EmailVerifiedContextKey, emailVerified)
+			ctx = context.WithValue(ctx, EmailContextKey, email)
+			ctx = context.WithValue(ctx, RolesContextKey, roles)
+			ctx = context.WithValue(ctx, OrgContextKey, org)
 
 			// Pass the enriched context to the next handler in the chain.
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -63,6 +108,77 @@ func AuthMiddleware(verifier auth.Verifier) func(http.Handler) http.Handler {
 	}
 }
 
+// OptionalAuthMiddleware lets anonymous requests through and authenticates the requests with an Authorization header
+// like AuthMiddleware, so the routes open to everyone can still tell the authenticated users apart, e.g. by their roles.
+// A request with an invalid token is rejected rather than served as anonymous.
+func OptionalAuthMiddleware(verifier auth.Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		authenticated := AuthMiddleware(verifier)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			authenticated.ServeHTTP(w, r)
+		})
+	}
+}
+
+// respondUnauthorized responds with 401 Unauthorized and the error code as JSON.
+// The WWW-Authenticate header follows RFC 6750: a request without a token gets a bare Bearer challenge,
+// a rejected token gets the invalid_token error with the code as the description.
+func respondUnauthorized(w http.ResponseWriter, code, message string) {
+	challenge := "Bearer"
+	if code != CodeTokenMissing {
+		challenge = `Bearer error="invalid_token", error_description="` + code + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	web.RespondAppError(w, slog.Default(), web.NewAppError(http.StatusUnauthorized, code, message))
+}
+
+// RequireRole is a middleware that rejects requests of users without the given role with 403 Forbidden.
+// It must be used after AuthMiddleware, which adds the roles from the token to the request context.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(ContextRoles(r.Context()), role) {
+				http.Error(w, "Forbidden: role `"+role+"` is required", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TokenRoles returns the roles granted to the user by the identity provider. Keycloak puts the realm roles
+// into the `realm_access.roles` claim and the roles of the client the token is issued for (`azp`)
+// into the `resource_access.<client>.roles` claim. Missing or malformed claims mean the user has no such roles.
+func TokenRoles(token jwt.Token) []string {
+	var roles []string
+	var realmAccess map[string]any
+	if err := token.Get("realm_access", &realmAccess); err == nil {
+		roles = appendRoles(roles, realmAccess)
+	}
+	var clientID string
+	var resourceAccess map[string]any
+	if err := token.Get("azp", &clientID); err == nil && token.Get("resource_access", &resourceAccess) == nil {
+		clientAccess, _ := resourceAccess[clientID].(map[string]any)
+		roles = appendRoles(roles, clientAccess)
+	}
+	return roles
+}
+
+// appendRoles appends the string values of the `roles` list of the access claim, skipping duplicates.
+func appendRoles(roles []string, access map[string]any) []string {
+	claimed, _ := access["roles"].([]any)
+	for _, role := range claimed {
+		if s, ok := role.(string); ok && !slices.Contains(roles, s) {
+			roles = append(roles, s)
+		}
+	}
+	return roles
+}
+
 // ContextUserID retrieves the user ID from the context.
 func ContextUserID(ctx context.Context) string {
 	value := ctx.Value(UserIDContextKey)
@@ -71,3 +187,27 @@ func ContextUserID(ctx context.Context) string {
 	}
 	return ""
 }
+
+// ContextEmailVerified reports whether the email of the authenticated user is verified.
+func ContextEmailVerified(ctx context.Context) bool {
+	verified, _ := ctx.Value(EmailVerifiedContextKey).(bool)
+	return verified
+}
+
+// ContextEmail retrieves the email address of the authenticated user from the context.
+func ContextEmail(ctx context.Context) string {
+	email, _ := ctx.Value(EmailContextKey).(string)
+	return email
+}
+
+// ContextRoles retrieves the roles of the authenticated user from the context.
+func ContextRoles(ctx context.Context) []string {
+	roles, _ := ctx.Value(RolesContextKey).([]string)
+	return roles
+}
+
+// ContextOrg retrieves the organization of the authenticated user from the context, or an empty string if there is none.
+func ContextOrg(ctx context.Context) string {
+	org, _ := ctx.Value(OrgContextKey).(string)
+	return org
+}
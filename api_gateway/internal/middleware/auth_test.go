@@ -2,16 +2,28 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/abgdnv/gocommerce/pkg/auth"
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/web"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jwk"
 	"github.com/lestrrat-go/jwx/v3/jwt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
 )
 
 // MockVerifier is a mock implementation of the auth.Verifier interface for testing purposes.
@@ -49,6 +61,8 @@ func TestAuthMiddleware(t *testing.T) {
 		expectedStatusCode int
 		shouldCallNext     bool   // Whether the next handler should be called
 		expectedUserID     string // userID expected in the context
+		expectedCode       string // error code expected in the 401 response
+		expectedChallenge  string // WWW-Authenticate header expected in the 401 response
 	}{
 		{
 			name:       "Success - valid bearer token",
@@ -67,6 +81,8 @@ func TestAuthMiddleware(t *testing.T) {
 			},
 			expectedStatusCode: http.StatusUnauthorized,
 			shouldCallNext:     false,
+			expectedCode:       CodeTokenMissing,
+			expectedChallenge:  "Bearer",
 		},
 		{
 			name:       "Failure - not a bearer token",
@@ -75,6 +91,8 @@ func TestAuthMiddleware(t *testing.T) {
 			},
 			expectedStatusCode: http.StatusUnauthorized,
 			shouldCallNext:     false,
+			expectedCode:       CodeTokenMissing,
+			expectedChallenge:  "Bearer",
 		},
 		{
 			name:       "Failure - verifier returns error",
@@ -85,6 +103,31 @@ func TestAuthMiddleware(t *testing.T) {
 			},
 			expectedStatusCode: http.StatusUnauthorized,
 			shouldCallNext:     false,
+			expectedCode:       CodeTokenInvalid,
+			expectedChallenge:  `Bearer error="invalid_token", error_description="token_invalid"`,
+		},
+		{
+			name:       "Failure - token is expired",
+			authHeader: "Bearer expired-token",
+			setupMock: func(m *MockVerifier) {
+				// Simulate the wrapped expiration error of the JWT verifier
+				m.On("Verify", mock.Anything, "expired-token").Return(nil, fmt.Errorf("failed to verify token: %w", jwt.TokenExpiredError()))
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			shouldCallNext:     false,
+			expectedCode:       CodeTokenExpired,
+			expectedChallenge:  `Bearer error="invalid_token", error_description="token_expired"`,
+		},
+		{
+			name:       "Failure - token without subject",
+			authHeader: "Bearer anonymous-token",
+			setupMock: func(m *MockVerifier) {
+				m.On("Verify", mock.Anything, "anonymous-token").Return(jwt.New(), nil)
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+			shouldCallNext:     false,
+			expectedCode:       CodeTokenInvalid,
+			expectedChallenge:  `Bearer error="invalid_token", error_description="token_invalid"`,
 		},
 	}
 
@@ -125,9 +168,405 @@ func TestAuthMiddleware(t *testing.T) {
 			// then
 			assert.Equal(t, tc.expectedStatusCode, rr.Code, "HTTP status code is wrong")
 			assert.Equal(t, tc.shouldCallNext, nextHandlerCalled, "Next handler call status is wrong")
+			if tc.expectedCode != "" {
+				var body web.ErrorResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, tc.expectedCode, body.Code, "error code is wrong")
+				assert.Equal(t, tc.expectedChallenge, rr.Header().Get("WWW-Authenticate"), "WWW-Authenticate header is wrong")
+			}
 
 			// Check if all expected calls on the mock were made
 			mockVerifier.AssertExpectations(t)
 		})
 	}
 }
+
+func TestAuthMiddleware_EmailVerified(t *testing.T) {
+	testCases := []struct {
+		name          string
+		claim         any // value of the email_verified claim, nil if absent
+		expectedValue bool
+	}{
+		{name: "verified email", claim: true, expectedValue: true},
+		{name: "unverified email", claim: false, expectedValue: false},
+		{name: "missing claim", claim: nil, expectedValue: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			builder := jwt.NewBuilder().Subject("user-123")
+			if tc.claim != nil {
+				builder = builder.Claim("email_verified", tc.claim)
+			}
+			token, err := builder.Build()
+			require.NoError(t, err)
+
+			mockVerifier := new(MockVerifier)
+			mockVerifier.On("Verify", mock.Anything, "valid-token").Return(token, nil)
+
+			var emailVerified bool
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				emailVerified = ContextEmailVerified(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rr := httptest.NewRecorder()
+
+			// when
+			AuthMiddleware(mockVerifier)(nextHandler).ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, http.StatusOK, rr.Code, "HTTP status code is wrong")
+			assert.Equal(t, tc.expectedValue, emailVerified, "email verification status in context is incorrect")
+			mockVerifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthMiddleware_Org(t *testing.T) {
+	testCases := []struct {
+		name          string
+		claim         any // value of the org claim, nil if absent
+		expectedValue string
+	}{
+		{name: "organization claim", claim: "acme", expectedValue: "acme"},
+		{name: "missing claim", claim: nil, expectedValue: ""},
+		{name: "malformed claim", claim: 42, expectedValue: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			builder := jwt.NewBuilder().Subject("user-123")
+			if tc.claim != nil {
+				builder = builder.Claim(OrgClaim, tc.claim)
+			}
+			token, err := builder.Build()
+			require.NoError(t, err)
+
+			mockVerifier := new(MockVerifier)
+			mockVerifier.On("Verify", mock.Anything, "valid-token").Return(token, nil)
+
+			var org string
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				org = ContextOrg(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rr := httptest.NewRecorder()
+
+			// when
+			AuthMiddleware(mockVerifier)(nextHandler).ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, http.StatusOK, rr.Code, "HTTP status code is wrong")
+			assert.Equal(t, tc.expectedValue, org, "organization in context is incorrect")
+			mockVerifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthMiddleware_RequireRole(t *testing.T) {
+	testCases := []struct {
+		name               string
+		claims             map[string]any // additional claims of the token
+		expectedStatusCode int
+		shouldCallNext     bool
+	}{
+		{
+			name:               "admin realm role",
+			claims:             map[string]any{"realm_access": map[string]any{"roles": []any{"user", "admin"}}},
+			expectedStatusCode: http.StatusOK,
+			shouldCallNext:     true,
+		},
+		{
+			name: "admin client role",
+			claims: map[string]any{
+				"azp":             "gocommerce",
+				"resource_access": map[string]any{"gocommerce": map[string]any{"roles": []any{"admin"}}},
+			},
+			expectedStatusCode: http.StatusOK,
+			shouldCallNext:     true,
+		},
+		{
+			name:               "no admin role",
+			claims:             map[string]any{"realm_access": map[string]any{"roles": []any{"user"}}},
+			expectedStatusCode: http.StatusForbidden,
+			shouldCallNext:     false,
+		},
+		{
+			name: "admin role of another client",
+			claims: map[string]any{
+				"azp":             "gocommerce",
+				"resource_access": map[string]any{"other": map[string]any{"roles": []any{"admin"}}},
+			},
+			expectedStatusCode: http.StatusForbidden,
+			shouldCallNext:     false,
+		},
+		{
+			name:               "malformed realm roles",
+			claims:             map[string]any{"realm_access": map[string]any{"roles": "admin"}},
+			expectedStatusCode: http.StatusForbidden,
+			shouldCallNext:     false,
+		},
+		{
+			name:               "malformed realm access",
+			claims:             map[string]any{"realm_access": []any{"admin"}},
+			expectedStatusCode: http.StatusForbidden,
+			shouldCallNext:     false,
+		},
+		{
+			name: "malformed client roles",
+			claims: map[string]any{
+				"azp":             "gocommerce",
+				"resource_access": map[string]any{"gocommerce": []any{"admin"}},
+			},
+			expectedStatusCode: http.StatusForbidden,
+			shouldCallNext:     false,
+		},
+		{
+			name:               "missing claim",
+			claims:             nil,
+			expectedStatusCode: http.StatusForbidden,
+			shouldCallNext:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			builder := jwt.NewBuilder().Subject("user-123")
+			for name, value := range tc.claims {
+				builder = builder.Claim(name, value)
+			}
+			token, err := builder.Build()
+			require.NoError(t, err)
+
+			mockVerifier := new(MockVerifier)
+			mockVerifier.On("Verify", mock.Anything, "valid-token").Return(token, nil)
+
+			nextCalled := false
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer valid-token")
+			rr := httptest.NewRecorder()
+
+			// when
+			AuthMiddleware(mockVerifier)(RequireRole("admin")(nextHandler)).ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "HTTP status code is wrong")
+			assert.Equal(t, tc.shouldCallNext, nextCalled, "next handler call expectation failed")
+			mockVerifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOptionalAuthMiddleware(t *testing.T) {
+	staffToken, err := jwt.NewBuilder().
+		Subject("user-123").
+		Claim("realm_access", map[string]any{"roles": []any{"user", "admin"}}).
+		Build()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name               string
+		authHeader         string
+		setupMock          func(m *MockVerifier)
+		expectedStatusCode int
+		expectedUserID     string
+		expectedRoles      []string
+	}{
+		{
+			name:               "anonymous request",
+			authHeader:         "",
+			setupMock:          func(m *MockVerifier) {},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:       "authenticated staff member",
+			authHeader: "Bearer valid-token",
+			setupMock: func(m *MockVerifier) {
+				m.On("Verify", mock.Anything, "valid-token").Return(staffToken, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedUserID:     "user-123",
+			expectedRoles:      []string{"user", "admin"},
+		},
+		{
+			name:       "invalid token is rejected",
+			authHeader: "Bearer invalid-token",
+			setupMock: func(m *MockVerifier) {
+				m.On("Verify", mock.Anything, "invalid-token").Return(nil, errors.New("invalid signature"))
+			},
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockVerifier := new(MockVerifier)
+			tc.setupMock(mockVerifier)
+			var userID string
+			var roles []string
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID = ContextUserID(r.Context())
+				roles = ContextRoles(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/products", nil)
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			OptionalAuthMiddleware(mockVerifier)(nextHandler).ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "HTTP status code is wrong")
+			assert.Equal(t, tc.expectedUserID, userID)
+			assert.Equal(t, tc.expectedRoles, roles)
+			mockVerifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTokenRoles(t *testing.T) {
+	// given
+	token, err := jwt.NewBuilder().
+		Subject("user-123").
+		Claim("azp", "gocommerce").
+		Claim("realm_access", map[string]any{"roles": []any{"user", "admin", 42}}).
+		Claim("resource_access", map[string]any{
+			"gocommerce": map[string]any{"roles": []any{"admin", "support"}},
+			"other":      map[string]any{"roles": []any{"auditor"}},
+		}).
+		Build()
+	require.NoError(t, err)
+
+	// when
+	roles := TokenRoles(token)
+
+	// then
+	assert.Equal(t, []string{"user", "admin", "support"}, roles, "roles should be deduplicated and skip non-strings and other clients")
+}
+
+func TestAuthMiddleware_JWTVerifier(t *testing.T) {
+	// given
+	const (
+		issuer   = "http://keycloak/realms/gocommerce"
+		audience = "gocommerce-api"
+		clientID = "gocommerce-web"
+	)
+	rawKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signingKey, err := jwk.Import(rawKey)
+	require.NoError(t, err)
+	require.NoError(t, signingKey.Set(jwk.KeyIDKey, "test-key"))
+	require.NoError(t, signingKey.Set(jwk.AlgorithmKey, jwa.RS256()))
+	publicKey, err := jwk.PublicKeyOf(signingKey)
+	require.NoError(t, err)
+	keySet := jwk.NewSet()
+	require.NoError(t, keySet.AddKey(publicKey))
+
+	// serve the public key as the JWKS of the identity provider
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(keySet)
+	}))
+	defer jwksServer.Close()
+
+	verifier, err := auth.NewJWTVerifier(context.Background(), config.IdP{
+		JwksURL:          jwksServer.URL,
+		ClientID:         clientID,
+		ExpectedIssuer:   issuer,
+		ExpectedAudience: audience,
+		MinInterval:      time.Minute,
+	}, otel.Meter("test"), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name               string
+		issuer             string
+		audience           []string
+		expectedStatusCode int
+		expectedErr        error
+	}{
+		{
+			name:               "expected issuer and audience",
+			issuer:             issuer,
+			audience:           []string{"account", audience},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "wrong issuer",
+			issuer:             "http://keycloak/realms/other",
+			audience:           []string{audience},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedErr:        auth.ErrInvalidIssuer,
+		},
+		{
+			name:               "wrong audience",
+			issuer:             issuer,
+			audience:           []string{"other-api"},
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedErr:        auth.ErrInvalidAudience,
+		},
+		{
+			name:               "missing audience",
+			issuer:             issuer,
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedErr:        auth.ErrInvalidAudience,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			builder := jwt.NewBuilder().
+				Subject("user-123").
+				Issuer(tc.issuer).
+				Claim("azp", clientID).
+				IssuedAt(time.Now()).
+				Expiration(time.Now().Add(time.Hour))
+			if tc.audience != nil {
+				builder = builder.Audience(tc.audience)
+			}
+			token, err := builder.Build()
+			require.NoError(t, err)
+			signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256(), signingKey))
+			require.NoError(t, err)
+
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer "+string(signed))
+			rr := httptest.NewRecorder()
+
+			// when
+			AuthMiddleware(verifier)(nextHandler).ServeHTTP(rr, req)
+			_, verifyErr := verifier.Verify(context.Background(), string(signed))
+
+			// then
+			assert.Equal(t, tc.expectedStatusCode, rr.Code, "HTTP status code is wrong")
+			if tc.expectedErr == nil {
+				assert.NoError(t, verifyErr)
+				return
+			}
+			assert.ErrorIs(t, verifyErr, tc.expectedErr)
+			var body web.ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, CodeTokenInvalid, body.Code, "error code is wrong")
+			assert.Contains(t, body.Error, tc.expectedErr.Error(), "error message should explain the rejection")
+		})
+	}
+}
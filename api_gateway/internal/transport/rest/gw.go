@@ -1,70 +1,94 @@
 package rest
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	sCfg "github.com/abgdnv/gocommerce/api_gateway/internal/config"
 	"github.com/abgdnv/gocommerce/api_gateway/internal/middleware"
 	"github.com/abgdnv/gocommerce/api_gateway/internal/service"
 	"github.com/abgdnv/gocommerce/pkg/auth"
+	httpclient "github.com/abgdnv/gocommerce/pkg/client/http"
 	"github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/abgdnv/gocommerce/pkg/server"
 	"github.com/abgdnv/gocommerce/pkg/web"
 	"github.com/go-chi/chi/v5"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
-	"golang.org/x/sync/errgroup"
+	"go.opentelemetry.io/otel"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// XRequestTimeout holds the time the client is willing to wait for the response, as a Go duration, e.g. 500ms.
+const XRequestTimeout = "X-Request-Timeout"
+
 type GW struct {
-	httpCfg           config.HTTPConfig
-	cfg               sCfg.Services
-	userService       *service.UserService
-	JwksURL           string
-	logger            *slog.Logger
-	healthCheckClient *http.Client
+	httpCfg        config.HTTPConfig
+	cfg            sCfg.Services
+	proxyCfg       sCfg.ProxyConfig
+	userService    *service.UserService
+	JwksURL        string
+	logger         *slog.Logger
+	upstreamClient *http.Client
 }
 
-func NewGW(httpCfg config.HTTPConfig, userService *service.UserService, cfg sCfg.Services, JwksURL string, logger *slog.Logger) *GW {
+// NewGW creates the gateway. The health checks and the reverse proxies call the upstream services
+// with a client of the clientCfg, sharing its connection pool.
+func NewGW(httpCfg config.HTTPConfig, userService *service.UserService, cfg sCfg.Services, proxyCfg sCfg.ProxyConfig, clientCfg config.HTTPClientConfig, JwksURL string, logger *slog.Logger) *GW {
 	return &GW{
-		httpCfg:     httpCfg,
-		cfg:         cfg,
-		userService: userService,
-		JwksURL:     JwksURL,
-		logger:      logger.With("component", "gw"),
-		healthCheckClient: &http.Client{
-			Timeout: 2 * time.Second,
-		},
+		httpCfg:        httpCfg,
+		cfg:            cfg,
+		proxyCfg:       proxyCfg,
+		userService:    userService,
+		JwksURL:        JwksURL,
+		logger:         logger.With("component", "gw"),
+		upstreamClient: httpclient.NewClient(clientCfg),
 	}
 }
 
 // SetupHTTPServer initializes the HTTP server with the configured reverse proxies.
+// Requests of hosts not in the allowed hosts of the HTTP configuration are rejected with 400.
 // If there is an error creating the reverse proxy, it returns an error.
 func (gw *GW) SetupHTTPServer(verifier *auth.JWTVerifier) (*http.Server, error) {
-	mux := server.NewChiRouter(gw.logger)
+	mux := server.NewChiRouter(gw.logger, gw.httpCfg.TrailingSlash)
 
-	productProxy, err := createReverseProxyWithRewrite(gw.cfg.Product.Url, gw.cfg.Product.From, gw.cfg.Product.To)
+	productProxy, err := createReverseProxyWithRewrite(gw.cfg.Product.Url, gw.cfg.Product.From, gw.cfg.Product.To, gw.proxyCfg.NormalizeErrors, gw.upstreamClient.Transport)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create product proxy: %w", err)
 	}
 	mux.Route(gw.cfg.Product.From, func(r chi.Router) {
 		r.With(middleware.AuthMiddleware(verifier)).Post("/", productProxy.ServeHTTP)
+		r.With(middleware.AuthMiddleware(verifier)).Post("/batch", productProxy.ServeHTTP)
 		r.With(middleware.AuthMiddleware(verifier)).Put("/{id}", productProxy.ServeHTTP)
+		// the catalog imports by SKU are for operators only
+		r.With(middleware.AuthMiddleware(verifier), middleware.RequireRole(web.RoleAdmin)).Put("/by-sku/{sku}", productProxy.ServeHTTP)
+		r.With(middleware.AuthMiddleware(verifier)).Patch("/{id}", productProxy.ServeHTTP)
 		r.With(middleware.AuthMiddleware(verifier)).Delete("/{id}", productProxy.ServeHTTP)
 
 		r.With(middleware.AuthMiddleware(verifier)).Put("/{id}/stock", productProxy.ServeHTTP)
+		r.With(middleware.AuthMiddleware(verifier)).Post("/{id}/stock/adjust", productProxy.ServeHTTP)
+		// the change log of a product is for operators only
+		r.With(middleware.AuthMiddleware(verifier), middleware.RequireRole(web.RoleAdmin)).Get("/{id}/history", productProxy.ServeHTTP)
+		// the price history is for the analytics dashboards of the staff
+		r.With(middleware.AuthMiddleware(verifier), middleware.RequireRole(web.RoleAdmin)).Get("/{id}/price-history", productProxy.ServeHTTP)
 
-		r.Get("/", productProxy.ServeHTTP)
-		r.Get("/{id}", productProxy.ServeHTTP)
+		// the products are browsed anonymously, the staff authenticates to see the internal ones as well
+		r.With(middleware.OptionalAuthMiddleware(verifier)).Get("/", productProxy.ServeHTTP)
+		r.With(middleware.OptionalAuthMiddleware(verifier)).Get("/search", productProxy.ServeHTTP)
+		r.With(middleware.OptionalAuthMiddleware(verifier)).Get("/{id}", productProxy.ServeHTTP)
 	})
 
 	mux.Group(func(r chi.Router) {
@@ -74,7 +98,7 @@ func (gw *GW) SetupHTTPServer(verifier *auth.JWTVerifier) (*http.Server, error)
 	mux.Get("/readyz", gw.Ready)
 	mux.Get("/livez", gw.Live)
 
-	orderProxy, err := createReverseProxyWithRewrite(gw.cfg.Order.Url, gw.cfg.Order.From, gw.cfg.Order.To)
+	orderProxy, err := createReverseProxyWithRewrite(gw.cfg.Order.Url, gw.cfg.Order.From, gw.cfg.Order.To, gw.proxyCfg.NormalizeErrors, gw.upstreamClient.Transport)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create order proxy: %w", err)
 	}
@@ -83,9 +107,21 @@ func (gw *GW) SetupHTTPServer(verifier *auth.JWTVerifier) (*http.Server, error)
 		r.Mount(gw.cfg.Order.From, orderProxy)
 	})
 
+	if gw.cfg.Order.AdminFrom != "" {
+		adminOrderProxy, err := createReverseProxyWithRewrite(gw.cfg.Order.Url, gw.cfg.Order.AdminFrom, gw.cfg.Order.AdminTo, gw.proxyCfg.NormalizeErrors, gw.upstreamClient.Transport)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create admin order proxy: %w", err)
+		}
+		// the order service checks the role as well, the gateway rejects non-admin callers early
+		mux.Group(func(r chi.Router) {
+			r.Use(middleware.AuthMiddleware(verifier), middleware.RequireRole(web.RoleAdmin))
+			r.Mount(gw.cfg.Order.AdminFrom, adminOrderProxy)
+		})
+	}
+
 	return &http.Server{
 		Addr:              fmt.Sprintf(":%d", gw.httpCfg.Port),
-		Handler:           mux,
+		Handler:           web.AllowedHostsMiddleware(gw.httpCfg.AllowedHosts, gw.logger)(web.MetricsMiddleware(otel.Meter("api-gateway"))(mux)),
 		ReadTimeout:       gw.httpCfg.Timeout.Read,
 		WriteTimeout:      gw.httpCfg.Timeout.Write,
 		IdleTimeout:       gw.httpCfg.Timeout.Idle,
@@ -96,16 +132,20 @@ func (gw *GW) SetupHTTPServer(verifier *auth.JWTVerifier) (*http.Server, error)
 
 // createReverseProxyWithRewrite creates a reverse proxy that rewrites the request path.
 // It takes the target URL, the path to match, and the path to rewrite to.
+// If normalizeErrors is set, upstream error responses with a non-JSON body are wrapped into the standard JSON error shape.
+// The upstream requests are sent with the transport, instrumented with OpenTelemetry.
+// The upstream request is cancelled once the deadline of the incoming request, or its X-Request-Timeout, expires,
+// so the upstream handlers stop working on it, and the client gets 504 Gateway Timeout.
 // It returns an http.Handler that can be used in a router.
 // If the target URL is invalid, it logs a fatal error and exits.
-func createReverseProxyWithRewrite(targetURL, fromPath, toPath string) (http.Handler, error) {
+func createReverseProxyWithRewrite(targetURL, fromPath, toPath string, normalizeErrors bool, transport http.RoundTripper) (http.Handler, error) {
 	target, err := url.Parse(targetURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid target URL '%s': %w", targetURL, err)
 	}
 	proxy := httputil.NewSingleHostReverseProxy(target)
 
-	otelTransport := otelhttp.NewTransport(http.DefaultTransport)
+	otelTransport := otelhttp.NewTransport(transport)
 	proxy.Transport = otelTransport
 
 	// Director will be called before the request is sent to the target.
@@ -113,12 +153,97 @@ func createReverseProxyWithRewrite(targetURL, fromPath, toPath string) (http.Han
 		userID := middleware.ContextUserID(req.Context())
 		if userID != "" {
 			req.Header.Set(web.XUserId, userID)
+			req.Header.Set(web.XUserEmailVerified, strconv.FormatBool(middleware.ContextEmailVerified(req.Context())))
+			req.Header.Set(web.XUserEmail, middleware.ContextEmail(req.Context()))
+			req.Header.Set(web.XUserRoles, strings.Join(middleware.ContextRoles(req.Context()), ","))
+			if org := middleware.ContextOrg(req.Context()); org != "" {
+				req.Header.Set(web.XOrgId, org)
+			} else {
+				req.Header.Del(web.XOrgId)
+			}
+		} else {
+			// never trust the identity headers sent by the client
+			req.Header.Del(web.XUserId)
+			req.Header.Del(web.XUserEmailVerified)
+			req.Header.Del(web.XUserEmail)
+			req.Header.Del(web.XUserRoles)
+			req.Header.Del(web.XOrgId)
 		}
 		req.URL.Scheme = target.Scheme
 		req.URL.Host = target.Host
 		req.URL.Path = toPath + strings.TrimPrefix(req.URL.Path, fromPath)
 	}
-	return proxy, nil
+	if normalizeErrors {
+		proxy.ModifyResponse = normalizeErrorResponse
+	}
+	proxy.ErrorHandler = proxyErrorHandler
+	return withRequestTimeout(proxy), nil
+}
+
+// withRequestTimeout bounds the context of the request with the duration of its X-Request-Timeout header.
+// The proxied request inherits the context, so it's cancelled when the client's timeout expires.
+// A malformed or non-positive timeout is ignored.
+func withRequestTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get(XRequestTimeout)
+		if value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			slog.WarnContext(r.Context(), "Ignoring invalid request timeout", "value", value)
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// proxyErrorHandler responds with 504 if the request deadline expired before the upstream responded, otherwise with 502.
+func proxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.WarnContext(r.Context(), "Upstream request timed out", "error", err)
+		web.RespondError(w, slog.Default(), http.StatusGatewayTimeout, "Request timed out")
+		return
+	}
+	slog.ErrorContext(r.Context(), "Upstream request failed", "error", err)
+	w.WriteHeader(http.StatusBadGateway)
+}
+
+// normalizeErrorResponse replaces the body of an upstream error response with the standard JSON error shape,
+// unless the upstream already responded with JSON. The original body (e.g. an HTML error page) is discarded.
+func normalizeErrorResponse(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest || isJSONContentType(resp.Header.Get("Content-Type")) {
+		return nil
+	}
+	message := http.StatusText(resp.StatusCode)
+	if message == "" {
+		message = "Upstream error"
+	}
+	body, err := json.Marshal(web.ErrorResponse{Error: message, Code: web.StatusCode(resp.StatusCode)})
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.Header.Del("Content-Encoding")
+	return nil
+}
+
+// isJSONContentType reports whether the content type is application/json or a JSON-based type like application/problem+json.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
 }
 
 func (gw *GW) userRegisterHandler() http.HandlerFunc {
@@ -158,23 +283,75 @@ func (gw *GW) Live(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-// Ready checks if the service is ready (i.e., all dependencies are healthy)
+// dependency is an upstream the readiness of the gateway depends on.
+// The gateway isn't ready while a critical dependency is down.
+type dependency struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// dependencies returns the upstreams checked by the readiness probe.
+func (gw *GW) dependencies() []dependency {
+	return []dependency{
+		{
+			name:     "product",
+			critical: !gw.cfg.Product.Optional,
+			check: func(ctx context.Context) error {
+				return gw.CheckHealth(ctx, gw.cfg.Product.Url+"/readyz")
+			},
+		},
+		{
+			name:     "order",
+			critical: !gw.cfg.Order.Optional,
+			check: func(ctx context.Context) error {
+				return gw.CheckHealth(ctx, gw.cfg.Order.Url+"/readyz")
+			},
+		},
+		{
+			name:     "user",
+			critical: !gw.cfg.User.Optional,
+			check:    gw.userService.Check,
+		},
+		{
+			name:     "jwks",
+			critical: !gw.cfg.Jwks.Optional,
+			check: func(ctx context.Context) error {
+				return gw.CheckHealth(ctx, gw.JwksURL)
+			},
+		},
+	}
+}
+
+// Ready checks if the service is ready (i.e., all critical dependencies are healthy).
+// The non-critical dependencies being down is logged as a warning, but doesn't fail the probe.
 func (gw *GW) Ready(w http.ResponseWriter, r *http.Request) {
-	eg, ctx := errgroup.WithContext(r.Context())
-	eg.Go(func() error {
-		return gw.CheckHealth(ctx, gw.cfg.Product.Url+"/healthz")
-	})
-	eg.Go(func() error {
-		return gw.CheckHealth(ctx, gw.cfg.Order.Url+"/healthz")
-	})
-	eg.Go(func() error {
-		return gw.userService.Check(ctx)
-	})
-	eg.Go(func() error {
-		return gw.CheckHealth(ctx, gw.JwksURL)
-	})
-	if err := eg.Wait(); err != nil {
-		slog.ErrorContext(ctx, "Readiness probe failed: upstream service is not ready", "error", err)
+	ctx := r.Context()
+	deps := gw.dependencies()
+	errs := make([]error, len(deps))
+	var wg sync.WaitGroup
+	for i, dep := range deps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = dep.check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for i, dep := range deps {
+		if errs[i] == nil {
+			continue
+		}
+		if dep.critical {
+			gw.logger.ErrorContext(ctx, "Readiness probe failed: upstream service is not ready", "dependency", dep.name, "error", errs[i])
+			ready = false
+			continue
+		}
+		gw.logger.WarnContext(ctx, "Non-critical upstream service is not ready", "dependency", dep.name, "error", errs[i])
+	}
+	if !ready {
 		http.Error(w, "Service Unavailable: Upstream service is not ready", http.StatusServiceUnavailable)
 		return
 	}
@@ -182,9 +359,10 @@ func (gw *GW) Ready(w http.ResponseWriter, r *http.Request) {
 }
 
 // CheckHealth checks the health status of a service via HTTP.
+// A failed check is retried as configured for the upstream client, within its timeout.
 func (gw *GW) CheckHealth(ctx context.Context, url string) error {
 	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	resp, err := gw.healthCheckClient.Do(req)
+	resp, err := gw.upstreamClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("get request error, url=%v: %w", url, err)
 	}
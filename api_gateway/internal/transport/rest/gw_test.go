@@ -1,13 +1,24 @@
 package rest
 
 import (
+	"context"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 
+	sCfg "github.com/abgdnv/gocommerce/api_gateway/internal/config"
+	"github.com/abgdnv/gocommerce/api_gateway/internal/middleware"
+	"github.com/abgdnv/gocommerce/api_gateway/internal/service"
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/web"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func TestCreateReverseProxyWithRewrite(t *testing.T) {
@@ -80,7 +91,7 @@ func TestCreateReverseProxyWithRewrite(t *testing.T) {
 			}
 
 			// when
-			proxyHandler, err := createReverseProxyWithRewrite(tc.cfg.targetURL, tc.cfg.fromPath, tc.cfg.toPath)
+			proxyHandler, err := createReverseProxyWithRewrite(tc.cfg.targetURL, tc.cfg.fromPath, tc.cfg.toPath, false, http.DefaultTransport)
 			// then
 			if tc.expectErr {
 				require.Error(t, err, "Expected an error during proxy creation, but got none")
@@ -102,3 +113,429 @@ func TestCreateReverseProxyWithRewrite(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateReverseProxyWithRewrite_IdentityHeaders(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		userID                string
+		email                 string
+		emailVerified         bool
+		roles                 []string
+		org                   string
+		spoofedUserID         string
+		spoofedEmailHeader    string
+		spoofedEmail          string
+		spoofedRoles          string
+		spoofedOrg            string
+		expectedUserID        string
+		expectedEmail         string
+		expectedEmailVerified string
+		expectedRoles         string
+		expectedOrg           string
+	}{
+		{
+			name:                  "authenticated user with verified email",
+			userID:                "user-123",
+			email:                 "user@example.com",
+			emailVerified:         true,
+			expectedUserID:        "user-123",
+			expectedEmail:         "user@example.com",
+			expectedEmailVerified: "true",
+		},
+		{
+			name:                  "authenticated user with unverified email overrides client header",
+			userID:                "user-123",
+			email:                 "user@example.com",
+			emailVerified:         false,
+			spoofedEmailHeader:    "true",
+			spoofedEmail:          "attacker@example.com",
+			spoofedRoles:          "admin",
+			spoofedOrg:            "other-org",
+			expectedUserID:        "user-123",
+			expectedEmail:         "user@example.com",
+			expectedEmailVerified: "false",
+			expectedRoles:         "",
+			expectedOrg:           "",
+		},
+		{
+			name:                  "authenticated user with roles",
+			userID:                "user-123",
+			roles:                 []string{"user", "admin"},
+			expectedUserID:        "user-123",
+			expectedEmailVerified: "false",
+			expectedRoles:         "user,admin",
+		},
+		{
+			name:                  "authenticated user of an organization overrides client header",
+			userID:                "user-123",
+			org:                   "acme",
+			spoofedOrg:            "other-org",
+			expectedUserID:        "user-123",
+			expectedEmailVerified: "false",
+			expectedOrg:           "acme",
+		},
+		{
+			name:                  "anonymous request drops client header",
+			spoofedUserID:         "user-456",
+			spoofedEmailHeader:    "true",
+			spoofedEmail:          "attacker@example.com",
+			spoofedRoles:          "admin",
+			spoofedOrg:            "other-org",
+			expectedUserID:        "",
+			expectedEmail:         "",
+			expectedEmailVerified: "",
+			expectedRoles:         "",
+			expectedOrg:           "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			var receivedHeaders http.Header
+			backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedHeaders = r.Header.Clone()
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer backendServer.Close()
+
+			proxyHandler, err := createReverseProxyWithRewrite(backendServer.URL, "/api/orders", "/api/v1/orders", false, http.DefaultTransport)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://gateway/api/orders", nil)
+			if tc.spoofedUserID != "" {
+				req.Header.Set(web.XUserId, tc.spoofedUserID)
+			}
+			if tc.spoofedEmailHeader != "" {
+				req.Header.Set(web.XUserEmailVerified, tc.spoofedEmailHeader)
+			}
+			if tc.spoofedEmail != "" {
+				req.Header.Set(web.XUserEmail, tc.spoofedEmail)
+			}
+			if tc.spoofedRoles != "" {
+				req.Header.Set(web.XUserRoles, tc.spoofedRoles)
+			}
+			if tc.spoofedOrg != "" {
+				req.Header.Set(web.XOrgId, tc.spoofedOrg)
+			}
+			if tc.userID != "" {
+				ctx := context.WithValue(req.Context(), middleware.UserIDContextKey, tc.userID)
+				ctx = context.WithValue(ctx, middleware.EmailVerifiedContextKey, tc.emailVerified)
+				ctx = context.WithValue(ctx, middleware.EmailContextKey, tc.email)
+				ctx = context.WithValue(ctx, middleware.RolesContextKey, tc.roles)
+				ctx = context.WithValue(ctx, middleware.OrgContextKey, tc.org)
+				req = req.WithContext(ctx)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			proxyHandler.ServeHTTP(rr, req)
+
+			// then
+			require.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.expectedUserID, receivedHeaders.Get(web.XUserId))
+			assert.Equal(t, tc.expectedEmailVerified, receivedHeaders.Get(web.XUserEmailVerified))
+			assert.Equal(t, tc.expectedEmail, receivedHeaders.Get(web.XUserEmail))
+			assert.Equal(t, tc.expectedRoles, receivedHeaders.Get(web.XUserRoles))
+			assert.Equal(t, tc.expectedOrg, receivedHeaders.Get(web.XOrgId))
+		})
+	}
+}
+
+func TestCreateReverseProxyWithRewrite_NormalizeErrors(t *testing.T) {
+	testCases := []struct {
+		name                string
+		normalizeErrors     bool
+		upstreamStatus      int
+		upstreamContentType string
+		upstreamBody        string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "plaintext 500 is wrapped into JSON error",
+			normalizeErrors:     true,
+			upstreamStatus:      http.StatusInternalServerError,
+			upstreamContentType: "text/plain; charset=utf-8",
+			upstreamBody:        "something went wrong",
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"Internal Server Error","code":"INTERNAL_ERROR"}`,
+		},
+		{
+			name:                "HTML 502 is wrapped into JSON error",
+			normalizeErrors:     true,
+			upstreamStatus:      http.StatusBadGateway,
+			upstreamContentType: "text/html",
+			upstreamBody:        "<html><body>Bad Gateway</body></html>",
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"Bad Gateway","code":"BAD_GATEWAY"}`,
+		},
+		{
+			name:                "JSON error is forwarded as-is",
+			normalizeErrors:     true,
+			upstreamStatus:      http.StatusNotFound,
+			upstreamContentType: "application/json",
+			upstreamBody:        `{"error":"Product not found","code":"PRODUCT_NOT_FOUND"}`,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"Product not found","code":"PRODUCT_NOT_FOUND"}`,
+		},
+		{
+			name:                "successful plaintext response is forwarded as-is",
+			normalizeErrors:     true,
+			upstreamStatus:      http.StatusOK,
+			upstreamContentType: "text/plain",
+			upstreamBody:        "ok",
+			expectedContentType: "text/plain",
+			expectedBody:        "ok",
+		},
+		{
+			name:                "disabled - plaintext 500 is forwarded as-is",
+			normalizeErrors:     false,
+			upstreamStatus:      http.StatusInternalServerError,
+			upstreamContentType: "text/plain",
+			upstreamBody:        "something went wrong",
+			expectedContentType: "text/plain",
+			expectedBody:        "something went wrong",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.upstreamContentType)
+				w.WriteHeader(tc.upstreamStatus)
+				_, _ = w.Write([]byte(tc.upstreamBody))
+			}))
+			defer backendServer.Close()
+
+			proxyHandler, err := createReverseProxyWithRewrite(backendServer.URL, "/api/products", "/api/v1/products", tc.normalizeErrors, http.DefaultTransport)
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodGet, "http://gateway/api/products/123", nil)
+			rr := httptest.NewRecorder()
+
+			// when
+			proxyHandler.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.upstreamStatus, rr.Code)
+			assert.Equal(t, tc.expectedContentType, rr.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestCreateReverseProxyWithRewrite_RequestTimeout(t *testing.T) {
+	testCases := []struct {
+		name            string
+		requestTimeout  string
+		clientDeadline  time.Duration
+		expectedCode    int
+		expectCancelled bool
+	}{
+		{
+			name:            "X-Request-Timeout cancels the upstream request",
+			requestTimeout:  "50ms",
+			expectedCode:    http.StatusGatewayTimeout,
+			expectCancelled: true,
+		},
+		{
+			name:            "client context deadline cancels the upstream request",
+			clientDeadline:  50 * time.Millisecond,
+			expectedCode:    http.StatusGatewayTimeout,
+			expectCancelled: true,
+		},
+		{
+			name:           "invalid X-Request-Timeout is ignored",
+			requestTimeout: "soon",
+			expectedCode:   http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			const upstreamDelay = 500 * time.Millisecond
+			cancelled := make(chan bool, 1)
+			backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+					cancelled <- true
+				case <-time.After(upstreamDelay):
+					cancelled <- false
+					w.WriteHeader(http.StatusOK)
+				}
+			}))
+			defer backendServer.Close()
+
+			proxyHandler, err := createReverseProxyWithRewrite(backendServer.URL, "/api/products", "/api/v1/products", false, http.DefaultTransport)
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodGet, "http://gateway/api/products/123", nil)
+			if tc.requestTimeout != "" {
+				req.Header.Set(XRequestTimeout, tc.requestTimeout)
+			}
+			if tc.clientDeadline > 0 {
+				ctx, cancel := context.WithTimeout(req.Context(), tc.clientDeadline)
+				defer cancel()
+				req = req.WithContext(ctx)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			start := time.Now()
+			proxyHandler.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			select {
+			case upstreamCancelled := <-cancelled:
+				assert.Equal(t, tc.expectCancelled, upstreamCancelled, "upstream context cancellation should match")
+			case <-time.After(upstreamDelay):
+				require.Fail(t, "upstream handler did not finish")
+			}
+			if tc.expectCancelled {
+				assert.Less(t, time.Since(start), upstreamDelay, "the proxy should not wait for the upstream")
+			}
+		})
+	}
+}
+
+// healthClientStub reports the configured status of the user service.
+type healthClientStub struct {
+	healthpb.HealthClient
+	status healthpb.HealthCheckResponse_ServingStatus
+}
+
+func (h healthClientStub) Check(_ context.Context, _ *healthpb.HealthCheckRequest, _ ...grpc.CallOption) (*healthpb.HealthCheckResponse, error) {
+	return &healthpb.HealthCheckResponse{Status: h.status}, nil
+}
+
+// newProbeServer returns a server answering the health checks with 200 if up, or 503 if down.
+func newProbeServer(t *testing.T, up bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGW_Ready(t *testing.T) {
+	// dependencies holds whether every dependency is up and whether it's non-critical
+	type dependencies struct {
+		productUp, orderUp, userUp, jwksUp                         bool
+		productOptional, orderOptional, userOptional, jwksOptional bool
+	}
+	testCases := []struct {
+		name         string
+		deps         dependencies
+		expectedCode int
+	}{
+		{
+			name:         "all dependencies up",
+			deps:         dependencies{productUp: true, orderUp: true, userUp: true, jwksUp: true},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "critical dependency down",
+			deps:         dependencies{productUp: false, orderUp: true, userUp: true, jwksUp: true},
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "critical gRPC dependency down",
+			deps:         dependencies{productUp: true, orderUp: true, userUp: false, jwksUp: true},
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "non-critical dependency down",
+			deps:         dependencies{productUp: true, orderUp: false, userUp: true, jwksUp: true, orderOptional: true},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "all non-critical dependencies down",
+			deps: dependencies{productUp: true, orderUp: false, userUp: false, jwksUp: false,
+				orderOptional: true, userOptional: true, jwksOptional: true},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "critical and non-critical dependencies down",
+			deps:         dependencies{productUp: true, orderUp: false, userUp: true, jwksUp: false, orderOptional: true},
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "non-critical dependency up, critical one down",
+			deps:         dependencies{productUp: false, orderUp: true, userUp: true, jwksUp: true, orderOptional: true},
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			var services sCfg.Services
+			services.Product.Url = newProbeServer(t, tc.deps.productUp).URL
+			services.Product.Optional = tc.deps.productOptional
+			services.Order.Url = newProbeServer(t, tc.deps.orderUp).URL
+			services.Order.Optional = tc.deps.orderOptional
+			services.User.Optional = tc.deps.userOptional
+			services.Jwks.Optional = tc.deps.jwksOptional
+			userStatus := healthpb.HealthCheckResponse_SERVING
+			if !tc.deps.userUp {
+				userStatus = healthpb.HealthCheckResponse_NOT_SERVING
+			}
+			userService := service.NewUserService(nil, healthClientStub{status: userStatus})
+			jwksURL := newProbeServer(t, tc.deps.jwksUp).URL
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			gw := NewGW(config.HTTPConfig{}, userService, services, sCfg.ProxyConfig{}, config.HTTPClientConfig{Timeout: 2 * time.Second}, jwksURL, logger)
+			rr := httptest.NewRecorder()
+
+			// when
+			gw.Ready(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code)
+		})
+	}
+}
+
+func TestGW_SetupHTTPServer_ProductRoutes(t *testing.T) {
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{name: "anonymous product list is proxied", method: http.MethodGet, path: "/api/v1/products", expectedCode: http.StatusOK},
+		{name: "batch creation requires a token", method: http.MethodPost, path: "/api/v1/products/batch", expectedCode: http.StatusUnauthorized},
+		{name: "price history requires a token", method: http.MethodGet, path: "/api/v1/products/123/price-history", expectedCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			var services sCfg.Services
+			services.Product.Url = newProbeServer(t, true).URL
+			services.Product.From = "/api/v1/products"
+			services.Product.To = "/api/v1/products"
+			services.Order.Url = newProbeServer(t, true).URL
+			services.Order.From = "/api/v1/orders"
+			services.Order.To = "/api/v1/orders"
+			services.User.From = "/api/v1/users"
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			gw := NewGW(config.HTTPConfig{}, nil, services, sCfg.ProxyConfig{}, config.HTTPClientConfig{Timeout: 2 * time.Second}, "", logger)
+			server, err := gw.SetupHTTPServer(nil)
+			require.NoError(t, err)
+			rr := httptest.NewRecorder()
+
+			// when
+			server.Handler.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,5 @@
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS total_price BIGINT NOT NULL DEFAULT 0;
+
+UPDATE orders o
+SET total_price = (SELECT COALESCE(SUM(i.price), 0) FROM order_items i WHERE i.order_id = o.id);
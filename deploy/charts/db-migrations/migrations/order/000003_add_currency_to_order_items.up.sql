@@ -0,0 +1,3 @@
+-- the currency of the product price at the order time, the existing items were priced in USD
+ALTER TABLE order_items
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
@@ -0,0 +1,4 @@
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS deleted_at;
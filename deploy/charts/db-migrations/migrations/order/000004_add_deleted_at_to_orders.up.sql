@@ -0,0 +1,5 @@
+-- deleted orders and their items are kept for the audit trail, but excluded from all lookups
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL;
+ALTER TABLE order_items
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL;
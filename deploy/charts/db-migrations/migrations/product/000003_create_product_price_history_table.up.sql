@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS product_price_history
+(
+    product_id  UUID      NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    price       BIGINT    NOT NULL,
+    recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_price_history_product_recorded_at
+    ON product_price_history (product_id, recorded_at);
+
+-- the current price of the existing products is the start of their history
+INSERT INTO product_price_history (product_id, price, recorded_at)
+SELECT id, price, created_at
+FROM products;
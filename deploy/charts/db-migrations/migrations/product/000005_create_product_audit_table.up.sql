@@ -0,0 +1,27 @@
+-- product_id has no foreign key, so the history of a product outlives its deletion
+CREATE TABLE IF NOT EXISTS product_audit
+(
+    id         BIGSERIAL PRIMARY KEY,
+    product_id UUID        NOT NULL,
+    action     VARCHAR(32) NOT NULL,
+    old_values JSONB,
+    new_values JSONB,
+    version    INTEGER     NOT NULL,
+    changed_at TIMESTAMP   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_audit_product_id
+    ON product_audit (product_id, id);
+
+-- the current values of the existing products are the start of their history
+INSERT INTO product_audit (product_id, action, new_values, version, changed_at)
+SELECT id,
+       'create',
+       jsonb_build_object('name', name,
+                          'price', price,
+                          'stock_quantity', stock_quantity,
+                          'restock_at', restock_at AT TIME ZONE 'UTC',
+                          'deleted_at', deleted_at AT TIME ZONE 'UTC'),
+       version,
+       created_at
+FROM products;
@@ -0,0 +1,4 @@
+-- internal products are only visible to the staff, the existing products stay public
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS visibility VARCHAR(16) NOT NULL DEFAULT 'public'
+        CONSTRAINT products_visibility_check CHECK (visibility IN ('public', 'internal'));
@@ -0,0 +1,9 @@
+-- the time of the last change of a product, the existing products were last changed when created
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+UPDATE products
+SET updated_at = created_at
+WHERE updated_at IS NULL;
+ALTER TABLE products
+    ALTER COLUMN updated_at SET DEFAULT NOW(),
+    ALTER COLUMN updated_at SET NOT NULL;
@@ -0,0 +1,3 @@
+-- the ISO 4217 code of the currency of the price, the existing products are priced in USD
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
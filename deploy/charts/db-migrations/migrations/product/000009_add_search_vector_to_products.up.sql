@@ -0,0 +1,7 @@
+-- the lexemes of the name matched by the product search, kept up to date by Postgres
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+        GENERATED ALWAYS AS (to_tsvector('english', name)) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_products_search_vector
+    ON products USING GIN (search_vector);
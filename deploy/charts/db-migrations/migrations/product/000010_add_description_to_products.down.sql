@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_products_search_vector;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS description;
+
+ALTER TABLE products
+    ADD COLUMN search_vector TSVECTOR
+        GENERATED ALWAYS AS (to_tsvector('english', name)) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_products_search_vector
+    ON products USING GIN (search_vector);
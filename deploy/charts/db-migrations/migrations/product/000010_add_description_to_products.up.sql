@@ -0,0 +1,16 @@
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS description TEXT;
+
+-- the description is searched as well, its matches are less relevant than the ones of the name
+DROP INDEX IF EXISTS idx_products_search_vector;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS search_vector;
+
+ALTER TABLE products
+    ADD COLUMN search_vector TSVECTOR
+        GENERATED ALWAYS AS (setweight(to_tsvector('english', name), 'A') ||
+                             setweight(to_tsvector('english', coalesce(description, '')), 'B')) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_products_search_vector
+    ON products USING GIN (search_vector);
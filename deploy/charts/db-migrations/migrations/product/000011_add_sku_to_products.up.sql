@@ -0,0 +1,6 @@
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS sku TEXT;
+
+-- the external SKU of the imported products, a soft-deleted product doesn't hold its SKU
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_sku
+    ON products (sku) WHERE deleted_at IS NULL;
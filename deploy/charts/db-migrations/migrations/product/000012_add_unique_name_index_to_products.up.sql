@@ -0,0 +1,5 @@
+-- the names of the live products are unique ignoring the case, a soft-deleted product doesn't hold its name.
+-- The index is built without blocking the writes, which can't run in a transaction, so it's the only statement here.
+-- It fails if live products already share a name: rename them, drop the invalid index and migrate again.
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS idx_products_name_unique
+    ON products (lower(name)) WHERE deleted_at IS NULL;
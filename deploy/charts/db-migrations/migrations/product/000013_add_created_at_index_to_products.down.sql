@@ -0,0 +1 @@
+DROP INDEX CONCURRENTLY IF EXISTS idx_products_created_at_id;
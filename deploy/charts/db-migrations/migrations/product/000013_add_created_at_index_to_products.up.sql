@@ -0,0 +1,4 @@
+-- the keyset of the cursor pagination of the live products, newest first, see FindFirstPage and FindPageAfter.
+-- The index is built without blocking the writes, which can't run in a transaction, so it's the only statement here.
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_products_created_at_id
+    ON products (created_at DESC, id DESC) WHERE deleted_at IS NULL;
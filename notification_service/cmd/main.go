@@ -2,23 +2,26 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"log/slog"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/abgdnv/gocommerce/notification_service/internal/config"
+	"github.com/abgdnv/gocommerce/notification_service/internal/dedup"
+	"github.com/abgdnv/gocommerce/notification_service/internal/email"
+	"github.com/abgdnv/gocommerce/notification_service/internal/probes"
 	"github.com/abgdnv/gocommerce/notification_service/internal/subscriber"
+	"github.com/abgdnv/gocommerce/notification_service/internal/webhook"
 	"github.com/abgdnv/gocommerce/pkg/bootstrap"
 	"github.com/abgdnv/gocommerce/pkg/config/configloader"
+	"github.com/abgdnv/gocommerce/pkg/health"
 	"github.com/abgdnv/gocommerce/pkg/nats"
+	"github.com/abgdnv/gocommerce/pkg/server"
 	"github.com/abgdnv/gocommerce/pkg/telemetry"
-	"golang.org/x/sync/errgroup"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
 const serviceName = "notification"
@@ -40,10 +43,9 @@ func run(ctx context.Context) error {
 	if cfgErr != nil {
 		return fmt.Errorf("failed to load configuration: %w", cfgErr)
 	}
-	log.Printf("Configuration loaded: %v", cfg)
-
-	logger := bootstrap.NewLogger(cfg.Log.Level)
+	logger := bootstrap.NewLogger(cfg.Log.Level, cfg.Log.Format, cfg.Log.AddSource)
 	slog.SetDefault(logger)
+	bootstrap.LogEffectiveConfig(logger, cfg)
 
 	// create tracer provider
 	tracerProvider, err := telemetry.NewTracerProvider(ctx, serviceName, cfg.Telemetry)
@@ -52,7 +54,7 @@ func run(ctx context.Context) error {
 		return err
 	}
 
-	natsConn, err := nats.NewClient(cfg.Nats.Url, cfg.Nats.Timeout)
+	natsConn, err := nats.NewClient(ctx, cfg.Nats, logger)
 	if err != nil {
 		return fmt.Errorf("failed to create NATS connection: %w", err)
 	}
@@ -61,88 +63,115 @@ func run(ctx context.Context) error {
 		return fmt.Errorf("failed to get JetStream context: %w", err)
 	}
 
-	// create readiness probe file and remove it on shutdown
-	if err := os.WriteFile(cfg.ProbesConfig.ReadinessFileName, []byte("ok"), 0644); err != nil {
-		slog.Error("failed to create readiness probe file", "error", err)
+	renderer, err := email.NewNotificationRenderer(cfg.Email.TemplatesDir)
+	if err != nil {
+		return fmt.Errorf("failed to load notification templates: %w", err)
 	}
-	defer func() {
-		err := os.Remove(cfg.ProbesConfig.ReadinessFileName)
-		if err != nil {
-			logger.Error("Can't delete file", "file", cfg.ProbesConfig.ReadinessFileName)
+	var sender email.Sender = email.NoopSender{}
+	if cfg.Email.Enabled {
+		sender = email.NewSMTPSender(cfg.Email)
+	} else {
+		logger.Warn("Email sending is disabled, notifications will not be delivered")
+	}
+	dlq := subscriber.NewStreamDeadLetterer(js, cfg.Subscriber.DeadLetterPrefix)
+	notifier := subscriber.NewNotifier(sender, renderer, dlq)
+	handlers := notifier.Handlers()
+	var webhookHandlers map[string]subscriber.Handler
+	if cfg.Webhook.Enabled {
+		subjects := make([]string, 0, len(handlers))
+		for subject := range handlers {
+			subjects = append(subjects, subject)
 		}
-	}()
-
-	g, gCtx := errgroup.WithContext(ctx)
-
-	g.Go(func() error {
-		logger.Info("NATS subscriber started")
-		err := subscriber.Start(gCtx, js, cfg.Subscriber, logger)
-		if err != nil && !errors.Is(err, context.Canceled) {
-			logger.Error("subscriber failed", "error", err)
-			return err
+		webhookHandlers = subscriber.WebhookHandlers(subjects, webhook.NewSender(cfg.Webhook), dlq, cfg.Webhook.MaxAttempts, cfg.Webhook.RetryBackoff)
+		handlers = subscriber.WithWebhooks(handlers, subscriber.NewStreamWebhookQueue(js, cfg.Webhook.Endpoints))
+	}
+	if cfg.Dedup.Enabled {
+		store, err := newDedupStore(ctx, js, cfg.Dedup)
+		if err != nil {
+			return fmt.Errorf("failed to create dedup store: %w", err)
 		}
-		logger.Info("subscriber stopped gracefully.")
-		return nil
-	})
+		handlers = subscriber.Deduplicate(handlers, store)
+	}
 
+	// components are shut down in reverse order: subscriber first, then NATS connection and tracer provider
+	subscriberDone := make(chan struct{})
+	components := []bootstrap.Component{
+		&bootstrap.FuncComponent{
+			ComponentName: "tracer provider",
+			ShutdownFn:    tracerProvider.Shutdown,
+		},
+		&bootstrap.FuncComponent{
+			ComponentName: "NATS connection",
+			ShutdownFn: func(_ context.Context) error {
+				return natsConn.Drain()
+			},
+		},
+		probes.NewComponent(cfg.ProbesConfig, map[string]health.Check{
+			"nats": health.NATSConn(natsConn),
+		}, logger),
+		&bootstrap.FuncComponent{
+			ComponentName: "NATS subscriber",
+			StartFn: func(ctx context.Context) error {
+				defer close(subscriberDone)
+				return subscriber.Start(ctx, js, cfg.Subscriber, handlers, cfg.Shutdown.Timeout, logger)
+			},
+			// the NATS connection is drained after the in-flight messages are handled
+			ShutdownFn: func(ctx context.Context) error {
+				select {
+				case <-subscriberDone:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+		},
+	}
+	if cfg.Webhook.Enabled {
+		components = append(components, newWebhookSubscriber(js, cfg, webhookHandlers, logger))
+	}
 	// Start the pprof server if enabled
 	if cfg.PProf.Enabled {
-		pprofServer := &http.Server{
-			Addr: cfg.PProf.Addr,
-		}
-		g.Go(func() error {
-			logger.Info("Pprof server listening", slog.String("addr", pprofServer.Addr))
-			if err := pprofServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				return fmt.Errorf("pprof server failed: %w", err)
-			}
-			return nil
-		})
-		// gracefully shutdown pprof server on context cancellation
-		g.Go(func() error {
-			<-gCtx.Done()
-			logger.Info("Shutting down pprof server")
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-			defer cancel()
-			return pprofServer.Shutdown(shutdownCtx)
-		})
+		pprofServer := server.NewPProfServer(cfg.PProf)
+		components = append(components, bootstrap.NewHTTPServerComponent("pprof server", pprofServer, logger))
 	}
 
-	// Create liveness probe file and update it periodically
-	g.Go(func() error {
-		if err := os.WriteFile(cfg.ProbesConfig.LivenessFileName, []byte("ok"), 0644); err != nil {
-			return fmt.Errorf("failed to create liveness probe file: %w", err)
-		}
-		ticker := time.NewTicker(cfg.ProbesConfig.LivenessInterval)
-		defer ticker.Stop()
-		for {
+	if err := bootstrap.RunServers(ctx, components, cfg.Shutdown.Timeout, logger); err != nil {
+		return fmt.Errorf("failed to run servers: %w", err)
+	}
+	return nil
+}
+
+// newWebhookSubscriber creates the component delivering the queued webhook deliveries with the handlers.
+// It creates the webhook stream if it doesn't exist, as the notification service is its only publisher and consumer.
+// The NATS connection is drained after the in-flight deliveries are handled.
+func newWebhookSubscriber(js jetstream.JetStream, cfg *config.Config, handlers map[string]subscriber.Handler, logger *slog.Logger) bootstrap.Component {
+	done := make(chan struct{})
+	return &bootstrap.FuncComponent{
+		ComponentName: "webhook subscriber",
+		StartFn: func(ctx context.Context) error {
+			defer close(done)
+			subscriberCfg := cfg.WebhookSubscriber()
+			stream := webhook.StreamConfig(subscriberCfg.Stream)
+			if _, err := nats.EnsureStream(ctx, js, stream, true, subscriberCfg.StreamRetryInterval(), logger); err != nil {
+				return fmt.Errorf("webhook stream %s is not available: %w", subscriberCfg.Stream, err)
+			}
+			return subscriber.Start(ctx, js, subscriberCfg, handlers, cfg.Shutdown.Timeout, logger)
+		},
+		ShutdownFn: func(ctx context.Context) error {
 			select {
-			case <-gCtx.Done():
-				_ = os.Remove(cfg.ProbesConfig.LivenessFileName)
+			case <-done:
 				return nil
-			case <-ticker.C:
-				if err := os.Chtimes(cfg.ProbesConfig.LivenessFileName, time.Now(), time.Now()); err != nil {
-					slog.Error("Failed to update liveness probe file", "error", err)
-				}
+			case <-ctx.Done():
+				return ctx.Err()
 			}
-		}
-	})
-	// gracefully shutdown tracer provider
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down tracer provider")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-		defer cancel()
-		if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
-			return fmt.Errorf("failed to shutdown tracer provider: %v", err)
-		}
-		return nil
-	})
-
-	if err := g.Wait(); err != nil {
-		if !errors.Is(err, context.Canceled) {
-			return fmt.Errorf("errgroup encountered an error: %w", err)
-		}
+		},
 	}
+}
 
-	return nil
+// newDedupStore creates the configured store of the processed event IDs.
+func newDedupStore(ctx context.Context, js jetstream.JetStream, cfg config.DedupConfig) (dedup.Store, error) {
+	if cfg.Store == config.DedupStoreMemory {
+		return dedup.NewMemoryStore(cfg.TTL), nil
+	}
+	return dedup.NewKVStore(ctx, js, cfg.Bucket, cfg.TTL)
 }
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"fmt"
+	"log"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/abgdnv/gocommerce/pkg/config/configloader"
@@ -17,6 +21,207 @@ type Config struct {
 	ProbesConfig config.ProbesConfig     `koanf:"probes"`
 	Telemetry    config.TelemetryConfig  `koanf:"telemetry"`
 	Shutdown     config.ShutdownConfig   `koanf:"shutdown"`
+	Email        EmailConfig             `koanf:"email"`
+	Dedup        DedupConfig             `koanf:"dedup"`
+	Webhook      WebhookConfig           `koanf:"webhook"`
+}
+
+// Dedup stores of the processed event IDs.
+const (
+	DedupStoreMemory = "memory"
+	DedupStoreNATS   = "nats"
+)
+
+const (
+	defaultDedupBucket = "notification_processed_events"
+	defaultDedupTTL    = 24 * time.Hour
+)
+
+// DedupConfig controls skipping of the events which were already processed, as JetStream delivers them at least once.
+// The processed event IDs are kept for TTL in the NATS key-value bucket, or in memory of a single instance.
+type DedupConfig struct {
+	Enabled bool          `koanf:"enabled"`
+	Store   string        `koanf:"store"`
+	Bucket  string        `koanf:"bucket"`
+	TTL     time.Duration `koanf:"ttl"`
+}
+
+// String returns a string representation of the dedup configuration.
+func (c *DedupConfig) String() string {
+	var b strings.Builder
+	b.WriteString("\n--- Dedup ---\n")
+	b.WriteString(fmt.Sprintf("  enabled: %t\n", c.Enabled))
+	b.WriteString(fmt.Sprintf("  store: %s\n", c.Store))
+	b.WriteString(fmt.Sprintf("  bucket: %s\n", c.Bucket))
+	b.WriteString(fmt.Sprintf("  ttl: %s\n", c.TTL))
+	return b.String()
+}
+
+func (c *DedupConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Store == "" {
+		log.Println("Using default value for dedup store")
+		c.Store = DedupStoreNATS
+	}
+	if c.Store != DedupStoreNATS && c.Store != DedupStoreMemory {
+		return fmt.Errorf("DedupConfig: store must be %s or %s, got %q", DedupStoreNATS, DedupStoreMemory, c.Store)
+	}
+	if c.Bucket == "" {
+		log.Println("Using default value for dedup bucket")
+		c.Bucket = defaultDedupBucket
+	}
+	if c.TTL < 0 {
+		return fmt.Errorf("DedupConfig: ttl must not be negative")
+	}
+	if c.TTL == 0 {
+		log.Println("Using default value for dedup ttl")
+		c.TTL = defaultDedupTTL
+	}
+	return nil
+}
+
+// EmailConfig holds the SMTP server settings and the location of the email templates.
+// If sending is disabled, emails are rendered but not sent.
+type EmailConfig struct {
+	Enabled      bool   `koanf:"enabled"`
+	Host         string `koanf:"host"`
+	Port         int    `koanf:"port"`
+	Username     string `koanf:"username"`
+	Password     string `koanf:"password"`
+	From         string `koanf:"from"`
+	TemplatesDir string `koanf:"templatesdir"`
+}
+
+// String returns a string representation of the email configuration. The password is masked.
+func (c *EmailConfig) String() string {
+	var b strings.Builder
+	b.WriteString("\n--- Email ---\n")
+	b.WriteString(fmt.Sprintf("  enabled: %t\n", c.Enabled))
+	b.WriteString(fmt.Sprintf("  host: %s\n", c.Host))
+	b.WriteString(fmt.Sprintf("  port: %d\n", c.Port))
+	b.WriteString(fmt.Sprintf("  username: %s\n", c.Username))
+	b.WriteString(fmt.Sprintf("  password: %s\n", config.Mask(c.Password)))
+	b.WriteString(fmt.Sprintf("  from: %s\n", c.From))
+	b.WriteString(fmt.Sprintf("  templatesDir: %s\n", c.TemplatesDir))
+	return b.String()
+}
+
+func (c *EmailConfig) Validate() error {
+	if c.TemplatesDir == "" {
+		return fmt.Errorf("EmailConfig: templatesDir is not configured")
+	}
+	if !c.Enabled {
+		return nil
+	}
+	if c.Host == "" {
+		return fmt.Errorf("EmailConfig: host is not configured")
+	}
+	if c.Port <= 0 {
+		return fmt.Errorf("EmailConfig: port must be greater than zero")
+	}
+	if c.From == "" {
+		return fmt.Errorf("EmailConfig: from is not configured")
+	}
+	return nil
+}
+
+const (
+	defaultWebhookTimeout      = 5 * time.Second
+	defaultWebhookMaxAttempts  = 3
+	defaultWebhookRetryBackoff = 500 * time.Millisecond
+	defaultWebhookStream       = "WEBHOOKS"
+	defaultWebhookConsumer     = "notification_webhooks"
+	// webhookSubjects matches the subjects of the queued deliveries, see webhook.SubjectPrefix.
+	webhookSubjects = "webhooks.>"
+	// webhookAckWait is the default ack wait of JetStream consumers, a delivery attempt must end before it.
+	webhookAckWait = 30 * time.Second
+)
+
+// WebhookConfig holds the subscriber URLs the order events are posted to, in addition to the emails.
+// The payloads are signed with the shared secret, see webhook.SignatureHeader.
+// The deliveries are queued in the stream, one per endpoint, and made by the durable consumer.
+type WebhookConfig struct {
+	Enabled   bool     `koanf:"enabled"`
+	Endpoints []string `koanf:"endpoints"`
+	Secret    string   `koanf:"secret"`
+	// Stream is the stream of the queued deliveries, it is created if it doesn't exist.
+	Stream string `koanf:"stream"`
+	// Consumer is the durable consumer of the queued deliveries.
+	Consumer string `koanf:"consumer"`
+	// Timeout limits a single delivery attempt, it must be shorter than the ack wait of the consumer.
+	Timeout time.Duration `koanf:"timeout"`
+	// MaxAttempts is the number of attempts of a delivery to an endpoint, a delivery still failing is dead-lettered.
+	MaxAttempts int `koanf:"maxattempts"`
+	// RetryBackoff is the wait before the first retry, doubled for every further retry.
+	RetryBackoff time.Duration `koanf:"retrybackoff"`
+}
+
+// String returns a string representation of the webhook configuration. The secret is masked.
+func (c *WebhookConfig) String() string {
+	var b strings.Builder
+	b.WriteString("\n--- Webhook ---\n")
+	b.WriteString(fmt.Sprintf("  enabled: %t\n", c.Enabled))
+	b.WriteString(fmt.Sprintf("  endpoints: %s\n", strings.Join(c.Endpoints, ",")))
+	b.WriteString(fmt.Sprintf("  secret: %s\n", config.Mask(c.Secret)))
+	b.WriteString(fmt.Sprintf("  stream: %s\n", c.Stream))
+	b.WriteString(fmt.Sprintf("  consumer: %s\n", c.Consumer))
+	b.WriteString(fmt.Sprintf("  timeout: %s\n", c.Timeout))
+	b.WriteString(fmt.Sprintf("  maxattempts: %d\n", c.MaxAttempts))
+	b.WriteString(fmt.Sprintf("  retrybackoff: %s\n", c.RetryBackoff))
+	return b.String()
+}
+
+func (c *WebhookConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Endpoints) == 0 {
+		return fmt.Errorf("WebhookConfig: endpoints are not configured")
+	}
+	for _, endpoint := range c.Endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("WebhookConfig: invalid endpoint %q, must be an http or https URL", endpoint)
+		}
+	}
+	if c.Secret == "" {
+		return fmt.Errorf("WebhookConfig: secret is not configured")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("WebhookConfig: timeout must not be negative")
+	}
+	if c.Timeout == 0 {
+		log.Println("Using default value for webhook timeout")
+		c.Timeout = defaultWebhookTimeout
+	}
+	if c.Timeout >= webhookAckWait {
+		return fmt.Errorf("WebhookConfig: timeout must be shorter than the ack wait of %s", webhookAckWait)
+	}
+	if c.Stream == "" {
+		log.Println("Using default value for webhook stream")
+		c.Stream = defaultWebhookStream
+	}
+	if c.Consumer == "" {
+		log.Println("Using default value for webhook consumer")
+		c.Consumer = defaultWebhookConsumer
+	}
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("WebhookConfig: maxattempts must not be negative")
+	}
+	if c.MaxAttempts == 0 {
+		log.Println("Using default value for webhook maxattempts")
+		c.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("WebhookConfig: retrybackoff must not be negative")
+	}
+	if c.RetryBackoff == 0 {
+		log.Println("Using default value for webhook retrybackoff")
+		c.RetryBackoff = defaultWebhookRetryBackoff
+	}
+	return nil
 }
 
 func (c *Config) String() string {
@@ -28,6 +233,9 @@ func (c *Config) String() string {
 	b.WriteString(c.ProbesConfig.String())
 	b.WriteString(c.Telemetry.String())
 	b.WriteString(c.Shutdown.String())
+	b.WriteString(c.Email.String())
+	b.WriteString(c.Dedup.String())
+	b.WriteString(c.Webhook.String())
 	return b.String()
 }
 
@@ -54,6 +262,26 @@ func (c *Config) Validate() error {
 	if err := c.Shutdown.Validate(); err != nil {
 		return err
 	}
+	if err := c.Email.Validate(); err != nil {
+		return err
+	}
+	if err := c.Dedup.Validate(); err != nil {
+		return err
+	}
+	if err := c.Webhook.Validate(); err != nil {
+		return err
+	}
 
 	return nil
 }
+
+// WebhookSubscriber returns the configuration of the consumer of the queued webhook deliveries,
+// which fetches and handles them like the subscriber of the events.
+func (c *Config) WebhookSubscriber() config.SubscriberConfig {
+	subscriber := c.Subscriber
+	subscriber.Stream = c.Webhook.Stream
+	subscriber.Subject = ""
+	subscriber.Subjects = []string{webhookSubjects}
+	subscriber.Consumer = c.Webhook.Consumer
+	return subscriber
+}
@@ -0,0 +1,50 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// KVStore keeps the processed event IDs in a JetStream key-value bucket, which expires them after the TTL of the bucket.
+// It is shared by all instances of the service and survives restarts.
+type KVStore struct {
+	kv jetstream.KeyValue
+}
+
+// NewKVStore creates a KVStore, creating the bucket or updating its TTL if it already exists.
+func NewKVStore(ctx context.Context, js jetstream.JetStream, bucket string, ttl time.Duration) (*KVStore, error) {
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      bucket,
+		Description: "IDs of the processed events",
+		TTL:         ttl,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key-value bucket %s: %w", bucket, err)
+	}
+	return &KVStore{kv: kv}, nil
+}
+
+// Seen reports whether the event with the ID is recorded in the bucket.
+func (s *KVStore) Seen(ctx context.Context, id uuid.UUID) (bool, error) {
+	_, err := s.kv.Get(ctx, id.String())
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get processed event %s: %w", id, err)
+	}
+	return true, nil
+}
+
+// MarkProcessed records the event with the ID in the bucket, with the processing time as the value.
+func (s *KVStore) MarkProcessed(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.kv.PutString(ctx, id.String(), time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("failed to record processed event %s: %w", id, err)
+	}
+	return nil
+}
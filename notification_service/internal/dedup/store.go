@@ -0,0 +1,65 @@
+// Package dedup records the IDs of processed events, so redelivered events can be skipped.
+package dedup
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store records the IDs of processed events for a limited time.
+type Store interface {
+	// Seen reports whether the event with the ID was recorded as processed and the record hasn't expired.
+	Seen(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// MarkProcessed records the event with the ID as processed.
+	MarkProcessed(ctx context.Context, id uuid.UUID) error
+}
+
+// MemoryStore keeps the processed event IDs in memory. It only deduplicates the events processed by
+// the same instance and forgets them on restart, so it is meant for tests and single instance setups.
+type MemoryStore struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	// expires holds the expiry time of every recorded event ID
+	expires   map[uuid.UUID]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates a MemoryStore, which keeps every event ID for ttl.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:     ttl,
+		expires: make(map[uuid.UUID]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Seen reports whether the event with the ID was recorded as processed within the TTL.
+func (s *MemoryStore) Seen(_ context.Context, id uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires, ok := s.expires[id]
+	return ok && s.now().Before(expires), nil
+}
+
+// MarkProcessed records the event with the ID as processed for the TTL.
+// The expired IDs are removed at most once per TTL.
+func (s *MemoryStore) MarkProcessed(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.lastSweep) >= s.ttl {
+		for recorded, expires := range s.expires {
+			if !now.Before(expires) {
+				delete(s.expires, recorded)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.expires[id] = now.Add(s.ttl)
+	return nil
+}
@@ -0,0 +1,55 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	// given
+	now := time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)
+	store := NewMemoryStore(time.Hour)
+	store.now = func() time.Time { return now }
+	processed, other := uuid.New(), uuid.New()
+
+	// when
+	require.NoError(t, store.MarkProcessed(context.Background(), processed))
+
+	// then
+	seen, err := store.Seen(context.Background(), processed)
+	require.NoError(t, err)
+	assert.True(t, seen, "a processed event should be seen")
+	seen, err = store.Seen(context.Background(), other)
+	require.NoError(t, err)
+	assert.False(t, seen, "an unknown event should not be seen")
+
+	// when
+	now = now.Add(time.Hour)
+
+	// then
+	seen, err = store.Seen(context.Background(), processed)
+	require.NoError(t, err)
+	assert.False(t, seen, "a processed event should be forgotten after the TTL")
+}
+
+func TestMemoryStore_SweepsExpired(t *testing.T) {
+	// given
+	now := time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)
+	store := NewMemoryStore(time.Hour)
+	store.now = func() time.Time { return now }
+	expired := uuid.New()
+	require.NoError(t, store.MarkProcessed(context.Background(), expired))
+	now = now.Add(2 * time.Hour)
+
+	// when
+	require.NoError(t, store.MarkProcessed(context.Background(), uuid.New()))
+
+	// then
+	assert.Len(t, store.expires, 1, "the expired event ID should be removed")
+	assert.NotContains(t, store.expires, expired)
+}
@@ -0,0 +1,121 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// ErrTemplateNotFound is returned when there is no template for the event type.
+var ErrTemplateNotFound = errors.New("notification template not found")
+
+const (
+	// textTemplateExt is the extension of the plain text templates, which define the "subject" and the "body" templates.
+	textTemplateExt = ".tmpl"
+	// htmlTemplateExt is the extension of the optional HTML templates, which define the "body" template.
+	htmlTemplateExt = ".html.tmpl"
+)
+
+// Notification is a rendered notification.
+type Notification struct {
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// notificationTemplate holds the templates of a single event type.
+type notificationTemplate struct {
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+// NotificationRenderer renders notifications from template files keyed by event type.
+// The templates of the event type "orders.created" are loaded from "orders.created.tmpl"
+// and the optional "orders.created.html.tmpl".
+type NotificationRenderer struct {
+	templates map[string]*notificationTemplate
+}
+
+// NewNotificationRenderer loads and parses all template files in the given directory.
+func NewNotificationRenderer(dir string) (*NotificationRenderer, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+textTemplateExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification templates: %w", err)
+	}
+	templates := make(map[string]*notificationTemplate)
+	get := func(eventType string) *notificationTemplate {
+		if templates[eventType] == nil {
+			templates[eventType] = &notificationTemplate{}
+		}
+		return templates[eventType]
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read notification template %s: %w", file, err)
+		}
+		name := filepath.Base(file)
+		if strings.HasSuffix(name, htmlTemplateExt) {
+			eventType := strings.TrimSuffix(name, htmlTemplateExt)
+			tmpl, err := htmltemplate.New(name).Parse(string(content))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse notification template %s: %w", file, err)
+			}
+			if tmpl.Lookup("body") == nil {
+				return nil, fmt.Errorf("notification template %s must define \"body\"", file)
+			}
+			get(eventType).html = tmpl
+			continue
+		}
+		eventType := strings.TrimSuffix(name, textTemplateExt)
+		tmpl, err := template.New(name).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse notification template %s: %w", file, err)
+		}
+		for _, required := range []string{"subject", "body"} {
+			if tmpl.Lookup(required) == nil {
+				return nil, fmt.Errorf("notification template %s must define %q", file, required)
+			}
+		}
+		get(eventType).text = tmpl
+	}
+	for eventType, tmpl := range templates {
+		if tmpl.text == nil {
+			return nil, fmt.Errorf("notification template %s%s is missing", eventType, textTemplateExt)
+		}
+	}
+	return &NotificationRenderer{templates: templates}, nil
+}
+
+// Render renders the notification of the event type with the given data.
+// Returns ErrTemplateNotFound if there is no template for the event type.
+func (r *NotificationRenderer) Render(eventType string, data any) (*Notification, error) {
+	tmpl, ok := r.templates[eventType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, eventType)
+	}
+	var subject, text bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render subject of %s: %w", eventType, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "body", data); err != nil {
+		return nil, fmt.Errorf("failed to render body of %s: %w", eventType, err)
+	}
+	notification := &Notification{
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: text.String(),
+	}
+	if tmpl.html != nil {
+		var html bytes.Buffer
+		if err := tmpl.html.ExecuteTemplate(&html, "body", data); err != nil {
+			return nil, fmt.Errorf("failed to render HTML body of %s: %w", eventType, err)
+		}
+		notification.HTMLBody = html.String()
+	}
+	return notification, nil
+}
@@ -0,0 +1,144 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// templatesDir is the location of the notification templates of the service relative to this package.
+const templatesDir = "../../templates"
+
+func TestNotificationRenderer_Render(t *testing.T) {
+	renderer, err := NewNotificationRenderer(templatesDir)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name             string
+		eventType        string
+		data             any
+		expectedErr      error
+		expectedSubject  string
+		expectedText     []string
+		expectedHTML     []string
+		expectedNoHTML   bool
+		notExpectedInAll []string
+	}{
+		{
+			name:      "order created",
+			eventType: "orders.created",
+			data: struct{ OrderID, TotalPrice, CreatedAt string }{
+				OrderID: "0b7f0f39-6f1e-4f35-9d54-0f2b2f0a8c11", TotalPrice: "123.45", CreatedAt: "Mon, 01 Jan 2024 10:00:00 UTC",
+			},
+			expectedSubject:  "Your order 0b7f0f39-6f1e-4f35-9d54-0f2b2f0a8c11 has been placed",
+			expectedText:     []string{"Order ID: 0b7f0f39-6f1e-4f35-9d54-0f2b2f0a8c11", "Total price: 123.45"},
+			expectedHTML:     []string{"<td>0b7f0f39-6f1e-4f35-9d54-0f2b2f0a8c11</td>", "<td>123.45</td>"},
+			notExpectedInAll: []string{"{{", "}}"},
+		},
+		{
+			name:      "order completed without HTML template",
+			eventType: "orders.completed",
+			data: struct{ OrderID, CompletedAt string }{
+				OrderID: "0b7f0f39-6f1e-4f35-9d54-0f2b2f0a8c11", CompletedAt: "Mon, 01 Jan 2024 10:00:00 UTC",
+			},
+			expectedSubject:  "Your order 0b7f0f39-6f1e-4f35-9d54-0f2b2f0a8c11 has been completed",
+			expectedText:     []string{"0b7f0f39-6f1e-4f35-9d54-0f2b2f0a8c11", "Mon, 01 Jan 2024 10:00:00 UTC"},
+			expectedNoHTML:   true,
+			notExpectedInAll: []string{"{{", "}}"},
+		},
+		{
+			name:        "unknown event type",
+			eventType:   "orders.unknown",
+			expectedErr: ErrTemplateNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// when
+			notification, err := renderer.Render(tc.eventType, tc.data)
+
+			// then
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Nil(t, notification)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSubject, notification.Subject)
+			for _, expected := range tc.expectedText {
+				assert.Contains(t, notification.TextBody, expected)
+			}
+			for _, expected := range tc.expectedHTML {
+				assert.Contains(t, notification.HTMLBody, expected)
+			}
+			if tc.expectedNoHTML {
+				assert.Empty(t, notification.HTMLBody)
+			}
+			for _, notExpected := range tc.notExpectedInAll {
+				assert.NotContains(t, notification.TextBody, notExpected)
+				assert.NotContains(t, notification.HTMLBody, notExpected)
+			}
+		})
+	}
+}
+
+func TestNotificationRenderer_HTMLIsEscaped(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	writeTemplate(t, dir, "test.tmpl", `{{define "subject"}}{{.Name}}{{end}}{{define "body"}}{{.Name}}{{end}}`)
+	writeTemplate(t, dir, "test.html.tmpl", `{{define "body"}}<p>{{.Name}}</p>{{end}}`)
+	renderer, err := NewNotificationRenderer(dir)
+	require.NoError(t, err)
+
+	// when
+	notification, err := renderer.Render("test", struct{ Name string }{Name: "<script>"})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "<script>", notification.TextBody)
+	assert.Equal(t, "<p>&lt;script&gt;</p>", notification.HTMLBody)
+}
+
+func TestNewNotificationRenderer_InvalidTemplates(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+	}{
+		{
+			name:  "missing subject",
+			files: map[string]string{"test.tmpl": `{{define "body"}}body{{end}}`},
+		},
+		{
+			name:  "syntax error",
+			files: map[string]string{"test.tmpl": `{{define "subject"}}{{.Name}{{end}}`},
+		},
+		{
+			name:  "HTML template without text template",
+			files: map[string]string{"test.html.tmpl": `{{define "body"}}body{{end}}`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				writeTemplate(t, dir, name, content)
+			}
+
+			// when
+			renderer, err := NewNotificationRenderer(dir)
+
+			// then
+			assert.Error(t, err)
+			assert.Nil(t, renderer)
+		})
+	}
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
@@ -0,0 +1,28 @@
+// Package email provides sending of notification emails.
+package email
+
+import (
+	"context"
+)
+
+// Message is an email message. HTMLBody is optional, if set the message is sent with both the plain text and the HTML body.
+type Message struct {
+	To       []string
+	Subject  string
+	Body     string
+	HTMLBody string
+}
+
+// Sender sends email messages.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NoopSender is a Sender which discards all messages.
+// It is used when email sending is disabled and in tests.
+type NoopSender struct{}
+
+// Send discards the message.
+func (NoopSender) Send(_ context.Context, _ Message) error {
+	return nil
+}
@@ -0,0 +1,81 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/abgdnv/gocommerce/notification_service/internal/config"
+)
+
+// SMTPSender sends email messages via an SMTP server.
+type SMTPSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPSender creates a new SMTPSender. Authentication is used only if the username is configured.
+func NewSMTPSender(cfg config.EmailConfig) *SMTPSender {
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return &SMTPSender{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		from: cfg.From,
+		auth: auth,
+	}
+}
+
+// Send sends the message as a plain text email, or as a multipart email if the message has an HTML body.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := smtp.SendMail(s.addr, s.auth, s.from, msg.To, s.compose(msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// compose builds the RFC 5322 representation of the message.
+func (s *SMTPSender) compose(msg Message) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + s.from + "\r\n")
+	b.WriteString("To: " + strings.Join(msg.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if msg.HTMLBody == "" {
+		b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(toCRLF(msg.Body))
+		return b.Bytes()
+	}
+
+	mw := multipart.NewWriter(&b)
+	b.WriteString("Content-Type: multipart/alternative; boundary=" + mw.Boundary() + "\r\n")
+	b.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{contentType: "text/plain; charset=UTF-8", body: msg.Body},
+		{contentType: "text/html; charset=UTF-8", body: msg.HTMLBody},
+	} {
+		// writing to a bytes.Buffer doesn't fail
+		w, _ := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		_, _ = w.Write([]byte(toCRLF(part.body)))
+	}
+	_ = mw.Close()
+	return b.Bytes()
+}
+
+// toCRLF converts the line endings to CRLF, as required by SMTP.
+func toCRLF(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
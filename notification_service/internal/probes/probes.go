@@ -0,0 +1,77 @@
+// Package probes reports the liveness and readiness of the notification service.
+// Depending on the configured mode, it either maintains probe files for exec probes
+// or serves the /livez and /readyz endpoints, which works on read-only root filesystems.
+package probes
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/bootstrap"
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/health"
+)
+
+// readHeaderTimeout limits the time to read the headers of a probe request.
+const readHeaderTimeout = 5 * time.Second
+
+// NewComponent returns the component reporting the probes in the configured mode.
+// The checks are run on readiness requests in the HTTP mode and are ignored in the file mode.
+func NewComponent(cfg config.ProbesConfig, checks map[string]health.Check, logger *slog.Logger) bootstrap.Component {
+	if cfg.Mode == config.ProbeModeHTTP {
+		return bootstrap.NewHTTPServerComponent("probes server", NewServer(cfg, checks, logger), logger)
+	}
+	return &bootstrap.FuncComponent{
+		ComponentName: "probe files",
+		StartFn: func(ctx context.Context) error {
+			return runFileProbes(ctx, cfg, logger)
+		},
+	}
+}
+
+// NewServer creates an HTTP server for the /livez and /readyz endpoints.
+func NewServer(cfg config.ProbesConfig, checks map[string]health.Check, logger *slog.Logger) *http.Server {
+	h := health.NewHandler(checks, logger)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /livez", h.Live)
+	mux.HandleFunc("GET /readyz", h.Ready)
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+}
+
+// runFileProbes creates the readiness and liveness probe files and updates the liveness file periodically
+// until the context is cancelled. Both files are removed on return.
+func runFileProbes(ctx context.Context, cfg config.ProbesConfig, logger *slog.Logger) error {
+	if err := os.WriteFile(cfg.ReadinessFileName, []byte("ok"), 0644); err != nil {
+		logger.Error("Failed to create readiness probe file", "error", err)
+	}
+	defer func() {
+		if err := os.Remove(cfg.ReadinessFileName); err != nil {
+			logger.Error("Can't delete file", "file", cfg.ReadinessFileName)
+		}
+	}()
+
+	if err := os.WriteFile(cfg.LivenessFileName, []byte("ok"), 0644); err != nil {
+		return fmt.Errorf("failed to create liveness probe file: %w", err)
+	}
+	ticker := time.NewTicker(cfg.LivenessInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			_ = os.Remove(cfg.LivenessFileName)
+			return nil
+		case <-ticker.C:
+			if err := os.Chtimes(cfg.LivenessFileName, time.Now(), time.Now()); err != nil {
+				logger.Error("Failed to update liveness probe file", "error", err)
+			}
+		}
+	}
+}
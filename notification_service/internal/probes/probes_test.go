@@ -0,0 +1,139 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// probesConfig returns a configuration with the probe files located in a temporary directory.
+func probesConfig(t *testing.T, mode string) config.ProbesConfig {
+	t.Helper()
+	dir := t.TempDir()
+	return config.ProbesConfig{
+		Mode:              mode,
+		Addr:              freeAddr(t),
+		ReadinessFileName: filepath.Join(dir, "ready"),
+		LivenessFileName:  filepath.Join(dir, "live"),
+		LivenessInterval:  time.Hour,
+	}
+}
+
+// freeAddr returns a local address with a port which is free at the time of the call.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	return addr
+}
+
+func TestNewComponent_HTTPMode(t *testing.T) {
+	// given
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	cfg := probesConfig(t, config.ProbeModeHTTP)
+	component := NewComponent(cfg, nil, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- component.Start(ctx) }()
+
+	// when
+	var status int
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + cfg.Addr + "/livez")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		status = resp.StatusCode
+		return true
+	}, 2*time.Second, 10*time.Millisecond, "probes server should start")
+
+	// then
+	assert.Equal(t, http.StatusOK, status, "livez should report the service as alive")
+	assert.NoFileExists(t, cfg.LivenessFileName, "liveness file should not be created")
+	assert.NoFileExists(t, cfg.ReadinessFileName, "readiness file should not be created")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutdownCancel()
+	require.NoError(t, component.Shutdown(shutdownCtx))
+	require.NoError(t, <-errCh)
+}
+
+func TestNewServer_Ready(t *testing.T) {
+	testCases := []struct {
+		name         string
+		checks       map[string]health.Check
+		expectedCode int
+	}{
+		{
+			name: "all checks pass",
+			checks: map[string]health.Check{
+				"nats": func(context.Context) error { return nil },
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "check fails",
+			checks: map[string]health.Check{
+				"nats": func(context.Context) error { return errors.New("not connected") },
+			},
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			server := NewServer(probesConfig(t, config.ProbeModeHTTP), tc.checks, logger)
+			rr := httptest.NewRecorder()
+			// when
+			server.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code)
+		})
+	}
+}
+
+func TestNewComponent_FileMode(t *testing.T) {
+	// given
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	cfg := probesConfig(t, config.ProbeModeFile)
+	component := NewComponent(cfg, nil, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+
+	// when
+	go func() { errCh <- component.Start(ctx) }()
+
+	// then
+	require.Eventually(t, func() bool {
+		return fileExists(cfg.LivenessFileName) && fileExists(cfg.ReadinessFileName)
+	}, 2*time.Second, 10*time.Millisecond, "probe files should be created")
+
+	cancel()
+	require.NoError(t, <-errCh)
+	assert.NoFileExists(t, cfg.LivenessFileName, "liveness file should be removed on shutdown")
+	assert.NoFileExists(t, cfg.ReadinessFileName, "readiness file should be removed on shutdown")
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
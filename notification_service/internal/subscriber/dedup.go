@@ -0,0 +1,70 @@
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/abgdnv/gocommerce/notification_service/internal/dedup"
+	"github.com/abgdnv/gocommerce/pkg/messaging/events"
+	"github.com/google/uuid"
+)
+
+// Deduplicate wraps the handlers to skip the events already processed according to the store.
+// A duplicate is acknowledged without being handled, an event is recorded as processed when its handler acknowledges it.
+// Events without an ID, e.g. published by an older version of the publisher, are always handled.
+func Deduplicate(handlers map[string]Handler, store dedup.Store) map[string]Handler {
+	deduplicated := make(map[string]Handler, len(handlers))
+	for subject, handler := range handlers {
+		deduplicated[subject] = deduplicate(handler, store)
+	}
+	return deduplicated
+}
+
+// deduplicate wraps a single handler, see Deduplicate.
+func deduplicate(handler Handler, store dedup.Store) Handler {
+	return func(msg AckableMsg, logger *slog.Logger) {
+		id := eventID(msg.Data())
+		if id == uuid.Nil {
+			handler(msg, logger)
+			return
+		}
+		ctx := context.Background()
+		seen, err := store.Seen(ctx, id)
+		if err != nil {
+			// a duplicate notification is better than a lost one
+			logger.WarnContext(ctx, "failed to check if event was processed, handling it", "event_id", id, "error", err)
+		} else if seen {
+			logger.InfoContext(ctx, "skipping already processed event", "subject", msg.Subject(), "event_id", id)
+			ackMessage(ctx, msg, logger)
+			return
+		}
+		handler(&processedMsg{AckableMsg: msg, id: id, store: store, logger: logger}, logger)
+	}
+}
+
+// eventID returns the ID of the event in the message payload, or uuid.Nil if it has none or can't be decoded.
+func eventID(data []byte) uuid.UUID {
+	var envelope events.Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return uuid.Nil
+	}
+	return envelope.EventID
+}
+
+// processedMsg records the event as processed when the message is acknowledged.
+type processedMsg struct {
+	AckableMsg
+	id     uuid.UUID
+	store  dedup.Store
+	logger *slog.Logger
+}
+
+// Ack records the event as processed before acknowledging the message,
+// so a redelivery caused by a failed acknowledgement is skipped as well.
+func (m *processedMsg) Ack() error {
+	if err := m.store.MarkProcessed(context.Background(), m.id); err != nil {
+		m.logger.Error("failed to record processed event", "event_id", m.id, "error", err)
+	}
+	return m.AckableMsg.Ack()
+}
@@ -0,0 +1,135 @@
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/notification_service/internal/dedup"
+	"github.com/abgdnv/gocommerce/pkg/messaging"
+	"github.com/abgdnv/gocommerce/pkg/messaging/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore fails every lookup and record of processed events.
+type failingStore struct{}
+
+func (failingStore) Seen(context.Context, uuid.UUID) (bool, error) {
+	return false, errors.New("store is down")
+}
+
+func (failingStore) MarkProcessed(context.Context, uuid.UUID) error {
+	return errors.New("store is down")
+}
+
+// newOrderCreatedMsg returns a mock message of an OrderCreatedEvent with the event ID,
+// which expects to be acknowledged once with expectedAck, e.g. "Ack" or "Nak".
+func newOrderCreatedMsg(t *testing.T, id uuid.UUID, expectedAck string) *mockAckableMsg {
+	t.Helper()
+	payload, err := json.Marshal(&events.OrderCreatedEvent{
+		Envelope:  events.Envelope{EventID: id},
+		OrderID:   uuid.New(),
+		UserID:    uuid.New(),
+		UserEmail: "user@example.com",
+		CreatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	msg := new(mockAckableMsg)
+	msg.On("Subject").Return(messaging.OrdersCreatedSubject)
+	msg.On("Data").Return(payload)
+	msg.On(expectedAck).Return(nil).Times(1)
+	return msg
+}
+
+func TestDeduplicate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	testCases := []struct {
+		name            string
+		store           dedup.Store
+		firstID         uuid.UUID
+		secondID        uuid.UUID
+		expectedHandled int
+	}{
+		{
+			name:            "same event delivered twice - handled once",
+			store:           dedup.NewMemoryStore(time.Hour),
+			firstID:         uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"),
+			secondID:        uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"),
+			expectedHandled: 1,
+		},
+		{
+			name:            "different events - both handled",
+			store:           dedup.NewMemoryStore(time.Hour),
+			firstID:         uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"),
+			secondID:        uuid.MustParse("123e4567-e89b-12d3-a456-426614174001"),
+			expectedHandled: 2,
+		},
+		{
+			name:            "events without ID - both handled",
+			store:           dedup.NewMemoryStore(time.Hour),
+			expectedHandled: 2,
+		},
+		{
+			name:            "store failure - both handled",
+			store:           failingStore{},
+			firstID:         uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"),
+			secondID:        uuid.MustParse("123e4567-e89b-12d3-a456-426614174000"),
+			expectedHandled: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			handled := 0
+			handlers := Deduplicate(map[string]Handler{
+				messaging.OrdersCreatedSubject: func(msg AckableMsg, logger *slog.Logger) {
+					handled++
+					ackMessage(context.Background(), msg, logger)
+				},
+			}, tc.store)
+			first, second := newOrderCreatedMsg(t, tc.firstID, "Ack"), newOrderCreatedMsg(t, tc.secondID, "Ack")
+
+			// when
+			handleMessage(first, handlers, logger)
+			handleMessage(second, handlers, logger)
+
+			// then
+			assert.Equal(t, tc.expectedHandled, handled)
+			first.AssertExpectations(t)
+			second.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeduplicate_NotRecordedWithoutAck(t *testing.T) {
+	// given: the first delivery fails and is redelivered
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	id := uuid.New()
+	handled := 0
+	handlers := Deduplicate(map[string]Handler{
+		messaging.OrdersCreatedSubject: func(msg AckableMsg, logger *slog.Logger) {
+			handled++
+			if handled == 1 {
+				_ = msg.Nak()
+				return
+			}
+			ackMessage(context.Background(), msg, logger)
+		},
+	}, dedup.NewMemoryStore(time.Hour))
+	first, second := newOrderCreatedMsg(t, id, "Nak"), newOrderCreatedMsg(t, id, "Ack")
+
+	// when
+	handleMessage(first, handlers, logger)
+	handleMessage(second, handlers, logger)
+
+	// then
+	assert.Equal(t, 2, handled, "a redelivered event should be handled until it is acknowledged")
+	first.AssertExpectations(t)
+	second.AssertExpectations(t)
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"time"
 
+	"github.com/abgdnv/gocommerce/notification_service/internal/email"
 	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/messaging"
 	"github.com/abgdnv/gocommerce/pkg/messaging/events"
+	pnats "github.com/abgdnv/gocommerce/pkg/nats"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 	"go.opentelemetry.io/otel"
@@ -16,28 +20,111 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// Handler processes a single message of a specific subject.
+// It is responsible for acknowledging or terminating the message.
+type Handler func(msg AckableMsg, logger *slog.Logger)
+
+// Notifier renders and sends notifications about order events.
+type Notifier struct {
+	sender   email.Sender
+	renderer *email.NotificationRenderer
+	dlq      DeadLetterer
+}
+
+// NewNotifier creates a new Notifier, which sends emails rendered from the templates of the event subjects.
+// Events of unsupported schema versions are moved to the dead letter queue.
+func NewNotifier(sender email.Sender, renderer *email.NotificationRenderer, dlq DeadLetterer) *Notifier {
+	return &Notifier{
+		sender:   sender,
+		renderer: renderer,
+		dlq:      dlq,
+	}
+}
+
+// Handlers returns the handlers for all order events supported by the notification service.
+// A new schema version of an event gets its own handler, the previous version is handled until no longer published.
+func (n *Notifier) Handlers() map[string]Handler {
+	return map[string]Handler{
+		messaging.OrdersCreatedSubject:   Versioned(map[int]Handler{1: n.handleOrderCreated}, n.dlq),
+		messaging.OrdersCompletedSubject: Versioned(map[int]Handler{1: n.handleOrderCompleted}, n.dlq),
+	}
+}
+
+// DeadLetterReasonHeader is the header of a dead-lettered message with the reason it wasn't processed.
+const DeadLetterReasonHeader = "Dead-Letter-Reason"
+
+// StreamDeadLetterer publishes the messages which can't be processed to their subject with a prefix,
+// e.g. dlq.orders.created, which is stored by the DLQ stream.
+type StreamDeadLetterer struct {
+	js     jetstream.JetStream
+	prefix string
+}
+
+// NewStreamDeadLetterer creates a new StreamDeadLetterer, which publishes the dead letters with the subject prefix.
+func NewStreamDeadLetterer(js jetstream.JetStream, prefix string) *StreamDeadLetterer {
+	return &StreamDeadLetterer{js: js, prefix: prefix}
+}
+
+// DeadLetter publishes a copy of the message with the reason in the DeadLetterReasonHeader.
+func (d *StreamDeadLetterer) DeadLetter(ctx context.Context, msg AckableMsg, reason string) error {
+	dead := nats.NewMsg(d.prefix + "." + msg.Subject())
+	dead.Data = msg.Data()
+	dead.Header.Set(DeadLetterReasonHeader, reason)
+	if _, err := d.js.PublishMsg(ctx, dead); err != nil {
+		return fmt.Errorf("failed to publish dead letter: %w", err)
+	}
+	return nil
+}
+
+// OrderCreatedData is the data available in the templates of the order created notification.
+type OrderCreatedData struct {
+	OrderID    string
+	TotalPrice string
+	CreatedAt  string
+}
+
+// OrderCompletedData is the data available in the templates of the order completed notification.
+type OrderCompletedData struct {
+	OrderID     string
+	CompletedAt string
+}
+
 // Start initializes the NATS JetStream consumer and starts multiple worker goroutines to process messages.
-func Start(ctx context.Context, js jetstream.JetStream, subscriberCfg config.SubscriberConfig, logger *slog.Logger) error {
+// It waits until the stream exists, the stream is created by the publishing service or the NATS stream setup job,
+// and creates the durable consumer if it doesn't exist yet, so a fresh environment needs no manual setup.
+// Messages are dispatched to the handler registered for their subject, up to subscriberCfg.MaxInFlight handlers run at once.
+// Once the context is done, the workers stop fetching and Start waits for the in-flight handlers up to drainTimeout.
+func Start(ctx context.Context, js jetstream.JetStream, subscriberCfg config.SubscriberConfig, handlers map[string]Handler, drainTimeout time.Duration, logger *slog.Logger) error {
+	stream := jetstream.StreamConfig{Name: subscriberCfg.Stream}
+	if _, err := pnats.EnsureStream(ctx, js, stream, false, subscriberCfg.StreamRetryInterval(), logger); err != nil {
+		return fmt.Errorf("stream %s is not available: %w", subscriberCfg.Stream, err)
+	}
 	cfg := jetstream.ConsumerConfig{
-		FilterSubject: subscriberCfg.Subject,
-		Durable:       subscriberCfg.Consumer,
-		AckPolicy:     jetstream.AckExplicitPolicy,
+		FilterSubjects: subscriberCfg.FilterSubjects(),
+		Durable:        subscriberCfg.Consumer,
+		AckPolicy:      jetstream.AckExplicitPolicy,
 	}
-	consumer, err := js.CreateOrUpdateConsumer(ctx, subscriberCfg.Stream, cfg)
+	consumer, err := pnats.EnsureConsumer(ctx, js, subscriberCfg.Stream, cfg, logger)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to ensure consumer %s: %w", subscriberCfg.Consumer, err)
 	}
+	pool := newWorkerPool(subscriberCfg.MaxInFlight, otel.Meter("notification-service"))
 	g, gCtx := errgroup.WithContext(ctx)
 	for i := 0; i < subscriberCfg.Workers; i++ {
 		g.Go(func() error {
-			return runWorker(gCtx, consumer, subscriberCfg.Batch, subscriberCfg.Timeout, subscriberCfg.Interval, logger)
+			return runWorker(gCtx, consumer, subscriberCfg.Batch, subscriberCfg.Timeout, subscriberCfg.Interval, pool, handlers, logger)
 		})
 	}
-	return g.Wait()
+	err = g.Wait()
+	if !pool.wait(drainTimeout) {
+		logger.Warn("in-flight messages were not handled within the drain timeout, they will be redelivered", "timeout", drainTimeout)
+	}
+	return err
 }
 
-// runWorker fetches messages from the NATS JetStream consumer and processes them.
-func runWorker(ctx context.Context, consumer jetstream.Consumer, batchSize int, timeout time.Duration, interval time.Duration, logger *slog.Logger) error {
+// runWorker fetches messages from the NATS JetStream consumer and dispatches them to the pool.
+// The messages fetched but not dispatched once the context is done are redelivered.
+func runWorker(ctx context.Context, consumer jetstream.Consumer, batchSize int, timeout time.Duration, interval time.Duration, pool *workerPool, handlers map[string]Handler, logger *slog.Logger) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -56,7 +143,9 @@ func runWorker(ctx context.Context, consumer jetstream.Consumer, batchSize int,
 				continue
 			}
 			for msg := range batch.Messages() {
-				handleMessage(msg, logger)
+				if !pool.dispatch(ctx, msg, handlers, logger) {
+					nakMessage(msg, logger)
+				}
 			}
 		}
 	}
@@ -64,23 +153,35 @@ func runWorker(ctx context.Context, consumer jetstream.Consumer, batchSize int,
 
 // AckableMsg is an interface that represents a message that can be acknowledged or negatively acknowledged.
 type AckableMsg interface {
+	Subject() string
 	Data() []byte
 	Ack() error
+	Nak() error
 	Term() error
 }
 
-// handleMessage processes a single message from the NATS JetStream consumer.
-func handleMessage(msg AckableMsg, logger *slog.Logger) {
+// handleMessage dispatches a single message from the NATS JetStream consumer to the handler registered for its subject.
+// Messages without a registered handler are terminated.
+func handleMessage(msg AckableMsg, handlers map[string]Handler, logger *slog.Logger) {
 	if msg == nil {
 		logger.Error("received nil message")
 		return
 	}
+	handler, ok := handlers[msg.Subject()]
+	if !ok {
+		logger.Warn("received message with unknown subject", "subject", msg.Subject())
+		termMessage(msg, logger)
+		return
+	}
+	handler(msg, logger)
+}
+
+// handleOrderCreated processes an OrderCreatedEvent by sending an order confirmation email to the user.
+func (n *Notifier) handleOrderCreated(msg AckableMsg, logger *slog.Logger) {
 	var event events.OrderCreatedEvent
 	if err := json.Unmarshal(msg.Data(), &event); err != nil {
 		logger.Error("failed to unmarshal message", "error", err)
-		if err := msg.Term(); err != nil {
-			logger.Error("failed to term message", "error", err)
-		}
+		termMessage(msg, logger)
 		return
 	}
 
@@ -95,15 +196,104 @@ func handleMessage(msg AckableMsg, logger *slog.Logger) {
 		slog.String("user_id", event.UserID.String()),
 		slog.String("created_at", event.CreatedAt.Format(time.RFC3339)))
 
-	notificationJob()
+	n.notify(ctx, msg, event.UserEmail, OrderCreatedData{
+		OrderID:    event.OrderID.String(),
+		TotalPrice: formatPrice(event.TotalPrice),
+		CreatedAt:  event.CreatedAt.Format(time.RFC1123),
+	}, logger)
+}
+
+// handleOrderCompleted processes an OrderCompletedEvent by notifying the user about the completed order.
+func (n *Notifier) handleOrderCompleted(msg AckableMsg, logger *slog.Logger) {
+	var event events.OrderCompletedEvent
+	if err := json.Unmarshal(msg.Data(), &event); err != nil {
+		logger.Error("failed to unmarshal message", "error", err)
+		termMessage(msg, logger)
+		return
+	}
+
+	carrier := propagation.MapCarrier(event.Carrier)
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+	tracer := otel.Tracer("notification-service")
+	_, span := tracer.Start(ctx, "handle.order.completed")
+	defer span.End()
 
+	logger.InfoContext(ctx, "received order completed event",
+		slog.String("order_id", event.OrderID.String()),
+		slog.String("user_id", event.UserID.String()),
+		slog.String("completed_at", event.CompletedAt.Format(time.RFC3339)))
+
+	n.notify(ctx, msg, event.UserEmail, OrderCompletedData{
+		OrderID:     event.OrderID.String(),
+		CompletedAt: event.CompletedAt.Format(time.RFC1123),
+	}, logger)
+}
+
+// notify renders the notification from the template of the message subject and sends it to the recipient.
+// Messages without a recipient or a template are acknowledged without sending, as redelivery won't help.
+// If sending fails, the message is redelivered.
+func (n *Notifier) notify(ctx context.Context, msg AckableMsg, recipient string, data any, logger *slog.Logger) {
+	if recipient == "" {
+		logger.WarnContext(ctx, "notification is not sent: user email is unknown", "subject", msg.Subject())
+		ackMessage(ctx, msg, logger)
+		return
+	}
+
+	notification, err := n.renderer.Render(msg.Subject(), data)
+	if errors.Is(err, email.ErrTemplateNotFound) {
+		logger.WarnContext(ctx, "notification is not sent: template is missing", "subject", msg.Subject())
+		ackMessage(ctx, msg, logger)
+		return
+	} else if err != nil {
+		logger.ErrorContext(ctx, "failed to render notification", "error", err)
+		termMessage(msg, logger)
+		return
+	}
+
+	err = n.sender.Send(ctx, email.Message{
+		To:       []string{recipient},
+		Subject:  notification.Subject,
+		Body:     notification.TextBody,
+		HTMLBody: notification.HTMLBody,
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to send notification", "error", err)
+		if err := msg.Nak(); err != nil {
+			logger.ErrorContext(ctx, "failed to nak message", "error", err)
+		}
+		return
+	}
+
+	ackMessage(ctx, msg, logger)
+}
+
+// ackMessage acknowledges a successfully processed message.
+func ackMessage(ctx context.Context, msg AckableMsg, logger *slog.Logger) {
 	if err := msg.Ack(); err != nil {
 		logger.ErrorContext(ctx, "failed to ack message", "error", err)
 	}
 }
 
-// notificationJob simulates a job that processes the notification.
-func notificationJob() {
-	// simulate some processing time
-	time.Sleep(100 * time.Millisecond)
+// nakMessage requests the redelivery of a message which wasn't handled.
+func nakMessage(msg AckableMsg, logger *slog.Logger) {
+	if err := msg.Nak(); err != nil {
+		logger.Error("failed to nak message", "error", err)
+	}
+}
+
+// termMessage terminates a message that can't be processed, so it isn't redelivered.
+func termMessage(msg AckableMsg, logger *slog.Logger) {
+	if err := msg.Term(); err != nil {
+		logger.Error("failed to term message", "error", err)
+	}
+}
+
+// formatPrice formats a price given in minor currency units, e.g. 1999 as "19.99".
+func formatPrice(price int64) string {
+	sign := ""
+	if price < 0 {
+		sign = "-"
+		price = -price
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, price/100, price%100)
 }
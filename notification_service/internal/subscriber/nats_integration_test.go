@@ -4,14 +4,19 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/abgdnv/gocommerce/notification_service/internal/email"
+	"github.com/abgdnv/gocommerce/notification_service/internal/webhook"
 	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/messaging"
 	"github.com/abgdnv/gocommerce/pkg/messaging/events"
 	pnats "github.com/abgdnv/gocommerce/pkg/nats"
 	"github.com/google/uuid"
 	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/testcontainers/testcontainers-go"
@@ -23,6 +28,15 @@ import (
 const skipIntegrationTests = "NOTIFICATION_SVC_SKIP_INTEGRATION_TESTS"
 const natsImg = "nats:2.11.6-alpine"
 
+// ordersSubjects matches all order event subjects, as the ORDERS stream does.
+const ordersSubjects = "orders.*"
+
+// dlqStream stores the dead-lettered messages published with dlqPrefix.
+const (
+	dlqStream = "DLQ"
+	dlqPrefix = "dlq"
+)
+
 // SubscriberSuite is a test suite for testing the NATS subscriber functionality.
 type SubscriberSuite struct {
 	suite.Suite                           // Embedding testify suite for structured testing
@@ -31,6 +45,7 @@ type SubscriberSuite struct {
 	natsContainer *nats.NATSContainer     // NATS container for running tests
 	jsCtx         natsgo.JetStreamContext // JetStream context for NATS operations
 	nc            *natsgo.Conn            // NATS connection for the subscriber
+	handlers      map[string]Handler      // Message handlers with a no-op email sender
 }
 
 // SetupSuite initializes the test suite, setting up the NATS container and JetStream context.
@@ -50,6 +65,19 @@ func (s *SubscriberSuite) SetupSuite() {
 	s.jsCtx, err = s.nc.JetStream()
 	require.NoError(s.T(), err, "Failed to get JetStream context")
 
+	// dead letters are kept in a stream of their own, as in the deployment
+	_, err = s.jsCtx.AddStream(&natsgo.StreamConfig{
+		Name:     dlqStream,
+		Subjects: []string{dlqPrefix + ".>"},
+	})
+	require.NoError(s.T(), err, "Failed to add DLQ stream")
+
+	js, err := pnats.NewJetStreamContext(s.nc)
+	require.NoError(s.T(), err, "Failed to create JetStream context")
+	renderer, err := email.NewNotificationRenderer(templatesDir)
+	require.NoError(s.T(), err, "Failed to load notification templates")
+	s.handlers = NewNotifier(email.NoopSender{}, renderer, NewStreamDeadLetterer(js, dlqPrefix)).Handlers()
+
 	s.logger.Info("Initialization complete for SubscribeSuite")
 }
 
@@ -93,8 +121,8 @@ func (s *SubscriberSuite) TestReceiveMessage() {
 			name:         "Successfully receive message",
 			streamName:   "STREAM-" + uuid.NewString(),
 			consumerName: "CONSUMER-" + uuid.NewString(),
-			subjectName:  "subject." + uuid.NewString(),
-			publish: func(js natsgo.JetStreamContext, testSubject string) error {
+			subjectName:  ordersSubjects,
+			publish: func(js natsgo.JetStreamContext, _ string) error {
 				testEvent := events.OrderCreatedEvent{
 					OrderID:    uuid.New(),
 					UserID:     uuid.New(),
@@ -103,7 +131,7 @@ func (s *SubscriberSuite) TestReceiveMessage() {
 				}
 				payload, _ := testEvent.Payload()
 				testMessage := &natsgo.Msg{
-					Subject: testSubject,
+					Subject: testEvent.Subject(),
 					Data:    payload,
 				}
 				_, err := js.PublishMsg(testMessage)
@@ -129,11 +157,11 @@ func (s *SubscriberSuite) TestReceiveMessage() {
 			name:         "Invalid payload",
 			streamName:   "STREAM_" + uuid.NewString(),
 			consumerName: "CONSUMER_" + uuid.NewString(),
-			subjectName:  "subject." + uuid.NewString(),
-			publish: func(js natsgo.JetStreamContext, testSubject string) error {
+			subjectName:  ordersSubjects,
+			publish: func(js natsgo.JetStreamContext, _ string) error {
 				// Publish an invalid message that cannot be unmarshalled
 				invalidMessage := &natsgo.Msg{
-					Subject: testSubject,
+					Subject: messaging.OrdersCreatedSubject,
 					Data:    []byte("invalid payload"),
 				}
 				_, err := js.PublishMsg(invalidMessage)
@@ -150,7 +178,7 @@ func (s *SubscriberSuite) TestReceiveMessage() {
 				}
 				payload, _ := validEvent.Payload()
 				validMessage := &natsgo.Msg{
-					Subject: testSubject,
+					Subject: validEvent.Subject(),
 					Data:    payload,
 				}
 				_, err = js.PublishMsg(validMessage)
@@ -174,6 +202,86 @@ func (s *SubscriberSuite) TestReceiveMessage() {
 				require.Equal(s.T(), uint64(2), finalConsumerInfo.AckFloor.Stream)
 			},
 		},
+		{
+			name:         "Unsupported schema version is dead-lettered",
+			streamName:   "STREAM-" + uuid.NewString(),
+			consumerName: "CONSUMER-" + uuid.NewString(),
+			subjectName:  ordersSubjects,
+			publish: func(js natsgo.JetStreamContext, _ string) error {
+				// a newer publisher sends the next version of the event, which this subscriber doesn't support yet
+				unsupported := &natsgo.Msg{
+					Subject: messaging.OrdersCreatedSubject,
+					Data:    []byte(`{"event_id":"` + uuid.NewString() + `","event_type":"OrderCreated","schema_version":2,"order":{}}`),
+				}
+				if _, err := js.PublishMsg(unsupported); err != nil {
+					return err
+				}
+
+				// Publish v1 message to ensure the subscriber is still running
+				validEvent := events.OrderCreatedEvent{
+					OrderID:    uuid.New(),
+					UserID:     uuid.New(),
+					TotalPrice: 9999,
+					CreatedAt:  time.Now(),
+				}
+				payload, _ := validEvent.Payload()
+				_, err := js.PublishMsg(&natsgo.Msg{Subject: validEvent.Subject(), Data: payload})
+				return err
+			},
+			condition: func(testStream, testConsumer string) bool {
+				consumerInfo, err := s.jsCtx.ConsumerInfo(testStream, testConsumer)
+				if err != nil {
+					return false
+				}
+				return consumerInfo.NumPending == 0 && consumerInfo.NumAckPending == 0 && consumerInfo.AckFloor.Stream == 2
+			},
+			assert: func(testStream, testConsumer string) {
+				finalConsumerInfo, err := s.jsCtx.ConsumerInfo(testStream, testConsumer)
+				require.NoError(s.T(), err)
+				require.Equal(s.T(), uint64(0), finalConsumerInfo.NumPending)
+				require.Equal(s.T(), 0, finalConsumerInfo.NumAckPending)
+				// Assert that the v2 event was moved to the DLQ with the reason
+				deadLetter, err := s.jsCtx.GetLastMsg(dlqStream, dlqPrefix+"."+messaging.OrdersCreatedSubject)
+				require.NoError(s.T(), err, "The unsupported event should be dead-lettered")
+				require.Contains(s.T(), string(deadLetter.Data), `"schema_version":2`)
+				require.Equal(s.T(), "unsupported schema version 2", deadLetter.Header.Get(DeadLetterReasonHeader))
+			},
+		},
+		{
+			name:         "Successfully receive order created and completed messages",
+			streamName:   "STREAM-" + uuid.NewString(),
+			consumerName: "CONSUMER-" + uuid.NewString(),
+			subjectName:  ordersSubjects,
+			publish: func(js natsgo.JetStreamContext, _ string) error {
+				orderID, userID := uuid.New(), uuid.New()
+				testEvents := []messaging.Event{
+					events.OrderCreatedEvent{OrderID: orderID, UserID: userID, TotalPrice: 9999, CreatedAt: time.Now()},
+					events.OrderCompletedEvent{OrderID: orderID, UserID: userID, CompletedAt: time.Now()},
+				}
+				for _, event := range testEvents {
+					payload, _ := event.Payload()
+					if _, err := js.PublishMsg(&natsgo.Msg{Subject: event.Subject(), Data: payload}); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			condition: func(testStream, testConsumer string) bool {
+				consumerInfo, err := s.jsCtx.ConsumerInfo(testStream, testConsumer)
+				if err != nil {
+					return false
+				}
+				return consumerInfo.NumPending == 0 && consumerInfo.NumAckPending == 0 && consumerInfo.AckFloor.Stream == 2
+			},
+			assert: func(testStream, testConsumer string) {
+				finalConsumerInfo, err := s.jsCtx.ConsumerInfo(testStream, testConsumer)
+				require.NoError(s.T(), err)
+				// Assert that both the created and the completed events were consumed
+				require.Equal(s.T(), uint64(0), finalConsumerInfo.NumPending)
+				require.Equal(s.T(), 0, finalConsumerInfo.NumAckPending)
+				require.Equal(s.T(), uint64(2), finalConsumerInfo.AckFloor.Stream)
+			},
+		},
 	}
 	for _, tc := range testCases {
 		s.T().Run(tc.name, func(t *testing.T) {
@@ -193,6 +301,9 @@ func (s *SubscriberSuite) runTest(t *testing.T, tc *TestCaseConfig) {
 		testCancel()
 		err := g.Wait()
 		require.ErrorIs(s.T(), err, context.Canceled, "error should be context.Canceled")
+		// test cases share the subjects, so the stream must be removed before the next test case
+		err = s.jsCtx.DeleteStream(tc.streamName)
+		require.NoError(s.T(), err, "Failed to delete stream")
 	})
 	// Create a new JetStream stream for the test
 	_, err := s.jsCtx.AddStream(&natsgo.StreamConfig{
@@ -204,19 +315,20 @@ func (s *SubscriberSuite) runTest(t *testing.T, tc *TestCaseConfig) {
 
 	// Initialize the subscriber with the configuration
 	cfgSubscriber := config.SubscriberConfig{
-		Stream:   tc.streamName,
-		Subject:  tc.subjectName,
-		Consumer: tc.consumerName,
-		Batch:    10,
-		Timeout:  200 * time.Millisecond,
-		Interval: 200 * time.Microsecond,
-		Workers:  1,
+		Stream:      tc.streamName,
+		Subject:     tc.subjectName,
+		Consumer:    tc.consumerName,
+		Batch:       10,
+		Timeout:     200 * time.Millisecond,
+		Interval:    200 * time.Microsecond,
+		Workers:     1,
+		MaxInFlight: 1,
 	}
 	js, err := pnats.NewJetStreamContext(s.nc)
 	require.NoError(s.T(), err, "Failed to create JetStream context")
 	g.Go(func() error {
 		s.logger.Info("NATS subscriber started")
-		return Start(gCtx, js, cfgSubscriber, s.logger)
+		return Start(gCtx, js, cfgSubscriber, s.handlers, time.Second, s.logger)
 	})
 
 	// when
@@ -232,3 +344,287 @@ func (s *SubscriberSuite) runTest(t *testing.T, tc *TestCaseConfig) {
 	tc.assert(tc.streamName, tc.consumerName)
 
 }
+
+// TestMultipleSubjects tests that messages of different subjects are dispatched to their own handlers.
+func (s *SubscriberSuite) TestMultipleSubjects() {
+	// given
+	streamName := "STREAM-" + uuid.NewString()
+	consumerName := "CONSUMER-" + uuid.NewString()
+	var createdCalls, completedCalls atomic.Int32
+	recordingHandler := func(calls *atomic.Int32) Handler {
+		return func(msg AckableMsg, logger *slog.Logger) {
+			calls.Add(1)
+			if err := msg.Ack(); err != nil {
+				logger.Error("failed to ack message", "error", err)
+			}
+		}
+	}
+	handlers := map[string]Handler{
+		messaging.OrdersCreatedSubject:   recordingHandler(&createdCalls),
+		messaging.OrdersCompletedSubject: recordingHandler(&completedCalls),
+	}
+
+	testCtx, testCancel := context.WithTimeout(s.ctx, 6*time.Second)
+	g, gCtx := errgroup.WithContext(testCtx)
+	s.T().Cleanup(func() {
+		testCancel()
+		err := g.Wait()
+		require.ErrorIs(s.T(), err, context.Canceled, "error should be context.Canceled")
+		err = s.jsCtx.DeleteStream(streamName)
+		require.NoError(s.T(), err, "Failed to delete stream")
+	})
+	_, err := s.jsCtx.AddStream(&natsgo.StreamConfig{
+		Name:      streamName,
+		Subjects:  []string{ordersSubjects},
+		Retention: natsgo.WorkQueuePolicy,
+	})
+	require.NoError(s.T(), err, "Failed to add stream to JetStream")
+
+	cfgSubscriber := config.SubscriberConfig{
+		Stream:      streamName,
+		Subjects:    []string{messaging.OrdersCreatedSubject, messaging.OrdersCompletedSubject},
+		Consumer:    consumerName,
+		Batch:       10,
+		Timeout:     200 * time.Millisecond,
+		Interval:    200 * time.Microsecond,
+		Workers:     1,
+		MaxInFlight: 1,
+	}
+	js, err := pnats.NewJetStreamContext(s.nc)
+	require.NoError(s.T(), err, "Failed to create JetStream context")
+	g.Go(func() error {
+		return Start(gCtx, js, cfgSubscriber, handlers, time.Second, s.logger)
+	})
+
+	// when
+	orderID, userID := uuid.New(), uuid.New()
+	testEvents := []messaging.Event{
+		events.OrderCreatedEvent{OrderID: orderID, UserID: userID, TotalPrice: 9999, CreatedAt: time.Now()},
+		events.OrderCompletedEvent{OrderID: orderID, UserID: userID, CompletedAt: time.Now()},
+	}
+	for _, event := range testEvents {
+		payload, _ := event.Payload()
+		_, err := s.jsCtx.PublishMsg(&natsgo.Msg{Subject: event.Subject(), Data: payload})
+		require.NoError(s.T(), err, "Failed to publish test message")
+	}
+
+	// then
+	require.Eventually(s.T(), func() bool {
+		return createdCalls.Load() == 1 && completedCalls.Load() == 1
+	}, 5*time.Second, 100*time.Millisecond, "Both handlers should be called once")
+}
+
+// TestMaxInFlight tests that slow handlers never run beyond the configured max in-flight messages,
+// while the workers together fetch more messages than that.
+func (s *SubscriberSuite) TestMaxInFlight() {
+	// given
+	const maxInFlight, messages = 2, 12
+	streamName := "STREAM-" + uuid.NewString()
+	consumerName := "CONSUMER-" + uuid.NewString()
+	var inFlight, peak, handled atomic.Int32
+	slowHandler := func(msg AckableMsg, logger *slog.Logger) {
+		current := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if current <= p || peak.CompareAndSwap(p, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		inFlight.Add(-1)
+		handled.Add(1)
+		if err := msg.Ack(); err != nil {
+			logger.Error("failed to ack message", "error", err)
+		}
+	}
+	handlers := map[string]Handler{messaging.OrdersCreatedSubject: slowHandler}
+
+	testCtx, testCancel := context.WithTimeout(s.ctx, 10*time.Second)
+	g, gCtx := errgroup.WithContext(testCtx)
+	s.T().Cleanup(func() {
+		testCancel()
+		err := g.Wait()
+		require.ErrorIs(s.T(), err, context.Canceled, "error should be context.Canceled")
+		err = s.jsCtx.DeleteStream(streamName)
+		require.NoError(s.T(), err, "Failed to delete stream")
+	})
+	_, err := s.jsCtx.AddStream(&natsgo.StreamConfig{
+		Name:      streamName,
+		Subjects:  []string{ordersSubjects},
+		Retention: natsgo.WorkQueuePolicy,
+	})
+	require.NoError(s.T(), err, "Failed to add stream to JetStream")
+
+	cfgSubscriber := config.SubscriberConfig{
+		Stream:      streamName,
+		Subject:     messaging.OrdersCreatedSubject,
+		Consumer:    consumerName,
+		Batch:       5,
+		Timeout:     200 * time.Millisecond,
+		Interval:    200 * time.Microsecond,
+		Workers:     3,
+		MaxInFlight: maxInFlight,
+	}
+	js, err := pnats.NewJetStreamContext(s.nc)
+	require.NoError(s.T(), err, "Failed to create JetStream context")
+
+	// when
+	for range messages {
+		payload, _ := events.OrderCreatedEvent{OrderID: uuid.New(), UserID: uuid.New(), CreatedAt: time.Now()}.Payload()
+		_, err := s.jsCtx.PublishMsg(&natsgo.Msg{Subject: messaging.OrdersCreatedSubject, Data: payload})
+		require.NoError(s.T(), err, "Failed to publish test message")
+	}
+	g.Go(func() error {
+		return Start(gCtx, js, cfgSubscriber, handlers, time.Second, s.logger)
+	})
+
+	// then
+	require.Eventually(s.T(), func() bool {
+		return handled.Load() == messages
+	}, 8*time.Second, 50*time.Millisecond, "All messages should be handled")
+	require.LessOrEqual(s.T(), peak.Load(), int32(maxInFlight), "in-flight handlers should never exceed the max")
+	require.Equal(s.T(), int32(maxInFlight), peak.Load(), "the pool should be saturated by the backlog")
+}
+
+// TestEnsureStreamAndConsumer bootstraps the stream and the consumer in a NATS without them,
+// then bootstraps them again, which finds and keeps them as they are.
+func (s *SubscriberSuite) TestEnsureStreamAndConsumer() {
+	// given
+	streamName := "STREAM-" + uuid.NewString()
+	consumerName := "CONSUMER-" + uuid.NewString()
+	s.T().Cleanup(func() {
+		err := s.jsCtx.DeleteStream(streamName)
+		require.NoError(s.T(), err, "Failed to delete stream")
+	})
+	js, err := pnats.NewJetStreamContext(s.nc)
+	require.NoError(s.T(), err, "Failed to create JetStream context")
+	_, err = js.Stream(s.ctx, streamName)
+	require.ErrorIs(s.T(), err, jetstream.ErrStreamNotFound, "the stream should not exist before the bootstrap")
+	streamCfg := jetstream.StreamConfig{
+		Name:      streamName,
+		Subjects:  []string{ordersSubjects},
+		Retention: jetstream.WorkQueuePolicy,
+	}
+	consumerCfg := jetstream.ConsumerConfig{
+		Durable:        consumerName,
+		FilterSubjects: []string{messaging.OrdersCreatedSubject},
+		AckPolicy:      jetstream.AckExplicitPolicy,
+	}
+
+	// when
+	stream, err := pnats.EnsureStream(s.ctx, js, streamCfg, true, 50*time.Millisecond, s.logger)
+	require.NoError(s.T(), err, "Failed to create the stream")
+	consumer, err := pnats.EnsureConsumer(s.ctx, js, streamName, consumerCfg, s.logger)
+	require.NoError(s.T(), err, "Failed to create the consumer")
+	payload, _ := events.OrderCreatedEvent{OrderID: uuid.New(), UserID: uuid.New(), CreatedAt: time.Now()}.Payload()
+	_, err = js.Publish(s.ctx, messaging.OrdersCreatedSubject, payload)
+	require.NoError(s.T(), err, "Failed to publish test message")
+	streamAgain, err := pnats.EnsureStream(s.ctx, js, streamCfg, true, 50*time.Millisecond, s.logger)
+	require.NoError(s.T(), err, "Failed to ensure the existing stream")
+	consumerAgain, err := pnats.EnsureConsumer(s.ctx, js, streamName, consumerCfg, s.logger)
+	require.NoError(s.T(), err, "Failed to ensure the existing consumer")
+
+	// then
+	created := stream.CachedInfo()
+	require.Equal(s.T(), []string{ordersSubjects}, created.Config.Subjects)
+	require.Equal(s.T(), jetstream.WorkQueuePolicy, created.Config.Retention)
+	found, err := streamAgain.Info(s.ctx)
+	require.NoError(s.T(), err, "Failed to get stream info")
+	require.Equal(s.T(), created.Created, found.Created, "the existing stream should not be recreated")
+	require.Equal(s.T(), uint64(1), found.State.Msgs, "the messages of the existing stream should be kept")
+
+	createdConsumer := consumer.CachedInfo()
+	require.Equal(s.T(), jetstream.AckExplicitPolicy, createdConsumer.Config.AckPolicy)
+	require.Equal(s.T(), []string{messaging.OrdersCreatedSubject}, createdConsumer.Config.FilterSubjects)
+	foundConsumer, err := consumerAgain.Info(s.ctx)
+	require.NoError(s.T(), err, "Failed to get consumer info")
+	require.Equal(s.T(), createdConsumer.Created, foundConsumer.Created, "the existing consumer should not be recreated")
+	require.Equal(s.T(), uint64(1), foundConsumer.NumPending, "the pending messages of the existing consumer should be kept")
+}
+
+// TestStartWaitsForStream starts the subscriber before the stream exists, as in a fresh environment,
+// and asserts it consumes the messages once the publishing service created the stream.
+func (s *SubscriberSuite) TestStartWaitsForStream() {
+	// given
+	streamName := "STREAM-" + uuid.NewString()
+	var handled atomic.Int32
+	handlers := map[string]Handler{messaging.OrdersCreatedSubject: func(msg AckableMsg, logger *slog.Logger) {
+		handled.Add(1)
+		if err := msg.Ack(); err != nil {
+			logger.Error("failed to ack message", "error", err)
+		}
+	}}
+	testCtx, testCancel := context.WithTimeout(s.ctx, 10*time.Second)
+	g, gCtx := errgroup.WithContext(testCtx)
+	s.T().Cleanup(func() {
+		testCancel()
+		err := g.Wait()
+		require.ErrorIs(s.T(), err, context.Canceled, "error should be context.Canceled")
+		err = s.jsCtx.DeleteStream(streamName)
+		require.NoError(s.T(), err, "Failed to delete stream")
+	})
+	cfgSubscriber := config.SubscriberConfig{
+		Stream:        streamName,
+		Subject:       messaging.OrdersCreatedSubject,
+		Consumer:      "CONSUMER-" + uuid.NewString(),
+		Batch:         10,
+		Timeout:       200 * time.Millisecond,
+		Interval:      200 * time.Microsecond,
+		Workers:       1,
+		MaxInFlight:   1,
+		RetryInterval: 50 * time.Millisecond,
+	}
+	js, err := pnats.NewJetStreamContext(s.nc)
+	require.NoError(s.T(), err, "Failed to create JetStream context")
+	g.Go(func() error {
+		return Start(gCtx, js, cfgSubscriber, handlers, time.Second, s.logger)
+	})
+
+	// when
+	time.Sleep(200 * time.Millisecond)
+	streamCfg := jetstream.StreamConfig{Name: streamName, Subjects: []string{ordersSubjects}, Retention: jetstream.WorkQueuePolicy}
+	_, err = pnats.EnsureStream(s.ctx, js, streamCfg, true, 50*time.Millisecond, s.logger)
+	require.NoError(s.T(), err, "Failed to create the stream")
+	payload, _ := events.OrderCreatedEvent{OrderID: uuid.New(), UserID: uuid.New(), CreatedAt: time.Now()}.Payload()
+	_, err = js.Publish(s.ctx, messaging.OrdersCreatedSubject, payload)
+	require.NoError(s.T(), err, "Failed to publish test message")
+
+	// then
+	require.Eventually(s.T(), func() bool {
+		return handled.Load() == 1
+	}, 5*time.Second, 50*time.Millisecond, "the message should be handled once the stream exists")
+}
+
+// TestStreamWebhookQueue queues an event for two endpoints twice, as on a redelivery of the event,
+// and asserts each endpoint gets a single delivery.
+func (s *SubscriberSuite) TestStreamWebhookQueue() {
+	// given
+	streamName := "WEBHOOKS-" + uuid.NewString()
+	s.T().Cleanup(func() {
+		err := s.jsCtx.DeleteStream(streamName)
+		require.NoError(s.T(), err, "Failed to delete stream")
+	})
+	js, err := pnats.NewJetStreamContext(s.nc)
+	require.NoError(s.T(), err, "Failed to create JetStream context")
+	stream, err := pnats.EnsureStream(s.ctx, js, webhook.StreamConfig(streamName), true, 50*time.Millisecond, s.logger)
+	require.NoError(s.T(), err, "Failed to create the webhook stream")
+	endpoints := []string{"https://first.example.com/hooks", "https://second.example.com/hooks"}
+	queue := NewStreamWebhookQueue(js, endpoints)
+	payload, _ := events.OrderCreatedEvent{OrderID: uuid.New(), UserID: uuid.New(), CreatedAt: time.Now()}.Payload()
+
+	// when
+	require.NoError(s.T(), queue.Enqueue(s.ctx, messaging.OrdersCreatedSubject, payload))
+	require.NoError(s.T(), queue.Enqueue(s.ctx, messaging.OrdersCreatedSubject, payload))
+
+	// then
+	info, err := stream.Info(s.ctx)
+	require.NoError(s.T(), err, "Failed to get stream info")
+	require.Equal(s.T(), uint64(len(endpoints)), info.State.Msgs, "the event should be queued once per endpoint")
+	for seq := uint64(1); seq <= info.State.Msgs; seq++ {
+		delivery, err := stream.GetMsg(s.ctx, seq)
+		require.NoError(s.T(), err, "Failed to get the queued delivery")
+		require.Equal(s.T(), "webhooks."+messaging.OrdersCreatedSubject, delivery.Subject)
+		require.Equal(s.T(), endpoints[seq-1], delivery.Header.Get(webhook.EndpointHeader))
+		require.Equal(s.T(), payload, delivery.Data)
+	}
+}
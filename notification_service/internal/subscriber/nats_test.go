@@ -1,21 +1,57 @@
 package subscriber
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"testing"
 	"time"
 
+	"github.com/abgdnv/gocommerce/notification_service/internal/email"
+	"github.com/abgdnv/gocommerce/pkg/messaging"
 	"github.com/abgdnv/gocommerce/pkg/messaging/events"
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
+// templatesDir is the location of the email templates relative to this package.
+const templatesDir = "../../templates"
+
+// spySender records the sent messages and returns the configured error.
+type spySender struct {
+	err  error
+	sent []email.Message
+}
+
+func (s *spySender) Send(_ context.Context, msg email.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// newTestNotifier creates a Notifier with the notification templates in the given directory.
+func newTestNotifier(t *testing.T, sender email.Sender, dir string) *Notifier {
+	t.Helper()
+	renderer, err := email.NewNotificationRenderer(dir)
+	require.NoError(t, err)
+	return NewNotifier(sender, renderer, &spyDeadLetterer{})
+}
+
 type mockAckableMsg struct {
 	mock.Mock
 }
 
+func (m *mockAckableMsg) Subject() string {
+	args := m.Called()
+	return args.String(0)
+}
+
 func (m *mockAckableMsg) Data() []byte {
 	args := m.Called()
 	return args.Get(0).([]byte)
@@ -26,6 +62,11 @@ func (m *mockAckableMsg) Ack() error {
 	return args.Error(0)
 }
 
+func (m *mockAckableMsg) Nak() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 func (m *mockAckableMsg) Term() error {
 	args := m.Called()
 	return args.Error(0)
@@ -43,11 +84,28 @@ func Test_handleMessage(t *testing.T) {
 				validPayload, _ := json.Marshal(&events.OrderCreatedEvent{
 					OrderID:    uuid.New(),
 					UserID:     uuid.New(),
+					UserEmail:  "user@example.com",
 					TotalPrice: 1000,
 					CreatedAt:  time.Now(),
 				})
 				msg := new(mockAckableMsg)
-				msg.On("Data").Return(validPayload).Times(1)
+				msg.On("Subject").Return(messaging.OrdersCreatedSubject)
+				msg.On("Data").Return(validPayload)
+				msg.On("Ack").Return(nil).Times(1)
+				return msg
+			},
+		},
+		{
+			name: "valid order completed message",
+			newMockMsg: func() *mockAckableMsg {
+				validPayload, _ := json.Marshal(&events.OrderCompletedEvent{
+					OrderID:     uuid.New(),
+					UserID:      uuid.New(),
+					CompletedAt: time.Now(),
+				})
+				msg := new(mockAckableMsg)
+				msg.On("Subject").Return(messaging.OrdersCompletedSubject)
+				msg.On("Data").Return(validPayload)
 				msg.On("Ack").Return(nil).Times(1)
 				return msg
 			},
@@ -56,7 +114,27 @@ func Test_handleMessage(t *testing.T) {
 			name: "invalid message",
 			newMockMsg: func() *mockAckableMsg {
 				msg := new(mockAckableMsg)
-				msg.On("Data").Return([]byte("invalid data")).Times(1)
+				msg.On("Subject").Return(messaging.OrdersCreatedSubject)
+				msg.On("Data").Return([]byte("invalid data"))
+				msg.On("Term").Return(nil).Times(1)
+				return msg
+			},
+		},
+		{
+			name: "unsupported schema version",
+			newMockMsg: func() *mockAckableMsg {
+				msg := new(mockAckableMsg)
+				msg.On("Subject").Return(messaging.OrdersCreatedSubject)
+				msg.On("Data").Return([]byte(`{"event_type":"OrderCreated","schema_version":2}`))
+				msg.On("Term").Return(nil).Times(1)
+				return msg
+			},
+		},
+		{
+			name: "unknown subject",
+			newMockMsg: func() *mockAckableMsg {
+				msg := new(mockAckableMsg)
+				msg.On("Subject").Return("orders.unknown")
 				msg.On("Term").Return(nil).Times(1)
 				return msg
 			},
@@ -68,10 +146,101 @@ func Test_handleMessage(t *testing.T) {
 			mockMsg := tc.newMockMsg()
 
 			// when
-			handleMessage(mockMsg, logger)
+			handleMessage(mockMsg, newTestNotifier(t, email.NoopSender{}, templatesDir).Handlers(), logger)
 
 			// then
 			mockMsg.AssertExpectations(t)
 		})
 	}
 }
+
+func TestNotifier_handleOrderCreated(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	orderID := uuid.New()
+	testCases := []struct {
+		name         string
+		userEmail    string
+		templatesDir string
+		sendErr      error
+		expectedAck  string
+		expectSent   bool
+	}{
+		{
+			name:         "confirmation email is sent",
+			userEmail:    "user@example.com",
+			templatesDir: templatesDir,
+			expectedAck:  "Ack",
+			expectSent:   true,
+		},
+		{
+			name:         "unknown user email - message is acked without sending",
+			userEmail:    "",
+			templatesDir: templatesDir,
+			expectedAck:  "Ack",
+		},
+		{
+			name:         "missing template - message is acked without sending",
+			userEmail:    "user@example.com",
+			templatesDir: t.TempDir(),
+			expectedAck:  "Ack",
+		},
+		{
+			name:         "send failure - message is redelivered",
+			userEmail:    "user@example.com",
+			templatesDir: templatesDir,
+			sendErr:      errors.New("smtp server is down"),
+			expectedAck:  "Nak",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			payload, _ := json.Marshal(&events.OrderCreatedEvent{
+				OrderID:    orderID,
+				UserID:     uuid.New(),
+				UserEmail:  tc.userEmail,
+				TotalPrice: 12345,
+				CreatedAt:  time.Now(),
+			})
+			msg := new(mockAckableMsg)
+			msg.On("Subject").Return(messaging.OrdersCreatedSubject)
+			msg.On("Data").Return(payload)
+			msg.On(tc.expectedAck).Return(nil).Times(1)
+			sender := &spySender{err: tc.sendErr}
+
+			// when
+			newTestNotifier(t, sender, tc.templatesDir).handleOrderCreated(msg, logger)
+
+			// then
+			msg.AssertExpectations(t)
+			if !tc.expectSent {
+				assert.Empty(t, sender.sent)
+				return
+			}
+			require.Len(t, sender.sent, 1)
+			sent := sender.sent[0]
+			assert.Equal(t, []string{tc.userEmail}, sent.To)
+			assert.Contains(t, sent.Subject, orderID.String())
+			assert.Contains(t, sent.Body, orderID.String())
+			assert.Contains(t, sent.Body, "123.45")
+			assert.Contains(t, sent.HTMLBody, orderID.String())
+		})
+	}
+}
+
+func Test_formatPrice(t *testing.T) {
+	testCases := []struct {
+		price    int64
+		expected string
+	}{
+		{price: 0, expected: "0.00"},
+		{price: 5, expected: "0.05"},
+		{price: 1999, expected: "19.99"},
+		{price: -250, expected: "-2.50"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatPrice(tc.price))
+		})
+	}
+}
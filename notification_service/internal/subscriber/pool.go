@@ -0,0 +1,86 @@
+package subscriber
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// workerPool bounds the number of messages handled concurrently across all workers of the subscriber.
+// A worker waits for a free slot before dispatching the next message, so it stops fetching while the pool is saturated,
+// and the messages already fetched are queued rather than handled by an unbounded number of goroutines.
+type workerPool struct {
+	slots    chan struct{}
+	wg       sync.WaitGroup
+	inFlight metric.Int64UpDownCounter
+	queued   metric.Int64UpDownCounter
+}
+
+// newWorkerPool creates a workerPool handling up to maxInFlight messages at once.
+// The in-flight and queued messages are measured with the meter.
+func newWorkerPool(maxInFlight int, meter metric.Meter) *workerPool {
+	inFlight, err := meter.Int64UpDownCounter("subscriber_in_flight_messages",
+		metric.WithDescription("Number of messages being handled by the subscriber"))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create subscriber_in_flight_messages gauge: %v", err))
+	}
+	queued, err := meter.Int64UpDownCounter("subscriber_queued_messages",
+		metric.WithDescription("Number of fetched messages waiting for a free worker slot"))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create subscriber_queued_messages gauge: %v", err))
+	}
+	return &workerPool{
+		slots:    make(chan struct{}, maxInFlight),
+		inFlight: inFlight,
+		queued:   queued,
+	}
+}
+
+// dispatch waits for a free slot and handles the message in its own goroutine.
+// Returns false without handling the message if the context is done first, the caller is responsible for the message then.
+func (p *workerPool) dispatch(ctx context.Context, msg AckableMsg, handlers map[string]Handler, logger *slog.Logger) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	p.queued.Add(ctx, 1)
+	select {
+	case p.slots <- struct{}{}:
+		p.queued.Add(ctx, -1)
+	case <-ctx.Done():
+		p.queued.Add(ctx, -1)
+		return false
+	}
+	// the in-flight gauge isn't bound to the context of the workers, which is done before the handlers are
+	p.inFlight.Add(context.Background(), 1)
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			p.inFlight.Add(context.Background(), -1)
+			<-p.slots
+			p.wg.Done()
+		}()
+		handleMessage(msg, handlers, logger)
+	}()
+	return true
+}
+
+// wait waits for the in-flight handlers to complete up to the timeout, and reports whether they did.
+func (p *workerPool) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
@@ -0,0 +1,75 @@
+package subscriber
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/messaging"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// newTestPoolMsg returns an order created message, which may be acknowledged by the handler.
+func newTestPoolMsg() *mockAckableMsg {
+	msg := new(mockAckableMsg)
+	msg.On("Subject").Return(messaging.OrdersCreatedSubject)
+	msg.On("Ack").Return(nil)
+	return msg
+}
+
+func TestWorkerPool_DispatchBlocksWhenSaturated(t *testing.T) {
+	// given: a pool of a single slot, taken by a handler released by the test
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	pool := newWorkerPool(1, noop.NewMeterProvider().Meter("test"))
+	release := make(chan struct{})
+	var handled atomic.Int32
+	handlers := map[string]Handler{messaging.OrdersCreatedSubject: func(msg AckableMsg, _ *slog.Logger) {
+		<-release
+		handled.Add(1)
+		_ = msg.Ack()
+	}}
+	assert.True(t, pool.dispatch(context.Background(), newTestPoolMsg(), handlers, logger))
+
+	// when
+	dispatched := make(chan bool, 1)
+	go func() { dispatched <- pool.dispatch(context.Background(), newTestPoolMsg(), handlers, logger) }()
+
+	// then
+	select {
+	case <-dispatched:
+		t.Fatal("the second message should wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	assert.True(t, <-dispatched)
+	assert.True(t, pool.wait(time.Second))
+	assert.Equal(t, int32(2), handled.Load())
+}
+
+func TestWorkerPool_DispatchStopsOnCancel(t *testing.T) {
+	// given: a saturated pool
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	pool := newWorkerPool(1, noop.NewMeterProvider().Meter("test"))
+	release := make(chan struct{})
+	handlers := map[string]Handler{messaging.OrdersCreatedSubject: func(msg AckableMsg, _ *slog.Logger) {
+		<-release
+		_ = msg.Ack()
+	}}
+	assert.True(t, pool.dispatch(context.Background(), newTestPoolMsg(), handlers, logger))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// when
+	dispatched := make(chan bool, 1)
+	go func() { dispatched <- pool.dispatch(ctx, newTestPoolMsg(), handlers, logger) }()
+	cancel()
+
+	// then
+	assert.False(t, <-dispatched, "the queued message should not be dispatched once the context is done")
+	assert.False(t, pool.wait(50*time.Millisecond), "the in-flight handler should not complete before it's released")
+	close(release)
+	assert.True(t, pool.wait(time.Second), "the in-flight handler should be drained")
+}
@@ -0,0 +1,51 @@
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/abgdnv/gocommerce/pkg/messaging/events"
+)
+
+// DeadLetterer moves the messages which can't be processed yet to a dead letter queue,
+// where they can be inspected and replayed later.
+type DeadLetterer interface {
+	DeadLetter(ctx context.Context, msg AckableMsg, reason string) error
+}
+
+// Versioned routes the messages of a subject to the handler of their schema version, see events.Envelope.
+// Messages of a version without a handler, e.g. published by a newer publisher, are dead-lettered
+// instead of being handled with the wrong schema, and can be replayed once their version is handled.
+func Versioned(handlers map[int]Handler, dlq DeadLetterer) Handler {
+	return func(msg AckableMsg, logger *slog.Logger) {
+		var envelope events.Envelope
+		if err := json.Unmarshal(msg.Data(), &envelope); err != nil {
+			logger.Error("failed to unmarshal message envelope", "subject", msg.Subject(), "error", err)
+			termMessage(msg, logger)
+			return
+		}
+		handler, ok := handlers[envelope.Version()]
+		if !ok {
+			deadLetter(msg, dlq, fmt.Sprintf("unsupported schema version %d", envelope.Version()), logger)
+			return
+		}
+		handler(msg, logger)
+	}
+}
+
+// deadLetter moves the message to the dead letter queue and terminates it.
+// If the message can't be dead-lettered, it is redelivered rather than lost.
+func deadLetter(msg AckableMsg, dlq DeadLetterer, reason string, logger *slog.Logger) {
+	ctx := context.Background()
+	logger.WarnContext(ctx, "dead-lettering message", "subject", msg.Subject(), "reason", reason)
+	if err := dlq.DeadLetter(ctx, msg, reason); err != nil {
+		logger.ErrorContext(ctx, "failed to dead-letter message", "subject", msg.Subject(), "error", err)
+		if err := msg.Nak(); err != nil {
+			logger.ErrorContext(ctx, "failed to nak message", "error", err)
+		}
+		return
+	}
+	termMessage(msg, logger)
+}
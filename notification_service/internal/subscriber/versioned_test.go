@@ -0,0 +1,100 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/abgdnv/gocommerce/pkg/messaging"
+	"github.com/stretchr/testify/assert"
+)
+
+// spyDeadLetterer records the reasons of the dead-lettered messages and returns the configured error.
+type spyDeadLetterer struct {
+	err     error
+	reasons []string
+}
+
+func (d *spyDeadLetterer) DeadLetter(_ context.Context, _ AckableMsg, reason string) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.reasons = append(d.reasons, reason)
+	return nil
+}
+
+func TestVersioned(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	testCases := []struct {
+		name            string
+		payload         string
+		dlqErr          error
+		expectedAck     string
+		expectedHandler int
+		expectedReasons []string
+	}{
+		{
+			name:            "v1 event - handled by the v1 handler",
+			payload:         `{"event_type":"OrderCreated","schema_version":1}`,
+			expectedAck:     "Ack",
+			expectedHandler: 1,
+		},
+		{
+			name:            "event without version - handled as v1",
+			payload:         `{"order_id":"8b0a3bba-57a3-4c3e-8f38-8b4c3cbd0a6e"}`,
+			expectedAck:     "Ack",
+			expectedHandler: 1,
+		},
+		{
+			name:            "v2 event - handled by the v2 handler",
+			payload:         `{"event_type":"OrderCreated","schema_version":2}`,
+			expectedAck:     "Ack",
+			expectedHandler: 2,
+		},
+		{
+			name:            "unknown version - dead-lettered and terminated",
+			payload:         `{"event_type":"OrderCreated","schema_version":3}`,
+			expectedAck:     "Term",
+			expectedReasons: []string{"unsupported schema version 3"},
+		},
+		{
+			name:        "unknown version and DLQ failure - message is redelivered",
+			payload:     `{"event_type":"OrderCreated","schema_version":3}`,
+			dlqErr:      errors.New("nats is down"),
+			expectedAck: "Nak",
+		},
+		{
+			name:        "invalid payload - terminated",
+			payload:     "invalid data",
+			expectedAck: "Term",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			msg := new(mockAckableMsg)
+			msg.On("Subject").Return(messaging.OrdersCreatedSubject).Maybe()
+			msg.On("Data").Return([]byte(tc.payload))
+			msg.On(tc.expectedAck).Return(nil).Times(1)
+			dlq := &spyDeadLetterer{err: tc.dlqErr}
+			var handled int
+			versionHandler := func(version int) Handler {
+				return func(msg AckableMsg, _ *slog.Logger) {
+					handled = version
+					_ = msg.Ack()
+				}
+			}
+			handler := Versioned(map[int]Handler{1: versionHandler(1), 2: versionHandler(2)}, dlq)
+
+			// when
+			handler(msg, logger)
+
+			// then
+			msg.AssertExpectations(t)
+			assert.Equal(t, tc.expectedHandler, handled)
+			assert.Equal(t, tc.expectedReasons, dlq.reasons)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package subscriber
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/abgdnv/gocommerce/notification_service/internal/webhook"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// WebhookQueue queues the deliveries of the events to the webhooks of integrators, see StreamWebhookQueue.
+type WebhookQueue interface {
+	Enqueue(ctx context.Context, subject string, payload []byte) error
+}
+
+// StreamWebhookQueue publishes a delivery of the event per endpoint to the webhook stream, see webhook.StreamConfig.
+// A delivery is deduplicated by its message ID, made of the hash of the payload and the endpoint,
+// so queueing an event again, e.g. on its redelivery, doesn't post it again to the endpoints.
+type StreamWebhookQueue struct {
+	js        jetstream.JetStream
+	endpoints []string
+}
+
+// NewStreamWebhookQueue creates a new StreamWebhookQueue, which queues the deliveries to the endpoints.
+func NewStreamWebhookQueue(js jetstream.JetStream, endpoints []string) *StreamWebhookQueue {
+	return &StreamWebhookQueue{js: js, endpoints: endpoints}
+}
+
+// Enqueue publishes a delivery of the payload to every endpoint with the endpoint in the webhook.EndpointHeader.
+func (q *StreamWebhookQueue) Enqueue(ctx context.Context, subject string, payload []byte) error {
+	sum := sha256.Sum256(payload)
+	for _, endpoint := range q.endpoints {
+		delivery := nats.NewMsg(webhook.SubjectPrefix + "." + subject)
+		delivery.Data = payload
+		delivery.Header.Set(webhook.EndpointHeader, endpoint)
+		if _, err := q.js.PublishMsg(ctx, delivery, jetstream.WithMsgID(hex.EncodeToString(sum[:])+" "+endpoint)); err != nil {
+			return fmt.Errorf("failed to queue webhook delivery to %s: %w", endpoint, err)
+		}
+	}
+	return nil
+}
+
+// WithWebhooks wraps the handlers to queue the deliveries of every event to the webhooks before it is handled,
+// e.g. by the email notification. The deliveries are made by the WebhookHandlers of their own consumer,
+// so a slow or failing webhook neither delays nor duplicates the emails.
+// An event which can't be queued is redelivered without being handled.
+func WithWebhooks(handlers map[string]Handler, queue WebhookQueue) map[string]Handler {
+	wrapped := make(map[string]Handler, len(handlers))
+	for subject, handler := range handlers {
+		wrapped[subject] = withWebhook(handler, queue)
+	}
+	return wrapped
+}
+
+// withWebhook wraps a single handler, see WithWebhooks.
+func withWebhook(handler Handler, queue WebhookQueue) Handler {
+	return func(msg AckableMsg, logger *slog.Logger) {
+		ctx := context.Background()
+		if err := queue.Enqueue(ctx, msg.Subject(), msg.Data()); err != nil {
+			logger.ErrorContext(ctx, "failed to queue event for webhooks, it will be redelivered", "subject", msg.Subject(), "error", err)
+			nakMessage(msg, logger)
+			return
+		}
+		handler(msg, logger)
+	}
+}
+
+// WebhookPoster makes a single attempt to deliver an event to an endpoint, see webhook.Sender.
+type WebhookPoster interface {
+	Post(ctx context.Context, endpoint, subject string, payload []byte) (bool, error)
+}
+
+// deliveryMsg is a queued webhook delivery, which is redelivered after a delay to retry it, see jetstream.Msg.
+type deliveryMsg interface {
+	AckableMsg
+	Headers() nats.Header
+	Metadata() (*jetstream.MsgMetadata, error)
+	NakWithDelay(delay time.Duration) error
+}
+
+// WebhookHandlers returns the handlers of the deliveries queued by WithWebhooks for the events with the subjects.
+// A delivery is acknowledged once the endpoint accepted it, regardless of the deliveries to the other endpoints.
+// A delivery failing with a retryable error is redelivered after the backoff, doubled for every further attempt,
+// up to maxAttempts in total. A delivery still failing, or rejected by the endpoint, is dead-lettered to be replayed later.
+func WebhookHandlers(subjects []string, poster WebhookPoster, dlq DeadLetterer, maxAttempts int, backoff time.Duration) map[string]Handler {
+	handlers := make(map[string]Handler, len(subjects))
+	for _, subject := range subjects {
+		handlers[webhook.SubjectPrefix+"."+subject] = deliverWebhook(subject, poster, dlq, maxAttempts, backoff)
+	}
+	return handlers
+}
+
+// deliverWebhook returns the handler of the deliveries of the events with the subject, see WebhookHandlers.
+func deliverWebhook(subject string, poster WebhookPoster, dlq DeadLetterer, maxAttempts int, backoff time.Duration) Handler {
+	return func(m AckableMsg, logger *slog.Logger) {
+		ctx := context.Background()
+		msg, ok := m.(deliveryMsg)
+		if !ok {
+			logger.ErrorContext(ctx, "webhook delivery is not a JetStream message", "subject", m.Subject())
+			termMessage(m, logger)
+			return
+		}
+		endpoint := msg.Headers().Get(webhook.EndpointHeader)
+		if endpoint == "" {
+			deadLetter(msg, dlq, "webhook delivery has no endpoint", logger)
+			return
+		}
+		retryable, err := poster.Post(ctx, endpoint, subject, msg.Data())
+		if err == nil {
+			ackMessage(ctx, msg, logger)
+			return
+		}
+		attempt := 1
+		if metadata, metadataErr := msg.Metadata(); metadataErr == nil {
+			attempt = int(metadata.NumDelivered)
+		}
+		if retryable && attempt < maxAttempts {
+			delay := backoff << (attempt - 1)
+			logger.WarnContext(ctx, "webhook delivery failed, retrying", "endpoint", endpoint, "attempt", attempt, "delay", delay, "error", err)
+			if err := msg.NakWithDelay(delay); err != nil {
+				logger.ErrorContext(ctx, "failed to nak message", "error", err)
+			}
+			return
+		}
+		deadLetter(msg, dlq, fmt.Sprintf("webhook delivery to %s failed on attempt %d: %s", endpoint, attempt, err), logger)
+	}
+}
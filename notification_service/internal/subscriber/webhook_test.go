@@ -0,0 +1,201 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/notification_service/internal/webhook"
+	"github.com/abgdnv/gocommerce/pkg/messaging"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+)
+
+// spyWebhookQueue records the queued payloads and returns the configured error.
+type spyWebhookQueue struct {
+	err      error
+	payloads []string
+}
+
+func (s *spyWebhookQueue) Enqueue(_ context.Context, _ string, payload []byte) error {
+	s.payloads = append(s.payloads, string(payload))
+	return s.err
+}
+
+func TestWithWebhooks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	payload := `{"event_type":"OrderCreated","schema_version":1}`
+	testCases := []struct {
+		name          string
+		queueErr      error
+		expectedAck   string
+		expectHandled bool
+	}{
+		{
+			name:          "queued event is handled",
+			expectedAck:   "Ack",
+			expectHandled: true,
+		},
+		{
+			name:        "event which can't be queued is redelivered without being handled",
+			queueErr:    errors.New("nats is down"),
+			expectedAck: "Nak",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			msg := new(mockAckableMsg)
+			msg.On("Subject").Return(messaging.OrdersCreatedSubject)
+			msg.On("Data").Return([]byte(payload))
+			msg.On(tc.expectedAck).Return(nil).Times(1)
+			queue := &spyWebhookQueue{err: tc.queueErr}
+			var handled bool
+			handlers := WithWebhooks(map[string]Handler{
+				messaging.OrdersCreatedSubject: func(msg AckableMsg, _ *slog.Logger) {
+					handled = true
+					_ = msg.Ack()
+				},
+			}, queue)
+
+			// when
+			handlers[messaging.OrdersCreatedSubject](msg, logger)
+
+			// then
+			msg.AssertExpectations(t)
+			assert.Equal(t, tc.expectHandled, handled)
+			assert.Equal(t, []string{payload}, queue.payloads)
+		})
+	}
+}
+
+// spyWebhookPoster records the posted endpoints and returns the configured result.
+type spyWebhookPoster struct {
+	retryable bool
+	err       error
+	endpoints []string
+}
+
+func (s *spyWebhookPoster) Post(_ context.Context, endpoint, subject string, _ []byte) (bool, error) {
+	s.endpoints = append(s.endpoints, endpoint+" "+subject)
+	return s.retryable, s.err
+}
+
+// mockDeliveryMsg is a queued webhook delivery, delivered for the numDelivered time.
+type mockDeliveryMsg struct {
+	mockAckableMsg
+	headers      nats.Header
+	numDelivered uint64
+	nakDelay     time.Duration
+}
+
+func (m *mockDeliveryMsg) Headers() nats.Header {
+	return m.headers
+}
+
+func (m *mockDeliveryMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	return &jetstream.MsgMetadata{NumDelivered: m.numDelivered}, nil
+}
+
+func (m *mockDeliveryMsg) NakWithDelay(delay time.Duration) error {
+	m.nakDelay = delay
+	return m.Called().Error(0)
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	endpoint := "https://partner.example.com/hooks"
+	testCases := []struct {
+		name              string
+		poster            *spyWebhookPoster
+		numDelivered      uint64
+		noEndpoint        bool
+		dlqErr            error
+		expectedAck       string
+		expectedNakDelay  time.Duration
+		expectedReasons   []string
+		expectedEndpoints []string
+	}{
+		{
+			name:              "delivered event is acknowledged",
+			poster:            &spyWebhookPoster{},
+			numDelivered:      1,
+			expectedAck:       "Ack",
+			expectedEndpoints: []string{endpoint + " orders.created"},
+		},
+		{
+			name:              "transient failure is redelivered after the backoff",
+			poster:            &spyWebhookPoster{retryable: true, err: errors.New("endpoint responded with 503")},
+			numDelivered:      1,
+			expectedAck:       "NakWithDelay",
+			expectedNakDelay:  100 * time.Millisecond,
+			expectedEndpoints: []string{endpoint + " orders.created"},
+		},
+		{
+			name:              "backoff doubles for every further attempt",
+			poster:            &spyWebhookPoster{retryable: true, err: errors.New("endpoint responded with 503")},
+			numDelivered:      2,
+			expectedAck:       "NakWithDelay",
+			expectedNakDelay:  200 * time.Millisecond,
+			expectedEndpoints: []string{endpoint + " orders.created"},
+		},
+		{
+			name:              "delivery failing after the max attempts is dead-lettered",
+			poster:            &spyWebhookPoster{retryable: true, err: errors.New("endpoint responded with 503")},
+			numDelivered:      3,
+			expectedAck:       "Term",
+			expectedReasons:   []string{"webhook delivery to " + endpoint + " failed on attempt 3: endpoint responded with 503"},
+			expectedEndpoints: []string{endpoint + " orders.created"},
+		},
+		{
+			name:              "rejected event is dead-lettered at once",
+			poster:            &spyWebhookPoster{err: errors.New("endpoint rejected the event with 400")},
+			numDelivered:      1,
+			expectedAck:       "Term",
+			expectedReasons:   []string{"webhook delivery to " + endpoint + " failed on attempt 1: endpoint rejected the event with 400"},
+			expectedEndpoints: []string{endpoint + " orders.created"},
+		},
+		{
+			name:              "undelivered event is redelivered if it can't be dead-lettered",
+			poster:            &spyWebhookPoster{err: errors.New("endpoint rejected the event with 400")},
+			numDelivered:      1,
+			dlqErr:            errors.New("nats is down"),
+			expectedAck:       "Nak",
+			expectedEndpoints: []string{endpoint + " orders.created"},
+		},
+		{
+			name:            "delivery without endpoint is dead-lettered",
+			poster:          &spyWebhookPoster{},
+			noEndpoint:      true,
+			expectedAck:     "Term",
+			expectedReasons: []string{"webhook delivery has no endpoint"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			msg := &mockDeliveryMsg{headers: nats.Header{}, numDelivered: tc.numDelivered}
+			if !tc.noEndpoint {
+				msg.headers.Set(webhook.EndpointHeader, endpoint)
+			}
+			msg.On("Subject").Return("webhooks.orders.created").Maybe()
+			msg.On("Data").Return([]byte(`{}`)).Maybe()
+			msg.On(tc.expectedAck).Return(nil).Times(1)
+			dlq := &spyDeadLetterer{err: tc.dlqErr}
+			handlers := WebhookHandlers([]string{messaging.OrdersCreatedSubject}, tc.poster, dlq, 3, 100*time.Millisecond)
+
+			// when
+			handlers["webhooks.orders.created"](msg, logger)
+
+			// then
+			msg.AssertExpectations(t)
+			assert.Equal(t, tc.expectedNakDelay, msg.nakDelay)
+			assert.Equal(t, tc.expectedEndpoints, tc.poster.endpoints, "the delivery should be posted once to its endpoint only")
+			assert.Equal(t, tc.expectedReasons, dlq.reasons)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Package webhook provides delivery of the order events to the HTTP webhooks of integrators.
+// Every event is queued once per endpoint in the webhook stream, see StreamConfig,
+// so each endpoint gets its own delivery, retried and acknowledged regardless of the other endpoints and the emails.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/abgdnv/gocommerce/notification_service/internal/config"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// SignatureHeader is the header with the signature of the payload, see Sign.
+// Subscribers verify it with the shared secret to make sure the event was sent by the notification service.
+const SignatureHeader = "X-Webhook-Signature"
+
+// SubjectHeader is the header with the subject of the event, e.g. orders.created.
+const SubjectHeader = "X-Webhook-Subject"
+
+// EndpointHeader is the header of a queued delivery with the endpoint the event is delivered to.
+const EndpointHeader = "Webhook-Endpoint"
+
+// SubjectPrefix prefixes the subject of the event in the subject of its queued deliveries, e.g. webhooks.orders.created.
+const SubjectPrefix = "webhooks"
+
+// duplicateWindow is the time a queued delivery is deduplicated by its message ID, see subscriber.StreamWebhookQueue.
+// It spans the redeliveries of an event which failed to be handled, so they don't queue the deliveries again.
+const duplicateWindow = time.Hour
+
+// StreamConfig returns the configuration of the stream of the queued deliveries.
+// It matches the WEBHOOKS stream of the NATS stream setup job: the deliveries are a work queue,
+// new deliveries are rejected when the stream is full.
+func StreamConfig(name string) jetstream.StreamConfig {
+	return jetstream.StreamConfig{
+		Name:       name,
+		Subjects:   []string{SubjectPrefix + ".>"},
+		Retention:  jetstream.WorkQueuePolicy,
+		Storage:    jetstream.FileStorage,
+		Discard:    jetstream.DiscardNew,
+		MaxAge:     7 * 24 * time.Hour,
+		MaxBytes:   1 << 30,
+		Duplicates: duplicateWindow,
+	}
+}
+
+// drainLimit bounds the bytes read from the body of a response, so its connection can be reused.
+const drainLimit = 4 << 10
+
+// Sender posts the events to the webhook endpoints.
+type Sender struct {
+	client *http.Client
+	secret []byte
+}
+
+// NewSender creates a Sender for the webhook configuration, every post is bounded by the timeout of the configuration.
+func NewSender(cfg config.WebhookConfig) *Sender {
+	return &Sender{
+		client: &http.Client{Timeout: cfg.Timeout},
+		secret: []byte(cfg.Secret),
+	}
+}
+
+// Sign returns the signature of the payload: the hex encoded HMAC-SHA256 keyed with the secret, prefixed with "sha256=".
+func Sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post makes a single attempt to deliver the JSON payload of the event to the endpoint,
+// and reports whether a failure is worth a retry: the endpoint isn't reachable, or responds with 429 or 5xx.
+// Other responses than 2xx fail the delivery for good, since the endpoint rejects the event.
+func (s *Sender) Post(ctx context.Context, endpoint, subject string, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(s.secret, payload))
+	req.Header.Set(SubjectHeader, subject)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post event: %w", err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, drainLimit))
+		_ = resp.Body.Close()
+	}()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("endpoint responded with %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("endpoint rejected the event with %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/notification_service/internal/config"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receivedRequest is a request received by the test endpoint.
+type receivedRequest struct {
+	body        string
+	contentType string
+	signature   string
+	subject     string
+}
+
+// newTestEndpoint returns an endpoint responding with the statuses in order, and 200 once they are used up.
+func newTestEndpoint(t *testing.T, statuses ...int) (*httptest.Server, func() []receivedRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var received []receivedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, receivedRequest{
+			body:        string(body),
+			contentType: r.Header.Get("Content-Type"),
+			signature:   r.Header.Get(SignatureHeader),
+			subject:     r.Header.Get(SubjectHeader),
+		})
+		status := http.StatusOK
+		if len(received) <= len(statuses) {
+			status = statuses[len(received)-1]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []receivedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedRequest(nil), received...)
+	}
+}
+
+func newTestSender() *Sender {
+	return NewSender(config.WebhookConfig{
+		Enabled: true,
+		Secret:  "s3cret",
+		Timeout: time.Second,
+	})
+}
+
+func TestSender_Post(t *testing.T) {
+	// given
+	payload := `{"event_type":"OrderCreated","order_id":"8b0a3bba-57a3-4c3e-8f38-8b4c3cbd0a6e"}`
+	endpoint, received := newTestEndpoint(t)
+
+	// when
+	retryable, err := newTestSender().Post(context.Background(), endpoint.URL, "orders.created", []byte(payload))
+
+	// then
+	require.NoError(t, err)
+	assert.False(t, retryable)
+	requests := received()
+	require.Len(t, requests, 1, "the endpoint should receive the event once")
+	assert.JSONEq(t, payload, requests[0].body)
+	assert.Equal(t, "application/json", requests[0].contentType)
+	assert.Equal(t, "orders.created", requests[0].subject)
+	assert.Equal(t, Sign([]byte("s3cret"), []byte(payload)), requests[0].signature)
+}
+
+func TestSender_Post_Failure(t *testing.T) {
+	testCases := []struct {
+		name              string
+		status            int
+		expectedRetryable bool
+	}{
+		{name: "server error is retryable", status: http.StatusServiceUnavailable, expectedRetryable: true},
+		{name: "rate limited delivery is retryable", status: http.StatusTooManyRequests, expectedRetryable: true},
+		{name: "rejected event is not retryable", status: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			endpoint, received := newTestEndpoint(t, tc.status)
+
+			// when
+			retryable, err := newTestSender().Post(context.Background(), endpoint.URL, "orders.created", []byte(`{}`))
+
+			// then
+			assert.Error(t, err)
+			assert.Equal(t, tc.expectedRetryable, retryable)
+			assert.Len(t, received(), 1, "a post should make a single attempt")
+		})
+	}
+}
+
+func TestSender_Post_Unreachable(t *testing.T) {
+	// given: nothing listens on the endpoint
+	endpoint, _ := newTestEndpoint(t)
+	endpoint.Close()
+
+	// when
+	retryable, err := newTestSender().Post(context.Background(), endpoint.URL, "orders.created", []byte(`{}`))
+
+	// then
+	assert.Error(t, err)
+	assert.True(t, retryable, "an unreachable endpoint should be retried")
+}
+
+func TestStreamConfig(t *testing.T) {
+	// when
+	cfg := StreamConfig("WEBHOOKS")
+
+	// then
+	assert.Equal(t, "WEBHOOKS", cfg.Name)
+	assert.Equal(t, []string{"webhooks.>"}, cfg.Subjects)
+	assert.Equal(t, jetstream.WorkQueuePolicy, cfg.Retention)
+	assert.Equal(t, time.Hour, cfg.Duplicates, "the redeliveries of an event should not queue its deliveries again")
+}
+
+func TestSign(t *testing.T) {
+	// computed with: echo -n '{"hello":"world"}' | openssl dgst -sha256 -hmac s3cret
+	assert.Equal(t, "sha256=d5d644dccc0b0763243db8acd3c44bab4adda9a2511ed24f2ba86379ff0f8a66", Sign([]byte("s3cret"), []byte(`{"hello":"world"}`)))
+}
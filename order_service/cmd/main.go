@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"log/slog"
@@ -10,26 +9,25 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/abgdnv/gocommerce/pkg/bootstrap"
+	"github.com/abgdnv/gocommerce/pkg/bootstrap/migration"
 	"github.com/abgdnv/gocommerce/pkg/client/grpc/interceptors"
+	"github.com/abgdnv/gocommerce/pkg/grpctls"
 	"github.com/abgdnv/gocommerce/pkg/nats"
+	"github.com/abgdnv/gocommerce/pkg/server"
 	"github.com/abgdnv/gocommerce/pkg/telemetry"
-	"github.com/nats-io/nats.go/jetstream"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
 
 	"github.com/abgdnv/gocommerce/order_service/internal/app"
 	"github.com/abgdnv/gocommerce/order_service/internal/config"
-	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/product/v1"
 	pconfig "github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/abgdnv/gocommerce/pkg/config/configloader"
-	"github.com/jackc/pgx/v5/pgxpool"
-	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 const serviceName = "order"
@@ -45,16 +43,16 @@ func main() {
 	log.Println("application stopped gracefully")
 }
 
-// run initializes the application, sets up the database connection, and starts the HTTP, gRPC and pprof servers.
+// run initializes the application, sets up the database connection, verifies the dependencies of the order routes,
+// and starts the HTTP, gRPC and pprof servers.
 func run(ctx context.Context) error {
 	cfg, cfgErr := configloader.Load[*config.Config](serviceName)
 	if cfgErr != nil {
 		return fmt.Errorf("failed to load configuration: %w", cfgErr)
 	}
-	log.Printf("Configuration loaded: %v", cfg)
-
-	logger := bootstrap.NewLogger(cfg.Log.Level)
+	logger := bootstrap.NewLogger(cfg.Log.Level, cfg.Log.Format, cfg.Log.AddSource)
 	slog.SetDefault(logger)
+	bootstrap.LogEffectiveConfig(logger, cfg)
 
 	// create tracer provider
 	tracerProvider, err := telemetry.NewTracerProvider(ctx, serviceName, cfg.Telemetry)
@@ -63,30 +61,31 @@ func run(ctx context.Context) error {
 		return err
 	}
 
-	dbPool, err := bootstrap.NewDbPool(ctx, cfg.Database.URI(), cfg.Database.Timeout)
+	if cfg.Database.AutoMigrate {
+		if err := migration.Up(ctx, cfg.Database, logger); err != nil {
+			return fmt.Errorf("failed to migrate the database: %w", err)
+		}
+	}
+
+	dbPool, err := bootstrap.NewDbPool(ctx, cfg.Database.URI(), cfg.Database.Timeout, cfg.Database.Pool)
 	if err != nil {
 		return fmt.Errorf("failed to create database connection pool: %w", err)
 	}
 	defer dbPool.Close()
 	logger.Info("Successfully connected to the database!")
 
-	// Create a gRPC client connection to the Product service
-	grpcClient, err := grpc.NewClient(
-		cfg.Services.Product.Grpc.Addr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithChainUnaryInterceptor(
-			interceptors.NewRetryInterceptor(cfg.Resilience.Retry),
-			interceptors.NewCircuitBreaker(cfg.Resilience.CircuitBreaker),
-			interceptors.UnaryClientTimeoutInterceptor(cfg.Services.Product.Grpc.Timeout),
-		),
-		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
-	)
+	// Create the gRPC client connections to the Product service and to the User service,
+	// which looks up the email of the owner of an order when its confirmation is resent.
+	grpcClient, err := newGrpcClient(cfg, cfg.Services.Product.Grpc, "product_client")
 	if err != nil {
-		return fmt.Errorf("failed to create gRPC client connection: %w", err)
+		return err
+	}
+	userClient, err := newGrpcClient(cfg, cfg.Services.User.Grpc, "user_client")
+	if err != nil {
+		return err
 	}
-	productClient := pb.NewProductServiceClient(grpcClient)
 
-	natsConn, err := nats.NewClient(cfg.Nats.Url, cfg.Nats.Timeout)
+	natsConn, err := nats.NewClient(ctx, cfg.Nats, logger)
 	if err != nil {
 		return fmt.Errorf("failed to create NATS connection: %w", err)
 	}
@@ -95,139 +94,98 @@ func run(ctx context.Context) error {
 		return fmt.Errorf("failed to get JetStream context: %w", err)
 	}
 
-	// Set up HTTP and pprof servers
-	httpServer, pprofServer := setupServers(dbPool, productClient, js, logger, cfg)
+	// no server accepts requests until the Product service is serving and the order events stream exists
+	if err := app.VerifyDependencies(ctx, healthpb.NewHealthClient(grpcClient), js, cfg.Startup, cfg.Stream, logger); err != nil {
+		return fmt.Errorf("dependencies are not available: %w", err)
+	}
 
-	g, gCtx := errgroup.WithContext(ctx)
+	// Set up HTTP and pprof servers
+	deps := app.SetupDependencies(dbPool, cfg.Database.QueryTimeout, cfg.Database.SlowQueryThreshold, grpcClient, userClient, js, cfg.Orders, cfg.Services.Product.Cache, cfg.Audit, logger)
+	httpServer, pprofServer := setupServers(deps, cfg)
+	drainer := server.NewDrainer(cfg.Shutdown.DrainDelay, logger)
+	httpServer.Handler = drainer.Middleware(httpServer.Handler)
 
-	// Start the HTTP server
-	g.Go(func() error {
-		logger.Info("HTTP server listening", slog.String("addr", httpServer.Addr))
-		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			return fmt.Errorf("http server failed: %w", err)
-		}
-		return nil
-	})
-	// gracefully shutdown HTTP server on context cancellation
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down HTTP server...")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-		defer cancel()
-		return httpServer.Shutdown(shutdownCtx)
-	})
-
-	// Start the pprof server if enabled
+	httpComponents := []bootstrap.Component{bootstrap.NewHTTPServerComponent("HTTP server", httpServer, logger)}
 	if cfg.PProf.Enabled {
-		g.Go(func() error {
-			logger.Info("Pprof server listening", slog.String("addr", pprofServer.Addr))
-			if err := pprofServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				return fmt.Errorf("pprof server failed: %w", err)
-			}
-			return nil
-		})
-		// gracefully shutdown pprof server on context cancellation
-		g.Go(func() error {
-			<-gCtx.Done()
-			logger.Info("Shutting down pprof server...")
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-			defer cancel()
-			return pprofServer.Shutdown(shutdownCtx)
-		})
-	}
-
-	// Start the metrics server if enabled
+		httpComponents = append(httpComponents, bootstrap.NewHTTPServerComponent("pprof server", pprofServer, logger))
+	}
 	if cfg.Telemetry.Metrics.Enabled {
 		metricsServer, err := setupMetricsServer(&cfg.Telemetry)
 		if err != nil {
 			return fmt.Errorf("failed to create metrics server")
 		}
-		g.Go(func() error {
-			logger.Info("Metrics server listening", slog.String("addr", metricsServer.Addr))
-			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				return fmt.Errorf("metrics server failed: %w", err)
-			}
-			return nil
-		})
-		// gracefully shutdown metrics server on context cancellation
-		g.Go(func() error {
-			<-gCtx.Done()
-			logger.Info("Shutting down metrics server...")
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-			defer cancel()
-			return metricsServer.Shutdown(shutdownCtx)
-		})
-	}
-
-	// gracefully shutdown grpc client
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down grpc client")
-
-		closeDone := make(chan struct{})
-		go func() {
-			err := grpcClient.Close()
-			if err != nil {
-				logger.Error("failed to close gRPC client connection")
-			}
-			close(closeDone)
-		}()
-
-		select {
-		case <-closeDone:
-			logger.Info("gRPC client connection closed successfully")
-			return nil
-		case <-time.After(cfg.Shutdown.Timeout):
-			return fmt.Errorf("failed to close gRPC client connection")
-		}
-	})
-
-	// gracefully shutdown NATS connection on context cancellation
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Draining NATS connection...")
-
-		drainDone := make(chan struct{})
-		go func() {
-			if err := natsConn.Drain(); err != nil {
-				logger.Error("failed to drain nats connection", "error", err)
-			}
-			close(drainDone)
-		}()
-
-		select {
-		case <-drainDone:
-			logger.Info("NATS connection drained successfully.")
-			return nil
-		case <-time.After(cfg.Shutdown.Timeout):
-			return fmt.Errorf("nats drain timeout")
-		}
-	})
-	// gracefully shutdown tracer provider
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down tracer provider")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-		defer cancel()
-		if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
-			return fmt.Errorf("failed to shutdown tracer provider: %v", err)
+		httpComponents = append(httpComponents, bootstrap.NewHTTPServerComponent("metrics server", metricsServer, logger))
+	}
+	// the pool stats stop with the context, the servers are stopped by the shutdown stages
+	var components []bootstrap.Component
+	if cfg.Telemetry.Metrics.Enabled {
+		poolStats, err := bootstrap.NewPoolStatsComponent(dbPool, otel.Meter("order-service"), bootstrap.DefaultPoolStatsInterval)
+		if err != nil {
+			return fmt.Errorf("failed to create the database pool stats: %w", err)
 		}
-		return nil
-	})
+		components = append(components, poolStats)
+	}
+	components = append(components, httpComponents...)
+
+	// the readiness probe fails first while the servers still serve requests,
+	// the servers stop before the clients they use, and the traces of the shutdown are flushed last
+	stages := []server.ShutdownStage{
+		{Name: "drain", Timeout: cfg.Shutdown.Stages.Drain, Funcs: []server.ShutdownFunc{drainer.Drain}},
+		{Name: "HTTP servers", Timeout: cfg.Shutdown.Stages.HTTP, Funcs: shutdownFuncs(httpComponents)},
+		{Name: "gRPC clients", Timeout: cfg.Shutdown.Stages.GRPC, Funcs: []server.ShutdownFunc{func(_ context.Context) error {
+			return grpcClient.Close()
+		}, func(_ context.Context) error {
+			return userClient.Close()
+		}}},
+		{Name: "NATS connection", Timeout: cfg.Shutdown.Stages.NATS, Funcs: []server.ShutdownFunc{func(_ context.Context) error {
+			return natsConn.Drain()
+		}}},
+		{Name: "tracer provider", Timeout: cfg.Shutdown.Stages.Telemetry, Funcs: []server.ShutdownFunc{tracerProvider.Shutdown}},
+	}
 
-	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
-		return fmt.Errorf("errgroup encountered an error: %w", err)
+	if err := bootstrap.Run(ctx, components, func() error { return server.Shutdown(stages, logger) }, logger); err != nil {
+		return fmt.Errorf("failed to run servers: %w", err)
 	}
 	return nil
 }
 
-// setupServers initializes the HTTP, pprof, and gRPC servers with the provided database pool, logger, and configuration.
-func setupServers(dbPool *pgxpool.Pool, productClient pb.ProductServiceClient, js jetstream.JetStream, logger *slog.Logger, cfg *config.Config) (*http.Server, *http.Server) {
-	deps := app.SetupDependencies(dbPool, productClient, js, logger)
-	httpServer := app.SetupHttpServer(deps, cfg)
-	pprofServer := &http.Server{
-		Addr: cfg.PProf.Addr,
+// newGrpcClient creates a gRPC client connection to a service with the resilience settings of the configuration.
+// The metrics interceptor goes first to record the call latency including retries and timeouts.
+func newGrpcClient(cfg *config.Config, clientCfg pconfig.GrpcClientConfig, metricsName string) (*grpc.ClientConn, error) {
+	creds, err := grpctls.ClientCredentials(clientCfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gRPC client TLS credentials of %s: %w", metricsName, err)
 	}
+	conn, err := grpc.NewClient(
+		clientCfg.Addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(
+			interceptors.NewMetricsInterceptor(otel.Meter("order-service"), metricsName, telemetry.NewOrgLabel(cfg.Telemetry.Metrics.OrgAllowlist)),
+			interceptors.NewRetryInterceptor(cfg.Resilience.Retry),
+			interceptors.NewCircuitBreaker(cfg.Resilience.CircuitBreaker),
+			interceptors.UnaryClientTimeoutInterceptor(clientCfg.Timeout),
+		),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client connection of %s: %w", metricsName, err)
+	}
+	return conn, nil
+}
+
+// shutdownFuncs returns the Shutdown functions of the components, in the same order.
+func shutdownFuncs(components []bootstrap.Component) []server.ShutdownFunc {
+	funcs := make([]server.ShutdownFunc, 0, len(components))
+	for _, c := range components {
+		funcs = append(funcs, c.Shutdown)
+	}
+	return funcs
+}
+
+// setupServers initializes the HTTP and pprof servers with the provided dependencies and configuration.
+func setupServers(deps *app.Dependencies, cfg *config.Config) (*http.Server, *http.Server) {
+	httpServer := app.SetupHttpServer(deps, cfg)
+	pprofServer := server.NewPProfServer(cfg.PProf)
 	return httpServer, pprofServer
 }
 
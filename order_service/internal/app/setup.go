@@ -4,51 +4,92 @@ package app
 import (
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/abgdnv/gocommerce/order_service/internal/config"
+	"github.com/abgdnv/gocommerce/order_service/internal/productcache"
 	"github.com/abgdnv/gocommerce/order_service/internal/service"
 	"github.com/abgdnv/gocommerce/order_service/internal/store"
 	"github.com/abgdnv/gocommerce/order_service/internal/transport/rest"
 	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/product/v1"
+	userpb "github.com/abgdnv/gocommerce/pkg/api/gen/go/user/v1"
+	"github.com/abgdnv/gocommerce/pkg/audit"
+	pconfig "github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/health"
 	"github.com/abgdnv/gocommerce/pkg/nats"
 	"github.com/abgdnv/gocommerce/pkg/server"
+	"github.com/abgdnv/gocommerce/pkg/telemetry"
+	"github.com/abgdnv/gocommerce/pkg/web"
 	"github.com/nats-io/nats.go/jetstream"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
 )
 
 type Dependencies struct {
 	OrderService service.OrderService
+	OrdersConfig config.OrdersConfig
+	Health       *health.Handler
 	Logger       *slog.Logger
 }
 
-func SetupDependencies(dbPool *pgxpool.Pool, productClient pb.ProductServiceClient, js jetstream.JetStream, logger *slog.Logger) *Dependencies {
+func SetupDependencies(dbPool *pgxpool.Pool, queryTimeout, slowQueryThreshold time.Duration, productConn, userConn *grpc.ClientConn, js jetstream.JetStream, ordersCfg config.OrdersConfig, productCacheCfg config.ProductCacheConfig, auditCfg pconfig.AuditConfig, logger *slog.Logger) *Dependencies {
 	publisher := nats.NewNatsPublisher(js)
-	pService := service.NewService(store.NewPgStore(dbPool), productClient, publisher)
+	var auditor audit.Recorder = audit.NoopRecorder{}
+	if auditCfg.Enabled {
+		auditor = audit.NewPublishingRecorder(publisher, logger)
+	}
+	productClient := pb.NewProductServiceClient(productConn)
+	if productCacheCfg.Enabled {
+		productClient = productcache.NewClient(productClient, productCacheCfg.TTL, productCacheCfg.MaxEntries)
+	}
+	pService := service.NewService(store.NewPgStore(dbPool, queryTimeout, telemetry.NewSlowQueryLogger(slowQueryThreshold, logger)), productClient, userpb.NewUserServiceClient(userConn), publisher, ordersCfg, auditor)
+	healthHandler := health.NewHandler(map[string]health.Check{
+		"database":        health.PgxPool(dbPool),
+		"product_service": health.GRPCConn(productConn),
+		"nats":            health.NATSConn(js.Conn()),
+	}, logger)
 
 	return &Dependencies{
 		OrderService: pService,
+		OrdersConfig: ordersCfg,
+		Health:       healthHandler,
 		Logger:       logger,
 	}
 }
 
 // SetupHttpHandler initializes the HTTP server and routes for the OrderService application.
 // Used by E2E tests to set up the HTTP server with the necessary routes and middleware.
-func SetupHttpHandler(deps *Dependencies) http.Handler {
-	mux := server.NewChiRouter(deps.Logger)
-	wireRoutes(mux, deps)
+// Trailing slashes are handled according to the trailingSlash mode of the HTTP server configuration.
+// The HTTP metrics of the organizations allowlisted by orgLabel are labelled with the organization.
+// The order routes are registered below basePath, e.g. /api/v1, the probes at the root.
+func SetupHttpHandler(deps *Dependencies, trailingSlash, basePath string, orgLabel *telemetry.OrgLabel) http.Handler {
+	mux := server.NewChiRouter(deps.Logger, trailingSlash)
+	mux.Use(orgLabel.Middleware)
+	wireRoutes(mux, deps, basePath)
 	return mux
 }
 
 // wireRoutes sets up the HTTP routes for the OrderService application.
-func wireRoutes(mux *chi.Mux, deps *Dependencies) {
-	orderHandler := rest.NewHandler(deps.OrderService, deps.Logger)
+func wireRoutes(mux *chi.Mux, deps *Dependencies, basePath string) {
+	orderHandler := rest.NewHandler(deps.OrderService, deps.OrdersConfig, basePath, deps.Logger)
 	orderHandler.RegisterRoutes(mux)
+	deps.Health.RegisterRoutes(mux)
 }
 
 // SetupHttpServer creates and configures an HTTP server for the OrderService application.
+// The requests are measured per route with web.MetricsMiddleware, including the 504 of the requests which timed out.
+// Requests of hosts not in the allowed hosts of the configuration are rejected before any other middleware.
 func SetupHttpServer(deps *Dependencies, cfg *config.Config) *http.Server {
-	mux := SetupHttpHandler(deps)
-	return server.NewHTTPServer(cfg.HTTPServer, mux)
+	handler := SetupHttpHandler(deps, cfg.HTTPServer.TrailingSlash, cfg.HTTPServer.BasePath, telemetry.NewOrgLabel(cfg.Telemetry.Metrics.OrgAllowlist))
+	if cfg.HTTPServer.Timeout.Handler > 0 {
+		handler = web.TimeoutMiddleware(cfg.HTTPServer.Timeout.Handler)(handler)
+	}
+	handler = web.MetricsMiddleware(otel.Meter("order-service"))(handler)
+	handler = web.MaxBodySizeMiddleware(cfg.HTTPServer.MaxBodyBytes)(handler)
+	handler = web.PageSizeMiddleware(cfg.HTTPServer.MaxPageSize, cfg.HTTPServer.PageSizeMode == pconfig.PageSizeReject, deps.Logger)(handler)
+	handler = web.AllowedHostsMiddleware(cfg.HTTPServer.AllowedHosts, deps.Logger)(handler)
+	return server.NewHTTPServer(cfg.HTTPServer, handler)
 }
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/abgdnv/gocommerce/order_service/internal/config"
+	"github.com/abgdnv/gocommerce/pkg/nats"
+	"github.com/nats-io/nats.go/jetstream"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// VerifyDependencies checks the dependencies of the order routes before the servers accept requests,
+// so the service fails to start with a clear error instead of serving orders which can't be created or published:
+// the Product service must report SERVING on the gRPC health check, and the order events stream must exist,
+// it's created first if configured. Both checks are retried until the startup timeout expires.
+func VerifyDependencies(ctx context.Context, productHealth healthpb.HealthClient, js jetstream.JetStream, cfg config.StartupConfig, streamCfg config.StreamConfig, logger *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	if err := waitForServing(ctx, productHealth, cfg.RetryInterval, logger); err != nil {
+		return fmt.Errorf("product service is not serving after %s: %w", cfg.Timeout, err)
+	}
+	logger.InfoContext(ctx, "Product service is serving")
+	if _, err := nats.EnsureStream(ctx, js, streamConfig(streamCfg), streamCfg.Create, streamCfg.RetryInterval, logger); err != nil {
+		return fmt.Errorf("order events stream %s is not available after %s: %w", streamCfg.Name, cfg.Timeout, err)
+	}
+	return nil
+}
+
+// waitForServing checks the health of the Product service every retryInterval until it reports SERVING.
+// Returns the context error together with the last failure if the context is done before,
+// a timed out check is reported only if no other check failed.
+func waitForServing(ctx context.Context, client healthpb.HealthClient, retryInterval time.Duration, logger *slog.Logger) error {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	var lastErr error
+	for {
+		err := checkServing(ctx, client)
+		if err == nil {
+			return nil
+		}
+		// the last check may be cut short by the startup timeout, an earlier failure tells more about the Product service
+		if lastErr == nil || status.Code(err) != codes.DeadlineExceeded {
+			lastErr = err
+		}
+		logger.WarnContext(ctx, "Product service is not serving yet", "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w, last check: %w", ctx.Err(), lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkServing returns nil if the health check of the whole server reports SERVING.
+func checkServing(ctx context.Context, client healthpb.HealthClient) error {
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("health status is %s", resp.GetStatus())
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/order_service/internal/config"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthClientMock fails the first failures checks, every check if failures is negative, and reports SERVING then.
+// A failed check returns err, or NOT_SERVING if err is nil.
+type healthClientMock struct {
+	healthpb.HealthClient
+	failures int
+	err      error
+	checks   int
+}
+
+func (m *healthClientMock) Check(_ context.Context, _ *healthpb.HealthCheckRequest, _ ...grpc.CallOption) (*healthpb.HealthCheckResponse, error) {
+	m.checks++
+	if m.failures < 0 || m.checks <= m.failures {
+		if m.err != nil {
+			return nil, m.err
+		}
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+// jetStreamMock looks up a stream which exists unless streamErr is set, a created stream exists from then on.
+type jetStreamMock struct {
+	jetstream.JetStream
+	streamErr error
+	lookups   int
+	created   *jetstream.StreamConfig
+}
+
+func (m *jetStreamMock) Stream(_ context.Context, _ string) (jetstream.Stream, error) {
+	m.lookups++
+	return nil, m.streamErr
+}
+
+func (m *jetStreamMock) CreateStream(_ context.Context, cfg jetstream.StreamConfig) (jetstream.Stream, error) {
+	m.created = &cfg
+	m.streamErr = nil
+	return nil, nil
+}
+
+var (
+	testStartupConfig = config.StartupConfig{Timeout: 300 * time.Millisecond, RetryInterval: 10 * time.Millisecond}
+	testStreamConfig  = config.StreamConfig{Name: "ORDERS", Subject: "orders.*", RetryInterval: 10 * time.Millisecond}
+)
+
+func TestVerifyDependencies(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	testCases := []struct {
+		name           string
+		health         *healthClientMock
+		js             *jetStreamMock
+		create         bool
+		expectedError  string
+		expectedChecks int
+		expectLookup   bool
+	}{
+		{
+			name:           "Success - dependencies are available",
+			health:         &healthClientMock{},
+			js:             &jetStreamMock{},
+			expectedChecks: 1,
+			expectLookup:   true,
+		},
+		{
+			name:           "Success - product service becomes serving",
+			health:         &healthClientMock{failures: 2, err: status.Error(codes.Unavailable, "connection refused")},
+			js:             &jetStreamMock{},
+			expectedChecks: 3,
+			expectLookup:   true,
+		},
+		{
+			name:         "Success - missing stream is created",
+			health:       &healthClientMock{},
+			js:           &jetStreamMock{streamErr: jetstream.ErrStreamNotFound},
+			create:       true,
+			expectLookup: true,
+		},
+		{
+			name:          "Error - product service is down",
+			health:        &healthClientMock{failures: -1, err: status.Error(codes.Unavailable, "connection refused")},
+			js:            &jetStreamMock{},
+			expectedError: "product service is not serving",
+		},
+		{
+			name:          "Error - product service is not serving",
+			health:        &healthClientMock{failures: -1},
+			js:            &jetStreamMock{},
+			expectedError: "health status is NOT_SERVING",
+		},
+		{
+			name:          "Error - stream doesn't exist",
+			health:        &healthClientMock{},
+			js:            &jetStreamMock{streamErr: jetstream.ErrStreamNotFound},
+			expectedError: "order events stream ORDERS is not available",
+			expectLookup:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			streamCfg := testStreamConfig
+			streamCfg.Create = tc.create
+
+			// when
+			err := VerifyDependencies(context.Background(), tc.health, tc.js, testStartupConfig, streamCfg, logger)
+
+			// then
+			if tc.expectedError != "" {
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+				assert.ErrorContains(t, err, tc.expectedError)
+				assert.Equal(t, tc.expectLookup, tc.js.lookups > 0, "the stream should be checked only once the product service is serving")
+				return
+			}
+			require.NoError(t, err)
+			if tc.expectedChecks > 0 {
+				assert.Equal(t, tc.expectedChecks, tc.health.checks)
+			}
+			assert.Equal(t, tc.expectLookup, tc.js.lookups > 0)
+			if tc.create {
+				require.NotNil(t, tc.js.created, "the missing stream should be created")
+				assert.Equal(t, streamConfig(streamCfg), *tc.js.created)
+			}
+		})
+	}
+}
+
+func TestVerifyDependencies_ProductServiceConnection(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	testCases := []struct {
+		name          string
+		serve         bool
+		status        healthpb.HealthCheckResponse_ServingStatus
+		expectedError string
+	}{
+		{name: "Success - product service is serving", serve: true, status: healthpb.HealthCheckResponse_SERVING},
+		{name: "Error - product service is not serving", serve: true, status: healthpb.HealthCheckResponse_NOT_SERVING, expectedError: "health status is NOT_SERVING"},
+		{name: "Error - nothing listens on the address", serve: false, expectedError: "product service is not serving"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			if tc.serve {
+				healthServer := grpchealth.NewServer()
+				healthServer.SetServingStatus("", tc.status)
+				server := grpc.NewServer()
+				healthpb.RegisterHealthServer(server, healthServer)
+				go func() { _ = server.Serve(lis) }()
+				t.Cleanup(server.Stop)
+			} else {
+				// the product service is down, its connections are refused
+				require.NoError(t, lis.Close())
+			}
+			conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = conn.Close() })
+			js := &jetStreamMock{}
+
+			// when
+			err = VerifyDependencies(context.Background(), healthpb.NewHealthClient(conn), js, testStartupConfig, testStreamConfig, logger)
+
+			// then
+			if tc.expectedError != "" {
+				require.Error(t, err, "the service should not start while the product service isn't serving")
+				assert.ErrorContains(t, err, tc.expectedError)
+				assert.Zero(t, js.lookups, "the stream should not be checked")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, js.lookups)
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package app
+
+import (
+	"github.com/abgdnv/gocommerce/order_service/internal/config"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// streamConfig returns the configuration of the stream created by the service.
+// It matches the ORDERS stream of the NATS stream setup job: order events are a work queue,
+// new events are rejected when the stream is full.
+func streamConfig(cfg config.StreamConfig) jetstream.StreamConfig {
+	return jetstream.StreamConfig{
+		Name:      cfg.Name,
+		Subjects:  []string{cfg.Subject},
+		Retention: jetstream.WorkQueuePolicy,
+		Storage:   jetstream.FileStorage,
+		Discard:   jetstream.DiscardNew,
+	}
+}
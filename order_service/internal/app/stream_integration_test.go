@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/order_service/internal/config"
+	pconfig "github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/nats"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const skipIntegrationTests = "ORDER_SVC_SKIP_INTEGRATION_TESTS"
+
+func TestVerifyDependencies_Stream(t *testing.T) {
+	// Skip integration tests if the environment variable is set
+	if os.Getenv(skipIntegrationTests) == "1" {
+		t.Skip("Skipping integration tests based on " + skipIntegrationTests + " env var")
+	}
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Start a NATS container with JetStream enabled
+	natsContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "nats:2.11.6-alpine",
+			Cmd:          []string{"--jetstream"},
+			ExposedPorts: []string{"4222/tcp"},
+			WaitingFor:   wait.ForLog("Server is ready"),
+		},
+		Started: true,
+	})
+	require.NoError(t, err, "Failed to run NATS container")
+	t.Cleanup(func() { _ = testcontainers.TerminateContainer(natsContainer) })
+
+	natsURL, err := natsContainer.PortEndpoint(ctx, "4222/tcp", "nats")
+	require.NoError(t, err, "Failed to get NATS URL")
+	nc, err := nats.NewClient(ctx, pconfig.NATSConfig{Url: natsURL, Timeout: 5 * time.Second}, logger)
+	require.NoError(t, err, "Failed to connect to NATS")
+	t.Cleanup(nc.Close)
+	js, err := nats.NewJetStreamContext(nc)
+	require.NoError(t, err, "Failed to get JetStream context")
+
+	testCases := []struct {
+		name   string
+		create bool
+	}{
+		{name: "stream is created by the stream setup job", create: false},
+		{name: "stream is created by the service", create: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			cfg := config.StreamConfig{
+				Name:          "ORDERS-" + uuid.NewString(),
+				Subject:       "orders-" + uuid.NewString() + ".*",
+				Create:        tc.create,
+				RetryInterval: 50 * time.Millisecond,
+			}
+			startup := config.StartupConfig{Timeout: 10 * time.Second, RetryInterval: 50 * time.Millisecond}
+
+			// when
+			done := make(chan error, 1)
+			go func() {
+				done <- VerifyDependencies(ctx, &healthClientMock{}, js, startup, cfg, logger)
+			}()
+
+			// then
+			if !tc.create {
+				select {
+				case err := <-done:
+					t.Fatalf("the dependencies should not be verified while the stream doesn't exist, got %v", err)
+				case <-time.After(500 * time.Millisecond):
+				}
+				_, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: cfg.Name, Subjects: []string{cfg.Subject}})
+				require.NoError(t, err, "Failed to create the stream")
+			}
+			select {
+			case err := <-done:
+				require.NoError(t, err, "the dependencies should be verified once the stream exists")
+			case <-time.After(5 * time.Second):
+				t.Fatal("the dependencies were not verified once the stream exists")
+			}
+			_, err := js.Stream(ctx, cfg.Name)
+			assert.NoError(t, err, "the stream should exist")
+		})
+	}
+
+	t.Run("stream is never created", func(t *testing.T) {
+		// given
+		cfg := config.StreamConfig{Name: "ORDERS-" + uuid.NewString(), Subject: "orders-" + uuid.NewString() + ".*", RetryInterval: 50 * time.Millisecond}
+		startup := config.StartupConfig{Timeout: 300 * time.Millisecond, RetryInterval: 50 * time.Millisecond}
+
+		// when
+		err := VerifyDependencies(ctx, &healthClientMock{}, js, startup, cfg, logger)
+
+		// then
+		require.ErrorIs(t, err, context.DeadlineExceeded, "the service should fail to start without the stream")
+		assert.ErrorContains(t, err, cfg.Name)
+	})
+}
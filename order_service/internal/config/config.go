@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/abgdnv/gocommerce/pkg/config/configloader"
@@ -18,20 +21,239 @@ type Config struct {
 	Telemetry  config.TelemetryConfig  `koanf:"telemetry"`
 	Resilience config.ResilienceConfig `koanf:"resilience"`
 	Shutdown   config.ShutdownConfig   `koanf:"shutdown"`
+	Orders     OrdersConfig            `koanf:"orders"`
+	Audit      config.AuditConfig      `koanf:"audit"`
+	Stream     StreamConfig            `koanf:"stream"`
+	Startup    StartupConfig           `koanf:"startup"`
 	Services   struct {
 		Product struct {
-			Grpc config.GrpcClientConfig `koanf:"grpc"`
+			Grpc  config.GrpcClientConfig `koanf:"grpc"`
+			Cache ProductCacheConfig      `koanf:"cache"`
 		} `koanf:"product"`
+		User struct {
+			Grpc config.GrpcClientConfig `koanf:"grpc"`
+		} `koanf:"user"`
 	} `koanf:"services"`
 }
 
+// DefaultMaxItemQuantity is the maximum quantity of a single order item if OrdersConfig.MaxItemQuantity is not set.
+const DefaultMaxItemQuantity = 100
+
+// DefaultCreateStatuses are the statuses an order can be created in if OrdersConfig.CreateStatuses is not set.
+var DefaultCreateStatuses = []string{"PENDING"}
+
+// OrdersConfig holds the business rules for order processing.
+type OrdersConfig struct {
+	// RequireVerifiedEmail rejects order creation for users whose email is not verified.
+	RequireVerifiedEmail bool `koanf:"requireverifiedemail"`
+	// RestockETA includes the expected restock time of products in insufficient stock errors.
+	RestockETA bool `koanf:"restocketa"`
+	// MaxItems limits the number of items in a single order, zero disables the limit.
+	MaxItems int `koanf:"maxitems"`
+	// MaxItemQuantity limits the quantity of every single order item, DefaultMaxItemQuantity if not set.
+	MaxItemQuantity int32 `koanf:"maxitemquantity"`
+	// MaxJSONDepth limits the nesting depth of JSON request bodies, zero disables the limit.
+	MaxJSONDepth int `koanf:"maxjsondepth"`
+	// StrictJSON rejects request bodies with fields the request doesn't have, e.g. misspelled ones, instead of ignoring them.
+	StrictJSON bool `koanf:"strictjson"`
+	// UnprocessableEntity responds with 422 instead of 400 to well-formed requests which can't be processed,
+	// e.g. ordering more than the available stock.
+	UnprocessableEntity bool `koanf:"unprocessableentity"`
+	// LocationHeader sets the Location header to the URL of the created order.
+	LocationHeader bool `koanf:"locationheader"`
+	// ConflictDetails includes the current version and status of a concurrently modified order in the 409 response.
+	ConflictDetails bool `koanf:"conflictdetails"`
+	// TaxRate is the estimated tax rate in basis points (1/100 of a percent) added to the authorization amount.
+	TaxRate int64 `koanf:"taxrate"`
+	// ShippingFee is the estimated shipping fee added to the authorization amount.
+	ShippingFee int64 `koanf:"shippingfee"`
+	// FreeShippingFrom is the order total from which shipping is free, zero disables free shipping.
+	FreeShippingFrom int64 `koanf:"freeshippingfrom"`
+	// ReserveStock takes the stock of the items from the Product service when an order is created,
+	// and releases it again if the order can't be stored.
+	ReserveStock bool `koanf:"reservestock"`
+	// CreateStatuses are the statuses an order can be created in, compared case-insensitively.
+	// Defaults to DefaultCreateStatuses when empty, test and staging environments may allow more.
+	CreateStatuses []string `koanf:"createstatuses"`
+}
+
+// AllowedCreateStatuses returns the statuses an order can be created in, or DefaultCreateStatuses if none are configured.
+func (c *OrdersConfig) AllowedCreateStatuses() []string {
+	if len(c.CreateStatuses) == 0 {
+		return DefaultCreateStatuses
+	}
+	return c.CreateStatuses
+}
+
+// String returns a string representation of the OrdersConfig.
+func (c *OrdersConfig) String() string {
+	var b strings.Builder
+	b.WriteString("\n--- Orders ---\n")
+	b.WriteString(fmt.Sprintf("  requireverifiedemail: %t\n", c.RequireVerifiedEmail))
+	b.WriteString(fmt.Sprintf("  restocketa: %t\n", c.RestockETA))
+	b.WriteString(fmt.Sprintf("  maxitems: %d\n", c.MaxItems))
+	b.WriteString(fmt.Sprintf("  maxitemquantity: %d\n", c.MaxItemQuantity))
+	b.WriteString(fmt.Sprintf("  maxjsondepth: %d\n", c.MaxJSONDepth))
+	b.WriteString(fmt.Sprintf("  strictjson: %t\n", c.StrictJSON))
+	b.WriteString(fmt.Sprintf("  unprocessableentity: %t\n", c.UnprocessableEntity))
+	b.WriteString(fmt.Sprintf("  locationheader: %t\n", c.LocationHeader))
+	b.WriteString(fmt.Sprintf("  conflictdetails: %t\n", c.ConflictDetails))
+	b.WriteString(fmt.Sprintf("  taxrate: %d\n", c.TaxRate))
+	b.WriteString(fmt.Sprintf("  shippingfee: %d\n", c.ShippingFee))
+	b.WriteString(fmt.Sprintf("  freeshippingfrom: %d\n", c.FreeShippingFrom))
+	b.WriteString(fmt.Sprintf("  reservestock: %t\n", c.ReserveStock))
+	b.WriteString(fmt.Sprintf("  createstatuses: %v\n", c.AllowedCreateStatuses()))
+	return b.String()
+}
+
+func (c *OrdersConfig) Validate() error {
+	if c.MaxItems < 0 {
+		return fmt.Errorf("OrdersConfig: maxitems must not be negative")
+	}
+	if c.MaxItemQuantity < 0 {
+		return fmt.Errorf("OrdersConfig: maxitemquantity must not be negative")
+	}
+	if c.MaxItemQuantity == 0 {
+		log.Println("Using default value for OrdersConfig.MaxItemQuantity:", DefaultMaxItemQuantity)
+		c.MaxItemQuantity = DefaultMaxItemQuantity
+	}
+	if c.MaxJSONDepth < 0 {
+		return fmt.Errorf("OrdersConfig: maxjsondepth must not be negative")
+	}
+	if c.TaxRate < 0 || c.TaxRate > 10000 {
+		return fmt.Errorf("OrdersConfig: taxrate must be between 0 and 10000 basis points")
+	}
+	if c.ShippingFee < 0 {
+		return fmt.Errorf("OrdersConfig: shippingfee must not be negative")
+	}
+	if c.FreeShippingFrom < 0 {
+		return fmt.Errorf("OrdersConfig: freeshippingfrom must not be negative")
+	}
+	for _, status := range c.CreateStatuses {
+		if strings.TrimSpace(status) == "" {
+			return fmt.Errorf("OrdersConfig: createstatuses must not contain an empty status")
+		}
+	}
+	return nil
+}
+
+// StreamConfig holds the settings of the JetStream stream the order events are published to.
+// The service doesn't start until the stream exists, so no orders are accepted before their events can be published.
+type StreamConfig struct {
+	// Name is the name of the stream.
+	Name string `koanf:"name"`
+	// Subject is the subject of the order events, used when the stream is created.
+	Subject string `koanf:"subject"`
+	// Create creates the stream if it doesn't exist. Otherwise the service waits until the stream is created elsewhere,
+	// e.g. by the NATS stream setup job, for up to StartupConfig.Timeout.
+	Create bool `koanf:"create"`
+	// RetryInterval is the time between the checks of the stream while it doesn't exist.
+	RetryInterval time.Duration `koanf:"retryinterval"`
+}
+
+// String returns a string representation of the StreamConfig.
+func (c *StreamConfig) String() string {
+	var b strings.Builder
+	b.WriteString("\n--- Stream ---\n")
+	b.WriteString(fmt.Sprintf("  name: %s\n", c.Name))
+	b.WriteString(fmt.Sprintf("  subject: %s\n", c.Subject))
+	b.WriteString(fmt.Sprintf("  create: %t\n", c.Create))
+	b.WriteString(fmt.Sprintf("  retryinterval: %s\n", c.RetryInterval))
+	return b.String()
+}
+
+func (c *StreamConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("StreamConfig: name is not configured")
+	}
+	if c.Create && c.Subject == "" {
+		return fmt.Errorf("StreamConfig: subject is required to create the stream")
+	}
+	if c.RetryInterval <= 0 {
+		return fmt.Errorf("StreamConfig: retryinterval must be greater than zero")
+	}
+	return nil
+}
+
+// StartupConfig holds the settings of the dependency checks before the service accepts requests:
+// the Product service must report SERVING on the gRPC health check and the order events stream must exist.
+type StartupConfig struct {
+	// Timeout limits the time to wait for the dependencies, the service fails to start once it expires.
+	Timeout time.Duration `koanf:"timeout"`
+	// RetryInterval is the time between the health checks of the Product service while it isn't serving.
+	RetryInterval time.Duration `koanf:"retryinterval"`
+}
+
+// String returns a string representation of the StartupConfig.
+func (c *StartupConfig) String() string {
+	var b strings.Builder
+	b.WriteString("\n--- Startup ---\n")
+	b.WriteString(fmt.Sprintf("  timeout: %s\n", c.Timeout))
+	b.WriteString(fmt.Sprintf("  retryinterval: %s\n", c.RetryInterval))
+	return b.String()
+}
+
+func (c *StartupConfig) Validate() error {
+	if c.Timeout <= 0 {
+		return fmt.Errorf("StartupConfig: timeout must be greater than zero")
+	}
+	if c.RetryInterval <= 0 {
+		return fmt.Errorf("StartupConfig: retryinterval must be greater than zero")
+	}
+	return nil
+}
+
+// DefaultProductCacheMaxEntries is the maximum number of cached products if ProductCacheConfig.MaxEntries is not set.
+const DefaultProductCacheMaxEntries = 10000
+
+// ProductCacheConfig holds the settings of the product cache used by order processing.
+// The cache doesn't hold the stock of the products, so it requires OrdersConfig.ReserveStock to check the stock.
+type ProductCacheConfig struct {
+	// Enabled caches products fetched from the Product service.
+	Enabled bool `koanf:"enabled"`
+	// TTL is how long a cached product is used before it is fetched again.
+	TTL time.Duration `koanf:"ttl"`
+	// MaxEntries limits the number of cached products, DefaultProductCacheMaxEntries if not set.
+	MaxEntries int `koanf:"maxentries"`
+}
+
+// String returns a string representation of the ProductCacheConfig.
+func (c *ProductCacheConfig) String() string {
+	var b strings.Builder
+	b.WriteString("\n--- Product Cache ---\n")
+	b.WriteString(fmt.Sprintf("  enabled: %t\n", c.Enabled))
+	b.WriteString(fmt.Sprintf("  ttl: %s\n", c.TTL))
+	b.WriteString(fmt.Sprintf("  maxentries: %d\n", c.MaxEntries))
+	return b.String()
+}
+
+func (c *ProductCacheConfig) Validate() error {
+	if c.Enabled && c.TTL <= 0 {
+		return fmt.Errorf("ProductCacheConfig: ttl must be greater than zero")
+	}
+	if c.MaxEntries < 0 {
+		return fmt.Errorf("ProductCacheConfig: maxentries must not be negative")
+	}
+	if c.MaxEntries == 0 {
+		log.Println("Using default value for ProductCacheConfig.MaxEntries:", DefaultProductCacheMaxEntries)
+		c.MaxEntries = DefaultProductCacheMaxEntries
+	}
+	return nil
+}
+
 func (c *Config) String() string {
 
 	var b strings.Builder
 	b.WriteString(c.HTTPServer.String())
 	b.WriteString(c.Database.String())
 	b.WriteString(c.Services.Product.Grpc.String())
+	b.WriteString(c.Services.Product.Cache.String())
+	b.WriteString(c.Services.User.Grpc.String())
 	b.WriteString(c.Nats.String())
+	b.WriteString(c.Stream.String())
+	b.WriteString(c.Startup.String())
+	b.WriteString(c.Orders.String())
+	b.WriteString(c.Audit.String())
 	b.WriteString(c.Telemetry.String())
 	b.WriteString(c.Resilience.String())
 	b.WriteString(c.Log.String())
@@ -58,6 +280,12 @@ func (c *Config) Validate() error {
 	if err := c.Nats.Validate(); err != nil {
 		return err
 	}
+	if err := c.Stream.Validate(); err != nil {
+		return err
+	}
+	if err := c.Startup.Validate(); err != nil {
+		return err
+	}
 	if err := c.Telemetry.Validate(); err != nil {
 		return err
 	}
@@ -70,6 +298,18 @@ func (c *Config) Validate() error {
 	if err := c.Services.Product.Grpc.Validate(); err != nil {
 		return err
 	}
+	if err := c.Services.Product.Cache.Validate(); err != nil {
+		return err
+	}
+	if err := c.Services.User.Grpc.Validate(); err != nil {
+		return fmt.Errorf("services.user: %w", err)
+	}
+	if err := c.Orders.Validate(); err != nil {
+		return err
+	}
+	if c.Services.Product.Cache.Enabled && !c.Orders.ReserveStock {
+		return fmt.Errorf("ProductCacheConfig: the cache doesn't hold the stock, it requires orders.reservestock")
+	}
 
 	return nil
 }
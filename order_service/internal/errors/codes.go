@@ -0,0 +1,50 @@
+package errors
+
+import "errors"
+
+// Codes of the order errors reported to clients, so they can switch on them instead of the message.
+const (
+	CodeOrderNotFound      = "ORDER_NOT_FOUND"
+	CodeOrderNotPending    = "ORDER_NOT_PENDING"
+	CodeInvalidStatus      = "INVALID_STATUS"
+	CodeOptimisticLock     = "OPTIMISTIC_LOCK"
+	CodeAccessDenied       = "ACCESS_DENIED"
+	CodeEmailNotVerified   = "EMAIL_NOT_VERIFIED"
+	CodeInsufficientStock  = "INSUFFICIENT_STOCK"
+	CodeQueryTimeout       = "QUERY_TIMEOUT"
+	CodeInvalidTimeRange   = "INVALID_TIME_RANGE"
+	CodeQuantityExceedsMax = "QUANTITY_EXCEEDS_MAX"
+	CodeMixedCurrencies    = "MIXED_CURRENCIES"
+	CodeProductNotFound    = "PRODUCT_NOT_FOUND"
+	CodeUserNotFound       = "USER_NOT_FOUND"
+)
+
+// codes maps the sentinel errors to their codes.
+var codes = []struct {
+	err  error
+	code string
+}{
+	{ErrOrderNotFound, CodeOrderNotFound},
+	{ErrOrderNotPending, CodeOrderNotPending},
+	{ErrInvalidStatus, CodeInvalidStatus},
+	{ErrOptimisticLock, CodeOptimisticLock},
+	{ErrAccessDenied, CodeAccessDenied},
+	{ErrEmailNotVerified, CodeEmailNotVerified},
+	{ErrInsufficientStock, CodeInsufficientStock},
+	{ErrQueryTimeout, CodeQueryTimeout},
+	{ErrInvalidTimeRange, CodeInvalidTimeRange},
+	{ErrQuantityExceedsMax, CodeQuantityExceedsMax},
+	{ErrMixedCurrencies, CodeMixedCurrencies},
+	{ErrProductNotFound, CodeProductNotFound},
+	{ErrUserNotFound, CodeUserNotFound},
+}
+
+// Code returns the code of the sentinel error err wraps, or an empty string if err doesn't wrap any of them.
+func Code(err error) string {
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ""
+}
@@ -1,17 +1,29 @@
 // Package errors provides custom error types for order-related operations.
 package errors
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
 
 var ErrCreateOrder = errors.New("failed to create order")
 var ErrCreateOrderItem = errors.New("failed to create order item")
 
 var ErrUpdateOrder = errors.New("failed to update order")
+var ErrUpdateOrderItems = errors.New("failed to update order items")
+var ErrOrderNotPending = errors.New("order is not pending")
+var ErrInvalidStatus = errors.New("order status is not allowed at creation")
+var ErrDeleteOrder = errors.New("failed to delete order")
 var ErrOptimisticLock = errors.New("optimistic lock error: the record has been modified by another transaction")
 
 var ErrOrderNotFound = errors.New("order not found")
 var ErrFailedToFindOrder = errors.New("failed to find order")
 var ErrFailedToFindUserOrders = errors.New("failed to find user orders")
+var ErrFailedToFindOrders = errors.New("failed to find orders")
+var ErrFailedToSummarizeOrders = errors.New("failed to summarize orders")
+var ErrInvalidTimeRange = errors.New("invalid time range")
 
 var ErrFailedToFindOrderItems = errors.New("failed to find order items")
 
@@ -19,6 +31,76 @@ var ErrTransactionBegin = errors.New("failed to begin transaction")
 var ErrTransactionCommit = errors.New("failed to commit transaction")
 var ErrTransactionRollback = errors.New("failed to rollback transaction")
 
+var ErrQueryTimeout = errors.New("database query timed out")
+
 var ErrAccessDenied = errors.New("access denied")
+var ErrEmailNotVerified = errors.New("email is not verified")
 
 var ErrInsufficientStock = errors.New("insufficient stock for product")
+var ErrQuantityExceedsMax = errors.New("quantity exceeds the maximum per item")
+var ErrMixedCurrencies = errors.New("order items are priced in different currencies")
+var ErrProductNotFound = errors.New("product not found")
+var ErrUserNotFound = errors.New("user not found")
+
+// OptimisticLockError describes the current state of an order modified concurrently,
+// so the client can reconcile its changes and retry with the current version.
+// It wraps ErrOptimisticLock, so it can be checked with errors.Is.
+type OptimisticLockError struct {
+	CurrentVersion int32
+	CurrentStatus  string
+}
+
+func (e *OptimisticLockError) Error() string {
+	return fmt.Sprintf("%s: current version %d, current status %s", ErrOptimisticLock, e.CurrentVersion, e.CurrentStatus)
+}
+
+func (e *OptimisticLockError) Unwrap() error {
+	return ErrOptimisticLock
+}
+
+// InsufficientStockItem describes an order item that can't be fulfilled.
+// RestockETA is the expected restock time of the product, nil if unknown.
+// Deleted is set if the product is deleted, so nothing is available.
+type InsufficientStockItem struct {
+	ProductID  string     `json:"product_id"`
+	Available  int32      `json:"available"`
+	Requested  int32      `json:"requested"`
+	RestockETA *time.Time `json:"restock_eta,omitempty"`
+	Deleted    bool       `json:"deleted,omitempty"`
+}
+
+// InsufficientStockError lists all order items with insufficient stock.
+// It wraps ErrInsufficientStock, so it can be checked with errors.Is.
+type InsufficientStockError struct {
+	Items []InsufficientStockItem
+}
+
+func (e *InsufficientStockError) Error() string {
+	details := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		if item.Deleted {
+			details = append(details, fmt.Sprintf("product %s is deleted", item.ProductID))
+			continue
+		}
+		details = append(details, fmt.Sprintf("product %s. Available: %d, Requested: %d", item.ProductID, item.Available, item.Requested))
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(details, "; "), ErrInsufficientStock)
+}
+
+func (e *InsufficientStockError) Unwrap() error {
+	return ErrInsufficientStock
+}
+
+// ProductNotFoundError lists the products of the order items which the Product service doesn't know.
+// It wraps ErrProductNotFound, so it can be checked with errors.Is.
+type ProductNotFoundError struct {
+	ProductIDs []string
+}
+
+func (e *ProductNotFoundError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrProductNotFound, strings.Join(e.ProductIDs, ", "))
+}
+
+func (e *ProductNotFoundError) Unwrap() error {
+	return ErrProductNotFound
+}
@@ -0,0 +1,144 @@
+// Package productcache provides a caching client for the Product service.
+package productcache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/product/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ pb.ProductServiceClient = (*Client)(nil)
+
+type entry struct {
+	product   *pb.Product
+	expiresAt time.Time
+}
+
+// Client is a pb.ProductServiceClient which caches products for a fixed TTL.
+// Cached products are served from memory and only the missing ones are fetched from the Product service.
+// Only the rarely changing fields of the products are cached, e.g. the price, the stock changes with every order:
+// the returned products have no stock quantity and no restock time, see ReportsStock.
+// At most maxEntries products are cached, the expired ones are dropped whenever products are fetched.
+type Client struct {
+	next       pb.ProductServiceClient
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	mu         sync.RWMutex
+	entries    map[string]entry
+}
+
+// NewClient creates a new Client which caches at most maxEntries of the products returned by next for the given TTL.
+func NewClient(next pb.ProductServiceClient, ttl time.Duration, maxEntries int) *Client {
+	return &Client{
+		next:       next,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]entry),
+	}
+}
+
+// ReportsStock reports false, since the returned products have no stock quantity.
+// The stock of the products is checked when it's reserved instead.
+func (c *Client) ReportsStock() bool {
+	return false
+}
+
+// GetProduct returns the requested products, fetching only the ones missing from the cache.
+// Products unknown to the Product service are not cached and are absent from the response.
+func (c *Client) GetProduct(ctx context.Context, in *pb.GetProductRequest, opts ...grpc.CallOption) (*pb.GetProductResponse, error) {
+	ids := in.GetProducts()
+	products := make([]*pb.Product, 0, len(ids))
+	var missing []string
+
+	now := c.now()
+	c.mu.RLock()
+	for _, id := range ids {
+		if e, ok := c.entries[id]; ok && now.Before(e.expiresAt) {
+			products = append(products, e.product)
+			continue
+		}
+		missing = append(missing, id)
+	}
+	c.mu.RUnlock()
+
+	if len(missing) == 0 {
+		return &pb.GetProductResponse{Products: products}, nil
+	}
+
+	resp, err := c.next.GetProduct(ctx, &pb.GetProductRequest{Products: missing}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	fetched := make([]*pb.Product, 0, len(resp.GetProducts()))
+	for _, product := range resp.GetProducts() {
+		fetched = append(fetched, withoutStock(product))
+	}
+	c.store(fetched)
+	return &pb.GetProductResponse{Products: append(products, fetched...)}, nil
+}
+
+// GetProductById returns the requested product from the cache, or fetches and caches it if it's missing.
+// A product unknown to the Product service is not cached, the NotFound status is returned as is.
+func (c *Client) GetProductById(ctx context.Context, in *pb.GetProductByIdRequest, opts ...grpc.CallOption) (*pb.GetProductByIdResponse, error) {
+	id := in.GetId()
+	c.mu.RLock()
+	e, ok := c.entries[id]
+	c.mu.RUnlock()
+	if ok && c.now().Before(e.expiresAt) {
+		return &pb.GetProductByIdResponse{Product: e.product}, nil
+	}
+
+	resp, err := c.next.GetProductById(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	product := withoutStock(resp.GetProduct())
+	c.store([]*pb.Product{product})
+	return &pb.GetProductByIdResponse{Product: product}, nil
+}
+
+// ReserveStock reserves the stock with the Product service, the cache doesn't hold the stock.
+func (c *Client) ReserveStock(ctx context.Context, in *pb.ReserveStockRequest, opts ...grpc.CallOption) (*pb.ReserveStockResponse, error) {
+	return c.next.ReserveStock(ctx, in, opts...)
+}
+
+// ReleaseStock releases the stock with the Product service, the cache doesn't hold the stock.
+func (c *Client) ReleaseStock(ctx context.Context, in *pb.ReleaseStockRequest, opts ...grpc.CallOption) (*pb.ReleaseStockResponse, error) {
+	return c.next.ReleaseStock(ctx, in, opts...)
+}
+
+// store caches the fetched products after dropping the expired entries,
+// so products which are no longer requested don't stay in memory. Products beyond maxEntries are not cached.
+func (c *Client) store(products []*pb.Product) {
+	now := c.now()
+	expiresAt := now.Add(c.ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, id)
+		}
+	}
+	for _, product := range products {
+		if _, ok := c.entries[product.GetId()]; !ok && len(c.entries) >= c.maxEntries {
+			continue
+		}
+		c.entries[product.GetId()] = entry{product: product, expiresAt: expiresAt}
+	}
+}
+
+// withoutStock returns a copy of the product without its stock quantity and restock time.
+func withoutStock(product *pb.Product) *pb.Product {
+	cached := proto.Clone(product).(*pb.Product)
+	cached.StockQuantity = 0
+	cached.RestockAt = ""
+	return cached
+}
@@ -0,0 +1,232 @@
+package productcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/product/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProductServiceClientMock returns the requested products from a catalogue and records the requested IDs.
+type ProductServiceClientMock struct {
+	catalogue map[string]*pb.Product
+	error     error
+	requests  [][]string
+}
+
+func (p *ProductServiceClientMock) GetProduct(_ context.Context, in *pb.GetProductRequest, _ ...grpc.CallOption) (*pb.GetProductResponse, error) {
+	p.requests = append(p.requests, in.GetProducts())
+	if p.error != nil {
+		return nil, p.error
+	}
+	resp := &pb.GetProductResponse{}
+	for _, id := range in.GetProducts() {
+		if product, ok := p.catalogue[id]; ok {
+			resp.Products = append(resp.Products, product)
+		}
+	}
+	return resp, nil
+}
+
+func (p *ProductServiceClientMock) GetProductById(_ context.Context, in *pb.GetProductByIdRequest, _ ...grpc.CallOption) (*pb.GetProductByIdResponse, error) {
+	p.requests = append(p.requests, []string{in.GetId()})
+	if p.error != nil {
+		return nil, p.error
+	}
+	product, ok := p.catalogue[in.GetId()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "product with ID %s is not found", in.GetId())
+	}
+	return &pb.GetProductByIdResponse{Product: product}, nil
+}
+
+func (p *ProductServiceClientMock) ReserveStock(_ context.Context, _ *pb.ReserveStockRequest, _ ...grpc.CallOption) (*pb.ReserveStockResponse, error) {
+	return &pb.ReserveStockResponse{}, p.error
+}
+
+func (p *ProductServiceClientMock) ReleaseStock(_ context.Context, _ *pb.ReleaseStockRequest, _ ...grpc.CallOption) (*pb.ReleaseStockResponse, error) {
+	return &pb.ReleaseStockResponse{}, p.error
+}
+
+func productIDs(products []*pb.Product) []string {
+	ids := make([]string, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.GetId())
+	}
+	return ids
+}
+
+func TestClient_GetProduct(t *testing.T) {
+	catalogue := map[string]*pb.Product{
+		"p1": {Id: "p1", Price: 100, StockQuantity: 10},
+		"p2": {Id: "p2", Price: 200, StockQuantity: 20},
+		"p3": {Id: "p3", Price: 300, StockQuantity: 30},
+	}
+	testCases := []struct {
+		name             string
+		cached           []string
+		elapsed          time.Duration
+		requested        []string
+		expectedRequests [][]string
+		expectedIDs      []string
+	}{
+		{
+			name:             "all products missing",
+			requested:        []string{"p1", "p2"},
+			expectedRequests: [][]string{{"p1", "p2"}},
+			expectedIDs:      []string{"p1", "p2"},
+		},
+		{
+			name:             "all products cached",
+			cached:           []string{"p1", "p2"},
+			requested:        []string{"p1", "p2"},
+			expectedRequests: nil,
+			expectedIDs:      []string{"p1", "p2"},
+		},
+		{
+			name:             "mixed hit and miss fetches only missing products",
+			cached:           []string{"p1", "p3"},
+			requested:        []string{"p1", "p2", "p3"},
+			expectedRequests: [][]string{{"p2"}},
+			expectedIDs:      []string{"p1", "p3", "p2"},
+		},
+		{
+			name:             "expired products are fetched again",
+			cached:           []string{"p1"},
+			elapsed:          2 * time.Minute,
+			requested:        []string{"p1", "p2"},
+			expectedRequests: [][]string{{"p1", "p2"}},
+			expectedIDs:      []string{"p1", "p2"},
+		},
+		{
+			name:             "unknown products are not cached",
+			cached:           []string{"unknown"},
+			requested:        []string{"unknown", "p1"},
+			expectedRequests: [][]string{{"unknown", "p1"}},
+			expectedIDs:      []string{"p1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mock := &ProductServiceClientMock{catalogue: catalogue}
+			client := NewClient(mock, time.Minute, 100)
+			now := time.Now()
+			client.now = func() time.Time { return now }
+			if len(tc.cached) > 0 {
+				_, err := client.GetProduct(context.Background(), &pb.GetProductRequest{Products: tc.cached})
+				require.NoError(t, err)
+				mock.requests = nil
+			}
+			now = now.Add(tc.elapsed)
+			// when
+			resp, err := client.GetProduct(context.Background(), &pb.GetProductRequest{Products: tc.requested})
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRequests, mock.requests)
+			assert.Equal(t, tc.expectedIDs, productIDs(resp.GetProducts()))
+		})
+	}
+}
+
+func TestClient_GetProduct_Error(t *testing.T) {
+	// given
+	errUnavailable := errors.New("product service unavailable")
+	mock := &ProductServiceClientMock{catalogue: map[string]*pb.Product{"p1": {Id: "p1"}}}
+	client := NewClient(mock, time.Minute, 100)
+	_, err := client.GetProduct(context.Background(), &pb.GetProductRequest{Products: []string{"p1"}})
+	require.NoError(t, err)
+	mock.error = errUnavailable
+	// when
+	resp, err := client.GetProduct(context.Background(), &pb.GetProductRequest{Products: []string{"p1", "p2"}})
+	// then
+	assert.ErrorIs(t, err, errUnavailable)
+	assert.Nil(t, resp)
+	assert.Equal(t, [][]string{{"p1"}, {"p2"}}, mock.requests)
+}
+
+func TestClient_GetProductById(t *testing.T) {
+	// given
+	mock := &ProductServiceClientMock{catalogue: map[string]*pb.Product{"p1": {Id: "p1"}, "p2": {Id: "p2"}}}
+	client := NewClient(mock, time.Minute, 100)
+	_, err := client.GetProduct(context.Background(), &pb.GetProductRequest{Products: []string{"p1"}})
+	require.NoError(t, err)
+	mock.requests = nil
+	// when
+	cached, err := client.GetProductById(context.Background(), &pb.GetProductByIdRequest{Id: "p1"})
+	require.NoError(t, err)
+	fetched, err := client.GetProductById(context.Background(), &pb.GetProductByIdRequest{Id: "p2"})
+	require.NoError(t, err)
+	refetched, err := client.GetProductById(context.Background(), &pb.GetProductByIdRequest{Id: "p2"})
+	require.NoError(t, err)
+	_, unknownErr := client.GetProductById(context.Background(), &pb.GetProductByIdRequest{Id: "unknown"})
+	// then
+	assert.Equal(t, "p1", cached.GetProduct().GetId())
+	assert.Equal(t, "p2", fetched.GetProduct().GetId())
+	assert.Equal(t, "p2", refetched.GetProduct().GetId())
+	assert.Equal(t, codes.NotFound, status.Code(unknownErr))
+	assert.Equal(t, [][]string{{"p2"}, {"unknown"}}, mock.requests)
+}
+
+func TestClient_StockIsNotCached(t *testing.T) {
+	// given
+	mock := &ProductServiceClientMock{catalogue: map[string]*pb.Product{
+		"p1": {Id: "p1", Price: 100, StockQuantity: 10, RestockAt: "2025-08-01T00:00:00Z"},
+	}}
+	client := NewClient(mock, time.Minute, 100)
+	// when
+	fetched, err := client.GetProduct(context.Background(), &pb.GetProductRequest{Products: []string{"p1"}})
+	require.NoError(t, err)
+	cached, err := client.GetProductById(context.Background(), &pb.GetProductByIdRequest{Id: "p1"})
+	require.NoError(t, err)
+	// then
+	assert.False(t, client.ReportsStock())
+	for _, product := range []*pb.Product{fetched.GetProducts()[0], cached.GetProduct()} {
+		assert.Equal(t, int64(100), product.GetPrice())
+		assert.Zero(t, product.GetStockQuantity(), "the stock should not be returned")
+		assert.Empty(t, product.GetRestockAt(), "the restock time should not be returned")
+	}
+	assert.Equal(t, int32(10), mock.catalogue["p1"].GetStockQuantity(), "the fetched product should not be modified")
+}
+
+func TestClient_MaxEntries(t *testing.T) {
+	// given
+	mock := &ProductServiceClientMock{catalogue: map[string]*pb.Product{"p1": {Id: "p1"}, "p2": {Id: "p2"}, "p3": {Id: "p3"}}}
+	client := NewClient(mock, time.Minute, 2)
+	now := time.Now()
+	client.now = func() time.Time { return now }
+	_, err := client.GetProduct(context.Background(), &pb.GetProductRequest{Products: []string{"p1", "p2", "p3"}})
+	require.NoError(t, err)
+	mock.requests = nil
+	// when
+	resp, err := client.GetProduct(context.Background(), &pb.GetProductRequest{Products: []string{"p1", "p2", "p3"}})
+	// then
+	require.NoError(t, err)
+	assert.Len(t, resp.GetProducts(), 3)
+	assert.Equal(t, [][]string{{"p3"}}, mock.requests, "the product beyond the maximum should not be cached")
+	assert.Len(t, client.entries, 2)
+}
+
+func TestClient_DropsExpiredEntries(t *testing.T) {
+	// given
+	mock := &ProductServiceClientMock{catalogue: map[string]*pb.Product{"p1": {Id: "p1"}, "p2": {Id: "p2"}}}
+	client := NewClient(mock, time.Minute, 1)
+	now := time.Now()
+	client.now = func() time.Time { return now }
+	_, err := client.GetProductById(context.Background(), &pb.GetProductByIdRequest{Id: "p1"})
+	require.NoError(t, err)
+	now = now.Add(2 * time.Minute)
+	// when
+	_, err = client.GetProductById(context.Background(), &pb.GetProductByIdRequest{Id: "p2"})
+	// then
+	require.NoError(t, err)
+	assert.Len(t, client.entries, 1)
+	assert.Contains(t, client.entries, "p2", "the expired product should make room for the fetched one")
+}
@@ -3,21 +3,32 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/abgdnv/gocommerce/order_service/internal/config"
 	ordererrors "github.com/abgdnv/gocommerce/order_service/internal/errors"
 	"github.com/abgdnv/gocommerce/order_service/internal/store"
 	"github.com/abgdnv/gocommerce/order_service/internal/store/db"
 	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/product/v1"
+	userpb "github.com/abgdnv/gocommerce/pkg/api/gen/go/user/v1"
+	"github.com/abgdnv/gocommerce/pkg/audit"
 	"github.com/abgdnv/gocommerce/pkg/messaging"
 	"github.com/abgdnv/gocommerce/pkg/messaging/events"
+	"github.com/abgdnv/gocommerce/pkg/money"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/google/uuid"
+	"github.com/sony/gobreaker/v2"
 )
 
 // OrderService defines the methods for managing orders.
@@ -27,29 +38,79 @@ type OrderService interface {
 	// Returns ErrOrderNotFound if no order exists with the given ID.
 	FindByID(ctx context.Context, userID uuid.UUID, id uuid.UUID) (*OrderDto, error)
 
-	// FindOrdersByUserID returns all available orders for a specific user.
-	// Returns an empty slice if no orders exist.
-	FindOrdersByUserID(ctx context.Context, userID uuid.UUID, offset, limit int32) (*[]OrderDto, error)
+	// FindAnyByID retrieves an order of any user by its unique identifier, skipping the ownership check of FindByID.
+	// It's intended for support and admin staff.
+	// Returns ErrOrderNotFound if no order exists with the given ID.
+	FindAnyByID(ctx context.Context, id uuid.UUID) (*OrderDto, error)
+
+	// FindOrdersByUserID returns all available orders for a specific user,
+	// optionally created within [createdFrom, createdTo). A zero time leaves that end of the range open.
+	// Returns an empty slice if no orders exist, or ErrInvalidTimeRange if createdFrom is not before createdTo.
+	FindOrdersByUserID(ctx context.Context, userID uuid.UUID, offset, limit int32, createdFrom, createdTo time.Time) (*[]OrderDto, error)
+
+	// FindAllOrders returns orders of all users, optionally filtered by status and created within [createdFrom, createdTo).
+	// It's intended for support and admin staff. A zero time leaves that end of the range open.
+	// Returns an empty slice if no orders exist, or ErrInvalidTimeRange if createdFrom is not before createdTo.
+	FindAllOrders(ctx context.Context, offset, limit int32, statusFilter string, createdFrom, createdTo time.Time) (*[]OrderDto, error)
+
+	// SummaryForUser aggregates the orders of a user: their number and total price per currency, overall and by status.
+	SummaryForUser(ctx context.Context, userID uuid.UUID) (*OrderSummaryDto, error)
 
 	// Create adds a new order to the system.
+	// Returns ErrInvalidStatus if the order can't be created in its status.
+	// Returns ErrEmailNotVerified if email verification is required and the user's email is not verified.
+	// Returns ErrQuantityExceedsMax if the quantity of an item exceeds the maximum.
+	// Returns ErrMixedCurrencies if the products of the items are priced in different currencies.
 	// Returns error if the order cannot be created.
 	Create(ctx context.Context, order OrderCreateDto) (*OrderDto, error)
 
 	// Update modifies an existing order's details.
 	// Returns ErrOrderNotFound if no order exists with the given ID and version.
 	Update(ctx context.Context, userID uuid.UUID, order OrderUpdateDto) (*OrderDto, error)
+
+	// UpdateItems replaces the items of a pending order.
+	// Returns ErrQuantityExceedsMax if the quantity of an item exceeds the maximum, ErrOrderNotPending if the order is not pending,
+	// InsufficientStockError if the stock is insufficient, ErrMixedCurrencies if the products are priced in different currencies
+	// and ErrOptimisticLock if the order has been modified concurrently.
+	UpdateItems(ctx context.Context, userID, orderID uuid.UUID, items []OrderItemUpdateDto, version int32) (*OrderDto, error)
+
+	// ResendCreatedEvent publishes the OrderCreatedEvent of an existing order of any user again,
+	// so its confirmation is sent to the email of the owner. It's intended for support and admin staff.
+	// Returns ErrOrderNotFound if no order exists with the given ID, ErrUserNotFound if the owner of the order doesn't exist,
+	// or error if the event cannot be published.
+	ResendCreatedEvent(ctx context.Context, id uuid.UUID) error
+
+	// Delete soft-deletes an order of any user with its items, e.g. on a data removal request. It's intended for admin staff.
+	// Returns ErrOrderNotFound if no order exists with the given ID and ErrOptimisticLock if the version doesn't match.
+	Delete(ctx context.Context, id uuid.UUID, version int32) error
 }
 
+// StatusCompleted is the status of an order that has been completed.
+const StatusCompleted = "COMPLETED"
+
+// DefaultCurrency is the currency of the products priced before the Product service reported currencies.
+const DefaultCurrency = "USD"
+
+// StatusPending is the status of an order that has not been processed yet, only pending orders can be modified.
+const StatusPending = "PENDING"
+
 // Service implements OrderService and provides methods to manage orders.
 type Service struct {
 	orderStore    store.OrderStore
 	productClient pb.ProductServiceClient
+	userClient    userpb.UserServiceClient
 	publisher     messaging.Publisher
 	ordersCounter metric.Int64Counter
+	cfg           config.OrdersConfig
+	auditor       audit.Recorder
 }
 
+// auditResource is the resource name used in audit events for orders.
+const auditResource = "order"
+
 // NewService creates a new instance of OrderService with the provided orderStore.
-func NewService(orderStore store.OrderStore, productClient pb.ProductServiceClient, publisher messaging.Publisher) *Service {
+// The userClient looks up the email of the owner of an order when its confirmation is resent.
+func NewService(orderStore store.OrderStore, productClient pb.ProductServiceClient, userClient userpb.UserServiceClient, publisher messaging.Publisher, cfg config.OrdersConfig, auditor audit.Recorder) *Service {
 	meter := otel.Meter("order-service")
 	ordersCounter, err := meter.Int64Counter("orders_created", metric.WithDescription("Total number of created orders"))
 	if err != nil {
@@ -58,53 +119,109 @@ func NewService(orderStore store.OrderStore, productClient pb.ProductServiceClie
 	return &Service{
 		orderStore:    orderStore,
 		productClient: productClient,
+		userClient:    userClient,
 		publisher:     publisher,
 		ordersCounter: ordersCounter,
+		cfg:           cfg,
+		auditor:       auditor,
 	}
 }
 
 // OrderDto represents the data transfer object for an order.
 // Version is read-only and used for optimistic concurrency control.
+// TotalPrice is read-only, it's the sum of the prices of the order items.
+// AuthorizationAmount is returned on creation only, it's the amount to hold on the payment method of the customer:
+// the total price plus the estimated tax and shipping. Nothing is charged.
+// Both are decimal strings of the major units of the currency of the items, e.g. "25.55", see money.Money.
 type OrderDto struct {
-	ID        uuid.UUID      `json:"id"`
-	UserID    uuid.UUID      `json:"user_id" validate:"required"`
-	Status    string         `json:"status"`
-	Version   int32          `json:"version" validate:"required,min=1"`
-	CreatedAt string         `json:"created_at"`
-	Items     []OrderItemDto `json:"items,omitempty" validate:"required,gt=0,dive"`
+	ID                  uuid.UUID      `json:"id"`
+	UserID              uuid.UUID      `json:"user_id" validate:"required"`
+	Status              string         `json:"status"`
+	Version             int32          `json:"version" validate:"required,min=1"`
+	TotalPrice          money.Money    `json:"total_price"`
+	AuthorizationAmount *money.Money   `json:"authorization_amount,omitempty"`
+	CreatedAt           string         `json:"created_at"`
+	Items               []OrderItemDto `json:"items,omitempty" validate:"required,gt=0,dive"`
 }
 
+// OrderItemDto represents the data transfer object for an order item.
+// Currency is the ISO 4217 code of the currency of the product price, captured when the item was priced.
+// PricePerItem and Price are decimal strings of the major units of Currency, e.g. "5.99", see money.Money.
 type OrderItemDto struct {
-	ID           uuid.UUID `json:"id"`
-	OrderID      uuid.UUID `json:"order_id" validate:"required"`
-	ProductID    uuid.UUID `json:"product_id" validate:"required"`
-	Quantity     int32     `json:"quantity" validate:"required,min=1"`
-	PricePerItem int64     `json:"price_per_item" validate:"required,min=0"`
-	Price        int64     `json:"price" validate:"required,min=0"`
-	Version      int32     `json:"version" validate:"required,min=1"`
-	CreatedAt    string    `json:"created_at"`
+	ID           uuid.UUID   `json:"id"`
+	OrderID      uuid.UUID   `json:"order_id" validate:"required"`
+	ProductID    uuid.UUID   `json:"product_id" validate:"required"`
+	Quantity     int32       `json:"quantity" validate:"required,min=1"`
+	PricePerItem money.Money `json:"price_per_item" validate:"required,min=0"`
+	Price        money.Money `json:"price" validate:"required,min=0"`
+	Currency     string      `json:"currency"`
+	Version      int32       `json:"version" validate:"required,min=1"`
+	CreatedAt    string      `json:"created_at"`
 }
 
 // OrderCreateDto represents the data transfer object for creating a new order.
+// Email and EmailVerified are taken from the authenticated user's identity and are never read from the request body.
 type OrderCreateDto struct {
-	UserID uuid.UUID            `json:"user_id" validate:"required"`
-	Status string               `json:"status"  validate:"required"`
-	Items  []OrderItemCreateDto `json:"items"   validate:"required,gt=0,dive"`
+	UserID        uuid.UUID            `json:"user_id" validate:"required"`
+	Status        string               `json:"status"  validate:"required"`
+	Items         []OrderItemCreateDto `json:"items"   validate:"required,gt=0,dive"`
+	Email         string               `json:"-"`
+	EmailVerified bool                 `json:"-"`
 }
 
 // OrderItemCreateDto represents the data transfer object for creating a new order item.
 type OrderItemCreateDto struct {
-	ProductID    uuid.UUID `json:"product_id" validate:"required"`
-	Quantity     int32     `json:"quantity" validate:"required,min=1"`
-	PricePerItem int64     `json:"price_per_item" validate:"required,min=0"`
-	Price        int64     `json:"price" validate:"required,min=0"`
+	ProductID    uuid.UUID   `json:"product_id" validate:"required"`
+	Quantity     int32       `json:"quantity" validate:"required,min=1"`
+	PricePerItem money.Money `json:"price_per_item" validate:"required,min=0"`
+	Price        money.Money `json:"price" validate:"required,min=0"`
 }
 
 // OrderUpdateDto represents the data transfer object for updating an existing order.
+// Email is taken from the authenticated user's identity and is never read from the request body.
 type OrderUpdateDto struct {
 	ID      uuid.UUID `json:"id" validate:"required"`
 	Status  string    `json:"status"  validate:"required"`
 	Version int32     `json:"version" validate:"required,min=1"`
+	Email   string    `json:"-"`
+}
+
+// OrderItemsUpdateDto represents the data transfer object for replacing the items of an order.
+// Prices are taken from the Product service, so only products and quantities are read from the request body.
+type OrderItemsUpdateDto struct {
+	Items   []OrderItemUpdateDto `json:"items"   validate:"required,gt=0,dive"`
+	Version int32                `json:"version" validate:"required,min=1"`
+}
+
+// OrderItemUpdateDto represents the data transfer object for an item of a modified order.
+type OrderItemUpdateDto struct {
+	ProductID uuid.UUID `json:"product_id" validate:"required"`
+	Quantity  int32     `json:"quantity" validate:"required,min=1"`
+}
+
+// OrderSummaryDto represents the data transfer object for the aggregated orders of a user.
+// TotalSpent is the total price of the orders of any status per currency, ordered by currency,
+// since the prices in different currencies can't be added up.
+type OrderSummaryDto struct {
+	TotalOrders int64                   `json:"total_orders"`
+	TotalSpent  []CurrencyTotalDto      `json:"total_spent"`
+	ByStatus    []OrderStatusSummaryDto `json:"by_status"`
+}
+
+// CurrencyTotalDto represents the data transfer object for a total price in a currency.
+// Amount is a decimal string of the major units of Currency, e.g. "35.00", see money.Money.
+type CurrencyTotalDto struct {
+	Amount   money.Money `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// OrderStatusSummaryDto represents the data transfer object for the aggregated orders of a user with a status
+// in a currency. TotalPrice is a decimal string of the major units of Currency.
+type OrderStatusSummaryDto struct {
+	Status     string      `json:"status"`
+	Currency   string      `json:"currency"`
+	Orders     int64       `json:"orders"`
+	TotalPrice money.Money `json:"total_price"`
 }
 
 // FindByID retrieves an order by its ID and returns it as a OrderDto.
@@ -120,10 +237,32 @@ func (s *Service) FindByID(ctx context.Context, userID uuid.UUID, id uuid.UUID)
 	return toDto(order, items), nil
 }
 
+// FindAnyByID retrieves an order of any user by its ID and returns it as a OrderDto.
+// Returns ErrOrderNotFound if no order exists with the given ID.
+func (s *Service) FindAnyByID(ctx context.Context, id uuid.UUID) (*OrderDto, error) {
+	order, items, err := s.orderStore.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toDto(order, items), nil
+}
+
 // FindOrdersByUserID retrieves a list of all orders and returns them as OrderDtos.
+// A zero createdFrom or createdTo leaves that end of the creation time range open.
+// Returns ErrInvalidTimeRange if createdFrom is not before createdTo.
 // Returns an empty slice if no orders exist or error if the retrieval fails.
-func (s *Service) FindOrdersByUserID(ctx context.Context, userID uuid.UUID, offset, limit int32) (*[]OrderDto, error) {
-	orders, err := s.orderStore.FindOrdersByUserID(ctx, &db.FindOrdersByUserIDParams{UserID: userID, Offset: offset, Limit: limit})
+func (s *Service) FindOrdersByUserID(ctx context.Context, userID uuid.UUID, offset, limit int32, createdFrom, createdTo time.Time) (*[]OrderDto, error) {
+	from, to, err := createdRange(createdFrom, createdTo)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.orderStore.FindOrdersByUserID(ctx, &db.FindOrdersByUserIDParams{
+		UserID:      userID,
+		CreatedFrom: from,
+		CreatedTo:   to,
+		Offset:      offset,
+		Limit:       limit,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -136,62 +275,116 @@ func (s *Service) FindOrdersByUserID(ctx context.Context, userID uuid.UUID, offs
 	return &OrderDtos, nil
 }
 
+// FindAllOrders retrieves a page of orders of all users and returns them as OrderDtos.
+// An empty statusFilter returns orders of any status, a zero createdFrom or createdTo leaves
+// that end of the creation time range open.
+// Returns ErrInvalidTimeRange if createdFrom is not before createdTo.
+// Returns an empty slice if no orders exist or error if the retrieval fails.
+func (s *Service) FindAllOrders(ctx context.Context, offset, limit int32, statusFilter string, createdFrom, createdTo time.Time) (*[]OrderDto, error) {
+	from, to, err := createdRange(createdFrom, createdTo)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.orderStore.FindAllOrders(ctx, &db.FindAllOrdersParams{
+		Status:      statusFilter,
+		CreatedFrom: from,
+		CreatedTo:   to,
+		Offset:      offset,
+		Limit:       limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	orderDtos := make([]OrderDto, len(*orders))
+	for i, order := range *orders {
+		orderDtos[i] = *toDto(&order, nil)
+	}
+
+	return &orderDtos, nil
+}
+
+// SummaryForUser aggregates the orders of the user by status and currency and adds up the totals per currency.
+// Returns a summary with no statuses and totals if the user has no orders, or error if the aggregation fails.
+func (s *Service) SummaryForUser(ctx context.Context, userID uuid.UUID) (*OrderSummaryDto, error) {
+	rows, err := s.orderStore.SummarizeByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	summary := OrderSummaryDto{TotalSpent: []CurrencyTotalDto{}, ByStatus: make([]OrderStatusSummaryDto, len(rows))}
+	spent := make(map[string]int64)
+	for i, row := range rows {
+		summary.ByStatus[i] = OrderStatusSummaryDto{
+			Status:     row.Status,
+			Currency:   row.Currency,
+			Orders:     row.OrderCount,
+			TotalPrice: money.New(row.TotalPrice, row.Currency),
+		}
+		summary.TotalOrders += row.OrderCount
+		spent[row.Currency] += row.TotalPrice
+	}
+	for _, currency := range slices.Sorted(maps.Keys(spent)) {
+		summary.TotalSpent = append(summary.TotalSpent, CurrencyTotalDto{Amount: money.New(spent[currency], currency), Currency: currency})
+	}
+
+	return &summary, nil
+}
+
 // Create creates a new order and returns it as a OrderDto.
+// Returns ErrInvalidStatus if the status isn't one of OrdersConfig.CreateStatuses.
+// Returns ErrEmailNotVerified if email verification is required and the user's email is not verified.
+// Returns ErrQuantityExceedsMax if the quantity of an item exceeds the maximum.
+// Returns InsufficientStockError listing all items with insufficient stock.
+// Returns ErrMixedCurrencies if the products of the items are priced in different currencies.
+// If the stock reservation is enabled, the stock of the items is reserved before the order is stored
+// and released again if the order cannot be stored.
 // Returns an error if the order cannot be created.
 func (s *Service) Create(ctx context.Context, order OrderCreateDto) (*OrderDto, error) {
-
-	orderParams := db.CreateOrderParams{
-		UserID: order.UserID,
-		Status: order.Status,
+	if err := s.checkCreateStatus(ctx, order.Status); err != nil {
+		return nil, err
+	}
+	if s.cfg.RequireVerifiedEmail && !order.EmailVerified {
+		slog.WarnContext(ctx, "Order creation rejected: email is not verified", "userID", order.UserID)
+		return nil, ordererrors.ErrEmailNotVerified
 	}
 
-	// Check if the products exist and has sufficient stock.
-	products := make(map[string]OrderItemCreateDto)
+	quantities := make(map[uuid.UUID]int32, len(order.Items))
 	for _, item := range order.Items {
-		products[item.ProductID.String()] = item
-	}
-	ids := make([]string, 0, len(order.Items))
-	for k := range products {
-		ids = append(ids, k)
+		if err := s.checkQuantity(ctx, item.ProductID, item.Quantity); err != nil {
+			return nil, err
+		}
+		quantities[item.ProductID] = item.Quantity
 	}
-	slog.InfoContext(ctx, "Checking products stock", "products", ids)
-	productResp, err := s.productClient.GetProduct(ctx, &pb.GetProductRequest{Products: ids})
+	orderItems, totalPrice, err := s.priceItems(ctx, quantities, nil)
 	if err != nil {
-		slog.ErrorContext(ctx, "Failed to get product info from Product service", "error", err)
+		return nil, err
+	}
+	if err := checkCurrencies(ctx, orderItems); err != nil {
 		return nil, err
 	}
 
-	var totalPrice, price int64
-	orderItems := make([]db.CreateOrderItemParams, 0, len(order.Items))
-	for _, resp := range productResp.Products {
-		available := resp.StockQuantity
-		requested := products[resp.Id].Quantity
-		if available < requested {
-			message := fmt.Sprintf("product %s. Available: %d, Requested: %d", resp.Id, available, requested)
-			slog.WarnContext(ctx, fmt.Sprintf("Insufficient stock for %s", message))
-			return nil, fmt.Errorf("%s: %w", message, ordererrors.ErrInsufficientStock)
-		}
-		price = resp.Price * int64(requested)
-		orderItems = append(orderItems, db.CreateOrderItemParams{
-			ProductID:    products[resp.Id].ProductID,
-			Quantity:     requested,
-			PricePerItem: resp.Price,
-			Price:        price,
-		})
-		totalPrice += price
+	orderParams := db.CreateOrderParams{
+		UserID:     order.UserID,
+		Status:     order.Status,
+		TotalPrice: totalPrice,
 	}
 
+	if err := s.reserveStock(ctx, orderItems); err != nil {
+		return nil, err
+	}
 	createOrder, items, err := s.orderStore.CreateOrder(ctx, &orderParams, &orderItems)
 	if err != nil {
+		s.releaseStock(ctx, orderItems)
 		return nil, err
 	}
 
 	carrier := make(propagation.MapCarrier)
 	otel.GetTextMapPropagator().Inject(ctx, carrier)
 	event := events.OrderCreatedEvent{
+		Envelope:   events.Envelope{EventID: uuid.New()},
 		Carrier:    carrier,
 		OrderID:    createOrder.ID,
 		UserID:     createOrder.UserID,
+		UserEmail:  order.Email,
 		TotalPrice: totalPrice,
 		CreatedAt:  *createOrder.CreatedAt,
 	}
@@ -199,13 +392,346 @@ func (s *Service) Create(ctx context.Context, order OrderCreateDto) (*OrderDto,
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to publish OrderCreatedEvent", "error", err)
 	}
+	s.auditor.Record(ctx, audit.ActionCreate, auditResource, createOrder.ID.String())
 	// increase the number of created orders
 	s.ordersCounter.Add(ctx, 1)
 
-	return toDto(createOrder, items), nil
+	dto := toDto(createOrder, items)
+	authorizationAmount := money.New(s.authorizationAmount(totalPrice), dto.TotalPrice.Currency)
+	dto.AuthorizationAmount = &authorizationAmount
+	return dto, nil
+}
+
+// ResendCreatedEvent publishes the OrderCreatedEvent of an existing order again, built from the stored order.
+// The email address isn't stored with the order, so it's the current email of the owner, looked up in the User service.
+// The event gets a new ID, so the notification service doesn't skip it as a duplicate of the original event.
+// Returns ErrOrderNotFound if no order exists with the given ID, ErrUserNotFound if the owner of the order doesn't exist,
+// or an error if the event cannot be published.
+func (s *Service) ResendCreatedEvent(ctx context.Context, id uuid.UUID) error {
+	order, _, err := s.orderStore.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	email, err := s.ownerEmail(ctx, order.UserID)
+	if err != nil {
+		return err
+	}
+
+	carrier := make(propagation.MapCarrier)
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	event := events.OrderCreatedEvent{
+		Envelope:   events.Envelope{EventID: uuid.New()},
+		Carrier:    carrier,
+		OrderID:    order.ID,
+		UserID:     order.UserID,
+		UserEmail:  email,
+		TotalPrice: order.TotalPrice,
+		CreatedAt:  *order.CreatedAt,
+	}
+	if err := s.publisher.Publish(ctx, event); err != nil {
+		return fmt.Errorf("failed to publish OrderCreatedEvent: %w", err)
+	}
+	slog.InfoContext(ctx, "OrderCreatedEvent resent", "orderID", order.ID, "eventID", event.EventID)
+	return nil
+}
+
+// ownerEmail returns the email of the user with the given ID from the User service.
+// Returns ErrUserNotFound if the user doesn't exist.
+func (s *Service) ownerEmail(ctx context.Context, userID uuid.UUID) (string, error) {
+	user, err := s.userClient.GetUser(ctx, &userpb.GetUserRequest{Id: userID.String()})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return "", fmt.Errorf("%w: %s", ordererrors.ErrUserNotFound, userID)
+		}
+		return "", fmt.Errorf("failed to get user %s: %w", userID, err)
+	}
+	return user.Email, nil
+}
+
+// authorizationAmount returns the total price plus the estimated tax and shipping of the order.
+// The tax is rounded half up to the minor unit.
+func (s *Service) authorizationAmount(totalPrice int64) int64 {
+	tax := (totalPrice*s.cfg.TaxRate + 5000) / 10000
+	shipping := s.cfg.ShippingFee
+	if s.cfg.FreeShippingFrom > 0 && totalPrice >= s.cfg.FreeShippingFrom {
+		shipping = 0
+	}
+	return totalPrice + tax + shipping
+}
+
+// checkCreateStatus returns ErrInvalidStatus if the status isn't one of the statuses an order can be created in,
+// so clients can't create an order which skipped processing, e.g. a completed one.
+func (s *Service) checkCreateStatus(ctx context.Context, status string) error {
+	allowed := s.cfg.AllowedCreateStatuses()
+	if slices.ContainsFunc(allowed, func(a string) bool { return strings.EqualFold(a, status) }) {
+		return nil
+	}
+	slog.WarnContext(ctx, "Order creation rejected: status is not allowed", "status", status, "allowed", allowed)
+	return fmt.Errorf("%w: %s, allowed: %s", ordererrors.ErrInvalidStatus, status, strings.Join(allowed, ", "))
+}
+
+// checkQuantity returns ErrQuantityExceedsMax if the quantity of the order item exceeds the maximum, zero disables the check.
+// The limit applies to every item on its own, not to the total quantity of the order.
+func (s *Service) checkQuantity(ctx context.Context, productID uuid.UUID, quantity int32) error {
+	if s.cfg.MaxItemQuantity <= 0 || quantity <= s.cfg.MaxItemQuantity {
+		return nil
+	}
+	slog.WarnContext(ctx, "Order item quantity exceeds the maximum", "productID", productID, "quantity", quantity, "max", s.cfg.MaxItemQuantity)
+	return fmt.Errorf("%w: product %s, quantity %d, maximum %d", ordererrors.ErrQuantityExceedsMax, productID, quantity, s.cfg.MaxItemQuantity)
+}
+
+// priceItems checks that the products exist and have sufficient stock, and prices the order items with the current product prices.
+// The stock is not checked if the Product service client doesn't report it, e.g. the product cache,
+// it's checked when the stock is reserved instead. The currency of every product price is captured in its order item.
+// The quantities are keyed by product ID. The reserved quantities, keyed by product ID as well, are already taken
+// from the stock for the order, so they are available to it in addition to the stock. Returns the order items and their total price.
+// Returns ProductNotFoundError listing all products the Product service doesn't know,
+// or InsufficientStockError listing all items with insufficient stock or a deleted product.
+func (s *Service) priceItems(ctx context.Context, quantities, reserved map[uuid.UUID]int32) ([]db.CreateOrderItemParams, int64, error) {
+	products := make(map[string]uuid.UUID, len(quantities))
+	ids := make([]string, 0, len(quantities))
+	for productID := range quantities {
+		products[productID.String()] = productID
+		ids = append(ids, productID.String())
+	}
+	slog.InfoContext(ctx, "Checking products stock", "products", ids)
+	found, err := s.getProducts(ctx, ids)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get product info from Product service", "error", err)
+		return nil, 0, err
+	}
+
+	if missing := missingProducts(ids, found); len(missing) > 0 {
+		notFoundErr := &ordererrors.ProductNotFoundError{ProductIDs: missing}
+		slog.WarnContext(ctx, "Products not found", "error", notFoundErr)
+		return nil, 0, notFoundErr
+	}
+
+	checkStock := s.reportsStock()
+	var totalPrice, price int64
+	var insufficient []ordererrors.InsufficientStockItem
+	orderItems := make([]db.CreateOrderItemParams, 0, len(quantities))
+	for _, resp := range found {
+		productID := products[resp.Id]
+		available := resp.StockQuantity + reserved[productID]
+		requested := quantities[productID]
+		if resp.IsDeleted {
+			insufficient = append(insufficient, ordererrors.InsufficientStockItem{
+				ProductID: resp.Id,
+				Requested: requested,
+				Deleted:   true,
+			})
+			continue
+		}
+		if checkStock && available < requested {
+			insufficient = append(insufficient, ordererrors.InsufficientStockItem{
+				ProductID:  resp.Id,
+				Available:  available,
+				Requested:  requested,
+				RestockETA: s.restockETA(ctx, resp),
+			})
+			continue
+		}
+		price = resp.Price * int64(requested)
+		orderItems = append(orderItems, db.CreateOrderItemParams{
+			ProductID:    productID,
+			Quantity:     requested,
+			PricePerItem: resp.Price,
+			Price:        price,
+			Currency:     productCurrency(resp),
+		})
+		totalPrice += price
+	}
+	if len(insufficient) > 0 {
+		stockErr := &ordererrors.InsufficientStockError{Items: insufficient}
+		slog.WarnContext(ctx, "Insufficient stock", "error", stockErr)
+		return nil, 0, stockErr
+	}
+	return orderItems, totalPrice, nil
+}
+
+// checkCurrencies returns ErrMixedCurrencies if the order items are priced in different currencies,
+// since the total price of the order has a single currency.
+func checkCurrencies(ctx context.Context, items []db.CreateOrderItemParams) error {
+	currencies := make(map[string]bool, 1)
+	for _, item := range items {
+		currencies[item.Currency] = true
+	}
+	if len(currencies) <= 1 {
+		return nil
+	}
+	names := slices.Sorted(maps.Keys(currencies))
+	slog.WarnContext(ctx, "Order items are priced in different currencies", "currencies", names)
+	return fmt.Errorf("%w: %s", ordererrors.ErrMixedCurrencies, strings.Join(names, ", "))
+}
+
+// reserveStock takes the quantities of the order items from the stock of their products, if the stock reservation is enabled.
+// Returns ErrInsufficientStock if another order took the stock since it was checked.
+func (s *Service) reserveStock(ctx context.Context, items []db.CreateOrderItemParams) error {
+	if !s.cfg.ReserveStock || len(items) == 0 {
+		return nil
+	}
+	_, err := s.productClient.ReserveStock(ctx, &pb.ReserveStockRequest{Items: stockItems(items)})
+	if status.Code(err) == codes.FailedPrecondition {
+		slog.WarnContext(ctx, "Stock taken since it was checked", "error", err)
+		return fmt.Errorf("%w: the stock was taken by another order", ordererrors.ErrInsufficientStock)
+	} else if err != nil {
+		slog.ErrorContext(ctx, "Failed to reserve stock", "error", err)
+		return fmt.Errorf("failed to reserve stock: %w", productServiceError(err))
+	}
+	return nil
+}
+
+// releaseStock returns the quantities of the order items reserved by reserveStock to the stock of their products,
+// after the order couldn't be stored or the items were removed from it.
+// It's released even if the request is canceled, e.g. by the timeout which failed the order.
+// A failure is logged with the items, so the stock can be reconciled manually.
+func (s *Service) releaseStock(ctx context.Context, items []db.CreateOrderItemParams) {
+	if !s.cfg.ReserveStock || len(items) == 0 {
+		return
+	}
+	stock := stockItems(items)
+	if _, err := s.productClient.ReleaseStock(context.WithoutCancel(ctx), &pb.ReleaseStockRequest{Items: stock}); err != nil {
+		quantities := make(map[string]int32, len(stock))
+		for _, item := range stock {
+			quantities[item.ProductId] = item.Quantity
+		}
+		slog.ErrorContext(ctx, "Failed to release reserved stock, it must be reconciled manually", "quantities", quantities, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "Reserved stock released", "items", len(stock))
+}
+
+// reservedQuantities returns the quantities of the stored order items keyed by product ID,
+// which are reserved for the order if the stock reservation is enabled, nil otherwise.
+func (s *Service) reservedQuantities(items []db.OrderItem) map[uuid.UUID]int32 {
+	if !s.cfg.ReserveStock {
+		return nil
+	}
+	reserved := make(map[uuid.UUID]int32, len(items))
+	for _, item := range items {
+		reserved[item.ProductID] += item.Quantity
+	}
+	return reserved
+}
+
+// stockChanges returns the quantities to reserve and to release when the previous items of an order are replaced by the items,
+// i.e. the increases of the quantities and the decreases, including the quantities of the removed items.
+func stockChanges(previous []db.OrderItem, items []db.CreateOrderItemParams) (reserve, release []db.CreateOrderItemParams) {
+	quantities := make(map[uuid.UUID]int32, len(previous))
+	for _, item := range previous {
+		quantities[item.ProductID] += item.Quantity
+	}
+	for _, item := range items {
+		delta := item.Quantity - quantities[item.ProductID]
+		delete(quantities, item.ProductID)
+		if delta > 0 {
+			reserve = append(reserve, db.CreateOrderItemParams{ProductID: item.ProductID, Quantity: delta})
+		} else if delta < 0 {
+			release = append(release, db.CreateOrderItemParams{ProductID: item.ProductID, Quantity: -delta})
+		}
+	}
+	for _, item := range previous {
+		if quantity, ok := quantities[item.ProductID]; ok {
+			release = append(release, db.CreateOrderItemParams{ProductID: item.ProductID, Quantity: quantity})
+			delete(quantities, item.ProductID)
+		}
+	}
+	return reserve, release
+}
+
+// stockItems returns the product quantities of the order items.
+func stockItems(items []db.CreateOrderItemParams) []*pb.StockItem {
+	stock := make([]*pb.StockItem, 0, len(items))
+	for _, item := range items {
+		stock = append(stock, &pb.StockItem{ProductId: item.ProductID.String(), Quantity: item.Quantity})
+	}
+	return stock
+}
+
+// missingProducts returns the sorted IDs of the products which are not found.
+func missingProducts(ids []string, found []*pb.Product) []string {
+	known := make(map[string]bool, len(found))
+	for _, product := range found {
+		known[product.GetId()] = true
+	}
+	var missing []string
+	for _, id := range ids {
+		if !known[id] {
+			missing = append(missing, id)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// stockReporter is implemented by Product service clients which may return products without their stock,
+// see productcache.Client.
+type stockReporter interface {
+	ReportsStock() bool
+}
+
+// reportsStock reports whether the products returned by the Product service client have their current stock.
+func (s *Service) reportsStock() bool {
+	if reporter, ok := s.productClient.(stockReporter); ok {
+		return reporter.ReportsStock()
+	}
+	return true
+}
+
+// getProducts fetches the products with the given IDs from the Product service.
+// A single product is fetched with GetProductById, multiple products with the batch GetProduct.
+// The products which don't exist are left out, like the batch GetProduct does, instead of failing the call.
+func (s *Service) getProducts(ctx context.Context, ids []string) ([]*pb.Product, error) {
+	if len(ids) == 1 {
+		resp, err := s.productClient.GetProductById(ctx, &pb.GetProductByIdRequest{Id: ids[0]})
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		} else if err != nil {
+			return nil, productServiceError(err)
+		}
+		return []*pb.Product{resp.GetProduct()}, nil
+	}
+	resp, err := s.productClient.GetProduct(ctx, &pb.GetProductRequest{Products: ids})
+	if err != nil {
+		return nil, productServiceError(err)
+	}
+	return resp.GetProducts(), nil
+}
+
+// productServiceError returns the error of a Product service call with its gRPC status preserved.
+// A call rejected by the open circuit breaker never reached the Product service, so it's reported as Unavailable,
+// like a refused connection, instead of an unknown error.
+func productServiceError(err error) error {
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return err
+}
+
+// productCurrency returns the currency of the product price, USD if the Product service doesn't report it.
+func productCurrency(product *pb.Product) string {
+	if product.GetCurrency() == "" {
+		return DefaultCurrency
+	}
+	return product.GetCurrency()
+}
+
+// restockETA returns the expected restock time of the product if it is known and exposing it is enabled.
+func (s *Service) restockETA(ctx context.Context, product *pb.Product) *time.Time {
+	if !s.cfg.RestockETA || product.RestockAt == "" {
+		return nil
+	}
+	eta, err := time.Parse(time.RFC3339, product.RestockAt)
+	if err != nil {
+		slog.WarnContext(ctx, "Invalid restock time from Product service", "productID", product.Id, "error", err)
+		return nil
+	}
+	return &eta
 }
 
 // Update modifies an existing order's details and returns the updated order as a OrderDto.
+// Publishes OrderCompletedEvent when the order transitions to COMPLETED.
 // Returns ErrOrderNotFound if no order exists with the given ID and version.
 func (s *Service) Update(ctx context.Context, userID uuid.UUID, updateDto OrderUpdateDto) (*OrderDto, error) {
 
@@ -222,10 +748,116 @@ func (s *Service) Update(ctx context.Context, userID uuid.UUID, updateDto OrderU
 	if err != nil {
 		return nil, err
 	}
+	s.auditor.Record(ctx, audit.ActionUpdate, auditResource, updated.ID.String())
+
+	// Notify downstream services only on the transition to COMPLETED, not on repeated updates of a completed order
+	if order.Status != StatusCompleted && updated.Status == StatusCompleted {
+		carrier := make(propagation.MapCarrier)
+		otel.GetTextMapPropagator().Inject(ctx, carrier)
+		event := events.OrderCompletedEvent{
+			Envelope:    events.Envelope{EventID: uuid.New()},
+			Carrier:     carrier,
+			OrderID:     updated.ID,
+			UserID:      updated.UserID,
+			UserEmail:   updateDto.Email,
+			CompletedAt: time.Now().UTC(),
+		}
+		if err := s.publisher.Publish(ctx, event); err != nil {
+			slog.ErrorContext(ctx, "Failed to publish OrderCompletedEvent", "error", err)
+		}
+	}
 
 	return toDto(updated, nil), nil
 }
 
+// UpdateItems replaces the items of a pending order and returns the updated order as a OrderDto.
+// The stock of the products is checked again and the items are priced with the current product prices.
+// If the stock reservation is enabled, the added quantities are reserved before the items are stored
+// and released again if they cannot be stored, the removed quantities are released once the items are stored.
+// Returns ErrQuantityExceedsMax if the quantity of an item exceeds the maximum, ErrOrderNotPending if the order is not pending,
+// InsufficientStockError listing all items with insufficient stock, ErrMixedCurrencies if the products are priced in different currencies
+// and ErrOptimisticLock if the order has been modified concurrently.
+func (s *Service) UpdateItems(ctx context.Context, userID, orderID uuid.UUID, items []OrderItemUpdateDto, version int32) (*OrderDto, error) {
+	for _, item := range items {
+		if err := s.checkQuantity(ctx, item.ProductID, item.Quantity); err != nil {
+			return nil, err
+		}
+	}
+	order, previous, err := s.orderStore.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, ordererrors.ErrAccessDenied
+	}
+	if !strings.EqualFold(order.Status, StatusPending) {
+		slog.WarnContext(ctx, "Order items modification rejected", "orderID", orderID, "status", order.Status)
+		return nil, ordererrors.ErrOrderNotPending
+	}
+
+	quantities := make(map[uuid.UUID]int32, len(items))
+	for _, item := range items {
+		quantities[item.ProductID] = item.Quantity
+	}
+	var previousItems []db.OrderItem
+	if previous != nil {
+		previousItems = *previous
+	}
+	orderItems, totalPrice, err := s.priceItems(ctx, quantities, s.reservedQuantities(previousItems))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCurrencies(ctx, orderItems); err != nil {
+		return nil, err
+	}
+
+	reserve, release := stockChanges(previousItems, orderItems)
+	if err := s.reserveStock(ctx, reserve); err != nil {
+		return nil, err
+	}
+	updated, updatedItems, err := s.orderStore.UpdateItems(ctx, &db.UpdateOrderTotalPriceParams{ID: orderID, Version: version, TotalPrice: totalPrice}, &orderItems)
+	if err != nil {
+		s.releaseStock(ctx, reserve)
+		return nil, err
+	}
+	s.releaseStock(ctx, release)
+	s.auditor.Record(ctx, audit.ActionUpdate, auditResource, updated.ID.String())
+	slog.InfoContext(ctx, "Order items updated", "orderID", updated.ID, "items", len(orderItems), "totalPrice", updated.TotalPrice)
+
+	return toDto(updated, updatedItems), nil
+}
+
+// Delete soft-deletes the order with its items, the deleted order is no longer found or listed.
+// Returns ErrOrderNotFound if no order exists with the given ID and ErrOptimisticLock if the version doesn't match.
+func (s *Service) Delete(ctx context.Context, id uuid.UUID, version int32) error {
+	if err := s.orderStore.Delete(ctx, &db.SoftDeleteOrderParams{ID: id, Version: version}); err != nil {
+		return err
+	}
+	s.auditor.Record(ctx, audit.ActionDelete, auditResource, id.String())
+	slog.InfoContext(ctx, "Order deleted", "orderID", id)
+	return nil
+}
+
+// createdRange converts the creation time range to the query parameters, nil for a zero time.
+// The orders store their creation time in UTC without a time zone, so the times are converted to UTC.
+// Returns ErrInvalidTimeRange if both times are set and from is not before to.
+func createdRange(from, to time.Time) (*time.Time, *time.Time, error) {
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return nil, nil, fmt.Errorf("%w: from %s must be before to %s",
+			ordererrors.ErrInvalidTimeRange, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	var fromUTC, toUTC *time.Time
+	if !from.IsZero() {
+		t := from.UTC()
+		fromUTC = &t
+	}
+	if !to.IsZero() {
+		t := to.UTC()
+		toUTC = &t
+	}
+	return fromUTC, toUTC, nil
+}
+
 // toDto converts a store.Order to a OrderDto.
 func toDto(order *db.Order, items *[]db.OrderItem) *OrderDto {
 	if order == nil {
@@ -241,8 +873,9 @@ func toDto(order *db.Order, items *[]db.OrderItem) *OrderDto {
 				OrderID:      item.OrderID,
 				ProductID:    item.ProductID,
 				Quantity:     item.Quantity,
-				PricePerItem: item.PricePerItem,
-				Price:        item.Price,
+				PricePerItem: money.New(item.PricePerItem, item.Currency),
+				Price:        money.New(item.Price, item.Currency),
+				Currency:     item.Currency,
 				Version:      item.Version,
 				CreatedAt:    item.CreatedAt.Format(time.RFC3339),
 			})
@@ -250,11 +883,21 @@ func toDto(order *db.Order, items *[]db.OrderItem) *OrderDto {
 	}
 
 	return &OrderDto{
-		ID:        order.ID,
-		UserID:    order.UserID,
-		Status:    order.Status,
-		Version:   order.Version,
-		CreatedAt: order.CreatedAt.Format(time.RFC3339),
-		Items:     itemsDto,
+		ID:         order.ID,
+		UserID:     order.UserID,
+		Status:     order.Status,
+		Version:    order.Version,
+		TotalPrice: money.New(order.TotalPrice, orderCurrency(items)),
+		CreatedAt:  order.CreatedAt.Format(time.RFC3339),
+		Items:      itemsDto,
+	}
+}
+
+// orderCurrency returns the currency of the order items, which is the same for all of them, see checkCurrencies.
+// It's empty if the items are not loaded, e.g. in the list of the orders.
+func orderCurrency(items *[]db.OrderItem) string {
+	if items == nil || len(*items) == 0 {
+		return ""
 	}
+	return (*items)[0].Currency
 }
@@ -2,15 +2,22 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
+	"github.com/abgdnv/gocommerce/order_service/internal/config"
 	ordererrors "github.com/abgdnv/gocommerce/order_service/internal/errors"
 	"github.com/abgdnv/gocommerce/order_service/internal/store/db"
 	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/product/v1"
+	userpb "github.com/abgdnv/gocommerce/pkg/api/gen/go/user/v1"
+	"github.com/abgdnv/gocommerce/pkg/audit"
 	"github.com/abgdnv/gocommerce/pkg/messaging"
+	"github.com/abgdnv/gocommerce/pkg/messaging/events"
+	"github.com/abgdnv/gocommerce/pkg/money"
 	"github.com/google/uuid"
+	"github.com/sony/gobreaker/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
@@ -26,6 +33,19 @@ type mockOrderStore struct {
 	error       error
 	updateOrder *db.Order
 	updateError error
+	// updatedItems captures the items passed to UpdateItems
+	updatedItems []db.CreateOrderItemParams
+	// createParams and createItems capture the order and the items passed to CreateOrder
+	createParams *db.CreateOrderParams
+	createItems  []db.CreateOrderItemParams
+	// findParams and findAllParams capture the params passed to FindOrdersByUserID and FindAllOrders
+	findParams    *db.FindOrdersByUserIDParams
+	findAllParams *db.FindAllOrdersParams
+	// deleteParams captures the params passed to Delete
+	deleteParams *db.SoftDeleteOrderParams
+	// summary is returned by SummarizeByUserID, summaryUserID captures the user passed to it
+	summary       []db.SummarizeOrdersByUserIDRow
+	summaryUserID uuid.UUID
 }
 
 func (m *mockOrderStore) FindByID(_ context.Context, _ uuid.UUID) (*db.Order, *[]db.OrderItem, error) {
@@ -35,14 +55,33 @@ func (m *mockOrderStore) FindByID(_ context.Context, _ uuid.UUID) (*db.Order, *[
 	return m.order, m.items, nil
 }
 
-func (m *mockOrderStore) FindOrdersByUserID(_ context.Context, _ *db.FindOrdersByUserIDParams) (*[]db.Order, error) {
+func (m *mockOrderStore) FindOrdersByUserID(_ context.Context, params *db.FindOrdersByUserIDParams) (*[]db.Order, error) {
+	m.findParams = params
 	if m.error != nil {
 		return nil, m.error
 	}
 	return m.orders, nil
 }
 
-func (m *mockOrderStore) CreateOrder(_ context.Context, _ *db.CreateOrderParams, _ *[]db.CreateOrderItemParams) (*db.Order, *[]db.OrderItem, error) {
+func (m *mockOrderStore) FindAllOrders(_ context.Context, params *db.FindAllOrdersParams) (*[]db.Order, error) {
+	m.findAllParams = params
+	if m.error != nil {
+		return nil, m.error
+	}
+	return m.orders, nil
+}
+
+func (m *mockOrderStore) SummarizeByUserID(_ context.Context, userID uuid.UUID) ([]db.SummarizeOrdersByUserIDRow, error) {
+	m.summaryUserID = userID
+	if m.error != nil {
+		return nil, m.error
+	}
+	return m.summary, nil
+}
+
+func (m *mockOrderStore) CreateOrder(_ context.Context, params *db.CreateOrderParams, items *[]db.CreateOrderItemParams) (*db.Order, *[]db.OrderItem, error) {
+	m.createParams = params
+	m.createItems = *items
 	if m.error != nil {
 		return nil, nil, m.error
 	}
@@ -56,15 +95,49 @@ func (m *mockOrderStore) Update(_ context.Context, _ *db.UpdateOrderParams) (*db
 	return m.updateOrder, nil
 }
 
+// UpdateItems returns the order with the incremented version and the passed items.
+func (m *mockOrderStore) UpdateItems(_ context.Context, params *db.UpdateOrderTotalPriceParams, items *[]db.CreateOrderItemParams) (*db.Order, *[]db.OrderItem, error) {
+	m.updatedItems = *items
+	if m.updateError != nil {
+		return nil, nil, m.updateError
+	}
+	order := *m.order
+	order.Version = params.Version + 1
+	order.TotalPrice = params.TotalPrice
+	orderItems := make([]db.OrderItem, 0, len(*items))
+	for _, item := range *items {
+		orderItems = append(orderItems, db.OrderItem{
+			OrderID:      order.ID,
+			ProductID:    item.ProductID,
+			Quantity:     item.Quantity,
+			PricePerItem: item.PricePerItem,
+			Price:        item.Price,
+			Currency:     item.Currency,
+			Version:      1,
+			CreatedAt:    order.CreatedAt,
+		})
+	}
+	return &order, &orderItems, nil
+}
+
+func (m *mockOrderStore) Delete(_ context.Context, params *db.SoftDeleteOrderParams) error {
+	m.deleteParams = params
+	return m.updateError
+}
+
 type ProductServiceClientMock struct {
 	productResponse *pb.GetProductResponse
 	error           error
 	ServerTimeout   time.Duration
+	reserveError    error
+	releaseError    error
+	reserved        []*pb.StockItem // captures the items passed to ReserveStock
+	released        []*pb.StockItem // captures the items passed to ReleaseStock
 }
 
 var errContextDeadlineExceeded = status.Error(codes.DeadlineExceeded, "context deadline exceeded")
 
-func (p ProductServiceClientMock) GetProduct(ctx context.Context, _ *pb.GetProductRequest, _ ...grpc.CallOption) (*pb.GetProductResponse, error) {
+func (p *ProductServiceClientMock) GetProduct(ctx context.Context, _ *pb.GetProductRequest, _ ...grpc.CallOption) (*pb.GetProductResponse, error) {
 	if p.ServerTimeout > 0 {
 		timer := time.NewTimer(p.ServerTimeout)
 		defer timer.Stop()
@@ -80,17 +153,76 @@ func (p ProductServiceClientMock) GetProduct(ctx context.Context, _ *pb.GetProdu
 	return p.productResponse, nil
 }
 
+// GetProductById returns the requested product from the product response, or NotFound if it's absent.
+func (p *ProductServiceClientMock) GetProductById(ctx context.Context, in *pb.GetProductByIdRequest, opts ...grpc.CallOption) (*pb.GetProductByIdResponse, error) {
+	resp, err := p.GetProduct(ctx, &pb.GetProductRequest{Products: []string{in.GetId()}}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, product := range resp.GetProducts() {
+		if product.GetId() == in.GetId() {
+			return &pb.GetProductByIdResponse{Product: product}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "product with ID %s is not found", in.GetId())
+}
+
+func (p *ProductServiceClientMock) ReserveStock(_ context.Context, in *pb.ReserveStockRequest, _ ...grpc.CallOption) (*pb.ReserveStockResponse, error) {
+	p.reserved = in.GetItems()
+	if p.reserveError != nil {
+		return nil, p.reserveError
+	}
+	return &pb.ReserveStockResponse{}, nil
+}
+
+func (p *ProductServiceClientMock) ReleaseStock(_ context.Context, in *pb.ReleaseStockRequest, _ ...grpc.CallOption) (*pb.ReleaseStockResponse, error) {
+	p.released = in.GetItems()
+	if p.releaseError != nil {
+		return nil, p.releaseError
+	}
+	return &pb.ReleaseStockResponse{}, nil
+}
+
+type UserServiceClientMock struct {
+	user      *userpb.GetUserResponse
+	error     error
+	requested string // captures the ID passed to GetUser
+}
+
+func (u *UserServiceClientMock) Register(context.Context, *userpb.RegisterRequest, ...grpc.CallOption) (*userpb.RegisterResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "not implemented")
+}
+
+func (u *UserServiceClientMock) GetUser(_ context.Context, in *userpb.GetUserRequest, _ ...grpc.CallOption) (*userpb.GetUserResponse, error) {
+	u.requested = in.GetId()
+	if u.error != nil {
+		return nil, u.error
+	}
+	return u.user, nil
+}
+
 type PublisherMock struct {
-	error error
+	error     error
+	published []messaging.Event
 }
 
-func (p *PublisherMock) Publish(_ context.Context, _ messaging.Event) error {
+func (p *PublisherMock) Publish(_ context.Context, event messaging.Event) error {
 	if p.error != nil {
 		return p.error
 	}
+	p.published = append(p.published, event)
 	return nil
 }
 
+// totalPrice returns the sum of the prices of the order items.
+func totalPrice(items []db.CreateOrderItemParams) int64 {
+	var total int64
+	for _, item := range items {
+		total += item.Price
+	}
+	return total
+}
+
 func assertEqualOrderDto(t *testing.T, expected, actual *OrderDto) {
 	t.Helper()
 	if expected == nil || actual == nil {
@@ -101,6 +233,7 @@ func assertEqualOrderDto(t *testing.T, expected, actual *OrderDto) {
 	assert.Equal(t, expected.UserID, actual.UserID)
 	assert.Equal(t, expected.Status, actual.Status)
 	assert.Equal(t, expected.Version, actual.Version)
+	assert.Equal(t, expected.TotalPrice, actual.TotalPrice)
 	assert.Equal(t, expected.CreatedAt, actual.CreatedAt)
 	require.Len(t, actual.Items, len(expected.Items))
 	for i := range expected.Items {
@@ -120,6 +253,7 @@ func assertEqualOrderItemDto(t *testing.T, expected, actual *OrderItemDto) {
 	assert.Equal(t, expected.Quantity, actual.Quantity)
 	assert.Equal(t, expected.PricePerItem, actual.PricePerItem)
 	assert.Equal(t, expected.Price, actual.Price)
+	assert.Equal(t, expected.Currency, actual.Currency)
 	assert.Equal(t, expected.Version, actual.Version)
 	assert.Equal(t, expected.CreatedAt, actual.CreatedAt)
 
@@ -159,7 +293,7 @@ func Test_OrderService_FindByID(t *testing.T) {
 					ID:        mockOrderItemID,
 					OrderID:   mockID,
 					ProductID: mockProductID,
-					Quantity:  1, Price: 100,
+					Quantity:  1, Price: money.New(100, ""),
 					CreatedAt: createdAt.Format(time.RFC3339),
 				}}},
 			expectError: nil,
@@ -190,7 +324,7 @@ func Test_OrderService_FindByID(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore, nil, nil)
+			service := NewService(tc.mockStore, nil, nil, nil, config.OrdersConfig{}, audit.NoopRecorder{})
 			// when
 			found, err := service.FindByID(context.Background(), tc.userID, tc.orderID)
 			// then
@@ -206,14 +340,74 @@ func Test_OrderService_FindByID(t *testing.T) {
 	}
 }
 
+func Test_OrderService_FindAnyByID(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockOwnerID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+
+	createdAt := time.Now()
+	testCases := []struct {
+		name        string
+		mockStore   *mockOrderStore
+		expected    *OrderDto
+		expectError error
+	}{
+		{
+			name: "Success - order of another user found",
+			mockStore: &mockOrderStore{
+				order: &db.Order{ID: mockID, UserID: mockOwnerID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
+				items: &[]db.OrderItem{},
+			},
+			expected: &OrderDto{
+				ID:        mockID,
+				UserID:    mockOwnerID,
+				Status:    "PENDING",
+				Version:   1,
+				CreatedAt: createdAt.Format(time.RFC3339),
+				Items:     []OrderItemDto{},
+			},
+		},
+		{
+			name:        "Error - order not found",
+			mockStore:   &mockOrderStore{error: ordererrors.ErrOrderNotFound},
+			expectError: ordererrors.ErrOrderNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, nil, nil, nil, config.OrdersConfig{}, audit.NoopRecorder{})
+			// when
+			found, err := service.FindAnyByID(context.Background(), mockID)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, found)
+				return
+			}
+			require.NoError(t, err)
+			assertEqualOrderDto(t, tc.expected, found)
+		})
+	}
+}
+
 func Test_OrderService_FindOrdersByUserID(t *testing.T) {
 	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
 	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
 	createdAt := time.Now()
+	cet := time.FixedZone("CET", 60*60)
+	from := time.Date(2025, 1, 1, 1, 0, 0, 0, cet)
+	to := time.Date(2025, 2, 1, 1, 0, 0, 0, cet)
+	fromUTC := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	toUTC := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
 	testCases := []struct {
 		name         string
 		mockStore    *mockOrderStore
 		userID       uuid.UUID
+		createdFrom  time.Time
+		createdTo    time.Time
+		expectedFrom *time.Time
+		expectedTo   *time.Time
 		expectedList []OrderDto
 		expectError  error
 	}{
@@ -251,15 +445,139 @@ func Test_OrderService_FindOrdersByUserID(t *testing.T) {
 			expectedList: nil,
 			expectError:  ordererrors.ErrFailedToFindUserOrders,
 		},
+		{
+			name:         "Success - creation time range is passed to the store in UTC",
+			mockStore:    &mockOrderStore{orders: &[]db.Order{}},
+			userID:       mockUserID,
+			createdFrom:  from,
+			createdTo:    to,
+			expectedFrom: &fromUTC,
+			expectedTo:   &toUTC,
+			expectedList: []OrderDto{},
+		},
+		{
+			name:         "Success - open-ended creation time range",
+			mockStore:    &mockOrderStore{orders: &[]db.Order{}},
+			userID:       mockUserID,
+			createdFrom:  from,
+			expectedFrom: &fromUTC,
+			expectedList: []OrderDto{},
+		},
+		{
+			name:        "Error - from after to",
+			mockStore:   &mockOrderStore{orders: &[]db.Order{}},
+			userID:      mockUserID,
+			createdFrom: to,
+			createdTo:   from,
+			expectError: ordererrors.ErrInvalidTimeRange,
+		},
+		{
+			name:        "Error - from equals to",
+			mockStore:   &mockOrderStore{orders: &[]db.Order{}},
+			userID:      mockUserID,
+			createdFrom: from,
+			createdTo:   from,
+			expectError: ordererrors.ErrInvalidTimeRange,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, nil, nil, nil, config.OrdersConfig{}, audit.NoopRecorder{})
+			// when
+			found, err := service.FindOrdersByUserID(context.Background(), tc.userID, 0, 10, tc.createdFrom, tc.createdTo)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, found)
+				if tc.expectError == ordererrors.ErrInvalidTimeRange {
+					assert.Nil(t, tc.mockStore.findParams, "the store should not be queried")
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedList, *found)
+			assert.Equal(t, db.FindOrdersByUserIDParams{
+				UserID:      tc.userID,
+				CreatedFrom: tc.expectedFrom,
+				CreatedTo:   tc.expectedTo,
+				Limit:       10,
+				Offset:      0,
+			}, *tc.mockStore.findParams)
+		})
+	}
+}
+
+func Test_OrderService_FindAllOrders(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	createdAt := time.Now()
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name         string
+		mockStore    *mockOrderStore
+		statusFilter string
+		createdFrom  time.Time
+		createdTo    time.Time
+		expectedList []OrderDto
+		expectError  error
+	}{
+		{
+			name: "Success - orders of any status",
+			mockStore: &mockOrderStore{
+				orders: &[]db.Order{{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 1, CreatedAt: &createdAt}},
+			},
+			statusFilter: "",
+			expectedList: []OrderDto{{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 1, CreatedAt: createdAt.Format(time.RFC3339)}},
+		},
+		{
+			name: "Success - status filter is passed to the store",
+			mockStore: &mockOrderStore{
+				orders: &[]db.Order{{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 1, CreatedAt: &createdAt}},
+			},
+			statusFilter: "PENDING",
+			expectedList: []OrderDto{{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 1, CreatedAt: createdAt.Format(time.RFC3339)}},
+		},
+		{
+			name:         "Success - no orders",
+			mockStore:    &mockOrderStore{orders: &[]db.Order{}},
+			statusFilter: "COMPLETED",
+			expectedList: []OrderDto{},
+		},
+		{
+			name:         "Error - store error",
+			mockStore:    &mockOrderStore{error: ordererrors.ErrFailedToFindOrders},
+			statusFilter: "",
+			expectError:  ordererrors.ErrFailedToFindOrders,
+		},
+		{
+			name:         "Success - creation time range is passed to the store",
+			mockStore:    &mockOrderStore{orders: &[]db.Order{}},
+			statusFilter: "PENDING",
+			createdFrom:  from,
+			createdTo:    to,
+			expectedList: []OrderDto{},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore, nil, nil)
+			service := NewService(tc.mockStore, nil, nil, nil, config.OrdersConfig{}, audit.NoopRecorder{})
 			// when
-			found, err := service.FindOrdersByUserID(context.Background(), tc.userID, 0, 10)
+			found, err := service.FindAllOrders(context.Background(), 5, 10, tc.statusFilter, tc.createdFrom, tc.createdTo)
 			// then
+			require.NotNil(t, tc.mockStore.findAllParams)
+			expectedParams := db.FindAllOrdersParams{Status: tc.statusFilter, Limit: 10, Offset: 5}
+			if !tc.createdFrom.IsZero() {
+				expectedParams.CreatedFrom = &tc.createdFrom
+			}
+			if !tc.createdTo.IsZero() {
+				expectedParams.CreatedTo = &tc.createdTo
+			}
+			assert.Equal(t, expectedParams, *tc.mockStore.findAllParams)
 			if tc.expectError != nil {
 				assert.ErrorIs(t, err, tc.expectError)
 				assert.Nil(t, found)
@@ -271,11 +589,86 @@ func Test_OrderService_FindOrdersByUserID(t *testing.T) {
 	}
 }
 
+func Test_OrderService_SummaryForUser(t *testing.T) {
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	testCases := []struct {
+		name            string
+		mockStore       *mockOrderStore
+		expectedSummary *OrderSummaryDto
+		expectError     error
+	}{
+		{
+			name: "Success - totals of all statuses",
+			mockStore: &mockOrderStore{summary: []db.SummarizeOrdersByUserIDRow{
+				{Status: StatusCompleted, Currency: "USD", OrderCount: 2, TotalPrice: 3000},
+				{Status: StatusPending, Currency: "USD", OrderCount: 1, TotalPrice: 500},
+			}},
+			expectedSummary: &OrderSummaryDto{
+				TotalOrders: 3,
+				TotalSpent:  []CurrencyTotalDto{{Amount: money.New(3500, "USD"), Currency: "USD"}},
+				ByStatus: []OrderStatusSummaryDto{
+					{Status: StatusCompleted, Currency: "USD", Orders: 2, TotalPrice: money.New(3000, "USD")},
+					{Status: StatusPending, Currency: "USD", Orders: 1, TotalPrice: money.New(500, "USD")},
+				},
+			},
+		},
+		{
+			name: "Success - totals per currency",
+			mockStore: &mockOrderStore{summary: []db.SummarizeOrdersByUserIDRow{
+				{Status: StatusCompleted, Currency: "USD", OrderCount: 2, TotalPrice: 3000},
+				{Status: StatusCompleted, Currency: "EUR", OrderCount: 1, TotalPrice: 1200},
+				{Status: StatusPending, Currency: "EUR", OrderCount: 1, TotalPrice: 500},
+			}},
+			expectedSummary: &OrderSummaryDto{
+				TotalOrders: 4,
+				TotalSpent: []CurrencyTotalDto{
+					{Amount: money.New(1700, "EUR"), Currency: "EUR"},
+					{Amount: money.New(3000, "USD"), Currency: "USD"},
+				},
+				ByStatus: []OrderStatusSummaryDto{
+					{Status: StatusCompleted, Currency: "USD", Orders: 2, TotalPrice: money.New(3000, "USD")},
+					{Status: StatusCompleted, Currency: "EUR", Orders: 1, TotalPrice: money.New(1200, "EUR")},
+					{Status: StatusPending, Currency: "EUR", Orders: 1, TotalPrice: money.New(500, "EUR")},
+				},
+			},
+		},
+		{
+			name:            "Success - no orders",
+			mockStore:       &mockOrderStore{summary: []db.SummarizeOrdersByUserIDRow{}},
+			expectedSummary: &OrderSummaryDto{TotalSpent: []CurrencyTotalDto{}, ByStatus: []OrderStatusSummaryDto{}},
+		},
+		{
+			name:        "Error - store error",
+			mockStore:   &mockOrderStore{error: ordererrors.ErrFailedToSummarizeOrders},
+			expectError: ordererrors.ErrFailedToSummarizeOrders,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, nil, nil, nil, config.OrdersConfig{}, audit.NoopRecorder{})
+			// when
+			summary, err := service.SummaryForUser(context.Background(), mockUserID)
+			// then
+			assert.Equal(t, mockUserID, tc.mockStore.summaryUserID)
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, summary)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSummary, summary)
+		})
+	}
+}
+
 func Test_OrderService_Create(t *testing.T) {
 	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
 	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
 	ProductID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
 	OrderItemID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	authorizationAmount := money.New(100, "")
 
 	createdAt := time.Now()
 	testCases := []struct {
@@ -284,6 +677,7 @@ func Test_OrderService_Create(t *testing.T) {
 		productClient *ProductServiceClientMock
 		Timeout       time.Duration
 		publisher     *PublisherMock
+		cfg           config.OrdersConfig
 		order         OrderCreateDto
 		expected      *OrderDto
 		expectError   error
@@ -308,9 +702,9 @@ func Test_OrderService_Create(t *testing.T) {
 				error: nil,
 			},
 			publisher: &PublisherMock{error: nil},
-			order:     OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 1, Price: 100}}},
-			expected: &OrderDto{ID: mockID, UserID: userID, Status: "PENDING", Version: 1, CreatedAt: createdAt.Format(time.RFC3339),
-				Items: []OrderItemDto{{ID: OrderItemID, OrderID: mockID, ProductID: ProductID, Quantity: 1, Price: 100, CreatedAt: createdAt.Format(time.RFC3339)}}},
+			order:     OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 1, Price: money.New(100, "")}}, Email: "user@example.com"},
+			expected: &OrderDto{ID: mockID, UserID: userID, Status: "PENDING", Version: 1, AuthorizationAmount: &authorizationAmount, CreatedAt: createdAt.Format(time.RFC3339),
+				Items: []OrderItemDto{{ID: OrderItemID, OrderID: mockID, ProductID: ProductID, Quantity: 1, Price: money.New(100, ""), CreatedAt: createdAt.Format(time.RFC3339)}}},
 			expectError: nil,
 		},
 		{
@@ -333,9 +727,9 @@ func Test_OrderService_Create(t *testing.T) {
 				error: nil,
 			},
 			publisher: &PublisherMock{error: fmt.Errorf("oops, NATS is down")},
-			order:     OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 1, Price: 100}}},
-			expected: &OrderDto{ID: mockID, UserID: userID, Status: "PENDING", Version: 1, CreatedAt: createdAt.Format(time.RFC3339),
-				Items: []OrderItemDto{{ID: OrderItemID, OrderID: mockID, ProductID: ProductID, Quantity: 1, Price: 100, CreatedAt: createdAt.Format(time.RFC3339)}}},
+			order:     OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 1, Price: money.New(100, "")}}},
+			expected: &OrderDto{ID: mockID, UserID: userID, Status: "PENDING", Version: 1, AuthorizationAmount: &authorizationAmount, CreatedAt: createdAt.Format(time.RFC3339),
+				Items: []OrderItemDto{{ID: OrderItemID, OrderID: mockID, ProductID: ProductID, Quantity: 1, Price: money.New(100, ""), CreatedAt: createdAt.Format(time.RFC3339)}}},
 			expectError: nil,
 		},
 		{
@@ -355,7 +749,7 @@ func Test_OrderService_Create(t *testing.T) {
 				},
 				error: nil,
 			},
-			order:       OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 1, Price: 100}}},
+			order:       OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 1, Price: money.New(100, "")}}},
 			expected:    nil,
 			expectError: ordererrors.ErrCreateOrder,
 		},
@@ -373,16 +767,48 @@ func Test_OrderService_Create(t *testing.T) {
 				},
 				error: nil,
 			},
-			order:       OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 10, Price: 100}}},
+			order:       OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 10, Price: money.New(100, "")}}},
 			expectError: ordererrors.ErrInsufficientStock,
 		},
+		{
+			name: "Success - verified user when email verification is required",
+			mockStore: &mockOrderStore{
+				order: &db.Order{ID: mockID, UserID: userID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
+				items: &[]db.OrderItem{{ID: OrderItemID, OrderID: mockID, ProductID: ProductID, Quantity: 1, Price: 100, CreatedAt: &createdAt}},
+				error: nil,
+			},
+			productClient: &ProductServiceClientMock{
+				productResponse: &pb.GetProductResponse{
+					Products: []*pb.Product{{
+						Id:            ProductID.String(),
+						Name:          "Test Product",
+						Price:         100,
+						StockQuantity: 10,
+						Version:       1,
+					}},
+				},
+				error: nil,
+			},
+			publisher: &PublisherMock{error: nil},
+			cfg:       config.OrdersConfig{RequireVerifiedEmail: true},
+			order:     OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 1, Price: money.New(100, "")}}, EmailVerified: true},
+			expected: &OrderDto{ID: mockID, UserID: userID, Status: "PENDING", Version: 1, AuthorizationAmount: &authorizationAmount, CreatedAt: createdAt.Format(time.RFC3339),
+				Items: []OrderItemDto{{ID: OrderItemID, OrderID: mockID, ProductID: ProductID, Quantity: 1, Price: money.New(100, ""), CreatedAt: createdAt.Format(time.RFC3339)}}},
+			expectError: nil,
+		},
+		{
+			name:        "Error - unverified user when email verification is required",
+			cfg:         config.OrdersConfig{RequireVerifiedEmail: true},
+			order:       OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 1, Price: money.New(100, "")}}, EmailVerified: false},
+			expectError: ordererrors.ErrEmailNotVerified,
+		},
 		{
 			name: "Error - product service timeout",
 			productClient: &ProductServiceClientMock{
 				ServerTimeout: 3 * time.Second,
 			},
 			Timeout:     2 * time.Second,
-			order:       OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 10, Price: 100}}},
+			order:       OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: ProductID, Quantity: 10, Price: money.New(100, "")}}},
 			expectError: errContextDeadlineExceeded,
 		},
 	}
@@ -390,7 +816,7 @@ func Test_OrderService_Create(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore, tc.productClient, tc.publisher)
+			service := NewService(tc.mockStore, tc.productClient, nil, tc.publisher, tc.cfg, audit.NoopRecorder{})
 			opCtx, cancel := context.WithTimeout(context.Background(), tc.Timeout)
 			defer cancel()
 			// when
@@ -404,81 +830,1081 @@ func Test_OrderService_Create(t *testing.T) {
 			}
 			require.NoError(t, err)
 			assert.Equal(t, tc.expected, created)
+			assert.Equal(t, totalPrice(tc.mockStore.createItems), tc.mockStore.createParams.TotalPrice, "total price should be the sum of the item prices")
+			if tc.publisher != nil {
+				for _, event := range tc.publisher.published {
+					createdEvent, ok := event.(events.OrderCreatedEvent)
+					require.True(t, ok, "published event should be OrderCreatedEvent")
+					assert.NotEqual(t, uuid.Nil, createdEvent.EventID, "published event should have an ID")
+					assert.Equal(t, tc.order.Email, createdEvent.UserEmail)
+				}
+			}
 		})
 	}
 }
 
-func Test_OrderService_Update(t *testing.T) {
-	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
-	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
-	createdAt := time.Now()
-
+func Test_OrderService_Create_ProductNotFound(t *testing.T) {
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	knownID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	unknownID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	productClient := &ProductServiceClientMock{
+		productResponse: &pb.GetProductResponse{
+			Products: []*pb.Product{{Id: knownID.String(), Name: "Known Product", Price: 100, StockQuantity: 10, Version: 1}},
+		},
+	}
 	testCases := []struct {
-		name        string
-		mockStore   *mockOrderStore
-		order       OrderUpdateDto
-		expected    *OrderDto
-		expectError error
+		name  string
+		items []OrderItemCreateDto
 	}{
 		{
-			name: "Success - order updated",
-			mockStore: &mockOrderStore{
-				order:       &db.Order{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
-				error:       nil,
-				updateOrder: &db.Order{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 2, CreatedAt: &createdAt},
-				updateError: nil,
-			},
-			order:       OrderUpdateDto{ID: mockID, Status: "PENDING", Version: 1},
-			expected:    &OrderDto{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 2, CreatedAt: createdAt.Format(time.RFC3339)},
-			expectError: nil,
-		},
-		{
-			name: "Error - order not found",
-			mockStore: &mockOrderStore{
-				error: ordererrors.ErrOrderNotFound,
-			},
-			order:       OrderUpdateDto{ID: mockID, Status: "PENDING", Version: 1},
-			expected:    nil,
-			expectError: ordererrors.ErrOrderNotFound,
-		},
-		{
-			name: "Error - store error",
-			mockStore: &mockOrderStore{
-				order:       &db.Order{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
-				error:       nil,
-				updateOrder: nil,
-				updateError: ordererrors.ErrUpdateOrder,
-			},
-			order:       OrderUpdateDto{ID: mockID, Status: "PENDING", Version: 1},
-			expected:    nil,
-			expectError: ordererrors.ErrUpdateOrder,
+			name:  "single unknown product",
+			items: []OrderItemCreateDto{{ProductID: unknownID, Quantity: 1}},
 		},
 		{
-			name: "Error - access denied",
-			mockStore: &mockOrderStore{
-				order: &db.Order{ID: mockID, UserID: uuid.New(), Status: "PENDING", Version: 1, CreatedAt: &createdAt},
-				error: nil,
-			},
-			order:       OrderUpdateDto{ID: mockID, Status: "PENDING", Version: 1},
-			expected:    nil,
-			expectError: ordererrors.ErrAccessDenied,
+			name:  "unknown product among several products",
+			items: []OrderItemCreateDto{{ProductID: knownID, Quantity: 1}, {ProductID: unknownID, Quantity: 1}},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore, nil, nil)
+			mockStore := &mockOrderStore{}
+			service := NewService(mockStore, productClient, nil, &PublisherMock{}, config.OrdersConfig{}, audit.NoopRecorder{})
 			// when
-			updated, err := service.Update(context.Background(), mockUserID, tc.order)
+			created, err := service.Create(context.Background(), OrderCreateDto{UserID: userID, Status: "PENDING", Items: tc.items})
 			// then
-			if tc.expectError != nil {
-				assert.ErrorIs(t, err, tc.expectError)
-				assert.Nil(t, updated)
-				return
-			}
-			require.NoError(t, err)
+			var notFoundErr *ordererrors.ProductNotFoundError
+			require.ErrorAs(t, err, &notFoundErr)
+			assert.ErrorIs(t, err, ordererrors.ErrProductNotFound)
+			assert.Equal(t, []string{unknownID.String()}, notFoundErr.ProductIDs, "the unknown product should be reported the same way")
+			assert.Nil(t, created)
+			assert.Nil(t, mockStore.createItems, "order should not be created")
+		})
+	}
+}
+
+func Test_OrderService_Create_AuthorizationAmount(t *testing.T) {
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	productID1, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	productID2, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	createdAt := time.Now()
+
+	productClient := &ProductServiceClientMock{
+		productResponse: &pb.GetProductResponse{
+			Products: []*pb.Product{
+				{Id: productID1.String(), Price: 1000, StockQuantity: 10, Version: 1},
+				{Id: productID2.String(), Price: 555, StockQuantity: 10, Version: 1},
+			},
+		},
+	}
+	// the items total is 2 * 1000 + 1 * 555 = 2555
+	order := OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{
+		{ProductID: productID1, Quantity: 2},
+		{ProductID: productID2, Quantity: 1},
+	}}
+
+	testCases := []struct {
+		name           string
+		cfg            config.OrdersConfig
+		expectedAmount int64
+	}{
+		{
+			name:           "no tax and shipping",
+			cfg:            config.OrdersConfig{},
+			expectedAmount: 2555,
+		},
+		{
+			name:           "tax is rounded half up",
+			cfg:            config.OrdersConfig{TaxRate: 2000}, // 20% of 2555 is 511
+			expectedAmount: 2555 + 511,
+		},
+		{
+			name:           "tax rounding of a fraction",
+			cfg:            config.OrdersConfig{TaxRate: 750}, // 7.5% of 2555 is 191.625
+			expectedAmount: 2555 + 192,
+		},
+		{
+			name:           "shipping fee below the free shipping threshold",
+			cfg:            config.OrdersConfig{TaxRate: 1000, ShippingFee: 499, FreeShippingFrom: 5000},
+			expectedAmount: 2555 + 256 + 499,
+		},
+		{
+			name:           "free shipping from the threshold",
+			cfg:            config.OrdersConfig{TaxRate: 1000, ShippingFee: 499, FreeShippingFrom: 2555},
+			expectedAmount: 2555 + 256,
+		},
+		{
+			name:           "shipping fee without free shipping",
+			cfg:            config.OrdersConfig{ShippingFee: 499},
+			expectedAmount: 2555 + 499,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockStore := &mockOrderStore{
+				order: &db.Order{ID: uuid.New(), UserID: userID, Status: "PENDING", Version: 1, TotalPrice: 2555, CreatedAt: &createdAt},
+				items: &[]db.OrderItem{},
+			}
+			service := NewService(mockStore, productClient, nil, &PublisherMock{}, tc.cfg, audit.NoopRecorder{})
+			// when
+			created, err := service.Create(context.Background(), order)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, int64(2555), created.TotalPrice.Amount, "total price should not include tax and shipping")
+			require.NotNil(t, created.AuthorizationAmount)
+			assert.Equal(t, tc.expectedAmount, created.AuthorizationAmount.Amount)
+		})
+	}
+}
+
+func Test_OrderService_Create_Currency(t *testing.T) {
+	// given
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	usdID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	legacyID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174004")
+	createdAt := time.Now()
+	productClient := &ProductServiceClientMock{
+		productResponse: &pb.GetProductResponse{
+			Products: []*pb.Product{
+				{Id: usdID.String(), Price: 200, StockQuantity: 10, Version: 1, Currency: "USD"},
+				// a product of a Product service not reporting the currency yet
+				{Id: legacyID.String(), Price: 300, StockQuantity: 10, Version: 1},
+			},
+		},
+	}
+	mockStore := &mockOrderStore{
+		order: &db.Order{ID: uuid.New(), UserID: userID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
+		items: &[]db.OrderItem{},
+	}
+	service := NewService(mockStore, productClient, nil, &PublisherMock{}, config.OrdersConfig{}, audit.NoopRecorder{})
+	order := OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{
+		{ProductID: usdID, Quantity: 1},
+		{ProductID: legacyID, Quantity: 1},
+	}}
+
+	// when
+	_, err := service.Create(context.Background(), order)
+
+	// then
+	require.NoError(t, err)
+	currencies := make(map[uuid.UUID]string, len(mockStore.createItems))
+	for _, item := range mockStore.createItems {
+		currencies[item.ProductID] = item.Currency
+	}
+	assert.Equal(t, map[uuid.UUID]string{usdID: "USD", legacyID: DefaultCurrency}, currencies,
+		"every item should capture the currency of its product")
+}
+
+func Test_OrderService_Create_MixedCurrencies(t *testing.T) {
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	firstID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	secondID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	createdAt := time.Now()
+	testCases := []struct {
+		name           string
+		firstCurrency  string
+		secondCurrency string
+		expectError    error
+	}{
+		{name: "Success - single currency", firstCurrency: "EUR", secondCurrency: "EUR"},
+		{name: "Success - product without a currency priced in the default currency", firstCurrency: DefaultCurrency, secondCurrency: ""},
+		{name: "Error - different currencies", firstCurrency: "EUR", secondCurrency: "USD", expectError: ordererrors.ErrMixedCurrencies},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			productClient := &ProductServiceClientMock{
+				productResponse: &pb.GetProductResponse{
+					Products: []*pb.Product{
+						{Id: firstID.String(), Price: 100, StockQuantity: 10, Version: 1, Currency: tc.firstCurrency},
+						{Id: secondID.String(), Price: 200, StockQuantity: 10, Version: 1, Currency: tc.secondCurrency},
+					},
+				},
+			}
+			mockStore := &mockOrderStore{
+				order: &db.Order{ID: uuid.New(), UserID: userID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
+				items: &[]db.OrderItem{},
+			}
+			publisher := &PublisherMock{}
+			service := NewService(mockStore, productClient, nil, publisher, config.OrdersConfig{}, audit.NoopRecorder{})
+			order := OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{
+				{ProductID: firstID, Quantity: 1},
+				{ProductID: secondID, Quantity: 1},
+			}}
+
+			// when
+			created, err := service.Create(context.Background(), order)
+
+			// then
+			if tc.expectError != nil {
+				require.ErrorIs(t, err, tc.expectError)
+				assert.Contains(t, err.Error(), "EUR, USD", "error should list the currencies")
+				assert.Nil(t, created)
+				assert.Nil(t, mockStore.createItems, "order should not be stored")
+				assert.Empty(t, publisher.published, "no event should be published")
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, created)
+			assert.Len(t, mockStore.createItems, 2)
+		})
+	}
+}
+
+// stockQuantities returns the quantities of the stock items per product ID.
+func stockQuantities(items []*pb.StockItem) map[string]int32 {
+	if items == nil {
+		return nil
+	}
+	quantities := make(map[string]int32, len(items))
+	for _, item := range items {
+		quantities[item.GetProductId()] = item.GetQuantity()
+	}
+	return quantities
+}
+
+func Test_OrderService_Create_StockReservation(t *testing.T) {
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	firstID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	secondID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	createdAt := time.Now()
+	errStore := errors.New("store error")
+	errUnavailable := status.Error(codes.Unavailable, "product service unavailable")
+	quantities := map[string]int32{firstID.String(): 2, secondID.String(): 3}
+	testCases := []struct {
+		name             string
+		reserveStock     bool
+		storeError       error
+		reserveError     error
+		releaseError     error
+		expectError      error
+		expectedReserved map[string]int32
+		expectedReleased map[string]int32
+		expectedStored   bool
+	}{
+		{name: "Success - stock reserved", reserveStock: true, expectedReserved: quantities, expectedStored: true},
+		{name: "Success - reservation disabled", expectedStored: true},
+		{
+			name:             "Error - store fails, the reserved stock is released",
+			reserveStock:     true,
+			storeError:       errStore,
+			expectError:      errStore,
+			expectedReserved: quantities,
+			expectedReleased: quantities,
+			expectedStored:   true,
+		},
+		{
+			name:             "Error - store fails and the release fails, the store error is returned",
+			reserveStock:     true,
+			storeError:       errStore,
+			releaseError:     errUnavailable,
+			expectError:      errStore,
+			expectedReserved: quantities,
+			expectedReleased: quantities,
+			expectedStored:   true,
+		},
+		{
+			name:           "Error - store fails, nothing is released if the reservation is disabled",
+			storeError:     errStore,
+			expectError:    errStore,
+			expectedStored: true,
+		},
+		{
+			name:             "Error - stock taken by another order",
+			reserveStock:     true,
+			reserveError:     status.Error(codes.FailedPrecondition, "insufficient stock"),
+			expectError:      ordererrors.ErrInsufficientStock,
+			expectedReserved: quantities,
+		},
+		{
+			name:             "Error - reservation fails",
+			reserveStock:     true,
+			reserveError:     errUnavailable,
+			expectError:      errUnavailable,
+			expectedReserved: quantities,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			productClient := &ProductServiceClientMock{
+				productResponse: &pb.GetProductResponse{
+					Products: []*pb.Product{
+						{Id: firstID.String(), Price: 100, StockQuantity: 10, Version: 1},
+						{Id: secondID.String(), Price: 200, StockQuantity: 10, Version: 1},
+					},
+				},
+				reserveError: tc.reserveError,
+				releaseError: tc.releaseError,
+			}
+			mockStore := &mockOrderStore{
+				order: &db.Order{ID: uuid.New(), UserID: userID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
+				items: &[]db.OrderItem{},
+				error: tc.storeError,
+			}
+			service := NewService(mockStore, productClient, nil, &PublisherMock{}, config.OrdersConfig{ReserveStock: tc.reserveStock}, audit.NoopRecorder{})
+			order := OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{
+				{ProductID: firstID, Quantity: 2},
+				{ProductID: secondID, Quantity: 3},
+			}}
+
+			// when
+			created, err := service.Create(context.Background(), order)
+
+			// then
+			if tc.expectError != nil {
+				require.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, created)
+			} else {
+				require.NoError(t, err)
+				assert.NotNil(t, created)
+			}
+			assert.Equal(t, tc.expectedReserved, stockQuantities(productClient.reserved))
+			assert.Equal(t, tc.expectedReleased, stockQuantities(productClient.released), "every reserved item should be released")
+			assert.Equal(t, tc.expectedStored, mockStore.createItems != nil, "the order should be stored only after the reservation")
+		})
+	}
+}
+
+func Test_OrderService_Create_ProductServiceUnavailable(t *testing.T) {
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	firstID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	secondID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	errUnavailable := status.Error(codes.Unavailable, "connection refused")
+	products := &pb.GetProductResponse{
+		Products: []*pb.Product{
+			{Id: firstID.String(), Price: 100, StockQuantity: 10, Version: 1},
+			{Id: secondID.String(), Price: 200, StockQuantity: 10, Version: 1},
+		},
+	}
+	testCases := []struct {
+		name          string
+		productClient *ProductServiceClientMock
+		cfg           config.OrdersConfig
+		items         []OrderItemCreateDto
+	}{
+		{
+			name:          "single product, connection refused",
+			productClient: &ProductServiceClientMock{error: errUnavailable},
+			items:         []OrderItemCreateDto{{ProductID: firstID, Quantity: 1}},
+		},
+		{
+			name:          "multiple products, connection refused",
+			productClient: &ProductServiceClientMock{error: errUnavailable},
+			items:         []OrderItemCreateDto{{ProductID: firstID, Quantity: 1}, {ProductID: secondID, Quantity: 1}},
+		},
+		{
+			name:          "circuit breaker is open",
+			productClient: &ProductServiceClientMock{error: gobreaker.ErrOpenState},
+			items:         []OrderItemCreateDto{{ProductID: firstID, Quantity: 1}},
+		},
+		{
+			name:          "circuit breaker is half-open",
+			productClient: &ProductServiceClientMock{error: gobreaker.ErrTooManyRequests},
+			items:         []OrderItemCreateDto{{ProductID: firstID, Quantity: 1}, {ProductID: secondID, Quantity: 1}},
+		},
+		{
+			name:          "stock reservation, circuit breaker is open",
+			productClient: &ProductServiceClientMock{productResponse: products, reserveError: gobreaker.ErrOpenState},
+			cfg:           config.OrdersConfig{ReserveStock: true},
+			items:         []OrderItemCreateDto{{ProductID: firstID, Quantity: 1}, {ProductID: secondID, Quantity: 1}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockStore := &mockOrderStore{}
+			service := NewService(mockStore, tc.productClient, nil, &PublisherMock{}, tc.cfg, audit.NoopRecorder{})
+
+			// when
+			created, err := service.Create(context.Background(), OrderCreateDto{UserID: userID, Status: "PENDING", Items: tc.items})
+
+			// then
+			require.Error(t, err)
+			assert.Nil(t, created)
+			assert.Equal(t, codes.Unavailable, status.Code(err), "an unreachable Product service should be reported as Unavailable")
+			assert.NotEqual(t, codes.DeadlineExceeded, status.Code(err), "an unreachable Product service is not a timeout")
+			assert.Nil(t, mockStore.createParams, "the order should not be stored")
+		})
+	}
+}
+
+func Test_OrderService_Create_Status(t *testing.T) {
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	productID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	createdAt := time.Now()
+	testCases := []struct {
+		name        string
+		cfg         config.OrdersConfig
+		status      string
+		expectError error
+	}{
+		{name: "Success - pending by default", status: "PENDING"},
+		{name: "Success - status is case-insensitive", status: "pending"},
+		{name: "Error - completed by default", status: "COMPLETED", expectError: ordererrors.ErrInvalidStatus},
+		{name: "Error - unknown status", status: "NEW", expectError: ordererrors.ErrInvalidStatus},
+		{name: "Success - configured status", cfg: config.OrdersConfig{CreateStatuses: []string{"PENDING", "COMPLETED"}}, status: "completed"},
+		{name: "Error - pending is not configured", cfg: config.OrdersConfig{CreateStatuses: []string{"COMPLETED"}}, status: "PENDING", expectError: ordererrors.ErrInvalidStatus},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			productClient := &ProductServiceClientMock{
+				productResponse: &pb.GetProductResponse{
+					Products: []*pb.Product{{Id: productID.String(), Price: 100, StockQuantity: 10, Version: 1}},
+				},
+			}
+			mockStore := &mockOrderStore{
+				order: &db.Order{ID: uuid.New(), UserID: userID, Status: tc.status, Version: 1, CreatedAt: &createdAt},
+				items: &[]db.OrderItem{},
+			}
+			service := NewService(mockStore, productClient, nil, &PublisherMock{}, tc.cfg, audit.NoopRecorder{})
+
+			// when
+			created, err := service.Create(context.Background(), OrderCreateDto{UserID: userID, Status: tc.status, Items: []OrderItemCreateDto{{ProductID: productID, Quantity: 1}}})
+
+			// then
+			if tc.expectError != nil {
+				require.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, created)
+				assert.Nil(t, mockStore.createParams, "the order should not be stored")
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, mockStore.createParams)
+			assert.Equal(t, tc.status, mockStore.createParams.Status)
+		})
+	}
+}
+
+func Test_OrderService_Create_InsufficientStock(t *testing.T) {
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	productID1, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	productID2, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	restockAt := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	productClient := &ProductServiceClientMock{
+		productResponse: &pb.GetProductResponse{
+			Products: []*pb.Product{
+				{Id: productID1.String(), Price: 100, StockQuantity: 0, Version: 1, RestockAt: restockAt.Format(time.RFC3339)},
+				{Id: productID2.String(), Price: 200, StockQuantity: 1, Version: 1},
+			},
+		},
+	}
+	order := OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{
+		{ProductID: productID1, Quantity: 2, Price: money.New(200, "")},
+		{ProductID: productID2, Quantity: 3, Price: money.New(600, "")},
+	}}
+
+	testCases := []struct {
+		name          string
+		cfg           config.OrdersConfig
+		expectedItems []ordererrors.InsufficientStockItem
+	}{
+		{
+			name: "restock ETA is returned when available",
+			cfg:  config.OrdersConfig{RestockETA: true},
+			expectedItems: []ordererrors.InsufficientStockItem{
+				{ProductID: productID1.String(), Available: 0, Requested: 2, RestockETA: &restockAt},
+				{ProductID: productID2.String(), Available: 1, Requested: 3},
+			},
+		},
+		{
+			name: "restock ETA is omitted when disabled",
+			cfg:  config.OrdersConfig{RestockETA: false},
+			expectedItems: []ordererrors.InsufficientStockItem{
+				{ProductID: productID1.String(), Available: 0, Requested: 2},
+				{ProductID: productID2.String(), Available: 1, Requested: 3},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(&mockOrderStore{}, productClient, nil, &PublisherMock{}, tc.cfg, audit.NoopRecorder{})
+			// when
+			created, err := service.Create(context.Background(), order)
+			// then
+			assert.Nil(t, created)
+			require.ErrorIs(t, err, ordererrors.ErrInsufficientStock)
+			var stockErr *ordererrors.InsufficientStockError
+			require.ErrorAs(t, err, &stockErr)
+			assert.Equal(t, tc.expectedItems, stockErr.Items)
+		})
+	}
+}
+
+// stocklessProductClient returns the products without their stock, like the product cache.
+type stocklessProductClient struct {
+	*ProductServiceClientMock
+}
+
+func (stocklessProductClient) ReportsStock() bool {
+	return false
+}
+
+func Test_OrderService_Create_StockNotReported(t *testing.T) {
+	// given
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	productID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	createdAt := time.Now()
+	productClient := &ProductServiceClientMock{
+		productResponse: &pb.GetProductResponse{
+			Products: []*pb.Product{{Id: productID.String(), Price: 100, Version: 1}},
+		},
+	}
+	mockStore := &mockOrderStore{
+		order: &db.Order{ID: uuid.New(), UserID: userID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
+		items: &[]db.OrderItem{},
+	}
+	service := NewService(mockStore, stocklessProductClient{productClient}, nil, &PublisherMock{}, config.OrdersConfig{ReserveStock: true}, audit.NoopRecorder{})
+	order := OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{{ProductID: productID, Quantity: 2}}}
+	// when
+	created, err := service.Create(context.Background(), order)
+	// then
+	require.NoError(t, err, "the stock should be checked by the reservation only")
+	assert.NotNil(t, created)
+	assert.Equal(t, map[string]int32{productID.String(): 2}, stockQuantities(productClient.reserved))
+}
+
+func Test_OrderService_Create_DeletedProduct(t *testing.T) {
+	// given
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	liveID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	deletedID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	productClient := &ProductServiceClientMock{
+		productResponse: &pb.GetProductResponse{
+			Products: []*pb.Product{
+				{Id: liveID.String(), Price: 100, StockQuantity: 10, Version: 1},
+				{Id: deletedID.String(), Price: 200, StockQuantity: 10, Version: 2, IsDeleted: true},
+			},
+		},
+	}
+	order := OrderCreateDto{UserID: userID, Status: "PENDING", Items: []OrderItemCreateDto{
+		{ProductID: liveID, Quantity: 1, Price: money.New(100, "")},
+		{ProductID: deletedID, Quantity: 1, Price: money.New(200, "")},
+	}}
+	service := NewService(&mockOrderStore{}, productClient, nil, &PublisherMock{}, config.OrdersConfig{}, audit.NoopRecorder{})
+
+	// when
+	created, err := service.Create(context.Background(), order)
+
+	// then
+	assert.Nil(t, created)
+	require.ErrorIs(t, err, ordererrors.ErrInsufficientStock)
+	var stockErr *ordererrors.InsufficientStockError
+	require.ErrorAs(t, err, &stockErr)
+	assert.Equal(t, []ordererrors.InsufficientStockItem{
+		{ProductID: deletedID.String(), Requested: 1, Deleted: true},
+	}, stockErr.Items)
+	assert.Contains(t, stockErr.Error(), "product "+deletedID.String()+" is deleted")
+}
+
+func Test_OrderService_Update(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	createdAt := time.Now()
+
+	testCases := []struct {
+		name              string
+		mockStore         *mockOrderStore
+		order             OrderUpdateDto
+		expected          *OrderDto
+		expectError       error
+		expectedPublished int // number of published OrderCompletedEvents
+	}{
+		{
+			name: "Success - order updated",
+			mockStore: &mockOrderStore{
+				order:       &db.Order{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
+				error:       nil,
+				updateOrder: &db.Order{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 2, CreatedAt: &createdAt},
+				updateError: nil,
+			},
+			order:       OrderUpdateDto{ID: mockID, Status: "PENDING", Version: 1},
+			expected:    &OrderDto{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 2, CreatedAt: createdAt.Format(time.RFC3339)},
+			expectError: nil,
+		},
+		{
+			name: "Success - order completed, event published",
+			mockStore: &mockOrderStore{
+				order:       &db.Order{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
+				updateOrder: &db.Order{ID: mockID, UserID: mockUserID, Status: StatusCompleted, Version: 2, CreatedAt: &createdAt},
+			},
+			order:             OrderUpdateDto{ID: mockID, Status: StatusCompleted, Version: 1, Email: "user@example.com"},
+			expected:          &OrderDto{ID: mockID, UserID: mockUserID, Status: StatusCompleted, Version: 2, CreatedAt: createdAt.Format(time.RFC3339)},
+			expectedPublished: 1,
+		},
+		{
+			name: "Success - already completed order, event not published again",
+			mockStore: &mockOrderStore{
+				order:       &db.Order{ID: mockID, UserID: mockUserID, Status: StatusCompleted, Version: 2, CreatedAt: &createdAt},
+				updateOrder: &db.Order{ID: mockID, UserID: mockUserID, Status: StatusCompleted, Version: 3, CreatedAt: &createdAt},
+			},
+			order:             OrderUpdateDto{ID: mockID, Status: StatusCompleted, Version: 2},
+			expected:          &OrderDto{ID: mockID, UserID: mockUserID, Status: StatusCompleted, Version: 3, CreatedAt: createdAt.Format(time.RFC3339)},
+			expectedPublished: 0,
+		},
+		{
+			name: "Error - order not found",
+			mockStore: &mockOrderStore{
+				error: ordererrors.ErrOrderNotFound,
+			},
+			order:       OrderUpdateDto{ID: mockID, Status: "PENDING", Version: 1},
+			expected:    nil,
+			expectError: ordererrors.ErrOrderNotFound,
+		},
+		{
+			name: "Error - store error",
+			mockStore: &mockOrderStore{
+				order:       &db.Order{ID: mockID, UserID: mockUserID, Status: "PENDING", Version: 1, CreatedAt: &createdAt},
+				error:       nil,
+				updateOrder: nil,
+				updateError: ordererrors.ErrUpdateOrder,
+			},
+			order:       OrderUpdateDto{ID: mockID, Status: "PENDING", Version: 1},
+			expected:    nil,
+			expectError: ordererrors.ErrUpdateOrder,
+		},
+		{
+			name: "Error - access denied",
+			mockStore: &mockOrderStore{
+				order: &db.Order{ID: mockID, UserID: uuid.New(), Status: "PENDING", Version: 1, CreatedAt: &createdAt},
+				error: nil,
+			},
+			order:       OrderUpdateDto{ID: mockID, Status: "PENDING", Version: 1},
+			expected:    nil,
+			expectError: ordererrors.ErrAccessDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			publisher := &PublisherMock{}
+			service := NewService(tc.mockStore, nil, nil, publisher, config.OrdersConfig{}, audit.NoopRecorder{})
+			// when
+			updated, err := service.Update(context.Background(), mockUserID, tc.order)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, updated)
+				assert.Empty(t, publisher.published)
+				return
+			}
+			require.NoError(t, err)
 			assert.Equal(t, tc.expected, updated)
+			require.Len(t, publisher.published, tc.expectedPublished)
+			for _, event := range publisher.published {
+				completed, ok := event.(events.OrderCompletedEvent)
+				require.True(t, ok, "published event should be OrderCompletedEvent")
+				assert.NotEqual(t, uuid.Nil, completed.EventID, "published event should have an ID")
+				assert.Equal(t, messaging.OrdersCompletedSubject, completed.Subject())
+				assert.Equal(t, mockID, completed.OrderID)
+				assert.Equal(t, mockUserID, completed.UserID)
+				assert.Equal(t, tc.order.Email, completed.UserEmail)
+				assert.False(t, completed.CompletedAt.IsZero())
+			}
+		})
+	}
+}
+
+func Test_OrderService_UpdateItems(t *testing.T) {
+	orderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	productID1, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	productID2, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	createdAt := time.Now()
+	pending := &db.Order{ID: orderID, UserID: userID, Status: StatusPending, Version: 1, CreatedAt: &createdAt}
+
+	testCases := []struct {
+		name          string
+		order         *db.Order
+		products      []*pb.Product
+		items         []OrderItemUpdateDto
+		expectedItems []db.CreateOrderItemParams
+		expectError   error
+	}{
+		{
+			name:  "Success - item added",
+			order: pending,
+			products: []*pb.Product{
+				{Id: productID1.String(), Price: 100, StockQuantity: 10, Version: 1},
+				{Id: productID2.String(), Price: 250, StockQuantity: 5, Version: 1},
+			},
+			items: []OrderItemUpdateDto{{ProductID: productID1, Quantity: 2}, {ProductID: productID2, Quantity: 1}},
+			expectedItems: []db.CreateOrderItemParams{
+				{ProductID: productID1, Quantity: 2, PricePerItem: 100, Price: 200, Currency: DefaultCurrency},
+				{ProductID: productID2, Quantity: 1, PricePerItem: 250, Price: 250, Currency: DefaultCurrency},
+			},
+		},
+		{
+			name:  "Success - item removed",
+			order: pending,
+			products: []*pb.Product{
+				{Id: productID1.String(), Price: 100, StockQuantity: 10, Version: 1},
+			},
+			items: []OrderItemUpdateDto{{ProductID: productID1, Quantity: 3}},
+			expectedItems: []db.CreateOrderItemParams{
+				{ProductID: productID1, Quantity: 3, PricePerItem: 100, Price: 300, Currency: DefaultCurrency},
+			},
+		},
+		{
+			name:  "Error - insufficient stock",
+			order: pending,
+			products: []*pb.Product{
+				{Id: productID1.String(), Price: 100, StockQuantity: 10, Version: 1},
+				{Id: productID2.String(), Price: 250, StockQuantity: 1, Version: 1},
+			},
+			items:       []OrderItemUpdateDto{{ProductID: productID1, Quantity: 2}, {ProductID: productID2, Quantity: 4}},
+			expectError: ordererrors.ErrInsufficientStock,
+		},
+		{
+			name:        "Error - order is not pending",
+			order:       &db.Order{ID: orderID, UserID: userID, Status: StatusCompleted, Version: 2, CreatedAt: &createdAt},
+			items:       []OrderItemUpdateDto{{ProductID: productID1, Quantity: 1}},
+			expectError: ordererrors.ErrOrderNotPending,
+		},
+		{
+			name:        "Error - access denied",
+			order:       &db.Order{ID: orderID, UserID: uuid.New(), Status: StatusPending, Version: 1, CreatedAt: &createdAt},
+			items:       []OrderItemUpdateDto{{ProductID: productID1, Quantity: 1}},
+			expectError: ordererrors.ErrAccessDenied,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			store := &mockOrderStore{order: tc.order}
+			productClient := &ProductServiceClientMock{productResponse: &pb.GetProductResponse{Products: tc.products}}
+			service := NewService(store, productClient, nil, &PublisherMock{}, config.OrdersConfig{}, audit.NoopRecorder{})
+			// when
+			updated, err := service.UpdateItems(context.Background(), userID, orderID, tc.items, tc.order.Version)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, updated)
+				assert.Nil(t, store.updatedItems, "order items should not be modified")
+				return
+			}
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expectedItems, store.updatedItems)
+			assert.Equal(t, tc.order.Version+1, updated.Version)
+			assert.Equal(t, totalPrice(tc.expectedItems), updated.TotalPrice.Amount, "total price should be recomputed")
+			require.Len(t, updated.Items, len(tc.expectedItems))
+			for _, item := range updated.Items {
+				assert.Equal(t, orderID, item.OrderID)
+			}
+		})
+	}
+}
+
+func Test_OrderService_UpdateItems_StockReservation(t *testing.T) {
+	orderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	firstID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	secondID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	thirdID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174004")
+	createdAt := time.Now()
+	errStore := errors.New("store error")
+	// the order has 2 of the first product and 3 of the second one, the first product has only 3 more in stock
+	previous := []db.OrderItem{
+		{OrderID: orderID, ProductID: firstID, Quantity: 2, PricePerItem: 100, Price: 200, Currency: DefaultCurrency},
+		{OrderID: orderID, ProductID: secondID, Quantity: 3, PricePerItem: 200, Price: 600, Currency: DefaultCurrency},
+	}
+	changed := []OrderItemUpdateDto{{ProductID: firstID, Quantity: 5}, {ProductID: thirdID, Quantity: 1}}
+	testCases := []struct {
+		name             string
+		reserveStock     bool
+		items            []OrderItemUpdateDto
+		storeError       error
+		reserveError     error
+		expectError      error
+		expectedReserved map[string]int32
+		expectedReleased map[string]int32
+		expectedStored   bool
+	}{
+		{
+			name:             "Success - increased and added quantities reserved, removed quantities released",
+			reserveStock:     true,
+			items:            changed,
+			expectedReserved: map[string]int32{firstID.String(): 3, thirdID.String(): 1},
+			expectedReleased: map[string]int32{secondID.String(): 3},
+			expectedStored:   true,
+		},
+		{
+			name:             "Success - decreased quantity released",
+			reserveStock:     true,
+			items:            []OrderItemUpdateDto{{ProductID: firstID, Quantity: 1}, {ProductID: secondID, Quantity: 3}},
+			expectedReleased: map[string]int32{firstID.String(): 1},
+			expectedStored:   true,
+		},
+		{
+			name:           "Success - reservation disabled",
+			items:          []OrderItemUpdateDto{{ProductID: firstID, Quantity: 3}},
+			expectedStored: true,
+		},
+		{
+			name:             "Error - store fails, the reserved stock is released",
+			reserveStock:     true,
+			items:            changed,
+			storeError:       errStore,
+			expectError:      errStore,
+			expectedReserved: map[string]int32{firstID.String(): 3, thirdID.String(): 1},
+			expectedReleased: map[string]int32{firstID.String(): 3, thirdID.String(): 1},
+			expectedStored:   true,
+		},
+		{
+			name:             "Error - stock taken by another order",
+			reserveStock:     true,
+			items:            changed,
+			reserveError:     status.Error(codes.FailedPrecondition, "insufficient stock"),
+			expectError:      ordererrors.ErrInsufficientStock,
+			expectedReserved: map[string]int32{firstID.String(): 3, thirdID.String(): 1},
+		},
+		{
+			name:        "Error - without the reservation the stored quantities are not available to the order",
+			items:       changed,
+			expectError: ordererrors.ErrInsufficientStock,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			productClient := &ProductServiceClientMock{
+				productResponse: &pb.GetProductResponse{
+					Products: []*pb.Product{
+						{Id: firstID.String(), Price: 100, StockQuantity: 3, Version: 1},
+						{Id: secondID.String(), Price: 200, StockQuantity: 10, Version: 1},
+						{Id: thirdID.String(), Price: 300, StockQuantity: 10, Version: 1},
+					},
+				},
+				reserveError: tc.reserveError,
+			}
+			mockStore := &mockOrderStore{
+				order:       &db.Order{ID: orderID, UserID: userID, Status: StatusPending, Version: 1, CreatedAt: &createdAt},
+				items:       &previous,
+				updateError: tc.storeError,
+			}
+			service := NewService(mockStore, productClient, nil, &PublisherMock{}, config.OrdersConfig{ReserveStock: tc.reserveStock}, audit.NoopRecorder{})
+
+			// when
+			updated, err := service.UpdateItems(context.Background(), userID, orderID, tc.items, 1)
+
+			// then
+			if tc.expectError != nil {
+				require.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, updated)
+			} else {
+				require.NoError(t, err)
+				assert.NotNil(t, updated)
+			}
+			assert.Equal(t, tc.expectedReserved, stockQuantities(productClient.reserved))
+			assert.Equal(t, tc.expectedReleased, stockQuantities(productClient.released))
+			assert.Equal(t, tc.expectedStored, mockStore.updatedItems != nil, "the items should be stored only after the reservation")
+		})
+	}
+}
+
+func Test_OrderService_MaxItemQuantity(t *testing.T) {
+	orderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	userID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	productID1, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	productID2, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174003")
+	createdAt := time.Now()
+	const maxQuantity = 5
+	cfg := config.OrdersConfig{MaxItemQuantity: maxQuantity}
+	products := []*pb.Product{
+		{Id: productID1.String(), Price: 100, StockQuantity: 100, Version: 1},
+		{Id: productID2.String(), Price: 200, StockQuantity: 100, Version: 1},
+	}
+	// the product lookup fails if it's made, so a rejected quantity proves the check runs before it
+	errProductLookup := status.Error(codes.Unavailable, "the product lookup should not be made")
+
+	testCases := []struct {
+		name        string
+		quantities  []int32
+		expectError error
+	}{
+		{name: "Success - quantity at the maximum", quantities: []int32{maxQuantity}},
+		{name: "Success - every item at the maximum", quantities: []int32{maxQuantity, maxQuantity}},
+		{name: "Error - quantity above the maximum", quantities: []int32{maxQuantity + 1}, expectError: ordererrors.ErrQuantityExceedsMax},
+		{name: "Error - one of the items above the maximum", quantities: []int32{1, maxQuantity + 1}, expectError: ordererrors.ErrQuantityExceedsMax},
+	}
+
+	for _, tc := range testCases {
+		productClient := &ProductServiceClientMock{productResponse: &pb.GetProductResponse{Products: products}}
+		if tc.expectError != nil {
+			productClient.error = errProductLookup
+		}
+		productIDs := []uuid.UUID{productID1, productID2}
+		createItems := make([]OrderItemCreateDto, len(tc.quantities))
+		updateItems := make([]OrderItemUpdateDto, len(tc.quantities))
+		for i, quantity := range tc.quantities {
+			createItems[i] = OrderItemCreateDto{ProductID: productIDs[i], Quantity: quantity}
+			updateItems[i] = OrderItemUpdateDto{ProductID: productIDs[i], Quantity: quantity}
+		}
+
+		t.Run(tc.name+" on create", func(t *testing.T) {
+			// given
+			store := &mockOrderStore{
+				order: &db.Order{ID: orderID, UserID: userID, Status: StatusPending, Version: 1, CreatedAt: &createdAt},
+				items: &[]db.OrderItem{},
+			}
+			service := NewService(store, productClient, nil, &PublisherMock{}, cfg, audit.NoopRecorder{})
+			// when
+			created, err := service.Create(context.Background(), OrderCreateDto{UserID: userID, Status: StatusPending, Items: createItems})
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, created)
+				assert.Nil(t, store.createParams, "the order should not be created")
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, store.createItems, len(tc.quantities))
+		})
+
+		t.Run(tc.name+" on items update", func(t *testing.T) {
+			// given
+			store := &mockOrderStore{order: &db.Order{ID: orderID, UserID: userID, Status: StatusPending, Version: 1, CreatedAt: &createdAt}}
+			service := NewService(store, productClient, nil, &PublisherMock{}, cfg, audit.NoopRecorder{})
+			// when
+			updated, err := service.UpdateItems(context.Background(), userID, orderID, updateItems, 1)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, updated)
+				assert.Nil(t, store.updatedItems, "order items should not be modified")
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, store.updatedItems, len(tc.quantities))
+		})
+	}
+}
+
+func Test_OrderService_ResendCreatedEvent(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockOwnerID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	createdAt := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	errPublish := errors.New("nats unavailable")
+	errUserService := status.Error(codes.Unavailable, "user service unavailable")
+	owner := &userpb.GetUserResponse{Id: mockOwnerID.String(), Email: "customer@example.com"}
+	testCases := []struct {
+		name        string
+		mockStore   *mockOrderStore
+		userClient  *UserServiceClientMock
+		publisher   *PublisherMock
+		expectError error
+	}{
+		{
+			name: "Success - event published",
+			mockStore: &mockOrderStore{
+				order: &db.Order{ID: mockID, UserID: mockOwnerID, Status: "PENDING", Version: 1, TotalPrice: 1500, CreatedAt: &createdAt},
+			},
+			userClient: &UserServiceClientMock{user: owner},
+			publisher:  &PublisherMock{},
+		},
+		{
+			name:        "Error - order not found",
+			mockStore:   &mockOrderStore{error: ordererrors.ErrOrderNotFound},
+			userClient:  &UserServiceClientMock{user: owner},
+			publisher:   &PublisherMock{},
+			expectError: ordererrors.ErrOrderNotFound,
+		},
+		{
+			name: "Error - owner not found",
+			mockStore: &mockOrderStore{
+				order: &db.Order{ID: mockID, UserID: mockOwnerID, Status: "PENDING", Version: 1, TotalPrice: 1500, CreatedAt: &createdAt},
+			},
+			userClient:  &UserServiceClientMock{error: status.Error(codes.NotFound, "user not found")},
+			publisher:   &PublisherMock{},
+			expectError: ordererrors.ErrUserNotFound,
+		},
+		{
+			name: "Error - user service unavailable",
+			mockStore: &mockOrderStore{
+				order: &db.Order{ID: mockID, UserID: mockOwnerID, Status: "PENDING", Version: 1, TotalPrice: 1500, CreatedAt: &createdAt},
+			},
+			userClient:  &UserServiceClientMock{error: errUserService},
+			publisher:   &PublisherMock{},
+			expectError: errUserService,
+		},
+		{
+			name: "Error - publishing failed",
+			mockStore: &mockOrderStore{
+				order: &db.Order{ID: mockID, UserID: mockOwnerID, Status: "PENDING", Version: 1, TotalPrice: 1500, CreatedAt: &createdAt},
+			},
+			userClient:  &UserServiceClientMock{user: owner},
+			publisher:   &PublisherMock{error: errPublish},
+			expectError: errPublish,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, nil, tc.userClient, tc.publisher, config.OrdersConfig{}, audit.NoopRecorder{})
+			// when
+			err := service.ResendCreatedEvent(context.Background(), mockID)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Empty(t, tc.publisher.published, "no event should be published")
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, tc.publisher.published, 1)
+			event, ok := tc.publisher.published[0].(events.OrderCreatedEvent)
+			require.True(t, ok, "an OrderCreatedEvent should be published")
+			assert.Equal(t, mockID, event.OrderID)
+			assert.Equal(t, mockOwnerID, event.UserID)
+			assert.Equal(t, int64(1500), event.TotalPrice)
+			assert.Equal(t, mockOwnerID.String(), tc.userClient.requested, "email of the owner should be looked up")
+			assert.Equal(t, "customer@example.com", event.UserEmail)
+			assert.Equal(t, createdAt, event.CreatedAt)
+			assert.NotEqual(t, uuid.Nil, event.EventID, "event should get a new ID")
+		})
+	}
+}
+
+func Test_OrderService_Delete(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	testCases := []struct {
+		name        string
+		mockStore   *mockOrderStore
+		expectError error
+	}{
+		{
+			name:      "Success - order deleted",
+			mockStore: &mockOrderStore{},
+		},
+		{
+			name:        "Error - order not found",
+			mockStore:   &mockOrderStore{updateError: ordererrors.ErrOrderNotFound},
+			expectError: ordererrors.ErrOrderNotFound,
+		},
+		{
+			name:        "Error - version conflict",
+			mockStore:   &mockOrderStore{updateError: &ordererrors.OptimisticLockError{CurrentVersion: 3, CurrentStatus: StatusPending}},
+			expectError: ordererrors.ErrOptimisticLock,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, nil, nil, &PublisherMock{}, config.OrdersConfig{}, audit.NoopRecorder{})
+			// when
+			err := service.Delete(context.Background(), mockID, 2)
+			// then
+			assert.ErrorIs(t, err, tc.expectError)
+			require.NotNil(t, tc.mockStore.deleteParams)
+			assert.Equal(t, db.SoftDeleteOrderParams{ID: mockID, Version: 2}, *tc.mockStore.deleteParams)
 		})
 	}
 }
@@ -506,11 +1932,12 @@ func Test_toDto(t *testing.T) {
 		{
 			name: "Order with items",
 			order: &db.Order{
-				ID:        mockID,
-				UserID:    mockUserID,
-				Status:    "PENDING",
-				Version:   1,
-				CreatedAt: &createdAt,
+				ID:         mockID,
+				UserID:     mockUserID,
+				Status:     "PENDING",
+				Version:    1,
+				CreatedAt:  &createdAt,
+				TotalPrice: 100,
 			},
 			items: &[]db.OrderItem{
 				{
@@ -520,24 +1947,27 @@ func Test_toDto(t *testing.T) {
 					Quantity:     2,
 					PricePerItem: 50,
 					Price:        100,
+					Currency:     "EUR",
 					CreatedAt:    &createdAt,
 					Version:      1,
 				},
 			},
 			expected: &OrderDto{
-				ID:        mockID,
-				UserID:    mockUserID,
-				Status:    "PENDING",
-				Version:   1,
-				CreatedAt: createdAt.Format(time.RFC3339),
+				ID:         mockID,
+				UserID:     mockUserID,
+				Status:     "PENDING",
+				Version:    1,
+				TotalPrice: money.New(100, "EUR"),
+				CreatedAt:  createdAt.Format(time.RFC3339),
 				Items: []OrderItemDto{
 					{
 						ID:           mockOrderItemID,
 						OrderID:      mockID,
 						ProductID:    mockProductID,
 						Quantity:     2,
-						PricePerItem: 50,
-						Price:        100,
+						PricePerItem: money.New(50, "EUR"),
+						Price:        money.New(100, "EUR"),
+						Currency:     "EUR",
 						CreatedAt:    createdAt.Format(time.RFC3339),
 						Version:      1,
 					},
@@ -11,11 +11,13 @@ import (
 )
 
 type Order struct {
-	ID        uuid.UUID  `json:"id"`
-	UserID    uuid.UUID  `json:"user_id"`
-	Status    string     `json:"status"`
-	Version   int32      `json:"version"`
-	CreatedAt *time.Time `json:"created_at"`
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Status     string     `json:"status"`
+	Version    int32      `json:"version"`
+	CreatedAt  *time.Time `json:"created_at"`
+	TotalPrice int64      `json:"total_price"`
+	DeletedAt  *time.Time `json:"deleted_at"`
 }
 
 type OrderItem struct {
@@ -27,4 +29,6 @@ type OrderItem struct {
 	Price        int64      `json:"price"`
 	Version      int32      `json:"version"`
 	CreatedAt    *time.Time `json:"created_at"`
+	Currency     string     `json:"currency"`
+	DeletedAt    *time.Time `json:"deleted_at"`
 }
@@ -7,23 +7,25 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const createOrder = `-- name: CreateOrder :one
-INSERT INTO orders (user_id, status)
-VALUES ($1, $2)
-RETURNING id, user_id, status, version, created_at
+INSERT INTO orders (user_id, status, total_price)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, status, version, created_at, total_price, deleted_at
 `
 
 type CreateOrderParams struct {
-	UserID uuid.UUID `json:"user_id"`
-	Status string    `json:"status"`
+	UserID     uuid.UUID `json:"user_id"`
+	Status     string    `json:"status"`
+	TotalPrice int64     `json:"total_price"`
 }
 
 func (q *Queries) CreateOrder(ctx context.Context, arg CreateOrderParams) (Order, error) {
-	row := q.db.QueryRow(ctx, createOrder, arg.UserID, arg.Status)
+	row := q.db.QueryRow(ctx, createOrder, arg.UserID, arg.Status, arg.TotalPrice)
 	var i Order
 	err := row.Scan(
 		&i.ID,
@@ -31,14 +33,16 @@ func (q *Queries) CreateOrder(ctx context.Context, arg CreateOrderParams) (Order
 		&i.Status,
 		&i.Version,
 		&i.CreatedAt,
+		&i.TotalPrice,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const createOrderItem = `-- name: CreateOrderItem :one
-INSERT INTO order_items (order_id, product_id, quantity, price_per_item, price)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, order_id, product_id, quantity, price_per_item, price, version, created_at
+INSERT INTO order_items (order_id, product_id, quantity, price_per_item, price, currency)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, order_id, product_id, quantity, price_per_item, price, version, created_at, currency, deleted_at
 `
 
 type CreateOrderItemParams struct {
@@ -47,6 +51,7 @@ type CreateOrderItemParams struct {
 	Quantity     int32     `json:"quantity"`
 	PricePerItem int64     `json:"price_per_item"`
 	Price        int64     `json:"price"`
+	Currency     string    `json:"currency"`
 }
 
 func (q *Queries) CreateOrderItem(ctx context.Context, arg CreateOrderItemParams) (OrderItem, error) {
@@ -56,6 +61,7 @@ func (q *Queries) CreateOrderItem(ctx context.Context, arg CreateOrderItemParams
 		arg.Quantity,
 		arg.PricePerItem,
 		arg.Price,
+		arg.Currency,
 	)
 	var i OrderItem
 	err := row.Scan(
@@ -67,14 +73,81 @@ func (q *Queries) CreateOrderItem(ctx context.Context, arg CreateOrderItemParams
 		&i.Price,
 		&i.Version,
 		&i.CreatedAt,
+		&i.Currency,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
+const deleteOrderItemsByOrderID = `-- name: DeleteOrderItemsByOrderID :exec
+DELETE
+FROM order_items
+WHERE order_id = $1
+`
+
+func (q *Queries) DeleteOrderItemsByOrderID(ctx context.Context, orderID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteOrderItemsByOrderID, orderID)
+	return err
+}
+
+const findAllOrders = `-- name: FindAllOrders :many
+SELECT id, user_id, status, version, created_at, total_price, deleted_at
+FROM orders
+WHERE ($1::text = '' OR status = $1::text)
+  AND deleted_at IS NULL
+  AND ($2::timestamp IS NULL OR created_at >= $2::timestamp)
+  AND ($3::timestamp IS NULL OR created_at < $3::timestamp)
+ORDER BY created_at DESC
+LIMIT $4 OFFSET $5
+`
+
+type FindAllOrdersParams struct {
+	Status      string     `json:"status"`
+	CreatedFrom *time.Time `json:"created_from"`
+	CreatedTo   *time.Time `json:"created_to"`
+	Limit       int32      `json:"limit"`
+	Offset      int32      `json:"offset"`
+}
+
+func (q *Queries) FindAllOrders(ctx context.Context, arg FindAllOrdersParams) ([]Order, error) {
+	rows, err := q.db.Query(ctx, findAllOrders,
+		arg.Status,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Order{}
+	for rows.Next() {
+		var i Order
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Status,
+			&i.Version,
+			&i.CreatedAt,
+			&i.TotalPrice,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const findOrderByID = `-- name: FindOrderByID :one
-SELECT id, user_id, status, version, created_at
+SELECT id, user_id, status, version, created_at, total_price, deleted_at
 FROM orders
 WHERE id = $1
+  AND deleted_at IS NULL
 `
 
 func (q *Queries) FindOrderByID(ctx context.Context, id uuid.UUID) (Order, error) {
@@ -86,6 +159,8 @@ func (q *Queries) FindOrderByID(ctx context.Context, id uuid.UUID) (Order, error
 		&i.Status,
 		&i.Version,
 		&i.CreatedAt,
+		&i.TotalPrice,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -98,9 +173,12 @@ SELECT id,
        price_per_item,
        price,
        version,
-       created_at
+       created_at,
+       currency,
+       deleted_at
 FROM order_items
 WHERE order_id = $1
+  AND deleted_at IS NULL
 `
 
 func (q *Queries) FindOrderItemsByOrderID(ctx context.Context, orderID uuid.UUID) ([]OrderItem, error) {
@@ -121,6 +199,8 @@ func (q *Queries) FindOrderItemsByOrderID(ctx context.Context, orderID uuid.UUID
 			&i.Price,
 			&i.Version,
 			&i.CreatedAt,
+			&i.Currency,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -133,21 +213,32 @@ func (q *Queries) FindOrderItemsByOrderID(ctx context.Context, orderID uuid.UUID
 }
 
 const findOrdersByUserID = `-- name: FindOrdersByUserID :many
-SELECT id, user_id, status, version, created_at
+SELECT id, user_id, status, version, created_at, total_price, deleted_at
 FROM orders
 where user_id = $1
+  AND deleted_at IS NULL
+  AND ($2::timestamp IS NULL OR created_at >= $2::timestamp)
+  AND ($3::timestamp IS NULL OR created_at < $3::timestamp)
 ORDER BY created_at DESC
-LIMIT $2 OFFSET $3
+LIMIT $4 OFFSET $5
 `
 
 type FindOrdersByUserIDParams struct {
-	UserID uuid.UUID `json:"user_id"`
-	Limit  int32     `json:"limit"`
-	Offset int32     `json:"offset"`
+	UserID      uuid.UUID  `json:"user_id"`
+	CreatedFrom *time.Time `json:"created_from"`
+	CreatedTo   *time.Time `json:"created_to"`
+	Limit       int32      `json:"limit"`
+	Offset      int32      `json:"offset"`
 }
 
 func (q *Queries) FindOrdersByUserID(ctx context.Context, arg FindOrdersByUserIDParams) ([]Order, error) {
-	rows, err := q.db.Query(ctx, findOrdersByUserID, arg.UserID, arg.Limit, arg.Offset)
+	rows, err := q.db.Query(ctx, findOrdersByUserID,
+		arg.UserID,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -161,6 +252,99 @@ func (q *Queries) FindOrdersByUserID(ctx context.Context, arg FindOrdersByUserID
 			&i.Status,
 			&i.Version,
 			&i.CreatedAt,
+			&i.TotalPrice,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const softDeleteOrder = `-- name: SoftDeleteOrder :one
+UPDATE orders
+SET deleted_at = NOW(),
+    version    = version + 1
+WHERE id = $1
+  AND version = $2
+  AND deleted_at IS NULL
+RETURNING id, user_id, status, version, created_at, total_price, deleted_at
+`
+
+type SoftDeleteOrderParams struct {
+	ID      uuid.UUID `json:"id"`
+	Version int32     `json:"version"`
+}
+
+func (q *Queries) SoftDeleteOrder(ctx context.Context, arg SoftDeleteOrderParams) (Order, error) {
+	row := q.db.QueryRow(ctx, softDeleteOrder, arg.ID, arg.Version)
+	var i Order
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.Version,
+		&i.CreatedAt,
+		&i.TotalPrice,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const softDeleteOrderItemsByOrderID = `-- name: SoftDeleteOrderItemsByOrderID :exec
+UPDATE order_items
+SET deleted_at = NOW()
+WHERE order_id = $1
+  AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteOrderItemsByOrderID(ctx context.Context, orderID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, softDeleteOrderItemsByOrderID, orderID)
+	return err
+}
+
+const summarizeOrdersByUserID = `-- name: SummarizeOrdersByUserID :many
+SELECT o.status,
+       COALESCE(c.currency, 'USD')::text       AS currency,
+       COUNT(*)::bigint                       AS order_count,
+       COALESCE(SUM(o.total_price), 0)::bigint AS total_price
+FROM orders o
+         -- the items of an order share the currency, see checkCurrencies
+         LEFT JOIN LATERAL (SELECT oi.currency
+                            FROM order_items oi
+                            WHERE oi.order_id = o.id
+                            LIMIT 1) c ON TRUE
+WHERE o.user_id = $1
+  AND o.deleted_at IS NULL
+GROUP BY o.status, COALESCE(c.currency, 'USD')
+ORDER BY o.status, currency
+`
+
+type SummarizeOrdersByUserIDRow struct {
+	Status     string `json:"status"`
+	Currency   string `json:"currency"`
+	OrderCount int64  `json:"order_count"`
+	TotalPrice int64  `json:"total_price"`
+}
+
+func (q *Queries) SummarizeOrdersByUserID(ctx context.Context, userID uuid.UUID) ([]SummarizeOrdersByUserIDRow, error) {
+	rows, err := q.db.Query(ctx, summarizeOrdersByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SummarizeOrdersByUserIDRow{}
+	for rows.Next() {
+		var i SummarizeOrdersByUserIDRow
+		if err := rows.Scan(
+			&i.Status,
+			&i.Currency,
+			&i.OrderCount,
+			&i.TotalPrice,
 		); err != nil {
 			return nil, err
 		}
@@ -178,7 +362,8 @@ SET status  = $2,
     version = version + 1
 WHERE id = $1
   AND version = $3
-RETURNING id, user_id, status, version, created_at
+  AND deleted_at IS NULL
+RETURNING id, user_id, status, version, created_at, total_price, deleted_at
 `
 
 type UpdateOrderParams struct {
@@ -196,6 +381,39 @@ func (q *Queries) UpdateOrder(ctx context.Context, arg UpdateOrderParams) (Order
 		&i.Status,
 		&i.Version,
 		&i.CreatedAt,
+		&i.TotalPrice,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateOrderTotalPrice = `-- name: UpdateOrderTotalPrice :one
+UPDATE orders
+SET total_price = $3,
+    version     = version + 1
+WHERE id = $1
+  AND version = $2
+  AND deleted_at IS NULL
+RETURNING id, user_id, status, version, created_at, total_price, deleted_at
+`
+
+type UpdateOrderTotalPriceParams struct {
+	ID         uuid.UUID `json:"id"`
+	Version    int32     `json:"version"`
+	TotalPrice int64     `json:"total_price"`
+}
+
+func (q *Queries) UpdateOrderTotalPrice(ctx context.Context, arg UpdateOrderTotalPriceParams) (Order, error) {
+	row := q.db.QueryRow(ctx, updateOrderTotalPrice, arg.ID, arg.Version, arg.TotalPrice)
+	var i Order
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Status,
+		&i.Version,
+		&i.CreatedAt,
+		&i.TotalPrice,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -13,10 +13,16 @@ import (
 type Querier interface {
 	CreateOrder(ctx context.Context, arg CreateOrderParams) (Order, error)
 	CreateOrderItem(ctx context.Context, arg CreateOrderItemParams) (OrderItem, error)
+	DeleteOrderItemsByOrderID(ctx context.Context, orderID uuid.UUID) error
+	FindAllOrders(ctx context.Context, arg FindAllOrdersParams) ([]Order, error)
 	FindOrderByID(ctx context.Context, id uuid.UUID) (Order, error)
 	FindOrderItemsByOrderID(ctx context.Context, orderID uuid.UUID) ([]OrderItem, error)
 	FindOrdersByUserID(ctx context.Context, arg FindOrdersByUserIDParams) ([]Order, error)
+	SoftDeleteOrder(ctx context.Context, arg SoftDeleteOrderParams) (Order, error)
+	SoftDeleteOrderItemsByOrderID(ctx context.Context, orderID uuid.UUID) error
+	SummarizeOrdersByUserID(ctx context.Context, userID uuid.UUID) ([]SummarizeOrdersByUserIDRow, error)
 	UpdateOrder(ctx context.Context, arg UpdateOrderParams) (Order, error)
+	UpdateOrderTotalPrice(ctx context.Context, arg UpdateOrderTotalPriceParams) (Order, error)
 }
 
 var _ Querier = (*Queries)(nil)
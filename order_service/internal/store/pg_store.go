@@ -3,9 +3,11 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 
 	ordererrors "github.com/abgdnv/gocommerce/order_service/internal/errors"
 	"github.com/abgdnv/gocommerce/order_service/internal/store/db"
+	"github.com/abgdnv/gocommerce/pkg/telemetry"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 
@@ -13,15 +15,22 @@ import (
 )
 
 type PgStore struct {
-	db *pgxpool.Pool
-	q  *db.Queries
+	db           *pgxpool.Pool
+	q            *db.Queries
+	queryTimeout time.Duration
+	slowLog      *telemetry.SlowQueryLogger
 }
 
 // NewPgStore creates a new instance of ProductStore using a PostgreSQL connection pool.
-func NewPgStore(dbp *pgxpool.Pool) *PgStore {
+// Every query or transaction is bounded by queryTimeout and fails with ErrQueryTimeout when it expires.
+// A zero queryTimeout disables the timeout.
+// The queries slower than the threshold of slowLog are logged, a nil slowLog disables the log.
+func NewPgStore(dbp *pgxpool.Pool, queryTimeout time.Duration, slowLog *telemetry.SlowQueryLogger) *PgStore {
 	return &PgStore{
-		db: dbp,
-		q:  db.New(dbp),
+		db:           dbp,
+		q:            db.New(dbp),
+		queryTimeout: queryTimeout,
+		slowLog:      slowLog,
 	}
 }
 
@@ -30,15 +39,19 @@ func (p *PgStore) FindByID(ctx context.Context, id uuid.UUID) (*db.Order, *[]db.
 	var orderItems *[]db.OrderItem
 
 	// Use transaction to ensure atomicity and consistency
-	txErr := p.withTransaction(ctx, func(qtx *db.Queries) error {
-		o, err := qtx.FindOrderByID(ctx, id)
+	txErr := p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "FindOrderByID")
+		o, err := qtx.FindOrderByID(spanCtx, id)
+		span.End(1, err)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
 				return ordererrors.ErrOrderNotFound
 			}
 			return ordererrors.ErrFailedToFindOrder
 		}
-		i, err := qtx.FindOrderItemsByOrderID(ctx, id)
+		spanCtx, span = p.slowLog.StartDBSpan(ctx, "FindOrderItemsByOrderID")
+		i, err := qtx.FindOrderItemsByOrderID(spanCtx, id)
+		span.End(int64(len(i)), err)
 		if err != nil {
 			return ordererrors.ErrFailedToFindOrderItems
 		}
@@ -54,30 +67,69 @@ func (p *PgStore) FindByID(ctx context.Context, id uuid.UUID) (*db.Order, *[]db.
 	return order, orderItems, nil
 }
 
+// FindOrdersByUserID retrieves a page of orders of the user, newest first.
+// The limit is only an upper bound, the result is sized by the number of returned rows.
 func (p *PgStore) FindOrdersByUserID(ctx context.Context, params *db.FindOrdersByUserIDParams) (*[]db.Order, error) {
 
 	// No need for transaction here as we are making just one query to fetch orders
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	ctx, span := p.slowLog.StartDBSpan(ctx, "FindOrdersByUserID")
 	orders, err := p.q.FindOrdersByUserID(ctx, *params)
+	span.End(int64(len(orders)), err)
 	if err != nil {
-		return nil, ordererrors.ErrFailedToFindUserOrders
+		return nil, queryError(ctx, ordererrors.ErrFailedToFindUserOrders)
 	}
 
 	return &orders, nil
 }
 
+// FindAllOrders retrieves a page of orders of all users, newest first.
+// An empty status in the params returns orders of any status.
+func (p *PgStore) FindAllOrders(ctx context.Context, params *db.FindAllOrdersParams) (*[]db.Order, error) {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	ctx, span := p.slowLog.StartDBSpan(ctx, "FindAllOrders")
+	orders, err := p.q.FindAllOrders(ctx, *params)
+	span.End(int64(len(orders)), err)
+	if err != nil {
+		return nil, queryError(ctx, ordererrors.ErrFailedToFindOrders)
+	}
+
+	return &orders, nil
+}
+
+// SummarizeByUserID aggregates the orders of the user by status in a single query.
+func (p *PgStore) SummarizeByUserID(ctx context.Context, userID uuid.UUID) ([]db.SummarizeOrdersByUserIDRow, error) {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	ctx, span := p.slowLog.StartDBSpan(ctx, "SummarizeOrdersByUserID")
+	rows, err := p.q.SummarizeOrdersByUserID(ctx, userID)
+	span.End(int64(len(rows)), err)
+	if err != nil {
+		return nil, queryError(ctx, ordererrors.ErrFailedToSummarizeOrders)
+	}
+
+	return rows, nil
+}
+
 func (p *PgStore) CreateOrder(ctx context.Context, orderParams *db.CreateOrderParams, items *[]db.CreateOrderItemParams) (*db.Order, *[]db.OrderItem, error) {
 	var createdOrder *db.Order
 	var createdItems *[]db.OrderItem
 
-	txErr := p.withTransaction(ctx, func(qtx *db.Queries) error {
-		order, err := qtx.CreateOrder(ctx, *orderParams)
+	txErr := p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "CreateOrder")
+		order, err := qtx.CreateOrder(spanCtx, *orderParams)
+		span.End(1, err)
 		if err != nil {
 			return ordererrors.ErrCreateOrder
 		}
 		orderItems := make([]db.OrderItem, 0, len(*items))
 		for _, item := range *items {
 			item.OrderID = order.ID
-			orderItem, err := qtx.CreateOrderItem(ctx, item)
+			spanCtx, span := p.slowLog.StartDBSpan(ctx, "CreateOrderItem")
+			orderItem, err := qtx.CreateOrderItem(spanCtx, item)
+			span.End(1, err)
 			if err != nil {
 				return ordererrors.ErrCreateOrderItem
 			}
@@ -98,21 +150,14 @@ func (p *PgStore) CreateOrder(ctx context.Context, orderParams *db.CreateOrderPa
 func (p *PgStore) Update(ctx context.Context, params *db.UpdateOrderParams) (*db.Order, error) {
 	var order db.Order
 
-	txErr := p.withTransaction(ctx, func(qtx *db.Queries) error {
+	txErr := p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
 		var err error
-		order, err = qtx.UpdateOrder(ctx, *params)
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "UpdateOrder")
+		order, err = qtx.UpdateOrder(spanCtx, *params)
+		span.End(1, err)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
-				// Check if the order exists, or it's an optimistic lock error.
-				order, err = qtx.FindOrderByID(ctx, params.ID)
-				if err != nil {
-					if errors.Is(err, pgx.ErrNoRows) {
-						return ordererrors.ErrOrderNotFound
-					}
-				} else {
-					return ordererrors.ErrOptimisticLock
-				}
-
+				return p.versionMismatchError(ctx, qtx, params.ID, ordererrors.ErrUpdateOrder)
 			}
 			return ordererrors.ErrUpdateOrder
 		}
@@ -126,24 +171,134 @@ func (p *PgStore) Update(ctx context.Context, params *db.UpdateOrderParams) (*db
 	return &order, nil
 }
 
-func (p *PgStore) withTransaction(ctx context.Context, fn func(qtx *db.Queries) error) error {
+// UpdateItems replaces the items of the order and increments the order version in one transaction.
+func (p *PgStore) UpdateItems(ctx context.Context, params *db.UpdateOrderTotalPriceParams, items *[]db.CreateOrderItemParams) (*db.Order, *[]db.OrderItem, error) {
+	var updatedOrder *db.Order
+	var updatedItems *[]db.OrderItem
+
+	txErr := p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		// Bump the version first, so concurrent modifications of the order fail fast on the optimistic lock.
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "UpdateOrderTotalPrice")
+		order, err := qtx.UpdateOrderTotalPrice(spanCtx, *params)
+		span.End(1, err)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return p.versionMismatchError(ctx, qtx, params.ID, ordererrors.ErrUpdateOrderItems)
+			}
+			return ordererrors.ErrUpdateOrderItems
+		}
+		spanCtx, span = p.slowLog.StartDBSpan(ctx, "DeleteOrderItemsByOrderID")
+		err = qtx.DeleteOrderItemsByOrderID(spanCtx, order.ID)
+		span.End(1, err)
+		if err != nil {
+			return ordererrors.ErrUpdateOrderItems
+		}
+		orderItems := make([]db.OrderItem, 0, len(*items))
+		for _, item := range *items {
+			item.OrderID = order.ID
+			spanCtx, span := p.slowLog.StartDBSpan(ctx, "CreateOrderItem")
+			orderItem, err := qtx.CreateOrderItem(spanCtx, item)
+			span.End(1, err)
+			if err != nil {
+				return ordererrors.ErrCreateOrderItem
+			}
+			orderItems = append(orderItems, orderItem)
+		}
+		updatedOrder = &order
+		updatedItems = &orderItems
+		return nil
+	})
+
+	if txErr != nil {
+		return nil, nil, txErr
+	}
+
+	return updatedOrder, updatedItems, nil
+}
+
+// Delete soft-deletes the order and its items in one transaction.
+func (p *PgStore) Delete(ctx context.Context, params *db.SoftDeleteOrderParams) error {
+	return p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "SoftDeleteOrder")
+		_, err := qtx.SoftDeleteOrder(spanCtx, *params)
+		span.End(1, err)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return p.versionMismatchError(ctx, qtx, params.ID, ordererrors.ErrDeleteOrder)
+			}
+			return ordererrors.ErrDeleteOrder
+		}
+		spanCtx, span = p.slowLog.StartDBSpan(ctx, "SoftDeleteOrderItemsByOrderID")
+		err = qtx.SoftDeleteOrderItemsByOrderID(spanCtx, params.ID)
+		span.End(1, err)
+		if err != nil {
+			return ordererrors.ErrDeleteOrder
+		}
+		return nil
+	})
+}
+
+// versionMismatchError tells apart a missing order from an optimistic lock error after a versioned update matched no rows.
+// The optimistic lock error carries the current version and status of the order.
+// Returns updateErr if the order can't be looked up.
+func (p *PgStore) versionMismatchError(ctx context.Context, qtx *db.Queries, id uuid.UUID, updateErr error) error {
+	spanCtx, span := p.slowLog.StartDBSpan(ctx, "FindOrderByID")
+	current, err := qtx.FindOrderByID(spanCtx, id)
+	span.End(1, err)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ordererrors.ErrOrderNotFound
+	} else if err != nil {
+		return updateErr
+	}
+	return &ordererrors.OptimisticLockError{CurrentVersion: current.Version, CurrentStatus: current.Status}
+}
+
+// withTransaction runs fn in a transaction bounded by the query timeout, fn must use the context it is given.
+// The transaction is rolled back if fn fails, and ErrQueryTimeout is returned if the query timeout expired.
+func (p *PgStore) withTransaction(ctx context.Context, fn func(ctx context.Context, qtx *db.Queries) error) error {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+
 	tx, err := p.db.Begin(ctx)
 	if err != nil {
-		return ordererrors.ErrTransactionBegin
+		return queryError(ctx, ordererrors.ErrTransactionBegin)
 	}
 	qtx := p.q.WithTx(tx)
 
-	err = fn(qtx)
+	err = fn(ctx, qtx)
 	if err != nil {
-		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+		// Roll back with a context that is not canceled by the query timeout,
+		// so a timed out transaction is still rolled back and its connection released to the pool.
+		if rbErr := tx.Rollback(context.WithoutCancel(ctx)); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) && !timedOut(ctx) {
 			return ordererrors.ErrTransactionRollback
 		}
-		return err
+		return queryError(ctx, err)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		return ordererrors.ErrTransactionCommit
+		return queryError(ctx, ordererrors.ErrTransactionCommit)
 	}
 
 	return nil
 }
+
+// withQueryTimeout returns a context canceled with ErrQueryTimeout when the query timeout expires.
+func (p *PgStore) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, p.queryTimeout, ordererrors.ErrQueryTimeout)
+}
+
+// timedOut reports whether the query timeout of the context expired.
+func timedOut(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ordererrors.ErrQueryTimeout)
+}
+
+// queryError returns ErrQueryTimeout if the query timeout of the context expired, otherwise err.
+func queryError(ctx context.Context, err error) error {
+	if timedOut(ctx) {
+		return ordererrors.ErrQueryTimeout
+	}
+	return err
+}
@@ -1,19 +1,49 @@
 -- name: CreateOrder :one
-INSERT INTO orders (user_id, status)
-VALUES ($1, $2)
-RETURNING id, user_id, status, version, created_at;
+INSERT INTO orders (user_id, status, total_price)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, status, version, created_at, total_price, deleted_at;
 
 -- name: FindOrderByID :one
-SELECT id, user_id, status, version, created_at
+SELECT id, user_id, status, version, created_at, total_price, deleted_at
 FROM orders
-WHERE id = $1;
+WHERE id = $1
+  AND deleted_at IS NULL;
 
 -- name: FindOrdersByUserID :many
-SELECT id, user_id, status, version, created_at
+SELECT id, user_id, status, version, created_at, total_price, deleted_at
+FROM orders
+where user_id = sqlc.arg('user_id')
+  AND deleted_at IS NULL
+  AND (sqlc.narg('created_from')::timestamp IS NULL OR created_at >= sqlc.narg('created_from')::timestamp)
+  AND (sqlc.narg('created_to')::timestamp IS NULL OR created_at < sqlc.narg('created_to')::timestamp)
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: FindAllOrders :many
+SELECT id, user_id, status, version, created_at, total_price, deleted_at
 FROM orders
-where user_id = $1
+WHERE (sqlc.arg('status')::text = '' OR status = sqlc.arg('status')::text)
+  AND deleted_at IS NULL
+  AND (sqlc.narg('created_from')::timestamp IS NULL OR created_at >= sqlc.narg('created_from')::timestamp)
+  AND (sqlc.narg('created_to')::timestamp IS NULL OR created_at < sqlc.narg('created_to')::timestamp)
 ORDER BY created_at DESC
-LIMIT $2 OFFSET $3;
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: SummarizeOrdersByUserID :many
+SELECT o.status,
+       COALESCE(c.currency, 'USD')::text       AS currency,
+       COUNT(*)::bigint                       AS order_count,
+       COALESCE(SUM(o.total_price), 0)::bigint AS total_price
+FROM orders o
+         -- the items of an order share the currency, see checkCurrencies
+         LEFT JOIN LATERAL (SELECT oi.currency
+                            FROM order_items oi
+                            WHERE oi.order_id = o.id
+                            LIMIT 1) c ON TRUE
+WHERE o.user_id = $1
+  AND o.deleted_at IS NULL
+GROUP BY o.status, COALESCE(c.currency, 'USD')
+ORDER BY o.status, currency;
 
 -- name: UpdateOrder :one
 UPDATE orders
@@ -21,12 +51,22 @@ SET status  = $2,
     version = version + 1
 WHERE id = $1
   AND version = $3
-RETURNING id, user_id, status, version, created_at;
+  AND deleted_at IS NULL
+RETURNING id, user_id, status, version, created_at, total_price, deleted_at;
+
+-- name: UpdateOrderTotalPrice :one
+UPDATE orders
+SET total_price = $3,
+    version     = version + 1
+WHERE id = $1
+  AND version = $2
+  AND deleted_at IS NULL
+RETURNING id, user_id, status, version, created_at, total_price, deleted_at;
 
 -- name: CreateOrderItem :one
-INSERT INTO order_items (order_id, product_id, quantity, price_per_item, price)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, order_id, product_id, quantity, price_per_item, price, version, created_at;
+INSERT INTO order_items (order_id, product_id, quantity, price_per_item, price, currency)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, order_id, product_id, quantity, price_per_item, price, version, created_at, currency, deleted_at;
 
 -- name: FindOrderItemsByOrderID :many
 SELECT id,
@@ -36,6 +76,29 @@ SELECT id,
        price_per_item,
        price,
        version,
-       created_at
+       created_at,
+       currency,
+       deleted_at
+FROM order_items
+WHERE order_id = $1
+  AND deleted_at IS NULL;
+
+-- name: DeleteOrderItemsByOrderID :exec
+DELETE
 FROM order_items
 WHERE order_id = $1;
+
+-- name: SoftDeleteOrder :one
+UPDATE orders
+SET deleted_at = NOW(),
+    version    = version + 1
+WHERE id = $1
+  AND version = $2
+  AND deleted_at IS NULL
+RETURNING id, user_id, status, version, created_at, total_price, deleted_at;
+
+-- name: SoftDeleteOrderItemsByOrderID :exec
+UPDATE order_items
+SET deleted_at = NOW()
+WHERE order_id = $1
+  AND deleted_at IS NULL;
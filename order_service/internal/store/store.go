@@ -19,6 +19,15 @@ type OrderStore interface {
 	// Returns an empty slice if no orders exist.
 	FindOrdersByUserID(ctx context.Context, params *db.FindOrdersByUserIDParams) (*[]db.Order, error)
 
+	// FindAllOrders returns orders of all users, newest first, optionally filtered by status.
+	// Returns an empty slice if no orders exist.
+	FindAllOrders(ctx context.Context, params *db.FindAllOrdersParams) (*[]db.Order, error)
+
+	// SummarizeByUserID returns the number and the total price of the orders of a user grouped by status and currency,
+	// ordered by status and currency. The currency of an order is the one of its items, USD if it has none.
+	// Returns an empty slice if the user has no orders.
+	SummarizeByUserID(ctx context.Context, userID uuid.UUID) ([]db.SummarizeOrdersByUserIDRow, error)
+
 	// CreateOrder adds a new order to the system.
 	// Returns error if the order cannot be created.
 	CreateOrder(ctx context.Context, orderParams *db.CreateOrderParams, items *[]db.CreateOrderItemParams) (*db.Order, *[]db.OrderItem, error)
@@ -26,4 +35,12 @@ type OrderStore interface {
 	// Update modifies an existing order's details.
 	// Returns ErrOrderNotFound if no order exists with the given ID and version.
 	Update(ctx context.Context, params *db.UpdateOrderParams) (*db.Order, error)
+
+	// UpdateItems replaces the items of an existing order and increments its version.
+	// Returns ErrOrderNotFound if no order exists with the given ID, ErrOptimisticLock if the version doesn't match.
+	UpdateItems(ctx context.Context, params *db.UpdateOrderTotalPriceParams, items *[]db.CreateOrderItemParams) (*db.Order, *[]db.OrderItem, error)
+
+	// Delete soft-deletes an order and its items, they are no longer found by the other methods.
+	// Returns ErrOrderNotFound if no order exists with the given ID, ErrOptimisticLock if the version doesn't match.
+	Delete(ctx context.Context, params *db.SoftDeleteOrderParams) error
 }
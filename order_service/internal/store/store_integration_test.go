@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
@@ -100,7 +101,7 @@ func (s *OrderStoreSuite) SetupSuite() {
 	}
 	s.logger.Info("Migrations applied for E2E tests")
 
-	s.store = NewPgStore(s.dbPool)
+	s.store = NewPgStore(s.dbPool, 0, nil)
 	s.logger.Info("Initialization complete for OrderStoreSuite")
 }
 
@@ -138,6 +139,15 @@ func TestOrderStoreIntegration(t *testing.T) {
 	suite.Run(t, new(OrderStoreSuite))
 }
 
+// sumItemPrices returns the sum of the prices of the order items.
+func sumItemPrices(items []db.OrderItem) int64 {
+	var total int64
+	for _, item := range items {
+		total += item.Price
+	}
+	return total
+}
+
 // createTestOrder is a helper function to create an order for testing purposes.
 func (s *OrderStoreSuite) createTestOrder(orderParams *db.CreateOrderParams, itemParams *[]db.CreateOrderItemParams) (*db.Order, *[]db.OrderItem, error) {
 	s.T().Helper()
@@ -150,14 +160,22 @@ func (s *OrderStoreSuite) TestCreate() {
 	s.SetupTest()
 	// given
 	orderToCreate := db.CreateOrderParams{
-		UserID: uuid.New(),
-		Status: "PENDING",
+		UserID:     uuid.New(),
+		Status:     "PENDING",
+		TotalPrice: 2500,
 	}
 	orderItemToCreate := []db.CreateOrderItemParams{{
 		ProductID:    uuid.New(),
 		Quantity:     2,
 		PricePerItem: 1000,
 		Price:        2000,
+		Currency:     "EUR",
+	}, {
+		ProductID:    uuid.New(),
+		Quantity:     1,
+		PricePerItem: 500,
+		Price:        500,
+		Currency:     "USD",
 	}}
 
 	// when
@@ -171,22 +189,27 @@ func (s *OrderStoreSuite) TestCreate() {
 	require.Equal(s.T(), orderToCreate.Status, createdOrder.Status)
 	require.Equal(s.T(), createdOrder.Version, int32(1), "Version should be 1 for newly created order")
 	require.NotZero(s.T(), *createdOrder.CreatedAt, "CreatedAt should be set")
+	require.Equal(s.T(), sumItemPrices(*createdItems), createdOrder.TotalPrice, "Total price should be the sum of the item prices")
 
-	require.Len(s.T(), *createdItems, 1, "Should create one order item")
+	require.Len(s.T(), *createdItems, 2, "Should create two order items")
 	require.NotZero(s.T(), (*createdItems)[0].ID, "Created order item ID should not be zero")
 	require.Equal(s.T(), orderItemToCreate[0].ProductID, (*createdItems)[0].ProductID)
 	require.Equal(s.T(), orderItemToCreate[0].Quantity, (*createdItems)[0].Quantity)
 	require.Equal(s.T(), orderItemToCreate[0].PricePerItem, (*createdItems)[0].PricePerItem)
 	require.Equal(s.T(), orderItemToCreate[0].Price, (*createdItems)[0].Price)
 	require.NotZero(s.T(), *(*createdItems)[0].CreatedAt, "CreatedAt for order item should be set")
+	for i, item := range *createdItems {
+		require.Equal(s.T(), orderItemToCreate[i].Currency, item.Currency, "order items should capture the currency")
+	}
 }
 
 func (s *OrderStoreSuite) TestFindByID() {
 	s.SetupTest()
 	// given
 	orderToCreate := db.CreateOrderParams{
-		UserID: uuid.New(),
-		Status: "PENDING",
+		UserID:     uuid.New(),
+		Status:     "PENDING",
+		TotalPrice: 2000,
 	}
 	orderItemToCreate := []db.CreateOrderItemParams{{
 		ProductID:    uuid.New(),
@@ -207,6 +230,7 @@ func (s *OrderStoreSuite) TestFindByID() {
 	require.Equal(s.T(), createdOrder.UserID, fetchedOrder.UserID)
 	require.Equal(s.T(), createdOrder.Status, fetchedOrder.Status)
 	require.WithinDuration(s.T(), *createdOrder.CreatedAt, *fetchedOrder.CreatedAt, time.Second)
+	require.Equal(s.T(), sumItemPrices(*fetchedOrderItems), fetchedOrder.TotalPrice, "Persisted total price should be the sum of the item prices")
 
 	require.Len(s.T(), *fetchedOrderItems, 1, "Should create one order item")
 	require.Equal(s.T(), (*createdItems)[0].ID, (*fetchedOrderItems)[0].ID, "Order item ID should match")
@@ -273,6 +297,21 @@ func (s *OrderStoreSuite) TestListOrders() {
 			},
 			expectedErr: nil,
 		},
+		{
+			name: "Limit far exceeding the number of orders",
+			findParams: &db.FindOrdersByUserIDParams{
+				UserID: mockUserID,
+				Offset: 0,
+				Limit:  math.MaxInt32,
+			},
+			postCheck: func(t *testing.T, orders *[]db.Order) {
+				require.NotNil(t, orders, "Orders should not be nil")
+				require.Len(t, *orders, 2, "Should retrieve all 2 orders")
+				assert.Less(t, cap(*orders), 16, "Result should be sized by the number of rows, not by the limit")
+				assert.Equal(t, statusCompleted, (*orders)[0].Status, "Newest order should come first")
+			},
+			expectedErr: nil,
+		},
 		{
 			name: "Wrong user id",
 			findParams: &db.FindOrdersByUserIDParams{
@@ -315,6 +354,197 @@ func (s *OrderStoreSuite) TestListOrders() {
 	}
 }
 
+func (s *OrderStoreSuite) TestFindAllOrders() {
+
+	const statusCompleted = "COMPLETED"
+	const statusPending = "PENDING"
+	firstUserID := uuid.New()
+	secondUserID := uuid.New()
+
+	testCases := []struct {
+		name       string
+		findParams *db.FindAllOrdersParams
+		postCheck  func(t *testing.T, order *[]db.Order)
+	}{
+		{
+			name:       "Orders of all users without status filter",
+			findParams: &db.FindAllOrdersParams{Offset: 0, Limit: 10},
+			postCheck: func(t *testing.T, orders *[]db.Order) {
+				require.NotNil(t, orders, "Orders should not be nil")
+				require.Len(t, *orders, 3, "Should retrieve orders of all users")
+				userIDs := make(map[uuid.UUID]bool)
+				for _, order := range *orders {
+					userIDs[order.UserID] = true
+				}
+				assert.True(t, userIDs[firstUserID], "Should contain orders of the first user")
+				assert.True(t, userIDs[secondUserID], "Should contain orders of the second user")
+			},
+		},
+		{
+			name:       "Status filter",
+			findParams: &db.FindAllOrdersParams{Status: statusPending, Offset: 0, Limit: 10},
+			postCheck: func(t *testing.T, orders *[]db.Order) {
+				require.NotNil(t, orders, "Orders should not be nil")
+				require.Len(t, *orders, 2, "Should retrieve pending orders only")
+				for _, order := range *orders {
+					assert.Equal(t, statusPending, order.Status)
+				}
+			},
+		},
+		{
+			name:       "Status filter without matching orders",
+			findParams: &db.FindAllOrdersParams{Status: "CANCELLED", Offset: 0, Limit: 10},
+			postCheck: func(t *testing.T, orders *[]db.Order) {
+				require.NotNil(t, orders, "Orders should not be nil")
+				require.Len(t, *orders, 0, "Should retrieve no orders")
+			},
+		},
+		{
+			name:       "Pagination",
+			findParams: &db.FindAllOrdersParams{Offset: 1, Limit: 1},
+			postCheck: func(t *testing.T, orders *[]db.Order) {
+				require.NotNil(t, orders, "Orders should not be nil")
+				require.Len(t, *orders, 1, "Should retrieve 1 order")
+				assert.Equal(t, statusCompleted, (*orders)[0].Status, "Second newest order should come second")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		// given
+		s.SetupTest()
+		for _, params := range []db.CreateOrderParams{
+			{UserID: firstUserID, Status: statusPending},
+			{UserID: firstUserID, Status: statusCompleted},
+			{UserID: secondUserID, Status: statusPending},
+		} {
+			_, _, err := s.createTestOrder(&params, &[]db.CreateOrderItemParams{
+				{ProductID: uuid.New(), Quantity: 1, PricePerItem: 1000, Price: 1000},
+			})
+			require.NoError(s.T(), err, "Failed to create order")
+		}
+
+		// when
+		orders, err := s.store.FindAllOrders(s.ctx, tc.findParams)
+
+		// then
+		require.NoError(s.T(), err, tc.name)
+		tc.postCheck(s.T(), orders)
+	}
+}
+
+func (s *OrderStoreSuite) TestListOrders_CreatedRange() {
+	// given: orders of the user created on the first days of January, and an order of another user
+	s.SetupTest()
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 12, 0, 0, 0, time.UTC) }
+	createdAt := make(map[uuid.UUID]time.Time)
+	for _, seed := range []struct {
+		userID    uuid.UUID
+		createdAt time.Time
+	}{
+		{userID, day(1)},
+		{userID, day(2)},
+		{userID, day(3)},
+		{userID, day(4)},
+		{otherUserID, day(2)},
+	} {
+		order, _, err := s.createTestOrder(&db.CreateOrderParams{UserID: seed.userID, Status: "PENDING"}, &[]db.CreateOrderItemParams{
+			{ProductID: uuid.New(), Quantity: 1, PricePerItem: 1000, Price: 1000},
+		})
+		require.NoError(s.T(), err, "Failed to create order")
+		_, err = s.dbPool.Exec(s.ctx, "UPDATE orders SET created_at = $2 WHERE id = $1", order.ID, seed.createdAt)
+		require.NoError(s.T(), err, "Failed to set the creation time")
+		createdAt[order.ID] = seed.createdAt
+	}
+	ptr := func(t time.Time) *time.Time { return &t }
+
+	testCases := []struct {
+		name          string
+		from, to      *time.Time
+		expectedDays  []int // creation days of the expected orders of the user, newest first
+		expectedTotal int   // number of the expected orders of all users
+	}{
+		{name: "No range", expectedDays: []int{4, 3, 2, 1}, expectedTotal: 5},
+		{name: "From is inclusive", from: ptr(day(2)), expectedDays: []int{4, 3, 2}, expectedTotal: 4},
+		{name: "To is exclusive", to: ptr(day(3)), expectedDays: []int{2, 1}, expectedTotal: 3},
+		{name: "Window", from: ptr(day(2)), to: ptr(day(4)), expectedDays: []int{3, 2}, expectedTotal: 3},
+		{name: "Window without orders", from: ptr(day(5)), to: ptr(day(6)), expectedDays: []int{}, expectedTotal: 0},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			// when
+			userOrders, err := s.store.FindOrdersByUserID(s.ctx, &db.FindOrdersByUserIDParams{
+				UserID: userID, CreatedFrom: tc.from, CreatedTo: tc.to, Limit: 10,
+			})
+			require.NoError(s.T(), err)
+			allOrders, err := s.store.FindAllOrders(s.ctx, &db.FindAllOrdersParams{CreatedFrom: tc.from, CreatedTo: tc.to, Limit: 10})
+			require.NoError(s.T(), err)
+
+			// then
+			days := make([]int, 0, len(*userOrders))
+			for _, order := range *userOrders {
+				days = append(days, createdAt[order.ID].Day())
+			}
+			assert.Equal(s.T(), tc.expectedDays, days)
+			assert.Len(s.T(), *allOrders, tc.expectedTotal)
+		})
+	}
+}
+
+func (s *OrderStoreSuite) TestSummarizeByUserID() {
+	// given: orders of the user with mixed statuses and currencies, a deleted order of the user and an order of another user
+	s.SetupTest()
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	for _, order := range []struct {
+		params   db.CreateOrderParams
+		currency string
+	}{
+		{params: db.CreateOrderParams{UserID: userID, Status: "PENDING", TotalPrice: 1000}, currency: "USD"},
+		{params: db.CreateOrderParams{UserID: userID, Status: "COMPLETED", TotalPrice: 2500}, currency: "USD"},
+		{params: db.CreateOrderParams{UserID: userID, Status: "PENDING", TotalPrice: 1500}, currency: "USD"},
+		{params: db.CreateOrderParams{UserID: userID, Status: "CANCELLED", TotalPrice: 700}, currency: "USD"},
+		{params: db.CreateOrderParams{UserID: userID, Status: "COMPLETED", TotalPrice: 9900}, currency: "USD"},
+		{params: db.CreateOrderParams{UserID: userID, Status: "COMPLETED", TotalPrice: 4200}, currency: "EUR"},
+		{params: db.CreateOrderParams{UserID: userID, Status: "PENDING", TotalPrice: 800}, currency: "EUR"},
+		{params: db.CreateOrderParams{UserID: otherUserID, Status: "PENDING", TotalPrice: 4000}, currency: "EUR"},
+	} {
+		_, _, err := s.createTestOrder(&order.params, &[]db.CreateOrderItemParams{
+			{ProductID: uuid.New(), Quantity: 1, PricePerItem: order.params.TotalPrice, Price: order.params.TotalPrice, Currency: order.currency},
+		})
+		require.NoError(s.T(), err, "Failed to create order")
+	}
+	deleted, _, err := s.createTestOrder(&db.CreateOrderParams{UserID: userID, Status: "COMPLETED", TotalPrice: 9900}, &[]db.CreateOrderItemParams{
+		{ProductID: uuid.New(), Quantity: 1, PricePerItem: 9900, Price: 9900, Currency: "EUR"},
+	})
+	require.NoError(s.T(), err, "Failed to create order")
+	err = s.store.Delete(s.ctx, &db.SoftDeleteOrderParams{ID: deleted.ID, Version: deleted.Version})
+	require.NoError(s.T(), err, "Failed to delete order")
+
+	// when
+	summary, err := s.store.SummarizeByUserID(s.ctx, userID)
+
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []db.SummarizeOrdersByUserIDRow{
+		{Status: "CANCELLED", Currency: "USD", OrderCount: 1, TotalPrice: 700},
+		{Status: "COMPLETED", Currency: "EUR", OrderCount: 1, TotalPrice: 4200},
+		{Status: "COMPLETED", Currency: "USD", OrderCount: 2, TotalPrice: 12400},
+		{Status: "PENDING", Currency: "EUR", OrderCount: 1, TotalPrice: 800},
+		{Status: "PENDING", Currency: "USD", OrderCount: 2, TotalPrice: 2500},
+	}, summary, "the live orders of the user should be aggregated by status and currency")
+
+	// when: the user has no orders
+	summary, err = s.store.SummarizeByUserID(s.ctx, uuid.New())
+
+	// then
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), summary)
+}
+
 func (s *OrderStoreSuite) TestUpdateOrder() {
 
 	const statusCompleted = "COMPLETED"
@@ -374,6 +604,12 @@ func (s *OrderStoreSuite) TestUpdateOrder() {
 			if tc.expectedErr != nil {
 				require.ErrorIs(s.T(), err, tc.expectedErr)
 				require.Nil(s.T(), updated)
+				if errors.Is(tc.expectedErr, ordererrors.ErrOptimisticLock) {
+					var lockErr *ordererrors.OptimisticLockError
+					require.ErrorAs(s.T(), err, &lockErr, "the conflict should carry the current state of the order")
+					assert.Equal(s.T(), initialOrder.Version, lockErr.CurrentVersion, "the current version should be reported")
+					assert.Equal(s.T(), initialOrder.Status, lockErr.CurrentStatus, "the current status should be reported")
+				}
 			} else {
 				require.NoError(s.T(), err, "Update should not return an error")
 				require.NotNil(s.T(), updated)
@@ -384,3 +620,178 @@ func (s *OrderStoreSuite) TestUpdateOrder() {
 		})
 	}
 }
+
+func (s *OrderStoreSuite) TestUpdateItems() {
+	nonExistentID := uuid.New()
+
+	testCases := []struct {
+		name              string
+		nonExistedOrderID bool
+		incVersion        int32
+		expectedErr       error
+	}{
+		{
+			name:        "Successful Update",
+			expectedErr: nil,
+		},
+		{
+			name:              "Update Non-Existent Order",
+			nonExistedOrderID: true,
+			expectedErr:       ordererrors.ErrOrderNotFound,
+		},
+		{
+			name:        "Update with Wrong Version",
+			incVersion:  1,
+			expectedErr: ordererrors.ErrOptimisticLock,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			s.SetupTest()
+			// given
+			initialOrder, initialItems, err := s.createTestOrder(&db.CreateOrderParams{UserID: uuid.New(), Status: "PENDING", TotalPrice: 2000}, &[]db.CreateOrderItemParams{
+				{ProductID: uuid.New(), Quantity: 2, PricePerItem: 1000, Price: 2000},
+			})
+			require.NoError(s.T(), err, "CreateOrder should not return an error")
+			newItems := []db.CreateOrderItemParams{
+				{ProductID: uuid.New(), Quantity: 1, PricePerItem: 700, Price: 700},
+				{ProductID: uuid.New(), Quantity: 3, PricePerItem: 100, Price: 300},
+			}
+			input := db.UpdateOrderTotalPriceParams{
+				ID:         initialOrder.ID,
+				Version:    initialOrder.Version + tc.incVersion,
+				TotalPrice: 1000,
+			}
+			if tc.nonExistedOrderID {
+				input.ID = nonExistentID
+			}
+
+			// when
+			updated, updatedItems, err := s.store.UpdateItems(s.ctx, &input, &newItems)
+
+			// then
+			fetchedOrder, fetchedItems, findErr := s.store.FindByID(s.ctx, initialOrder.ID)
+			require.NoError(s.T(), findErr)
+			if tc.expectedErr != nil {
+				require.ErrorIs(s.T(), err, tc.expectedErr)
+				require.Nil(s.T(), updated)
+				require.Nil(s.T(), updatedItems)
+				require.Equal(s.T(), initialOrder.TotalPrice, fetchedOrder.TotalPrice, "Total price should not change")
+				require.Len(s.T(), *fetchedItems, len(*initialItems), "Items should not change")
+				return
+			}
+			require.NoError(s.T(), err, "UpdateItems should not return an error")
+			require.Equal(s.T(), initialOrder.Version+1, updated.Version, "Version should be incremented")
+			require.Len(s.T(), *updatedItems, 2, "Items should be replaced")
+			require.Len(s.T(), *fetchedItems, 2, "Items should be replaced")
+			require.Equal(s.T(), sumItemPrices(*fetchedItems), fetchedOrder.TotalPrice, "Persisted total price should be the sum of the item prices")
+		})
+	}
+}
+
+func (s *OrderStoreSuite) TestDelete() {
+	testCases := []struct {
+		name        string
+		incVersion  int32
+		nonExistent bool
+		expectedErr error
+	}{
+		{name: "Successful Delete"},
+		{name: "Delete with Wrong Version", incVersion: 1, expectedErr: ordererrors.ErrOptimisticLock},
+		{name: "Delete Non-Existent Order", nonExistent: true, expectedErr: ordererrors.ErrOrderNotFound},
+	}
+
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			s.SetupTest()
+			// given
+			userID := uuid.New()
+			order, _, err := s.createTestOrder(&db.CreateOrderParams{UserID: userID, Status: "PENDING"}, &[]db.CreateOrderItemParams{
+				{ProductID: uuid.New(), Quantity: 1, PricePerItem: 1000, Price: 1000},
+			})
+			require.NoError(s.T(), err)
+			_, _, err = s.createTestOrder(&db.CreateOrderParams{UserID: userID, Status: "PENDING"}, &[]db.CreateOrderItemParams{
+				{ProductID: uuid.New(), Quantity: 1, PricePerItem: 1000, Price: 1000},
+			})
+			require.NoError(s.T(), err)
+			params := db.SoftDeleteOrderParams{ID: order.ID, Version: order.Version + tc.incVersion}
+			if tc.nonExistent {
+				params.ID = uuid.New()
+			}
+
+			// when
+			err = s.store.Delete(s.ctx, &params)
+
+			// then
+			if tc.expectedErr != nil {
+				require.ErrorIs(s.T(), err, tc.expectedErr)
+				_, _, err := s.store.FindByID(s.ctx, order.ID)
+				require.NoError(s.T(), err, "the order should not be deleted")
+				return
+			}
+			require.NoError(s.T(), err)
+			_, _, err = s.store.FindByID(s.ctx, order.ID)
+			require.ErrorIs(s.T(), err, ordererrors.ErrOrderNotFound, "the deleted order should not be found")
+			userOrders, err := s.store.FindOrdersByUserID(s.ctx, &db.FindOrdersByUserIDParams{UserID: userID, Limit: 10})
+			require.NoError(s.T(), err)
+			require.Len(s.T(), *userOrders, 1, "the deleted order should not be listed for the user")
+			assert.NotEqual(s.T(), order.ID, (*userOrders)[0].ID)
+			allOrders, err := s.store.FindAllOrders(s.ctx, &db.FindAllOrdersParams{Limit: 10})
+			require.NoError(s.T(), err)
+			require.Len(s.T(), *allOrders, 1, "the deleted order should not be listed for admins")
+			var deletedItems int
+			err = s.dbPool.QueryRow(s.ctx, "SELECT count(*) FROM order_items WHERE order_id = $1 AND deleted_at IS NOT NULL", order.ID).Scan(&deletedItems)
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), 1, deletedItems, "the items should be soft-deleted with the order")
+			_, err = s.store.Update(s.ctx, &db.UpdateOrderParams{ID: order.ID, Status: "COMPLETED", Version: order.Version + 1})
+			require.ErrorIs(s.T(), err, ordererrors.ErrOrderNotFound, "the deleted order should not be updated")
+			err = s.store.Delete(s.ctx, &db.SoftDeleteOrderParams{ID: order.ID, Version: order.Version + 1})
+			require.ErrorIs(s.T(), err, ordererrors.ErrOrderNotFound, "the deleted order should not be deleted again")
+		})
+	}
+}
+
+func (s *OrderStoreSuite) TestCreateOrder_QueryTimeout() {
+	s.SetupTest()
+	// given: inserting an order item takes longer than the query timeout
+	_, err := s.dbPool.Exec(s.ctx, `
+		CREATE FUNCTION slow_insert() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_sleep(1);
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+		CREATE TRIGGER slow_order_items BEFORE INSERT ON order_items FOR EACH ROW EXECUTE FUNCTION slow_insert();`)
+	require.NoError(s.T(), err, "Failed to create the slow trigger")
+	defer func() {
+		_, err := s.dbPool.Exec(s.ctx, "DROP TRIGGER slow_order_items ON order_items; DROP FUNCTION slow_insert()")
+		require.NoError(s.T(), err, "Failed to drop the slow trigger")
+	}()
+	store := NewPgStore(s.dbPool, 200*time.Millisecond, nil)
+	orderToCreate := db.CreateOrderParams{
+		UserID:     uuid.New(),
+		Status:     "PENDING",
+		TotalPrice: 1000,
+	}
+	orderItemToCreate := []db.CreateOrderItemParams{{
+		ProductID:    uuid.New(),
+		Quantity:     1,
+		PricePerItem: 1000,
+		Price:        1000,
+	}}
+
+	// when
+	start := time.Now()
+	createdOrder, createdItems, err := store.CreateOrder(s.ctx, &orderToCreate, &orderItemToCreate)
+
+	// then
+	require.ErrorIs(s.T(), err, ordererrors.ErrQueryTimeout)
+	require.Less(s.T(), time.Since(start), time.Second, "CreateOrder should not wait for the slow query")
+	require.Nil(s.T(), createdOrder)
+	require.Nil(s.T(), createdItems)
+	var count int
+	err = s.dbPool.QueryRow(s.ctx, "SELECT count(*) FROM orders WHERE user_id = $1", orderToCreate.UserID).Scan(&count)
+	require.NoError(s.T(), err, "The pool should be usable after the timeout")
+	require.Zero(s.T(), count, "The transaction should be rolled back")
+}
@@ -1,31 +1,61 @@
 // Package rest provides HTTP handlers for order-related operations.
+//
+// Errors are reported as {"error": "<message>", "code": "<CODE>"}, optionally with details, and mapped to status codes as follows:
+//   - 400 Bad Request: the request is malformed, e.g. invalid JSON, an unknown field, failed validation, too many items,
+//     an item quantity exceeding the maximum, items priced in different currencies or an invalid time range.
+//   - 413 Request Entity Too Large: the request body exceeds the size limit of the HTTP server.
+//   - 415 Unsupported Media Type: the request body of a write request is not JSON.
+//   - 422 Unprocessable Entity: the request is well-formed, but can't be processed, e.g. insufficient stock
+//     or a resent confirmation of an order whose owner doesn't exist anymore.
+//     Responds with 400 instead unless OrdersConfig.UnprocessableEntity is enabled.
+//   - 403 Forbidden: the user has no access to the order, the email address is not verified or the admin role is missing.
+//   - 404 Not Found: the order does not exist.
+//   - 409 Conflict: the order has been modified concurrently or its items are modified, but it's not pending.
+//     A concurrent modification includes the current_version and current_status of the order if OrdersConfig.ConflictDetails is enabled.
 package rest
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/abgdnv/gocommerce/order_service/internal/config"
 	ordererrors "github.com/abgdnv/gocommerce/order_service/internal/errors"
 	"github.com/abgdnv/gocommerce/order_service/internal/service"
+	"github.com/abgdnv/gocommerce/pkg/money"
 	"github.com/abgdnv/gocommerce/pkg/web"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 )
 
+// ordersPath is the path of the order resources below the base path.
+const ordersPath = "/orders"
+
+// adminOrdersPath is the path of the order resources available to admins only below the base path.
+const adminOrdersPath = "/admin/orders"
+
 type Handler struct {
 	service  service.OrderService
+	cfg      config.OrdersConfig
+	basePath string
 	validate *validator.Validate
 	logger   *slog.Logger
 }
 
 // NewHandler creates a new instance of OrderAPI with the provided service.
-func NewHandler(service service.OrderService, logger *slog.Logger) *Handler {
+// The limits of the configuration guard the decoding of request bodies, zero values disable them.
+// The routes are registered below basePath, e.g. /api/v1.
+func NewHandler(service service.OrderService, cfg config.OrdersConfig, basePath string, logger *slog.Logger) *Handler {
+	validate := validator.New()
+	money.RegisterValidation(validate)
 	return &Handler{
 		service:  service,
-		validate: validator.New(),
+		cfg:      cfg,
+		basePath: basePath,
+		validate: validate,
 
 		logger: logger.With("component", "rest"),
 	}
@@ -35,17 +65,27 @@ func NewHandler(service service.OrderService, logger *slog.Logger) *Handler {
 func (h *Handler) RegisterRoutes(r *chi.Mux) {
 	r.Group(func(r chi.Router) {
 		r.Use(web.AuthMiddleware)
-		r.Route("/api/v1/orders", func(r chi.Router) {
+		// the write requests are rejected with 415 unless their bodies are JSON
+		r.Use(web.RequireJSON)
+		r.Route(h.basePath+ordersPath, func(r chi.Router) {
 			r.Get("/", h.FindOrdersByUserID)
 			r.Post("/", h.Create)
+			r.Get("/summary", h.Summary)
 
 			r.Route("/{id}", func(r chi.Router) {
 				r.Get("/", h.FindByID)
 				r.Put("/", h.Update)
+				r.Put("/items", h.UpdateItems)
 			})
 		})
+		r.Route(h.basePath+adminOrdersPath, func(r chi.Router) {
+			r.Use(web.RequireRole(web.RoleAdmin))
+			r.Get("/", h.FindAllOrders)
+			r.Get("/{id}", h.FindAnyByID)
+			r.Delete("/{id}", h.DeleteByID)
+			r.Post("/{id}/resend-notification", h.ResendNotification)
+		})
 	})
-	r.Get("/healthz", h.HealthCheck)
 }
 
 // FindByID retrieves an order by its ID.
@@ -66,15 +106,15 @@ func (h *Handler) FindByID(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, ordererrors.ErrOrderNotFound) {
 			h.logger.WarnContext(r.Context(), "Order not found", "ID", id)
-			web.RespondError(w, h.logger, http.StatusNotFound, fmt.Sprintf("Order with ID %s not found", id))
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Order with ID %s not found", id))
 			return
 		} else if errors.Is(err, ordererrors.ErrAccessDenied) {
 			h.logger.WarnContext(r.Context(), "Access denied to order", "ID", id, "UserID", userID)
-			web.RespondError(w, h.logger, http.StatusForbidden, fmt.Sprintf("Access denied to order with ID %s", id))
+			h.respondError(w, http.StatusForbidden, err, fmt.Sprintf("Access denied to order with ID %s", id))
 			return
 		}
 		h.logger.ErrorContext(r.Context(), "Error retrieving order", "ID", id, "error", err)
-		web.RespondError(w, h.logger, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve order with ID %s", id))
+		h.respondServerError(w, err, fmt.Sprintf("Failed to retrieve order with ID %s", id))
 		return
 	}
 	h.logger.DebugContext(r.Context(), "Successfully retrieved order", slog.String("ID", found.ID.String()))
@@ -82,30 +122,101 @@ func (h *Handler) FindByID(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// FindAnyByID retrieves an order of any user by its ID.
+func (h *Handler) FindAnyByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := web.ParseID(w, r, h.logger)
+	if !ok {
+		return
+	}
+
+	h.logger.DebugContext(r.Context(), "Received request to find order of any user by ID", "ID", id)
+	found, err := h.service.FindAnyByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ordererrors.ErrOrderNotFound) {
+			h.logger.WarnContext(r.Context(), "Order not found", "ID", id)
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Order with ID %s not found", id))
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "Error retrieving order", "ID", id, "error", err)
+		h.respondServerError(w, err, fmt.Sprintf("Failed to retrieve order with ID %s", id))
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Successfully retrieved order", slog.String("ID", found.ID.String()))
+	web.RespondJSON(w, h.logger, http.StatusOK, found)
+}
+
 // FindOrdersByUserID retrieves a list of all orders.
 func (h *Handler) FindOrdersByUserID(w http.ResponseWriter, r *http.Request) {
-	limit, ok := web.ParseValidateGt(r, w, h.logger, "limit", 0)
+	page, ok := web.ParsePagination(r, w, h.logger, 0)
 	if !ok {
 		return
 	}
-	offset, ok := web.ParseValidateGte(r, w, h.logger, "offset", 0)
+	userID, ok := web.GetUserID(w, r, h.logger)
 	if !ok {
 		return
 	}
-	userID, ok := web.GetUserID(w, r, h.logger)
+	from, to, ok := h.parseCreatedRange(w, r)
 	if !ok {
 		return
 	}
 
-	h.logger.DebugContext(r.Context(), "Received request to find all orders", "limit", limit, "offset", offset)
-	list, err := h.service.FindOrdersByUserID(r.Context(), userID, offset, limit)
+	h.logger.DebugContext(r.Context(), "Received request to find all orders", "limit", page.Limit, "offset", page.Offset, "from", from, "to", to)
+	list, err := h.service.FindOrdersByUserID(r.Context(), userID, page.Offset, page.Limit, from, to)
 	if err != nil && errors.Is(err, ordererrors.ErrAccessDenied) {
 		h.logger.WarnContext(r.Context(), "Access denied to order list", "UserID", userID)
-		web.RespondError(w, h.logger, http.StatusForbidden, "Access denied")
+		h.respondError(w, http.StatusForbidden, err, "Access denied")
+		return
+	} else if err != nil && errors.Is(err, ordererrors.ErrInvalidTimeRange) {
+		h.respondInvalidTimeRange(w, r, err, from, to)
 		return
 	} else if err != nil {
 		h.logger.ErrorContext(r.Context(), "Error retrieving order list", "error", err)
-		web.RespondError(w, h.logger, http.StatusInternalServerError, "Failed to fetch orders")
+		h.respondServerError(w, err, "Failed to fetch orders")
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Successfully retrieved order list", "count", len(*list))
+	web.RespondJSON(w, h.logger, http.StatusOK, *list)
+}
+
+// Summary retrieves the number and the total price of the orders of the user, overall and by status.
+func (h *Handler) Summary(w http.ResponseWriter, r *http.Request) {
+	userID, ok := web.GetUserID(w, r, h.logger)
+	if !ok {
+		return
+	}
+
+	h.logger.DebugContext(r.Context(), "Received request to summarize orders", "UserID", userID)
+	summary, err := h.service.SummaryForUser(r.Context(), userID)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Error summarizing orders", "UserID", userID, "error", err)
+		h.respondServerError(w, err, "Failed to summarize orders")
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Successfully summarized orders", "total", summary.TotalOrders)
+	web.RespondJSON(w, h.logger, http.StatusOK, summary)
+}
+
+// FindAllOrders retrieves a list of orders of all users, optionally filtered by the status query parameter
+// and the creation time range of the from and to query parameters.
+func (h *Handler) FindAllOrders(w http.ResponseWriter, r *http.Request) {
+	page, ok := web.ParsePagination(r, w, h.logger, 0)
+	if !ok {
+		return
+	}
+	status := r.URL.Query().Get("status")
+	from, to, ok := h.parseCreatedRange(w, r)
+	if !ok {
+		return
+	}
+
+	h.logger.DebugContext(r.Context(), "Received request to find orders of all users", "limit", page.Limit, "offset", page.Offset, "status", status, "from", from, "to", to)
+	list, err := h.service.FindAllOrders(r.Context(), page.Offset, page.Limit, status, from, to)
+	if err != nil && errors.Is(err, ordererrors.ErrInvalidTimeRange) {
+		h.respondInvalidTimeRange(w, r, err, from, to)
+		return
+	} else if err != nil {
+		h.logger.ErrorContext(r.Context(), "Error retrieving order list", "error", err)
+		h.respondServerError(w, err, "Failed to fetch orders")
 		return
 	}
 	h.logger.DebugContext(r.Context(), "Successfully retrieved order list", "count", len(*list))
@@ -119,45 +230,59 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var OrderCreateDto service.OrderCreateDto
-	if err := json.NewDecoder(r.Body).Decode(&OrderCreateDto); err != nil {
-		h.logger.ErrorContext(r.Context(), "Error decoding request body", "error", err)
-		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+	if !h.decodeBody(w, r, &OrderCreateDto) {
+		return
+	}
+	if h.cfg.MaxItems > 0 && len(OrderCreateDto.Items) > h.cfg.MaxItems {
+		h.logger.WarnContext(r.Context(), "Too many order items", "count", len(OrderCreateDto.Items), "max", h.cfg.MaxItems)
+		web.RespondError(w, h.logger, http.StatusBadRequest, fmt.Sprintf("Too many order items: maximum is %d", h.cfg.MaxItems))
 		return
 	}
-	// Set the user ID in the order creation DTO.
+	// Set the user ID, email address and email verification status in the order creation DTO.
 	OrderCreateDto.UserID = userID
+	OrderCreateDto.Email = web.GetUserEmail(r.Context())
+	OrderCreateDto.EmailVerified = web.IsEmailVerified(r.Context())
 
 	h.logger.DebugContext(r.Context(), "Received request to create order", "order", OrderCreateDto)
-	if err := h.validate.Struct(OrderCreateDto); err != nil {
-		var validationErrors validator.ValidationErrors
-		if errors.As(err, &validationErrors) {
-			// If the error is a validation error, we can extract field-specific errors.
-			errorResponse := make(map[string]string)
-			for _, fieldErr := range validationErrors {
-				// fieldErr.Tag() returns "required", "max", etc.
-				errorResponse[fieldErr.Field()] = "failed on rule: " + fieldErr.Tag()
-			}
-			h.logger.WarnContext(r.Context(), "Validation errors occurred", "errors", errorResponse)
-			web.RespondJSON(w, h.logger, http.StatusBadRequest, map[string]any{"validation_errors": errorResponse})
-			return
-		}
-		h.logger.ErrorContext(r.Context(), "Error validating request body", "error", err)
-		// If it's not a validation error, we can return a generic error.
-		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+	if err := web.Validate(h.validate, OrderCreateDto); err != nil {
+		web.RespondValidationError(w, r, h.logger, err)
 		return
 	}
 
 	newOrder, err := h.service.Create(r.Context(), OrderCreateDto)
-	if err != nil && errors.Is(err, ordererrors.ErrInsufficientStock) {
-		web.RespondError(w, h.logger, http.StatusBadRequest, err.Error())
+	var stockErr *ordererrors.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		// Report every failing item, so the client can show the available stock and the restock time
+		web.RespondJSON(w, h.logger, h.unprocessableStatus(), map[string]any{"error": stockErr.Error(), "code": ordererrors.CodeInsufficientStock, "items": stockErr.Items})
+		return
+	} else if err != nil && errors.Is(err, ordererrors.ErrInsufficientStock) {
+		h.respondError(w, h.unprocessableStatus(), err, err.Error())
+		return
+	} else if err != nil && errors.Is(err, ordererrors.ErrInvalidStatus) {
+		h.respondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	} else if err != nil && errors.Is(err, ordererrors.ErrQuantityExceedsMax) {
+		h.respondQuantityExceedsMax(w, r, err)
+		return
+	} else if err != nil && errors.Is(err, ordererrors.ErrMixedCurrencies) {
+		h.respondMixedCurrencies(w, r, err)
+		return
+	} else if err != nil && errors.Is(err, ordererrors.ErrProductNotFound) {
+		h.respondProductNotFound(w, r, err)
+		return
+	} else if err != nil && errors.Is(err, ordererrors.ErrEmailNotVerified) {
+		h.logger.WarnContext(r.Context(), "Order creation rejected for unverified email", "UserID", userID)
+		h.respondError(w, http.StatusForbidden, err, "Email address must be verified to create orders")
+		return
+	} else if errors.Is(err, ordererrors.ErrQueryTimeout) {
+		h.respondError(w, http.StatusGatewayTimeout, err, "Database query timed out")
 		return
 	} else if err != nil {
-		errStatus, message := web.MapGrpcToHttpStatus(err)
-		web.RespondError(w, h.logger, errStatus, message)
+		h.respondProductError(w, err)
 		return
 	}
 	h.logger.InfoContext(r.Context(), "Order created successfully", slog.String("ID", newOrder.ID.String()))
-	web.RespondJSON(w, h.logger, http.StatusCreated, newOrder)
+	web.RespondCreated(w, h.logger, h.location(newOrder.ID.String()), newOrder)
 }
 
 func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
@@ -171,28 +296,16 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 	h.logger.DebugContext(r.Context(), "Received request to update order", "ID", id)
 	var orderUpdateDto service.OrderUpdateDto
-	if err := json.NewDecoder(r.Body).Decode(&orderUpdateDto); err != nil {
-		h.logger.ErrorContext(r.Context(), "Error decoding request body", "error", err)
-		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+	if !h.decodeBody(w, r, &orderUpdateDto) {
 		return
 	}
 
-	// Set the ID in the order update DTO.
+	// Set the ID and the user's email address in the order update DTO.
 	orderUpdateDto.ID = id
+	orderUpdateDto.Email = web.GetUserEmail(r.Context())
 
-	if err := h.validate.Struct(orderUpdateDto); err != nil {
-		var validationErrors validator.ValidationErrors
-		if errors.As(err, &validationErrors) {
-			errorResponse := make(map[string]string)
-			for _, fieldErr := range validationErrors {
-				errorResponse[fieldErr.Field()] = "failed on rule: " + fieldErr.Tag()
-			}
-			h.logger.WarnContext(r.Context(), "Validation errors occurred", "errors", errorResponse)
-			web.RespondJSON(w, h.logger, http.StatusBadRequest, map[string]any{"validation_errors": errorResponse})
-			return
-		}
-		h.logger.ErrorContext(r.Context(), "Error validating request body", "error", err)
-		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+	if err := web.Validate(h.validate, orderUpdateDto); err != nil {
+		web.RespondValidationError(w, r, h.logger, err)
 		return
 	}
 
@@ -200,26 +313,284 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, ordererrors.ErrOrderNotFound) {
 			h.logger.WarnContext(r.Context(), "Order not found for update", "ID", id)
-			web.RespondError(w, h.logger, http.StatusNotFound, fmt.Sprintf("Order with ID %s not found", id))
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Order with ID %s not found", id))
 			return
 		} else if errors.Is(err, ordererrors.ErrOptimisticLock) {
-			h.logger.WarnContext(r.Context(), "Optimistic lock error during order update", "ID", id)
-			web.RespondError(w, h.logger, http.StatusConflict, fmt.Sprintf("Order with ID %s has been modified by another user", id))
+			h.logger.WarnContext(r.Context(), "Optimistic lock error during order update", "ID", id, "error", err)
+			h.respondConflict(w, err, id)
 			return
 		} else if errors.Is(err, ordererrors.ErrAccessDenied) {
 			h.logger.WarnContext(r.Context(), "Access denied to order update", "ID", id, "UserID", userID)
-			web.RespondError(w, h.logger, http.StatusForbidden, fmt.Sprintf("Access denied to order with ID %s", id))
+			h.respondError(w, http.StatusForbidden, err, fmt.Sprintf("Access denied to order with ID %s", id))
 			return
 		}
 		h.logger.ErrorContext(r.Context(), "Error updating order", "ID", id, "error", err)
-		web.RespondError(w, h.logger, http.StatusInternalServerError, fmt.Sprintf("Failed to update order with ID %s", id))
+		h.respondServerError(w, err, fmt.Sprintf("Failed to update order with ID %s", id))
 		return
 	}
 	h.logger.InfoContext(r.Context(), "Order updated successfully", slog.String("ID", updated.ID.String()))
 	web.RespondJSON(w, h.logger, http.StatusOK, updated)
 }
 
-// HealthCheck is a simple health check endpoint.
-func (h *Handler) HealthCheck(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
+// UpdateItems handles the replacement of the items of a pending order.
+func (h *Handler) UpdateItems(w http.ResponseWriter, r *http.Request) {
+	id, ok := web.ParseID(w, r, h.logger)
+	if !ok {
+		return
+	}
+	userID, ok := web.GetUserID(w, r, h.logger)
+	if !ok {
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to update order items", "ID", id)
+	var itemsUpdateDto service.OrderItemsUpdateDto
+	if !h.decodeBody(w, r, &itemsUpdateDto) {
+		return
+	}
+	if h.cfg.MaxItems > 0 && len(itemsUpdateDto.Items) > h.cfg.MaxItems {
+		h.logger.WarnContext(r.Context(), "Too many order items", "count", len(itemsUpdateDto.Items), "max", h.cfg.MaxItems)
+		web.RespondError(w, h.logger, http.StatusBadRequest, fmt.Sprintf("Too many order items: maximum is %d", h.cfg.MaxItems))
+		return
+	}
+
+	if err := web.Validate(h.validate, itemsUpdateDto); err != nil {
+		web.RespondValidationError(w, r, h.logger, err)
+		return
+	}
+
+	updated, err := h.service.UpdateItems(r.Context(), userID, id, itemsUpdateDto.Items, itemsUpdateDto.Version)
+	var stockErr *ordererrors.InsufficientStockError
+	if errors.As(err, &stockErr) {
+		web.RespondJSON(w, h.logger, h.unprocessableStatus(), map[string]any{"error": stockErr.Error(), "code": ordererrors.CodeInsufficientStock, "items": stockErr.Items})
+		return
+	} else if errors.Is(err, ordererrors.ErrQuantityExceedsMax) {
+		h.respondQuantityExceedsMax(w, r, err)
+		return
+	} else if errors.Is(err, ordererrors.ErrMixedCurrencies) {
+		h.respondMixedCurrencies(w, r, err)
+		return
+	} else if errors.Is(err, ordererrors.ErrProductNotFound) {
+		h.respondProductNotFound(w, r, err)
+		return
+	} else if errors.Is(err, ordererrors.ErrOrderNotFound) {
+		h.logger.WarnContext(r.Context(), "Order not found for items update", "ID", id)
+		h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Order with ID %s not found", id))
+		return
+	} else if errors.Is(err, ordererrors.ErrOrderNotPending) {
+		h.respondError(w, http.StatusConflict, err, fmt.Sprintf("Items of order with ID %s can't be modified: the order is not pending", id))
+		return
+	} else if errors.Is(err, ordererrors.ErrOptimisticLock) {
+		h.logger.WarnContext(r.Context(), "Optimistic lock error during order items update", "ID", id, "error", err)
+		h.respondConflict(w, err, id)
+		return
+	} else if errors.Is(err, ordererrors.ErrAccessDenied) {
+		h.logger.WarnContext(r.Context(), "Access denied to order items update", "ID", id, "UserID", userID)
+		h.respondError(w, http.StatusForbidden, err, fmt.Sprintf("Access denied to order with ID %s", id))
+		return
+	} else if errors.Is(err, ordererrors.ErrQueryTimeout) {
+		h.logger.ErrorContext(r.Context(), "Error updating order items", "ID", id, "error", err)
+		h.respondError(w, http.StatusGatewayTimeout, err, "Database query timed out")
+		return
+	} else if err != nil {
+		h.logger.ErrorContext(r.Context(), "Error updating order items", "ID", id, "error", err)
+		h.respondProductError(w, err)
+		return
+	}
+	h.logger.InfoContext(r.Context(), "Order items updated successfully", slog.String("ID", updated.ID.String()))
+	web.RespondJSON(w, h.logger, http.StatusOK, updated)
+}
+
+// ResendNotification publishes the order created event of an order of any user again, so its confirmation is resent
+// to the email address of the owner of the order. Responds with 202, as the notification is sent asynchronously.
+func (h *Handler) ResendNotification(w http.ResponseWriter, r *http.Request) {
+	id, ok := web.ParseID(w, r, h.logger)
+	if !ok {
+		return
+	}
+
+	h.logger.DebugContext(r.Context(), "Received request to resend order notification", "ID", id)
+	if err := h.service.ResendCreatedEvent(r.Context(), id); err != nil {
+		if errors.Is(err, ordererrors.ErrOrderNotFound) {
+			h.logger.WarnContext(r.Context(), "Order not found for notification resend", "ID", id)
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Order with ID %s not found", id))
+			return
+		}
+		if errors.Is(err, ordererrors.ErrUserNotFound) {
+			h.logger.WarnContext(r.Context(), "Owner of order not found for notification resend", "ID", id, "error", err)
+			h.respondError(w, h.unprocessableStatus(), err, fmt.Sprintf("Owner of order with ID %s not found", id))
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "Error resending order notification", "ID", id, "error", err)
+		h.respondServerError(w, err, fmt.Sprintf("Failed to resend notification of order with ID %s", id))
+		return
+	}
+	h.logger.InfoContext(r.Context(), "Order notification resent", "ID", id)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// DeleteByID soft-deletes an order of any user, the version query parameter is required for optimistic locking.
+func (h *Handler) DeleteByID(w http.ResponseWriter, r *http.Request) {
+	id, ok := web.ParseID(w, r, h.logger)
+	if !ok {
+		return
+	}
+	version, ok := web.ParseValidateGte(r, w, h.logger, "version", 1)
+	if !ok {
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to delete order", "ID", id, "Version", version)
+	if err := h.service.Delete(r.Context(), id, version); err != nil {
+		if errors.Is(err, ordererrors.ErrOrderNotFound) {
+			h.logger.WarnContext(r.Context(), "Order not found for deletion", "ID", id)
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Order with ID %s not found", id))
+			return
+		} else if errors.Is(err, ordererrors.ErrOptimisticLock) {
+			h.logger.WarnContext(r.Context(), "Optimistic lock error during order deletion", "ID", id, "error", err)
+			h.respondConflict(w, err, id)
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "Error deleting order", "ID", id, "error", err)
+		h.respondServerError(w, err, fmt.Sprintf("Failed to delete order with ID %s", id))
+		return
+	}
+	h.logger.InfoContext(r.Context(), "Order deleted successfully", "ID", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseCreatedRange parses the optional RFC 3339 from and to query parameters of the creation time range,
+// a missing parameter is the zero time. It responds with 400 and returns false if a parameter is malformed.
+func (h *Handler) parseCreatedRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	from, ok := h.parseTime(w, r, "from")
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	to, ok := h.parseTime(w, r, "to")
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// parseTime parses the optional RFC 3339 time query parameter, a missing parameter is the zero time.
+// It responds with 400 and returns false if the parameter is malformed.
+func (h *Handler) parseTime(w http.ResponseWriter, r *http.Request, param string) (time.Time, bool) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid time query parameter", "param", param, "value", value)
+		h.respondError(w, http.StatusBadRequest, ordererrors.ErrInvalidTimeRange, fmt.Sprintf("Invalid %s: %s, must be an RFC 3339 time", param, value))
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// respondInvalidTimeRange responds with 400 to a creation time range with from not before to.
+func (h *Handler) respondInvalidTimeRange(w http.ResponseWriter, r *http.Request, err error, from, to time.Time) {
+	h.logger.WarnContext(r.Context(), "Invalid order creation time range", "from", from, "to", to, "error", err)
+	h.respondError(w, http.StatusBadRequest, err, "Invalid time range: from must be before to")
+}
+
+// respondQuantityExceedsMax responds with 400 to an order item with a quantity exceeding the maximum.
+func (h *Handler) respondQuantityExceedsMax(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.WarnContext(r.Context(), "Order item quantity exceeds the maximum", "error", err)
+	h.respondError(w, http.StatusBadRequest, err, fmt.Sprintf("Quantity of an order item exceeds the maximum of %d", h.cfg.MaxItemQuantity))
+}
+
+// respondMixedCurrencies responds with 400 to order items priced in different currencies.
+func (h *Handler) respondMixedCurrencies(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.WarnContext(r.Context(), "Order items are priced in different currencies", "error", err)
+	h.respondError(w, http.StatusBadRequest, err, "Order items must be priced in a single currency")
+}
+
+// respondProductNotFound responds with 400 to order items of products which don't exist,
+// listing all of them, so the client can remove them at once.
+func (h *Handler) respondProductNotFound(w http.ResponseWriter, r *http.Request, err error) {
+	h.logger.WarnContext(r.Context(), "Order items of unknown products", "error", err)
+	var notFoundErr *ordererrors.ProductNotFoundError
+	if !errors.As(err, &notFoundErr) {
+		h.respondError(w, http.StatusBadRequest, err, err.Error())
+		return
+	}
+	web.RespondJSON(w, h.logger, http.StatusBadRequest, map[string]any{"error": notFoundErr.Error(), "code": ordererrors.CodeProductNotFound, "product_ids": notFoundErr.ProductIDs})
+}
+
+// respondConflict responds with 409 to a concurrent modification of the order.
+// The current version and status of the order are included if the conflict details are enabled,
+// so the client can reconcile its changes and retry.
+func (h *Handler) respondConflict(w http.ResponseWriter, err error, id uuid.UUID) {
+	message := fmt.Sprintf("Order with ID %s has been modified by another user", id)
+	var lockErr *ordererrors.OptimisticLockError
+	if h.cfg.ConflictDetails && errors.As(err, &lockErr) {
+		web.RespondJSON(w, h.logger, http.StatusConflict, map[string]any{
+			"error":           message,
+			"code":            ordererrors.CodeOptimisticLock,
+			"current_version": lockErr.CurrentVersion,
+			"current_status":  lockErr.CurrentStatus,
+		})
+		return
+	}
+	h.respondError(w, http.StatusConflict, err, message)
+}
+
+// respondServerError responds with 504 if a database query timed out, otherwise with 500 and the message.
+func (h *Handler) respondServerError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, ordererrors.ErrQueryTimeout) {
+		h.respondError(w, http.StatusGatewayTimeout, err, "Database query timed out")
+		return
+	}
+	web.RespondError(w, h.logger, http.StatusInternalServerError, message)
+}
+
+// respondProductError responds to a failed call of the Product service with the status mapped from its gRPC status.
+// An unavailable Product service, e.g. refusing connections or behind the open circuit breaker, is a 503 the client can retry,
+// unlike the 504 of a call which timed out.
+func (h *Handler) respondProductError(w http.ResponseWriter, err error) {
+	errStatus, message := web.MapGrpcToHttpStatus(err)
+	if errStatus == http.StatusServiceUnavailable {
+		message = "Product service unavailable, please retry"
+	}
+	web.RespondError(w, h.logger, errStatus, message)
+}
+
+// respondError responds with the status and the message, and the code of the order error err wraps.
+func (h *Handler) respondError(w http.ResponseWriter, status int, err error, message string) {
+	web.RespondAppError(w, h.logger, web.NewAppError(status, ordererrors.Code(err), message))
+}
+
+// location returns the URL of the order with the given ID, or empty if the Location header is disabled.
+func (h *Handler) location(id string) string {
+	if !h.cfg.LocationHeader {
+		return ""
+	}
+	return h.basePath + ordersPath + "/" + id
+}
+
+// unprocessableStatus returns the status code for well-formed requests which can't be processed.
+func (h *Handler) unprocessableStatus() int {
+	if h.cfg.UnprocessableEntity {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
+
+// decodeBody decodes the JSON request body into v, rejecting bodies nested deeper than the configured limit
+// and, if OrdersConfig.StrictJSON is enabled, bodies with unknown fields.
+// It responds with 413 if the body exceeds the size limit, with 400 if it can't be decoded otherwise, and returns false.
+func (h *Handler) decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := web.DecodeJSON(r.Body, v, h.cfg.MaxJSONDepth, h.cfg.StrictJSON)
+	if web.RespondBodyTooLarge(w, r, h.logger, err) || web.RespondUnknownField(w, r, h.logger, err) {
+		return false
+	} else if errors.Is(err, web.ErrJSONTooDeep) {
+		h.logger.WarnContext(r.Context(), "Request body is nested too deeply", "error", err)
+		web.RespondError(w, h.logger, http.StatusBadRequest, fmt.Sprintf("Request body is nested too deeply: maximum depth is %d", h.cfg.MaxJSONDepth))
+		return false
+	} else if err != nil {
+		h.logger.ErrorContext(r.Context(), "Error decoding request body", "error", err)
+		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	return true
 }
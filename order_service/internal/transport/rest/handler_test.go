@@ -13,18 +13,32 @@ import (
 	"testing"
 	"time"
 
+	"github.com/abgdnv/gocommerce/order_service/internal/config"
 	ordererrors "github.com/abgdnv/gocommerce/order_service/internal/errors"
 	"github.com/abgdnv/gocommerce/order_service/internal/service"
+	pconfig "github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/money"
 	"github.com/abgdnv/gocommerce/pkg/web"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // mockOrderService is a mock implementation of the OrderService interface
 type mockOrderService struct {
-	order  *service.OrderDto
-	orders []service.OrderDto
-	error  error
+	order     *service.OrderDto
+	orders    []service.OrderDto
+	error     error
+	createDto service.OrderCreateDto // captures the DTO passed to Create
+	status    string                 // captures the status filter passed to FindAllOrders
+	from, to  time.Time              // capture the creation time range passed to the list methods
+	deleted   *uuid.UUID             // captures the ID passed to Delete
+	summary   *service.OrderSummaryDto
+	summaryOf uuid.UUID  // captures the user passed to SummaryForUser
+	foundAny  *uuid.UUID // captures the ID passed to FindAnyByID
+	resent    *uuid.UUID // captures the ID passed to ResendCreatedEvent
 }
 
 func (m *mockOrderService) FindByID(_ context.Context, _ uuid.UUID, _ uuid.UUID) (*service.OrderDto, error) {
@@ -34,14 +48,41 @@ func (m *mockOrderService) FindByID(_ context.Context, _ uuid.UUID, _ uuid.UUID)
 	return m.order, nil
 }
 
-func (m *mockOrderService) FindOrdersByUserID(_ context.Context, _ uuid.UUID, _, _ int32) (*[]service.OrderDto, error) {
+func (m *mockOrderService) FindAnyByID(_ context.Context, id uuid.UUID) (*service.OrderDto, error) {
+	m.foundAny = &id
+	if m.error != nil {
+		return nil, m.error
+	}
+	return m.order, nil
+}
+
+func (m *mockOrderService) FindOrdersByUserID(_ context.Context, _ uuid.UUID, _, _ int32, createdFrom, createdTo time.Time) (*[]service.OrderDto, error) {
+	m.from, m.to = createdFrom, createdTo
+	if m.error != nil {
+		return nil, m.error
+	}
+	return &m.orders, nil
+}
+
+func (m *mockOrderService) FindAllOrders(_ context.Context, _, _ int32, statusFilter string, createdFrom, createdTo time.Time) (*[]service.OrderDto, error) {
+	m.status = statusFilter
+	m.from, m.to = createdFrom, createdTo
 	if m.error != nil {
 		return nil, m.error
 	}
 	return &m.orders, nil
 }
 
-func (m *mockOrderService) Create(_ context.Context, _ service.OrderCreateDto) (*service.OrderDto, error) {
+func (m *mockOrderService) SummaryForUser(_ context.Context, userID uuid.UUID) (*service.OrderSummaryDto, error) {
+	m.summaryOf = userID
+	if m.error != nil {
+		return nil, m.error
+	}
+	return m.summary, nil
+}
+
+func (m *mockOrderService) Create(_ context.Context, dto service.OrderCreateDto) (*service.OrderDto, error) {
+	m.createDto = dto
 	if m.error != nil {
 		return nil, m.error
 	}
@@ -55,12 +96,31 @@ func (m *mockOrderService) Update(_ context.Context, _ uuid.UUID, _ service.Orde
 	return m.order, nil
 }
 
+func (m *mockOrderService) UpdateItems(_ context.Context, _, _ uuid.UUID, _ []service.OrderItemUpdateDto, _ int32) (*service.OrderDto, error) {
+	if m.error != nil {
+		return nil, m.error
+	}
+	return m.order, nil
+}
+
+func (m *mockOrderService) ResendCreatedEvent(_ context.Context, id uuid.UUID) error {
+	m.resent = &id
+	return m.error
+}
+
+func (m *mockOrderService) Delete(_ context.Context, id uuid.UUID, _ int32) error {
+	m.deleted = &id
+	return m.error
+}
+
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code"`
 }
 
 type ValidationErrorResponse struct {
 	ValidationErrors map[string]string `json:"validation_errors"`
+	Code             string            `json:"code"`
 }
 
 // toJSON is a helper function to convert a struct to JSON string
@@ -99,8 +159,8 @@ func Test_OrderAPI_FindByID(t *testing.T) {
 						OrderID:      mockID,
 						ProductID:    mockID,
 						Quantity:     1,
-						PricePerItem: 100,
-						Price:        100,
+						PricePerItem: money.New(100, ""),
+						Price:        money.New(100, ""),
 						Version:      1,
 						CreatedAt:    createdAt.Format(time.RFC3339),
 					}}},
@@ -120,8 +180,8 @@ func Test_OrderAPI_FindByID(t *testing.T) {
 					OrderID:      mockID,
 					ProductID:    mockID,
 					Quantity:     1,
-					PricePerItem: 100,
-					Price:        100,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
 					Version:      1,
 					CreatedAt:    createdAt.Format(time.RFC3339),
 				}},
@@ -138,6 +198,7 @@ func Test_OrderAPI_FindByID(t *testing.T) {
 			expectedCode: http.StatusForbidden,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Access denied to order with ID " + mockID.String(),
+				Code:  ordererrors.CodeAccessDenied,
 			}),
 		},
 		{
@@ -151,6 +212,7 @@ func Test_OrderAPI_FindByID(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Invalid ID: 123-invalid-id",
+				Code:  web.CodeBadRequest,
 			}),
 		},
 		{
@@ -164,6 +226,7 @@ func Test_OrderAPI_FindByID(t *testing.T) {
 			expectedCode: http.StatusNotFound,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Order with ID " + mockID.String() + " not found",
+				Code:  ordererrors.CodeOrderNotFound,
 			}),
 		},
 		{
@@ -177,6 +240,21 @@ func Test_OrderAPI_FindByID(t *testing.T) {
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Failed to retrieve order with ID " + mockID.String(),
+				Code:  web.CodeInternal,
+			}),
+		},
+		{
+			name: "Error - query timeout",
+			mockService: mockOrderService{
+				order: nil,
+				error: ordererrors.ErrQueryTimeout,
+			},
+			orderID:      mockID.String(),
+			userID:       mockUserID,
+			expectedCode: http.StatusGatewayTimeout,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Database query timed out",
+				Code:  ordererrors.CodeQueryTimeout,
 			}),
 		},
 	}
@@ -185,12 +263,12 @@ func Test_OrderAPI_FindByID(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
 			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-			api := NewHandler(&tc.mockService, logger)
+			api := NewHandler(&tc.mockService, config.OrdersConfig{}, pconfig.DefaultBasePath, logger)
 
 			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+tc.orderID, nil)
 
 			if tc.userID != uuid.Nil {
-				ctx := context.WithValue(context.Background(), web.UserIDKey, tc.userID.String())
+				ctx := web.WithUser(context.Background(), web.User{ID: tc.userID.String()})
 				req = req.WithContext(ctx)
 			}
 
@@ -209,6 +287,85 @@ func Test_OrderAPI_FindByID(t *testing.T) {
 
 }
 
+func Test_OrderAPI_FindAnyByID(t *testing.T) {
+	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockAdminID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	mockOwnerID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	order := &service.OrderDto{ID: mockOrderID, UserID: mockOwnerID, Status: "PENDING", Version: 1}
+	testCases := []struct {
+		name         string
+		mockService  mockOrderService
+		roles        string
+		expectedCode int
+		expectedBody string
+		expectFound  bool
+	}{
+		{
+			name:         "Success - admin finds the order of another user",
+			mockService:  mockOrderService{order: order},
+			roles:        "user,admin",
+			expectedCode: http.StatusOK,
+			expectedBody: toJSON(t, order),
+			expectFound:  true,
+		},
+		{
+			name:         "Error - missing admin role",
+			mockService:  mockOrderService{order: order},
+			roles:        "user",
+			expectedCode: http.StatusForbidden,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Access denied: missing role admin", Code: web.CodeForbidden}),
+		},
+		{
+			name:         "Error - order not found",
+			mockService:  mockOrderService{error: ordererrors.ErrOrderNotFound},
+			roles:        "admin",
+			expectedCode: http.StatusNotFound,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Order with ID " + mockOrderID.String() + " not found",
+				Code:  ordererrors.CodeOrderNotFound,
+			}),
+			expectFound: true,
+		},
+		{
+			name:         "Error - internal server error",
+			mockService:  mockOrderService{error: errors.New("db error")},
+			roles:        "admin",
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Failed to retrieve order with ID " + mockOrderID.String(),
+				Code:  web.CodeInternal,
+			}),
+			expectFound: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.OrdersConfig{}, pconfig.DefaultBasePath, logger)
+			router := chi.NewRouter()
+			api.RegisterRoutes(router)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/orders/"+mockOrderID.String(), nil)
+			req.Header.Set(web.XUserId, mockAdminID.String())
+			req.Header.Set(web.XUserRoles, tc.roles)
+			rr := httptest.NewRecorder()
+
+			// when
+			router.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+			if tc.expectFound {
+				assert.Equal(t, &mockOrderID, tc.mockService.foundAny, "order ID should be passed to the service")
+			} else {
+				assert.Nil(t, tc.mockService.foundAny, "service should not be called")
+			}
+		})
+	}
+}
+
 func Test_OrderAPI_FindOrdersByUserID(t *testing.T) {
 	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
 	mockOrderID1, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
@@ -225,6 +382,9 @@ func Test_OrderAPI_FindOrdersByUserID(t *testing.T) {
 		noLimit         bool
 		noOffset        bool
 		OffsetNotNumber bool
+		rangeQuery      string
+		expectedFrom    time.Time
+		expectedTo      time.Time
 	}{
 		{
 			name: "Success - orders found",
@@ -262,6 +422,7 @@ func Test_OrderAPI_FindOrdersByUserID(t *testing.T) {
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Failed to fetch orders",
+				Code:  web.CodeInternal,
 			}),
 		},
 		{
@@ -274,6 +435,7 @@ func Test_OrderAPI_FindOrdersByUserID(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "limit url parameter is required",
+				Code:  web.CodeBadRequest,
 			}),
 			noLimit: true,
 		},
@@ -287,6 +449,7 @@ func Test_OrderAPI_FindOrdersByUserID(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "offset url parameter is required",
+				Code:  web.CodeBadRequest,
 			}),
 			noOffset: true,
 		},
@@ -300,6 +463,7 @@ func Test_OrderAPI_FindOrdersByUserID(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Invalid offset number: not-a-number",
+				Code:  web.CodeBadRequest,
 			}),
 			OffsetNotNumber: true,
 		},
@@ -313,7 +477,49 @@ func Test_OrderAPI_FindOrdersByUserID(t *testing.T) {
 			expectedCode: http.StatusForbidden,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Access denied",
+				Code:  ordererrors.CodeAccessDenied,
+			}),
+		},
+		{
+			name: "Success - creation time range is passed to the service",
+			mockService: mockOrderService{
+				orders: []service.OrderDto{},
+			},
+			userID:       mockUserID,
+			expectedCode: http.StatusOK,
+			expectedBody: `[]`,
+			rangeQuery:   "from=2025-01-01T00:00:00Z&to=2025-02-01T00:00:00%2B02:00",
+			expectedFrom: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			expectedTo:   time.Date(2025, 1, 31, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "Error - invalid from",
+			mockService: mockOrderService{
+				orders: nil,
+			},
+			userID:       mockUserID,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Invalid from: 2025-01-01, must be an RFC 3339 time",
+				Code:  ordererrors.CodeInvalidTimeRange,
+			}),
+			rangeQuery: "from=2025-01-01",
+		},
+		{
+			name: "Error - inverted time range",
+			mockService: mockOrderService{
+				orders: nil,
+				error:  ordererrors.ErrInvalidTimeRange,
+			},
+			userID:       mockUserID,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Invalid time range: from must be before to",
+				Code:  ordererrors.CodeInvalidTimeRange,
 			}),
+			rangeQuery:   "from=2025-02-01T00:00:00Z&to=2025-01-01T00:00:00Z",
+			expectedFrom: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+			expectedTo:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
 		},
 	}
 
@@ -321,7 +527,7 @@ func Test_OrderAPI_FindOrdersByUserID(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
 			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-			api := NewHandler(&tc.mockService, logger)
+			api := NewHandler(&tc.mockService, config.OrdersConfig{}, pconfig.DefaultBasePath, logger)
 
 			params := make([]string, 0, 2)
 			if !tc.noOffset {
@@ -334,12 +540,15 @@ func Test_OrderAPI_FindOrdersByUserID(t *testing.T) {
 			if !tc.noLimit {
 				params = append(params, "limit=100")
 			}
+			if tc.rangeQuery != "" {
+				params = append(params, tc.rangeQuery)
+			}
 			target := "/api/v1/orders?" + strings.Join(params, "&")
 
 			req := httptest.NewRequest(http.MethodGet, target, nil)
 
 			if tc.userID != uuid.Nil {
-				ctx := context.WithValue(context.Background(), web.UserIDKey, tc.userID.String())
+				ctx := web.WithUser(context.Background(), web.User{ID: tc.userID.String()})
 				req = req.WithContext(ctx)
 			}
 
@@ -352,6 +561,211 @@ func Test_OrderAPI_FindOrdersByUserID(t *testing.T) {
 			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
 			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
 			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+			assert.True(t, tc.expectedFrom.Equal(tc.mockService.from), "from should match")
+			assert.True(t, tc.expectedTo.Equal(tc.mockService.to), "to should match")
+		})
+	}
+}
+
+func Test_OrderAPI_Summary(t *testing.T) {
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	summary := &service.OrderSummaryDto{
+		TotalOrders: 4,
+		TotalSpent: []service.CurrencyTotalDto{
+			{Amount: money.New(500, "EUR"), Currency: "EUR"},
+			{Amount: money.New(3000, "USD"), Currency: "USD"},
+		},
+		ByStatus: []service.OrderStatusSummaryDto{
+			{Status: service.StatusCompleted, Currency: "USD", Orders: 2, TotalPrice: money.New(3000, "USD")},
+			{Status: service.StatusPending, Currency: "EUR", Orders: 1, TotalPrice: money.New(500, "EUR")},
+		},
+	}
+
+	testCases := []struct {
+		name              string
+		mockService       mockOrderService
+		userID            string
+		expectedCode      int
+		expectedBody      string
+		expectedSummaryOf uuid.UUID
+	}{
+		{
+			name:              "Success - summary of the user",
+			mockService:       mockOrderService{summary: summary},
+			userID:            mockUserID.String(),
+			expectedCode:      http.StatusOK,
+			expectedBody:      `{"total_orders":4,"total_spent":[{"amount":"5.00","currency":"EUR"},{"amount":"30.00","currency":"USD"}],"by_status":[{"status":"COMPLETED","currency":"USD","orders":2,"total_price":"30.00"},{"status":"PENDING","currency":"EUR","orders":1,"total_price":"5.00"}]}`,
+			expectedSummaryOf: mockUserID,
+		},
+		{
+			name:              "Success - no orders",
+			mockService:       mockOrderService{summary: &service.OrderSummaryDto{TotalSpent: []service.CurrencyTotalDto{}, ByStatus: []service.OrderStatusSummaryDto{}}},
+			userID:            mockUserID.String(),
+			expectedCode:      http.StatusOK,
+			expectedBody:      `{"total_orders":0,"total_spent":[],"by_status":[]}`,
+			expectedSummaryOf: mockUserID,
+		},
+		{
+			name:              "Error - service error",
+			mockService:       mockOrderService{error: ordererrors.ErrFailedToSummarizeOrders},
+			userID:            mockUserID.String(),
+			expectedCode:      http.StatusInternalServerError,
+			expectedBody:      toJSON(t, ErrorResponse{Error: "Failed to summarize orders", Code: web.CodeInternal}),
+			expectedSummaryOf: mockUserID,
+		},
+		{
+			name:         "Error - invalid user ID",
+			mockService:  mockOrderService{summary: summary},
+			userID:       "invalid-uuid",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Invalid user ID: invalid-uuid", Code: web.CodeBadRequest}),
+		},
+		{
+			name:         "Error - anonymous user",
+			mockService:  mockOrderService{summary: summary},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.OrdersConfig{}, pconfig.DefaultBasePath, logger)
+			router := chi.NewRouter()
+			api.RegisterRoutes(router)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/summary", nil)
+			req.Header.Set(web.XUserId, tc.userID)
+			rr := httptest.NewRecorder()
+
+			// when
+			router.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+			}
+			assert.Equal(t, tc.expectedSummaryOf, tc.mockService.summaryOf, "the summary should be scoped to the user")
+		})
+	}
+}
+
+func Test_OrderAPI_FindAllOrders(t *testing.T) {
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	orders := []service.OrderDto{{ID: mockOrderID, UserID: mockUserID, Status: "PENDING", Version: 1}}
+
+	testCases := []struct {
+		name           string
+		mockService    mockOrderService
+		roles          string
+		query          string
+		expectedCode   int
+		expectedBody   string
+		expectedStatus string
+		expectedFrom   time.Time
+	}{
+		{
+			name:         "Success - admin lists orders of all users",
+			mockService:  mockOrderService{orders: orders},
+			roles:        "user,admin",
+			query:        "offset=0&limit=100",
+			expectedCode: http.StatusOK,
+			expectedBody: toJSON(t, orders),
+		},
+		{
+			name:           "Success - status filter is passed to the service",
+			mockService:    mockOrderService{orders: orders},
+			roles:          "admin",
+			query:          "offset=0&limit=100&status=PENDING",
+			expectedCode:   http.StatusOK,
+			expectedBody:   toJSON(t, orders),
+			expectedStatus: "PENDING",
+		},
+		{
+			name:         "Error - missing admin role",
+			mockService:  mockOrderService{orders: orders},
+			roles:        "user",
+			query:        "offset=0&limit=100",
+			expectedCode: http.StatusForbidden,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Access denied: missing role admin", Code: web.CodeForbidden}),
+		},
+		{
+			name:         "Error - no roles",
+			mockService:  mockOrderService{orders: orders},
+			query:        "offset=0&limit=100",
+			expectedCode: http.StatusForbidden,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Access denied: missing role admin", Code: web.CodeForbidden}),
+		},
+		{
+			name:         "Error - no limit provided",
+			mockService:  mockOrderService{orders: orders},
+			roles:        "admin",
+			query:        "offset=0",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{Error: "limit url parameter is required", Code: web.CodeBadRequest}),
+		},
+		{
+			name:         "Error - service error",
+			mockService:  mockOrderService{error: ordererrors.ErrFailedToFindOrders},
+			roles:        "admin",
+			query:        "offset=0&limit=100",
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Failed to fetch orders", Code: web.CodeInternal}),
+		},
+		{
+			name:         "Success - creation time range is passed to the service",
+			mockService:  mockOrderService{orders: orders},
+			roles:        "admin",
+			query:        "offset=0&limit=100&from=2025-01-01T00:00:00Z",
+			expectedCode: http.StatusOK,
+			expectedBody: toJSON(t, orders),
+			expectedFrom: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "Error - invalid to",
+			mockService:  mockOrderService{orders: orders},
+			roles:        "admin",
+			query:        "offset=0&limit=100&to=yesterday",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Invalid to: yesterday, must be an RFC 3339 time", Code: ordererrors.CodeInvalidTimeRange}),
+		},
+		{
+			name:         "Error - inverted time range",
+			mockService:  mockOrderService{error: ordererrors.ErrInvalidTimeRange},
+			roles:        "admin",
+			query:        "offset=0&limit=100&from=2025-01-01T00:00:00Z&to=2025-01-01T00:00:00Z",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Invalid time range: from must be before to", Code: ordererrors.CodeInvalidTimeRange}),
+			expectedFrom: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.OrdersConfig{}, pconfig.DefaultBasePath, logger)
+			router := chi.NewRouter()
+			api.RegisterRoutes(router)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/orders?"+tc.query, nil)
+			req.Header.Set(web.XUserId, mockUserID.String())
+			if tc.roles != "" {
+				req.Header.Set(web.XUserRoles, tc.roles)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			router.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+			assert.Equal(t, tc.expectedStatus, tc.mockService.status, "status filter should match")
+			assert.True(t, tc.expectedFrom.Equal(tc.mockService.from), "from should match")
 		})
 	}
 }
@@ -362,6 +776,7 @@ func Test_OrderAPI_Create(t *testing.T) {
 	mockItemID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
 
 	createdAt := time.Now()
+	restockAt := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
 
 	testCases := []struct {
 		name         string
@@ -379,8 +794,8 @@ func Test_OrderAPI_Create(t *testing.T) {
 						OrderID:      mockOrderID,
 						ProductID:    mockItemID,
 						Quantity:     1,
-						PricePerItem: 100,
-						Price:        100,
+						PricePerItem: money.New(100, ""),
+						Price:        money.New(100, ""),
 						Version:      1,
 						CreatedAt:    createdAt.Format(time.RFC3339),
 					}},
@@ -393,8 +808,8 @@ func Test_OrderAPI_Create(t *testing.T) {
 				Items: []service.OrderItemCreateDto{{
 					ProductID:    mockItemID,
 					Quantity:     1,
-					PricePerItem: 100,
-					Price:        100,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
 				}},
 			}),
 			expectedCode: http.StatusCreated,
@@ -409,8 +824,8 @@ func Test_OrderAPI_Create(t *testing.T) {
 					OrderID:      mockOrderID,
 					ProductID:    mockItemID,
 					Quantity:     1,
-					PricePerItem: 100,
-					Price:        100,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
 					Version:      1,
 					CreatedAt:    createdAt.Format(time.RFC3339),
 				}},
@@ -426,6 +841,7 @@ func Test_OrderAPI_Create(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Invalid request body",
+				Code:  web.CodeBadRequest,
 			}),
 		},
 		{
@@ -441,6 +857,7 @@ func Test_OrderAPI_Create(t *testing.T) {
 			}),
 			expectedCode: http.StatusBadRequest,
 			expectedBody: toJSON(t, ValidationErrorResponse{
+				Code: web.CodeValidationFailed,
 				ValidationErrors: map[string]string{
 					"Status": "failed on rule: required",
 					"Items":  "failed on rule: gt",
@@ -458,14 +875,15 @@ func Test_OrderAPI_Create(t *testing.T) {
 				Status: "pending",
 				Items: []service.OrderItemCreateDto{{
 					ProductID:    mockItemID,
-					Quantity:     0,    // Invalid quantity
-					PricePerItem: -100, // Invalid price
-					Price:        -100, // Invalid price
+					Quantity:     0,                   // Invalid quantity
+					PricePerItem: money.New(-100, ""), // Invalid price
+					Price:        money.New(-100, ""), // Invalid price
 
 				}},
 			}),
 			expectedCode: http.StatusBadRequest,
 			expectedBody: toJSON(t, ValidationErrorResponse{
+				Code: web.CodeValidationFailed,
 				ValidationErrors: map[string]string{
 					"Quantity":     "failed on rule: required",
 					"PricePerItem": "failed on rule: min",
@@ -483,6 +901,7 @@ func Test_OrderAPI_Create(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Invalid request body",
+				Code:  web.CodeBadRequest,
 			}),
 		},
 		{
@@ -497,20 +916,21 @@ func Test_OrderAPI_Create(t *testing.T) {
 				Items: []service.OrderItemCreateDto{{
 					ProductID:    mockItemID,
 					Quantity:     1,
-					PricePerItem: 100,
-					Price:        100,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
 				}},
 			}),
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Internal server error",
+				Code:  web.CodeInternal,
 			}),
 		},
 		{
-			name: "Error - insufficient stock",
+			name: "Error - product service unavailable",
 			mockService: mockOrderService{
 				order: nil,
-				error: fmt.Errorf("product %s. Available: %d, Requested: %d: %w", mockItemID.String(), 0, 1, ordererrors.ErrInsufficientStock),
+				error: status.Error(codes.Unavailable, "connection refused"),
 			},
 			requestBody: toJSON(t, service.OrderCreateDto{
 				UserID: mockUserID,
@@ -518,80 +938,695 @@ func Test_OrderAPI_Create(t *testing.T) {
 				Items: []service.OrderItemCreateDto{{
 					ProductID:    mockItemID,
 					Quantity:     1,
-					PricePerItem: 100,
-					Price:        100,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
 				}},
 			}),
-			expectedCode: http.StatusBadRequest,
+			expectedCode: http.StatusServiceUnavailable,
 			expectedBody: toJSON(t, ErrorResponse{
-				Error: fmt.Sprintf("product %s. Available: %d, Requested: %d: %s", mockItemID.String(), 0, 1, ordererrors.ErrInsufficientStock.Error()),
+				Error: "Product service unavailable, please retry",
+				Code:  web.CodeServiceUnavailable,
 			}),
 		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			// given
-			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-			api := NewHandler(&tc.mockService, logger)
-			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", nil)
-			req.Body = io.NopCloser(strings.NewReader(tc.requestBody))
-			req.Header.Set("Content-Type", "application/json")
-			ctx := context.WithValue(context.Background(), web.UserIDKey, mockUserID.String())
-			req = req.WithContext(ctx)
-			rr := httptest.NewRecorder()
-			// when
-			api.Create(rr, req)
-			// then
-			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
-			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
-			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
-		})
-	}
-}
-
-func Test_OrderAPI_Update(t *testing.T) {
-	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
-	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
-	createdAt := time.Now()
-	const pending = "PENDING"
-	testCases := []struct {
-		name         string
-		mockService  mockOrderService
-		orderID      uuid.UUID
-		userID       uuid.UUID
-		requestBody  string
-		expectedCode int
-		expectedBody string
-	}{
 		{
-			name: "Success - order updated",
+			name: "Error - product service unavailable, stock reservation",
 			mockService: mockOrderService{
-				order: &service.OrderDto{ID: mockOrderID, UserID: mockUserID, Status: pending, Version: 2, CreatedAt: createdAt.Format(time.RFC3339)},
-				error: nil,
+				order: nil,
+				error: fmt.Errorf("failed to reserve stock: %w", status.Error(codes.Unavailable, "circuit breaker is open")),
 			},
-			orderID: mockOrderID,
-			userID:  mockUserID,
-			requestBody: toJSON(t, service.OrderUpdateDto{
-				Status:  pending,
-				Version: 1,
+			requestBody: toJSON(t, service.OrderCreateDto{
+				UserID: mockUserID,
+				Status: "pending",
+				Items: []service.OrderItemCreateDto{{
+					ProductID:    mockItemID,
+					Quantity:     1,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
+				}},
 			}),
-			expectedCode: http.StatusOK,
-			expectedBody: toJSON(t, service.OrderDto{
-				ID:        mockOrderID,
-				UserID:    mockUserID,
-				Status:    pending,
-				Version:   2,
-				CreatedAt: createdAt.Format(time.RFC3339),
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Product service unavailable, please retry",
+				Code:  web.CodeServiceUnavailable,
 			}),
 		},
 		{
-			name: "Error - validation failed",
+			name: "Error - product service timeout",
 			mockService: mockOrderService{
 				order: nil,
-				error: nil,
+				error: status.Error(codes.DeadlineExceeded, "context deadline exceeded"),
 			},
-			orderID: mockOrderID,
+			requestBody: toJSON(t, service.OrderCreateDto{
+				UserID: mockUserID,
+				Status: "pending",
+				Items: []service.OrderItemCreateDto{{
+					ProductID:    mockItemID,
+					Quantity:     1,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
+				}},
+			}),
+			expectedCode: http.StatusGatewayTimeout,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "The request timed out",
+				Code:  web.CodeTimeout,
+			}),
+		},
+		{
+			name: "Error - insufficient stock",
+			mockService: mockOrderService{
+				order: nil,
+				error: fmt.Errorf("product %s. Available: %d, Requested: %d: %w", mockItemID.String(), 0, 1, ordererrors.ErrInsufficientStock),
+			},
+			requestBody: toJSON(t, service.OrderCreateDto{
+				UserID: mockUserID,
+				Status: "pending",
+				Items: []service.OrderItemCreateDto{{
+					ProductID:    mockItemID,
+					Quantity:     1,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
+				}},
+			}),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: fmt.Sprintf("product %s. Available: %d, Requested: %d: %s", mockItemID.String(), 0, 1, ordererrors.ErrInsufficientStock.Error()),
+				Code:  ordererrors.CodeInsufficientStock,
+			}),
+		},
+		{
+			name: "Error - insufficient stock with restock ETA",
+			mockService: mockOrderService{
+				order: nil,
+				error: &ordererrors.InsufficientStockError{Items: []ordererrors.InsufficientStockItem{
+					{ProductID: mockItemID.String(), Available: 0, Requested: 1, RestockETA: &restockAt},
+				}},
+			},
+			requestBody: toJSON(t, service.OrderCreateDto{
+				UserID: mockUserID,
+				Status: "pending",
+				Items: []service.OrderItemCreateDto{{
+					ProductID:    mockItemID,
+					Quantity:     1,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
+				}},
+			}),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: fmt.Sprintf(`{"error":"product %s. Available: 0, Requested: 1: %s","code":"INSUFFICIENT_STOCK","items":[{"product_id":"%s","available":0,"requested":1,"restock_eta":"2025-07-01T12:00:00Z"}]}`,
+				mockItemID, ordererrors.ErrInsufficientStock, mockItemID),
+		},
+		{
+			name: "Error - insufficient stock without restock ETA",
+			mockService: mockOrderService{
+				order: nil,
+				error: &ordererrors.InsufficientStockError{Items: []ordererrors.InsufficientStockItem{
+					{ProductID: mockItemID.String(), Available: 0, Requested: 1},
+				}},
+			},
+			requestBody: toJSON(t, service.OrderCreateDto{
+				UserID: mockUserID,
+				Status: "pending",
+				Items: []service.OrderItemCreateDto{{
+					ProductID:    mockItemID,
+					Quantity:     1,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
+				}},
+			}),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: fmt.Sprintf(`{"error":"product %s. Available: 0, Requested: 1: %s","code":"INSUFFICIENT_STOCK","items":[{"product_id":"%s","available":0,"requested":1}]}`,
+				mockItemID, ordererrors.ErrInsufficientStock, mockItemID),
+		},
+		{
+			name: "Error - email is not verified",
+			mockService: mockOrderService{
+				order: nil,
+				error: ordererrors.ErrEmailNotVerified,
+			},
+			requestBody: toJSON(t, service.OrderCreateDto{
+				UserID: mockUserID,
+				Status: "pending",
+				Items: []service.OrderItemCreateDto{{
+					ProductID:    mockItemID,
+					Quantity:     1,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
+				}},
+			}),
+			expectedCode: http.StatusForbidden,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Email address must be verified to create orders",
+				Code:  ordererrors.CodeEmailNotVerified,
+			}),
+		},
+		{
+			name: "Error - status is not allowed at creation",
+			mockService: mockOrderService{
+				order: nil,
+				error: fmt.Errorf("%w: COMPLETED, allowed: PENDING", ordererrors.ErrInvalidStatus),
+			},
+			requestBody: toJSON(t, service.OrderCreateDto{
+				UserID: mockUserID,
+				Status: "COMPLETED",
+				Items: []service.OrderItemCreateDto{{
+					ProductID:    mockItemID,
+					Quantity:     1,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
+				}},
+			}),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "order status is not allowed at creation: COMPLETED, allowed: PENDING",
+				Code:  ordererrors.CodeInvalidStatus,
+			}),
+		},
+		{
+			name: "Error - quantity exceeds the maximum",
+			mockService: mockOrderService{
+				order: nil,
+				error: ordererrors.ErrQuantityExceedsMax,
+			},
+			requestBody: toJSON(t, service.OrderCreateDto{
+				UserID: mockUserID,
+				Status: "pending",
+				Items: []service.OrderItemCreateDto{{
+					ProductID:    mockItemID,
+					Quantity:     11,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(1100, ""),
+				}},
+			}),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Quantity of an order item exceeds the maximum of 10",
+				Code:  ordererrors.CodeQuantityExceedsMax,
+			}),
+		},
+		{
+			name: "Error - items priced in different currencies",
+			mockService: mockOrderService{
+				order: nil,
+				error: fmt.Errorf("%w: EUR, USD", ordererrors.ErrMixedCurrencies),
+			},
+			requestBody: toJSON(t, service.OrderCreateDto{
+				UserID: mockUserID,
+				Status: "pending",
+				Items: []service.OrderItemCreateDto{{
+					ProductID:    mockItemID,
+					Quantity:     1,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
+				}},
+			}),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Order items must be priced in a single currency",
+				Code:  ordererrors.CodeMixedCurrencies,
+			}),
+		},
+		{
+			name: "Error - unknown product",
+			mockService: mockOrderService{
+				order: nil,
+				error: &ordererrors.ProductNotFoundError{ProductIDs: []string{mockItemID.String()}},
+			},
+			requestBody: toJSON(t, service.OrderCreateDto{
+				UserID: mockUserID,
+				Status: "pending",
+				Items: []service.OrderItemCreateDto{{
+					ProductID:    mockItemID,
+					Quantity:     1,
+					PricePerItem: money.New(100, ""),
+					Price:        money.New(100, ""),
+				}},
+			}),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"product not found: ` + mockItemID.String() + `","code":"PRODUCT_NOT_FOUND","product_ids":["` + mockItemID.String() + `"]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.OrdersConfig{MaxItemQuantity: 10}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", nil)
+			req.Body = io.NopCloser(strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			ctx := web.WithUser(context.Background(), web.User{ID: mockUserID.String()})
+			req = req.WithContext(ctx)
+			rr := httptest.NewRecorder()
+			// when
+			api.Create(rr, req)
+			// then
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_OrderAPI_Create_EmailVerified(t *testing.T) {
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockItemID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	requestBody := toJSON(t, service.OrderCreateDto{
+		Status: "pending",
+		Items:  []service.OrderItemCreateDto{{ProductID: mockItemID, Quantity: 1, PricePerItem: money.New(100, ""), Price: money.New(100, "")}},
+	})
+
+	testCases := []struct {
+		name          string
+		ctxValue      any // value stored under web.EmailVerifiedKey, nil if absent
+		expectedValue bool
+	}{
+		{name: "verified user", ctxValue: true, expectedValue: true},
+		{name: "unverified user", ctxValue: false, expectedValue: false},
+		{name: "no verification status", ctxValue: nil, expectedValue: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			mockService := &mockOrderService{order: &service.OrderDto{UserID: mockUserID}}
+			api := NewHandler(mockService, config.OrdersConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(requestBody))
+			ctx := web.WithUser(context.Background(), web.User{ID: mockUserID.String()})
+			if tc.ctxValue != nil {
+				ctx = context.WithValue(ctx, web.EmailVerifiedKey, tc.ctxValue)
+			}
+			req = req.WithContext(ctx)
+			rr := httptest.NewRecorder()
+			// when
+			api.Create(rr, req)
+			// then
+			assert.Equal(t, http.StatusCreated, rr.Code, "status code should match")
+			assert.Equal(t, tc.expectedValue, mockService.createDto.EmailVerified, "email verification status should be forwarded to the service")
+		})
+	}
+}
+
+func Test_OrderAPI_Create_RequestLimits(t *testing.T) {
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	cfg := config.OrdersConfig{MaxItems: 2, MaxJSONDepth: 5}
+	const maxBodyBytes = 1024
+	item := `{"product_id":"123e4567-e89b-12d3-a456-426614174002","quantity":1,"price_per_item":100,"price":100}`
+
+	testCases := []struct {
+		name         string
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Success - within limits",
+			requestBody:  `{"status":"pending","items":[` + item + `,` + item + `]}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Error - deeply nested payload",
+			requestBody:  `{"status":"pending","items":[` + item + `],"meta":` + strings.Repeat(`{"a":`, 100) + `1` + strings.Repeat(`}`, 100) + `}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Request body is nested too deeply: maximum depth is 5", Code: web.CodeBadRequest}),
+		},
+		{
+			name:         "Error - oversized items array",
+			requestBody:  `{"status":"pending","items":[` + item + `,` + item + `,` + item + `]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Too many order items: maximum is 2", Code: web.CodeBadRequest}),
+		},
+		{
+			name:         "Error - body too large",
+			requestBody:  `{"status":"pending","items":[` + item + `],"note":"` + strings.Repeat("a", maxBodyBytes) + `"}`,
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Request body too large: maximum is 1024 bytes", Code: "REQUEST_ENTITY_TOO_LARGE"}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			mockService := &mockOrderService{order: &service.OrderDto{UserID: mockUserID}}
+			api := NewHandler(mockService, cfg, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(tc.requestBody))
+			ctx := web.WithUser(context.Background(), web.User{ID: mockUserID.String()})
+			req = req.WithContext(ctx)
+			rr := httptest.NewRecorder()
+			// when
+			web.MaxBodySizeMiddleware(maxBodyBytes)(http.HandlerFunc(api.Create)).ServeHTTP(rr, req)
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+				assert.Empty(t, mockService.createDto.Items, "service should not be called")
+			}
+		})
+	}
+}
+
+func Test_OrderAPI_StrictJSON(t *testing.T) {
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	item := `{"product_id":"123e4567-e89b-12d3-a456-426614174002","quantity":1,"price_per_item":100,"price":100}`
+
+	testCases := []struct {
+		name         string
+		strict       bool
+		method       string
+		target       string
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Success - unknown field ignored by lenient decoding",
+			method:       http.MethodPost,
+			target:       "/api/v1/orders",
+			requestBody:  `{"status":"pending","items":[` + item + `],"stauts":"pending"}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Error - unknown field of an order",
+			strict:       true,
+			method:       http.MethodPost,
+			target:       "/api/v1/orders",
+			requestBody:  `{"status":"pending","items":[` + item + `],"stauts":"pending"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{Error: `Unknown field in request body: "stauts"`, Code: web.CodeBadRequest}),
+		},
+		{
+			name:         "Error - unknown field of an order update",
+			strict:       true,
+			method:       http.MethodPut,
+			target:       "/api/v1/orders/" + mockOrderID.String(),
+			requestBody:  `{"status":"completed","version":1,"note":"leave at the door"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{Error: `Unknown field in request body: "note"`, Code: web.CodeBadRequest}),
+		},
+		{
+			name:         "Error - unknown field of an order item",
+			strict:       true,
+			method:       http.MethodPut,
+			target:       "/api/v1/orders/" + mockOrderID.String() + "/items",
+			requestBody:  `{"items":[{"product_id":"123e4567-e89b-12d3-a456-426614174002","qty":2}],"version":1}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{Error: `Unknown field in request body: "qty"`, Code: web.CodeBadRequest}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			mockService := &mockOrderService{order: &service.OrderDto{ID: mockOrderID, UserID: mockUserID}}
+			api := NewHandler(mockService, config.OrdersConfig{StrictJSON: tc.strict}, pconfig.DefaultBasePath, logger)
+			router := chi.NewRouter()
+			api.RegisterRoutes(router)
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(web.XUserId, mockUserID.String())
+			rr := httptest.NewRecorder()
+			// when
+			router.ServeHTTP(rr, req)
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+			}
+		})
+	}
+}
+
+func Test_OrderAPI_RequireJSON(t *testing.T) {
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	mockItemID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	createBody := toJSON(t, service.OrderCreateDto{
+		Status: "pending",
+		Items:  []service.OrderItemCreateDto{{ProductID: mockItemID, Quantity: 1, PricePerItem: money.New(100, ""), Price: money.New(100, "")}},
+	})
+	itemsBody := toJSON(t, service.OrderItemsUpdateDto{
+		Items:   []service.OrderItemUpdateDto{{ProductID: mockItemID, Quantity: 1}},
+		Version: 1,
+	})
+
+	testCases := []struct {
+		name         string
+		method       string
+		target       string
+		contentType  string
+		requestBody  string
+		expectedCode int
+	}{
+		{
+			name:         "Create with JSON",
+			method:       http.MethodPost,
+			target:       "/api/v1/orders",
+			contentType:  "application/json",
+			requestBody:  createBody,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Create with form data",
+			method:       http.MethodPost,
+			target:       "/api/v1/orders",
+			contentType:  "application/x-www-form-urlencoded",
+			requestBody:  "status=pending",
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:         "Create without content type",
+			method:       http.MethodPost,
+			target:       "/api/v1/orders",
+			requestBody:  createBody,
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:         "UpdateItems with JSON and charset",
+			method:       http.MethodPut,
+			target:       "/api/v1/orders/" + mockOrderID.String() + "/items",
+			contentType:  "application/json; charset=utf-8",
+			requestBody:  itemsBody,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "UpdateItems with plain text",
+			method:       http.MethodPut,
+			target:       "/api/v1/orders/" + mockOrderID.String() + "/items",
+			contentType:  "text/plain",
+			requestBody:  itemsBody,
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			mockService := &mockOrderService{order: &service.OrderDto{ID: mockOrderID, UserID: mockUserID, Status: service.StatusPending, Version: 2}}
+			api := NewHandler(mockService, config.OrdersConfig{}, pconfig.DefaultBasePath, logger)
+			router := chi.NewRouter()
+			api.RegisterRoutes(router)
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.requestBody))
+			req.Header.Set(web.XUserId, mockUserID.String())
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rr := httptest.NewRecorder()
+			// when
+			router.ServeHTTP(rr, req)
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			if tc.expectedCode == http.StatusUnsupportedMediaType {
+				assert.JSONEq(t, toJSON(t, ErrorResponse{
+					Error: fmt.Sprintf("Unsupported content type: %q, must be application/json", tc.contentType),
+					Code:  "UNSUPPORTED_MEDIA_TYPE",
+				}), rr.Body.String(), "response body should match")
+				assert.Empty(t, mockService.createDto.Items, "service should not be called")
+			}
+		})
+	}
+}
+
+func Test_OrderAPI_Create_UnprocessableEntity(t *testing.T) {
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockItemID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	validBody := toJSON(t, service.OrderCreateDto{
+		Status: "pending",
+		Items:  []service.OrderItemCreateDto{{ProductID: mockItemID, Quantity: 5, PricePerItem: money.New(100, ""), Price: money.New(500, "")}},
+	})
+	stockErr := &ordererrors.InsufficientStockError{Items: []ordererrors.InsufficientStockItem{
+		{ProductID: mockItemID.String(), Available: 1, Requested: 5},
+	}}
+
+	testCases := []struct {
+		name                string
+		unprocessableEntity bool
+		requestBody         string
+		serviceError        error
+		expectedCode        int
+	}{
+		{
+			name:                "insufficient stock with 422 enabled",
+			unprocessableEntity: true,
+			requestBody:         validBody,
+			serviceError:        stockErr,
+			expectedCode:        http.StatusUnprocessableEntity,
+		},
+		{
+			name:                "insufficient stock with 422 disabled",
+			unprocessableEntity: false,
+			requestBody:         validBody,
+			serviceError:        stockErr,
+			expectedCode:        http.StatusBadRequest,
+		},
+		{
+			name:                "plain insufficient stock error with 422 enabled",
+			unprocessableEntity: true,
+			requestBody:         validBody,
+			serviceError:        ordererrors.ErrInsufficientStock,
+			expectedCode:        http.StatusUnprocessableEntity,
+		},
+		{
+			name:                "malformed JSON with 422 enabled",
+			unprocessableEntity: true,
+			requestBody:         `{"status":`,
+			expectedCode:        http.StatusBadRequest,
+		},
+		{
+			name:                "validation error with 422 enabled",
+			unprocessableEntity: true,
+			requestBody:         `{"status":"pending","items":[]}`,
+			expectedCode:        http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			mockService := &mockOrderService{error: tc.serviceError}
+			api := NewHandler(mockService, config.OrdersConfig{UnprocessableEntity: tc.unprocessableEntity}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(tc.requestBody))
+			ctx := web.WithUser(context.Background(), web.User{ID: mockUserID.String()})
+			req = req.WithContext(ctx)
+			rr := httptest.NewRecorder()
+			// when
+			api.Create(rr, req)
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			var body map[string]any
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			if tc.serviceError != nil {
+				assert.Equal(t, tc.serviceError.Error(), body["error"], "error message should match")
+				assert.Equal(t, ordererrors.CodeInsufficientStock, body["code"], "error code should match")
+			}
+		})
+	}
+}
+
+func Test_OrderAPI_Create_LocationHeader(t *testing.T) {
+	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	mockItemID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	requestBody := toJSON(t, service.OrderCreateDto{
+		Status: "pending",
+		Items:  []service.OrderItemCreateDto{{ProductID: mockItemID, Quantity: 1, PricePerItem: money.New(100, ""), Price: money.New(100, "")}},
+	})
+	created := &service.OrderDto{ID: mockOrderID, UserID: mockUserID, Status: "pending", Version: 1}
+
+	testCases := []struct {
+		name             string
+		cfg              config.OrdersConfig
+		mockService      mockOrderService
+		expectedCode     int
+		expectedLocation string
+	}{
+		{
+			name:             "Location header is set when enabled",
+			cfg:              config.OrdersConfig{LocationHeader: true},
+			mockService:      mockOrderService{order: created},
+			expectedCode:     http.StatusCreated,
+			expectedLocation: "/api/v1/orders/" + mockOrderID.String(),
+		},
+		{
+			name:             "Location header is omitted when disabled",
+			cfg:              config.OrdersConfig{LocationHeader: false},
+			mockService:      mockOrderService{order: created},
+			expectedCode:     http.StatusCreated,
+			expectedLocation: "",
+		},
+		{
+			name:             "Location header is omitted on error",
+			cfg:              config.OrdersConfig{LocationHeader: true},
+			mockService:      mockOrderService{error: ordererrors.ErrEmailNotVerified},
+			expectedCode:     http.StatusForbidden,
+			expectedLocation: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, tc.cfg, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(requestBody))
+			req = req.WithContext(web.WithUser(context.Background(), web.User{ID: mockUserID.String()}))
+			rr := httptest.NewRecorder()
+			// when
+			api.Create(rr, req)
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.Equal(t, tc.expectedLocation, rr.Header().Get("Location"), "Location header should match")
+		})
+	}
+}
+
+func Test_OrderAPI_Update(t *testing.T) {
+	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	createdAt := time.Now()
+	const pending = "PENDING"
+	testCases := []struct {
+		name         string
+		mockService  mockOrderService
+		orderID      uuid.UUID
+		userID       uuid.UUID
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Success - order updated",
+			mockService: mockOrderService{
+				order: &service.OrderDto{ID: mockOrderID, UserID: mockUserID, Status: pending, Version: 2, CreatedAt: createdAt.Format(time.RFC3339)},
+				error: nil,
+			},
+			orderID: mockOrderID,
+			userID:  mockUserID,
+			requestBody: toJSON(t, service.OrderUpdateDto{
+				Status:  pending,
+				Version: 1,
+			}),
+			expectedCode: http.StatusOK,
+			expectedBody: toJSON(t, service.OrderDto{
+				ID:        mockOrderID,
+				UserID:    mockUserID,
+				Status:    pending,
+				Version:   2,
+				CreatedAt: createdAt.Format(time.RFC3339),
+			}),
+		},
+		{
+			name: "Error - validation failed",
+			mockService: mockOrderService{
+				order: nil,
+				error: nil,
+			},
+			orderID: mockOrderID,
 			userID:  mockUserID,
 			requestBody: toJSON(t, service.OrderUpdateDto{
 				Status:  "", // Invalid status
@@ -599,6 +1634,7 @@ func Test_OrderAPI_Update(t *testing.T) {
 			}),
 			expectedCode: http.StatusBadRequest,
 			expectedBody: toJSON(t, ValidationErrorResponse{
+				Code: web.CodeValidationFailed,
 				ValidationErrors: map[string]string{
 					"Status":  "failed on rule: required",
 					"Version": "failed on rule: required",
@@ -617,6 +1653,7 @@ func Test_OrderAPI_Update(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Invalid request body",
+				Code:  web.CodeBadRequest,
 			}),
 		},
 		{
@@ -634,6 +1671,7 @@ func Test_OrderAPI_Update(t *testing.T) {
 			expectedCode: http.StatusNotFound,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Order with ID " + mockOrderID.String() + " not found",
+				Code:  ordererrors.CodeOrderNotFound,
 			}),
 		},
 		{
@@ -651,6 +1689,7 @@ func Test_OrderAPI_Update(t *testing.T) {
 			expectedCode: http.StatusInternalServerError,
 			expectedBody: toJSON(t, ErrorResponse{
 				Error: "Failed to update order with ID " + mockOrderID.String(),
+				Code:  web.CodeInternal,
 			}),
 		},
 	}
@@ -658,13 +1697,13 @@ func Test_OrderAPI_Update(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
 			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-			api := NewHandler(&tc.mockService, logger)
+			api := NewHandler(&tc.mockService, config.OrdersConfig{}, pconfig.DefaultBasePath, logger)
 			req := httptest.NewRequest(http.MethodPut, "/api/v1/orders/"+tc.orderID.String(), nil)
 			req.Body = io.NopCloser(strings.NewReader(tc.requestBody))
 			req.Header.Set("Content-Type", "application/json")
 			req.SetPathValue("id", tc.orderID.String())
 			if tc.userID != uuid.Nil {
-				ctx := context.WithValue(context.Background(), web.UserIDKey, tc.userID.String())
+				ctx := web.WithUser(context.Background(), web.User{ID: tc.userID.String()})
 				req = req.WithContext(ctx)
 			}
 			rr := httptest.NewRecorder()
@@ -681,17 +1720,323 @@ func Test_OrderAPI_Update(t *testing.T) {
 
 }
 
-func Test_OrderAPI_HealthCheck(t *testing.T) {
-	// given
-	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-	api := NewHandler(nil, logger) // No service needed for health check
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/healthz", nil)
-	rr := httptest.NewRecorder()
+func Test_OrderAPI_Update_ConflictDetails(t *testing.T) {
+	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	requestBody := toJSON(t, service.OrderUpdateDto{Status: "COMPLETED", Version: 1})
+	lockErr := &ordererrors.OptimisticLockError{CurrentVersion: 3, CurrentStatus: "CANCELLED"}
+	message := "Order with ID " + mockOrderID.String() + " has been modified by another user"
+
+	testCases := []struct {
+		name         string
+		cfg          config.OrdersConfig
+		mockService  mockOrderService
+		expectedBody string
+	}{
+		{
+			name:        "current version and status are included when enabled",
+			cfg:         config.OrdersConfig{ConflictDetails: true},
+			mockService: mockOrderService{error: lockErr},
+			expectedBody: `{"error":"` + message + `","code":"` + ordererrors.CodeOptimisticLock + `",
+				"current_version":3,"current_status":"CANCELLED"}`,
+		},
+		{
+			name:         "current version and status are omitted when disabled",
+			cfg:          config.OrdersConfig{ConflictDetails: false},
+			mockService:  mockOrderService{error: lockErr},
+			expectedBody: toJSON(t, ErrorResponse{Error: message, Code: ordererrors.CodeOptimisticLock}),
+		},
+		{
+			name:         "current version and status are omitted when unknown",
+			cfg:          config.OrdersConfig{ConflictDetails: true},
+			mockService:  mockOrderService{error: ordererrors.ErrOptimisticLock},
+			expectedBody: toJSON(t, ErrorResponse{Error: message, Code: ordererrors.CodeOptimisticLock}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, tc.cfg, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/orders/"+mockOrderID.String(), strings.NewReader(requestBody))
+			req.SetPathValue("id", mockOrderID.String())
+			req = req.WithContext(web.WithUser(context.Background(), web.User{ID: mockUserID.String()}))
+			rr := httptest.NewRecorder()
+			// when
+			api.Update(rr, req)
+			// then
+			assert.Equal(t, http.StatusConflict, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_OrderAPI_UpdateItems(t *testing.T) {
+	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	mockProductID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	createdAt := time.Now()
+	order := &service.OrderDto{ID: mockOrderID, UserID: mockUserID, Status: service.StatusPending, Version: 2, CreatedAt: createdAt.Format(time.RFC3339)}
+	requestBody := toJSON(t, service.OrderItemsUpdateDto{
+		Items:   []service.OrderItemUpdateDto{{ProductID: mockProductID, Quantity: 2}},
+		Version: 1,
+	})
+	testCases := []struct {
+		name         string
+		mockService  mockOrderService
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Success - order items updated",
+			mockService:  mockOrderService{order: order},
+			requestBody:  requestBody,
+			expectedCode: http.StatusOK,
+			expectedBody: toJSON(t, order),
+		},
+		{
+			name:         "Error - validation failed",
+			mockService:  mockOrderService{},
+			requestBody:  toJSON(t, service.OrderItemsUpdateDto{}),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ValidationErrorResponse{
+				Code: web.CodeValidationFailed,
+				ValidationErrors: map[string]string{
+					"Items":   "failed on rule: required",
+					"Version": "failed on rule: required",
+				},
+			}),
+		},
+		{
+			name:         "Error - quantity exceeds the maximum",
+			mockService:  mockOrderService{error: ordererrors.ErrQuantityExceedsMax},
+			requestBody:  requestBody,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Quantity of an order item exceeds the maximum of 10",
+				Code:  ordererrors.CodeQuantityExceedsMax,
+			}),
+		},
+		{
+			name:         "Error - order is not pending",
+			mockService:  mockOrderService{error: ordererrors.ErrOrderNotPending},
+			requestBody:  requestBody,
+			expectedCode: http.StatusConflict,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Items of order with ID " + mockOrderID.String() + " can't be modified: the order is not pending",
+				Code:  ordererrors.CodeOrderNotPending,
+			}),
+		},
+		{
+			name:         "Error - optimistic lock",
+			mockService:  mockOrderService{error: ordererrors.ErrOptimisticLock},
+			requestBody:  requestBody,
+			expectedCode: http.StatusConflict,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Order with ID " + mockOrderID.String() + " has been modified by another user",
+				Code:  ordererrors.CodeOptimisticLock,
+			}),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.OrdersConfig{MaxItemQuantity: 10}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/orders/"+mockOrderID.String()+"/items", strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.SetPathValue("id", mockOrderID.String())
+			req = req.WithContext(web.WithUser(context.Background(), web.User{ID: mockUserID.String()}))
+			rr := httptest.NewRecorder()
+
+			// when
+			api.UpdateItems(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_OrderAPI_DeleteByID(t *testing.T) {
+	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockUserID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	testCases := []struct {
+		name          string
+		mockService   mockOrderService
+		roles         string
+		query         string
+		expectedCode  int
+		expectedBody  string
+		expectDeleted bool
+	}{
+		{
+			name:          "Success - admin deletes the order",
+			roles:         "user,admin",
+			query:         "version=1",
+			expectedCode:  http.StatusNoContent,
+			expectDeleted: true,
+		},
+		{
+			name:         "Error - missing admin role",
+			roles:        "user",
+			query:        "version=1",
+			expectedCode: http.StatusForbidden,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Access denied: missing role admin", Code: web.CodeForbidden}),
+		},
+		{
+			name:         "Error - no version provided",
+			roles:        "admin",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{Error: "version url parameter is required", Code: web.CodeBadRequest}),
+		},
+		{
+			name:         "Error - order not found",
+			mockService:  mockOrderService{error: ordererrors.ErrOrderNotFound},
+			roles:        "admin",
+			query:        "version=1",
+			expectedCode: http.StatusNotFound,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Order with ID " + mockOrderID.String() + " not found",
+				Code:  ordererrors.CodeOrderNotFound,
+			}),
+			expectDeleted: true,
+		},
+		{
+			name:         "Error - optimistic lock",
+			mockService:  mockOrderService{error: ordererrors.ErrOptimisticLock},
+			roles:        "admin",
+			query:        "version=1",
+			expectedCode: http.StatusConflict,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Order with ID " + mockOrderID.String() + " has been modified by another user",
+				Code:  ordererrors.CodeOptimisticLock,
+			}),
+			expectDeleted: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.OrdersConfig{}, pconfig.DefaultBasePath, logger)
+			router := chi.NewRouter()
+			api.RegisterRoutes(router)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/admin/orders/"+mockOrderID.String()+"?"+tc.query, nil)
+			req.Header.Set(web.XUserId, mockUserID.String())
+			if tc.roles != "" {
+				req.Header.Set(web.XUserRoles, tc.roles)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			router.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+			} else {
+				assert.Empty(t, rr.Body.String(), "response body should be empty")
+			}
+			if tc.expectDeleted {
+				assert.Equal(t, &mockOrderID, tc.mockService.deleted, "the order should be passed to the service")
+			} else {
+				assert.Nil(t, tc.mockService.deleted, "the service should not be called")
+			}
+		})
+	}
+}
+
+func Test_OrderAPI_ResendNotification(t *testing.T) {
+	mockOrderID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockAdminID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	testCases := []struct {
+		name         string
+		mockService  mockOrderService
+		roles        string
+		expectedCode int
+		expectedBody string
+		expectResent bool
+	}{
+		{
+			name:         "Success - admin resends the notification",
+			roles:        "user,admin",
+			expectedCode: http.StatusAccepted,
+			expectResent: true,
+		},
+		{
+			name:         "Error - missing admin role",
+			roles:        "user",
+			expectedCode: http.StatusForbidden,
+			expectedBody: toJSON(t, ErrorResponse{Error: "Access denied: missing role admin", Code: web.CodeForbidden}),
+		},
+		{
+			name:         "Error - order not found",
+			mockService:  mockOrderService{error: ordererrors.ErrOrderNotFound},
+			roles:        "admin",
+			expectedCode: http.StatusNotFound,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Order with ID " + mockOrderID.String() + " not found",
+				Code:  ordererrors.CodeOrderNotFound,
+			}),
+			expectResent: true,
+		},
+		{
+			name:         "Error - owner not found",
+			mockService:  mockOrderService{error: ordererrors.ErrUserNotFound},
+			roles:        "admin",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Owner of order with ID " + mockOrderID.String() + " not found",
+				Code:  ordererrors.CodeUserNotFound,
+			}),
+			expectResent: true,
+		},
+		{
+			name:         "Error - publishing failed",
+			mockService:  mockOrderService{error: errors.New("nats unavailable")},
+			roles:        "admin",
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: toJSON(t, ErrorResponse{
+				Error: "Failed to resend notification of order with ID " + mockOrderID.String(),
+				Code:  web.CodeInternal,
+			}),
+			expectResent: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.OrdersConfig{}, pconfig.DefaultBasePath, logger)
+			router := chi.NewRouter()
+			api.RegisterRoutes(router)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/orders/"+mockOrderID.String()+"/resend-notification", nil)
+			req.Header.Set(web.XUserId, mockAdminID.String())
+			req.Header.Set(web.XUserRoles, tc.roles)
+			rr := httptest.NewRecorder()
 
-	// when
-	api.HealthCheck(rr, req)
+			// when
+			router.ServeHTTP(rr, req)
 
-	// then
-	assert.Equal(t, http.StatusOK, rr.Code, "status code should be 200 OK")
-	assert.Empty(t, rr.Body.String(), "response body should be empty")
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+			} else {
+				assert.Empty(t, rr.Body.String(), "response body should be empty")
+			}
+			if tc.expectResent {
+				assert.Equal(t, &mockOrderID, tc.mockService.resent, "order ID should be passed to the service")
+			} else {
+				assert.Nil(t, tc.mockService.resent, "service should not be called")
+			}
+		})
+	}
 }
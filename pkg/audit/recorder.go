@@ -0,0 +1,70 @@
+// Package audit publishes structured audit events describing changes made to resources.
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/messaging"
+	"github.com/abgdnv/gocommerce/pkg/messaging/events"
+	"github.com/abgdnv/gocommerce/pkg/web"
+)
+
+const (
+	ActionCreate      = "create"
+	ActionUpdate      = "update"
+	ActionUpdateStock = "update_stock"
+	ActionDelete      = "delete"
+)
+
+// AnonymousActor is recorded when the request carries no user identity.
+const AnonymousActor = "anonymous"
+
+// Recorder records audit events. Failures are logged and never returned,
+// so auditing can't break the operation being audited.
+type Recorder interface {
+	Record(ctx context.Context, action, resource, resourceID string)
+}
+
+// NoopRecorder discards all audit events. It is used when auditing is disabled.
+type NoopRecorder struct{}
+
+func (NoopRecorder) Record(context.Context, string, string, string) {}
+
+// PublishingRecorder publishes audit events through a messaging.Publisher.
+type PublishingRecorder struct {
+	publisher messaging.Publisher
+	logger    *slog.Logger
+}
+
+// NewPublishingRecorder creates a new PublishingRecorder.
+func NewPublishingRecorder(publisher messaging.Publisher, logger *slog.Logger) *PublishingRecorder {
+	return &PublishingRecorder{publisher: publisher, logger: logger}
+}
+
+// Record publishes an audit event for the given action. The actor is taken from the request context.
+func (r *PublishingRecorder) Record(ctx context.Context, action, resource, resourceID string) {
+	event := events.AuditEvent{
+		Actor:      actor(ctx),
+		Action:     action,
+		Resource:   resource,
+		ResourceID: resourceID,
+		At:         time.Now().UTC(),
+	}
+	if err := r.publisher.Publish(ctx, event); err != nil {
+		r.logger.ErrorContext(ctx, "failed to publish audit event",
+			slog.String("action", action),
+			slog.String("resource", resource),
+			slog.String("resource_id", resourceID),
+			slog.Any("error", err))
+	}
+}
+
+// actor returns the user ID from the context, or AnonymousActor if there is none.
+func actor(ctx context.Context) string {
+	if userID := web.GetUserIDString(ctx); userID != "" {
+		return userID
+	}
+	return AnonymousActor
+}
@@ -2,102 +2,216 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/lestrrat-go/jwx/v3/jws"
 	"github.com/lestrrat-go/jwx/v3/jwt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	// ErrInvalidIssuer is returned when the `iss` claim of the token is not the expected issuer.
+	ErrInvalidIssuer = errors.New("token issuer is not the expected issuer")
+	// ErrInvalidAudience is returned when the `aud` claim of the token doesn't contain the expected audience.
+	ErrInvalidAudience = errors.New("token audience doesn't contain the expected audience")
 )
 
 type Verifier interface {
 	Verify(ctx context.Context, tokenString string) (jwt.Token, error)
 }
 
+// Triggers of JWKS refreshes, used as the trigger attribute of the jwks_refreshes counter.
+const (
+	refreshStartup    = "startup"
+	refreshScheduled  = "scheduled"
+	refreshUnknownKey = "unknown_kid"
+)
+
 // JWTVerifier manages JWT verification using a JWKS endpoint.
-// It caches the JWKS set to minimize network calls and supports automatic refresh.
+// It caches the JWKS set, which Run refreshes every refresh interval in the background.
+// A token signed with a key ID missing from the cache triggers an immediate refresh, so rotated keys are
+// picked up without waiting for the next scheduled refresh. Such refreshes are done at most once per min interval,
+// so tokens with made-up key IDs can't flood the identity provider.
+// The JWKS is fetched without holding the cache lock, so a slow identity provider never blocks the verification
+// of tokens signed with cached keys.
 type JWTVerifier struct {
+	// mu guards the cached set and the time of the last fetch, it is only held to read or swap them.
 	mu sync.RWMutex
+	// fetchMu serializes the fetches, so concurrent tokens with the same unknown key ID trigger a single fetch.
+	fetchMu sync.Mutex
 
 	jwksURL  string
 	issuer   string
+	audience string
 	clientID string
 
-	cachedSet     jwk.Set
-	lastRefreshed time.Time
-	minInterval   time.Duration
+	cachedSet       jwk.Set
+	lastFetched     time.Time
+	minInterval     time.Duration
+	refreshInterval time.Duration
+	fetchTimeout    time.Duration
+	httpClient      *http.Client
+	logger          *slog.Logger
+
+	hits      metric.Int64Counter
+	misses    metric.Int64Counter
+	refreshes metric.Int64Counter
 }
 
-// NewJWTVerifier creates a new JWTVerifier instance.
-func NewJWTVerifier(ctx context.Context, cfg config.IdP) (*JWTVerifier, error) {
+// NewJWTVerifier creates a new JWTVerifier instance, which records the cache metrics with the meter.
+func NewJWTVerifier(ctx context.Context, cfg config.IdP, meter metric.Meter, logger *slog.Logger) (*JWTVerifier, error) {
+	fetchTimeout := cfg.JwksFetchTimeout()
 	v := &JWTVerifier{
-		jwksURL:     cfg.JwksURL,
-		issuer:      cfg.Issuer,
-		clientID:    cfg.ClientID,
-		minInterval: cfg.MinInterval,
+		jwksURL:         cfg.JwksURL,
+		issuer:          cfg.ExpectedIssuer,
+		audience:        cfg.ExpectedAudience,
+		clientID:        cfg.ClientID,
+		minInterval:     cfg.MinInterval,
+		refreshInterval: cfg.RefreshInterval,
+		fetchTimeout:    fetchTimeout,
+		httpClient:      &http.Client{Timeout: fetchTimeout},
+		logger:          logger,
 	}
+	var err error
+	if v.hits, err = meter.Int64Counter("jwks_cache_hits",
+		metric.WithDescription("Total number of tokens verified with a cached JWKS key")); err != nil {
+		return nil, fmt.Errorf("failed to create jwks_cache_hits counter: %w", err)
+	}
+	if v.misses, err = meter.Int64Counter("jwks_cache_misses",
+		metric.WithDescription("Total number of tokens signed with a key missing from the cached JWKS")); err != nil {
+		return nil, fmt.Errorf("failed to create jwks_cache_misses counter: %w", err)
+	}
+	if v.refreshes, err = meter.Int64Counter("jwks_refreshes",
+		metric.WithDescription("Total number of JWKS fetches from the identity provider")); err != nil {
+		return nil, fmt.Errorf("failed to create jwks_refreshes counter: %w", err)
+	}
+
 	// Fail-Fast: Immediately fetch the JWKS to ensure the configuration is valid.
-	if _, err := v.getKeySet(ctx); err != nil {
+	if err := v.refresh(ctx, refreshStartup); err != nil {
 		return nil, fmt.Errorf("initial JWKS fetch failed: %w", err)
 	}
-
 	return v, nil
 }
 
-// getKeySet retrieves the JWKS set, caching it for subsequent calls.
-func (v *JWTVerifier) getKeySet(ctx context.Context) (jwk.Set, error) {
-	// Check if the cached set is still valid
-	// If it is, return it immediately to avoid unnecessary network calls.
+// Run refreshes the JWKS every refresh interval until the context is cancelled.
+// A failed refresh keeps the cached JWKS, so verification continues while the identity provider is unavailable.
+func (v *JWTVerifier) Run(ctx context.Context) error {
+	ticker := time.NewTicker(v.refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		v.fetchMu.Lock()
+		err := v.refresh(ctx, refreshScheduled)
+		v.fetchMu.Unlock()
+		if err != nil && ctx.Err() == nil {
+			v.logger.WarnContext(ctx, "Scheduled JWKS refresh failed, using the cached JWKS", "error", err)
+		}
+	}
+}
+
+// getKeySet returns the JWKS to verify a token signed with the key ID.
+// If the key ID is missing from the cached JWKS, the JWKS is refreshed unless it was fetched within the min interval.
+func (v *JWTVerifier) getKeySet(ctx context.Context, keyID string) jwk.Set {
 	v.mu.RLock()
-	if v.cachedSet != nil && time.Since(v.lastRefreshed) < v.minInterval {
-		set := v.cachedSet
-		v.mu.RUnlock()
-		return set, nil
+	set := v.cachedSet
+	v.mu.RUnlock()
+	if keyID == "" {
+		// the token can't be verified without a key ID, the parser reports it
+		return set
+	}
+	if _, ok := set.LookupKeyID(keyID); ok {
+		v.hits.Add(ctx, 1)
+		return set
 	}
+	v.misses.Add(ctx, 1)
+
+	// Serialize the fetches, so the goroutines waiting for the same unknown key ID don't fetch the JWKS again.
+	v.fetchMu.Lock()
+	defer v.fetchMu.Unlock()
+	// Double-check the cache after acquiring the lock, another goroutine may have fetched the key already.
+	v.mu.RLock()
+	set, lastFetched := v.cachedSet, v.lastFetched
 	v.mu.RUnlock()
+	if _, ok := set.LookupKeyID(keyID); ok || time.Since(lastFetched) < v.minInterval {
+		return set
+	}
+	if err := v.refresh(ctx, refreshUnknownKey); err != nil {
+		// If the fetch fails, return the cached set, the token is rejected if its key is still unknown.
+		// This ensures that the application can still function if the JWKS endpoint is temporarily unavailable.
+		v.logger.WarnContext(ctx, "JWKS refresh for an unknown key ID failed, using the cached JWKS", "kid", keyID, "error", err)
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.cachedSet
+}
 
-	// If the cached set is not valid or not present, fetch it from the JWKS URL.
-	// Use a write lock to ensure thread safety while updating the cache.
-	// This prevents multiple goroutines from fetching the JWKS simultaneously.
+// refresh fetches the JWKS within the fetch timeout and replaces the cached set with it.
+// The cache lock is only held to swap the set, a failed fetch keeps the cached set.
+func (v *JWTVerifier) refresh(ctx context.Context, trigger string) error {
+	fetchCtx, cancel := context.WithTimeout(ctx, v.fetchTimeout)
+	defer cancel()
+	fetchedAt := time.Now()
+	set, err := jwk.Fetch(fetchCtx, v.jwksURL, jwk.WithHTTPClient(v.httpClient))
+	v.refreshes.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("trigger", trigger),
+		attribute.Bool("success", err == nil),
+	))
 	v.mu.Lock()
 	defer v.mu.Unlock()
-	// Double-check if the cached set is still valid after acquiring the lock.
-	// This prevents unnecessary network calls if another goroutine has already updated the cache.
-	if v.cachedSet != nil && time.Since(v.lastRefreshed) < v.minInterval {
-		return v.cachedSet, nil
-	}
-	set, err := jwk.Fetch(ctx, v.jwksURL)
+	v.lastFetched = fetchedAt
 	if err != nil {
-		// If the fetch fails, return the cached set if available, or an error.
-		// This ensures that the application can still function if the JWKS endpoint is temporarily unavailable.
-		if v.cachedSet != nil {
-			return v.cachedSet, nil
-		}
-		return nil, fmt.Errorf("failed to fetch JWKS from %s: %w", v.jwksURL, err)
+		return fmt.Errorf("failed to fetch JWKS from %s: %w", v.jwksURL, err)
 	}
 	v.cachedSet = set
-	v.lastRefreshed = time.Now()
-	return v.cachedSet, nil
+	return nil
 }
 
-func (v *JWTVerifier) Verify(ctx context.Context, tokenString string) (jwt.Token, error) {
-	set, err := v.getKeySet(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get keyset for verification: %w", err)
+// tokenKeyID returns the key ID the token is signed with, or an empty string if the token has none or is malformed.
+func tokenKeyID(tokenString string) string {
+	msg, err := jws.Parse([]byte(tokenString))
+	if err != nil || len(msg.Signatures()) == 0 {
+		return ""
 	}
+	keyID, _ := msg.Signatures()[0].ProtectedHeaders().KeyID()
+	return keyID
+}
 
-	token, err := jwt.Parse(
-		[]byte(tokenString),
-		jwt.WithKeySet(set),
+// Verify parses the token and verifies its signature, expiration, issuer, audience and authorized party.
+// Tokens issued by another issuer are rejected with ErrInvalidIssuer,
+// tokens issued for another audience are rejected with ErrInvalidAudience.
+func (v *JWTVerifier) Verify(ctx context.Context, tokenString string) (jwt.Token, error) {
+	options := []jwt.ParseOption{
+		jwt.WithKeySet(v.getKeySet(ctx, tokenKeyID(tokenString))),
 		// Standard validation checks - expiration, not before, etc.
 		jwt.WithValidate(true),
 		// Validate the issuer
 		jwt.WithIssuer(v.issuer),
 		// Validate the authorized party (client ID)
 		jwt.WithClaimValue("azp", v.clientID),
-	)
-	if err != nil {
+	}
+	if v.audience != "" {
+		// Validate the audience, so tokens minted for another client are rejected
+		options = append(options, jwt.WithAudience(v.audience))
+	}
+	token, err := jwt.Parse([]byte(tokenString), options...)
+	switch {
+	case errors.Is(err, jwt.InvalidIssuerError()):
+		return nil, fmt.Errorf("failed to verify token: %w: expected %q", ErrInvalidIssuer, v.issuer)
+	case errors.Is(err, jwt.InvalidAudienceError()):
+		return nil, fmt.Errorf("failed to verify token: %w: expected %q", ErrInvalidAudience, v.audience)
+	case err != nil:
 		return nil, fmt.Errorf("failed to verify token: %w", err)
 	}
 	return token, nil
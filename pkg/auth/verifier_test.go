@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+const (
+	testIssuer   = "http://keycloak/realms/gocommerce"
+	testClientID = "gocommerce-api"
+)
+
+// jwksServer serves the public keys of a JWKS, which can be replaced to simulate a key rotation.
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	set     jwk.Set
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys ...jwk.Key) *jwksServer {
+	t.Helper()
+	s := &jwksServer{}
+	s.rotate(t, keys...)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// rotate replaces the served JWKS with the public keys of the signing keys.
+func (s *jwksServer) rotate(t *testing.T, keys ...jwk.Key) {
+	t.Helper()
+	set := jwk.NewSet()
+	for _, key := range keys {
+		publicKey, err := jwk.PublicKeyOf(key)
+		require.NoError(t, err)
+		require.NoError(t, set.AddKey(publicKey))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set = set
+}
+
+// newSigningKey generates an RSA signing key with the key ID.
+func newSigningKey(t *testing.T, keyID string) jwk.Key {
+	t.Helper()
+	rawKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := jwk.Import(rawKey)
+	require.NoError(t, err)
+	require.NoError(t, key.Set(jwk.KeyIDKey, keyID))
+	require.NoError(t, key.Set(jwk.AlgorithmKey, jwa.RS256()))
+	return key
+}
+
+// signToken returns a valid token signed with the key.
+func signToken(t *testing.T, key jwk.Key) string {
+	t.Helper()
+	token, err := jwt.NewBuilder().
+		Subject("user-123").
+		Issuer(testIssuer).
+		Claim("azp", testClientID).
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(time.Hour)).
+		Build()
+	require.NoError(t, err)
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256(), key))
+	require.NoError(t, err)
+	return string(signed)
+}
+
+// newTestVerifier creates a verifier of the JWKS server, which records its metrics with the reader.
+func newTestVerifier(t *testing.T, server *jwksServer, minInterval, refreshInterval time.Duration) (*JWTVerifier, *sdkmetric.ManualReader) {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	verifier, err := NewJWTVerifier(context.Background(), config.IdP{
+		JwksURL:         server.URL,
+		ClientID:        testClientID,
+		ExpectedIssuer:  testIssuer,
+		MinInterval:     minInterval,
+		RefreshInterval: refreshInterval,
+	}, meter, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	return verifier, reader
+}
+
+// counterValue returns the sum of the data points of the counter which have all the attributes.
+func counterValue(t *testing.T, reader *sdkmetric.ManualReader, name string, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	var value int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok, "metric %s is not an int64 counter", name)
+			for _, dp := range sum.DataPoints {
+				matches := true
+				for _, attr := range attrs {
+					if v, ok := dp.Attributes.Value(attr.Key); !ok || v != attr.Value {
+						matches = false
+					}
+				}
+				if matches {
+					value += dp.Value
+				}
+			}
+		}
+	}
+	return value
+}
+
+func TestJWTVerifier_UnknownKeyID(t *testing.T) {
+	// given
+	oldKey := newSigningKey(t, "old-key")
+	newKey := newSigningKey(t, "new-key")
+	server := newJWKSServer(t, oldKey)
+	verifier, reader := newTestVerifier(t, server, time.Nanosecond, time.Hour)
+	_, err := verifier.Verify(context.Background(), signToken(t, oldKey))
+	require.NoError(t, err)
+
+	// the identity provider rotates the signing key
+	server.rotate(t, oldKey, newKey)
+
+	// when
+	_, err = verifier.Verify(context.Background(), signToken(t, newKey))
+
+	// then
+	require.NoError(t, err, "the verifier should refresh the JWKS and verify the token signed with the new key")
+	assert.EqualValues(t, 2, server.fetches.Load(), "the JWKS should be fetched on startup and for the unknown key ID")
+	assert.EqualValues(t, 1, counterValue(t, reader, "jwks_cache_misses"))
+	assert.EqualValues(t, 1, counterValue(t, reader, "jwks_cache_hits"))
+	assert.EqualValues(t, 1, counterValue(t, reader, "jwks_refreshes",
+		attribute.String("trigger", refreshUnknownKey), attribute.Bool("success", true)))
+
+	// when
+	_, err = verifier.Verify(context.Background(), signToken(t, newKey))
+
+	// then
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, server.fetches.Load(), "the new key should be served from the cache")
+	assert.EqualValues(t, 2, counterValue(t, reader, "jwks_cache_hits"))
+}
+
+func TestJWTVerifier_UnknownKeyID_WithinMinInterval(t *testing.T) {
+	// given
+	oldKey := newSigningKey(t, "old-key")
+	newKey := newSigningKey(t, "new-key")
+	server := newJWKSServer(t, oldKey)
+	verifier, reader := newTestVerifier(t, server, time.Hour, time.Hour)
+	server.rotate(t, oldKey, newKey)
+
+	// when
+	_, err := verifier.Verify(context.Background(), signToken(t, newKey))
+	_, errUnknown := verifier.Verify(context.Background(), signToken(t, newSigningKey(t, "made-up-key")))
+
+	// then
+	assert.Error(t, err, "the JWKS shouldn't be refreshed within the min interval")
+	assert.Error(t, errUnknown)
+	assert.EqualValues(t, 1, server.fetches.Load(), "the JWKS should be fetched on startup only")
+	assert.EqualValues(t, 2, counterValue(t, reader, "jwks_cache_misses"))
+}
+
+func TestJWTVerifier_Run(t *testing.T) {
+	// given
+	oldKey := newSigningKey(t, "old-key")
+	newKey := newSigningKey(t, "new-key")
+	server := newJWKSServer(t, oldKey)
+	// the min interval prevents refreshes for unknown key IDs, so only the scheduled refresh picks up the new key
+	verifier, reader := newTestVerifier(t, server, time.Hour, 10*time.Millisecond)
+	server.rotate(t, oldKey, newKey)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	// when
+	go func() { done <- verifier.Run(ctx) }()
+
+	// then
+	require.Eventually(t, func() bool {
+		return counterValue(t, reader, "jwks_refreshes", attribute.String("trigger", refreshScheduled)) > 0
+	}, time.Second, 5*time.Millisecond, "the JWKS should be refreshed in the background")
+	_, err := verifier.Verify(context.Background(), signToken(t, newKey))
+	require.NoError(t, err)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestJWTVerifier_Run_UnresponsiveIdentityProvider(t *testing.T) {
+	// given
+	key := newSigningKey(t, "key")
+	server := newJWKSServer(t, key)
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	verifier, err := NewJWTVerifier(context.Background(), config.IdP{
+		JwksURL:         server.URL,
+		ClientID:        testClientID,
+		ExpectedIssuer:  testIssuer,
+		MinInterval:     time.Hour,
+		RefreshInterval: 300 * time.Millisecond,
+		FetchTimeout:    time.Second,
+	}, meter, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	// the identity provider stops responding, the server blocks until the JWKS is released
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- verifier.Run(ctx) }()
+	require.Eventually(t, func() bool {
+		return server.fetches.Load() > 1
+	}, time.Second, time.Millisecond, "the scheduled refresh should be in progress")
+	time.Sleep(10 * time.Millisecond)
+
+	// when
+	start := time.Now()
+	_, err = verifier.Verify(context.Background(), signToken(t, key))
+
+	// then
+	require.NoError(t, err, "the cached key should verify the token during the refresh")
+	assert.Less(t, time.Since(start), 100*time.Millisecond, "the verification should not wait for the refresh")
+	require.Eventually(t, func() bool {
+		return counterValue(t, reader, "jwks_refreshes",
+			attribute.String("trigger", refreshScheduled), attribute.Bool("success", false)) > 0
+	}, 3*time.Second, 10*time.Millisecond, "the refresh should fail after the fetch timeout")
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -3,32 +3,48 @@ package bootstrap
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"time"
 
+	"github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/abgdnv/gocommerce/pkg/logger"
+	"github.com/abgdnv/gocommerce/pkg/telemetry"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// NewLogger creates a new slog.Logger instance with the specified log level.
-func NewLogger(level string) *slog.Logger {
-	logLevel := toLevel(level)
-	loggerOpts := &slog.HandlerOptions{
-		AddSource: logLevel == slog.LevelDebug,
-		Level:     logLevel,
+// NewLogger creates a new slog.Logger instance writing to stdout with the specified log level,
+// in the text format or JSON for any other format. addSource adds the source file and line to every record.
+// The records logged with a context carry its request ID and the IDs of its span.
+func NewLogger(level, format string, addSource bool) *slog.Logger {
+	return slog.New(logger.NewContextHandler(telemetry.NewLogHandler(newLogHandler(os.Stdout, level, format, addSource))))
+}
+
+// newLogHandler creates the slog handler of the format writing to w.
+func newLogHandler(w io.Writer, level, format string, addSource bool) slog.Handler {
+	opts := &slog.HandlerOptions{
+		AddSource: addSource,
+		Level:     toLevel(level),
+	}
+	if format == config.LogFormatText {
+		return slog.NewTextHandler(w, opts)
 	}
-	logHandler := slog.NewJSONHandler(os.Stdout, loggerOpts)
-	return slog.New(logger.NewContextHandler(logHandler))
+	return slog.NewJSONHandler(w, opts)
 }
 
 // NewDbPool creates a new database connection pool with the provided context and configuration,
-func NewDbPool(ctx context.Context, url string, connectTimeout time.Duration) (*pgxpool.Pool, error) {
+// the pool settings which are not set keep the pgx defaults.
+func NewDbPool(ctx context.Context, url string, connectTimeout time.Duration, poolCfg config.PoolConfig) (*pgxpool.Pool, error) {
 	// Create context with timeout for database connection
 	poolCtx, cancel := context.WithTimeout(ctx, connectTimeout)
 	defer cancel()
 
-	dbPool, errPool := pgxpool.New(poolCtx, url)
+	pgxCfg, err := newPoolConfig(url, poolCfg)
+	if err != nil {
+		return nil, err
+	}
+	dbPool, errPool := pgxpool.NewWithConfig(poolCtx, pgxCfg)
 	if errPool != nil {
 		return nil, fmt.Errorf("failed to create database connection pool: %w", errPool)
 	}
@@ -39,6 +55,31 @@ func NewDbPool(ctx context.Context, url string, connectTimeout time.Duration) (*
 	return dbPool, nil
 }
 
+// newPoolConfig parses the database URL and applies the pool settings which are set.
+func newPoolConfig(url string, poolCfg config.PoolConfig) (*pgxpool.Config, error) {
+	pgxCfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+	if poolCfg.MaxConns > 0 {
+		pgxCfg.MaxConns = poolCfg.MaxConns
+	}
+	if poolCfg.MinConns > 0 {
+		pgxCfg.MinConns = poolCfg.MinConns
+	}
+	if poolCfg.MaxConnLifetime > 0 {
+		pgxCfg.MaxConnLifetime = poolCfg.MaxConnLifetime
+	}
+	if poolCfg.MaxConnIdleTime > 0 {
+		pgxCfg.MaxConnIdleTime = poolCfg.MaxConnIdleTime
+	}
+	// MaxConns may be the pgx default, which depends on the number of CPUs
+	if pgxCfg.MinConns > pgxCfg.MaxConns {
+		return nil, fmt.Errorf("database pool minConns (%d) must not be greater than maxConns (%d)", pgxCfg.MinConns, pgxCfg.MaxConns)
+	}
+	return pgxCfg, nil
+}
+
 // toLevel converts a string representation of a log level to slog.Level.
 func toLevel(level string) slog.Level {
 	switch level {
@@ -0,0 +1,115 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc"
+)
+
+// HTTPServerComponent runs an *http.Server as a Component.
+type HTTPServerComponent struct {
+	name   string
+	server *http.Server
+	logger *slog.Logger
+}
+
+// NewHTTPServerComponent creates a Component for the given HTTP server.
+func NewHTTPServerComponent(name string, server *http.Server, logger *slog.Logger) *HTTPServerComponent {
+	return &HTTPServerComponent{name: name, server: server, logger: logger}
+}
+
+func (c *HTTPServerComponent) Name() string {
+	return c.name
+}
+
+func (c *HTTPServerComponent) Start(_ context.Context) error {
+	c.logger.Info(c.name+" listening", slog.String("addr", c.server.Addr))
+	if err := c.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (c *HTTPServerComponent) Shutdown(ctx context.Context) error {
+	return c.server.Shutdown(ctx)
+}
+
+// GRPCServerComponent runs a *grpc.Server as a Component.
+type GRPCServerComponent struct {
+	name   string
+	server *grpc.Server
+	addr   string
+	logger *slog.Logger
+}
+
+// NewGRPCServerComponent creates a Component for the given gRPC server, which listens on addr.
+func NewGRPCServerComponent(name string, server *grpc.Server, addr string, logger *slog.Logger) *GRPCServerComponent {
+	return &GRPCServerComponent{name: name, server: server, addr: addr, logger: logger}
+}
+
+func (c *GRPCServerComponent) Name() string {
+	return c.name
+}
+
+func (c *GRPCServerComponent) Start(_ context.Context) error {
+	lis, err := net.Listen("tcp", c.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on gRPC port: %w", err)
+	}
+	c.logger.Info(c.name+" listening", slog.String("addr", c.addr))
+	if err := c.server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops the gRPC server gracefully, forcing the stop if the context is done first.
+func (c *GRPCServerComponent) Shutdown(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		c.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		c.logger.Warn(c.name + " graceful stop timed out. Forcing stop.")
+		c.server.Stop()
+		return fmt.Errorf("grpc server graceful stop timed out")
+	}
+}
+
+// FuncComponent adapts plain functions to the Component interface.
+// A nil StartFn blocks until the context is cancelled, which suits resources that only need a shutdown
+// (tracer provider, client connections). A nil ShutdownFn is a no-op, which suits background workers
+// stopped by the context cancellation.
+type FuncComponent struct {
+	ComponentName string
+	StartFn       func(ctx context.Context) error
+	ShutdownFn    func(ctx context.Context) error
+}
+
+func (c *FuncComponent) Name() string {
+	return c.ComponentName
+}
+
+func (c *FuncComponent) Start(ctx context.Context) error {
+	if c.StartFn == nil {
+		<-ctx.Done()
+		return nil
+	}
+	return c.StartFn(ctx)
+}
+
+func (c *FuncComponent) Shutdown(ctx context.Context) error {
+	if c.ShutdownFn == nil {
+		return nil
+	}
+	return c.ShutdownFn(ctx)
+}
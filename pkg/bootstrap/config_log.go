@@ -0,0 +1,63 @@
+package bootstrap
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+)
+
+// sensitiveKeys are parts of configuration keys whose values are never logged.
+var sensitiveKeys = []string{"password", "secret", "token", "apikey", "credential"}
+
+// LogEffectiveConfig logs the configuration loaded at startup, grouped by section.
+// Values of sensitive keys are redacted, even if the String method of the configuration does not mask them.
+func LogEffectiveConfig(logger *slog.Logger, cfg fmt.Stringer) {
+	logger.Info("Configuration loaded", configAttrs(cfg.String())...)
+}
+
+// configAttrs converts the string representation of a configuration into log attributes.
+// Sections ("--- Name ---") become groups and "key: value" lines become attributes of the current group.
+func configAttrs(s string) []any {
+	var attrs, section []any
+	var name string
+	flush := func() {
+		if len(section) > 0 {
+			attrs = append(attrs, slog.Group(name, section...))
+		}
+		section = nil
+	}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if header, ok := strings.CutPrefix(line, "---"); ok {
+			flush()
+			name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(strings.TrimSuffix(header, "---"))), " ", "_")
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if value != "" && isSensitive(key) {
+			value = config.MaskedValue
+		}
+		section = append(section, slog.String(key, value))
+	}
+	flush()
+	return attrs
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
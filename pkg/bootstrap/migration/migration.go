@@ -0,0 +1,55 @@
+// Package migration applies the database migrations on service start.
+// It's kept apart from bootstrap so that only the services with a database depend on golang-migrate.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// Up applies the pending migrations from cfg.Migrations to the configured database.
+// The postgres driver holds an advisory lock while migrating, so when several instances start at once
+// only one of them applies the migrations and the others wait for it and find nothing left to do.
+// Cancelling ctx stops the migration after the one in progress.
+func Up(ctx context.Context, cfg config.DatabaseConfig, logger *slog.Logger) error {
+	m, err := migrate.New(cfg.Migrations, cfg.URI())
+	if err != nil {
+		return fmt.Errorf("failed to create the migrator: %w", err)
+	}
+	defer func() {
+		if srcErr, dbErr := m.Close(); srcErr != nil || dbErr != nil {
+			logger.Warn("failed to close the migrator", slog.Any("source_error", srcErr), slog.Any("database_error", dbErr))
+		}
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.GracefulStop <- true
+		case <-done:
+		}
+	}()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply the migrations: %w", err)
+	}
+	// a graceful stop returns without an error, leaving the remaining migrations pending
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("migrations interrupted: %w", err)
+	}
+	version, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("failed to read the migration version: %w", err)
+	}
+	logger.Info("Database migrations applied", slog.Uint64("version", uint64(version)), slog.Bool("dirty", dirty))
+	return nil
+}
@@ -0,0 +1,78 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// DefaultPoolStatsInterval is the interval the database pool stats are exported at.
+const DefaultPoolStatsInterval = 15 * time.Second
+
+// PoolStatsComponent periodically exports the stats of a database connection pool as gauges.
+type PoolStatsComponent struct {
+	pool            *pgxpool.Pool
+	interval        time.Duration
+	acquireCount    metric.Int64Gauge
+	acquireDuration metric.Float64Gauge
+	idleConns       metric.Int64Gauge
+	totalConns      metric.Int64Gauge
+}
+
+// NewPoolStatsComponent creates a Component exporting the stats of the pool with the meter every interval.
+func NewPoolStatsComponent(pool *pgxpool.Pool, meter metric.Meter, interval time.Duration) (*PoolStatsComponent, error) {
+	c := &PoolStatsComponent{pool: pool, interval: interval}
+	var err error
+	if c.acquireCount, err = meter.Int64Gauge("db_pool_acquire_count",
+		metric.WithDescription("Cumulative number of successful connection acquires from the pool")); err != nil {
+		return nil, fmt.Errorf("failed to create the acquire count gauge: %w", err)
+	}
+	if c.acquireDuration, err = meter.Float64Gauge("db_pool_acquire_duration",
+		metric.WithDescription("Total time spent acquiring connections from the pool"), metric.WithUnit("s")); err != nil {
+		return nil, fmt.Errorf("failed to create the acquire duration gauge: %w", err)
+	}
+	if c.idleConns, err = meter.Int64Gauge("db_pool_idle_conns",
+		metric.WithDescription("Number of idle connections in the pool")); err != nil {
+		return nil, fmt.Errorf("failed to create the idle connections gauge: %w", err)
+	}
+	if c.totalConns, err = meter.Int64Gauge("db_pool_total_conns",
+		metric.WithDescription("Total number of connections in the pool")); err != nil {
+		return nil, fmt.Errorf("failed to create the total connections gauge: %w", err)
+	}
+	return c, nil
+}
+
+func (c *PoolStatsComponent) Name() string {
+	return "database pool stats"
+}
+
+// Start exports the pool stats right away and then every interval, until the context is cancelled.
+func (c *PoolStatsComponent) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.export(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown is a no-op, the export stops with the context of Start.
+func (c *PoolStatsComponent) Shutdown(_ context.Context) error {
+	return nil
+}
+
+// export records the current stats of the pool.
+func (c *PoolStatsComponent) export(ctx context.Context) {
+	stat := c.pool.Stat()
+	c.acquireCount.Record(ctx, stat.AcquireCount())
+	c.acquireDuration.Record(ctx, stat.AcquireDuration().Seconds())
+	c.idleConns.Record(ctx, int64(stat.IdleConns()))
+	c.totalConns.Record(ctx, int64(stat.TotalConns()))
+}
@@ -0,0 +1,51 @@
+package bootstrap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestPoolStatsComponent(t *testing.T) {
+	// given
+	// the pool connects lazily, so its stats are available without a database
+	pool, err := pgxpool.New(context.Background(), testDbURL)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	component, err := NewPoolStatsComponent(pool, meterProvider.Meter("test"), time.Hour)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	// when
+	go func() { done <- component.Start(ctx) }()
+
+	// then
+	expected := []string{"db_pool_acquire_count", "db_pool_acquire_duration", "db_pool_idle_conns", "db_pool_total_conns"}
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		var rm metricdata.ResourceMetrics
+		require.NoError(c, reader.Collect(context.Background(), &rm))
+		var names []string
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				names = append(names, m.Name)
+			}
+		}
+		assert.ElementsMatch(c, expected, names)
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the export didn't stop with the context")
+	}
+}
@@ -0,0 +1,78 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Component is a long-running part of a service (HTTP/gRPC server, subscriber, client connection, etc.)
+// whose lifecycle is managed by RunServers.
+type Component interface {
+	// Name returns a human-readable name of the component, used for logging and error messages.
+	Name() string
+	// Start runs the component and blocks until it is stopped, or the context is cancelled.
+	Start(ctx context.Context) error
+	// Shutdown gracefully stops the component. It should return once the context is done.
+	Shutdown(ctx context.Context) error
+}
+
+// RunServers starts all components concurrently and blocks until the context is cancelled or any component fails.
+// A start error cancels the group, after that the components are shut down sequentially in reverse order,
+// so the components registered first (e.g. client connections, tracer provider) outlive the ones depending on them.
+// Each Shutdown call gets its own shutdownTimeout. Returns the first start error joined with the shutdown errors.
+func RunServers(ctx context.Context, components []Component, shutdownTimeout time.Duration, logger *slog.Logger) error {
+	return Run(ctx, components, func() error {
+		var shutdownErr error
+		for i := len(components) - 1; i >= 0; i-- {
+			c := components[i]
+			logger.Info("Shutting down component", slog.String("component", c.Name()))
+			if err := shutdown(c, shutdownTimeout); err != nil {
+				logger.Error("Failed to shut down component", slog.String("component", c.Name()), slog.Any("error", err))
+				shutdownErr = errors.Join(shutdownErr, fmt.Errorf("%s shutdown failed: %w", c.Name(), err))
+			}
+		}
+		return shutdownErr
+	}, logger)
+}
+
+// Run starts all components concurrently like RunServers, but once the context is cancelled or any component fails,
+// it calls shutdownFn instead of shutting down the components in reverse order, e.g. to shut them down in stages.
+// shutdownFn must stop all components, so their Start returns. Returns the first start error joined with the shutdown error.
+func Run(ctx context.Context, components []Component, shutdownFn func() error, logger *slog.Logger) error {
+	g, gCtx := errgroup.WithContext(ctx)
+
+	for _, c := range components {
+		g.Go(func() error {
+			logger.Info("Starting component", slog.String("component", c.Name()))
+			if err := c.Start(gCtx); err != nil && !errors.Is(err, context.Canceled) {
+				return fmt.Errorf("%s failed: %w", c.Name(), err)
+			}
+			return nil
+		})
+	}
+
+	var shutdownErr error
+	g.Go(func() error {
+		<-gCtx.Done()
+		shutdownErr = shutdownFn()
+		return nil
+	})
+
+	err := g.Wait()
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+	return errors.Join(err, shutdownErr)
+}
+
+// shutdown calls the component Shutdown with a fresh context limited by the given timeout.
+func shutdown(c Component, timeout time.Duration) error {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.Shutdown(shutdownCtx)
+}
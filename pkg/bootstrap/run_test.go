@@ -0,0 +1,200 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventLog records lifecycle events of the test components.
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+// filter returns the recorded events with the given prefix, preserving their order.
+func (l *eventLog) filter(prefix string) []string {
+	var filtered []string
+	for _, e := range l.get() {
+		if strings.HasPrefix(e, prefix) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// newTestComponent creates a component which records its lifecycle events.
+// If startErr is not nil, Start returns it immediately, otherwise Start blocks until the context is cancelled.
+func newTestComponent(name string, log *eventLog, started *sync.WaitGroup, startErr error) Component {
+	return &FuncComponent{
+		ComponentName: name,
+		StartFn: func(ctx context.Context) error {
+			log.add("start " + name)
+			started.Done()
+			if startErr != nil {
+				return startErr
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		ShutdownFn: func(ctx context.Context) error {
+			log.add("shutdown " + name)
+			return nil
+		},
+	}
+}
+
+func TestRunServers_ShutdownInReverseOrder(t *testing.T) {
+	// given
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	log := &eventLog{}
+	var started sync.WaitGroup
+	started.Add(3)
+	components := []Component{
+		newTestComponent("tracer", log, &started, nil),
+		newTestComponent("grpc client", log, &started, nil),
+		newTestComponent("http server", log, &started, nil),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+
+	// when
+	go func() {
+		errCh <- RunServers(ctx, components, time.Second, logger)
+	}()
+	started.Wait()
+	cancel()
+
+	// then
+	select {
+	case err := <-errCh:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("RunServers did not return after context cancellation")
+	}
+	events := log.get()
+	require.Len(t, events, 6)
+	assert.ElementsMatch(t, []string{"start tracer", "start grpc client", "start http server"}, events[:3])
+	assert.Equal(t, []string{"shutdown http server", "shutdown grpc client", "shutdown tracer"}, events[3:])
+}
+
+func TestRunServers_StartErrorCancelsGroup(t *testing.T) {
+	// given
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	log := &eventLog{}
+	var started sync.WaitGroup
+	started.Add(2)
+	startErr := errors.New("address already in use")
+	components := []Component{
+		newTestComponent("tracer", log, &started, nil),
+		newTestComponent("http server", log, &started, startErr),
+	}
+
+	// when
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- RunServers(context.Background(), components, time.Second, logger)
+	}()
+
+	// then
+	select {
+	case err := <-errCh:
+		require.Error(t, err)
+		assert.ErrorIs(t, err, startErr)
+		assert.Contains(t, err.Error(), "http server failed")
+	case <-time.After(time.Second):
+		t.Fatal("RunServers did not return after a start error")
+	}
+	assert.ElementsMatch(t, []string{"start tracer", "start http server"}, log.filter("start "))
+	assert.Equal(t, []string{"shutdown http server", "shutdown tracer"}, log.filter("shutdown "))
+}
+
+func TestRunServers_ShutdownErrorsAreReturned(t *testing.T) {
+	// given
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	shutdownErr := errors.New("flush failed")
+	var shutdownCalled bool
+	components := []Component{
+		&FuncComponent{
+			ComponentName: "tracer",
+			ShutdownFn: func(ctx context.Context) error {
+				return shutdownErr
+			},
+		},
+		&FuncComponent{
+			ComponentName: "pprof server",
+			ShutdownFn: func(ctx context.Context) error {
+				shutdownCalled = true
+				_, hasDeadline := ctx.Deadline()
+				assert.True(t, hasDeadline, "shutdown context should have a deadline")
+				return nil
+			},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// when
+	err := RunServers(ctx, components, time.Second, logger)
+
+	// then
+	require.Error(t, err)
+	assert.ErrorIs(t, err, shutdownErr)
+	assert.Contains(t, err.Error(), "tracer shutdown failed")
+	assert.True(t, shutdownCalled, "all components should be shut down even if one of them fails")
+}
+
+func TestRun_CustomShutdown(t *testing.T) {
+	// given
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	log := &eventLog{}
+	var started sync.WaitGroup
+	started.Add(2)
+	components := []Component{
+		newTestComponent("tracer", log, &started, nil),
+		newTestComponent("http server", log, &started, nil),
+	}
+	shutdownErr := errors.New("flush failed")
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+
+	// when
+	go func() {
+		errCh <- Run(ctx, components, func() error {
+			log.add("custom shutdown")
+			return shutdownErr
+		}, logger)
+	}()
+	started.Wait()
+	cancel()
+
+	// then
+	select {
+	case err := <-errCh:
+		assert.ErrorIs(t, err, shutdownErr)
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after context cancellation")
+	}
+	assert.Equal(t, []string{"custom shutdown"}, log.filter("custom "))
+	assert.Empty(t, log.filter("shutdown "), "the components should only be shut down by the custom shutdown")
+}
@@ -0,0 +1,45 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// NewMetricsInterceptor returns a unary client interceptor that counts calls and records their latency.
+// Instruments are named <prefix>_requests and <prefix>_request_duration and are labelled with the
+// gRPC method and status code. Place it first in the chain to measure the latency seen by the caller,
+// including retries and timeouts applied by the interceptors after it.
+// Calls made on behalf of an organization allowlisted by orgLabel are labelled with it as well,
+// see telemetry.ContextWithOrg.
+func NewMetricsInterceptor(meter metric.Meter, prefix string, orgLabel *telemetry.OrgLabel) grpc.UnaryClientInterceptor {
+	requests, err := meter.Int64Counter(prefix+"_requests",
+		metric.WithDescription("Total number of gRPC client requests"))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create %s_requests counter: %v", prefix, err))
+	}
+	duration, err := meter.Float64Histogram(prefix+"_request_duration",
+		metric.WithDescription("Latency of gRPC client requests"),
+		metric.WithUnit("s"))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create %s_request_duration histogram: %v", prefix, err))
+	}
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		attrs := metric.WithAttributes(append([]attribute.KeyValue{
+			attribute.String("rpc.method", method),
+			attribute.String("rpc.grpc.status_code", status.Code(err).String()),
+		}, orgLabel.Attributes(telemetry.OrgFromContext(ctx))...)...)
+		requests.Add(ctx, 1, attrs)
+		duration.Record(ctx, time.Since(start).Seconds(), attrs)
+		return err
+	}
+}
@@ -0,0 +1,188 @@
+package interceptors
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/product/v1"
+	"github.com/abgdnv/gocommerce/pkg/telemetry"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// findMetric returns the metric with the given name from the collected resource metrics.
+func findMetric(t *testing.T, rm metricdata.ResourceMetrics, name string) metricdata.Metrics {
+	t.Helper()
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	require.Failf(t, "metric not found", "metric %s was not recorded", name)
+	return metricdata.Metrics{}
+}
+
+// Test_GRPCClient_Instrumentation tests that product lookups produce spans and metrics
+// when the stats handler and the metrics interceptor are combined with the timeout interceptor.
+func Test_GRPCClient_Instrumentation(t *testing.T) {
+	const clientTimeout = 100 * time.Millisecond
+	testCases := []struct {
+		name               string
+		serviceDelay       time.Duration
+		expectedCode       codes.Code
+		expectedSpanStatus otelcodes.Code
+	}{
+		{
+			name:               "successful lookup",
+			serviceDelay:       0,
+			expectedCode:       codes.OK,
+			expectedSpanStatus: otelcodes.Unset,
+		},
+		{
+			name:               "lookup timed out",
+			serviceDelay:       200 * time.Millisecond,
+			expectedCode:       codes.DeadlineExceeded,
+			expectedSpanStatus: otelcodes.Error,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			lis := bufconn.Listen(1024 * 1024)
+			grpcServer := grpc.NewServer()
+			pb.RegisterProductServiceServer(grpcServer, &slowProductService{delay: tc.serviceDelay})
+			go func() {
+				_ = grpcServer.Serve(lis)
+			}()
+			t.Cleanup(grpcServer.Stop)
+
+			spanRecorder := tracetest.NewSpanRecorder()
+			tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+			metricReader := sdkmetric.NewManualReader()
+			meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader))
+
+			grpcClient, err := grpc.NewClient(
+				"passthrough:///bufnet",
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return lis.DialContext(ctx)
+				}),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithChainUnaryInterceptor(
+					NewMetricsInterceptor(meterProvider.Meter("test"), "product_client", nil),
+					UnaryClientTimeoutInterceptor(clientTimeout),
+				),
+				grpc.WithStatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithTracerProvider(tracerProvider))),
+			)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = grpcClient.Close() })
+			productClient := pb.NewProductServiceClient(grpcClient)
+
+			// when
+			_, err = productClient.GetProduct(context.Background(), &pb.GetProductRequest{Products: []string{uuid.NewString()}})
+
+			// then
+			if tc.expectedCode == codes.OK {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+
+			spans := spanRecorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "product.v1.ProductService/GetProduct", spans[0].Name())
+			assert.Equal(t, tc.expectedSpanStatus, spans[0].Status().Code)
+
+			var rm metricdata.ResourceMetrics
+			require.NoError(t, metricReader.Collect(context.Background(), &rm))
+			expectedAttrs := attribute.NewSet(
+				attribute.String("rpc.method", "/product.v1.ProductService/GetProduct"),
+				attribute.String("rpc.grpc.status_code", tc.expectedCode.String()),
+			)
+
+			requests, ok := findMetric(t, rm, "product_client_requests").Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			require.Len(t, requests.DataPoints, 1)
+			assert.Equal(t, int64(1), requests.DataPoints[0].Value)
+			assert.True(t, expectedAttrs.Equals(&requests.DataPoints[0].Attributes))
+
+			duration, ok := findMetric(t, rm, "product_client_request_duration").Data.(metricdata.Histogram[float64])
+			require.True(t, ok)
+			require.Len(t, duration.DataPoints, 1)
+			assert.Equal(t, uint64(1), duration.DataPoints[0].Count)
+			assert.True(t, expectedAttrs.Equals(&duration.DataPoints[0].Attributes))
+		})
+	}
+}
+
+// Test_MetricsInterceptor_OrgLabel tests that only the calls made on behalf of an allowlisted organization are labelled with it.
+func Test_MetricsInterceptor_OrgLabel(t *testing.T) {
+	const method = "/product.v1.ProductService/GetProduct"
+	testCases := []struct {
+		name          string
+		org           string
+		expectedAttrs attribute.Set
+	}{
+		{
+			name: "allowlisted org is labelled",
+			org:  "acme",
+			expectedAttrs: attribute.NewSet(
+				attribute.String("rpc.method", method),
+				attribute.String("rpc.grpc.status_code", codes.OK.String()),
+				telemetry.OrgAttributeKey.String("acme"),
+			),
+		},
+		{
+			name: "org not in the allowlist is dropped",
+			org:  "initech",
+			expectedAttrs: attribute.NewSet(
+				attribute.String("rpc.method", method),
+				attribute.String("rpc.grpc.status_code", codes.OK.String()),
+			),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			metricReader := sdkmetric.NewManualReader()
+			meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader))
+			interceptor := NewMetricsInterceptor(meterProvider.Meter("test"), "product_client", telemetry.NewOrgLabel([]string{"acme"}))
+			invoker := func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+				return nil
+			}
+			ctx := telemetry.ContextWithOrg(context.Background(), tc.org)
+
+			// when
+			err := interceptor(ctx, method, nil, nil, nil, invoker)
+
+			// then
+			require.NoError(t, err)
+			var rm metricdata.ResourceMetrics
+			require.NoError(t, metricReader.Collect(context.Background(), &rm))
+			requests, ok := findMetric(t, rm, "product_client_requests").Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			require.Len(t, requests.DataPoints, 1)
+			assert.True(t, tc.expectedAttrs.Equals(&requests.DataPoints[0].Attributes))
+
+			duration, ok := findMetric(t, rm, "product_client_request_duration").Data.(metricdata.Histogram[float64])
+			require.True(t, ok)
+			require.Len(t, duration.DataPoints, 1)
+			assert.True(t, tc.expectedAttrs.Equals(&duration.DataPoints[0].Attributes))
+		})
+	}
+}
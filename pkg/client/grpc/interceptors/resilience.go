@@ -2,6 +2,11 @@ package interceptors
 
 import (
 	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/abgdnv/gocommerce/pkg/config"
@@ -12,15 +17,115 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// retryBudgetMaxTokens is the capacity of the retry budget, i.e. the number of retries allowed in a burst.
+const retryBudgetMaxTokens = 10
+
+// errRetryBudgetExhausted stops the retry loop when the retry budget is exhausted.
+// It is not a gRPC status error, so it is never retried itself and never returned to the caller.
+var errRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// retryBudget is a token bucket shared across calls. Every call deposits ratio tokens
+// and every retry withdraws one token, so retries are limited to a fraction of the calls.
+type retryBudget struct {
+	mu        sync.Mutex
+	ratio     float64
+	maxTokens float64
+	tokens    float64
+}
+
+func newRetryBudget(ratio, maxTokens float64) *retryBudget {
+	return &retryBudget{ratio: ratio, maxTokens: maxTokens, tokens: maxTokens}
+}
+
+// deposit adds tokens for a new call.
+func (b *retryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.maxTokens)
+}
+
+// withdraw takes a token for a retry. Returns false if the budget is exhausted.
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// newJitteredBackoff returns an exponential backoff with full jitter: the wait before a retry is random
+// in [0, min(maxBackoff, initial * 2^(attempt-1))], so clients failing together don't retry in lockstep.
+// A zero maxBackoff doesn't cap the backoff. The retry interceptor waits for the backoff respecting the context.
+func newJitteredBackoff(initial, maxBackoff time.Duration, rnd *rand.Rand) retry.BackoffFunc {
+	var mu sync.Mutex
+	return func(_ context.Context, attempt uint) time.Duration {
+		backoff := computeBackoff(initial, maxBackoff, attempt)
+		if backoff <= 0 {
+			return 0
+		}
+		// rand.Rand is not safe for concurrent use
+		mu.Lock()
+		defer mu.Unlock()
+		return time.Duration(rnd.Int64N(int64(backoff) + 1))
+	}
+}
+
+// computeBackoff returns the upper bound of the backoff before the given retry attempt, starting from 1.
+func computeBackoff(initial, maxBackoff time.Duration, attempt uint) time.Duration {
+	if attempt == 0 {
+		return 0
+	}
+	backoff := initial
+	// stop doubling before the duration overflows
+	for i := uint(1); i < attempt && backoff < math.MaxInt64/2; i++ {
+		backoff *= 2
+	}
+	if maxBackoff > 0 {
+		backoff = min(backoff, maxBackoff)
+	}
+	return backoff
+}
+
 // NewRetryInterceptor creates a gRPC unary client interceptor with retry logic.
+// Only errors with one of the configured retryable codes are retried, transient errors by default.
+// If cfg.BudgetRatio is set, retries are limited by a retry budget shared across calls,
+// so broad outages don't multiply the load on the server. The first attempt is never limited.
 func NewRetryInterceptor(cfg config.RetryConfig) grpc.UnaryClientInterceptor {
+	retryCodes := cfg.Codes()
 	opts := []retry.CallOption{
-		// Retry on transient errors.
-		retry.WithCodes(codes.Unavailable, codes.ResourceExhausted, codes.Aborted),
+		retry.WithCodes(retryCodes...),
 		retry.WithMax(cfg.MaxAttempts),
-		retry.WithBackoff(retry.BackoffExponential(cfg.InitialBackoff)),
+		retry.WithBackoff(newJitteredBackoff(cfg.InitialBackoff, cfg.MaxBackoff, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))),
+	}
+	retryInterceptor := retry.UnaryClientInterceptor(opts...)
+	if cfg.BudgetRatio <= 0 {
+		return retryInterceptor
+	}
+
+	budget := newRetryBudget(cfg.BudgetRatio, retryBudgetMaxTokens)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		budget.deposit()
+		attempts := 0
+		var lastErr error
+		budgetedInvoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			attempts++
+			lastErr = invoker(ctx, method, req, reply, cc, opts...)
+			// pay for the retry before the retry interceptor backs off, so an exhausted budget fails fast
+			retryable := lastErr != nil && slices.Contains(retryCodes, status.Code(lastErr))
+			if retryable && attempts < int(cfg.MaxAttempts) && !budget.withdraw() {
+				return errRetryBudgetExhausted
+			}
+			return lastErr
+		}
+		err := retryInterceptor(ctx, method, req, reply, cc, budgetedInvoker, opts...)
+		if errors.Is(err, errRetryBudgetExhausted) {
+			// report the error of the last attempt made
+			return lastErr
+		}
+		return err
 	}
-	return retry.UnaryClientInterceptor(opts...)
 }
 
 // UnaryCircuitBreakerInterceptor returns a gRPC unary client interceptor that wraps calls in a Circuit Breaker.
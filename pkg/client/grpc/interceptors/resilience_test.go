@@ -2,6 +2,8 @@ package interceptors
 
 import (
 	"context"
+	"fmt"
+	"math/rand/v2"
 	"net"
 	"testing"
 	"time"
@@ -57,19 +59,10 @@ func (s *mockService) getCallCount() int32 {
 func setupTestEnvironment(t *testing.T) (client pb.ProductServiceClient, service *mockService, cleanup func()) {
 	t.Helper()
 
-	lis := bufconn.Listen(1024 * 1024)
-	service = &mockService{}
-
-	grpcServer := grpc.NewServer()
-	pb.RegisterProductServiceServer(grpcServer, service)
-
-	go func() {
-		_ = grpcServer.Serve(lis)
-	}()
-
 	retryCfg := config.RetryConfig{
 		MaxAttempts:    3,
 		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
 	}
 	circuitBreakerCfg := config.CircuitBreakerConfig{
 		ConsecutiveFailures: 5,
@@ -77,15 +70,32 @@ func setupTestEnvironment(t *testing.T) (client pb.ProductServiceClient, service
 		OpenTimeout:         5 * time.Second,
 	}
 
+	return setupTestEnvironmentWithInterceptors(t,
+		NewRetryInterceptor(retryCfg),
+		NewCircuitBreaker(circuitBreakerCfg),
+	)
+}
+
+// setupTestEnvironmentWithInterceptors creates a test gRPC server, a client with the given interceptors, and a cleanup function.
+func setupTestEnvironmentWithInterceptors(t *testing.T, interceptors ...grpc.UnaryClientInterceptor) (client pb.ProductServiceClient, service *mockService, cleanup func()) {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	service = &mockService{}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterProductServiceServer(grpcServer, service)
+
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+
 	conn, err := grpc.NewClient("passthrough://bufnet",
 		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
 			return lis.Dial()
 		}),
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithChainUnaryInterceptor(
-			NewRetryInterceptor(retryCfg),
-			NewCircuitBreaker(circuitBreakerCfg),
-		),
+		grpc.WithChainUnaryInterceptor(interceptors...),
 	)
 	require.NoError(t, err)
 
@@ -204,3 +214,184 @@ func TestInterceptors_CircuitBreakerIgnoresDataError(t *testing.T) {
 	// then
 	require.Equal(t, int32(10), service.getCallCount(), "Server should be called exactly 10 times, circuit breaker should not trigger on data errors")
 }
+
+func TestInterceptors_RetryBudgetExhausted(t *testing.T) {
+	// the circuit breaker is left out, so it doesn't block the calls before the budget is exhausted
+	client, service, cleanup := setupTestEnvironmentWithInterceptors(t, NewRetryInterceptor(config.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		BudgetRatio:    0.1,
+	}))
+	defer cleanup()
+
+	// given
+	// The budget starts with 10 tokens and each failed call spends 2 of them on retries,
+	// while getting back only 0.1, so the first 5 calls are retried and exhaust the budget.
+	responses := make([]codes.Code, 17)
+	for i := range responses {
+		responses[i] = codes.Unavailable
+	}
+	service.setResponses(responses...)
+	for i := 0; i < 5; i++ {
+		_, err := client.GetProduct(context.Background(), &pb.GetProductRequest{})
+		require.Error(t, err)
+	}
+	require.Equal(t, int32(15), service.getCallCount(), "Server should be called 15 times (5 calls * 3 attempts)")
+
+	// when
+	for i := 0; i < 2; i++ {
+		_, err := client.GetProduct(context.Background(), &pb.GetProductRequest{})
+
+		// then: the error of the only attempt is returned
+		require.Error(t, err)
+		st, ok := status.FromError(err)
+		require.True(t, ok)
+		require.Equal(t, codes.Unavailable, st.Code())
+	}
+
+	// then
+	require.Equal(t, int32(17), service.getCallCount(), "Calls should not be retried once the budget is exhausted")
+}
+
+func TestInterceptors_RetryBudgetAllowsFirstAttempt(t *testing.T) {
+	client, service, cleanup := setupTestEnvironmentWithInterceptors(t, NewRetryInterceptor(config.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		BudgetRatio:    0.1,
+	}))
+	defer cleanup()
+
+	// given: the budget is exhausted
+	responses := make([]codes.Code, 15)
+	for i := range responses {
+		responses[i] = codes.Unavailable
+	}
+	service.setResponses(responses...)
+	for i := 0; i < 5; i++ {
+		_, err := client.GetProduct(context.Background(), &pb.GetProductRequest{})
+		require.Error(t, err)
+	}
+	service.setResponses(codes.OK)
+
+	// when
+	_, err := client.GetProduct(context.Background(), &pb.GetProductRequest{})
+
+	// then
+	require.NoError(t, err)
+	require.Equal(t, int32(1), service.getCallCount(), "Server should be called exactly once")
+}
+
+func TestInterceptors_RetryableCodes(t *testing.T) {
+	testCases := []struct {
+		name           string
+		retryableCodes []codes.Code
+		responses      []codes.Code
+		expectedCode   codes.Code
+		expectedCalls  int32
+	}{
+		{
+			name:          "default codes retry Unavailable",
+			responses:     []codes.Code{codes.Unavailable, codes.OK},
+			expectedCode:  codes.OK,
+			expectedCalls: 2,
+		},
+		{
+			name:          "default codes don't retry Internal",
+			responses:     []codes.Code{codes.Internal, codes.OK},
+			expectedCode:  codes.Internal,
+			expectedCalls: 1,
+		},
+		{
+			name:           "configured code is retried",
+			retryableCodes: []codes.Code{codes.Internal},
+			responses:      []codes.Code{codes.Internal, codes.Internal, codes.OK},
+			expectedCode:   codes.OK,
+			expectedCalls:  3,
+		},
+		{
+			name:           "code missing from configured codes is not retried",
+			retryableCodes: []codes.Code{codes.Internal},
+			responses:      []codes.Code{codes.Unavailable, codes.OK},
+			expectedCode:   codes.Unavailable,
+			expectedCalls:  1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, service, cleanup := setupTestEnvironmentWithInterceptors(t, NewRetryInterceptor(config.RetryConfig{
+				MaxAttempts:    3,
+				InitialBackoff: time.Millisecond,
+				RetryableCodes: tc.retryableCodes,
+			}))
+			defer cleanup()
+
+			// given
+			service.setResponses(tc.responses...)
+
+			// when
+			_, err := client.GetProduct(context.Background(), &pb.GetProductRequest{})
+
+			// then
+			require.Equal(t, tc.expectedCode, status.Code(err))
+			require.Equal(t, tc.expectedCalls, service.getCallCount())
+		})
+	}
+}
+
+func TestJitteredBackoff_Bounds(t *testing.T) {
+	const initialBackoff = 100 * time.Millisecond
+	const maxBackoff = time.Second
+	testCases := []struct {
+		attempt  uint
+		expected time.Duration
+	}{
+		{attempt: 1, expected: 100 * time.Millisecond},
+		{attempt: 2, expected: 200 * time.Millisecond},
+		{attempt: 3, expected: 400 * time.Millisecond},
+		{attempt: 4, expected: 800 * time.Millisecond},
+		{attempt: 5, expected: time.Second},
+		{attempt: 6, expected: time.Second},
+	}
+	// given
+	backoff := newJitteredBackoff(initialBackoff, maxBackoff, rand.New(rand.NewPCG(1, 2)))
+	replay := newJitteredBackoff(initialBackoff, maxBackoff, rand.New(rand.NewPCG(1, 2)))
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("attempt %d", tc.attempt), func(t *testing.T) {
+			require.Equal(t, tc.expected, computeBackoff(initialBackoff, maxBackoff, tc.attempt))
+			distinct := make(map[time.Duration]struct{})
+			for i := 0; i < 100; i++ {
+				// when
+				value := backoff(context.Background(), tc.attempt)
+				// then
+				require.GreaterOrEqual(t, value, time.Duration(0))
+				require.LessOrEqual(t, value, tc.expected)
+				require.Equal(t, value, replay(context.Background(), tc.attempt), "the same seed should produce the same backoff")
+				distinct[value] = struct{}{}
+			}
+			require.Greater(t, len(distinct), 1, "backoff should be jittered")
+		})
+	}
+}
+
+func TestInterceptors_RetryBackoffRespectsContext(t *testing.T) {
+	client, service, cleanup := setupTestEnvironmentWithInterceptors(t, NewRetryInterceptor(config.RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     time.Minute,
+	}))
+	defer cleanup()
+
+	// given
+	service.setResponses(codes.Unavailable, codes.Unavailable, codes.Unavailable)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// when
+	start := time.Now()
+	_, err := client.GetProduct(ctx, &pb.GetProductRequest{})
+
+	// then
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second, "backoff should be interrupted by the context")
+	require.LessOrEqual(t, service.getCallCount(), int32(2))
+}
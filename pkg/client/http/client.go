@@ -0,0 +1,90 @@
+// Package http provides the HTTP client of the calls to upstream services, with timeouts,
+// retries of idempotent GET requests and pooled connections.
+package http
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+)
+
+// drainLimit bounds the bytes read from the body of a discarded response, so its connection can be reused.
+const drainLimit = 4 << 10
+
+// NewClient returns an http.Client with the timeout of the configuration, using NewTransport.
+func NewClient(cfg config.HTTPClientConfig) *http.Client {
+	return &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: NewTransport(cfg),
+	}
+}
+
+// NewTransport returns a http.RoundTripper pooling the connections as configured and retrying failed GET requests.
+// A GET request without a body is retried up to cfg.MaxAttempts attempts in total if it failed to get a response,
+// or the upstream responded with 502 or 503. The wait before a retry starts at cfg.RetryBackoff and doubles.
+// A 504 is not retried: the upstream already spent its timeout on the request, so a retry would multiply the latency
+// of the proxies by the attempts. No other request is retried, since it may not be idempotent. The transport has no overall timeout,
+// so it can be used by reverse proxies bounding the requests with their contexts.
+func NewTransport(cfg config.HTTPClientConfig) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	return &retryTransport{
+		next:        transport,
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     cfg.RetryBackoff,
+	}
+}
+
+// retryTransport retries failed GET requests, see NewTransport.
+type retryTransport struct {
+	next        http.RoundTripper
+	maxAttempts uint
+	backoff     time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isRetryable(req) {
+		return t.next.RoundTrip(req)
+	}
+	backoff := t.backoff
+	for attempt := uint(1); ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.maxAttempts || !shouldRetry(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, drainLimit))
+			_ = resp.Body.Close()
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// isRetryable reports whether the request can be sent again: a GET request without a body.
+func isRetryable(req *http.Request) bool {
+	return req.Method == http.MethodGet && (req.Body == nil || req.Body == http.NoBody)
+}
+
+// shouldRetry reports whether the attempt failed with a transient error.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	default:
+		return false
+	}
+}
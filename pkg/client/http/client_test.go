@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFlakyServer returns a server failing the first failures requests with the status and counting all requests.
+func newFlakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestClient_Retry(t *testing.T) {
+	testCases := []struct {
+		name             string
+		method           string
+		failures         int32
+		status           int
+		expectedCode     int
+		expectedRequests int32
+	}{
+		{name: "GET is retried after a failure", method: http.MethodGet, failures: 1, status: http.StatusServiceUnavailable, expectedCode: http.StatusOK, expectedRequests: 2},
+		{name: "GET is retried after a bad gateway", method: http.MethodGet, failures: 1, status: http.StatusBadGateway, expectedCode: http.StatusOK, expectedRequests: 2},
+		{name: "GET succeeding at once is sent once", method: http.MethodGet, failures: 0, expectedCode: http.StatusOK, expectedRequests: 1},
+		{name: "GET stops after the max attempts", method: http.MethodGet, failures: 5, status: http.StatusServiceUnavailable, expectedCode: http.StatusServiceUnavailable, expectedRequests: 3},
+		{name: "GET timing out upstream is not retried", method: http.MethodGet, failures: 1, status: http.StatusGatewayTimeout, expectedCode: http.StatusGatewayTimeout, expectedRequests: 1},
+		{name: "POST is not retried", method: http.MethodPost, failures: 1, status: http.StatusServiceUnavailable, expectedCode: http.StatusServiceUnavailable, expectedRequests: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			server, requests := newFlakyServer(t, tc.failures, tc.status)
+			client := NewClient(config.HTTPClientConfig{Timeout: 5 * time.Second, MaxAttempts: 3, RetryBackoff: time.Millisecond})
+			req, err := http.NewRequestWithContext(context.Background(), tc.method, server.URL, nil)
+			require.NoError(t, err)
+
+			// when
+			resp, err := client.Do(req)
+
+			// then
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+			assert.Equal(t, tc.expectedCode, resp.StatusCode)
+			assert.Equal(t, tc.expectedRequests, requests.Load())
+		})
+	}
+}
+
+func TestClient_RetryStopsOnCancel(t *testing.T) {
+	// given: the backoff is longer than the deadline of the request
+	server, requests := newFlakyServer(t, 1, http.StatusServiceUnavailable)
+	client := NewClient(config.HTTPClientConfig{Timeout: 5 * time.Second, MaxAttempts: 3, RetryBackoff: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	// when
+	_, err = client.Do(req)
+
+	// then
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(1), requests.Load())
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AuditConfig controls publishing of audit events.
+type AuditConfig struct {
+	Enabled bool `koanf:"enabled"`
+}
+
+// String returns a string representation of the audit configuration.
+func (c *AuditConfig) String() string {
+	var b strings.Builder
+	b.WriteString("\n--- Audit ---\n")
+	b.WriteString(fmt.Sprintf("  enabled: %t\n", c.Enabled))
+	return b.String()
+}
@@ -1,11 +1,15 @@
 package configloader
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/joho/godotenv"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/confmap"
@@ -61,7 +65,20 @@ func Load[T Validator](serviceName string) (T, error) {
 	}
 
 	// 4. Unmarshal the configuration into the Config struct
-	if err := k.Unmarshal("", &cfg); err != nil {
+	unmarshalConf := koanf.UnmarshalConf{
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				mapstructure.StringToSliceHookFunc(","),
+				mapstructure.TextUnmarshallerHookFunc(),
+				jsonUnmarshalerHookFunc(),
+			),
+			Result:           &cfg,
+			WeaklyTypedInput: true,
+			TagName:          "koanf",
+		},
+	}
+	if err := k.UnmarshalWithConf("", &cfg, unmarshalConf); err != nil {
 		return cfg, fmt.Errorf("error unmarshalling config: %w", err)
 	}
 
@@ -72,3 +89,25 @@ func Load[T Validator](serviceName string) (T, error) {
 
 	return cfg, nil
 }
+
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// jsonUnmarshalerHookFunc returns a decode hook converting strings into types implementing json.Unmarshaler,
+// e.g. gRPC codes given by name such as UNAVAILABLE. Numeric strings are passed to UnmarshalJSON as numbers.
+func jsonUnmarshalerHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
+		if from.Kind() != reflect.String || !reflect.PointerTo(to).Implements(jsonUnmarshalerType) {
+			return data, nil
+		}
+		str, _ := data.(string)
+		raw := str
+		if _, err := strconv.ParseUint(str, 10, 64); err != nil {
+			raw = strconv.Quote(str)
+		}
+		result := reflect.New(to)
+		if err := result.Interface().(json.Unmarshaler).UnmarshalJSON([]byte(raw)); err != nil {
+			return nil, fmt.Errorf("failed to decode %q into %s: %w", str, to, err)
+		}
+		return result.Elem().Interface(), nil
+	}
+}
@@ -14,6 +14,27 @@ type DatabaseConfig struct {
 	Name     string        `koanf:"name"`
 	SSLMode  string        `koanf:"sslmode"`
 	Timeout  time.Duration `koanf:"timeout"`
+	// QueryTimeout bounds every query or transaction of the stores, zero means the queries are bounded by the request only.
+	QueryTimeout time.Duration `koanf:"queryTimeout"`
+	// SlowQueryThreshold is the duration above which a query of the stores is logged at warn, zero disables the log.
+	SlowQueryThreshold time.Duration `koanf:"slowQueryThreshold"`
+	Pool               PoolConfig    `koanf:"pool"`
+	// AutoMigrate applies the pending migrations before the service starts serving.
+	AutoMigrate bool `koanf:"autoMigrate"`
+	// Migrations is the golang-migrate source URL of the migrations, e.g. file://migrations.
+	Migrations string `koanf:"migrations"`
+}
+
+// PoolConfig holds the connection pool settings. Zero values keep the pgx defaults.
+type PoolConfig struct {
+	// MaxConns is the maximum number of connections in the pool.
+	MaxConns int32 `koanf:"maxConns"`
+	// MinConns is the number of connections the pool keeps open even when they are idle.
+	MinConns int32 `koanf:"minConns"`
+	// MaxConnLifetime is the time after which a connection is closed and replaced.
+	MaxConnLifetime time.Duration `koanf:"maxConnLifetime"`
+	// MaxConnIdleTime is the time after which an idle connection is closed.
+	MaxConnIdleTime time.Duration `koanf:"maxConnIdleTime"`
 }
 
 // URI constructs the PostgreSQL connection URI based on the configuration.
@@ -22,16 +43,25 @@ func (c *DatabaseConfig) URI() string {
 		c.User, c.Password, c.Host, c.Port, c.Name, c.SSLMode)
 }
 
-// String returns a string representation of the database configuration.
+// String returns a string representation of the database configuration. The password is masked.
 func (c *DatabaseConfig) String() string {
 	var b strings.Builder
 	b.WriteString("\n--- Database ---\n")
 	b.WriteString(fmt.Sprintf("  host: %s\n", c.Host))
 	b.WriteString(fmt.Sprintf("  port: %d\n", c.Port))
 	b.WriteString(fmt.Sprintf("  user: %s\n", c.User))
+	b.WriteString(fmt.Sprintf("  password: %s\n", Mask(c.Password)))
 	b.WriteString(fmt.Sprintf("  name: %s\n", c.Name))
 	b.WriteString(fmt.Sprintf("  sslmode: %s\n", c.SSLMode))
 	b.WriteString(fmt.Sprintf("  timeout: %s\n", c.Timeout))
+	b.WriteString(fmt.Sprintf("  queryTimeout: %s\n", c.QueryTimeout))
+	b.WriteString(fmt.Sprintf("  slowQueryThreshold: %s\n", c.SlowQueryThreshold))
+	b.WriteString(fmt.Sprintf("  pool.maxConns: %d\n", c.Pool.MaxConns))
+	b.WriteString(fmt.Sprintf("  pool.minConns: %d\n", c.Pool.MinConns))
+	b.WriteString(fmt.Sprintf("  pool.maxConnLifetime: %s\n", c.Pool.MaxConnLifetime))
+	b.WriteString(fmt.Sprintf("  pool.maxConnIdleTime: %s\n", c.Pool.MaxConnIdleTime))
+	b.WriteString(fmt.Sprintf("  autoMigrate: %t\n", c.AutoMigrate))
+	b.WriteString(fmt.Sprintf("  migrations: %s\n", c.Migrations))
 	return b.String()
 }
 
@@ -57,5 +87,33 @@ func (c *DatabaseConfig) Validate() error {
 	if c.Timeout <= 0 {
 		return fmt.Errorf("database timeout must be greater than 0")
 	}
+	if c.QueryTimeout < 0 {
+		return fmt.Errorf("database query timeout must not be negative")
+	}
+	if c.SlowQueryThreshold < 0 {
+		return fmt.Errorf("database slow query threshold must not be negative")
+	}
+	if c.AutoMigrate && c.Migrations == "" {
+		return fmt.Errorf("database migrations source is required when autoMigrate is enabled")
+	}
+	return c.Pool.Validate()
+}
+
+func (c *PoolConfig) Validate() error {
+	if c.MaxConns < 0 {
+		return fmt.Errorf("database pool maxConns must not be negative")
+	}
+	if c.MinConns < 0 {
+		return fmt.Errorf("database pool minConns must not be negative")
+	}
+	if c.MaxConns > 0 && c.MinConns > c.MaxConns {
+		return fmt.Errorf("database pool minConns (%d) must not be greater than maxConns (%d)", c.MinConns, c.MaxConns)
+	}
+	if c.MaxConnLifetime < 0 {
+		return fmt.Errorf("database pool maxConnLifetime must not be negative")
+	}
+	if c.MaxConnIdleTime < 0 {
+		return fmt.Errorf("database pool maxConnIdleTime must not be negative")
+	}
 	return nil
 }
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PoolConfig
+		wantErr bool
+	}{
+		{name: "unset", cfg: PoolConfig{}},
+		{name: "min conns equal to max conns", cfg: PoolConfig{MaxConns: 10, MinConns: 10}},
+		{name: "min conns without max conns", cfg: PoolConfig{MinConns: 2}},
+		{name: "min conns above max conns", cfg: PoolConfig{MaxConns: 5, MinConns: 10}, wantErr: true},
+		{name: "negative max conns", cfg: PoolConfig{MaxConns: -1}, wantErr: true},
+		{name: "negative min conns", cfg: PoolConfig{MinConns: -1}, wantErr: true},
+		{name: "negative max conn lifetime", cfg: PoolConfig{MaxConnLifetime: -time.Second}, wantErr: true},
+		{name: "negative max conn idle time", cfg: PoolConfig{MaxConnIdleTime: -time.Second}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			err := tt.cfg.Validate()
+			// then
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDatabaseConfig_Validate_AutoMigrate(t *testing.T) {
+	tests := []struct {
+		name        string
+		autoMigrate bool
+		migrations  string
+		wantErr     bool
+	}{
+		{name: "disabled", autoMigrate: false},
+		{name: "enabled with migrations", autoMigrate: true, migrations: "file://migrations"},
+		{name: "enabled without migrations", autoMigrate: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			cfg := DatabaseConfig{
+				Host:        "localhost",
+				Port:        5432,
+				User:        "user",
+				Password:    "password",
+				Name:        "db",
+				SSLMode:     "disable",
+				Timeout:     time.Second,
+				AutoMigrate: tt.autoMigrate,
+				Migrations:  tt.migrations,
+			}
+			// when
+			err := cfg.Validate()
+			// then
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
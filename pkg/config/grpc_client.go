@@ -9,6 +9,7 @@ import (
 type GrpcClientConfig struct {
 	Addr    string        `koanf:"addr"`
 	Timeout time.Duration `koanf:"timeout"`
+	TLS     GrpcTLSConfig `koanf:"tls"`
 }
 
 // String returns a string representation of the gRPC client configuration.
@@ -17,6 +18,7 @@ func (c *GrpcClientConfig) String() string {
 	b.WriteString("\n--- gRPC Client ---\n")
 	b.WriteString(fmt.Sprintf("  addr: %s\n", c.Addr))
 	b.WriteString(fmt.Sprintf("  timeout: %s\n", c.Timeout))
+	b.WriteString(c.TLS.String())
 	return b.String()
 }
 
@@ -27,5 +29,5 @@ func (c *GrpcClientConfig) Validate() error {
 	if c.Timeout <= 0 {
 		return fmt.Errorf("gRPC timeout is not configured")
 	}
-	return nil
+	return c.TLS.validate(false)
 }
@@ -3,11 +3,34 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
+// EnvProduction is the environment of production deployments, which enforce the production guards,
+// e.g. see GrpcServerConfig.ValidateEnv.
+const EnvProduction = "production"
+
+// NormalizeEnv returns the environment in lower case without surrounding spaces, e.g. "production" for " Production ".
+// An empty environment and "prod" are EnvProduction, so a deployment only skips the production guards
+// if it's configured for another environment explicitly.
+func NormalizeEnv(env string) string {
+	env = strings.ToLower(strings.TrimSpace(env))
+	if env == "" || env == "prod" {
+		return EnvProduction
+	}
+	return env
+}
+
 type GrpcServerConfig struct {
 	Port              string `koanf:"port"`
 	ReflectionEnabled bool   `koanf:"reflection"`
+	// MaxConnectionAge is the maximum age of a client connection, after which the server gracefully closes it,
+	// so clients reconnect and get spread over the available instances. Zero disables the limit.
+	MaxConnectionAge time.Duration `koanf:"maxConnectionAge"`
+	// MaxConnectionAgeGrace is the time given to pending RPCs to complete after MaxConnectionAge,
+	// before the connection is forcibly closed. Zero waits for the pending RPCs indefinitely.
+	MaxConnectionAgeGrace time.Duration `koanf:"maxConnectionAgeGrace"`
+	TLS                   GrpcTLSConfig `koanf:"tls"`
 }
 
 // String returns a string representation of the gRPC server configuration.
@@ -16,6 +39,9 @@ func (c *GrpcServerConfig) String() string {
 	b.WriteString("\n--- gRPC Server ---\n")
 	b.WriteString(fmt.Sprintf("  port: %s\n", c.Port))
 	b.WriteString(fmt.Sprintf("  reflection_enabled: %t\n", c.ReflectionEnabled))
+	b.WriteString(fmt.Sprintf("  maxConnectionAge: %s\n", c.MaxConnectionAge))
+	b.WriteString(fmt.Sprintf("  maxConnectionAgeGrace: %s\n", c.MaxConnectionAgeGrace))
+	b.WriteString(c.TLS.String())
 	return b.String()
 }
 
@@ -23,5 +49,23 @@ func (c *GrpcServerConfig) Validate() error {
 	if c.Port == "" {
 		return fmt.Errorf("gRPC port is not configured")
 	}
+	if c.MaxConnectionAge < 0 {
+		return fmt.Errorf("invalid gRPC max connection age: %v", c.MaxConnectionAge)
+	}
+	if c.MaxConnectionAgeGrace < 0 {
+		return fmt.Errorf("invalid gRPC max connection age grace: %v", c.MaxConnectionAgeGrace)
+	}
+	if c.MaxConnectionAgeGrace > 0 && c.MaxConnectionAge == 0 {
+		return fmt.Errorf("gRPC max connection age grace requires max connection age")
+	}
+	return c.TLS.validate(true)
+}
+
+// ValidateEnv rejects the reflection in the production environment, since it lets any client list the services.
+// The environment is normalized with NormalizeEnv.
+func (c *GrpcServerConfig) ValidateEnv(env string) error {
+	if c.ReflectionEnabled && NormalizeEnv(env) == EnvProduction {
+		return fmt.Errorf("gRPC reflection must not be enabled in %s", EnvProduction)
+	}
 	return nil
 }
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEnv(t *testing.T) {
+	tests := []struct {
+		env      string
+		expected string
+	}{
+		{env: "production", expected: EnvProduction},
+		{env: " Production\n", expected: EnvProduction},
+		{env: "prod", expected: EnvProduction},
+		{env: "", expected: EnvProduction},
+		{env: "Development", expected: "development"},
+		{env: "staging", expected: "staging"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NormalizeEnv(tt.env))
+		})
+	}
+}
+
+func TestGrpcServerConfig_ValidateEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		reflection bool
+		env        string
+		wantErr    bool
+	}{
+		{name: "reflection in production", reflection: true, env: EnvProduction, wantErr: true},
+		{name: "no reflection in production", reflection: false, env: EnvProduction},
+		{name: "reflection in production in other case", reflection: true, env: " Production ", wantErr: true},
+		{name: "reflection in prod", reflection: true, env: "PROD", wantErr: true},
+		{name: "reflection in development", reflection: true, env: "development"},
+		{name: "reflection without an environment", reflection: true, env: "", wantErr: true},
+		{name: "no reflection without an environment", reflection: false, env: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			cfg := GrpcServerConfig{Port: "50051", ReflectionEnabled: tt.reflection}
+			// when
+			err := cfg.ValidateEnv(tt.env)
+			// then
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
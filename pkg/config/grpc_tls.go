@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GrpcTLSConfig holds the TLS settings of a gRPC server or client connection.
+// TLS is enabled unless Insecure is set explicitly, plaintext is only acceptable within a trusted network.
+type GrpcTLSConfig struct {
+	Insecure bool `koanf:"insecure"`
+	// CertFile and KeyFile are the PEM encoded certificate and key the server or client presents to its peer.
+	// They are required by the server, a client presenting them authenticates with mutual TLS.
+	CertFile string `koanf:"certFile"`
+	KeyFile  string `koanf:"keyFile"`
+	// CAFile is the PEM encoded CA certificate verifying the peer. The server requires and verifies client certificates
+	// signed by it, enabling mutual TLS. The client verifies the server certificate with it, or with the system roots if empty.
+	CAFile string `koanf:"caFile"`
+	// ServerName overrides the name the client verifies the server certificate against, defaults to the host of the address.
+	ServerName string `koanf:"serverName"`
+}
+
+// String returns a string representation of the gRPC TLS configuration.
+func (c *GrpcTLSConfig) String() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("  tls.insecure: %t\n", c.Insecure))
+	b.WriteString(fmt.Sprintf("  tls.certFile: %s\n", c.CertFile))
+	b.WriteString(fmt.Sprintf("  tls.keyFile: %s\n", c.KeyFile))
+	b.WriteString(fmt.Sprintf("  tls.caFile: %s\n", c.CAFile))
+	b.WriteString(fmt.Sprintf("  tls.serverName: %s\n", c.ServerName))
+	return b.String()
+}
+
+// validate checks the TLS configuration, the certificate and key are required if requireCert is set.
+func (c *GrpcTLSConfig) validate(requireCert bool) error {
+	if c.Insecure {
+		return nil
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("gRPC TLS certFile and keyFile must be configured together")
+	}
+	if requireCert && c.CertFile == "" {
+		return fmt.Errorf("gRPC TLS certFile and keyFile are not configured, set tls.insecure to disable TLS")
+	}
+	return nil
+}
@@ -2,18 +2,57 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"strings"
 	"time"
 )
 
+// Trailing slash handling modes of the HTTP router.
+const (
+	// TrailingSlashStrip routes /products/ like /products.
+	TrailingSlashStrip = "strip"
+	// TrailingSlashRedirect redirects /products/ to /products with 301 Moved Permanently.
+	TrailingSlashRedirect = "redirect"
+	// TrailingSlashOff routes the paths as they are, so /products/ may not be found.
+	TrailingSlashOff = "off"
+)
+
+// Handling modes of a list endpoint limit above the max page size.
+const (
+	// PageSizeClamp serves the page of the max page size.
+	PageSizeClamp = "clamp"
+	// PageSizeReject responds with 400 Bad Request.
+	PageSizeReject = "reject"
+)
+
+// defaultMaxPageSize bounds the result sets of the list endpoints.
+const defaultMaxPageSize = 100
+
+// defaultMaxBodyBytes bounds the request bodies, 1 MiB.
+const defaultMaxBodyBytes = 1 << 20
+
+// DefaultBasePath is the path prefix of the API routes, i.e. the API version.
+const DefaultBasePath = "/api/v1"
+
 type HTTPConfig struct {
-	Port           int `koanf:"port"`
-	MaxHeaderBytes int `koanf:"maxHeaderBytes"`
-	Timeout        struct {
+	Port           int    `koanf:"port"`
+	MaxHeaderBytes int    `koanf:"maxHeaderBytes"`
+	TrailingSlash  string `koanf:"trailingSlash"`
+	// MaxPageSize limits the limit query parameter of the list endpoints, see PageSizeMode.
+	MaxPageSize  int    `koanf:"maxPageSize"`
+	PageSizeMode string `koanf:"pageSizeMode"`
+	// MaxBodyBytes limits the size of the request bodies, larger bodies are rejected with 413.
+	MaxBodyBytes int64 `koanf:"maxBodyBytes"`
+	// AllowedHosts limits the Host headers of the requests, others are rejected with 400. Empty allows any host.
+	AllowedHosts []string `koanf:"allowedHosts"`
+	// BasePath prefixes the API routes, e.g. /api/v1/products, so another API version can be mounted side by side.
+	BasePath string `koanf:"basePath"`
+	Timeout  struct {
 		Read       time.Duration `koanf:"read"`
 		Write      time.Duration `koanf:"write"`
 		Idle       time.Duration `koanf:"idle"`
 		ReadHeader time.Duration `koanf:"readHeader"`
+		Handler    time.Duration `koanf:"handler"`
 	} `koanf:"timeout"`
 }
 
@@ -23,10 +62,17 @@ func (c *HTTPConfig) String() string {
 	b.WriteString("\n--- HTTP Server ---\n")
 	b.WriteString(fmt.Sprintf("  port: %d\n", c.Port))
 	b.WriteString(fmt.Sprintf("  maxHeaderBytes: %d\n", c.MaxHeaderBytes))
+	b.WriteString(fmt.Sprintf("  trailingSlash: %s\n", c.TrailingSlash))
+	b.WriteString(fmt.Sprintf("  maxPageSize: %d\n", c.MaxPageSize))
+	b.WriteString(fmt.Sprintf("  pageSizeMode: %s\n", c.PageSizeMode))
+	b.WriteString(fmt.Sprintf("  maxBodyBytes: %d\n", c.MaxBodyBytes))
+	b.WriteString(fmt.Sprintf("  allowedHosts: %s\n", strings.Join(c.AllowedHosts, ",")))
+	b.WriteString(fmt.Sprintf("  basePath: %s\n", c.BasePath))
 	b.WriteString(fmt.Sprintf("  timeout.read: %s\n", c.Timeout.Read))
 	b.WriteString(fmt.Sprintf("  timeout.write: %s\n", c.Timeout.Write))
 	b.WriteString(fmt.Sprintf("  timeout.idle: %s\n", c.Timeout.Idle))
 	b.WriteString(fmt.Sprintf("  timeout.readHeader: %s\n", c.Timeout.ReadHeader))
+	b.WriteString(fmt.Sprintf("  timeout.handler: %s\n", c.Timeout.Handler))
 	return b.String()
 }
 
@@ -34,6 +80,47 @@ func (c *HTTPConfig) Validate() error {
 	if c.Port <= 0 || c.Port > 65535 {
 		return fmt.Errorf("invalid HTTP server port: %d", c.Port)
 	}
+	if c.TrailingSlash == "" {
+		log.Println("Using default value for trailingSlash")
+		c.TrailingSlash = TrailingSlashStrip
+	}
+	if c.TrailingSlash != TrailingSlashStrip && c.TrailingSlash != TrailingSlashRedirect && c.TrailingSlash != TrailingSlashOff {
+		return fmt.Errorf("invalid HTTP server trailing slash mode: %q", c.TrailingSlash)
+	}
+	if c.MaxPageSize < 0 {
+		return fmt.Errorf("invalid HTTP server max page size: %d", c.MaxPageSize)
+	}
+	if c.MaxPageSize == 0 {
+		log.Println("Using default value for maxPageSize")
+		c.MaxPageSize = defaultMaxPageSize
+	}
+	if c.PageSizeMode == "" {
+		log.Println("Using default value for pageSizeMode")
+		c.PageSizeMode = PageSizeClamp
+	}
+	if c.PageSizeMode != PageSizeClamp && c.PageSizeMode != PageSizeReject {
+		return fmt.Errorf("invalid HTTP server page size mode: %q", c.PageSizeMode)
+	}
+	if c.MaxBodyBytes < 0 {
+		return fmt.Errorf("invalid HTTP server max body bytes: %d", c.MaxBodyBytes)
+	}
+	if c.MaxBodyBytes == 0 {
+		log.Println("Using default value for maxBodyBytes")
+		c.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	for i, host := range c.AllowedHosts {
+		c.AllowedHosts[i] = strings.TrimSpace(host)
+		if c.AllowedHosts[i] == "" || strings.ContainsAny(c.AllowedHosts[i], "/ ") {
+			return fmt.Errorf("invalid HTTP server allowed host: %q", host)
+		}
+	}
+	if c.BasePath == "" {
+		log.Println("Using default value for basePath")
+		c.BasePath = DefaultBasePath
+	}
+	if !strings.HasPrefix(c.BasePath, "/") || strings.HasSuffix(c.BasePath, "/") || strings.ContainsAny(c.BasePath, " {}") {
+		return fmt.Errorf("invalid HTTP server base path: %q", c.BasePath)
+	}
 	if c.Timeout.Read <= 0 {
 		return fmt.Errorf("invalid HTTP server read timeout: %v", c.Timeout.Read)
 	}
@@ -46,5 +133,12 @@ func (c *HTTPConfig) Validate() error {
 	if c.Timeout.ReadHeader <= 0 {
 		return fmt.Errorf("invalid HTTP server read header timeout: %v", c.Timeout.ReadHeader)
 	}
+	// handler timeout is optional, zero disables the per-request deadline
+	if c.Timeout.Handler < 0 {
+		return fmt.Errorf("invalid HTTP server handler timeout: %v", c.Timeout.Handler)
+	}
+	if c.Timeout.Handler >= c.Timeout.Write {
+		return fmt.Errorf("HTTP server handler timeout %v must be less than write timeout %v", c.Timeout.Handler, c.Timeout.Write)
+	}
 	return nil
 }
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const defaultHTTPClientTimeout = 2 * time.Second
+const defaultHTTPClientMaxAttempts = 2
+const defaultHTTPClientRetryBackoff = 100 * time.Millisecond
+const defaultHTTPClientMaxIdleConns = 100
+const defaultHTTPClientMaxIdleConnsPerHost = 10
+const defaultHTTPClientIdleConnTimeout = 90 * time.Second
+
+// HTTPClientConfig holds the settings of an HTTP client calling upstream services.
+type HTTPClientConfig struct {
+	// Timeout limits a request made by the client, including its retries.
+	Timeout time.Duration `koanf:"timeout"`
+	// MaxAttempts is the number of attempts of an idempotent GET request, 1 disables retries.
+	MaxAttempts uint `koanf:"maxattempts"`
+	// RetryBackoff is the wait before the first retry, doubled for every further retry.
+	RetryBackoff time.Duration `koanf:"retrybackoff"`
+	// MaxIdleConns limits the idle connections kept open across all hosts.
+	MaxIdleConns int `koanf:"maxidleconns"`
+	// MaxIdleConnsPerHost limits the idle connections kept open to a single host.
+	MaxIdleConnsPerHost int `koanf:"maxidleconnsperhost"`
+	// IdleConnTimeout is how long an idle connection is kept open.
+	IdleConnTimeout time.Duration `koanf:"idleconntimeout"`
+}
+
+// String returns a string representation of the HTTP client configuration.
+func (c *HTTPClientConfig) String() string {
+	var b strings.Builder
+	b.WriteString("\n--- HTTP Client ---\n")
+	b.WriteString(fmt.Sprintf("  timeout: %s\n", c.Timeout))
+	b.WriteString(fmt.Sprintf("  maxattempts: %d\n", c.MaxAttempts))
+	b.WriteString(fmt.Sprintf("  retrybackoff: %s\n", c.RetryBackoff))
+	b.WriteString(fmt.Sprintf("  maxidleconns: %d\n", c.MaxIdleConns))
+	b.WriteString(fmt.Sprintf("  maxidleconnsperhost: %d\n", c.MaxIdleConnsPerHost))
+	b.WriteString(fmt.Sprintf("  idleconntimeout: %s\n", c.IdleConnTimeout))
+	return b.String()
+}
+
+func (c *HTTPClientConfig) Validate() error {
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid HTTP client timeout: %v", c.Timeout)
+	}
+	if c.Timeout == 0 {
+		log.Println("Using default value for HTTP client timeout")
+		c.Timeout = defaultHTTPClientTimeout
+	}
+	if c.MaxAttempts == 0 {
+		log.Println("Using default value for HTTP client maxattempts")
+		c.MaxAttempts = defaultHTTPClientMaxAttempts
+	}
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("invalid HTTP client retry backoff: %v", c.RetryBackoff)
+	}
+	if c.RetryBackoff == 0 {
+		log.Println("Using default value for HTTP client retrybackoff")
+		c.RetryBackoff = defaultHTTPClientRetryBackoff
+	}
+	if c.MaxIdleConns < 0 {
+		return fmt.Errorf("invalid HTTP client max idle connections: %d", c.MaxIdleConns)
+	}
+	if c.MaxIdleConns == 0 {
+		log.Println("Using default value for HTTP client maxidleconns")
+		c.MaxIdleConns = defaultHTTPClientMaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("invalid HTTP client max idle connections per host: %d", c.MaxIdleConnsPerHost)
+	}
+	if c.MaxIdleConnsPerHost == 0 {
+		log.Println("Using default value for HTTP client maxidleconnsperhost")
+		c.MaxIdleConnsPerHost = defaultHTTPClientMaxIdleConnsPerHost
+	}
+	if c.IdleConnTimeout < 0 {
+		return fmt.Errorf("invalid HTTP client idle connection timeout: %v", c.IdleConnTimeout)
+	}
+	if c.IdleConnTimeout == 0 {
+		log.Println("Using default value for HTTP client idleconntimeout")
+		c.IdleConnTimeout = defaultHTTPClientIdleConnTimeout
+	}
+	return nil
+}
@@ -2,15 +2,39 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"strings"
 	"time"
 )
 
+// defaultRefreshInterval is the default interval of the scheduled JWKS refreshes.
+const defaultRefreshInterval = 15 * time.Minute
+
+// DefaultFetchTimeout is the default timeout of a JWKS fetch.
+const DefaultFetchTimeout = 5 * time.Second
+
 type IdP struct {
-	JwksURL     string        `koanf:"jwksurl"`
-	Issuer      string        `koanf:"issuer"`
-	ClientID    string        `koanf:"clientid"`
+	JwksURL  string `koanf:"jwksurl"`
+	Issuer   string `koanf:"issuer"`
+	ClientID string `koanf:"clientid"`
+	// ExpectedIssuer is the `iss` claim tokens must have, it defaults to Issuer.
+	ExpectedIssuer string `koanf:"expectedissuer"`
+	// ExpectedAudience is the value the `aud` claim of tokens must contain. The audience isn't checked if it's empty.
+	ExpectedAudience string `koanf:"expectedaudience"`
+	// MinInterval is the minimum interval between two JWKS fetches triggered by tokens with unknown key IDs.
 	MinInterval time.Duration `koanf:"mininterval"`
+	// RefreshInterval is the interval of the scheduled JWKS refreshes.
+	RefreshInterval time.Duration `koanf:"refreshinterval"`
+	// FetchTimeout bounds a JWKS fetch, so an unresponsive identity provider fails the fetch quickly.
+	FetchTimeout time.Duration `koanf:"fetchtimeout"`
+}
+
+// JwksFetchTimeout returns the timeout of a JWKS fetch, DefaultFetchTimeout if it isn't set.
+func (c *IdP) JwksFetchTimeout() time.Duration {
+	if c.FetchTimeout <= 0 {
+		return DefaultFetchTimeout
+	}
+	return c.FetchTimeout
 }
 
 // String returns a string representation of the IdP configuration.
@@ -20,7 +44,11 @@ func (c *IdP) String() string {
 	b.WriteString(fmt.Sprintf("  jwksurl: %s\n", c.JwksURL))
 	b.WriteString(fmt.Sprintf("  issuer: %s\n", c.Issuer))
 	b.WriteString(fmt.Sprintf("  clientid: %s\n", c.ClientID))
+	b.WriteString(fmt.Sprintf("  expectedissuer: %s\n", c.ExpectedIssuer))
+	b.WriteString(fmt.Sprintf("  expectedaudience: %s\n", c.ExpectedAudience))
 	b.WriteString(fmt.Sprintf("  mininterval: %v\n", c.MinInterval))
+	b.WriteString(fmt.Sprintf("  refreshinterval: %v\n", c.RefreshInterval))
+	b.WriteString(fmt.Sprintf("  fetchtimeout: %v\n", c.JwksFetchTimeout()))
 	return b.String()
 }
 
@@ -34,8 +62,22 @@ func (c *IdP) Validate() error {
 	if c.ClientID == "" {
 		return fmt.Errorf("IdP client ID cannot be empty")
 	}
+	if c.ExpectedIssuer == "" {
+		log.Println("Using default value for IdP expected issuer")
+		c.ExpectedIssuer = c.Issuer
+	}
 	if c.MinInterval <= 0 {
 		return fmt.Errorf("IdP minimum interval must be greater than zero")
 	}
+	if c.RefreshInterval <= 0 {
+		log.Println("Using default value for IdP refresh interval")
+		c.RefreshInterval = defaultRefreshInterval
+	}
+	if c.FetchTimeout < 0 {
+		return fmt.Errorf("IdP fetch timeout must not be negative")
+	}
+	if c.RefreshInterval < c.MinInterval {
+		return fmt.Errorf("IdP refresh interval must not be shorter than the minimum interval")
+	}
 	return nil
 }
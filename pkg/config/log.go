@@ -2,11 +2,23 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"strings"
 )
 
+// Output formats of the logs.
+const (
+	// LogFormatJSON writes a JSON object per record, for log collectors.
+	LogFormatJSON = "json"
+	// LogFormatText writes human-readable key=value lines, for local development.
+	LogFormatText = "text"
+)
+
 type LogConfig struct {
-	Level string `koanf:"level"`
+	Level  string `koanf:"level"`
+	Format string `koanf:"format"`
+	// AddSource adds the source file and line of the log statement to every record.
+	AddSource bool `koanf:"addSource"`
 }
 
 // String returns a string representation of the log configuration.
@@ -14,9 +26,18 @@ func (c *LogConfig) String() string {
 	var b strings.Builder
 	b.WriteString("\n--- Log ---\n")
 	b.WriteString(fmt.Sprintf("  level: %s\n", c.Level))
+	b.WriteString(fmt.Sprintf("  format: %s\n", c.Format))
+	b.WriteString(fmt.Sprintf("  addSource: %t\n", c.AddSource))
 	return b.String()
 }
 
 func (c *LogConfig) Validate() error {
+	if c.Format == "" {
+		log.Println("Using default value for log format")
+		c.Format = LogFormatJSON
+	}
+	if c.Format != LogFormatJSON && c.Format != LogFormatText {
+		return fmt.Errorf("invalid log format: %q", c.Format)
+	}
 	return nil
 }
@@ -9,14 +9,26 @@ import (
 type NATSConfig struct {
 	Url     string        `koanf:"url"`
 	Timeout time.Duration `koanf:"timeout"`
+	// MaxReconnects is the number of attempts to reconnect a lost connection, -1 reconnects forever.
+	// Zero uses the default of the NATS client, 60 attempts.
+	MaxReconnects int `koanf:"maxreconnects"`
+	// ReconnectWait is the time between the attempts to reconnect, and between the attempts of the initial connect.
+	// Zero uses the default of the NATS client, 2s.
+	ReconnectWait time.Duration `koanf:"reconnectwait"`
+	// ConnectRetries is the number of times the initial connect is retried, e.g. while NATS isn't up yet at startup.
+	// Zero fails on the first failed attempt.
+	ConnectRetries int `koanf:"connectretries"`
 }
 
-// String returns a string representation of the NATS configuration.
+// String returns a string representation of the NATS configuration. The password in the URL is masked.
 func (c *NATSConfig) String() string {
 	var b strings.Builder
 	b.WriteString("\n--- NATS ---\n")
-	b.WriteString(fmt.Sprintf("  url: %s\n", c.Url))
+	b.WriteString(fmt.Sprintf("  url: %s\n", MaskURL(c.Url)))
 	b.WriteString(fmt.Sprintf("  timeout: %s\n", c.Timeout))
+	b.WriteString(fmt.Sprintf("  maxreconnects: %d\n", c.MaxReconnects))
+	b.WriteString(fmt.Sprintf("  reconnectwait: %s\n", c.ReconnectWait))
+	b.WriteString(fmt.Sprintf("  connectretries: %d\n", c.ConnectRetries))
 	return b.String()
 }
 
@@ -27,5 +39,14 @@ func (c *NATSConfig) Validate() error {
 	if c.Timeout <= 0 {
 		return fmt.Errorf("nats dial timeout is not configured")
 	}
+	if c.MaxReconnects < -1 {
+		return fmt.Errorf("nats max reconnects must be -1 (forever) or more, got %d", c.MaxReconnects)
+	}
+	if c.ReconnectWait < 0 {
+		return fmt.Errorf("nats reconnect wait cannot be negative")
+	}
+	if c.ConnectRetries < 0 {
+		return fmt.Errorf("nats connect retries cannot be negative")
+	}
 	return nil
 }
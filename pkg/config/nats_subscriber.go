@@ -2,18 +2,43 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"strings"
 	"time"
 )
 
+// defaultDeadLetterPrefix matches the subjects of the DLQ stream of the NATS stream setup job.
+const defaultDeadLetterPrefix = "dlq"
+
+// DefaultStreamRetryInterval is the time between the checks of a missing stream if SubscriberConfig.RetryInterval is not set.
+const DefaultStreamRetryInterval = 2 * time.Second
+
 type SubscriberConfig struct {
 	Stream   string        `koanf:"stream"`
 	Subject  string        `koanf:"subject"`
+	Subjects []string      `koanf:"subjects"`
 	Consumer string        `koanf:"consumer"`
 	Batch    int           `koanf:"batch"`
 	Timeout  time.Duration `koanf:"timeout"`
 	Interval time.Duration `koanf:"interval"`
 	Workers  int           `koanf:"workers"`
+	// MaxInFlight limits the messages handled at once across all workers, the workers stop fetching while it's reached.
+	// Defaults to the number of workers.
+	MaxInFlight int `koanf:"maxinflight"`
+	// DeadLetterPrefix prefixes the subject of the messages which can't be processed,
+	// e.g. dlq.orders.created, to publish them to the dead letter queue.
+	DeadLetterPrefix string `koanf:"deadletterprefix"`
+	// RetryInterval is the time between the checks of the stream while it doesn't exist at startup,
+	// e.g. before the publishing service created it. Defaults to DefaultStreamRetryInterval.
+	RetryInterval time.Duration `koanf:"retryinterval"`
+}
+
+// StreamRetryInterval returns the time between the checks of a missing stream, or DefaultStreamRetryInterval if it's not set.
+func (c *SubscriberConfig) StreamRetryInterval() time.Duration {
+	if c.RetryInterval <= 0 {
+		return DefaultStreamRetryInterval
+	}
+	return c.RetryInterval
 }
 
 // String returns a string representation of the NATS Subscriber configuration.
@@ -22,11 +47,15 @@ func (c *SubscriberConfig) String() string {
 	b.WriteString("\n--- NATS Subscriber ---\n")
 	b.WriteString(fmt.Sprintf("  stream: %s\n", c.Stream))
 	b.WriteString(fmt.Sprintf("  subject: %s\n", c.Subject))
+	b.WriteString(fmt.Sprintf("  subjects: %v\n", c.Subjects))
 	b.WriteString(fmt.Sprintf("  consumer: %s\n", c.Consumer))
 	b.WriteString(fmt.Sprintf("  batch: %d\n", c.Batch))
 	b.WriteString(fmt.Sprintf("  timeout: %s\n", c.Timeout))
 	b.WriteString(fmt.Sprintf("  interval: %s\n", c.Interval))
 	b.WriteString(fmt.Sprintf("  workers: %d\n", c.Workers))
+	b.WriteString(fmt.Sprintf("  maxInFlight: %d\n", c.MaxInFlight))
+	b.WriteString(fmt.Sprintf("  deadLetterPrefix: %s\n", c.DeadLetterPrefix))
+	b.WriteString(fmt.Sprintf("  retryInterval: %s\n", c.StreamRetryInterval()))
 	return b.String()
 }
 
@@ -34,8 +63,13 @@ func (c *SubscriberConfig) Validate() error {
 	if c.Stream == "" {
 		return fmt.Errorf("SubscriberConfig: Stream is not configured")
 	}
-	if c.Subject == "" {
-		return fmt.Errorf("SubscriberConfig: Subject is not configured")
+	if c.Subject == "" && len(c.Subjects) == 0 {
+		return fmt.Errorf("SubscriberConfig: Subject or Subjects must be configured")
+	}
+	for _, subject := range c.Subjects {
+		if subject == "" {
+			return fmt.Errorf("SubscriberConfig: Subjects must not contain empty values")
+		}
 	}
 	if c.Consumer == "" {
 		return fmt.Errorf("SubscriberConfig: consumer is not configured")
@@ -52,5 +86,28 @@ func (c *SubscriberConfig) Validate() error {
 	if c.Workers <= 0 {
 		return fmt.Errorf("SubscriberConfig: workers must be greater than zero")
 	}
+	if c.RetryInterval < 0 {
+		return fmt.Errorf("SubscriberConfig: retryinterval must not be negative")
+	}
+	if c.MaxInFlight < 0 {
+		return fmt.Errorf("SubscriberConfig: maxinflight must not be negative")
+	}
+	if c.MaxInFlight == 0 {
+		log.Println("Using default value for maxinflight")
+		c.MaxInFlight = c.Workers
+	}
+	if c.DeadLetterPrefix == "" {
+		log.Println("Using default value for deadLetterPrefix")
+		c.DeadLetterPrefix = defaultDeadLetterPrefix
+	}
 	return nil
 }
+
+// FilterSubjects returns the subjects the consumer is subscribed to.
+// Subjects takes precedence over the single Subject, which is kept for backward compatibility.
+func (c *SubscriberConfig) FilterSubjects() []string {
+	if len(c.Subjects) > 0 {
+		return c.Subjects
+	}
+	return []string{c.Subject}
+}
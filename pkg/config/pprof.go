@@ -8,6 +8,8 @@ import (
 type PProfConfig struct {
 	Enabled bool   `koanf:"enabled"`
 	Addr    string `koanf:"addr"`
+	// AuthToken protects the profiles, requests without it are rejected with 401. Empty leaves them unprotected.
+	AuthToken string `koanf:"authtoken"`
 }
 
 // String returns a string representation of the pprof configuration.
@@ -16,6 +18,7 @@ func (c *PProfConfig) String() string {
 	b.WriteString("\n--- PProf ---\n")
 	b.WriteString(fmt.Sprintf("  enabled: %t\n", c.Enabled))
 	b.WriteString(fmt.Sprintf("  address: %s\n", c.Addr))
+	b.WriteString(fmt.Sprintf("  authtoken: %s\n", Mask(c.AuthToken)))
 	return b.String()
 }
 
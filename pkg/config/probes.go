@@ -7,12 +7,23 @@ import (
 	"time"
 )
 
+// ProbeModeFile reports liveness and readiness by writing files, checked by exec probes.
+const ProbeModeFile = "file"
+
+// ProbeModeHTTP reports liveness and readiness on the /livez and /readyz endpoints,
+// so the service doesn't need a writable filesystem.
+const ProbeModeHTTP = "http"
+
 type ProbesConfig struct {
+	Mode              string        `koanf:"mode"`
+	Addr              string        `koanf:"addr"`
 	ReadinessFileName string        `koanf:"readinessfilename"`
 	LivenessFileName  string        `koanf:"livenessfilename"`
 	LivenessInterval  time.Duration `koanf:"livenessinterval"`
 }
 
+const defaultProbeMode = ProbeModeFile
+const defaultProbeAddr = ":8080"
 const defaultReadinessFileName = "/tmp/ready"
 const defaultLivenessFileName = "/tmp/live"
 const defaultLivenessInterval = 20 * time.Second
@@ -21,6 +32,8 @@ const defaultLivenessInterval = 20 * time.Second
 func (c *ProbesConfig) String() string {
 	var b strings.Builder
 	b.WriteString("\n--- Probes ---\n")
+	b.WriteString(fmt.Sprintf("  mode: %s\n", c.Mode))
+	b.WriteString(fmt.Sprintf("  addr: %s\n", c.Addr))
 	b.WriteString(fmt.Sprintf("  readinessfilename: %s\n", c.ReadinessFileName))
 	b.WriteString(fmt.Sprintf("  livenessfilename: %s\n", c.LivenessFileName))
 	b.WriteString(fmt.Sprintf("  livenessinterval: %s\n", c.LivenessInterval))
@@ -28,6 +41,17 @@ func (c *ProbesConfig) String() string {
 }
 
 func (c *ProbesConfig) Validate() error {
+	if c.Mode == "" {
+		log.Println("Using default value for probes mode")
+		c.Mode = defaultProbeMode
+	}
+	if c.Mode != ProbeModeFile && c.Mode != ProbeModeHTTP {
+		return fmt.Errorf("ProbesConfig: mode must be %q or %q, got %q", ProbeModeFile, ProbeModeHTTP, c.Mode)
+	}
+	if c.Addr == "" {
+		log.Println("Using default value for probes addr")
+		c.Addr = defaultProbeAddr
+	}
 	if c.ReadinessFileName == "" {
 		log.Println("Using default value for readinessfilename")
 		c.ReadinessFileName = defaultReadinessFileName
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"google.golang.org/grpc/codes"
 )
 
 type ResilienceConfig struct {
@@ -14,6 +16,25 @@ type ResilienceConfig struct {
 type RetryConfig struct {
 	MaxAttempts    uint          `koanf:"maxattempts"`
 	InitialBackoff time.Duration `koanf:"initialbackoff"`
+	// MaxBackoff caps the exponential backoff between retries, zero leaves it uncapped.
+	MaxBackoff time.Duration `koanf:"maxbackoff"`
+	// BudgetRatio is the number of retries allowed per call across all calls, e.g. 0.1 allows one retry
+	// for every ten calls. Zero disables the retry budget.
+	BudgetRatio float64 `koanf:"budgetratio"`
+	// RetryableCodes are the gRPC codes which are retried, given by name, e.g. UNAVAILABLE.
+	// Defaults to DefaultRetryableCodes when empty.
+	RetryableCodes []codes.Code `koanf:"retryablecodes"`
+}
+
+// DefaultRetryableCodes are the gRPC codes of transient errors which are retried by default.
+var DefaultRetryableCodes = []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.Aborted}
+
+// Codes returns the retryable gRPC codes, or DefaultRetryableCodes if none are configured.
+func (c *RetryConfig) Codes() []codes.Code {
+	if len(c.RetryableCodes) == 0 {
+		return DefaultRetryableCodes
+	}
+	return c.RetryableCodes
 }
 
 type CircuitBreakerConfig struct {
@@ -28,6 +49,9 @@ func (c *ResilienceConfig) String() string {
 	b.WriteString("\n--- Retry ---\n")
 	b.WriteString(fmt.Sprintf("  maxattempts: %d\n", c.Retry.MaxAttempts))
 	b.WriteString(fmt.Sprintf("  initialbackoff: %v\n", c.Retry.InitialBackoff))
+	b.WriteString(fmt.Sprintf("  maxbackoff: %v\n", c.Retry.MaxBackoff))
+	b.WriteString(fmt.Sprintf("  budgetratio: %v\n", c.Retry.BudgetRatio))
+	b.WriteString(fmt.Sprintf("  retryablecodes: %v\n", c.Retry.Codes()))
 	b.WriteString("\n--- Circuit Breaker ---\n")
 	b.WriteString(fmt.Sprintf("  consecutivefailures: %d\n", c.CircuitBreaker.ConsecutiveFailures))
 	b.WriteString(fmt.Sprintf("  errorratepercent: %d\n", c.CircuitBreaker.ErrorRatePercent))
@@ -42,6 +66,23 @@ func (c *ResilienceConfig) Validate() error {
 	if c.Retry.InitialBackoff <= 0 {
 		return fmt.Errorf("retry.initial_backoff must be greater than 0")
 	}
+	if c.Retry.MaxBackoff < 0 {
+		return fmt.Errorf("retry.max_backoff must not be negative")
+	}
+	if c.Retry.MaxBackoff > 0 && c.Retry.MaxBackoff < c.Retry.InitialBackoff {
+		return fmt.Errorf("retry.max_backoff must not be less than retry.initial_backoff")
+	}
+	if c.Retry.BudgetRatio < 0 {
+		return fmt.Errorf("retry.budget_ratio must not be negative")
+	}
+	for _, code := range c.Retry.RetryableCodes {
+		if code == codes.OK {
+			return fmt.Errorf("retry.retryable_codes must not contain OK")
+		}
+		if code > codes.Unauthenticated {
+			return fmt.Errorf("retry.retryable_codes contains unknown code %d", code)
+		}
+	}
 	if c.CircuitBreaker.ConsecutiveFailures <= 0 {
 		return fmt.Errorf("circuit_breaker.consecutive_failures must be greater than 0")
 	}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResilienceConfig_Validate_MaxBackoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBackoff time.Duration
+		wantErr    bool
+	}{
+		{name: "above the initial backoff", maxBackoff: time.Second},
+		{name: "equal to the initial backoff", maxBackoff: 100 * time.Millisecond},
+		{name: "zero leaves the backoff uncapped", maxBackoff: 0},
+		{name: "below the initial backoff", maxBackoff: 50 * time.Millisecond, wantErr: true},
+		{name: "negative", maxBackoff: -time.Second, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			cfg := ResilienceConfig{
+				Retry:          RetryConfig{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: tt.maxBackoff},
+				CircuitBreaker: CircuitBreakerConfig{ConsecutiveFailures: 5, OpenTimeout: time.Second},
+			}
+			// when
+			err := cfg.Validate()
+			// then
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
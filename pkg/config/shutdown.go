@@ -2,12 +2,32 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"strings"
 	"time"
 )
 
 type ShutdownConfig struct {
 	Timeout time.Duration `koanf:"timeout"`
+	// DrainDelay is the time between failing the readiness probe and shutting down the servers,
+	// so load balancers can deregister the service. Zero only fails the readiness probe.
+	DrainDelay time.Duration `koanf:"drainDelay"`
+	// Stages limits the stages of an ordered shutdown independently, a zero stage timeout defaults to Timeout.
+	Stages ShutdownStagesConfig `koanf:"stages"`
+}
+
+// ShutdownStagesConfig holds the timeouts of the stages of an ordered shutdown, in the order they run.
+type ShutdownStagesConfig struct {
+	// Drain limits failing the readiness probe and waiting for the DrainDelay.
+	Drain time.Duration `koanf:"drain"`
+	// HTTP limits the shutdown of the HTTP servers, which complete their in-flight requests.
+	HTTP time.Duration `koanf:"http"`
+	// GRPC limits closing the gRPC servers and clients.
+	GRPC time.Duration `koanf:"grpc"`
+	// NATS limits draining the NATS connection.
+	NATS time.Duration `koanf:"nats"`
+	// Telemetry limits flushing the pending traces.
+	Telemetry time.Duration `koanf:"telemetry"`
 }
 
 // String returns a string representation of the ShutdownConfig.
@@ -15,6 +35,12 @@ func (c *ShutdownConfig) String() string {
 	var b strings.Builder
 	b.WriteString("\n--- Shutdown ---\n")
 	b.WriteString(fmt.Sprintf("  timeout: %s\n", c.Timeout))
+	b.WriteString(fmt.Sprintf("  drainDelay: %s\n", c.DrainDelay))
+	b.WriteString(fmt.Sprintf("  stages.drain: %s\n", c.Stages.Drain))
+	b.WriteString(fmt.Sprintf("  stages.http: %s\n", c.Stages.HTTP))
+	b.WriteString(fmt.Sprintf("  stages.grpc: %s\n", c.Stages.GRPC))
+	b.WriteString(fmt.Sprintf("  stages.nats: %s\n", c.Stages.NATS))
+	b.WriteString(fmt.Sprintf("  stages.telemetry: %s\n", c.Stages.Telemetry))
 	return b.String()
 }
 
@@ -22,5 +48,34 @@ func (c *ShutdownConfig) Validate() error {
 	if c.Timeout <= 0 {
 		return fmt.Errorf("shutdown timeout is not configured")
 	}
+	if c.DrainDelay < 0 {
+		return fmt.Errorf("invalid shutdown drain delay: %v", c.DrainDelay)
+	}
+	// the drain is limited by the shutdown timeout like any other shutdown step
+	if c.DrainDelay >= c.Timeout {
+		return fmt.Errorf("shutdown drain delay (%v) must be shorter than the shutdown timeout (%v)", c.DrainDelay, c.Timeout)
+	}
+	stages := []struct {
+		name    string
+		timeout *time.Duration
+	}{
+		{"drain", &c.Stages.Drain},
+		{"http", &c.Stages.HTTP},
+		{"grpc", &c.Stages.GRPC},
+		{"nats", &c.Stages.NATS},
+		{"telemetry", &c.Stages.Telemetry},
+	}
+	for _, s := range stages {
+		if *s.timeout < 0 {
+			return fmt.Errorf("invalid shutdown %s stage timeout: %v", s.name, *s.timeout)
+		}
+		if *s.timeout == 0 {
+			log.Printf("Using the shutdown timeout for the shutdown %s stage", s.name)
+			*s.timeout = c.Timeout
+		}
+	}
+	if c.DrainDelay >= c.Stages.Drain {
+		return fmt.Errorf("shutdown drain delay (%v) must be shorter than the shutdown drain stage timeout (%v)", c.DrainDelay, c.Stages.Drain)
+	}
 	return nil
 }
@@ -15,9 +15,14 @@ type TracesConfig struct {
 	OtlpHttp OtlpHttpConfig `koanf:"otlphttp"`
 }
 
+// maxOrgAllowlist limits the number of organizations labelled in the metrics to keep their cardinality low.
+const maxOrgAllowlist = 50
+
 type MetricsConfig struct {
 	Enabled bool   `koanf:"enabled"`
 	Addr    string `koanf:"addr"`
+	// OrgAllowlist is the list of organizations labelled in the metrics, the others aren't labelled.
+	OrgAllowlist []string `koanf:"orgAllowlist"`
 }
 
 type OtlpHttpConfig struct {
@@ -35,6 +40,7 @@ func (c *TelemetryConfig) String() string {
 	b.WriteString(fmt.Sprintf("  traces.otlphttp.timeout: %v\n", c.Traces.OtlpHttp.Timeout))
 	if c.Metrics.Enabled {
 		b.WriteString(fmt.Sprintf("  metrics.addr: %v\n", c.Metrics.Addr))
+		b.WriteString(fmt.Sprintf("  metrics.orgAllowlist: %v\n", c.Metrics.OrgAllowlist))
 	}
 	return b.String()
 }
@@ -49,6 +55,9 @@ func (c *TelemetryConfig) Validate() error {
 	if c.Metrics.Enabled && c.Metrics.Addr == "" {
 		return fmt.Errorf("metrics.addr is not configured")
 	}
+	if len(c.Metrics.OrgAllowlist) > maxOrgAllowlist {
+		return fmt.Errorf("metrics.orgAllowlist must not contain more than %d organizations", maxOrgAllowlist)
+	}
 
 	return nil
 }
@@ -0,0 +1,209 @@
+package grpctls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const serverName = "product_service"
+
+func TestCredentials_BufconnCall(t *testing.T) {
+	pki := newTestPKI(t)
+	tests := []struct {
+		name      string
+		serverCfg config.GrpcTLSConfig
+		clientCfg config.GrpcTLSConfig
+		wantErr   bool
+	}{
+		{
+			name:      "insecure",
+			serverCfg: config.GrpcTLSConfig{Insecure: true},
+			clientCfg: config.GrpcTLSConfig{Insecure: true},
+		},
+		{
+			name:      "TLS",
+			serverCfg: config.GrpcTLSConfig{CertFile: pki.serverCert, KeyFile: pki.serverKey},
+			clientCfg: config.GrpcTLSConfig{CAFile: pki.ca, ServerName: serverName},
+		},
+		{
+			name:      "mutual TLS",
+			serverCfg: config.GrpcTLSConfig{CertFile: pki.serverCert, KeyFile: pki.serverKey, CAFile: pki.ca},
+			clientCfg: config.GrpcTLSConfig{CAFile: pki.ca, CertFile: pki.clientCert, KeyFile: pki.clientKey, ServerName: serverName},
+		},
+		{
+			name:      "mutual TLS without client certificate",
+			serverCfg: config.GrpcTLSConfig{CertFile: pki.serverCert, KeyFile: pki.serverKey, CAFile: pki.ca},
+			clientCfg: config.GrpcTLSConfig{CAFile: pki.ca, ServerName: serverName},
+			wantErr:   true,
+		},
+		{
+			name:      "untrusted server certificate",
+			serverCfg: config.GrpcTLSConfig{CertFile: pki.serverCert, KeyFile: pki.serverKey},
+			clientCfg: config.GrpcTLSConfig{CAFile: pki.otherCA, ServerName: serverName},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			client := newBufconnHealthClient(t, tt.serverCfg, tt.clientCfg)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			// when
+			resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+			// then
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+		})
+	}
+}
+
+func TestServerCredentials_MissingFiles(t *testing.T) {
+	// when
+	_, err := ServerCredentials(config.GrpcTLSConfig{CertFile: "missing.crt", KeyFile: "missing.key"})
+	// then
+	require.Error(t, err)
+}
+
+func TestClientCredentials_InvalidCA(t *testing.T) {
+	// given
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+	// when
+	_, err := ClientCredentials(config.GrpcTLSConfig{CAFile: caFile})
+	// then
+	require.Error(t, err)
+}
+
+// newBufconnHealthClient serves a health server over bufconn with the server TLS configuration
+// and returns a client connected to it with the client TLS configuration.
+func newBufconnHealthClient(t *testing.T, serverCfg, clientCfg config.GrpcTLSConfig) healthpb.HealthClient {
+	t.Helper()
+	serverCreds, err := ServerCredentials(serverCfg)
+	require.NoError(t, err)
+	clientCreds, err := ClientCredentials(clientCfg)
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(grpc.Creds(serverCreds))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(clientCreds),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+// testPKI holds the paths of the PEM files of a CA, a server and a client certificate signed by it,
+// and of an unrelated CA.
+type testPKI struct {
+	ca, otherCA           string
+	serverCert, serverKey string
+	clientCert, clientKey string
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	caCert, caKey := newCertificate(t, nil, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "gocommerce test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	otherCACert, _ := newCertificate(t, nil, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "other test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	serverCert, serverKey := newCertificate(t, caCert, caKey, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: serverName},
+		DNSNames:    []string{serverName},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	clientCert, clientKey := newCertificate(t, caCert, caKey, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "order_service"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	pki := testPKI{
+		ca:         writeCert(t, dir, "ca.crt", caCert),
+		otherCA:    writeCert(t, dir, "other-ca.crt", otherCACert),
+		serverCert: writeCert(t, dir, "server.crt", serverCert),
+		serverKey:  writeKey(t, dir, "server.key", serverKey),
+		clientCert: writeCert(t, dir, "client.crt", clientCert),
+		clientKey:  writeKey(t, dir, "client.key", clientKey),
+	}
+	return pki
+}
+
+// newCertificate creates a certificate from the template signed by the parent, or self-signed if the parent is nil.
+func newCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, template *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func writeCert(t *testing.T, dir, name string, cert *x509.Certificate) string {
+	t.Helper()
+	return writePEM(t, dir, name, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+func writeKey(t *testing.T, dir, name string, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return writePEM(t, dir, name, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func writePEM(t *testing.T, dir, name string, block *pem.Block) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0o600))
+	return path
+}
@@ -0,0 +1,137 @@
+// Package health provides liveness and readiness probes with dependency checks.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/web"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// checkTimeout limits the time of all dependency checks of a single readiness probe.
+const checkTimeout = 2 * time.Second
+
+// Check verifies a single dependency and returns an error if it is not available.
+type Check func(ctx context.Context) error
+
+// Handler serves the liveness and readiness probes.
+type Handler struct {
+	checks map[string]Check
+	logger *slog.Logger
+}
+
+// NewHandler creates a new probe handler, which runs the given named checks on readiness requests.
+func NewHandler(checks map[string]Check, logger *slog.Logger) *Handler {
+	return &Handler{
+		checks: checks,
+		logger: logger.With("component", "health"),
+	}
+}
+
+// RegisterRoutes registers the /livez and /readyz routes.
+func (h *Handler) RegisterRoutes(r chi.Router) {
+	r.Get("/livez", h.Live)
+	r.Get("/readyz", h.Ready)
+}
+
+// Live reports that the process is up. It doesn't check any dependencies,
+// so an unavailable dependency doesn't cause the orchestrator to restart the service.
+func (h *Handler) Live(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Ready runs all dependency checks concurrently and returns 503 if any of them fails.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	for name, check := range h.checks {
+		eg.Go(func() error {
+			if err := check(egCtx); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		h.logger.ErrorContext(r.Context(), "Readiness probe failed: dependency is not ready", "error", err)
+		web.RespondError(w, h.logger, http.StatusServiceUnavailable, "Service Unavailable: dependency is not ready")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// PgxPool returns a Check which pings the database using the connection pool.
+func PgxPool(pool *pgxpool.Pool) Check {
+	return func(ctx context.Context) error {
+		return pool.Ping(ctx)
+	}
+}
+
+// GRPCConn returns a Check which verifies that the gRPC client connection is ready,
+// triggering a connection attempt if the connection is idle.
+func GRPCConn(conn *grpc.ClientConn) Check {
+	return func(ctx context.Context) error {
+		for {
+			state := conn.GetState()
+			switch state {
+			case connectivity.Ready:
+				return nil
+			case connectivity.Shutdown:
+				return fmt.Errorf("grpc connection is shut down")
+			case connectivity.Idle:
+				conn.Connect()
+			}
+			if !conn.WaitForStateChange(ctx, state) {
+				return fmt.Errorf("grpc connection is not ready, state: %s", state)
+			}
+		}
+	}
+}
+
+// NATSConn returns a Check which verifies that the NATS connection is established.
+func NATSConn(nc *nats.Conn) Check {
+	return func(_ context.Context) error {
+		if !nc.IsConnected() {
+			return fmt.Errorf("nats connection is not connected, status: %s", nc.Status())
+		}
+		return nil
+	}
+}
+
+// Gate is a readiness check which fails until the gate is opened,
+// so the service isn't ready until a startup task is done.
+type Gate struct {
+	open   atomic.Bool
+	reason string
+}
+
+// NewGate creates a closed gate, its check fails with the reason until Open is called.
+func NewGate(reason string) *Gate {
+	return &Gate{reason: reason}
+}
+
+// Open opens the gate, so its check passes from now on.
+func (g *Gate) Open() {
+	g.open.Store(true)
+}
+
+// Check is the Check of the gate, it fails while the gate is closed.
+func (g *Gate) Check(_ context.Context) error {
+	if !g.open.Load() {
+		return errors.New(g.reason)
+	}
+	return nil
+}
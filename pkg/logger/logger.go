@@ -5,10 +5,10 @@ import (
 	"log/slog"
 
 	"github.com/go-chi/chi/v5/middleware"
-	"go.opentelemetry.io/otel/trace"
 )
 
 // ContextHandler is a wrapper around slog.Handler that adds context information.
+// The trace context is added by telemetry.LogHandler.
 type ContextHandler struct {
 	slog.Handler
 }
@@ -25,11 +25,8 @@ func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
 	return h.Handler.Enabled(ctx, level)
 }
 
-// Handle processes a log record and adds context information.
+// Handle processes a log record and adds the request ID.
 func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
-	if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
-		r.AddAttrs(slog.String("trace_id", span.SpanContext().TraceID().String()))
-	}
 	if reqID := middleware.GetReqID(ctx); reqID != "" {
 		r.AddAttrs(slog.String("request_id", reqID))
 	}
@@ -0,0 +1,25 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/messaging"
+)
+
+// AuditEvent records who performed an action on which resource and when.
+type AuditEvent struct {
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	Resource   string    `json:"resource"`
+	ResourceID string    `json:"resource_id"`
+	At         time.Time `json:"at"`
+}
+
+func (a AuditEvent) Subject() string {
+	return messaging.AuditEventsSubject
+}
+
+func (a AuditEvent) Payload() ([]byte, error) {
+	return json.Marshal(a)
+}
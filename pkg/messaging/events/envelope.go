@@ -0,0 +1,31 @@
+package events
+
+import "github.com/google/uuid"
+
+// Envelope holds the fields common to all events. Consumers decode the envelope first,
+// to skip redelivered events by EventID and to route the event to the handler of its schema version.
+//
+// A backward incompatible change of an event increments its schema version. The consumers handle both
+// versions before the publishers switch to the new one, so the versions coexist during the rollout.
+type Envelope struct {
+	EventID       uuid.UUID `json:"event_id"`
+	EventType     string    `json:"event_type"`
+	SchemaVersion int       `json:"schema_version"`
+}
+
+// Version returns the schema version of the event.
+// Events published before the schema versions were introduced are version 1.
+func (e Envelope) Version() int {
+	if e.SchemaVersion == 0 {
+		return 1
+	}
+	return e.SchemaVersion
+}
+
+// Types and current schema versions of the published events.
+const (
+	OrderCreatedType      = "OrderCreated"
+	OrderCreatedVersion   = 1
+	OrderCompletedType    = "OrderCompleted"
+	OrderCompletedVersion = 1
+)
@@ -9,10 +9,14 @@ import (
 	"go.opentelemetry.io/otel/propagation"
 )
 
+// OrderCreatedEvent is published when an order is created.
+// The type and the schema version of the envelope are set on publish.
 type OrderCreatedEvent struct {
+	Envelope
 	Carrier    propagation.MapCarrier `json:"carrier"`
 	OrderID    uuid.UUID              `json:"order_id"`
 	UserID     uuid.UUID              `json:"user_id"`
+	UserEmail  string                 `json:"user_email,omitempty"`
 	TotalPrice int64                  `json:"total_price"`
 	CreatedAt  time.Time              `json:"created_at"`
 }
@@ -22,5 +26,28 @@ func (o OrderCreatedEvent) Subject() string {
 }
 
 func (o OrderCreatedEvent) Payload() ([]byte, error) {
+	o.EventType = OrderCreatedType
+	o.SchemaVersion = OrderCreatedVersion
+	return json.Marshal(o)
+}
+
+// OrderCompletedEvent is published when an order transitions to completed.
+// The type and the schema version of the envelope are set on publish.
+type OrderCompletedEvent struct {
+	Envelope
+	Carrier     propagation.MapCarrier `json:"carrier"`
+	OrderID     uuid.UUID              `json:"order_id"`
+	UserID      uuid.UUID              `json:"user_id"`
+	UserEmail   string                 `json:"user_email,omitempty"`
+	CompletedAt time.Time              `json:"completed_at"`
+}
+
+func (o OrderCompletedEvent) Subject() string {
+	return messaging.OrdersCompletedSubject
+}
+
+func (o OrderCompletedEvent) Payload() ([]byte, error) {
+	o.EventType = OrderCompletedType
+	o.SchemaVersion = OrderCompletedVersion
 	return json.Marshal(o)
 }
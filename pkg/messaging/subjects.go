@@ -1,3 +1,5 @@
 package messaging
 
 const OrdersCreatedSubject = "orders.created"
+const OrdersCompletedSubject = "orders.completed"
+const AuditEventsSubject = "audit.events"
@@ -0,0 +1,190 @@
+// Package money provides the Money value type of the prices, an amount in minor units of a currency.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Scale is the number of decimal digits of the minor units, the amounts are hundredths of the major unit
+// of any currency, e.g. cents of USD. It matches the integer prices stored by the services,
+// so only the currencies with hundredths as their ISO 4217 minor unit are supported, see Supports.
+const Scale = 2
+
+// otherMinorUnits holds the ISO 4217 currencies whose minor unit isn't the hundredth of the major unit,
+// with the number of its decimal digits, e.g. JPY has none and KWD has fils, thousandths of the dinar.
+var otherMinorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// unitsPerMajor is the number of minor units of a major unit.
+const unitsPerMajor = 100
+
+var (
+	// ErrInvalidAmount is returned when a string is not a decimal amount, e.g. "5.99" or "-10".
+	ErrInvalidAmount = errors.New("invalid amount")
+	// ErrTooPrecise is returned when a decimal amount has more significant fractional digits than Scale.
+	// The amounts are never rounded implicitly.
+	ErrTooPrecise = errors.New("amount has more than 2 decimal places")
+	// ErrOverflow is returned when an amount doesn't fit into int64 minor units.
+	ErrOverflow = errors.New("amount is out of range")
+	// ErrUnsupportedCurrency is returned when an amount is read in a currency whose minor unit isn't the hundredth,
+	// which Scale can't represent, see Supports.
+	ErrUnsupportedCurrency = errors.New("currency doesn't have 2 decimal places")
+)
+
+// Money is an amount of money in minor units of its currency.
+// Currency is the ISO 4217 code of the currency, it's not part of the JSON representation,
+// since the DTOs carry it in a field of their own.
+//
+// Money is marshaled to JSON as a decimal string of the major units, e.g. "599.00" for 59900 minor units.
+// It's unmarshaled from a decimal string, or from a JSON integer of minor units, which is the deprecated
+// representation of the prices kept for the existing clients.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// Supports reports whether the ISO 4217 minor unit of the currency is the hundredth of the major unit,
+// so its amounts can be read at Scale. Unknown and empty currencies are supported, they are validated elsewhere.
+func Supports(currency string) bool {
+	_, other := otherMinorUnits[currency]
+	return !other
+}
+
+// New creates an amount of money of the minor units of the currency.
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Parse parses a decimal amount of the major units of the currency, e.g. "5.99", "-10" or "0.5".
+// Trailing zeros beyond Scale are accepted, other fractional digits are rejected with ErrTooPrecise.
+// Returns ErrUnsupportedCurrency if the minor unit of the currency isn't the hundredth, e.g. for JPY or KWD.
+func Parse(s, currency string) (Money, error) {
+	if !Supports(currency) {
+		return Money{}, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, currency)
+	}
+	amount, err := parseAmount(s)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(amount, currency), nil
+}
+
+// parseAmount parses a decimal amount of the major units into minor units.
+func parseAmount(s string) (int64, error) {
+	digits, negative := strings.CutPrefix(s, "-")
+	whole, fraction, hasPoint := strings.Cut(digits, ".")
+	if whole == "" || (hasPoint && fraction == "") || !isDigits(whole) || !isDigits(fraction) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if len(fraction) > Scale {
+		if strings.TrimRight(fraction[Scale:], "0") != "" {
+			return 0, fmt.Errorf("%w: %q", ErrTooPrecise, s)
+		}
+		fraction = fraction[:Scale]
+	}
+	fraction += strings.Repeat("0", Scale-len(fraction))
+	units, err := strconv.ParseUint(whole+fraction, 10, 64)
+	// the magnitude of math.MinInt64 is greater than math.MaxInt64
+	limit := uint64(math.MaxInt64)
+	if negative {
+		limit++
+	}
+	if err != nil || units > limit {
+		return 0, fmt.Errorf("%w: %q", ErrOverflow, s)
+	}
+	if negative {
+		return -int64(units), nil
+	}
+	return int64(units), nil
+}
+
+// isDigits reports whether s consists of the decimal digits only, an empty s does.
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the decimal amount of the major units with Scale fractional digits, e.g. "599.00" or "-0.05".
+// The amounts of an unsupported currency, stored before it was rejected, are hundredths as well and written as such.
+func (m Money) String() string {
+	sign := ""
+	// the magnitude of math.MinInt64 doesn't fit into int64
+	units := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign, units = "-", -units
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, units/unitsPerMajor, Scale, units%unitsPerMajor)
+}
+
+// MarshalJSON marshals the amount as a decimal string, see String.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON unmarshals the amount from a decimal string, see Parse, or from a JSON integer of minor units.
+// The currency is kept, and null leaves the amount unchanged. Returns ErrUnsupportedCurrency if the currency is set
+// and its minor unit isn't the hundredth. The DTOs validate their currency field with the money_currency rule instead,
+// see RegisterValidation.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if !Supports(m.Currency) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedCurrency, m.Currency)
+	}
+	if len(data) > 0 && data[0] != '"' {
+		// the deprecated representation: an integer of minor units, e.g. 599 for "5.99"
+		if bytes.ContainsAny(data, ".eE") {
+			return fmt.Errorf("%w: %s, a JSON number must be an integer of minor units", ErrInvalidAmount, data)
+		}
+		amount, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidAmount, data)
+		}
+		m.Amount = amount
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, data)
+	}
+	amount, err := parseAmount(s)
+	if err != nil {
+		return err
+	}
+	m.Amount = amount
+	return nil
+}
+
+// CurrencyTag is the validation rule of the currency codes of the amounts, which rejects the currencies
+// whose minor unit isn't the hundredth, e.g. `validate:"omitempty,iso4217,money_currency"`, see Supports.
+const CurrencyTag = "money_currency"
+
+// RegisterValidation makes the validation rules of the Money fields apply to their amount,
+// e.g. `validate:"required,min=0"` rejects a zero or a negative amount, and registers the CurrencyTag rule.
+func RegisterValidation(v *validator.Validate) {
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		return field.Interface().(Money).Amount
+	}, Money{})
+	// it only fails for an empty tag or a nil function
+	_ = v.RegisterValidation(CurrencyTag, func(fl validator.FieldLevel) bool {
+		return Supports(fl.Field().String())
+	})
+}
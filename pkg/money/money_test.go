@@ -0,0 +1,225 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		expected    int64
+		expectedErr error
+	}{
+		{name: "two decimal places", input: "599.00", expected: 59900},
+		{name: "integer", input: "10", expected: 1000},
+		{name: "one decimal place", input: "0.5", expected: 50},
+		{name: "cents only", input: "0.05", expected: 5},
+		{name: "negative", input: "-0.05", expected: -5},
+		{name: "zero", input: "0", expected: 0},
+		{name: "leading zeros", input: "007.10", expected: 710},
+		{name: "trailing zeros beyond the scale", input: "5.9900", expected: 599},
+		{name: "largest amount", input: "92233720368547758.07", expected: math.MaxInt64},
+		{name: "smallest amount", input: "-92233720368547758.08", expected: math.MinInt64},
+		{name: "third decimal place is not rounded", input: "5.995", expectedErr: ErrTooPrecise},
+		{name: "half cent is not rounded", input: "0.005", expectedErr: ErrTooPrecise},
+		{name: "significant digit after trailing zeros", input: "5.9901", expectedErr: ErrTooPrecise},
+		{name: "out of range", input: "92233720368547758.08", expectedErr: ErrOverflow},
+		{name: "empty", input: "", expectedErr: ErrInvalidAmount},
+		{name: "point without fraction", input: "5.", expectedErr: ErrInvalidAmount},
+		{name: "fraction without integer part", input: ".5", expectedErr: ErrInvalidAmount},
+		{name: "plus sign", input: "+5", expectedErr: ErrInvalidAmount},
+		{name: "thousands separator", input: "1,000.00", expectedErr: ErrInvalidAmount},
+		{name: "exponent", input: "1e3", expectedErr: ErrInvalidAmount},
+		{name: "whitespace", input: " 5.99", expectedErr: ErrInvalidAmount},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// when
+			m, err := Parse(tc.input, "USD")
+
+			// then
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, New(tc.expected, "USD"), m)
+		})
+	}
+}
+
+func TestParse_Currency(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		currency    string
+		expected    int64
+		expectedErr error
+	}{
+		{name: "hundredths of the euro", input: "5.99", currency: "EUR", expected: 599},
+		{name: "no currency", input: "5.99", currency: "", expected: 599},
+		{name: "yen has no minor unit", input: "500", currency: "JPY", expectedErr: ErrUnsupportedCurrency},
+		{name: "dinar has thousandths", input: "1.234", currency: "KWD", expectedErr: ErrUnsupportedCurrency},
+		{name: "dinar amount of hundredths", input: "1.23", currency: "BHD", expectedErr: ErrUnsupportedCurrency},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// when
+			m, err := Parse(tc.input, tc.currency)
+
+			// then
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, New(tc.expected, tc.currency), m)
+		})
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	testCases := []struct {
+		amount   int64
+		expected string
+	}{
+		{amount: 59900, expected: "599.00"},
+		{amount: 599, expected: "5.99"},
+		{amount: 5, expected: "0.05"},
+		{amount: 0, expected: "0.00"},
+		{amount: -5, expected: "-0.05"},
+		{amount: -1050, expected: "-10.50"},
+		{amount: math.MaxInt64, expected: "92233720368547758.07"},
+		{amount: math.MinInt64, expected: "-92233720368547758.08"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			// when
+			s := New(tc.amount, "USD").String()
+
+			// then
+			assert.Equal(t, tc.expected, s)
+			parsed, err := Parse(s, "USD")
+			require.NoError(t, err)
+			assert.Equal(t, tc.amount, parsed.Amount, "the string should be parsed back to the amount")
+		})
+	}
+}
+
+type priced struct {
+	Price    Money  `json:"price"`
+	Currency string `json:"currency"`
+}
+
+func TestMoney_MarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    priced
+		expected string
+	}{
+		{name: "hundredths", value: priced{Price: New(59900, "EUR"), Currency: "EUR"}, expected: `{"price":"599.00","currency":"EUR"}`},
+		// the amounts of the unsupported currencies stored before they were rejected are hundredths too
+		{name: "stored yen amount", value: priced{Price: New(50000, "JPY"), Currency: "JPY"}, expected: `{"price":"500.00","currency":"JPY"}`},
+		{name: "stored dinar amount", value: priced{Price: New(123, "KWD"), Currency: "KWD"}, expected: `{"price":"1.23","currency":"KWD"}`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// when
+			data, err := json.Marshal(tc.value)
+
+			// then
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expected, string(data))
+		})
+	}
+}
+
+func TestMoney_UnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name        string
+		currency    string
+		input       string
+		expected    int64
+		expectedErr error
+	}{
+		{name: "decimal string", input: `{"price":"599.00"}`, expected: 59900},
+		{name: "decimal string of a currency", currency: "EUR", input: `{"price":"599.00"}`, expected: 59900},
+		{name: "yen has no minor unit", currency: "JPY", input: `{"price":"500"}`, expectedErr: ErrUnsupportedCurrency},
+		{name: "dinar has thousandths", currency: "KWD", input: `{"price":"1.234"}`, expectedErr: ErrUnsupportedCurrency},
+		{name: "deprecated integer of a dinar", currency: "KWD", input: `{"price":1234}`, expectedErr: ErrUnsupportedCurrency},
+		{name: "decimal string without cents", input: `{"price":"599"}`, expected: 59900},
+		{name: "deprecated integer of minor units", input: `{"price":599}`, expected: 599},
+		{name: "null keeps the amount", input: `{"price":null}`, expected: 0},
+		{name: "too precise string", input: `{"price":"5.999"}`, expectedErr: ErrTooPrecise},
+		{name: "fractional number is ambiguous", input: `{"price":5.99}`, expectedErr: ErrInvalidAmount},
+		{name: "number with an exponent", input: `{"price":1e3}`, expectedErr: ErrInvalidAmount},
+		{name: "boolean", input: `{"price":true}`, expectedErr: ErrInvalidAmount},
+		{name: "invalid string", input: `{"price":"five"}`, expectedErr: ErrInvalidAmount},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			v := priced{Price: New(0, tc.currency)}
+
+			// when
+			err := json.Unmarshal([]byte(tc.input), &v)
+
+			// then
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, v.Price.Amount)
+		})
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	type payload struct {
+		Price    Money  `validate:"required,min=0"`
+		Patch    *Money `validate:"omitnil,min=0"`
+		Currency string `validate:"omitempty,money_currency"`
+	}
+	validate := validator.New()
+	RegisterValidation(validate)
+	negative := New(-1, "USD")
+	testCases := []struct {
+		name          string
+		payload       payload
+		expectedField string
+		expectedTag   string
+	}{
+		{name: "positive amount", payload: payload{Price: New(599, "USD")}},
+		{name: "zero amount is required", payload: payload{Price: New(0, "USD")}, expectedField: "Price", expectedTag: "required"},
+		{name: "negative amount", payload: payload{Price: New(-1, "USD")}, expectedField: "Price", expectedTag: "min"},
+		{name: "negative pointer amount", payload: payload{Price: New(599, "USD"), Patch: &negative}, expectedField: "Patch", expectedTag: "min"},
+		{name: "currency of hundredths", payload: payload{Price: New(599, "EUR"), Currency: "EUR"}},
+		{name: "currency without minor unit", payload: payload{Price: New(599, "JPY"), Currency: "JPY"}, expectedField: "Currency", expectedTag: CurrencyTag},
+		{name: "currency of thousandths", payload: payload{Price: New(599, "KWD"), Currency: "KWD"}, expectedField: "Currency", expectedTag: CurrencyTag},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// when
+			err := validate.Struct(tc.payload)
+
+			// then
+			if tc.expectedField == "" {
+				require.NoError(t, err)
+				return
+			}
+			var validationErrors validator.ValidationErrors
+			require.ErrorAs(t, err, &validationErrors)
+			require.Len(t, validationErrors, 1)
+			assert.Equal(t, tc.expectedField, validationErrors[0].Field())
+			assert.Equal(t, tc.expectedTag, validationErrors[0].Tag())
+		})
+	}
+}
@@ -1,19 +1,64 @@
 package nats
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 )
 
-func NewClient(url string, timeout time.Duration) (*nats.Conn, error) {
-	nc, err := nats.Connect(url, nats.Timeout(timeout))
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+// NewClient connects to NATS, retrying the initial connect cfg.ConnectRetries times every cfg.ReconnectWait,
+// e.g. while NATS isn't up yet at startup. A lost connection is reconnected in the background
+// as configured by cfg.MaxReconnects and cfg.ReconnectWait, the disconnects and reconnects are logged.
+// Returns an error if no attempt succeeds or ctx is done while waiting for the next one.
+func NewClient(ctx context.Context, cfg config.NATSConfig, logger *slog.Logger) (*nats.Conn, error) {
+	logger = logger.With("component", "nats")
+	wait := cfg.ReconnectWait
+	if wait == 0 {
+		wait = nats.DefaultReconnectWait
+	}
+	maxReconnects := cfg.MaxReconnects
+	if maxReconnects == 0 {
+		maxReconnects = nats.DefaultMaxReconnect
+	}
+	opts := []nats.Option{
+		nats.Timeout(cfg.Timeout),
+		nats.MaxReconnects(maxReconnects),
+		nats.ReconnectWait(wait),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			logger.Warn("Disconnected from NATS", "error", err)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logger.Info("Reconnected to NATS", "url", nc.ConnectedUrlRedacted())
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			if err := nc.LastError(); err != nil {
+				logger.Error("NATS connection closed", "error", err)
+				return
+			}
+			logger.Info("NATS connection closed")
+		}),
+	}
+
+	for attempt := 0; ; attempt++ {
+		nc, err := nats.Connect(cfg.Url, opts...)
+		if err == nil {
+			return nc, nil
+		}
+		if attempt >= cfg.ConnectRetries {
+			return nil, fmt.Errorf("failed to connect to NATS after %d attempts: %w", attempt+1, err)
+		}
+		logger.Warn("Failed to connect to NATS, retrying", "attempt", attempt+1, "wait", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to connect to NATS: %w", ctx.Err())
+		case <-time.After(wait):
+		}
 	}
-	return nc, nil
 }
 
 func NewJetStreamContext(nc *nats.Conn) (jetstream.JetStream, error) {
@@ -0,0 +1,176 @@
+package nats
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient_RetriesInitialConnect(t *testing.T) {
+	// given: the broker is started after the first connect attempts failed
+	broker := newFakeBroker(t)
+	time.AfterFunc(200*time.Millisecond, broker.start)
+	cfg := config.NATSConfig{Url: broker.url(), Timeout: time.Second, ReconnectWait: 50 * time.Millisecond, ConnectRetries: 40}
+
+	// when
+	nc, err := NewClient(context.Background(), cfg, discardLogger())
+
+	// then
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	assert.True(t, nc.IsConnected())
+}
+
+func TestNewClient_FailsAfterRetries(t *testing.T) {
+	testCases := []struct {
+		name    string
+		retries int
+		cancel  bool
+	}{
+		{name: "no retries", retries: 0},
+		{name: "retries exhausted", retries: 2},
+		{name: "context cancelled while waiting", retries: 100, cancel: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given: the broker never starts
+			broker := newFakeBroker(t)
+			cfg := config.NATSConfig{Url: broker.url(), Timeout: time.Second, ReconnectWait: 10 * time.Millisecond, ConnectRetries: tc.retries}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancel {
+				time.AfterFunc(50*time.Millisecond, cancel)
+			}
+
+			// when
+			nc, err := NewClient(ctx, cfg, discardLogger())
+
+			// then
+			require.Error(t, err)
+			assert.Nil(t, nc)
+			if tc.cancel {
+				assert.ErrorIs(t, err, context.Canceled)
+			}
+		})
+	}
+}
+
+func TestNewClient_ReconnectsAfterBrokerRestart(t *testing.T) {
+	// given
+	broker := newFakeBroker(t)
+	broker.start()
+	cfg := config.NATSConfig{Url: broker.url(), Timeout: time.Second, MaxReconnects: -1, ReconnectWait: 50 * time.Millisecond}
+	nc, err := NewClient(context.Background(), cfg, discardLogger())
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+
+	// when
+	broker.stop()
+	require.Eventually(t, func() bool { return !nc.IsConnected() }, 5*time.Second, 10*time.Millisecond,
+		"the client should notice the broker is down")
+	broker.start()
+
+	// then
+	require.Eventually(t, nc.IsConnected, 5*time.Second, 10*time.Millisecond,
+		"the client should reconnect to the restarted broker")
+	assert.False(t, nc.IsClosed())
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// fakeBroker speaks just enough of the NATS protocol to accept clients: it introduces itself
+// and answers their pings. It can be stopped and restarted on the same address.
+type fakeBroker struct {
+	t        *testing.T
+	addr     string
+	mu       sync.Mutex
+	listener net.Listener
+	conns    []net.Conn
+}
+
+// newFakeBroker reserves a free address for the broker without starting it.
+func newFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	b := &fakeBroker{t: t, addr: addr}
+	t.Cleanup(b.stop)
+	return b
+}
+
+func (b *fakeBroker) url() string {
+	return "nats://" + b.addr
+}
+
+// start listens on the address of the broker and serves the clients until stop.
+func (b *fakeBroker) start() {
+	ln, err := net.Listen("tcp", b.addr)
+	if err != nil {
+		b.t.Errorf("failed to start the fake broker: %v", err)
+		return
+	}
+	b.mu.Lock()
+	b.listener = ln
+	b.mu.Unlock()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.mu.Lock()
+			b.conns = append(b.conns, conn)
+			b.mu.Unlock()
+			go b.serve(conn)
+		}
+	}()
+}
+
+// stop closes the listener and drops the connected clients.
+func (b *fakeBroker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.listener != nil {
+		_ = b.listener.Close()
+		b.listener = nil
+	}
+	for _, conn := range b.conns {
+		_ = conn.Close()
+	}
+	b.conns = nil
+}
+
+func (b *fakeBroker) serve(conn net.Conn) {
+	host, port, _ := net.SplitHostPort(b.addr)
+	info := fmt.Sprintf(`INFO {"server_id":"fake","server_name":"fake","version":"2.11.6","proto":1,"host":%q,"port":%s,"max_payload":1048576}`+"\r\n", host, port)
+	if _, err := conn.Write([]byte(info)); err != nil {
+		return
+	}
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if strings.HasPrefix(line, "PING") {
+			if _, err := conn.Write([]byte("PONG\r\n")); err != nil {
+				return
+			}
+		}
+	}
+}
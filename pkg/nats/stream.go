@@ -0,0 +1,73 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// EnsureStream blocks until the stream exists, checking it every retryInterval, and returns it.
+// If create is set, a missing stream is created with cfg, otherwise the stream is expected to be created elsewhere,
+// e.g. by the stream setup job or the publishing service. An existing stream is left as it is, so ensuring it again is a no-op.
+// Logs whether the stream was created or found. Returns the context error if the context is done before the stream exists.
+func EnsureStream(ctx context.Context, js jetstream.JetStream, cfg jetstream.StreamConfig, create bool, retryInterval time.Duration, logger *slog.Logger) (jetstream.Stream, error) {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	for {
+		stream, created, err := lookupStream(ctx, js, cfg, create)
+		if err == nil {
+			if created {
+				logger.InfoContext(ctx, "Stream created", "stream", cfg.Name, "subjects", cfg.Subjects, "retention", cfg.Retention)
+			} else {
+				logger.InfoContext(ctx, "Stream found", "stream", cfg.Name)
+			}
+			return stream, nil
+		}
+		logger.WarnContext(ctx, "Stream is not available yet", "stream", cfg.Name, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// lookupStream returns the stream, creating it first if it is missing and create is set, and reports whether it was created.
+func lookupStream(ctx context.Context, js jetstream.JetStream, cfg jetstream.StreamConfig, create bool) (jetstream.Stream, bool, error) {
+	stream, err := js.Stream(ctx, cfg.Name)
+	if !create || !errors.Is(err, jetstream.ErrStreamNotFound) {
+		return stream, false, err
+	}
+	stream, err = js.CreateStream(ctx, cfg)
+	if errors.Is(err, jetstream.ErrStreamNameAlreadyInUse) {
+		// another instance of the service created the stream in the meantime
+		stream, err = js.Stream(ctx, cfg.Name)
+		return stream, false, err
+	}
+	return stream, err == nil, err
+}
+
+// EnsureConsumer creates the durable consumer cfg.Durable of the stream, or updates the existing one to cfg,
+// e.g. to new filter subjects, and returns it. Ensuring it again with the same cfg is a no-op.
+// Logs whether the consumer was created or found. Returns jetstream.ErrStreamNotFound if the stream doesn't exist,
+// see EnsureStream to wait for it.
+func EnsureConsumer(ctx context.Context, js jetstream.JetStream, stream string, cfg jetstream.ConsumerConfig, logger *slog.Logger) (jetstream.Consumer, error) {
+	_, err := js.Consumer(ctx, stream, cfg.Durable)
+	if err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+		return nil, err
+	}
+	created := err != nil
+	consumer, err := js.CreateOrUpdateConsumer(ctx, stream, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		logger.InfoContext(ctx, "Consumer created", "stream", stream, "consumer", cfg.Durable, "subjects", cfg.FilterSubjects, "ackPolicy", cfg.AckPolicy)
+	} else {
+		logger.InfoContext(ctx, "Consumer found", "stream", stream, "consumer", cfg.Durable)
+	}
+	return consumer, nil
+}
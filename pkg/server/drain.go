@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/web"
+)
+
+// ReadinessPath is the path of the readiness probe failed by the Drainer.
+const ReadinessPath = "/readyz"
+
+// Drainer fails the readiness probe on shutdown before the servers are stopped,
+// so load balancers deregister the service while it still serves the in-flight and the new requests.
+type Drainer struct {
+	delay    time.Duration
+	draining atomic.Bool
+	logger   *slog.Logger
+}
+
+// NewDrainer creates a Drainer, which waits for delay after failing the readiness probe.
+// A zero delay only fails the readiness probe.
+func NewDrainer(delay time.Duration, logger *slog.Logger) *Drainer {
+	return &Drainer{
+		delay:  delay,
+		logger: logger.With("component", "drainer"),
+	}
+}
+
+// Middleware responds with 503 to the readiness probe once the drain is started,
+// all other requests are passed to next.
+func (d *Drainer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.draining.Load() && r.URL.Path == ReadinessPath {
+			web.RespondError(w, d.logger, http.StatusServiceUnavailable, "Service Unavailable: shutting down")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Drain fails the readiness probe and waits for the delay, or until the context is done.
+// It's meant to be the first shutdown step, so the servers are shut down after it returns.
+func (d *Drainer) Drain(ctx context.Context) error {
+	d.draining.Store(true)
+	d.logger.Info("Draining, the readiness probe fails from now on", slog.Duration("delay", d.delay))
+	if d.delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d.delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainer(t *testing.T) {
+	// given: a server with a readiness probe and a slow handler, which is released by the test
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	drainer := NewDrainer(200*time.Millisecond, logger)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc(ReadinessPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewServer(drainer.Middleware(mux))
+	t.Cleanup(srv.Close)
+	status := func(path string) int {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusOK, status(ReadinessPath), "the service should be ready before the drain")
+
+	inFlight := make(chan int, 1)
+	go func() { inFlight <- status("/slow") }()
+	<-started
+
+	// when
+	start := time.Now()
+	drained := make(chan error, 1)
+	go func() { drained <- drainer.Drain(context.Background()) }()
+
+	// then
+	assert.Eventually(t, func() bool { return status(ReadinessPath) == http.StatusServiceUnavailable },
+		time.Second, 10*time.Millisecond, "the readiness probe should fail once the drain is started")
+	assert.Equal(t, http.StatusOK, status("/fast"), "new requests should still be served while draining")
+	close(release)
+	assert.Equal(t, http.StatusOK, <-inFlight, "the in-flight request should be served while draining")
+	require.NoError(t, <-drained)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond, "the drain should wait for the delay")
+}
+
+func TestDrainer_ContextDone(t *testing.T) {
+	// given
+	drainer := NewDrainer(time.Minute, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// when
+	err := drainer.Drain(ctx)
+
+	// then
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "the drain should stop waiting when the shutdown timeout expires")
+	rr := httptest.NewRecorder()
+	drainer.Middleware(http.NotFoundHandler()).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+}
@@ -1,8 +1,13 @@
 package server
 
 import (
+	"log/slog"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/grpctls"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -10,16 +15,45 @@ import (
 type RegistrationFunc func(*grpc.Server)
 
 // NewGRPCServer creates a new gRPC server instance with optional reflection and service registration.
-func NewGRPCServer(enableReflection bool, registerFunc ...RegistrationFunc) *grpc.Server {
-	grpcServer := grpc.NewServer(grpc.StatsHandler(otelgrpc.NewServerHandler()))
+// The server uses TLS unless it's disabled in the configuration, returns an error if the certificates can't be loaded.
+// Registering the reflection is logged, since it exposes the API of the server, see config.GrpcServerConfig.ValidateEnv.
+func NewGRPCServer(cfg config.GrpcServerConfig, logger *slog.Logger, registerFunc ...RegistrationFunc) (*grpc.Server, error) {
+	creds, err := grpctls.ServerCredentials(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+	grpcServer := grpc.NewServer(append(serverOptions(cfg), grpc.Creds(creds))...)
 
-	if enableReflection {
+	if cfg.ReflectionEnabled {
 		reflection.Register(grpcServer)
+		logger.Warn("gRPC reflection is enabled, any client can list the services", slog.String("port", cfg.Port))
 	}
 
 	for _, regFunc := range registerFunc {
 		regFunc(grpcServer)
 	}
 
-	return grpcServer
+	return grpcServer, nil
+}
+
+// serverOptions builds the options of the gRPC server from the configuration.
+func serverOptions(cfg config.GrpcServerConfig) []grpc.ServerOption {
+	opts := []grpc.ServerOption{grpc.StatsHandler(otelgrpc.NewServerHandler())}
+	if params, ok := keepaliveParams(cfg); ok {
+		opts = append(opts, grpc.KeepaliveParams(params))
+	}
+	return opts
+}
+
+// keepaliveParams returns the keepalive parameters limiting the age of client connections.
+// When the age is reached, the server sends GOAWAY, so clients open a new connection for new RPCs,
+// while pending RPCs get the grace period to complete. Returns false if the age is not limited.
+func keepaliveParams(cfg config.GrpcServerConfig) (keepalive.ServerParameters, bool) {
+	if cfg.MaxConnectionAge <= 0 {
+		return keepalive.ServerParameters{}, false
+	}
+	return keepalive.ServerParameters{
+		MaxConnectionAge:      cfg.MaxConnectionAge,
+		MaxConnectionAgeGrace: cfg.MaxConnectionAgeGrace,
+	}, true
 }
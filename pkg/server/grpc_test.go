@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestKeepaliveParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.GrpcServerConfig
+		expected keepalive.ServerParameters
+		ok       bool
+	}{
+		{
+			name: "max connection age is not configured",
+			cfg:  config.GrpcServerConfig{Port: "50051"},
+			ok:   false,
+		},
+		{
+			name: "max connection age with grace",
+			cfg:  config.GrpcServerConfig{Port: "50051", MaxConnectionAge: 5 * time.Minute, MaxConnectionAgeGrace: 30 * time.Second},
+			expected: keepalive.ServerParameters{
+				MaxConnectionAge:      5 * time.Minute,
+				MaxConnectionAgeGrace: 30 * time.Second,
+			},
+			ok: true,
+		},
+		{
+			name:     "max connection age without grace",
+			cfg:      config.GrpcServerConfig{Port: "50051", MaxConnectionAge: time.Minute},
+			expected: keepalive.ServerParameters{MaxConnectionAge: time.Minute},
+			ok:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			params, ok := keepaliveParams(tt.cfg)
+			// then
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, params)
+		})
+	}
+}
+
+func TestServerOptions(t *testing.T) {
+	// given
+	withAge := config.GrpcServerConfig{Port: "50051", MaxConnectionAge: time.Minute}
+	// when
+	defaults := serverOptions(config.GrpcServerConfig{Port: "50051"})
+	limited := serverOptions(withAge)
+	// then
+	assert.Len(t, defaults, 1, "only the stats handler is expected")
+	assert.Len(t, limited, 2, "the stats handler and keepalive parameters are expected")
+}
+
+func TestNewGRPCServer_Reflection(t *testing.T) {
+	tests := []struct {
+		name         string
+		enabled      bool
+		expectedCode codes.Code
+	}{
+		{name: "reflection enabled", enabled: true, expectedCode: codes.OK},
+		{name: "reflection disabled", enabled: false, expectedCode: codes.Unimplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			cfg := config.GrpcServerConfig{Port: "50051", ReflectionEnabled: tt.enabled, TLS: config.GrpcTLSConfig{Insecure: true}}
+			grpcServer, err := NewGRPCServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			require.NoError(t, err)
+			lis := bufconn.Listen(1024 * 1024)
+			go func() {
+				_ = grpcServer.Serve(lis)
+			}()
+			t.Cleanup(grpcServer.Stop)
+			conn, err := grpc.NewClient("passthrough:///bufnet",
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return lis.DialContext(ctx)
+				}),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+			)
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = conn.Close() })
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			// when
+			stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+			require.NoError(t, err)
+			require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+				MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+			}))
+			resp, err := stream.Recv()
+
+			// then
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedCode == codes.OK {
+				assert.NotEmpty(t, resp.GetListServicesResponse().GetService(), "the reflection should list the services")
+			}
+		})
+	}
+}
@@ -37,15 +37,22 @@ func NewHTTPServer(cfg config.HTTPConfig, handler http.Handler) *http.Server {
 }
 
 // NewChiRouter creates a new Chi router with a set of
-// middleware for request ID injection, structured logging, telemetry, and recovery.
-func NewChiRouter(logger *slog.Logger) *chi.Mux {
+// middleware for request ID injection, structured access logging, telemetry, and recovery.
+// Trailing slashes are handled according to the trailingSlash mode, see config.TrailingSlashStrip and the other modes.
+func NewChiRouter(logger *slog.Logger, trailingSlash string) *chi.Mux {
 	mux := chi.NewRouter()
+	switch trailingSlash {
+	case config.TrailingSlashStrip:
+		mux.Use(middleware.StripSlashes)
+	case config.TrailingSlashRedirect:
+		mux.Use(middleware.RedirectSlashes)
+	}
 	mux.Use(web.Recoverer(logger))
 	mux.Use(middleware.RequestID)
 	mux.Use(func(next http.Handler) http.Handler {
 		return otelhttp.NewHandler(next, "http.server")
 	})
 	mux.Use(web.TelemetryEnricher)
-	mux.Use(web.StructuredLogger(logger))
+	mux.Use(web.AccessLogMiddleware(logger))
 	return mux
 }
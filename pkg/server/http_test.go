@@ -0,0 +1,97 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewChiRouter_TrailingSlash(t *testing.T) {
+	tests := []struct {
+		name             string
+		mode             string
+		path             string
+		expectedStatus   int
+		expectedBody     string
+		expectedLocation string
+	}{
+		{
+			name:           "strip: collection without trailing slash",
+			mode:           config.TrailingSlashStrip,
+			path:           "/api/v1/products",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "products",
+		},
+		{
+			name:           "strip: collection with trailing slash",
+			mode:           config.TrailingSlashStrip,
+			path:           "/api/v1/products/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "products",
+		},
+		{
+			name:           "strip: item with trailing slash",
+			mode:           config.TrailingSlashStrip,
+			path:           "/api/v1/products/42/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "product 42",
+		},
+		{
+			name:           "redirect: collection without trailing slash",
+			mode:           config.TrailingSlashRedirect,
+			path:           "/api/v1/products",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "products",
+		},
+		{
+			name:             "redirect: collection with trailing slash",
+			mode:             config.TrailingSlashRedirect,
+			path:             "/api/v1/products/",
+			expectedStatus:   http.StatusMovedPermanently,
+			expectedLocation: "/api/v1/products",
+		},
+		{
+			name:           "off: collection without trailing slash",
+			mode:           config.TrailingSlashOff,
+			path:           "/api/v1/products",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "products",
+		},
+		{
+			name:           "off: collection with trailing slash",
+			mode:           config.TrailingSlashOff,
+			path:           "/api/v1/products/",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			mux := NewChiRouter(slog.New(slog.NewTextHandler(io.Discard, nil)), tt.mode)
+			mux.Get("/api/v1/products", func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("products"))
+			})
+			mux.Get("/api/v1/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("product " + chi.URLParam(r, "id")))
+			})
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rr := httptest.NewRecorder()
+			// when
+			mux.ServeHTTP(rr, req)
+			// then
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedBody != "" {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+			if tt.expectedLocation != "" {
+				assert.Equal(t, tt.expectedLocation, rr.Header().Get("Location"))
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/abgdnv/gocommerce/pkg/config"
+)
+
+// NewPProfServer creates the pprof server listening on the address of the configuration, see PProfHandler.
+func NewPProfServer(cfg config.PProfConfig) *http.Server {
+	return &http.Server{
+		Addr:    cfg.Addr,
+		Handler: PProfHandler(cfg.AuthToken),
+	}
+}
+
+// PProfHandler serves the pprof profiles at /debug/pprof/.
+// If token is set, requests without it are rejected with 401 Unauthorized. The token is accepted as a bearer token
+// of the Authorization header, or as the password of basic auth, e.g. go tool pprof http://pprof:<token>@host:6060/debug/pprof/heap.
+func PProfHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	if token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasToken(r, token) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="pprof"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// hasToken reports whether the request has the token as a bearer token or as the password of basic auth.
+func hasToken(r *http.Request, token string) bool {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		_, provided, ok = r.BasicAuth()
+	}
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
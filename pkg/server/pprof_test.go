@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPProfHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		authorization  string
+		basicPassword  string
+		path           string
+		expectedStatus int
+	}{
+		{name: "no token configured", path: "/debug/pprof/", expectedStatus: http.StatusOK},
+		{name: "no token configured: profile", path: "/debug/pprof/cmdline", expectedStatus: http.StatusOK},
+		{name: "missing token", token: "secret", path: "/debug/pprof/", expectedStatus: http.StatusUnauthorized},
+		{name: "missing token: profile", token: "secret", path: "/debug/pprof/heap", expectedStatus: http.StatusUnauthorized},
+		{name: "wrong bearer token", token: "secret", authorization: "Bearer wrong", path: "/debug/pprof/", expectedStatus: http.StatusUnauthorized},
+		{name: "bearer token", token: "secret", authorization: "Bearer secret", path: "/debug/pprof/", expectedStatus: http.StatusOK},
+		{name: "bearer token: profile", token: "secret", authorization: "Bearer secret", path: "/debug/pprof/cmdline", expectedStatus: http.StatusOK},
+		{name: "wrong basic auth password", token: "secret", basicPassword: "wrong", path: "/debug/pprof/", expectedStatus: http.StatusUnauthorized},
+		{name: "basic auth password", token: "secret", basicPassword: "secret", path: "/debug/pprof/", expectedStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			handler := PProfHandler(tt.token)
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.basicPassword != "" {
+				req.SetBasicAuth("pprof", tt.basicPassword)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, `Basic realm="pprof"`, rr.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
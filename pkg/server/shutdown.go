@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ShutdownFunc stops a part of a service. It should return once the context is done.
+type ShutdownFunc func(ctx context.Context) error
+
+// ShutdownStage is a step of an ordered shutdown, e.g. draining the HTTP servers.
+// Its functions are called in order and share the timeout of the stage.
+type ShutdownStage struct {
+	Name    string
+	Timeout time.Duration
+	Funcs   []ShutdownFunc
+}
+
+// Shutdown runs the stages in the given order, each limited by its own timeout.
+// A stage still running once its timeout is over is abandoned, so a stuck stage doesn't block the later ones
+// past its timeout, e.g. the traces are flushed even if an HTTP server doesn't stop. Returns the joined errors of the stages.
+func Shutdown(stages []ShutdownStage, logger *slog.Logger) error {
+	var errs error
+	for _, stage := range stages {
+		logger.Info("Shutting down stage", slog.String("stage", stage.Name), slog.Duration("timeout", stage.Timeout))
+		if err := runStage(stage); err != nil {
+			logger.Error("Failed to shut down stage", slog.String("stage", stage.Name), slog.Any("error", err))
+			errs = errors.Join(errs, fmt.Errorf("%s shutdown failed: %w", stage.Name, err))
+		}
+	}
+	return errs
+}
+
+// runStage calls the functions of the stage with a fresh context limited by the stage timeout.
+// It returns once the functions return or the timeout is over, whichever comes first.
+func runStage(stage ShutdownStage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), stage.Timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		var errs error
+		for _, fn := range stage.Funcs {
+			errs = errors.Join(errs, fn(ctx))
+		}
+		done <- errs
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("stage timed out after %v: %w", stage.Timeout, ctx.Err())
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown_StagesRunInOrder(t *testing.T) {
+	// given
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) ShutdownFunc {
+		return func(ctx context.Context) error {
+			_, hasDeadline := ctx.Deadline()
+			assert.True(t, hasDeadline, "shutdown context should have a deadline")
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+			return nil
+		}
+	}
+	stages := []ShutdownStage{
+		{Name: "drain", Timeout: time.Second, Funcs: []ShutdownFunc{record("drain")}},
+		{Name: "http", Timeout: time.Second, Funcs: []ShutdownFunc{record("http server"), record("pprof server")}},
+		{Name: "grpc", Timeout: time.Second, Funcs: []ShutdownFunc{record("grpc client")}},
+		{Name: "nats", Timeout: time.Second, Funcs: []ShutdownFunc{record("nats")}},
+		{Name: "telemetry", Timeout: time.Second, Funcs: []ShutdownFunc{record("tracer")}},
+	}
+
+	// when
+	err := Shutdown(stages, logger)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []string{"drain", "http server", "pprof server", "grpc client", "nats", "tracer"}, events)
+}
+
+func TestShutdown_StuckStageDoesNotBlockLaterStages(t *testing.T) {
+	// given: the http stage ignores its context and never returns
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	stuck := make(chan struct{})
+	t.Cleanup(func() { close(stuck) })
+	flushed := make(chan time.Time, 1)
+	stages := []ShutdownStage{
+		{Name: "http", Timeout: 100 * time.Millisecond, Funcs: []ShutdownFunc{func(_ context.Context) error {
+			<-stuck
+			return nil
+		}}},
+		{Name: "telemetry", Timeout: time.Second, Funcs: []ShutdownFunc{func(_ context.Context) error {
+			flushed <- time.Now()
+			return nil
+		}}},
+	}
+	start := time.Now()
+
+	// when
+	err := Shutdown(stages, logger)
+
+	// then
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "http shutdown failed")
+	select {
+	case at := <-flushed:
+		assert.GreaterOrEqual(t, at.Sub(start), 100*time.Millisecond, "the later stage should run after the timeout of the stuck stage")
+		assert.Less(t, at.Sub(start), time.Second, "the later stage should not wait for the stuck stage past its timeout")
+	default:
+		t.Fatal("the stage after the stuck stage was not run")
+	}
+}
+
+func TestShutdown_ErrorsAreJoined(t *testing.T) {
+	// given
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	natsErr := errors.New("drain failed")
+	tracerErr := errors.New("flush failed")
+	var httpCalled bool
+	stages := []ShutdownStage{
+		{Name: "nats", Timeout: time.Second, Funcs: []ShutdownFunc{func(_ context.Context) error { return natsErr }}},
+		{Name: "http", Timeout: time.Second, Funcs: []ShutdownFunc{func(_ context.Context) error {
+			httpCalled = true
+			return nil
+		}}},
+		{Name: "telemetry", Timeout: time.Second, Funcs: []ShutdownFunc{func(_ context.Context) error { return tracerErr }}},
+	}
+
+	// when
+	err := Shutdown(stages, logger)
+
+	// then
+	require.Error(t, err)
+	assert.ErrorIs(t, err, natsErr)
+	assert.ErrorIs(t, err, tracerErr)
+	assert.True(t, httpCalled, "all stages should run even if one of them fails")
+}
@@ -0,0 +1,85 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dbTracerName is the instrumentation scope of the database spans.
+const dbTracerName = "github.com/abgdnv/gocommerce/pkg/telemetry/db"
+
+// DBSpan traces a single database operation.
+type DBSpan struct {
+	span  trace.Span
+	ctx   context.Context
+	query string
+	start time.Time
+	// slowLog logs the operation when the span ends if it took too long, nil disables the log.
+	slowLog *SlowQueryLogger
+}
+
+// SlowQueryLogger starts the spans of the database operations like StartDBSpan,
+// and logs the operations taking longer than the threshold at warn when their span ends.
+// A nil SlowQueryLogger or a zero threshold disables the log.
+type SlowQueryLogger struct {
+	threshold time.Duration
+	logger    *slog.Logger
+}
+
+// NewSlowQueryLogger creates a SlowQueryLogger logging the operations taking longer than threshold to the logger.
+func NewSlowQueryLogger(threshold time.Duration, logger *slog.Logger) *SlowQueryLogger {
+	return &SlowQueryLogger{threshold: threshold, logger: logger}
+}
+
+// StartDBSpan starts the span of the database operation, see StartDBSpan.
+func (l *SlowQueryLogger) StartDBSpan(ctx context.Context, query string) (context.Context, *DBSpan) {
+	ctx, span := StartDBSpan(ctx, query)
+	if l != nil && l.threshold > 0 {
+		span.ctx, span.query, span.start, span.slowLog = ctx, query, time.Now(), l
+	}
+	return ctx, span
+}
+
+// StartDBSpan starts a child span for the database operation, named after the query.
+// The span is created by the global tracer provider, which is set up by NewTracerProvider.
+func StartDBSpan(ctx context.Context, query string) (context.Context, *DBSpan) {
+	ctx, span := otel.Tracer(dbTracerName).Start(ctx, "db."+query,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", query),
+		),
+	)
+	return ctx, &DBSpan{span: span}
+}
+
+// End records the number of returned or affected rows, or the error of the operation, and ends the span.
+// pgx.ErrNoRows isn't recorded as an error, as it's an expected result of a lookup.
+func (s *DBSpan) End(rows int64, err error) {
+	defer s.span.End()
+	if s.slowLog != nil {
+		if elapsed := time.Since(s.start); elapsed > s.slowLog.threshold {
+			s.slowLog.logger.WarnContext(s.ctx, "Slow database query",
+				slog.String("query", s.query),
+				slog.Duration("duration", elapsed),
+				slog.Duration("threshold", s.slowLog.threshold))
+		}
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		rows, err = 0, nil
+	}
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	s.span.SetAttributes(attribute.Int64("db.response.returned_rows", rows))
+}
@@ -0,0 +1,119 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestDBSpan(t *testing.T) {
+	testCases := []struct {
+		name           string
+		rows           int64
+		err            error
+		expectedStatus codes.Code
+		expectedRows   int64
+		expectEvents   bool
+	}{
+		{
+			name:           "rows are recorded",
+			rows:           3,
+			expectedStatus: codes.Unset,
+			expectedRows:   3,
+		},
+		{
+			name:           "no rows is not an error",
+			rows:           1,
+			err:            pgx.ErrNoRows,
+			expectedStatus: codes.Unset,
+			expectedRows:   0,
+		},
+		{
+			name:           "error is recorded",
+			rows:           1,
+			err:            errors.New("connection refused"),
+			expectedStatus: codes.Error,
+			expectEvents:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			recorder := tracetest.NewSpanRecorder()
+			provider := tracesdk.NewTracerProvider(tracesdk.WithSpanProcessor(recorder))
+			prev := otel.GetTracerProvider()
+			otel.SetTracerProvider(provider)
+			t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+			// when
+			_, span := StartDBSpan(context.Background(), "FindByID")
+			span.End(tc.rows, tc.err)
+
+			// then
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "db.FindByID", spans[0].Name())
+			assert.Contains(t, spans[0].Attributes(), attribute.String("db.operation.name", "FindByID"))
+			assert.Equal(t, tc.expectedStatus, spans[0].Status().Code)
+			if tc.expectEvents {
+				assert.NotEmpty(t, spans[0].Events(), "error should be recorded as a span event")
+				return
+			}
+			assert.Contains(t, spans[0].Attributes(), attribute.Int64("db.response.returned_rows", tc.expectedRows))
+		})
+	}
+}
+
+func TestSlowQueryLogger(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold time.Duration
+		nilLogger bool
+		expectLog bool
+	}{
+		{name: "slow query is logged", threshold: time.Nanosecond, expectLog: true},
+		{name: "fast query is not logged", threshold: time.Hour},
+		{name: "zero threshold disables the log", threshold: 0},
+		{name: "nil logger disables the log", nilLogger: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			var buf bytes.Buffer
+			slowLog := NewSlowQueryLogger(tc.threshold, slog.New(slog.NewJSONHandler(&buf, nil)))
+			if tc.nilLogger {
+				slowLog = nil
+			}
+
+			// when
+			_, span := slowLog.StartDBSpan(context.Background(), "FindByID")
+			time.Sleep(time.Millisecond)
+			span.End(1, nil)
+
+			// then
+			if !tc.expectLog {
+				assert.Empty(t, buf.String())
+				return
+			}
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+			assert.Equal(t, "WARN", record["level"])
+			assert.Equal(t, "Slow database query", record["msg"])
+			assert.Equal(t, "FindByID", record["query"])
+			assert.Equal(t, float64(time.Nanosecond), record["threshold"])
+		})
+	}
+}
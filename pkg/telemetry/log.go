@@ -0,0 +1,46 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LogHandler is a wrapper around slog.Handler that adds the trace_id and span_id of the current span
+// to the records logged with a context, linking the logs to their traces.
+type LogHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler creates a new LogHandler.
+func NewLogHandler(handler slog.Handler) *LogHandler {
+	return &LogHandler{
+		Handler: handler,
+	}
+}
+
+// Handle adds the IDs of the span in ctx, if any, to the record.
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a new LogHandler with the given attributes added.
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{
+		Handler: h.Handler.WithAttrs(attrs),
+	}
+}
+
+// WithGroup returns a new LogHandler with the given group added.
+func (h *LogHandler) WithGroup(group string) slog.Handler {
+	return &LogHandler{
+		Handler: h.Handler.WithGroup(group),
+	}
+}
@@ -0,0 +1,38 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestLogHandler(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil))).With("service", "test")
+	tp := sdktrace.NewTracerProvider()
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	ctx, span := tp.Tracer("test").Start(context.Background(), "operation")
+	defer span.End()
+
+	// when
+	logger.InfoContext(ctx, "within the span")
+	logger.InfoContext(context.Background(), "without a span")
+
+	// then
+	dec := json.NewDecoder(&buf)
+	var withSpan, withoutSpan map[string]any
+	require.NoError(t, dec.Decode(&withSpan))
+	require.NoError(t, dec.Decode(&withoutSpan))
+	assert.Equal(t, span.SpanContext().TraceID().String(), withSpan["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), withSpan["span_id"])
+	assert.Equal(t, "test", withSpan["service"])
+	assert.NotContains(t, withoutSpan, "trace_id")
+	assert.NotContains(t, withoutSpan, "span_id")
+}
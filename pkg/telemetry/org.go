@@ -0,0 +1,85 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/abgdnv/gocommerce/pkg/web"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OrgAttributeKey is the metric label of the organization the request is made on behalf of.
+const OrgAttributeKey = attribute.Key("org")
+
+type orgKey struct{}
+
+// ContextWithOrg returns a copy of ctx carrying the organization of the request.
+func ContextWithOrg(ctx context.Context, org string) context.Context {
+	return context.WithValue(ctx, orgKey{}, org)
+}
+
+// OrgFromContext returns the organization of the request, or an empty string if there is none.
+func OrgFromContext(ctx context.Context) string {
+	org, _ := ctx.Value(orgKey{}).(string)
+	return org
+}
+
+// OrgLabel labels metrics with the organization of the request.
+// Only the allowlisted organizations are labelled to keep the metrics cardinality low,
+// the requests of all the other organizations are recorded without the label.
+// A nil OrgLabel labels nothing.
+type OrgLabel struct {
+	allowlist map[string]struct{}
+}
+
+// NewOrgLabel creates an OrgLabel for the allowlisted organizations.
+// An empty allowlist disables the label.
+func NewOrgLabel(allowlist []string) *OrgLabel {
+	l := &OrgLabel{allowlist: make(map[string]struct{}, len(allowlist))}
+	for _, org := range allowlist {
+		if org != "" {
+			l.allowlist[org] = struct{}{}
+		}
+	}
+	return l
+}
+
+// Enabled reports whether any organization is allowlisted.
+func (l *OrgLabel) Enabled() bool {
+	return l != nil && len(l.allowlist) > 0
+}
+
+// Attributes returns the org attribute if the organization is allowlisted, otherwise nil.
+func (l *OrgLabel) Attributes(org string) []attribute.KeyValue {
+	if !l.Enabled() {
+		return nil
+	}
+	if _, ok := l.allowlist[org]; !ok {
+		return nil
+	}
+	return []attribute.KeyValue{OrgAttributeKey.String(org)}
+}
+
+// Middleware puts the organization of the X-Org-Id header into the request context
+// and labels the HTTP server metrics with it if the organization is allowlisted.
+// The header is trusted like the X-User-* headers, since the API gateway sets it from the verified token.
+// It must be placed after the otelhttp handler, which provides the labeler.
+func (l *OrgLabel) Middleware(next http.Handler) http.Handler {
+	if !l.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		org := r.Header.Get(web.XOrgId)
+		if org == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if attrs := l.Attributes(org); attrs != nil {
+			if labeler, ok := otelhttp.LabelerFromContext(r.Context()); ok {
+				labeler.Add(attrs...)
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(ContextWithOrg(r.Context(), org)))
+	})
+}
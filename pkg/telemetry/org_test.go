@@ -0,0 +1,126 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abgdnv/gocommerce/pkg/web"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// dataPointAttributes returns the attribute sets of all the recorded data points.
+func dataPointAttributes(rm metricdata.ResourceMetrics) []attribute.Set {
+	var sets []attribute.Set
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					sets = append(sets, dp.Attributes)
+				}
+			case metricdata.Histogram[int64]:
+				for _, dp := range data.DataPoints {
+					sets = append(sets, dp.Attributes)
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					sets = append(sets, dp.Attributes)
+				}
+			}
+		}
+	}
+	return sets
+}
+
+func TestOrgLabel_Middleware(t *testing.T) {
+	testCases := []struct {
+		name        string
+		allowlist   []string
+		org         string
+		expectedOrg string
+	}{
+		{
+			name:        "allowlisted org is labelled",
+			allowlist:   []string{"acme", "globex"},
+			org:         "acme",
+			expectedOrg: "acme",
+		},
+		{
+			name:      "org not in the allowlist is dropped",
+			allowlist: []string{"acme", "globex"},
+			org:       "initech",
+		},
+		{
+			name:      "request without org is not labelled",
+			allowlist: []string{"acme"},
+		},
+		{
+			name: "empty allowlist disables the label",
+			org:  "acme",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			reader := sdkmetric.NewManualReader()
+			meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+			var contextOrg string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contextOrg = OrgFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := otelhttp.NewHandler(NewOrgLabel(tc.allowlist).Middleware(next), "http.server",
+				otelhttp.WithMeterProvider(meterProvider))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil)
+			if tc.org != "" {
+				req.Header.Set(web.XOrgId, tc.org)
+			}
+
+			// when
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			// then
+			var rm metricdata.ResourceMetrics
+			require.NoError(t, reader.Collect(context.Background(), &rm))
+			sets := dataPointAttributes(rm)
+			require.NotEmpty(t, sets)
+			for _, set := range sets {
+				org, ok := set.Value(OrgAttributeKey)
+				if tc.expectedOrg == "" {
+					assert.False(t, ok, "unexpected org label %v", org.Emit())
+				} else {
+					assert.True(t, ok)
+					assert.Equal(t, tc.expectedOrg, org.AsString())
+				}
+			}
+			if len(tc.allowlist) > 0 {
+				assert.Equal(t, tc.org, contextOrg)
+			}
+		})
+	}
+}
+
+func TestOrgLabel_Attributes(t *testing.T) {
+	// given
+	label := NewOrgLabel([]string{"acme", ""})
+	var nilLabel *OrgLabel
+
+	// when
+	allowlisted := label.Attributes("acme")
+	other := label.Attributes("initech")
+	empty := label.Attributes("")
+	disabled := nilLabel.Attributes("acme")
+
+	// then
+	assert.Equal(t, []attribute.KeyValue{OrgAttributeKey.String("acme")}, allowlisted)
+	assert.Nil(t, other)
+	assert.Nil(t, empty)
+	assert.Nil(t, disabled)
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const accessLogKey = contextKey("accessLog")
+
+// accessLogEntry holds the request details resolved by the inner handlers, e.g. the authenticated user.
+// The handler may still run in another goroutine when the request times out, so the fields are atomic.
+type accessLogEntry struct {
+	userID atomic.Pointer[string]
+}
+
+// SetAccessLogUserID records the resolved user ID in the access log entry of the request.
+// It's a no-op if the request isn't logged by AccessLogMiddleware.
+func SetAccessLogUserID(ctx context.Context, userID string) {
+	if entry, ok := ctx.Value(accessLogKey).(*accessLogEntry); ok {
+		entry.userID.Store(&userID)
+	}
+}
+
+// AccessLogMiddleware logs every completed request as a structured record with the method, path, status,
+// latency, response size, request ID and the user ID recorded by the auth middleware, see SetAccessLogUserID.
+// Requests failed with 5xx are logged as errors, requests rejected with 4xx as warnings.
+// A request whose handler panicked is logged with 500 and the panic is passed on to the outer Recoverer.
+// It must be used after middleware.RequestID to log the request ID.
+func AccessLogMiddleware(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			entry := &accessLogEntry{}
+			start := time.Now()
+
+			defer func() {
+				rvr := recover()
+				status := ww.Status()
+				if rvr != nil {
+					// the request failed, the outer Recoverer responds with 500
+					status = http.StatusInternalServerError
+				} else if status == 0 {
+					// the handler wrote nothing, net/http responds with 200
+					status = http.StatusOK
+				}
+				var userID string
+				if id := entry.userID.Load(); id != nil {
+					userID = *id
+				}
+				logger.LogAttrs(r.Context(), accessLogLevel(status), "Request completed",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", status),
+					slog.Int("bytes_written", ww.BytesWritten()),
+					slog.Float64("duration_ms", float64(time.Since(start).Nanoseconds())/1e6),
+					slog.String("request_id", middleware.GetReqID(r.Context())),
+					slog.String("user_id", userID),
+					slog.String("remote_addr", r.RemoteAddr),
+					slog.String("user_agent", r.UserAgent()),
+				)
+				if rvr != nil {
+					panic(rvr)
+				}
+			}()
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), accessLogKey, entry)))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// accessLogLevel returns the level of the access log record of a response with the status.
+func accessLogLevel(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return slog.LevelError
+	case status >= http.StatusBadRequest:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	testCases := []struct {
+		name           string
+		handler        http.HandlerFunc
+		expectedStatus float64
+		expectedLevel  string
+		expectedUserID string
+		expectedBytes  float64
+	}{
+		{
+			name: "successful request of an authenticated user",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				SetAccessLogUserID(r.Context(), "user-123")
+				_, _ = w.Write([]byte("ok"))
+			},
+			expectedStatus: http.StatusOK,
+			expectedLevel:  "INFO",
+			expectedUserID: "user-123",
+			expectedBytes:  2,
+		},
+		{
+			name: "handler writes nothing",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+			},
+			expectedStatus: http.StatusOK,
+			expectedLevel:  "INFO",
+		},
+		{
+			name: "client error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedLevel:  "WARN",
+		},
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedLevel:  "ERROR",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+			handler := middleware.RequestID(AccessLogMiddleware(logger)(tc.handler))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/products?limit=10", nil)
+			req.Header.Set(middleware.RequestIDHeader, "req-1")
+			req.Header.Set("User-Agent", "test-agent")
+			rr := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(rr, req)
+
+			// then
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &record), "a single JSON record should be logged")
+			assert.Equal(t, tc.expectedLevel, record["level"])
+			assert.Equal(t, "Request completed", record["msg"])
+			assert.Equal(t, http.MethodGet, record["method"])
+			assert.Equal(t, "/api/v1/products", record["path"])
+			assert.Equal(t, tc.expectedStatus, record["status"])
+			assert.Equal(t, tc.expectedBytes, record["bytes_written"])
+			assert.Contains(t, record, "duration_ms")
+			assert.Equal(t, "req-1", record["request_id"])
+			assert.Equal(t, tc.expectedUserID, record["user_id"])
+			assert.Equal(t, "test-agent", record["user_agent"])
+		})
+	}
+}
+
+func TestAccessLogMiddleware_Panic(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	})
+	handler := Recoverer(slog.New(slog.NewTextHandler(io.Discard, nil)))(middleware.RequestID(AccessLogMiddleware(logger)(panicking)))
+	rr := httptest.NewRecorder()
+
+	// when
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/products", nil))
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, rr.Code, "the panic should be passed on to the Recoverer")
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record), "a single JSON record should be logged")
+	assert.Equal(t, "ERROR", record["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), record["status"])
+}
+
+func TestAuthMiddleware_SetsAccessLogUserID(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := AccessLogMiddleware(logger)(AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(XUserId, "user-123")
+
+	// when
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// then
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "user-123", record["user_id"])
+}
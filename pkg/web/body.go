@@ -0,0 +1,63 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+)
+
+// MaxBodySizeMiddleware limits the request bodies to maxBytes.
+// Reading beyond the limit fails with *http.MaxBytesError, which the decoding helpers report with RespondBodyTooLarge.
+func MaxBodySizeMiddleware(maxBytes int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RespondBodyTooLarge responds with 413 Request Entity Too Large if err is caused by a body exceeding the limit
+// of MaxBodySizeMiddleware. Returns false without responding if it's not.
+func RespondBodyTooLarge(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return false
+	}
+	logger.WarnContext(r.Context(), "Request body too large", "limit", maxBytesErr.Limit)
+	RespondError(w, logger, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body too large: maximum is %d bytes", maxBytesErr.Limit))
+	return true
+}
+
+// RequireJSON rejects POST, PUT and PATCH requests with a body of a media type other than application/json
+// with 415 Unsupported Media Type, so a client sending e.g. form data gets a clear error instead of a decoding error.
+// Requests without a body are passed on, the handlers report a missing body themselves.
+func RequireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasWriteBody(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		contentType := r.Header.Get("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != "application/json" {
+			slog.WarnContext(r.Context(), "Unsupported request content type", "contentType", contentType)
+			RespondError(w, slog.Default(), http.StatusUnsupportedMediaType,
+				fmt.Sprintf("Unsupported content type: %q, must be application/json", contentType))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasWriteBody reports whether the request is a POST, PUT or PATCH request with a body.
+func hasWriteBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return r.ContentLength != 0 && r.Body != nil && r.Body != http.NoBody
+	default:
+		return false
+	}
+}
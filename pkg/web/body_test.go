@@ -0,0 +1,120 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxBodySizeMiddleware(t *testing.T) {
+	type payload struct {
+		Name string `json:"name" validate:"required"`
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	validate := validator.New()
+	handler := MaxBodySizeMiddleware(32)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := DecodeAndValidate[payload](r, validate, false); err != nil {
+			RespondValidationError(w, r, logger, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "body within the limit",
+			body:         `{"name":"test"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "body exceeding the limit",
+			body:         `{"name":"` + strings.Repeat("a", 32) + `"}`,
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: `{"error":"Request body too large: maximum is 32 bytes","code":"REQUEST_ENTITY_TOO_LARGE"}`,
+		},
+		{
+			name:         "invalid body within the limit",
+			body:         `{"name":`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid request body","code":"BAD_REQUEST"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	handler := RequireJSON(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		name         string
+		method       string
+		contentType  string
+		body         string
+		expectedCode int
+	}{
+		{name: "POST with JSON", method: http.MethodPost, contentType: "application/json", body: `{}`, expectedCode: http.StatusOK},
+		{name: "PUT with JSON and charset", method: http.MethodPut, contentType: "application/json; charset=utf-8", body: `{}`, expectedCode: http.StatusOK},
+		{name: "PATCH with JSON in upper case", method: http.MethodPatch, contentType: "Application/JSON", body: `{}`, expectedCode: http.StatusOK},
+		{name: "POST with form data", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", body: "name=test", expectedCode: http.StatusUnsupportedMediaType},
+		{name: "PUT with plain text", method: http.MethodPut, contentType: "text/plain", body: `{}`, expectedCode: http.StatusUnsupportedMediaType},
+		{name: "PATCH without content type", method: http.MethodPatch, body: `{}`, expectedCode: http.StatusUnsupportedMediaType},
+		{name: "POST with malformed content type", method: http.MethodPost, contentType: "application/", body: `{}`, expectedCode: http.StatusUnsupportedMediaType},
+		{name: "POST without body", method: http.MethodPost, expectedCode: http.StatusOK},
+		{name: "DELETE without content type", method: http.MethodDelete, body: `{}`, expectedCode: http.StatusOK},
+		{name: "GET without content type", method: http.MethodGet, expectedCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			var body io.Reader
+			if tc.body != "" {
+				body = strings.NewReader(tc.body)
+			}
+			req := httptest.NewRequest(tc.method, "/", body)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			if tc.expectedCode == http.StatusUnsupportedMediaType {
+				var resp ErrorResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				assert.Equal(t, fmt.Sprintf("Unsupported content type: %q, must be application/json", tc.contentType), resp.Error)
+				assert.Equal(t, "UNSUPPORTED_MEDIA_TYPE", resp.Code)
+			}
+		})
+	}
+}
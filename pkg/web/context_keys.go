@@ -3,3 +3,6 @@ package web
 type contextKey string
 
 const UserIDKey = contextKey("userID")
+const EmailVerifiedKey = contextKey("emailVerified")
+const UserEmailKey = contextKey("userEmail")
+const UserRolesKey = contextKey("userRoles")
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Generic error codes, used when there is no more specific code for the error.
+const (
+	CodeBadRequest         = "BAD_REQUEST"
+	CodeValidationFailed   = "VALIDATION_FAILED"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeNotFound           = "NOT_FOUND"
+	CodeConflict           = "CONFLICT"
+	CodeUnprocessable      = "UNPROCESSABLE_ENTITY"
+	CodeInternal           = "INTERNAL_ERROR"
+	CodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	CodeTimeout            = "TIMEOUT"
+)
+
+// AppError is an error reported to the client. It carries the HTTP status of the response,
+// a machine-readable code clients can switch on and a human-readable message.
+type AppError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// NewAppError creates a new AppError. An empty code is replaced with the generic code of the status.
+func NewAppError(status int, code, message string) *AppError {
+	if code == "" {
+		code = StatusCode(status)
+	}
+	return &AppError{Status: status, Code: code, Message: message}
+}
+
+func (e *AppError) Error() string {
+	return e.Message
+}
+
+// ErrorResponse is the JSON body of error responses.
+// Error is the human-readable message, kept for backward compatibility, Code is the machine-readable code.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// RespondAppError responds with the status of the error and its code and message as JSON.
+func RespondAppError(w http.ResponseWriter, logger *slog.Logger, appErr *AppError) {
+	RespondJSON(w, logger, appErr.Status, ErrorResponse{Error: appErr.Message, Code: appErr.Code})
+}
+
+// StatusCode returns the generic error code of the HTTP status,
+// e.g. NOT_FOUND for 404. Unknown statuses are derived from the status text.
+func StatusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusUnprocessableEntity:
+		return CodeUnprocessable
+	case http.StatusInternalServerError:
+		return CodeInternal
+	case http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return CodeInternal
+	}
+	return strings.ToUpper(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespondAppError(t *testing.T) {
+	testCases := []struct {
+		name         string
+		appErr       *AppError
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "specific code",
+			appErr:       NewAppError(http.StatusNotFound, "ORDER_NOT_FOUND", "Order not found"),
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Order not found","code":"ORDER_NOT_FOUND"}`,
+		},
+		{
+			name:         "generic code of the status",
+			appErr:       NewAppError(http.StatusConflict, "", "Conflict"),
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"Conflict","code":"CONFLICT"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			rr := httptest.NewRecorder()
+			// when
+			RespondAppError(rr, logger, tc.appErr)
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestRespondError_GenericCode(t *testing.T) {
+	// given
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	rr := httptest.NewRecorder()
+	// when
+	RespondError(rr, logger, http.StatusBadRequest, "Invalid request body")
+	// then
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"error":"Invalid request body","code":"BAD_REQUEST"}`, rr.Body.String())
+}
+
+func TestStatusCode(t *testing.T) {
+	testCases := []struct {
+		status   int
+		expected string
+	}{
+		{status: http.StatusBadRequest, expected: CodeBadRequest},
+		{status: http.StatusForbidden, expected: CodeForbidden},
+		{status: http.StatusGatewayTimeout, expected: CodeTimeout},
+		{status: http.StatusTooManyRequests, expected: "TOO_MANY_REQUESTS"},
+		{status: http.StatusRequestEntityTooLarge, expected: "REQUEST_ENTITY_TOO_LARGE"},
+		{status: 599, expected: CodeInternal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			assert.Equal(t, tc.expected, StatusCode(tc.status))
+		})
+	}
+}
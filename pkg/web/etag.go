@@ -0,0 +1,42 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag returns the strong entity tag of a version of a resource, e.g. "3" including the quotes.
+func ETag(version int32) string {
+	return strconv.Quote(strconv.FormatInt(int64(version), 10))
+}
+
+// SetETag sets the ETag header of the response to the entity tag of the version, see ETag.
+// It must be called before the status is written.
+func SetETag(w http.ResponseWriter, version int32) {
+	w.Header().Set("ETag", ETag(version))
+}
+
+// ParseIfMatch returns the version of the entity tag of the If-Match header, as set by SetETag,
+// so a client can replay the ETag of a resource instead of sending its version in the request.
+// Returns 0 if the header is missing or *, then the version of the request applies.
+// Responds with 400 and returns false if the header isn't a single strong entity tag of a version.
+func ParseIfMatch(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (int32, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	tag, err := strconv.Unquote(header)
+	if err != nil || !strings.HasPrefix(header, `"`) {
+		RespondError(w, logger, http.StatusBadRequest, fmt.Sprintf("Invalid If-Match header: %s", header))
+		return 0, false
+	}
+	version, err := strconv.ParseInt(tag, 10, 32)
+	if err != nil || version < 1 {
+		RespondError(w, logger, http.StatusBadRequest, fmt.Sprintf("Invalid If-Match header: %s", header))
+		return 0, false
+	}
+	return int32(version), true
+}
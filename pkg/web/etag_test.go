@@ -0,0 +1,64 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetETag(t *testing.T) {
+	// given
+	rr := httptest.NewRecorder()
+
+	// when
+	SetETag(rr, 3)
+
+	// then
+	assert.Equal(t, `"3"`, rr.Header().Get("ETag"))
+}
+
+func TestParseIfMatch(t *testing.T) {
+	testCases := []struct {
+		name            string
+		header          string
+		expectedVersion int32
+		expectedOK      bool
+	}{
+		{name: "no header", expectedOK: true},
+		{name: "any version", header: "*", expectedOK: true},
+		{name: "entity tag of a version", header: `"3"`, expectedVersion: 3, expectedOK: true},
+		{name: "entity tag of the ETag", header: ETag(42), expectedVersion: 42, expectedOK: true},
+		{name: "surrounding spaces", header: ` "7" `, expectedVersion: 7, expectedOK: true},
+		{name: "unquoted version", header: "3"},
+		{name: "weak entity tag", header: `W/"3"`},
+		{name: "list of entity tags", header: `"3", "4"`},
+		{name: "not a version", header: `"abc"`},
+		{name: "zero version", header: `"0"`},
+		{name: "version overflow", header: `"2147483648"`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tc.header != "" {
+				req.Header.Set("If-Match", tc.header)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			version, ok := ParseIfMatch(rr, req, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			// then
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedVersion, version)
+			if !tc.expectedOK {
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+				assert.Contains(t, rr.Body.String(), `"code":"BAD_REQUEST"`)
+			}
+		})
+	}
+}
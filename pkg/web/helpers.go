@@ -1,6 +1,7 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -29,8 +30,19 @@ func RespondJSON(w http.ResponseWriter, logger *slog.Logger, status int, payload
 	_, _ = w.Write(response)
 }
 
+// RespondCreated responds with 201 and the created resource as JSON.
+// The Location header is set to the URL of the created resource, unless location is empty.
+func RespondCreated(w http.ResponseWriter, logger *slog.Logger, location string, payload any) {
+	if location != "" {
+		w.Header().Set("Location", location)
+	}
+	RespondJSON(w, logger, http.StatusCreated, payload)
+}
+
+// RespondError responds with the message and the generic code of the status as JSON.
+// Use RespondAppError to respond with a more specific code.
 func RespondError(w http.ResponseWriter, logger *slog.Logger, status int, message string) {
-	RespondJSON(w, logger, status, map[string]string{"error": message})
+	RespondAppError(w, logger, NewAppError(status, "", message))
 }
 
 // ParseID extracts and validates the ID from the request path. Returns the ID and a boolean indicating success.
@@ -46,19 +58,43 @@ func ParseID(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (uuid.
 
 // GetUserID retrieves the user ID from the request context. Returns the user ID and a boolean indicating success.
 func GetUserID(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (uuid.UUID, bool) {
-	userID, ok := r.Context().Value(UserIDKey).(string)
-	if !ok || userID == "" {
+	user, ok := UserFromContext(r.Context())
+	if !ok {
 		RespondError(w, logger, http.StatusUnauthorized, "Unauthorized: Missing or invalid user ID")
 		return uuid.Nil, false
 	}
-	parsedUserID, err := uuid.Parse(userID)
+	parsedUserID, err := uuid.Parse(user.ID)
 	if err != nil {
-		RespondError(w, logger, http.StatusBadRequest, fmt.Sprintf("Invalid user ID: %s", userID))
+		RespondError(w, logger, http.StatusBadRequest, fmt.Sprintf("Invalid user ID: %s", user.ID))
 		return uuid.Nil, false
 	}
 	return parsedUserID, true
 }
 
+// IsEmailVerified reports whether the authenticated user's email is verified.
+func IsEmailVerified(ctx context.Context) bool {
+	verified, _ := ctx.Value(EmailVerifiedKey).(bool)
+	return verified
+}
+
+// GetUserIDString returns the user ID stored in the context, or an empty string if the request is anonymous.
+func GetUserIDString(ctx context.Context) string {
+	user, _ := UserFromContext(ctx)
+	return user.ID
+}
+
+// GetUserEmail returns the authenticated user's email address, or an empty string if it is unknown.
+func GetUserEmail(ctx context.Context) string {
+	email, _ := ctx.Value(UserEmailKey).(string)
+	return email
+}
+
+// HasRole reports whether the authenticated user has the given role.
+func HasRole(ctx context.Context, role string) bool {
+	user, _ := UserFromContext(ctx)
+	return user.HasRole(role)
+}
+
 func MapGrpcToHttpStatus(err error) (statusCode int, message string) {
 	st, ok := status.FromError(err)
 	if !ok {
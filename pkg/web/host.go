@@ -0,0 +1,49 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// probePaths are the paths of the liveness and readiness probes, which the orchestrator calls with the address of the pod.
+var probePaths = map[string]bool{"/livez": true, "/readyz": true}
+
+// AllowedHostsMiddleware rejects requests with a Host header not in hosts with 400 Bad Request,
+// so links and redirects built from the Host header can't be pointed at another site.
+// A host matches with or without the port of the request, case-insensitively, e.g. shop.example.com matches
+// shop.example.com:8080, while shop.example.com:8080 matches only that port. The probes are never rejected.
+// An empty list disables the check.
+func AllowedHostsMiddleware(hosts []string, logger *slog.Logger) func(next http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		allowed[strings.ToLower(host)] = true
+	}
+	return func(next http.Handler) http.Handler {
+		if len(allowed) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if probePaths[r.URL.Path] || isAllowedHost(allowed, r.Host) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			logger.WarnContext(r.Context(), "Host not allowed", "host", r.Host)
+			RespondError(w, logger, http.StatusBadRequest, fmt.Sprintf("Host not allowed: %q", r.Host))
+		})
+	}
+}
+
+// isAllowedHost reports whether the Host header matches an allowed host, with or without its port.
+func isAllowedHost(allowed map[string]bool, header string) bool {
+	host := strings.ToLower(header)
+	if allowed[host] {
+		return true
+	}
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return allowed[name]
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowedHostsMiddleware(t *testing.T) {
+	testCases := []struct {
+		name         string
+		hosts        []string
+		host         string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "empty list allows any host", host: "evil.example.com", expectedCode: http.StatusOK},
+		{name: "allowed host", hosts: []string{"shop.example.com"}, host: "shop.example.com", expectedCode: http.StatusOK},
+		{name: "allowed host with a port", hosts: []string{"shop.example.com"}, host: "shop.example.com:8080", expectedCode: http.StatusOK},
+		{name: "allowed host in another case", hosts: []string{"Shop.Example.com"}, host: "shop.EXAMPLE.com", expectedCode: http.StatusOK},
+		{name: "allowed host and port", hosts: []string{"localhost:8080"}, host: "localhost:8080", expectedCode: http.StatusOK},
+		{name: "allowed IPv6 host", hosts: []string{"::1"}, host: "[::1]:8080", expectedCode: http.StatusOK},
+		{
+			name:         "disallowed host",
+			hosts:        []string{"shop.example.com"},
+			host:         "evil.example.com",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Host not allowed: \"evil.example.com\"","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:         "disallowed port",
+			hosts:        []string{"localhost:8080"},
+			host:         "localhost:9090",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Host not allowed: \"localhost:9090\"","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:         "subdomain of an allowed host",
+			hosts:        []string{"example.com"},
+			host:         "evil.example.com",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Host not allowed: \"evil.example.com\"","code":"BAD_REQUEST"}`,
+		},
+		{name: "readiness probe of a disallowed host", hosts: []string{"shop.example.com"}, host: "10.0.0.7:8080", path: "/readyz", expectedCode: http.StatusOK},
+		{name: "liveness probe of a disallowed host", hosts: []string{"shop.example.com"}, host: "10.0.0.7:8080", path: "/livez", expectedCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			handler := AllowedHostsMiddleware(tc.hosts, logger)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			path := tc.path
+			if path == "" {
+				path = "/api/v1/products"
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Host = tc.host
+			rr := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrJSONTooDeep is returned when the nesting depth of a JSON document exceeds the limit.
+var ErrJSONTooDeep = errors.New("JSON nesting depth exceeds the limit")
+
+// UnknownFieldError is returned by strict decoding if the JSON document has a field the target doesn't,
+// e.g. a misspelled field name, which lenient decoding ignores.
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.Field)
+}
+
+// DecodeJSON reads the JSON document from r and decodes it into v.
+// If maxDepth is positive, documents nested deeper than maxDepth are rejected with ErrJSONTooDeep
+// before any values are allocated for them.
+// If strict is set, a field v doesn't have is rejected with *UnknownFieldError instead of being ignored.
+func DecodeJSON(r io.Reader, v any, maxDepth int, strict bool) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read JSON: %w", err)
+	}
+	if maxDepth > 0 {
+		if err := checkJSONDepth(data, maxDepth); err != nil {
+			return err
+		}
+	}
+	if !strict {
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return unknownFieldError(err)
+	}
+	// like json.Unmarshal, reject anything after the document
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid JSON: unexpected data after the document")
+	}
+	return nil
+}
+
+// unknownFieldError converts the error of a json.Decoder disallowing unknown fields to *UnknownFieldError,
+// other errors are returned as they are. The decoder reports the unknown field only in its message.
+func unknownFieldError(err error) error {
+	field, ok := strings.CutPrefix(err.Error(), "json: unknown field ")
+	if !ok {
+		return err
+	}
+	if unquoted, err := strconv.Unquote(field); err == nil {
+		field = unquoted
+	}
+	return &UnknownFieldError{Field: field}
+}
+
+// checkJSONDepth scans the JSON document and returns ErrJSONTooDeep if objects and arrays are nested deeper than maxDepth.
+// It doesn't validate the document, syntax errors are reported by the decoder.
+func checkJSONDepth(data []byte, maxDepth int) error {
+	depth := 0
+	inString, escaped := false, false
+	for _, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return fmt.Errorf("%w of %d", ErrJSONTooDeep, maxDepth)
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package web
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type payload struct {
+		Name  string `json:"name"`
+		Items []struct {
+			ID int `json:"id"`
+		} `json:"items"`
+	}
+
+	testCases := []struct {
+		name          string
+		body          string
+		maxDepth      int
+		strict        bool
+		expectedErr   error
+		expectedField string
+		expectErr     bool
+	}{
+		{
+			name:     "within the depth limit",
+			body:     `{"name":"test","items":[{"id":1},{"id":2}]}`,
+			maxDepth: 3,
+		},
+		{
+			name:        "exceeds the depth limit",
+			body:        `{"name":"test","items":[{"id":1,"nested":{"a":[1]}}]}`,
+			maxDepth:    3,
+			expectedErr: ErrJSONTooDeep,
+		},
+		{
+			name:        "deeply nested arrays",
+			body:        strings.Repeat("[", 10000) + strings.Repeat("]", 10000),
+			maxDepth:    32,
+			expectedErr: ErrJSONTooDeep,
+		},
+		{
+			name:     "brackets inside strings are ignored",
+			body:     `{"name":"[[[{{{\"[[[","items":[]}`,
+			maxDepth: 2,
+		},
+		{
+			name:     "no depth limit",
+			body:     `{"name":"test","items":[{"id":1,"nested":{"a":[1]}}]}`,
+			maxDepth: 0,
+		},
+		{
+			name:      "invalid JSON",
+			body:      `{"name":`,
+			maxDepth:  3,
+			expectErr: true,
+		},
+		{
+			name: "unknown field is ignored by lenient decoding",
+			body: `{"name":"test","stok":5}`,
+		},
+		{
+			name:          "unknown field is rejected by strict decoding",
+			body:          `{"name":"test","stok":5}`,
+			strict:        true,
+			expectedField: "stok",
+		},
+		{
+			name:          "unknown nested field is rejected by strict decoding",
+			body:          `{"name":"test","items":[{"id":1,"qty":2}]}`,
+			strict:        true,
+			expectedField: "qty",
+		},
+		{
+			name:   "known fields pass strict decoding",
+			body:   `{"name":"test","items":[{"id":1}]}`,
+			strict: true,
+		},
+		{
+			name:      "data after the document is rejected by strict decoding",
+			body:      `{"name":"test"} {"name":"other"}`,
+			strict:    true,
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			var p payload
+
+			// when
+			err := DecodeJSON(strings.NewReader(tc.body), &p, tc.maxDepth, tc.strict)
+
+			// then
+			switch {
+			case tc.expectedErr != nil:
+				assert.ErrorIs(t, err, tc.expectedErr)
+			case tc.expectedField != "":
+				var unknownErr *UnknownFieldError
+				require.ErrorAs(t, err, &unknownErr)
+				assert.Equal(t, tc.expectedField, unknownErr.Field)
+			case tc.expectErr:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, ErrJSONTooDeep)
+			default:
+				require.NoError(t, err)
+			}
+		})
+	}
+}
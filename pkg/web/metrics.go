@@ -0,0 +1,77 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// unmatchedRoute labels the metrics of requests matching no route, so unknown paths don't grow the cardinality.
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware records the number, the latency and the in-flight count of the HTTP requests with the meter.
+// Instruments are named http_server_requests, http_server_request_duration and http_server_active_requests.
+// Completed requests are labelled with the method, the route pattern (e.g. /api/v1/products/{id}) and the status,
+// in-flight requests only with the method, since their route isn't known before routing.
+// It can wrap a chi router from the outside, the router resolves the route into the routing context provided by the middleware then.
+func MetricsMiddleware(meter metric.Meter) func(next http.Handler) http.Handler {
+	requests, err := meter.Int64Counter("http_server_requests",
+		metric.WithDescription("Total number of HTTP server requests"))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create http_server_requests counter: %v", err))
+	}
+	duration, err := meter.Float64Histogram("http_server_request_duration",
+		metric.WithDescription("Latency of HTTP server requests"),
+		metric.WithUnit("s"))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create http_server_request_duration histogram: %v", err))
+	}
+	active, err := meter.Int64UpDownCounter("http_server_active_requests",
+		metric.WithDescription("Number of in-flight HTTP server requests"))
+	if err != nil {
+		panic(fmt.Sprintf("failed to create http_server_active_requests gauge: %v", err))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rctx := chi.RouteContext(r.Context())
+			if rctx == nil {
+				rctx = chi.NewRouteContext()
+				r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ctx := r.Context()
+			methodAttr := attribute.String("http.request.method", r.Method)
+			active.Add(ctx, 1, metric.WithAttributes(methodAttr))
+			start := time.Now()
+
+			defer func() {
+				active.Add(ctx, -1, metric.WithAttributes(methodAttr))
+				status := ww.Status()
+				if status == 0 {
+					// the handler wrote nothing, net/http responds with 200
+					status = http.StatusOK
+				}
+				route := rctx.RoutePattern()
+				if route == "" {
+					route = unmatchedRoute
+				}
+				attrs := metric.WithAttributes(
+					methodAttr,
+					attribute.String("http.route", route),
+					attribute.String("http.response.status_code", strconv.Itoa(status)),
+				)
+				requests.Add(ctx, 1, attrs)
+				duration.Record(ctx, time.Since(start).Seconds(), attrs)
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
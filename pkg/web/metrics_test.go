@@ -0,0 +1,124 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	// given: a router wrapped from the outside, like the HTTP servers do
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	router := chi.NewRouter()
+	router.Get("/api/v1/products/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	router.Delete("/api/v1/products/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	})
+	handler := MetricsMiddleware(meter)(router)
+
+	// when
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil),
+		httptest.NewRequest(http.MethodGet, "/api/v1/products/2", nil),
+		httptest.NewRequest(http.MethodDelete, "/api/v1/products/1", nil),
+		httptest.NewRequest(http.MethodGet, "/unknown", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// then
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	expected := map[attribute.Set]uint64{
+		attrSet(http.MethodGet, "/api/v1/products/{id}", "200"):    2,
+		attrSet(http.MethodDelete, "/api/v1/products/{id}", "409"): 1,
+		attrSet(http.MethodGet, unmatchedRoute, "404"):             1,
+	}
+
+	requests, ok := findMetric(t, rm, "http_server_requests").Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, requests.DataPoints, len(expected))
+	for _, dp := range requests.DataPoints {
+		assert.Equal(t, int64(expected[dp.Attributes]), dp.Value, "requests of %v", dp.Attributes.ToSlice())
+	}
+
+	duration, ok := findMetric(t, rm, "http_server_request_duration").Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, duration.DataPoints, len(expected))
+	for _, dp := range duration.DataPoints {
+		assert.Equal(t, expected[dp.Attributes], dp.Count, "latencies of %v", dp.Attributes.ToSlice())
+	}
+
+	active, ok := findMetric(t, rm, "http_server_active_requests").Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.NotEmpty(t, active.DataPoints)
+	for _, dp := range active.DataPoints {
+		assert.Zero(t, dp.Value, "no request should be in flight after completion")
+	}
+}
+
+func TestMetricsMiddleware_Timeout(t *testing.T) {
+	// given: the timeout inside the metrics, like the HTTP servers do
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	router := chi.NewRouter()
+	router.Get("/api/v1/products/{id}", func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	router.Delete("/api/v1/products/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := MetricsMiddleware(meter)(TimeoutMiddleware(10 * time.Millisecond)(router))
+
+	// when
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/products/1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/v1/products/1", nil))
+
+	// then: the request which timed out is measured with the 504 of the timeout
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	expected := map[attribute.Set]int64{
+		attrSet(http.MethodGet, "/api/v1/products/{id}", "504"):    1,
+		attrSet(http.MethodDelete, "/api/v1/products/{id}", "204"): 1,
+	}
+	requests, ok := findMetric(t, rm, "http_server_requests").Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, requests.DataPoints, len(expected))
+	for _, dp := range requests.DataPoints {
+		assert.Equal(t, expected[dp.Attributes], dp.Value, "requests of %v", dp.Attributes.ToSlice())
+	}
+}
+
+func attrSet(method, route, status string) attribute.Set {
+	return attribute.NewSet(
+		attribute.String("http.request.method", method),
+		attribute.String("http.route", route),
+		attribute.String("http.response.status_code", status),
+	)
+}
+
+// findMetric returns the metric with the given name, failing the test if there is none.
+func findMetric(t *testing.T, rm metricdata.ResourceMetrics, name string) metricdata.Metrics {
+	t.Helper()
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return metricdata.Metrics{}
+}
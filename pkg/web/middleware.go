@@ -2,8 +2,13 @@ package web
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -13,6 +18,18 @@ import (
 )
 
 const XUserId = "X-User-Id"
+const XUserEmailVerified = "X-User-Email-Verified"
+const XUserEmail = "X-User-Email"
+
+// XUserRoles holds the comma-separated roles of the authenticated user.
+const XUserRoles = "X-User-Roles"
+
+// XOrgId holds the organization the request is made on behalf of.
+// The API gateway sets it from the verified token and drops the header sent by the client, like the X-User-* headers.
+const XOrgId = "X-Org-Id"
+
+// RoleAdmin is the role of the support and admin staff.
+const RoleAdmin = "admin"
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -23,36 +40,54 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Create a new context with the user ID
-		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		SetAccessLogUserID(r.Context(), userID)
+		// Create a new context with the user
+		ctx := WithUser(r.Context(), User{ID: userID, Roles: parseRoles(r.Header.Get(XUserRoles))})
+		// A missing or malformed email verification header is treated as unverified
+		emailVerified, _ := strconv.ParseBool(r.Header.Get(XUserEmailVerified))
+		ctx = context.WithValue(ctx, EmailVerifiedKey, emailVerified)
+		ctx = context.WithValue(ctx, UserEmailKey, r.Header.Get(XUserEmail))
 
 		// Pass the new context to the next handler
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// StructuredLogger creates a middleware that logs HTTP requests in a structured format.
-func StructuredLogger(logger *slog.Logger) func(next http.Handler) http.Handler {
+// RequireRole rejects requests of users without the given role with 403 Forbidden.
+// It must be used after AuthMiddleware, which stores the roles of the user in the request context.
+func RequireRole(role string) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		fn := func(w http.ResponseWriter, r *http.Request) {
-			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-			start := time.Now()
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasRole(r.Context(), role) {
+				RespondError(w, slog.Default(), http.StatusForbidden, "Access denied: missing role "+role)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-			defer func() {
-				logger.InfoContext(r.Context(), "Request completed",
-					"method", r.Method,
-					"path", r.URL.Path,
-					"status", ww.Status(),
-					"bytes_written", ww.BytesWritten(),
-					"duration_ms", float64(time.Since(start).Nanoseconds())/1e6,
-					"remote_addr", r.RemoteAddr,
-					"user_agent", r.UserAgent(),
-				)
-			}()
-			next.ServeHTTP(ww, r)
+// parseRoles splits the comma-separated roles, ignoring empty entries.
+func parseRoles(header string) []string {
+	var roles []string
+	for _, role := range strings.Split(header, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
 		}
-		return http.HandlerFunc(fn)
 	}
+	return roles
+}
+
+// IdentityMiddleware stores the user ID from the X-User-Id header and the roles from the X-User-Roles header
+// in the request context when present. Unlike AuthMiddleware, it does not reject anonymous requests.
+func IdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID := r.Header.Get(XUserId); userID != "" {
+			SetAccessLogUserID(r.Context(), userID)
+			r = r.WithContext(WithUser(r.Context(), User{ID: userID, Roles: parseRoles(r.Header.Get(XUserRoles))}))
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 // Recoverer is a middleware that recovers from panics and logs them using the provided logger.
@@ -94,3 +129,111 @@ func TelemetryEnricher(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// TimeoutMiddleware bounds every request with a context deadline of the given duration.
+// The deadline is propagated through r.Context() to downstream calls (pgx, gRPC), so slow work is cancelled.
+// If the handler has not written a response when the deadline fires, 504 Gateway Timeout is returned,
+// and any later writes from the handler are discarded.
+// The handler is routed on a copy of the chi routing context of an outer middleware, e.g. MetricsMiddleware,
+// which gets the route pattern once the handler returned, or from the router next when the deadline fires.
+func TimeoutMiddleware(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			// the handler goroutine may outlive the request, so it mustn't write the routing context read by the outer middlewares
+			rctx := chi.RouteContext(r.Context())
+			var routed *chi.Context
+			if rctx != nil {
+				routed = chi.NewRouteContext()
+				*routed = *rctx
+				routed.RoutePatterns = slices.Clone(rctx.RoutePatterns)
+				ctx = context.WithValue(ctx, chi.RouteCtxKey, routed)
+			}
+
+			tw := &timeoutWriter{w: w, h: make(http.Header), ctx: ctx}
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				// re-panic in the serving goroutine so that the outer Recoverer can handle it
+				panic(p)
+			case <-done:
+				if rctx != nil {
+					rctx.RoutePatterns = routed.RoutePatterns
+				}
+			case <-ctx.Done():
+				if routes, ok := next.(chi.Routes); ok && rctx != nil {
+					if pattern := routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path); pattern != "" {
+						rctx.RoutePatterns = append(rctx.RoutePatterns, pattern)
+					}
+				}
+			}
+
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			if !tw.wroteHeader && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				RespondError(w, slog.Default(), http.StatusGatewayTimeout, "Request timed out")
+			}
+			tw.timedOut = true
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// timeoutWriter guards the underlying ResponseWriter, so that the handler goroutine
+// can't write to it once the request deadline has been exceeded.
+type timeoutWriter struct {
+	w           http.ResponseWriter
+	h           http.Header
+	ctx         context.Context
+	mu          sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader || tw.ctx.Err() != nil {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.ctx.Err() != nil {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.w.Write(p)
+}
+
+// writeHeaderLocked copies the buffered headers to the underlying writer and sends the status code.
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	dst := tw.w.Header()
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.wroteHeader = true
+	tw.w.WriteHeader(code)
+}
@@ -0,0 +1,216 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	testCases := []struct {
+		name           string
+		handler        http.HandlerFunc
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "fast handler",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(`{"status":"ok"}`))
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"ok"}`,
+		},
+		{
+			name: "slow handler respecting context",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+					// simulate a downstream call (pgx, gRPC) which failed due to the cancelled context
+					w.WriteHeader(http.StatusInternalServerError)
+				case <-time.After(time.Second):
+					w.WriteHeader(http.StatusOK)
+				}
+			},
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedBody:   `{"error":"Request timed out","code":"TIMEOUT"}`,
+		},
+		{
+			name: "slow handler ignoring context",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+				w.WriteHeader(http.StatusOK)
+			},
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedBody:   `{"error":"Request timed out","code":"TIMEOUT"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			handler := TimeoutMiddleware(50 * time.Millisecond)(tc.handler)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rr := httptest.NewRecorder()
+
+			// when
+			start := time.Now()
+			handler.ServeHTTP(rr, req)
+
+			// then
+			assert.Less(t, time.Since(start), 150*time.Millisecond, "middleware should not wait for the slow handler")
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+}
+
+func TestTimeoutMiddleware_DeadlinePropagated(t *testing.T) {
+	// given
+	var deadline time.Time
+	var ok bool
+	handler := TimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+		RespondJSON(w, nil, http.StatusOK, map[string]string{"status": "ok"})
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+
+	// when
+	handler.ServeHTTP(rr, req)
+
+	// then
+	require.True(t, ok, "request context should have a deadline")
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, "ok", body["status"])
+}
+
+func TestRequireRole(t *testing.T) {
+	testCases := []struct {
+		name           string
+		rolesHeader    string
+		expectedStatus int
+	}{
+		{
+			name:           "user with the role",
+			rolesHeader:    "user,admin",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "roles with spaces",
+			rolesHeader:    " user , admin ",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "user without the role",
+			rolesHeader:    "user",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "role is a substring of another role",
+			rolesHeader:    "administrator",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "no roles",
+			rolesHeader:    "",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := AuthMiddleware(RequireRole(RoleAdmin)(next))
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			req.Header.Set(XUserId, "user-123")
+			if tc.rolesHeader != "" {
+				req.Header.Set(XUserRoles, tc.rolesHeader)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedStatus, rr.Code)
+		})
+	}
+}
+
+func TestHasRole_NoRolesInContext(t *testing.T) {
+	assert.False(t, HasRole(context.Background(), RoleAdmin))
+}
+
+func TestIdentityMiddleware(t *testing.T) {
+	testCases := []struct {
+		name           string
+		userID         string
+		rolesHeader    string
+		expectedUserID string
+		expectedAdmin  bool
+	}{
+		{
+			name:           "staff member",
+			userID:         "user-123",
+			rolesHeader:    "user,admin",
+			expectedUserID: "user-123",
+			expectedAdmin:  true,
+		},
+		{
+			name:           "customer",
+			userID:         "user-123",
+			rolesHeader:    "user",
+			expectedUserID: "user-123",
+		},
+		{
+			name: "anonymous caller",
+		},
+		{
+			name:        "roles without user ID are ignored",
+			rolesHeader: "admin",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			var userID string
+			var admin bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID = GetUserIDString(r.Context())
+				admin = HasRole(r.Context(), RoleAdmin)
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/products", nil)
+			if tc.userID != "" {
+				req.Header.Set(XUserId, tc.userID)
+			}
+			if tc.rolesHeader != "" {
+				req.Header.Set(XUserRoles, tc.rolesHeader)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			IdentityMiddleware(next).ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, tc.expectedUserID, userID)
+			assert.Equal(t, tc.expectedAdmin, admin)
+		})
+	}
+}
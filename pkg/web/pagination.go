@@ -0,0 +1,82 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// XMaxPageSize reports the max page size of the list endpoints.
+const XMaxPageSize = "X-Max-Page-Size"
+
+// limitParam is the query parameter of the page size of the list endpoints.
+const limitParam = "limit"
+
+// PageSizeMiddleware limits the page size the list endpoints are requested with by the limit query parameter.
+// A limit above maxPageSize is clamped to it, or rejected with 400 Bad Request if reject is set.
+// The responses to the requests with a limit report maxPageSize in the X-Max-Page-Size header.
+// A malformed limit is passed through, so the handlers report it.
+func PageSizeMiddleware(maxPageSize int, reject bool, logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			value := query.Get(limitParam)
+			if value == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set(XMaxPageSize, strconv.Itoa(maxPageSize))
+			limit, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || limit <= int64(maxPageSize) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if reject {
+				RespondError(w, logger, http.StatusBadRequest, fmt.Sprintf("Invalid %s number: %s, must not exceed %d", limitParam, value, maxPageSize))
+				return
+			}
+			query.Set(limitParam, strconv.Itoa(maxPageSize))
+			u := *r.URL
+			u.RawQuery = query.Encode()
+			r.URL = &u
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Pagination holds the offset pagination parameters of a list request.
+type Pagination struct {
+	Offset int32
+	Limit  int32
+}
+
+// ParsePagination parses the limit and offset query parameters of a list request.
+// The limit must be greater than zero and not exceed maxLimit, the offset must not be negative.
+// A maxLimit of zero doesn't bound the limit, e.g. if it's bounded by PageSizeMiddleware.
+// Responds with 400 Bad Request and returns false if a parameter is missing or invalid.
+func ParsePagination(r *http.Request, w http.ResponseWriter, logger *slog.Logger, maxLimit int32) (Pagination, bool) {
+	limit, ok := ParseLimit(r, w, logger, maxLimit)
+	if !ok {
+		return Pagination{}, false
+	}
+	offset, ok := ParseValidateGte(r, w, logger, "offset", 0)
+	if !ok {
+		return Pagination{}, false
+	}
+	return Pagination{Offset: offset, Limit: limit}, true
+}
+
+// ParseLimit parses the limit query parameter of a list request, see ParsePagination.
+// It's used on its own by the list endpoints which don't paginate by offset.
+func ParseLimit(r *http.Request, w http.ResponseWriter, logger *slog.Logger, maxLimit int32) (int32, bool) {
+	limit, ok := ParseValidateGt(r, w, logger, limitParam, 0)
+	if !ok {
+		return 0, false
+	}
+	if maxLimit > 0 && limit > maxLimit {
+		RespondError(w, logger, http.StatusBadRequest, fmt.Sprintf("Invalid %s number: %d, must not exceed %d", limitParam, limit, maxLimit))
+		return 0, false
+	}
+	return limit, true
+}
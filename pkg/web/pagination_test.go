@@ -0,0 +1,196 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageSizeMiddleware(t *testing.T) {
+	const maxPageSize = 100
+	testCases := []struct {
+		name           string
+		reject         bool
+		query          string
+		expectedCode   int
+		expectedLimit  string
+		expectedHeader string
+	}{
+		{
+			name:           "limit below the max is passed",
+			query:          "?limit=99&offset=0",
+			expectedCode:   http.StatusOK,
+			expectedLimit:  "99",
+			expectedHeader: "100",
+		},
+		{
+			name:           "limit at the max is passed",
+			query:          "?limit=100&offset=0",
+			expectedCode:   http.StatusOK,
+			expectedLimit:  "100",
+			expectedHeader: "100",
+		},
+		{
+			name:           "limit above the max is clamped",
+			query:          "?limit=101&offset=0",
+			expectedCode:   http.StatusOK,
+			expectedLimit:  "100",
+			expectedHeader: "100",
+		},
+		{
+			name:           "limit at the max is passed when rejecting",
+			reject:         true,
+			query:          "?limit=100&offset=0",
+			expectedCode:   http.StatusOK,
+			expectedLimit:  "100",
+			expectedHeader: "100",
+		},
+		{
+			name:           "limit above the max is rejected",
+			reject:         true,
+			query:          "?limit=101&offset=0",
+			expectedCode:   http.StatusBadRequest,
+			expectedHeader: "100",
+		},
+		{
+			name:           "malformed limit is passed to the handler",
+			query:          "?limit=many",
+			expectedCode:   http.StatusOK,
+			expectedLimit:  "many",
+			expectedHeader: "100",
+		},
+		{
+			name:         "request without limit has no header",
+			query:        "?offset=0",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			var limit string
+			handler := PageSizeMiddleware(maxPageSize, tc.reject, slog.New(slog.NewTextHandler(io.Discard, nil)))(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					limit = r.URL.Query().Get("limit")
+					w.WriteHeader(http.StatusOK)
+				}))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/products"+tc.query, nil)
+			rr := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			assert.Equal(t, tc.expectedLimit, limit)
+			assert.Equal(t, tc.expectedHeader, rr.Header().Get(XMaxPageSize))
+		})
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	const maxLimit = 100
+	testCases := []struct {
+		name          string
+		query         string
+		maxLimit      int32
+		expected      Pagination
+		expectedOK    bool
+		expectedError string
+	}{
+		{
+			name:       "valid limit and offset",
+			query:      "?limit=10&offset=20",
+			maxLimit:   maxLimit,
+			expected:   Pagination{Offset: 20, Limit: 10},
+			expectedOK: true,
+		},
+		{
+			name:       "limit at the max",
+			query:      "?limit=100&offset=0",
+			maxLimit:   maxLimit,
+			expected:   Pagination{Offset: 0, Limit: 100},
+			expectedOK: true,
+		},
+		{
+			name:       "limit without max",
+			query:      "?limit=100000&offset=0",
+			expected:   Pagination{Offset: 0, Limit: 100000},
+			expectedOK: true,
+		},
+		{
+			name:          "missing limit",
+			query:         "?offset=0",
+			maxLimit:      maxLimit,
+			expectedError: `{"error":"limit url parameter is required","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:          "missing offset",
+			query:         "?limit=10",
+			maxLimit:      maxLimit,
+			expectedError: `{"error":"offset url parameter is required","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:          "non-numeric limit",
+			query:         "?limit=ten&offset=0",
+			maxLimit:      maxLimit,
+			expectedError: `{"error":"Invalid limit number: ten","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:          "non-numeric offset",
+			query:         "?limit=10&offset=not-a-number",
+			maxLimit:      maxLimit,
+			expectedError: `{"error":"Invalid offset number: not-a-number","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:          "zero limit",
+			query:         "?limit=0&offset=0",
+			maxLimit:      maxLimit,
+			expectedError: `{"error":"Invalid limit number: 0","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:          "negative offset",
+			query:         "?limit=10&offset=-1",
+			maxLimit:      maxLimit,
+			expectedError: `{"error":"Invalid offset number: -1","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:          "limit above the max",
+			query:         "?limit=101&offset=0",
+			maxLimit:      maxLimit,
+			expectedError: `{"error":"Invalid limit number: 101, must not exceed 100","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:          "limit out of the int32 range",
+			query:         "?limit=2147483648&offset=0",
+			expectedError: `{"error":"Invalid limit number: 2147483648","code":"BAD_REQUEST"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/products"+tc.query, nil)
+			rr := httptest.NewRecorder()
+
+			// when
+			page, ok := ParsePagination(req, rr, slog.New(slog.NewTextHandler(io.Discard, nil)), tc.maxLimit)
+
+			// then
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expected, page)
+			if tc.expectedOK {
+				assert.Equal(t, http.StatusOK, rr.Code, "nothing should be written on success")
+				assert.Empty(t, rr.Body.String())
+				return
+			}
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.JSONEq(t, tc.expectedError, rr.Body.String())
+		})
+	}
+}
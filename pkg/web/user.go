@@ -0,0 +1,35 @@
+package web
+
+import (
+	"context"
+	"slices"
+)
+
+// User is the authenticated user of a request, as forwarded by the gateway.
+type User struct {
+	ID    string
+	Roles []string
+}
+
+// HasRole reports whether the user has the given role.
+func (u User) HasRole(role string) bool {
+	return slices.Contains(u.Roles, role)
+}
+
+// WithUser returns a copy of ctx carrying the user, see UserFromContext.
+// The user is stored under UserIDKey and UserRolesKey, so GetUserID, GetUserIDString and HasRole read it as well.
+func WithUser(ctx context.Context, user User) context.Context {
+	ctx = context.WithValue(ctx, UserIDKey, user.ID)
+	return context.WithValue(ctx, UserRolesKey, user.Roles)
+}
+
+// UserFromContext returns the user stored by WithUser or by the auth middlewares,
+// or false if the request is anonymous.
+func UserFromContext(ctx context.Context) (User, bool) {
+	id, _ := ctx.Value(UserIDKey).(string)
+	if id == "" {
+		return User{}, false
+	}
+	roles, _ := ctx.Value(UserRolesKey).([]string)
+	return User{ID: id, Roles: roles}, true
+}
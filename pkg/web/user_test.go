@@ -0,0 +1,76 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithUser(t *testing.T) {
+	testCases := []struct {
+		name  string
+		user  User
+		admin bool
+	}{
+		{name: "staff member", user: User{ID: "user-123", Roles: []string{"user", RoleAdmin}}, admin: true},
+		{name: "customer", user: User{ID: "user-123", Roles: []string{"user"}}},
+		{name: "user without roles", user: User{ID: "user-123"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// when
+			ctx := WithUser(context.Background(), tc.user)
+
+			// then
+			user, ok := UserFromContext(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, tc.user, user)
+			assert.Equal(t, tc.admin, user.HasRole(RoleAdmin))
+			assert.Equal(t, tc.admin, HasRole(ctx, RoleAdmin), "the roles should be read by HasRole")
+			assert.Equal(t, tc.user.ID, GetUserIDString(ctx), "the ID should be read by GetUserIDString")
+		})
+	}
+}
+
+func TestUserFromContext_Anonymous(t *testing.T) {
+	testCases := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{name: "no user", ctx: context.Background()},
+		{name: "empty user ID", ctx: WithUser(context.Background(), User{Roles: []string{RoleAdmin}})},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// when
+			user, ok := UserFromContext(tc.ctx)
+
+			// then
+			assert.False(t, ok)
+			assert.Equal(t, User{}, user)
+			assert.False(t, HasRole(tc.ctx, RoleAdmin), "the roles of an anonymous request should be ignored")
+		})
+	}
+}
+
+func TestUserFromContext_AuthMiddleware(t *testing.T) {
+	// given
+	var user User
+	var ok bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok = UserFromContext(r.Context())
+	})
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(XUserId, "user-123")
+	req.Header.Set(XUserRoles, "user, admin")
+
+	// when
+	AuthMiddleware(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	// then
+	assert.True(t, ok)
+	assert.Equal(t, User{ID: "user-123", Roles: []string{"user", RoleAdmin}}, user)
+}
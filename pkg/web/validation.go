@@ -0,0 +1,89 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrInvalidBody is returned when the request body can't be decoded.
+var ErrInvalidBody = errors.New("invalid request body")
+
+// ValidationError is returned when the request body fails validation.
+// Fields maps the name of every invalid field to the rule it failed on.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field, rule := range e.Fields {
+		fields = append(fields, field+" "+rule)
+	}
+	return "validation failed: " + strings.Join(fields, ", ")
+}
+
+// DecodeAndValidate decodes the JSON request body into a T and validates it.
+// If strict is set, a field T doesn't have is rejected, see DecodeJSON.
+// Returns an error wrapping ErrInvalidBody if the body can't be decoded,
+// or a *ValidationError with the failed rule of every invalid field.
+func DecodeAndValidate[T any](r *http.Request, validate *validator.Validate, strict bool) (T, error) {
+	var v T
+	if err := DecodeJSON(r.Body, &v, 0, strict); err != nil {
+		return v, fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+	return v, Validate(validate, v)
+}
+
+// Validate validates v and returns a *ValidationError with the failed rule of every invalid field.
+// Errors other than field validation errors, e.g. v not being a struct, are wrapped in ErrInvalidBody.
+func Validate(validate *validator.Validate, v any) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return fmt.Errorf("%w: %w", ErrInvalidBody, err)
+	}
+	fields := make(map[string]string, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		// fieldErr.Tag() returns "required", "max", etc.
+		fields[fieldErr.Field()] = "failed on rule: " + fieldErr.Tag()
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// RespondValidationError responds with 400 to an error returned by DecodeAndValidate or Validate.
+// Validation errors are reported field by field with the VALIDATION_FAILED code,
+// a body exceeding the limit of MaxBodySizeMiddleware with 413, an unknown field of strict decoding by its name,
+// and any other error as an invalid request body.
+func RespondValidationError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) {
+	if RespondBodyTooLarge(w, r, logger, err) || RespondUnknownField(w, r, logger, err) {
+		return
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		logger.WarnContext(r.Context(), "Validation errors occurred", "errors", validationErr.Fields)
+		RespondJSON(w, logger, http.StatusBadRequest, map[string]any{"validation_errors": validationErr.Fields, "code": CodeValidationFailed})
+		return
+	}
+	logger.ErrorContext(r.Context(), "Error decoding request body", "error", err)
+	RespondError(w, logger, http.StatusBadRequest, "Invalid request body")
+}
+
+// RespondUnknownField responds with 400 naming the field if err is caused by an unknown field of strict decoding.
+// Returns false without responding if it's not.
+func RespondUnknownField(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) bool {
+	var unknownErr *UnknownFieldError
+	if !errors.As(err, &unknownErr) {
+		return false
+	}
+	logger.WarnContext(r.Context(), "Request body has an unknown field", "field", unknownErr.Field)
+	RespondError(w, logger, http.StatusBadRequest, fmt.Sprintf("Unknown field in request body: %q", unknownErr.Field))
+	return true
+}
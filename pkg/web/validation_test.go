@@ -0,0 +1,149 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeAndValidate(t *testing.T) {
+	type payload struct {
+		Name  string `json:"name" validate:"required,min=3"`
+		Price int64  `json:"price" validate:"gt=0"`
+	}
+
+	testCases := []struct {
+		name           string
+		body           string
+		strict         bool
+		expected       payload
+		expectedFields map[string]string
+		expectedErr    error
+	}{
+		{
+			name:     "valid body",
+			body:     `{"name":"test","price":100}`,
+			expected: payload{Name: "test", Price: 100},
+		},
+		{
+			name:        "invalid JSON",
+			body:        `{"name":`,
+			expectedErr: ErrInvalidBody,
+		},
+		{
+			name:        "wrong field type",
+			body:        `{"name":"test","price":"100"}`,
+			expectedErr: ErrInvalidBody,
+		},
+		{
+			name: "validation failure",
+			body: `{"name":"ab","price":0}`,
+			expectedFields: map[string]string{
+				"Name":  "failed on rule: min",
+				"Price": "failed on rule: gt",
+			},
+		},
+		{
+			name:           "missing required field",
+			body:           `{"price":100}`,
+			expectedFields: map[string]string{"Name": "failed on rule: required"},
+		},
+		{
+			name:     "unknown field of lenient decoding",
+			body:     `{"name":"test","price":100,"stok":5}`,
+			expected: payload{Name: "test", Price: 100},
+		},
+		{
+			name:        "unknown field of strict decoding",
+			body:        `{"name":"test","price":100,"stok":5}`,
+			strict:      true,
+			expectedErr: ErrInvalidBody,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+
+			// when
+			got, err := DecodeAndValidate[payload](r, validator.New(), tc.strict)
+
+			// then
+			switch {
+			case tc.expectedErr != nil:
+				assert.ErrorIs(t, err, tc.expectedErr)
+			case tc.expectedFields != nil:
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, tc.expectedFields, validationErr.Fields)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidate_NotAStruct(t *testing.T) {
+	// when
+	err := Validate(validator.New(), "not a struct")
+
+	// then
+	assert.ErrorIs(t, err, ErrInvalidBody)
+	var validationErr *ValidationError
+	assert.NotErrorAs(t, err, &validationErr)
+}
+
+func TestRespondValidationError(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name         string
+		err          error
+		expectedBody map[string]any
+	}{
+		{
+			name: "validation error",
+			err:  &ValidationError{Fields: map[string]string{"Name": "failed on rule: required"}},
+			expectedBody: map[string]any{
+				"validation_errors": map[string]any{"Name": "failed on rule: required"},
+				"code":              CodeValidationFailed,
+			},
+		},
+		{
+			name:         "decode error",
+			err:          ErrInvalidBody,
+			expectedBody: map[string]any{"error": "Invalid request body", "code": CodeBadRequest},
+		},
+		{
+			name:         "unknown field",
+			err:          fmt.Errorf("%w: %w", ErrInvalidBody, &UnknownFieldError{Field: "stok"}),
+			expectedBody: map[string]any{"error": `Unknown field in request body: "stok"`, "code": CodeBadRequest},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+
+			// when
+			RespondValidationError(rr, r, logger, tc.err)
+
+			// then
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+			assert.Equal(t, tc.expectedBody, body)
+		})
+	}
+}
@@ -3,24 +3,24 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"log/slog"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/abgdnv/gocommerce/pkg/bootstrap"
+	"github.com/abgdnv/gocommerce/pkg/bootstrap/migration"
 	"github.com/abgdnv/gocommerce/pkg/config/configloader"
+	"github.com/abgdnv/gocommerce/pkg/nats"
+	"github.com/abgdnv/gocommerce/pkg/server"
 	"github.com/abgdnv/gocommerce/pkg/telemetry"
 	"github.com/abgdnv/gocommerce/product_service/internal/app"
 	"github.com/abgdnv/gocommerce/product_service/internal/config"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"golang.org/x/sync/errgroup"
+	"github.com/nats-io/nats.go/jetstream"
 	"google.golang.org/grpc"
 )
 
@@ -44,10 +44,9 @@ func run(ctx context.Context) error {
 	if cfgErr != nil {
 		return fmt.Errorf("failed to load configuration: %w", cfgErr)
 	}
-	log.Printf("Configuration loaded: %v", cfg)
-
-	logger := bootstrap.NewLogger(cfg.Log.Level)
+	logger := bootstrap.NewLogger(cfg.Log.Level, cfg.Log.Format, cfg.Log.AddSource)
 	slog.SetDefault(logger)
+	bootstrap.LogEffectiveConfig(logger, cfg)
 
 	// create tracer provider
 	tracerProvider, err := telemetry.NewTracerProvider(ctx, serviceName, cfg.Telemetry)
@@ -56,107 +55,81 @@ func run(ctx context.Context) error {
 		return err
 	}
 
-	dbPool, err := bootstrap.NewDbPool(ctx, cfg.Database.URI(), cfg.Database.Timeout)
+	if cfg.Database.AutoMigrate {
+		if err := migration.Up(ctx, cfg.Database, logger); err != nil {
+			return fmt.Errorf("failed to migrate the database: %w", err)
+		}
+	}
+
+	dbPool, err := bootstrap.NewDbPool(ctx, cfg.Database.URI(), cfg.Database.Timeout, cfg.Database.Pool)
 	if err != nil {
 		return fmt.Errorf("failed to create database connection pool: %w", err)
 	}
 	defer dbPool.Close()
 	logger.Info("Successfully connected to the database!")
 
-	httpServer, pprofServer, grpcServer := setupServers(dbPool, logger, cfg)
-
-	g, gCtx := errgroup.WithContext(ctx)
-
-	// Start the HTTP server
-	g.Go(func() error {
-		logger.Info("HTTP server listening", slog.String("addr", httpServer.Addr))
-		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			return fmt.Errorf("http server failed: %w", err)
-		}
-		return nil
-	})
-	// gracefully shutdown HTTP server on context cancellation
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down HTTP server...")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-		defer cancel()
-		return httpServer.Shutdown(shutdownCtx)
-	})
+	// components are shut down in reverse order: servers first, tracer provider last
+	components := []bootstrap.Component{
+		&bootstrap.FuncComponent{
+			ComponentName: "tracer provider",
+			ShutdownFn:    tracerProvider.Shutdown,
+		},
+	}
 
-	// Start the gRPC server
-	g.Go(func() error {
-		grpcAddr := ":" + cfg.GRPC.Port
-		lis, err := net.Listen("tcp", grpcAddr)
+	// NATS is only needed to publish audit events
+	var js jetstream.JetStream
+	if cfg.Audit.Enabled {
+		natsConn, err := nats.NewClient(ctx, cfg.Nats, logger)
 		if err != nil {
-			return fmt.Errorf("failed to listen on gRPC port: %w", err)
+			return fmt.Errorf("failed to create NATS connection: %w", err)
 		}
-		logger.Info("gRPC server listening", slog.String("addr", grpcAddr))
-		return grpcServer.Serve(lis)
-	})
-	// gracefully shutdown gRPC server on context cancellation
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down gRPC server...")
-		stopped := make(chan struct{})
-		go func() {
-			grpcServer.GracefulStop()
-			close(stopped)
-		}()
-		select {
-		case <-stopped:
-			logger.Info("gRPC server stopped gracefully.")
-			return nil
-		case <-time.After(cfg.Shutdown.Timeout):
-			logger.Warn("gRPC server graceful stop timed out. Forcing stop.")
-			grpcServer.Stop()
-			return fmt.Errorf("grpc server graceful stop timed out")
+		js, err = nats.NewJetStreamContext(natsConn)
+		if err != nil {
+			return fmt.Errorf("failed to get JetStream context: %w", err)
 		}
-	})
+		components = append(components, &bootstrap.FuncComponent{
+			ComponentName: "NATS connection",
+			ShutdownFn: func(_ context.Context) error {
+				return natsConn.Drain()
+			},
+		})
+	}
+
+	httpServer, pprofServer, grpcServer, err := setupServers(dbPool, js, logger, cfg)
+	if err != nil {
+		return err
+	}
+	drainer := server.NewDrainer(cfg.Shutdown.DrainDelay, logger)
+	httpServer.Handler = drainer.Middleware(httpServer.Handler)
 
-	// Start the pprof server if enabled
+	components = append(components,
+		bootstrap.NewHTTPServerComponent("HTTP server", httpServer, logger),
+		bootstrap.NewGRPCServerComponent("gRPC server", grpcServer, ":"+cfg.GRPC.Port, logger),
+	)
 	if cfg.PProf.Enabled {
-		g.Go(func() error {
-			logger.Info("Pprof server listening", slog.String("addr", pprofServer.Addr))
-			if err := pprofServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				return fmt.Errorf("pprof server failed: %w", err)
-			}
-			return nil
-		})
-		// gracefully shutdown pprof server on context cancellation
-		g.Go(func() error {
-			<-gCtx.Done()
-			logger.Info("Shutting down pprof server...")
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-			defer cancel()
-			return pprofServer.Shutdown(shutdownCtx)
-		})
+		components = append(components, bootstrap.NewHTTPServerComponent("pprof server", pprofServer, logger))
 	}
-	// gracefully shutdown tracer provider
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down tracer provider")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-		defer cancel()
-		if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
-			return fmt.Errorf("failed to shutdown tracer provider: %v", err)
-		}
-		return nil
+
+	// the drainer is shut down first, so the readiness probe fails while the servers still serve requests
+	components = append(components, &bootstrap.FuncComponent{
+		ComponentName: "drainer",
+		ShutdownFn:    drainer.Drain,
 	})
 
-	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
-		return fmt.Errorf("errgroup encountered an error: %w", err)
+	if err := bootstrap.RunServers(ctx, components, cfg.Shutdown.Timeout, logger); err != nil {
+		return fmt.Errorf("failed to run servers: %w", err)
 	}
 	return nil
 }
 
 // setupServers initializes the HTTP, pprof, and gRPC servers with the provided database pool, logger, and configuration.
-func setupServers(dbPool *pgxpool.Pool, logger *slog.Logger, cfg *config.Config) (*http.Server, *http.Server, *grpc.Server) {
-	deps := app.SetupDependencies(dbPool, logger)
+func setupServers(dbPool *pgxpool.Pool, js jetstream.JetStream, logger *slog.Logger, cfg *config.Config) (*http.Server, *http.Server, *grpc.Server, error) {
+	deps := app.SetupDependencies(dbPool, cfg.Database.QueryTimeout, cfg.Database.SlowQueryThreshold, js, cfg.Products, cfg.Audit, logger)
 	httpServer := app.SetupHttpServer(deps, cfg)
-	grpcServer := app.SetupGrpcServer(deps, cfg.GRPC.ReflectionEnabled)
-	pprofServer := &http.Server{
-		Addr: cfg.PProf.Addr,
+	grpcServer, err := app.SetupGrpcServer(deps, cfg.GRPC)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create gRPC server: %w", err)
 	}
-	return httpServer, pprofServer, grpcServer
+	pprofServer := server.NewPProfServer(cfg.PProf)
+	return httpServer, pprofServer, grpcServer, nil
 }
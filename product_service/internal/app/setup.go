@@ -4,9 +4,16 @@ package app
 import (
 	"log/slog"
 	"net/http"
+	"time"
 
 	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/product/v1"
+	"github.com/abgdnv/gocommerce/pkg/audit"
+	pconfig "github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/health"
+	"github.com/abgdnv/gocommerce/pkg/nats"
 	"github.com/abgdnv/gocommerce/pkg/server"
+	"github.com/abgdnv/gocommerce/pkg/telemetry"
+	"github.com/abgdnv/gocommerce/pkg/web"
 	"github.com/abgdnv/gocommerce/product_service/internal/config"
 	"github.com/abgdnv/gocommerce/product_service/internal/service"
 	"github.com/abgdnv/gocommerce/product_service/internal/store"
@@ -14,50 +21,86 @@ import (
 	"github.com/abgdnv/gocommerce/product_service/internal/transport/rest"
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.opentelemetry.io/otel"
 	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type Dependencies struct {
 	ProductService service.ProductService
+	ProductsConfig config.ProductsConfig
+	Health         *health.Handler
 	Logger         *slog.Logger
 }
 
-func SetupDependencies(dbPool *pgxpool.Pool, logger *slog.Logger) *Dependencies {
-	pService := service.NewService(store.NewPgStore(dbPool))
+// SetupDependencies wires the ProductService dependencies.
+// Every database query is bounded by queryTimeout, zero disables the timeout.
+// The queries slower than slowQueryThreshold are logged at warn, zero disables the log.
+// js is only used to publish audit events and may be nil when auditing is disabled.
+func SetupDependencies(dbPool *pgxpool.Pool, queryTimeout, slowQueryThreshold time.Duration, js jetstream.JetStream, productsCfg config.ProductsConfig, auditCfg pconfig.AuditConfig, logger *slog.Logger) *Dependencies {
+	checks := map[string]health.Check{
+		"database": health.PgxPool(dbPool),
+	}
+	var auditor audit.Recorder = audit.NoopRecorder{}
+	if auditCfg.Enabled {
+		auditor = audit.NewPublishingRecorder(nats.NewNatsPublisher(js), logger)
+		checks["nats"] = health.NATSConn(js.Conn())
+	}
+	pService := service.NewService(store.NewPgStore(dbPool, queryTimeout, telemetry.NewSlowQueryLogger(slowQueryThreshold, logger), productsCfg.UniqueNames), auditor, productsCfg)
+	healthHandler := health.NewHandler(checks, logger)
 
 	return &Dependencies{
 		ProductService: pService,
+		ProductsConfig: productsCfg,
+		Health:         healthHandler,
 		Logger:         logger,
 	}
 }
 
 // SetupHttpHandler initializes the HTTP server and routes for the ProductService application.
 // Used by E2E tests to set up the HTTP server with the necessary routes and middleware.
-func SetupHttpHandler(deps *Dependencies) http.Handler {
-	mux := server.NewChiRouter(deps.Logger)
-	wireRoutes(mux, deps)
+// Trailing slashes are handled according to the trailingSlash mode of the HTTP server configuration.
+// The product routes are registered below basePath, e.g. /api/v1, the probes at the root.
+func SetupHttpHandler(deps *Dependencies, trailingSlash, basePath string) http.Handler {
+	mux := server.NewChiRouter(deps.Logger, trailingSlash)
+	wireRoutes(mux, deps, basePath)
 	return mux
 }
 
 // wireRoutes sets up the HTTP routes for the ProductService application.
-func wireRoutes(mux *chi.Mux, deps *Dependencies) {
-	productHandler := rest.NewHandler(deps.ProductService, deps.Logger)
+func wireRoutes(mux *chi.Mux, deps *Dependencies, basePath string) {
+	productHandler := rest.NewHandler(deps.ProductService, deps.ProductsConfig, basePath, deps.Logger)
 	productHandler.RegisterRoutes(mux)
+	deps.Health.RegisterRoutes(mux)
 }
 
 // SetupHttpServer creates and configures an HTTP server for the ProductService application.
+// The requests are measured per route with web.MetricsMiddleware, including the 504 of the requests which timed out.
+// Requests of hosts not in the allowed hosts of the configuration are rejected before any other middleware.
 func SetupHttpServer(deps *Dependencies, cfg *config.Config) *http.Server {
-	mux := SetupHttpHandler(deps)
-	return server.NewHTTPServer(cfg.HTTPServer, mux)
+	handler := SetupHttpHandler(deps, cfg.HTTPServer.TrailingSlash, cfg.HTTPServer.BasePath)
+	if cfg.HTTPServer.Timeout.Handler > 0 {
+		handler = web.TimeoutMiddleware(cfg.HTTPServer.Timeout.Handler)(handler)
+	}
+	handler = web.MetricsMiddleware(otel.Meter("product-service"))(handler)
+	handler = web.MaxBodySizeMiddleware(cfg.HTTPServer.MaxBodyBytes)(handler)
+	handler = web.PageSizeMiddleware(cfg.HTTPServer.MaxPageSize, cfg.HTTPServer.PageSizeMode == pconfig.PageSizeReject, deps.Logger)(handler)
+	handler = web.AllowedHostsMiddleware(cfg.HTTPServer.AllowedHosts, deps.Logger)(handler)
+	return server.NewHTTPServer(cfg.HTTPServer, handler)
 }
 
 // SetupGrpcServer initializes the gRPC server for the ProductService application.
-func SetupGrpcServer(deps *Dependencies, reflectionEnabled bool) *grpc.Server {
+// It serves the standard gRPC health service too, which reports SERVING, so clients such as the Order service
+// can verify that the Product service is up before they accept requests depending on it.
+func SetupGrpcServer(deps *Dependencies, cfg pconfig.GrpcServerConfig) (*grpc.Server, error) {
 	// Service registration function for gRPC server
 	productRegisterFunc := func(s *grpc.Server) {
 		productGRPCServer := grpcImpl.NewServer(deps.ProductService)
 		pb.RegisterProductServiceServer(s, productGRPCServer)
+		healthpb.RegisterHealthServer(s, grpchealth.NewServer())
 	}
-	// create a new gRPC server with reflection if enabled
-	return server.NewGRPCServer(reflectionEnabled, productRegisterFunc)
+	// create a new gRPC server with TLS, reflection and the connection age limit if configured
+	return server.NewGRPCServer(cfg, deps.Logger, productRegisterFunc)
 }
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/abgdnv/gocommerce/pkg/config/configloader"
@@ -10,6 +13,9 @@ import (
 var _ configloader.Validator = (*Config)(nil)
 
 type Config struct {
+	// Env is the deployment environment, config.EnvProduction enforces the production guards.
+	// It's normalized with config.NormalizeEnv, so it's config.EnvProduction if not set.
+	Env        string                  `koanf:"env"`
 	HTTPServer config.HTTPConfig       `koanf:"server"`
 	Database   config.DatabaseConfig   `koanf:"db"`
 	Log        config.LogConfig        `koanf:"log"`
@@ -17,10 +23,74 @@ type Config struct {
 	GRPC       config.GrpcServerConfig `koanf:"grpc"`
 	Telemetry  config.TelemetryConfig  `koanf:"telemetry"`
 	Shutdown   config.ShutdownConfig   `koanf:"shutdown"`
+	Audit      config.AuditConfig      `koanf:"audit"`
+	Nats       config.NATSConfig       `koanf:"nats"`
+	Products   ProductsConfig          `koanf:"products"`
+}
+
+// ProductsConfig holds the settings of product processing.
+type ProductsConfig struct {
+	// StockLock serializes stock updates, reservations and releases of the same product within a replica.
+	// The version check in the database still guards against concurrent updates across replicas.
+	StockLock bool `koanf:"stocklock"`
+	// LocationHeader sets the Location header to the URL of the created product.
+	LocationHeader bool `koanf:"locationheader"`
+	// PriceHistoryMaxRange limits the time range of a single price history request.
+	PriceHistoryMaxRange time.Duration `koanf:"pricehistorymaxrange"`
+	// BatchMaxSize limits the number of products created by a single batch request.
+	BatchMaxSize int `koanf:"batchmaxsize"`
+	// SoftDelete marks the deleted products instead of removing them, so the lookup by IDs
+	// reports them as deleted rather than not found.
+	SoftDelete bool `koanf:"softdelete"`
+	// UniqueNames rejects a product named like another live product, ignoring the case, with 409 Conflict.
+	// The names are unique by the index of the migrations regardless, if disabled a duplicate name fails with 500.
+	UniqueNames bool `koanf:"uniquenames"`
+	// StrictJSON rejects request bodies with fields the request doesn't have, e.g. misspelled ones, instead of ignoring them.
+	StrictJSON bool `koanf:"strictjson"`
+}
+
+// defaultPriceHistoryMaxRange allows a year of daily price history per request.
+const defaultPriceHistoryMaxRange = 366 * 24 * time.Hour
+
+// defaultBatchMaxSize keeps a batch transaction short enough not to hold the locks for long.
+const defaultBatchMaxSize = 100
+
+// String returns a string representation of the ProductsConfig.
+func (c *ProductsConfig) String() string {
+	var b strings.Builder
+	b.WriteString("\n--- Products ---\n")
+	b.WriteString(fmt.Sprintf("  stocklock: %t\n", c.StockLock))
+	b.WriteString(fmt.Sprintf("  locationheader: %t\n", c.LocationHeader))
+	b.WriteString(fmt.Sprintf("  pricehistorymaxrange: %s\n", c.PriceHistoryMaxRange))
+	b.WriteString(fmt.Sprintf("  batchmaxsize: %d\n", c.BatchMaxSize))
+	b.WriteString(fmt.Sprintf("  softdelete: %t\n", c.SoftDelete))
+	b.WriteString(fmt.Sprintf("  uniquenames: %t\n", c.UniqueNames))
+	b.WriteString(fmt.Sprintf("  strictjson: %t\n", c.StrictJSON))
+	return b.String()
+}
+
+// Validate checks if the products configuration values are valid
+func (c *ProductsConfig) Validate() error {
+	if c.PriceHistoryMaxRange < 0 {
+		return fmt.Errorf("invalid products price history max range: %v", c.PriceHistoryMaxRange)
+	}
+	if c.PriceHistoryMaxRange == 0 {
+		log.Println("Using default value for products pricehistorymaxrange")
+		c.PriceHistoryMaxRange = defaultPriceHistoryMaxRange
+	}
+	if c.BatchMaxSize < 0 {
+		return fmt.Errorf("invalid products batch max size: %d", c.BatchMaxSize)
+	}
+	if c.BatchMaxSize == 0 {
+		log.Println("Using default value for products batchmaxsize")
+		c.BatchMaxSize = defaultBatchMaxSize
+	}
+	return nil
 }
 
 func (c *Config) String() string {
 	var b strings.Builder
+	b.WriteString(fmt.Sprintf("\nenv: %s\n", c.Env))
 	b.WriteString(c.HTTPServer.String())
 	b.WriteString(c.Database.String())
 	b.WriteString(c.GRPC.String())
@@ -28,6 +98,11 @@ func (c *Config) String() string {
 	b.WriteString(c.PProf.String())
 	b.WriteString(c.Telemetry.String())
 	b.WriteString(c.Shutdown.String())
+	b.WriteString(c.Products.String())
+	b.WriteString(c.Audit.String())
+	if c.Audit.Enabled {
+		b.WriteString(c.Nats.String())
+	}
 	return b.String()
 }
 
@@ -54,5 +129,21 @@ func (c *Config) Validate() error {
 	if err := c.GRPC.Validate(); err != nil {
 		return err
 	}
+	if strings.TrimSpace(c.Env) == "" {
+		log.Println("Using default value for env:", config.EnvProduction)
+	}
+	c.Env = config.NormalizeEnv(c.Env)
+	if err := c.GRPC.ValidateEnv(c.Env); err != nil {
+		return err
+	}
+	if err := c.Products.Validate(); err != nil {
+		return err
+	}
+	// NATS is only used to publish audit events
+	if c.Audit.Enabled {
+		if err := c.Nats.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
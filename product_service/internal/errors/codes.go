@@ -0,0 +1,48 @@
+package errors
+
+import "errors"
+
+// Codes of the product errors reported to clients, so they can switch on them instead of the message.
+const (
+	CodeProductNotFound   = "PRODUCT_NOT_FOUND"
+	CodeOptimisticLock    = "OPTIMISTIC_LOCK"
+	CodeInvalidCursor     = "INVALID_CURSOR"
+	CodeQueryTimeout      = "QUERY_TIMEOUT"
+	CodeInvalidInterval   = "INVALID_INTERVAL"
+	CodeInvalidRange      = "INVALID_TIME_RANGE"
+	CodeBatchTooLarge     = "BATCH_TOO_LARGE"
+	CodeInsufficientStock = "INSUFFICIENT_STOCK"
+	CodeStockOverflow     = "STOCK_OVERFLOW"
+	CodeAlreadyExists     = "PRODUCT_ALREADY_EXISTS"
+	CodeInvalidSKU        = "INVALID_SKU"
+	CodeCurrencyMismatch  = "CURRENCY_MISMATCH"
+)
+
+// codes maps the sentinel errors to their codes.
+var codes = []struct {
+	err  error
+	code string
+}{
+	{ErrProductNotFound, CodeProductNotFound},
+	{ErrOptimisticLock, CodeOptimisticLock},
+	{ErrInvalidCursor, CodeInvalidCursor},
+	{ErrQueryTimeout, CodeQueryTimeout},
+	{ErrInvalidInterval, CodeInvalidInterval},
+	{ErrInvalidTimeRange, CodeInvalidRange},
+	{ErrBatchTooLarge, CodeBatchTooLarge},
+	{ErrInsufficientStock, CodeInsufficientStock},
+	{ErrStockOverflow, CodeStockOverflow},
+	{ErrProductAlreadyExists, CodeAlreadyExists},
+	{ErrInvalidSKU, CodeInvalidSKU},
+	{ErrCurrencyMismatch, CodeCurrencyMismatch},
+}
+
+// Code returns the code of the sentinel error err wraps, or an empty string if err doesn't wrap any of them.
+func Code(err error) string {
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ""
+}
@@ -4,3 +4,25 @@ package errors
 import "errors"
 
 var ErrProductNotFound = errors.New("product not found")
+
+var ErrOptimisticLock = errors.New("optimistic lock error: the product has been modified by another transaction")
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+var ErrQueryTimeout = errors.New("database query timed out")
+
+var ErrInvalidInterval = errors.New("invalid price history interval")
+
+var ErrInvalidTimeRange = errors.New("invalid price history time range")
+
+var ErrBatchTooLarge = errors.New("product batch too large")
+
+var ErrInsufficientStock = errors.New("insufficient stock: the stock quantity can't go below zero")
+
+var ErrStockOverflow = errors.New("stock overflow: the stock quantity can't exceed 2147483647")
+
+var ErrProductAlreadyExists = errors.New("product already exists: another product has the same name")
+
+var ErrInvalidSKU = errors.New("invalid SKU")
+
+var ErrCurrencyMismatch = errors.New("currency mismatch: the product is priced in another currency")
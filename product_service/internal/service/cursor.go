@@ -0,0 +1,45 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	producterrors "github.com/abgdnv/gocommerce/product_service/internal/errors"
+	"github.com/google/uuid"
+)
+
+// cursor is a position in the product list ordered by (created_at, id) descending.
+type cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// encodeCursor returns an opaque cursor string: base64 encoded "created_at,id".
+func encodeCursor(c cursor) string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses the opaque cursor string produced by encodeCursor.
+// Returns ErrInvalidCursor if the cursor is malformed.
+func decodeCursor(s string) (cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, fmt.Errorf("%w: %v", producterrors.ErrInvalidCursor, err)
+	}
+	createdAtStr, idStr, found := strings.Cut(string(raw), ",")
+	if !found {
+		return cursor{}, fmt.Errorf("%w: unexpected format", producterrors.ErrInvalidCursor)
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return cursor{}, fmt.Errorf("%w: %v", producterrors.ErrInvalidCursor, err)
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return cursor{}, fmt.Errorf("%w: %v", producterrors.ErrInvalidCursor, err)
+	}
+	return cursor{CreatedAt: createdAt, ID: id}, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// productLocks serializes operations on the same product within a single replica.
+// Locks are created on demand and removed once no goroutine holds or waits for them.
+type productLocks struct {
+	mu    sync.Mutex
+	locks map[uuid.UUID]*productLock
+}
+
+type productLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+func newProductLocks() *productLocks {
+	return &productLocks{locks: make(map[uuid.UUID]*productLock)}
+}
+
+// lock acquires the lock of the product and returns the function releasing it.
+// Returns the context error if the context is done before the lock is acquired.
+func (l *productLocks) lock(ctx context.Context, id uuid.UUID) (func(), error) {
+	l.mu.Lock()
+	pl, ok := l.locks[id]
+	if !ok {
+		pl = &productLock{ch: make(chan struct{}, 1)}
+		l.locks[id] = pl
+	}
+	pl.refs++
+	l.mu.Unlock()
+
+	select {
+	case pl.ch <- struct{}{}:
+		return func() {
+			<-pl.ch
+			l.release(id, pl)
+		}, nil
+	case <-ctx.Done():
+		l.release(id, pl)
+		return nil, ctx.Err()
+	}
+}
+
+// release drops a reference to the lock and removes it when it is no longer used.
+func (l *productLocks) release(id uuid.UUID, pl *productLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pl.refs--
+	if pl.refs == 0 {
+		delete(l.locks, id)
+	}
+}
+
+// lockAll acquires the locks of the products in the order of their IDs, so concurrent callers can't deadlock,
+// and returns the function releasing them. Returns the context error if the context is done before all locks are acquired.
+func (l *productLocks) lockAll(ctx context.Context, ids []uuid.UUID) (func(), error) {
+	sorted := slices.Clone(ids)
+	slices.SortFunc(sorted, func(a, b uuid.UUID) int { return bytes.Compare(a[:], b[:]) })
+	sorted = slices.Compact(sorted)
+	unlocks := make([]func(), 0, len(sorted))
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, id := range sorted {
+		unlock, err := l.lock(ctx, id)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return unlockAll, nil
+}
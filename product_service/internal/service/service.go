@@ -4,7 +4,15 @@ package service
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/abgdnv/gocommerce/pkg/audit"
+	"github.com/abgdnv/gocommerce/pkg/money"
+	"github.com/abgdnv/gocommerce/product_service/internal/config"
+	producterrors "github.com/abgdnv/gocommerce/product_service/internal/errors"
 	"github.com/abgdnv/gocommerce/product_service/internal/store"
 	"github.com/abgdnv/gocommerce/product_service/internal/store/db"
 	"github.com/google/uuid"
@@ -14,76 +22,276 @@ import (
 // It abstracts the underlying business logic and data access.
 type ProductService interface {
 	// FindByID retrieves a single product by its unique identifier.
-	// Returns ErrProductNotFound if no product exists with the given ID.
-	FindByID(ctx context.Context, id uuid.UUID) (*ProductDto, error)
+	// Returns ErrProductNotFound if no product exists with the given ID, or if it's internal and includeInternal isn't set.
+	FindByID(ctx context.Context, id uuid.UUID, includeInternal bool) (*ProductDto, error)
 
-	// FindByIDs returns products by IDs.
+	// FindByIDs returns products by IDs, the soft-deleted ones are returned with Deleted set.
 	// Returns an empty slice if no products exist.
 	FindByIDs(ctx context.Context, ids []uuid.UUID) ([]ProductDto, error)
 
-	// FindAll returns all available products.
+	// FindAll returns all available products, the internal ones only if includeInternal is set.
 	// Returns an empty slice if no products exist.
-	FindAll(ctx context.Context, offset, limit int32) ([]ProductDto, error)
+	FindAll(ctx context.Context, offset, limit int32, includeInternal bool) ([]ProductDto, error)
+
+	// FindAllByCursor returns a page of products using keyset pagination, the internal ones only if includeInternal is set.
+	// An empty cursor returns the first page. NextCursor is empty when there are no more products.
+	// Returns ErrInvalidCursor if the cursor is malformed.
+	FindAllByCursor(ctx context.Context, cursor string, limit int32, includeInternal bool) (*ProductPageDto, error)
+
+	// Search returns the available products matching all the words of the query, the most relevant first,
+	// the internal ones only if includeInternal is set. Returns an empty slice if no products match.
+	Search(ctx context.Context, query string, offset, limit int32, includeInternal bool) ([]ProductDto, error)
 
 	// Create adds a new product to the system.
-	// Returns error if the product cannot be created.
+	// Returns ErrProductAlreadyExists if the names are unique and the name is taken, or error if the product cannot be created.
 	Create(ctx context.Context, product ProductCreateDto) (*ProductDto, error)
 
+	// CreateBatch adds the products to the system in a single transaction.
+	// The created products are returned in the given order. Returns error and creates none of them if any cannot be created,
+	// ErrProductAlreadyExists if the names are unique and any of the names is taken.
+	CreateBatch(ctx context.Context, products []ProductCreateDto) ([]ProductDto, error)
+
+	// CheckBatch checks the names of the products of a batch without creating them, for a dry run of CreateBatch.
+	// Returns ErrProductAlreadyExists or nil per product, in the given order: a product is rejected if a live product
+	// or an earlier product of the batch has its name, ignoring the case. Returns error if the names cannot be checked.
+	CheckBatch(ctx context.Context, products []ProductCreateDto) ([]error, error)
+
+	// UpsertBySKU creates a product with the external SKU, or updates the details of the product with the SKU,
+	// and reports whether the product was created. The visibility and the currency only apply to a created product.
+	// Returns ErrProductAlreadyExists if the names are unique and the name is taken by another product,
+	// or ErrCurrencyMismatch if the currency, USD if omitted, differs from the currency of the existing product.
+	UpsertBySKU(ctx context.Context, sku string, product ProductCreateDto) (*ProductDto, bool, error)
+
 	// Update modifies an existing product's details.
-	// Returns ErrProductNotFound if no product exists with the given ID and version.
+	// Returns ErrProductNotFound if no product exists with the given ID, ErrOptimisticLock if its version differs,
+	// or ErrProductAlreadyExists if the names are unique and the new name is taken.
 	Update(ctx context.Context, product ProductDto) (*ProductDto, error)
 
-	// UpdateStock adjusts the stock quantity of a product.
-	// Returns ErrProductNotFound if no product exists with the given ID and version.
-	UpdateStock(ctx context.Context, id uuid.UUID, stock int32, version int32) (*ProductDto, error)
+	// Patch modifies the provided fields of a product, the omitted ones remain unchanged.
+	// Returns ErrProductNotFound if no product exists with the given ID, ErrOptimisticLock if its version differs,
+	// or ErrProductAlreadyExists if the names are unique and the new name is taken.
+	Patch(ctx context.Context, id uuid.UUID, patch ProductPatchDto) (*ProductDto, error)
+
+	// UpdateStock adjusts the stock quantity and the expected restock time of a product.
+	// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
+	UpdateStock(ctx context.Context, id uuid.UUID, stock int32, version int32, restockAt *time.Time) (*ProductDto, error)
 
-	// DeleteByID removes a product by its ID.
-	// Returns ErrProductNotFound if no product exists with the given ID.
+	// AdjustStock adds the delta to the stock quantity of a product, a negative delta reduces it.
+	// Returns ErrProductNotFound if no product exists with the given ID, ErrOptimisticLock if its version differs,
+	// ErrInsufficientStock if the stock quantity would go below zero, or ErrStockOverflow if it would exceed the range of int4.
+	AdjustStock(ctx context.Context, id uuid.UUID, delta int32, version int32) (*ProductDto, error)
+
+	// ReserveStock takes the quantities from the stock of the products for an order, all or none.
+	// Returns ErrProductNotFound if any of the products doesn't exist,
+	// or ErrInsufficientStock if the stock quantity of any of the products is below its quantity.
+	ReserveStock(ctx context.Context, quantities map[uuid.UUID]int32) error
+
+	// ReleaseStock returns the quantities taken by ReserveStock to the stock of the products, all or none.
+	// Returns ErrProductNotFound if any of the products doesn't exist,
+	// or ErrStockOverflow if the stock quantity of any of the products would exceed the range of int4.
+	ReleaseStock(ctx context.Context, quantities map[uuid.UUID]int32) error
+
+	// DeleteByID removes a product by its ID, or marks it as deleted if the soft delete is enabled.
+	// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
 	DeleteByID(ctx context.Context, id uuid.UUID, version int32) error
+
+	// PriceHistory returns the min, max and average price of a product per interval bucket in [from, to).
+	// A zero to means now, a zero from means the max range before to.
+	// Returns ErrInvalidInterval, ErrInvalidTimeRange, or ErrProductNotFound if no product exists with the given ID.
+	PriceHistory(ctx context.Context, id uuid.UUID, interval string, from, to time.Time) (*PriceHistoryDto, error)
+
+	// History returns the changes of a product, oldest first, including the changes of a deleted product.
+	// Returns ErrProductNotFound if no changes are recorded for the given ID.
+	History(ctx context.Context, id uuid.UUID) (*ProductHistoryDto, error)
 }
 
+// Intervals of the price history buckets.
+const (
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
 // Service implements ProductService and provides methods to manage products.
+// auditResource is the resource name used in audit events for products.
+const auditResource = "product"
+
 type Service struct {
 	repository store.ProductStore
+	auditor    audit.Recorder
+	// stockLocks serializes stock updates of the same product, nil if disabled
+	stockLocks *productLocks
+	// priceHistoryMaxRange limits the time range of a price history request
+	priceHistoryMaxRange time.Duration
+	// softDelete marks the deleted products instead of removing them
+	softDelete bool
 }
 
-// NewService creates a new instance of ProductService with the provided repository.
-func NewService(repo store.ProductStore) *Service {
-	return &Service{
-		repository: repo,
+// NewService creates a new instance of ProductService with the provided repository and audit recorder.
+func NewService(repo store.ProductStore, auditor audit.Recorder, cfg config.ProductsConfig) *Service {
+	s := &Service{
+		repository:           repo,
+		auditor:              auditor,
+		priceHistoryMaxRange: cfg.PriceHistoryMaxRange,
+		softDelete:           cfg.SoftDelete,
 	}
+	if cfg.StockLock {
+		s.stockLocks = newProductLocks()
+	}
+	return s
 }
 
 // ProductCreateDto represents the data transfer object for creating a new product.
+// An omitted Visibility creates a public product.
+// Currency is the ISO 4217 code of the currency of the price, an omitted one is USD. The currencies whose minor unit
+// isn't the hundredth, e.g. JPY or KWD, are rejected, since the prices are stored in hundredths, see money.Supports.
+// Price is a decimal string of the major units, e.g. "5.99", see money.Money.
 type ProductCreateDto struct {
-	Name  string `json:"name"    validate:"required,max=100"`
-	Price int64  `json:"price"   validate:"required,min=0"`
-	Stock int32  `json:"stock"   validate:"required,min=0"`
+	Name        string      `json:"name"    validate:"required,max=100"`
+	Description string      `json:"description,omitempty" validate:"max=1000"`
+	Price       money.Money `json:"price"   validate:"required,min=0"`
+	Stock       int32       `json:"stock"   validate:"required,min=0"`
+	Visibility  string      `json:"visibility,omitempty" validate:"omitempty,oneof=public internal"`
+	Currency    string      `json:"currency,omitempty"   validate:"omitempty,iso4217,money_currency"`
+}
+
+// ProductBatchResultDto represents the outcome of a batch product creation.
+// Items holds a result per product of the batch, in the order of the request.
+// DryRun is set if the products were only checked, then Created is the number of products that would be created.
+type ProductBatchResultDto struct {
+	Created int                   `json:"created"`
+	Failed  int                   `json:"failed"`
+	DryRun  bool                  `json:"dry_run,omitempty"`
+	Items   []ProductBatchItemDto `json:"items"`
+}
+
+// ProductBatchItemDto represents the outcome of a single product of a batch:
+// the ID of the created product, or the validation errors of the rejected one.
+type ProductBatchItemDto struct {
+	Index            int               `json:"index"`
+	Status           int               `json:"status"`
+	ID               string            `json:"id,omitempty"`
+	ValidationErrors map[string]string `json:"validation_errors,omitempty"`
 }
 
 // ProductDto represents the data transfer object for a product.
 // Version is read-only and used for optimistic concurrency control.
+// RestockAt is read-only here and is changed by the stock update.
+// Deleted is set for the soft-deleted products, which are only returned by the lookup by IDs.
+// Visibility and Currency are read-only and set on creation.
+// Price is a decimal string of the major units of Currency, e.g. "5.99", see money.Money.
+// CreatedAt and UpdatedAt are read-only RFC 3339 timestamps of the creation and the last change of the product.
+// Description is optional, an update without it clears the previous one.
+// SKU is read-only, it's the external SKU of a product created by UpsertBySKU.
 type ProductDto struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"    validate:"required,max=100"`
-	Price   int64  `json:"price"   validate:"required,min=0"`
-	Stock   int32  `json:"stock"   validate:"required,min=0"`
-	Version int32  `json:"version" validate:"required,min=1"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"    validate:"required,max=100"`
+	Description string      `json:"description,omitempty" validate:"max=1000"`
+	Price       money.Money `json:"price"   validate:"required,min=0"`
+	Stock       int32       `json:"stock"   validate:"required,min=0"`
+	Version     int32       `json:"version" validate:"required,min=1"`
+	RestockAt   *time.Time  `json:"restock_at,omitempty"`
+	Deleted     bool        `json:"deleted,omitempty"`
+	Visibility  string      `json:"visibility,omitempty"`
+	Currency    string      `json:"currency,omitempty"`
+	CreatedAt   string      `json:"created_at,omitempty"`
+	UpdatedAt   string      `json:"updated_at,omitempty"`
+	SKU         string      `json:"sku,omitempty"`
+}
+
+// ProductPatchDto represents the data transfer object for partially updating a product.
+// The omitted fields are nil, the provided ones are validated with the rules of ProductDto.
+// The required rule of a pointer only rejects nil, so the zero values rejected by ProductDto are rejected by the min rules instead.
+// An empty description clears the previous one.
+type ProductPatchDto struct {
+	Name        *string      `json:"name"    validate:"omitnil,min=1,max=100"`
+	Description *string      `json:"description" validate:"omitnil,max=1000"`
+	Price       *money.Money `json:"price"   validate:"omitnil,min=1"`
+	Stock       *int32       `json:"stock"   validate:"omitnil,min=1"`
+	Version     int32        `json:"version" validate:"required,min=1"`
+}
+
+// empty reports whether the patch provides no fields.
+func (p ProductPatchDto) empty() bool {
+	return p.Name == nil && p.Description == nil && p.Price == nil && p.Stock == nil
+}
+
+// ProductPageDto represents a page of products returned by cursor pagination.
+type ProductPageDto struct {
+	Items      []ProductDto `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
 }
 
 // StockUpdateDto represents the data transfer object for updating product stock.
+// RestockAt is the expected restock time, omitting it clears the previous value.
 type StockUpdateDto struct {
-	Stock   int32 `json:"stock"   validate:"required,min=0"`
+	Stock     int32      `json:"stock"   validate:"required,min=0"`
+	Version   int32      `json:"version" validate:"required,min=1"`
+	RestockAt *time.Time `json:"restock_at,omitempty"`
+}
+
+// StockAdjustDto represents the data transfer object for adjusting product stock by a relative delta.
+type StockAdjustDto struct {
+	Delta   int32 `json:"delta"   validate:"required"`
 	Version int32 `json:"version" validate:"required,min=1"`
 }
 
+// PriceHistoryDto represents the aggregated price history of a product in [from, to).
+// The previous time range ends at From.
+type PriceHistoryDto struct {
+	ProductID string           `json:"product_id"`
+	Interval  string           `json:"interval"`
+	From      time.Time        `json:"from"`
+	To        time.Time        `json:"to"`
+	Buckets   []PriceBucketDto `json:"buckets"`
+}
+
+// PriceBucketDto represents the prices a product had in a single interval bucket starting at Start,
+// i.e. the price in effect at its start and the Changes of the price within it.
+type PriceBucketDto struct {
+	Start    time.Time `json:"start"`
+	MinPrice int64     `json:"min_price"`
+	MaxPrice int64     `json:"max_price"`
+	AvgPrice float64   `json:"avg_price"`
+	Changes  int64     `json:"changes"`
+}
+
+// ProductHistoryDto represents the changes of a product, oldest first.
+type ProductHistoryDto struct {
+	ProductID string             `json:"product_id"`
+	Changes   []ProductChangeDto `json:"changes"`
+}
+
+// ProductChangeDto represents a single change of a product and the version it resulted in.
+// Old is omitted for a created product, New for a deleted one, unless it's soft-deleted.
+type ProductChangeDto struct {
+	Action    string            `json:"action"`
+	Version   int32             `json:"version"`
+	ChangedAt time.Time         `json:"changed_at"`
+	Old       *ProductValuesDto `json:"old,omitempty"`
+	New       *ProductValuesDto `json:"new,omitempty"`
+}
+
+// ProductValuesDto represents the values of a product before or after a change.
+type ProductValuesDto struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Price       int64      `json:"price"`
+	Stock       int32      `json:"stock"`
+	RestockAt   *time.Time `json:"restock_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
 // FindByID retrieves a product by its ID and returns it as a ProductDto.
+// An internal product is reported as not found unless includeInternal is set, so its existence isn't disclosed.
 // Returns ErrProductNotFound if no product exists with the given ID.
-func (s *Service) FindByID(ctx context.Context, id uuid.UUID) (*ProductDto, error) {
+func (s *Service) FindByID(ctx context.Context, id uuid.UUID, includeInternal bool) (*ProductDto, error) {
 	product, err := s.repository.FindByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch product by ID %s: %w", id, err)
 	}
+	if product.Visibility == store.VisibilityInternal && !includeInternal {
+		return nil, fmt.Errorf("failed to fetch product by ID %s: %w", id, producterrors.ErrProductNotFound)
+	}
 
 	return toDto(product), nil
 }
@@ -104,10 +312,10 @@ func (s *Service) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]ProductDto,
 	return productDTOs, nil
 }
 
-// FindAll retrieves a list of all products and returns them as ProductDTOs.
+// FindAll retrieves a list of all products, the internal ones only if includeInternal is set, and returns them as ProductDTOs.
 // Returns an empty slice if no products exist or error if the retrieval fails.
-func (s *Service) FindAll(ctx context.Context, offset, limit int32) ([]ProductDto, error) {
-	products, err := s.repository.FindAll(ctx, offset, limit)
+func (s *Service) FindAll(ctx context.Context, offset, limit int32, includeInternal bool) ([]ProductDto, error) {
+	products, err := s.repository.FindAll(ctx, offset, limit, includeInternal)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch products: %w", err)
 	}
@@ -120,58 +328,476 @@ func (s *Service) FindAll(ctx context.Context, offset, limit int32) ([]ProductDt
 	return productDTOs, nil
 }
 
+// Search retrieves the products matching the full-text query, ranked by relevance, and returns them as ProductDTOs.
+// Returns an empty slice if no products match or error if the search fails.
+func (s *Service) Search(ctx context.Context, query string, offset, limit int32, includeInternal bool) ([]ProductDto, error) {
+	products, err := s.repository.Search(ctx, query, offset, limit, includeInternal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search products: %w", err)
+	}
+	productDTOs := make([]ProductDto, len(products))
+	for i, item := range products {
+		productDTOs[i] = *toDto(&item)
+	}
+	return productDTOs, nil
+}
+
+// FindAllByCursor retrieves a page of products after the given cursor.
+// One extra product is fetched to find out whether the next page exists.
+// The internal products are included only if includeInternal is set.
+// Returns ErrInvalidCursor if the cursor is malformed.
+func (s *Service) FindAllByCursor(ctx context.Context, cursorStr string, limit int32, includeInternal bool) (*ProductPageDto, error) {
+	var createdAt *time.Time
+	var id uuid.UUID
+	if cursorStr != "" {
+		c, err := decodeCursor(cursorStr)
+		if err != nil {
+			return nil, err
+		}
+		createdAt, id = &c.CreatedAt, c.ID
+	}
+
+	products, err := s.repository.FindAllByCursor(ctx, createdAt, id, limit+1, includeInternal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	page := &ProductPageDto{}
+	if len(products) > int(limit) {
+		products = products[:limit]
+		last := products[len(products)-1]
+		if last.CreatedAt != nil {
+			page.NextCursor = encodeCursor(cursor{CreatedAt: *last.CreatedAt, ID: last.ID})
+		}
+	}
+	page.Items = make([]ProductDto, len(products))
+	for i, item := range products {
+		page.Items[i] = *toDto(&item)
+	}
+
+	return page, nil
+}
+
 // Create creates a new product and returns it as a ProductDto.
 // Returns an error if the product cannot be created.
 func (s *Service) Create(ctx context.Context, product ProductCreateDto) (*ProductDto, error) {
-	p, err := s.repository.Create(ctx, product.Name, product.Price, product.Stock)
+	p, err := s.repository.Create(
+		ctx,
+		product.Name,
+		descriptionOrNil(product.Description),
+		product.Price.Amount,
+		product.Stock,
+		visibilityOrDefault(product.Visibility),
+		currencyOrDefault(product.Currency))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create product: %w", err)
 	}
+	s.auditor.Record(ctx, audit.ActionCreate, auditResource, p.ID.String())
 
 	return toDto(p), nil
 }
 
+// CreateBatch creates the products in a single transaction and returns them as ProductDTOs, in the given order.
+// Returns error and creates none of the products if any of them cannot be created.
+func (s *Service) CreateBatch(ctx context.Context, products []ProductCreateDto) ([]ProductDto, error) {
+	params := make([]db.CreateParams, len(products))
+	for i, product := range products {
+		params[i] = db.CreateParams{
+			Name:          product.Name,
+			Description:   descriptionOrNil(product.Description),
+			Price:         product.Price.Amount,
+			StockQuantity: product.Stock,
+			Visibility:    visibilityOrDefault(product.Visibility),
+			Currency:      currencyOrDefault(product.Currency),
+		}
+	}
+	created, err := s.repository.CreateBatch(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %d products: %w", len(products), err)
+	}
+	productDTOs := make([]ProductDto, len(created))
+	for i, item := range created {
+		s.auditor.Record(ctx, audit.ActionCreate, auditResource, item.ID.String())
+		productDTOs[i] = *toDto(&item)
+	}
+
+	return productDTOs, nil
+}
+
+// CheckBatch checks the names of the products against the unique index of the names and each other,
+// and returns ErrProductAlreadyExists for every product which CreateBatch would reject for its name, nil for the others.
+// Nothing is created or recorded.
+func (s *Service) CheckBatch(ctx context.Context, products []ProductCreateDto) ([]error, error) {
+	names := make([]string, len(products))
+	for i, product := range products {
+		names[i] = product.Name
+	}
+	taken, err := s.repository.FindTakenNames(ctx, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %d products: %w", len(products), err)
+	}
+	seen := make(map[string]bool, len(taken)+len(products))
+	for _, name := range taken {
+		seen[strings.ToLower(name)] = true
+	}
+	results := make([]error, len(products))
+	for i, product := range products {
+		name := strings.ToLower(product.Name)
+		if seen[name] {
+			results[i] = producterrors.ErrProductAlreadyExists
+		}
+		seen[name] = true
+	}
+
+	return results, nil
+}
+
+// UpsertBySKU creates or updates the product with the external SKU and returns it as a ProductDto,
+// together with whether it was created. Upserting the current values of a product leaves it unchanged.
+func (s *Service) UpsertBySKU(ctx context.Context, sku string, product ProductCreateDto) (*ProductDto, bool, error) {
+	p, created, err := s.repository.Upsert(
+		ctx,
+		sku,
+		product.Name,
+		descriptionOrNil(product.Description),
+		product.Price.Amount,
+		product.Stock,
+		visibilityOrDefault(product.Visibility),
+		currencyOrDefault(product.Currency))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to upsert product with SKU %s: %w", sku, err)
+	}
+	action := audit.ActionUpdate
+	if created {
+		action = audit.ActionCreate
+	}
+	s.auditor.Record(ctx, action, auditResource, p.ID.String())
+
+	return toDto(p), created, nil
+}
+
 // Update modifies an existing product's details and returns the updated product as a ProductDto.
-// Returns ErrProductNotFound if no product exists with the given ID and version.
+// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
 func (s *Service) Update(ctx context.Context, product ProductDto) (*ProductDto, error) {
 	updated, err := s.repository.Update(
 		ctx,
 		uuid.MustParse(product.ID),
 		product.Name,
-		product.Price,
+		descriptionOrNil(product.Description),
+		product.Price.Amount,
 		product.Stock,
 		product.Version)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update product with ID %s: %w", product.ID, err)
 	}
+	s.auditor.Record(ctx, audit.ActionUpdate, auditResource, product.ID)
+
+	return toDto(updated), nil
+}
+
+// Patch applies the provided fields to the current product and writes it with the version of the patch,
+// so the patch is rejected if the product has been modified since the client read it.
+// An empty patch doesn't modify the product, it only checks the version.
+// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
+func (s *Service) Patch(ctx context.Context, id uuid.UUID, patch ProductPatchDto) (*ProductDto, error) {
+	current, err := s.repository.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch product with ID %s for patch: %w", id, err)
+	}
+	if current.Version != patch.Version {
+		return nil, fmt.Errorf("failed to patch product with ID %s: %w", id, producterrors.ErrOptimisticLock)
+	}
+	if patch.empty() {
+		return toDto(current), nil
+	}
+	name, description, price, stock := current.Name, current.Description, current.Price, current.StockQuantity
+	if patch.Name != nil {
+		name = *patch.Name
+	}
+	if patch.Description != nil {
+		description = descriptionOrNil(*patch.Description)
+	}
+	if patch.Price != nil {
+		price = patch.Price.Amount
+	}
+	if patch.Stock != nil {
+		stock = *patch.Stock
+	}
+	updated, err := s.repository.Update(ctx, id, name, description, price, stock, patch.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to patch product with ID %s: %w", id, err)
+	}
+	s.auditor.Record(ctx, audit.ActionUpdate, auditResource, id.String())
 
 	return toDto(updated), nil
 }
 
 // UpdateStock adjusts the stock quantity of a product and returns the updated product as a ProductDto.
-// Returns ErrProductNotFound if no product exists with the given ID and version.
-func (s *Service) UpdateStock(ctx context.Context, id uuid.UUID, stock int32, version int32) (*ProductDto, error) {
-	product, err := s.repository.UpdateStock(ctx, id, stock, version)
+// Concurrent updates of the same product are serialized if the stock lock is enabled.
+// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
+func (s *Service) UpdateStock(ctx context.Context, id uuid.UUID, stock int32, version int32, restockAt *time.Time) (*ProductDto, error) {
+	if s.stockLocks != nil {
+		unlock, err := s.stockLocks.lock(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock stock of product with ID %s: %w", id, err)
+		}
+		defer unlock()
+	}
+	product, err := s.repository.UpdateStock(ctx, id, stock, version, restockAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update stock for product with ID %s: %w", id, err)
 	}
+	s.auditor.Record(ctx, audit.ActionUpdateStock, auditResource, id.String())
+
+	return toDto(product), nil
+}
+
+// AdjustStock adds the delta to the stock quantity of a product and returns the updated product as a ProductDto.
+// Concurrent updates of the same product are serialized if the stock lock is enabled.
+// Returns ErrProductNotFound if no product exists with the given ID, ErrOptimisticLock if its version differs,
+// ErrInsufficientStock if the stock quantity would go below zero, or ErrStockOverflow if it would exceed the range of int4.
+func (s *Service) AdjustStock(ctx context.Context, id uuid.UUID, delta int32, version int32) (*ProductDto, error) {
+	if s.stockLocks != nil {
+		unlock, err := s.stockLocks.lock(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock stock of product with ID %s: %w", id, err)
+		}
+		defer unlock()
+	}
+	product, err := s.repository.AdjustStock(ctx, id, delta, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to adjust stock for product with ID %s: %w", id, err)
+	}
+	s.auditor.Record(ctx, audit.ActionUpdateStock, auditResource, id.String())
 
 	return toDto(product), nil
 }
 
-// DeleteByID deletes a product by its ID.
-// Returns ErrProductNotFound if no product exists with the given ID and version.
+// ReserveStock takes the quantities from the stock of the products for an order, all or none.
+// Returns ErrProductNotFound if any of the products doesn't exist,
+// or ErrInsufficientStock if the stock quantity of any of the products is below its quantity.
+// Concurrent updates of the same products are serialized if the stock lock is enabled.
+func (s *Service) ReserveStock(ctx context.Context, quantities map[uuid.UUID]int32) error {
+	unlock, err := s.lockStocks(ctx, quantities)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.repository.ReserveStock(ctx, quantities); err != nil {
+		return fmt.Errorf("failed to reserve stock: %w", err)
+	}
+	s.recordStockUpdates(ctx, quantities)
+	return nil
+}
+
+// ReleaseStock returns the quantities taken by ReserveStock to the stock of the products, all or none.
+// Returns ErrProductNotFound if any of the products doesn't exist,
+// or ErrStockOverflow if the stock quantity of any of the products would exceed the range of int4.
+// Concurrent updates of the same products are serialized if the stock lock is enabled.
+func (s *Service) ReleaseStock(ctx context.Context, quantities map[uuid.UUID]int32) error {
+	unlock, err := s.lockStocks(ctx, quantities)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.repository.ReleaseStock(ctx, quantities); err != nil {
+		return fmt.Errorf("failed to release stock: %w", err)
+	}
+	s.recordStockUpdates(ctx, quantities)
+	return nil
+}
+
+// lockStocks acquires the stock locks of the products of the quantities if the stock lock is enabled,
+// and returns the function releasing them.
+func (s *Service) lockStocks(ctx context.Context, quantities map[uuid.UUID]int32) (func(), error) {
+	if s.stockLocks == nil {
+		return func() {}, nil
+	}
+	unlock, err := s.stockLocks.lockAll(ctx, slices.Collect(maps.Keys(quantities)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock stock of products: %w", err)
+	}
+	return unlock, nil
+}
+
+// recordStockUpdates records a stock update audit event of each of the products.
+func (s *Service) recordStockUpdates(ctx context.Context, quantities map[uuid.UUID]int32) {
+	for id := range quantities {
+		s.auditor.Record(ctx, audit.ActionUpdateStock, auditResource, id.String())
+	}
+}
+
+// DeleteByID deletes a product by its ID, or marks it as deleted if the soft delete is enabled.
+// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
 func (s *Service) DeleteByID(ctx context.Context, id uuid.UUID, version int32) error {
-	return s.repository.DeleteByID(ctx, id, version)
+	deleteByID := s.repository.DeleteByID
+	if s.softDelete {
+		deleteByID = s.repository.SoftDeleteByID
+	}
+	if err := deleteByID(ctx, id, version); err != nil {
+		return err
+	}
+	s.auditor.Record(ctx, audit.ActionDelete, auditResource, id.String())
+	return nil
+}
+
+// PriceHistory aggregates the price history of a product into buckets of the given interval.
+// The time range defaults to the max range before now, and must not exceed it.
+// Returns ErrInvalidInterval, ErrInvalidTimeRange, or ErrProductNotFound if no product exists with the given ID.
+func (s *Service) PriceHistory(ctx context.Context, id uuid.UUID, interval string, from, to time.Time) (*PriceHistoryDto, error) {
+	if interval != IntervalDay && interval != IntervalWeek {
+		return nil, fmt.Errorf("%w: %q", producterrors.ErrInvalidInterval, interval)
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-s.priceHistoryMaxRange)
+	}
+	// the history is stored in UTC
+	from, to = from.UTC(), to.UTC()
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", producterrors.ErrInvalidTimeRange)
+	}
+	if to.Sub(from) > s.priceHistoryMaxRange {
+		return nil, fmt.Errorf("%w: must not exceed %s", producterrors.ErrInvalidTimeRange, s.priceHistoryMaxRange)
+	}
+
+	// an unknown product would otherwise have an empty history
+	if _, err := s.repository.FindByID(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to fetch product by ID %s: %w", id, err)
+	}
+	buckets, err := s.repository.PriceHistory(ctx, id, interval, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch price history of product with ID %s: %w", id, err)
+	}
+
+	history := &PriceHistoryDto{
+		ProductID: id.String(),
+		Interval:  interval,
+		From:      from,
+		To:        to,
+		Buckets:   make([]PriceBucketDto, len(buckets)),
+	}
+	for i, b := range buckets {
+		history.Buckets[i] = PriceBucketDto{
+			Start:    *b.BucketStart,
+			MinPrice: b.MinPrice,
+			MaxPrice: b.MaxPrice,
+			AvgPrice: b.AvgPrice,
+			Changes:  b.Changes,
+		}
+	}
+	return history, nil
+}
+
+// History returns the changes of a product recorded by the store, oldest first.
+// Returns ErrProductNotFound if no changes are recorded for the given ID.
+func (s *Service) History(ctx context.Context, id uuid.UUID) (*ProductHistoryDto, error) {
+	changes, err := s.repository.History(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch history of product with ID %s: %w", id, err)
+	}
+	// every product has at least its creation recorded
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("failed to fetch history of product with ID %s: %w", id, producterrors.ErrProductNotFound)
+	}
+
+	history := &ProductHistoryDto{
+		ProductID: id.String(),
+		Changes:   make([]ProductChangeDto, len(changes)),
+	}
+	for i, c := range changes {
+		history.Changes[i] = ProductChangeDto{
+			Action:    c.Action,
+			Version:   c.Version,
+			ChangedAt: c.ChangedAt,
+			Old:       toValuesDto(c.Old),
+			New:       toValuesDto(c.New),
+		}
+	}
+	return history, nil
+}
+
+// toValuesDto converts the store.ProductValues of a change to a ProductValuesDto, nil stays nil.
+func toValuesDto(values *store.ProductValues) *ProductValuesDto {
+	if values == nil {
+		return nil
+	}
+	return &ProductValuesDto{
+		Name:        values.Name,
+		Description: descriptionOrEmpty(values.Description),
+		Price:       values.Price,
+		Stock:       values.StockQuantity,
+		RestockAt:   values.RestockAt,
+		DeletedAt:   values.DeletedAt,
+	}
 }
 
 // toDto converts a store.Product to a ProductDto.
 func toDto(product *db.Product) *ProductDto {
 	return &ProductDto{
-		ID:      product.ID.String(),
-		Name:    product.Name,
-		Price:   product.Price,
-		Stock:   product.StockQuantity,
-		Version: product.Version,
+		ID:          product.ID.String(),
+		Name:        product.Name,
+		Description: descriptionOrEmpty(product.Description),
+		Price:       money.New(product.Price, product.Currency),
+		Stock:       product.StockQuantity,
+		Version:     product.Version,
+		RestockAt:   product.RestockAt,
+		Deleted:     product.DeletedAt != nil,
+		Visibility:  product.Visibility,
+		Currency:    product.Currency,
+		CreatedAt:   formatTimestamp(product.CreatedAt),
+		UpdatedAt:   formatTimestamp(product.UpdatedAt),
+		SKU:         skuOrEmpty(product.Sku),
+	}
+}
+
+// formatTimestamp formats a timestamp of a product as RFC 3339 with fractional seconds,
+// so changes within the same second are still told apart. A nil timestamp is empty.
+func formatTimestamp(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// visibilityOrDefault returns the visibility of a created product, the products are public by default.
+func visibilityOrDefault(visibility string) string {
+	if visibility == "" {
+		return store.VisibilityPublic
+	}
+	return visibility
+}
+
+// currencyOrDefault returns the currency of a created product, the products are priced in USD by default.
+func currencyOrDefault(currency string) string {
+	if currency == "" {
+		return store.DefaultCurrency
+	}
+	return currency
+}
+
+// descriptionOrNil returns the description stored for a product, an empty description is stored as NULL.
+func descriptionOrNil(description string) *string {
+	if description == "" {
+		return nil
+	}
+	return &description
+}
+
+// descriptionOrEmpty returns the description of a product, empty if it has none.
+func descriptionOrEmpty(description *string) string {
+	if description == nil {
+		return ""
+	}
+	return *description
+}
+
+// skuOrEmpty returns the external SKU of a product, empty if it wasn't created by an upsert.
+func skuOrEmpty(sku *string) string {
+	if sku == nil {
+		return ""
 	}
+	return *sku
 }
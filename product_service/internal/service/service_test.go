@@ -3,19 +3,69 @@ package service
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/abgdnv/gocommerce/pkg/audit"
+	"github.com/abgdnv/gocommerce/pkg/messaging"
+	"github.com/abgdnv/gocommerce/pkg/messaging/events"
+	"github.com/abgdnv/gocommerce/pkg/money"
+	"github.com/abgdnv/gocommerce/pkg/web"
+	"github.com/abgdnv/gocommerce/product_service/internal/config"
+	producterrors "github.com/abgdnv/gocommerce/product_service/internal/errors"
+	"github.com/abgdnv/gocommerce/product_service/internal/store"
 	"github.com/abgdnv/gocommerce/product_service/internal/store/db"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+type PublisherMock struct {
+	error     error
+	published []messaging.Event
+}
+
+func (p *PublisherMock) Publish(_ context.Context, event messaging.Event) error {
+	if p.error != nil {
+		return p.error
+	}
+	p.published = append(p.published, event)
+	return nil
+}
+
 // mockProductStore is a mock implementation of the ProductStore interface
 type mockProductStore struct {
 	products []db.Product
 	product  db.Product
+	buckets  []db.AggregatePriceHistoryRow
+	changes  []store.ProductChange
 	error    error
+	// softDeleted is set when the product is soft-deleted instead of removed
+	softDeleted bool
+	// updated holds the fields the product was updated with, nil if it wasn't updated
+	updated *db.Product
+	// includeInternal is the flag the products were listed with
+	includeInternal bool
+	// visibility is the visibility the product was created with
+	visibility string
+	// currency is the currency the product was created with
+	currency string
+	// description is the description the product was created with
+	description *string
+	// query is the full-text query the products were searched with
+	query string
+	// reserved and released are the quantities the stock was reserved and released with
+	reserved, released map[uuid.UUID]int32
+	// sku is the SKU the product was upserted with, created reports the upsert created the product
+	sku     string
+	created bool
+	// takenNames are the names found taken, checkedNames the names they were looked up by
+	takenNames, checkedNames []string
+	// batchCreated reports a batch of products was created
+	batchCreated bool
 }
 
 // Simulate finding a product by ID
@@ -29,39 +79,114 @@ func (m *mockProductStore) FindByIDs(_ context.Context, _ []uuid.UUID) ([]db.Pro
 }
 
 // Simulate finding all products
-func (m *mockProductStore) FindAll(_ context.Context, _, _ int32) ([]db.Product, error) {
+func (m *mockProductStore) FindAll(_ context.Context, _, _ int32, includeInternal bool) ([]db.Product, error) {
+	m.includeInternal = includeInternal
+	return m.products, m.error
+}
+
+// Simulate finding products by cursor
+func (m *mockProductStore) FindAllByCursor(_ context.Context, _ *time.Time, _ uuid.UUID, _ int32, includeInternal bool) ([]db.Product, error) {
+	m.includeInternal = includeInternal
+	return m.products, m.error
+}
+
+// Simulate searching products
+func (m *mockProductStore) Search(_ context.Context, query string, _, _ int32, includeInternal bool) ([]db.Product, error) {
+	m.query = query
+	m.includeInternal = includeInternal
 	return m.products, m.error
 }
 
 // Simulate creating a product
-func (m *mockProductStore) Create(_ context.Context, _ string, _ int64, _ int32) (*db.Product, error) {
+func (m *mockProductStore) Create(_ context.Context, _ string, description *string, _ int64, _ int32, visibility, currency string) (*db.Product, error) {
+	m.description = description
+	m.visibility = visibility
+	m.currency = currency
 	return &m.product, m.error
 }
 
+// Simulate creating a batch of products
+func (m *mockProductStore) CreateBatch(_ context.Context, _ []db.CreateParams) ([]db.Product, error) {
+	m.batchCreated = true
+	return m.products, m.error
+}
+
+// Simulate finding the taken product names
+func (m *mockProductStore) FindTakenNames(_ context.Context, names []string) ([]string, error) {
+	m.checkedNames = names
+	return m.takenNames, m.error
+}
+
+// Simulate upserting a product by SKU
+func (m *mockProductStore) Upsert(_ context.Context, sku, _ string, description *string, _ int64, _ int32, visibility, currency string) (*db.Product, bool, error) {
+	m.sku = sku
+	m.description = description
+	m.visibility = visibility
+	m.currency = currency
+	return &m.product, m.created, m.error
+}
+
 // Simulate updating a product
-func (m *mockProductStore) Update(_ context.Context, _ uuid.UUID, _ string, _ int64, _ int32, _ int32) (*db.Product, error) {
+func (m *mockProductStore) Update(_ context.Context, id uuid.UUID, name string, description *string, price int64, stock int32, version int32) (*db.Product, error) {
+	m.updated = &db.Product{ID: id, Name: name, Description: description, Price: price, StockQuantity: stock, Version: version}
 	return &m.product, m.error
 }
 
 // Simulate updating stock for a product
-func (m *mockProductStore) UpdateStock(_ context.Context, _ uuid.UUID, _ int32, _ int32) (*db.Product, error) {
+func (m *mockProductStore) UpdateStock(_ context.Context, _ uuid.UUID, _ int32, _ int32, _ *time.Time) (*db.Product, error) {
+	return &m.product, m.error
+}
+
+// Simulate adjusting stock for a product
+func (m *mockProductStore) AdjustStock(_ context.Context, _ uuid.UUID, _ int32, _ int32) (*db.Product, error) {
 	return &m.product, m.error
 }
 
+// Simulate reserving stock
+func (m *mockProductStore) ReserveStock(_ context.Context, quantities map[uuid.UUID]int32) error {
+	m.reserved = quantities
+	return m.error
+}
+
+// Simulate releasing stock
+func (m *mockProductStore) ReleaseStock(_ context.Context, quantities map[uuid.UUID]int32) error {
+	m.released = quantities
+	return m.error
+}
+
 // Simulate deleting a product by ID
 func (m *mockProductStore) DeleteByID(_ context.Context, _ uuid.UUID, _ int32) error {
 	return m.error
 }
 
+// Simulate soft deleting a product by ID
+func (m *mockProductStore) SoftDeleteByID(_ context.Context, _ uuid.UUID, _ int32) error {
+	m.softDeleted = true
+	return m.error
+}
+
+// Simulate aggregating the price history of a product
+func (m *mockProductStore) PriceHistory(_ context.Context, _ uuid.UUID, _ string, _, _ time.Time) ([]db.AggregatePriceHistoryRow, error) {
+	return m.buckets, m.error
+}
+
+// Simulate finding the changes of a product
+func (m *mockProductStore) History(_ context.Context, _ uuid.UUID) ([]store.ProductChange, error) {
+	return m.changes, m.error
+}
+
 func Test_ProductService_FindByID(t *testing.T) {
 	ErrProductNotFound := errors.New("product not found")
 	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	createdAt := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2025, 7, 2, 8, 30, 15, 500000000, time.UTC)
 	testCases := []struct {
-		name        string
-		mockStore   *mockProductStore
-		productID   uuid.UUID
-		expected    *ProductDto
-		expectError error
+		name            string
+		mockStore       *mockProductStore
+		productID       uuid.UUID
+		includeInternal bool
+		expected        *ProductDto
+		expectError     error
 	}{
 		{
 			name: "Success - product found",
@@ -73,6 +198,42 @@ func Test_ProductService_FindByID(t *testing.T) {
 			expected:    &ProductDto{ID: mockID.String(), Name: "Toy"},
 			expectError: nil,
 		},
+		{
+			name: "Success - timestamps formatted as RFC 3339",
+			mockStore: &mockProductStore{
+				product: db.Product{
+					ID:        mockID,
+					Name:      "Toy",
+					CreatedAt: &createdAt,
+					UpdatedAt: &updatedAt,
+				},
+			},
+			productID: mockID,
+			expected: &ProductDto{
+				ID:        mockID.String(),
+				Name:      "Toy",
+				CreatedAt: "2025-07-01T12:00:00Z",
+				UpdatedAt: "2025-07-02T08:30:15.5Z",
+			},
+		},
+		{
+			name: "Success - internal product found for staff",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID, Name: "Toy", Visibility: store.VisibilityInternal},
+			},
+			productID:       mockID,
+			includeInternal: true,
+			expected:        &ProductDto{ID: mockID.String(), Name: "Toy", Visibility: store.VisibilityInternal},
+		},
+		{
+			name: "Error - internal product not found for others",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID, Name: "Toy", Visibility: store.VisibilityInternal},
+			},
+			productID:   mockID,
+			expected:    nil,
+			expectError: producterrors.ErrProductNotFound,
+		},
 		{
 			name: "Error - product not found",
 			mockStore: &mockProductStore{
@@ -87,9 +248,9 @@ func Test_ProductService_FindByID(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore)
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
 			// when
-			found, err := service.FindByID(context.Background(), tc.productID)
+			found, err := service.FindByID(context.Background(), tc.productID, tc.includeInternal)
 			// then
 			if tc.expectError != nil {
 				assert.ErrorIs(t, err, tc.expectError)
@@ -123,6 +284,14 @@ func Test_ProductService_FindByIDs(t *testing.T) {
 			expectedList: []ProductDto{{ID: mockID.String(), Name: "Toy"}},
 			expectError:  nil,
 		},
+		{
+			name: "Success - deleted product flagged",
+			mockStore: &mockProductStore{
+				products: []db.Product{{ID: mockID, Name: "Toy", DeletedAt: &time.Time{}}},
+			},
+			ids:          []uuid.UUID{mockID},
+			expectedList: []ProductDto{{ID: mockID.String(), Name: "Toy", Deleted: true}},
+		},
 		{
 			name: "Success - no products",
 			mockStore: &mockProductStore{
@@ -146,7 +315,7 @@ func Test_ProductService_FindByIDs(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore)
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
 			// when
 			found, err := service.FindByIDs(context.Background(), tc.ids)
 			// then
@@ -202,9 +371,9 @@ func Test_ProductService_FindAll(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore)
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
 			// when
-			found, err := service.FindAll(context.Background(), 0, 10)
+			found, err := service.FindAll(context.Background(), 0, 10, false)
 			// then
 			if tc.expectError != nil {
 				assert.ErrorIs(t, err, tc.expectError)
@@ -217,33 +386,63 @@ func Test_ProductService_FindAll(t *testing.T) {
 	}
 }
 
-func Test_ProductService_Create(t *testing.T) {
+func Test_ProductService_FindAll_IncludeInternal(t *testing.T) {
+	testCases := []struct {
+		name            string
+		includeInternal bool
+	}{
+		{
+			name:            "staff - internal products listed",
+			includeInternal: true,
+		},
+		{
+			name:            "others - internal products filtered",
+			includeInternal: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockStore := &mockProductStore{products: []db.Product{}}
+			service := NewService(mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
+			// when
+			_, err := service.FindAll(context.Background(), 0, 10, tc.includeInternal)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, tc.includeInternal, mockStore.includeInternal, "the store should filter the internal products")
+		})
+	}
+}
+
+func Test_ProductService_Search(t *testing.T) {
 	ErrStoreError := errors.New("store error")
 	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
 	testCases := []struct {
-		name        string
-		mockStore   *mockProductStore
-		product     ProductCreateDto
-		expected    *ProductDto
-		expectError error
+		name         string
+		mockStore    *mockProductStore
+		expectedList []ProductDto
+		expectError  error
 	}{
 		{
-			name: "Success - product created",
+			name: "Success - products found",
 			mockStore: &mockProductStore{
-				product: db.Product{ID: mockID, Name: "Toy", Price: 100, StockQuantity: 10},
-				error:   nil,
+				products: []db.Product{{ID: mockID, Name: "Wireless Headphones"}},
 			},
-			product:     ProductCreateDto{Name: "Toy", Price: 100, Stock: 10},
-			expected:    &ProductDto{ID: mockID.String(), Name: "Toy", Price: 100, Stock: 10},
-			expectError: nil,
+			expectedList: []ProductDto{{ID: mockID.String(), Name: "Wireless Headphones"}},
+		},
+		{
+			name: "Success - no products match",
+			mockStore: &mockProductStore{
+				products: []db.Product{},
+			},
+			expectedList: []ProductDto{},
 		},
 		{
 			name: "Error - store error",
 			mockStore: &mockProductStore{
 				error: ErrStoreError,
 			},
-			product:     ProductCreateDto{Name: "Toy", Price: 100, Stock: 10},
-			expected:    nil,
 			expectError: ErrStoreError,
 		},
 	}
@@ -251,58 +450,69 @@ func Test_ProductService_Create(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore)
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
 			// when
-			created, err := service.Create(context.Background(), tc.product)
+			found, err := service.Search(context.Background(), "wireless headphones", 0, 10, true)
 			// then
 			if tc.expectError != nil {
 				assert.ErrorIs(t, err, tc.expectError)
-				assert.Nil(t, created)
+				assert.Nil(t, found)
 				return
 			}
 			require.NoError(t, err)
-			assert.Equal(t, tc.expected, created)
+			assert.Equal(t, tc.expectedList, found)
+			assert.Equal(t, "wireless headphones", tc.mockStore.query)
+			assert.True(t, tc.mockStore.includeInternal, "the store should search the internal products")
 		})
 	}
 }
 
-func Test_ProductService_Update(t *testing.T) {
-	ErrProductNotFound := errors.New("product not found")
+func Test_ProductService_FindAllByCursor(t *testing.T) {
 	ErrStoreError := errors.New("store error")
-	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	id1, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	id2, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174002")
+	createdAt := time.Date(2025, 7, 1, 12, 0, 0, 123456000, time.UTC)
 	testCases := []struct {
-		name        string
-		mockStore   *mockProductStore
-		product     ProductDto
-		expected    *ProductDto
-		expectError error
+		name         string
+		mockStore    *mockProductStore
+		cursor       string
+		expectedPage *ProductPageDto
+		expectError  error
 	}{
 		{
-			name: "Success - product updated",
+			name: "Success - next page exists",
 			mockStore: &mockProductStore{
-				product: db.Product{ID: mockID, Name: "Updated Toy", Price: 150, StockQuantity: 20, Version: 2},
-				error:   nil,
+				products: []db.Product{
+					{ID: id2, Name: "Toy 2", CreatedAt: &createdAt},
+					{ID: id1, Name: "Toy 1", CreatedAt: &createdAt},
+				},
+			},
+			expectedPage: &ProductPageDto{
+				Items:      []ProductDto{{ID: id2.String(), Name: "Toy 2", CreatedAt: "2025-07-01T12:00:00.123456Z"}},
+				NextCursor: encodeCursor(cursor{CreatedAt: createdAt, ID: id2}),
 			},
-			product:     ProductDto{ID: mockID.String(), Name: "Updated Toy", Price: 150, Stock: 20, Version: 2},
-			expected:    &ProductDto{ID: mockID.String(), Name: "Updated Toy", Price: 150, Stock: 20, Version: 2},
-			expectError: nil,
 		},
 		{
-			name: "Error - product not found",
+			name: "Success - last page",
 			mockStore: &mockProductStore{
-				error: ErrProductNotFound,
+				products: []db.Product{{ID: id1, Name: "Toy 1", CreatedAt: &createdAt}},
 			},
-			product:     ProductDto{ID: mockID.String(), Name: "Updated Toy", Price: 150, Stock: 20, Version: 2},
-			expected:    nil,
-			expectError: ErrProductNotFound,
+			cursor: encodeCursor(cursor{CreatedAt: createdAt, ID: id2}),
+			expectedPage: &ProductPageDto{
+				Items: []ProductDto{{ID: id1.String(), Name: "Toy 1", CreatedAt: "2025-07-01T12:00:00.123456Z"}},
+			},
+		},
+		{
+			name:        "Error - invalid cursor",
+			mockStore:   &mockProductStore{},
+			cursor:      "not-a-cursor",
+			expectError: producterrors.ErrInvalidCursor,
 		},
 		{
 			name: "Error - store error",
 			mockStore: &mockProductStore{
 				error: ErrStoreError,
 			},
-			product:     ProductDto{ID: mockID.String(), Name: "Updated Toy", Price: 150, Stock: 20, Version: 2},
-			expected:    nil,
 			expectError: ErrStoreError,
 		},
 	}
@@ -310,65 +520,92 @@ func Test_ProductService_Update(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore)
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
 			// when
-			updated, err := service.Update(context.Background(), tc.product)
+			page, err := service.FindAllByCursor(context.Background(), tc.cursor, 1, false)
 			// then
 			if tc.expectError != nil {
 				assert.ErrorIs(t, err, tc.expectError)
-				assert.Nil(t, updated)
+				assert.Nil(t, page)
 				return
 			}
 			require.NoError(t, err)
-			assert.Equal(t, tc.expected, updated)
+			assert.Equal(t, tc.expectedPage, page)
 		})
 	}
 }
 
-func Test_ProductService_UpdateStock(t *testing.T) {
-	ErrProductNotFound := errors.New("product not found")
+func Test_cursor_EncodeDecode(t *testing.T) {
+	// given
+	expected := cursor{CreatedAt: time.Date(2025, 7, 1, 12, 0, 0, 123456000, time.UTC), ID: uuid.New()}
+	// when
+	decoded, err := decodeCursor(encodeCursor(expected))
+	// then
+	require.NoError(t, err)
+	assert.True(t, expected.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, expected.ID, decoded.ID)
+}
+
+func Test_ProductService_Create(t *testing.T) {
 	ErrStoreError := errors.New("store error")
 	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
 	testCases := []struct {
-		name        string
-		mockStore   *mockProductStore
-		productID   uuid.UUID
-		quantity    int32
-		version     int32
-		expected    *ProductDto
-		expectError error
+		name               string
+		mockStore          *mockProductStore
+		product            ProductCreateDto
+		expected           *ProductDto
+		expectedVisibility string
+		expectedCurrency   string
+		expectError        error
 	}{
 		{
-			name: "Success - stock updated",
+			name: "Success - product created",
 			mockStore: &mockProductStore{
-				product: db.Product{ID: mockID, Name: "Toy", StockQuantity: 15, Version: 2},
+				product: db.Product{ID: mockID, Name: "Toy", Price: 100, StockQuantity: 10, Currency: "USD"},
 				error:   nil,
 			},
-			productID:   mockID,
-			quantity:    15,
-			version:     1,
-			expected:    &ProductDto{ID: mockID.String(), Name: "Toy", Stock: 15, Version: 2},
-			expectError: nil,
+			product:            ProductCreateDto{Name: "Toy", Price: money.New(100, ""), Stock: 10},
+			expected:           &ProductDto{ID: mockID.String(), Name: "Toy", Price: money.New(100, "USD"), Stock: 10, Currency: "USD"},
+			expectedVisibility: store.VisibilityPublic,
+			expectedCurrency:   store.DefaultCurrency,
+			expectError:        nil,
 		},
 		{
-			name: "Error - product not found",
+			name: "Success - internal product created",
 			mockStore: &mockProductStore{
-				error: ErrProductNotFound,
+				product: db.Product{ID: mockID, Name: "Toy", Price: 100, StockQuantity: 10, Visibility: store.VisibilityInternal},
 			},
-			productID:   mockID,
-			quantity:    15,
-			version:     1,
-			expected:    nil,
-			expectError: ErrProductNotFound,
+			product:            ProductCreateDto{Name: "Toy", Price: money.New(100, ""), Stock: 10, Visibility: store.VisibilityInternal},
+			expected:           &ProductDto{ID: mockID.String(), Name: "Toy", Price: money.New(100, ""), Stock: 10, Visibility: store.VisibilityInternal},
+			expectedVisibility: store.VisibilityInternal,
+			expectedCurrency:   store.DefaultCurrency,
+		},
+		{
+			name: "Success - product priced in EUR",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID, Name: "Toy", Price: 100, StockQuantity: 10, Currency: "EUR"},
+			},
+			product:            ProductCreateDto{Name: "Toy", Price: money.New(100, ""), Stock: 10, Currency: "EUR"},
+			expected:           &ProductDto{ID: mockID.String(), Name: "Toy", Price: money.New(100, "EUR"), Stock: 10, Currency: "EUR"},
+			expectedVisibility: store.VisibilityPublic,
+			expectedCurrency:   "EUR",
+		},
+		{
+			name: "Success - product with a description",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID, Name: "Toy", Description: ptr("A wooden toy"), Price: 100, StockQuantity: 10},
+			},
+			product:            ProductCreateDto{Name: "Toy", Description: "A wooden toy", Price: money.New(100, ""), Stock: 10},
+			expected:           &ProductDto{ID: mockID.String(), Name: "Toy", Description: "A wooden toy", Price: money.New(100, ""), Stock: 10},
+			expectedVisibility: store.VisibilityPublic,
+			expectedCurrency:   store.DefaultCurrency,
 		},
 		{
 			name: "Error - store error",
 			mockStore: &mockProductStore{
 				error: ErrStoreError,
 			},
-			productID:   mockID,
-			quantity:    15,
-			version:     1,
+			product:     ProductCreateDto{Name: "Toy", Price: money.New(100, ""), Stock: 10},
 			expected:    nil,
 			expectError: ErrStoreError,
 		},
@@ -377,53 +614,172 @@ func Test_ProductService_UpdateStock(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore)
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
 			// when
-			updated, err := service.UpdateStock(context.Background(), tc.productID, tc.quantity, tc.version)
+			created, err := service.Create(context.Background(), tc.product)
 			// then
 			if tc.expectError != nil {
 				assert.ErrorIs(t, err, tc.expectError)
-				assert.Nil(t, updated)
+				assert.Nil(t, created)
 				return
 			}
 			require.NoError(t, err)
-			assert.Equal(t, tc.expected, updated)
+			assert.Equal(t, tc.expected, created)
+			assert.Equal(t, tc.expectedVisibility, tc.mockStore.visibility)
+			assert.Equal(t, tc.expectedCurrency, tc.mockStore.currency)
+			assert.Equal(t, descriptionOrNil(tc.product.Description), tc.mockStore.description)
 		})
 	}
 }
 
-func Test_ProductService_DeleteByID(t *testing.T) {
-	ErrProductNotFound := errors.New("product not found")
+func Test_ProductService_CreateBatch(t *testing.T) {
 	ErrStoreError := errors.New("store error")
-	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	firstID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	secondID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
 	testCases := []struct {
 		name        string
 		mockStore   *mockProductStore
-		productID   uuid.UUID
+		products    []ProductCreateDto
+		expected    []ProductDto
 		expectError error
 	}{
 		{
-			name: "Success - product deleted",
+			name: "Success - products created",
 			mockStore: &mockProductStore{
-				error: nil,
+				products: []db.Product{
+					{ID: firstID, Name: "Toy", Price: 100, StockQuantity: 10, Version: 1},
+					{ID: secondID, Name: "Ball", Price: 50, StockQuantity: 5, Version: 1},
+				},
+			},
+			products: []ProductCreateDto{{Name: "Toy", Price: money.New(100, ""), Stock: 10}, {Name: "Ball", Price: money.New(50, ""), Stock: 5}},
+			expected: []ProductDto{
+				{ID: firstID.String(), Name: "Toy", Price: money.New(100, ""), Stock: 10, Version: 1},
+				{ID: secondID.String(), Name: "Ball", Price: money.New(50, ""), Stock: 5, Version: 1},
 			},
-			productID:   mockID,
-			expectError: nil,
 		},
 		{
-			name: "Error - product not found",
+			name: "Error - store error",
 			mockStore: &mockProductStore{
-				error: ErrProductNotFound,
+				error: ErrStoreError,
 			},
-			productID:   mockID,
-			expectError: ErrProductNotFound,
+			products:    []ProductCreateDto{{Name: "Toy", Price: money.New(100, ""), Stock: 10}},
+			expectError: ErrStoreError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
+			// when
+			created, err := service.CreateBatch(context.Background(), tc.products)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, created)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, created)
+		})
+	}
+}
+
+func Test_ProductService_CheckBatch(t *testing.T) {
+	ErrStoreError := errors.New("store error")
+	testCases := []struct {
+		name        string
+		mockStore   *mockProductStore
+		products    []ProductCreateDto
+		expected    []error
+		expectError error
+	}{
+		{
+			name:      "Success - no name taken",
+			mockStore: &mockProductStore{},
+			products:  []ProductCreateDto{{Name: "Toy", Price: money.New(100, ""), Stock: 10}, {Name: "Ball", Price: money.New(50, ""), Stock: 5}},
+			expected:  []error{nil, nil},
+		},
+		{
+			name:      "Success - names taken by a live product and an earlier product of the batch, ignoring the case",
+			mockStore: &mockProductStore{takenNames: []string{"Toy"}},
+			products: []ProductCreateDto{
+				{Name: "toy", Price: money.New(100, ""), Stock: 10},
+				{Name: "Ball", Price: money.New(50, ""), Stock: 5},
+				{Name: "BALL", Price: money.New(60, ""), Stock: 1},
+			},
+			expected: []error{producterrors.ErrProductAlreadyExists, nil, producterrors.ErrProductAlreadyExists},
+		},
+		{
+			name:        "Error - store error",
+			mockStore:   &mockProductStore{error: ErrStoreError},
+			products:    []ProductCreateDto{{Name: "Toy", Price: money.New(100, ""), Stock: 10}},
+			expectError: ErrStoreError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			publisher := &PublisherMock{}
+			recorder := audit.NewPublishingRecorder(publisher, slog.New(slog.DiscardHandler))
+			service := NewService(tc.mockStore, recorder, config.ProductsConfig{})
+			// when
+			checks, err := service.CheckBatch(context.Background(), tc.products)
+			// then
+			assert.False(t, tc.mockStore.batchCreated, "no product should be created")
+			assert.Empty(t, publisher.published, "no audit event should be recorded")
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, checks)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, checks)
+			assert.Len(t, tc.mockStore.checkedNames, len(tc.products), "every name should be checked")
+		})
+	}
+}
+
+func Test_ProductService_UpsertBySKU(t *testing.T) {
+	ErrStoreError := errors.New("store error")
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	sku := "ERP-1001"
+	testCases := []struct {
+		name            string
+		mockStore       *mockProductStore
+		product         ProductCreateDto
+		expected        *ProductDto
+		expectedCreated bool
+		expectedAction  string
+		expectError     error
+	}{
+		{
+			name: "Success - product created",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID, Name: "Toy", Price: 100, StockQuantity: 10, Version: 1, Currency: "USD", Sku: &sku},
+				created: true,
+			},
+			product:         ProductCreateDto{Name: "Toy", Price: money.New(100, ""), Stock: 10},
+			expected:        &ProductDto{ID: mockID.String(), Name: "Toy", Price: money.New(100, "USD"), Stock: 10, Version: 1, Currency: "USD", SKU: sku},
+			expectedCreated: true,
+			expectedAction:  audit.ActionCreate,
+		},
+		{
+			name: "Success - product updated",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID, Name: "Toy", Price: 200, StockQuantity: 5, Version: 2, Currency: "USD", Sku: &sku},
+			},
+			product:        ProductCreateDto{Name: "Toy", Price: money.New(200, ""), Stock: 5},
+			expected:       &ProductDto{ID: mockID.String(), Name: "Toy", Price: money.New(200, "USD"), Stock: 5, Version: 2, Currency: "USD", SKU: sku},
+			expectedAction: audit.ActionUpdate,
 		},
 		{
 			name: "Error - store error",
 			mockStore: &mockProductStore{
 				error: ErrStoreError,
 			},
-			productID:   mockID,
+			product:     ProductCreateDto{Name: "Toy", Price: money.New(100, ""), Stock: 10},
 			expectError: ErrStoreError,
 		},
 	}
@@ -431,15 +787,778 @@ func Test_ProductService_DeleteByID(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
-			service := NewService(tc.mockStore)
+			publisher := &PublisherMock{}
+			recorder := audit.NewPublishingRecorder(publisher, slog.New(slog.DiscardHandler))
+			service := NewService(tc.mockStore, recorder, config.ProductsConfig{})
 			// when
-			err := service.DeleteByID(context.Background(), tc.productID, 1)
+			upserted, created, err := service.UpsertBySKU(context.Background(), sku, tc.product)
 			// then
 			if tc.expectError != nil {
 				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, upserted)
+				assert.Empty(t, publisher.published)
 				return
 			}
 			require.NoError(t, err)
+			assert.Equal(t, tc.expected, upserted)
+			assert.Equal(t, tc.expectedCreated, created)
+			assert.Equal(t, sku, tc.mockStore.sku)
+			assert.Equal(t, store.VisibilityPublic, tc.mockStore.visibility)
+			assert.Equal(t, store.DefaultCurrency, tc.mockStore.currency)
+			require.Len(t, publisher.published, 1)
+			event, ok := publisher.published[0].(events.AuditEvent)
+			require.True(t, ok)
+			assert.Equal(t, tc.expectedAction, event.Action)
+			assert.Equal(t, mockID.String(), event.ResourceID)
 		})
 	}
 }
+
+func Test_ProductService_Update(t *testing.T) {
+	ErrProductNotFound := errors.New("product not found")
+	ErrStoreError := errors.New("store error")
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	testCases := []struct {
+		name        string
+		mockStore   *mockProductStore
+		product     ProductDto
+		expected    *ProductDto
+		expectError error
+	}{
+		{
+			name: "Success - product updated",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID, Name: "Updated Toy", Price: 150, StockQuantity: 20, Version: 2},
+				error:   nil,
+			},
+			product:     ProductDto{ID: mockID.String(), Name: "Updated Toy", Price: money.New(150, ""), Stock: 20, Version: 2},
+			expected:    &ProductDto{ID: mockID.String(), Name: "Updated Toy", Price: money.New(150, ""), Stock: 20, Version: 2},
+			expectError: nil,
+		},
+		{
+			name: "Error - product not found",
+			mockStore: &mockProductStore{
+				error: ErrProductNotFound,
+			},
+			product:     ProductDto{ID: mockID.String(), Name: "Updated Toy", Price: money.New(150, ""), Stock: 20, Version: 2},
+			expected:    nil,
+			expectError: ErrProductNotFound,
+		},
+		{
+			name: "Error - store error",
+			mockStore: &mockProductStore{
+				error: ErrStoreError,
+			},
+			product:     ProductDto{ID: mockID.String(), Name: "Updated Toy", Price: money.New(150, ""), Stock: 20, Version: 2},
+			expected:    nil,
+			expectError: ErrStoreError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
+			// when
+			updated, err := service.Update(context.Background(), tc.product)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, updated)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, updated)
+		})
+	}
+}
+
+func Test_ProductService_Patch(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	current := db.Product{ID: mockID, Name: "Toy", Description: ptr("A wooden toy"), Price: 100, StockQuantity: 10, Version: 1}
+	name, price, stock := "Renamed Toy", money.New(150, ""), int32(0)
+	description, noDescription := "A painted wooden toy", ""
+	testCases := []struct {
+		name            string
+		patch           ProductPatchDto
+		storeError      error
+		expectedUpdated *db.Product
+		expectError     error
+	}{
+		{
+			name:            "only the price provided",
+			patch:           ProductPatchDto{Price: &price, Version: 1},
+			expectedUpdated: &db.Product{ID: mockID, Name: "Toy", Description: ptr("A wooden toy"), Price: 150, StockQuantity: 10, Version: 1},
+		},
+		{
+			name:            "name and stock provided",
+			patch:           ProductPatchDto{Name: &name, Stock: &stock, Version: 1},
+			expectedUpdated: &db.Product{ID: mockID, Name: "Renamed Toy", Description: ptr("A wooden toy"), Price: 100, StockQuantity: 0, Version: 1},
+		},
+		{
+			name:            "description provided",
+			patch:           ProductPatchDto{Description: &description, Version: 1},
+			expectedUpdated: &db.Product{ID: mockID, Name: "Toy", Description: ptr("A painted wooden toy"), Price: 100, StockQuantity: 10, Version: 1},
+		},
+		{
+			name:            "empty description clears it",
+			patch:           ProductPatchDto{Description: &noDescription, Version: 1},
+			expectedUpdated: &db.Product{ID: mockID, Name: "Toy", Price: 100, StockQuantity: 10, Version: 1},
+		},
+		{
+			name:  "no fields provided",
+			patch: ProductPatchDto{Version: 1},
+		},
+		{
+			name:        "stale version",
+			patch:       ProductPatchDto{Price: &price, Version: 2},
+			expectError: producterrors.ErrOptimisticLock,
+		},
+		{
+			name:        "product not found",
+			patch:       ProductPatchDto{Price: &price, Version: 1},
+			storeError:  producterrors.ErrProductNotFound,
+			expectError: producterrors.ErrProductNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockStore := &mockProductStore{product: current, error: tc.storeError}
+			service := NewService(mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
+
+			// when
+			patched, err := service.Patch(context.Background(), mockID, tc.patch)
+
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, patched)
+				assert.Nil(t, mockStore.updated, "the product shouldn't be updated")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedUpdated, mockStore.updated, "only the provided fields should be updated")
+			assert.Equal(t, mockID.String(), patched.ID)
+		})
+	}
+}
+
+func Test_ProductService_UpdateStock(t *testing.T) {
+	ErrProductNotFound := errors.New("product not found")
+	ErrStoreError := errors.New("store error")
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	testCases := []struct {
+		name        string
+		mockStore   *mockProductStore
+		productID   uuid.UUID
+		quantity    int32
+		version     int32
+		expected    *ProductDto
+		expectError error
+	}{
+		{
+			name: "Success - stock updated",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID, Name: "Toy", StockQuantity: 15, Version: 2},
+				error:   nil,
+			},
+			productID:   mockID,
+			quantity:    15,
+			version:     1,
+			expected:    &ProductDto{ID: mockID.String(), Name: "Toy", Stock: 15, Version: 2},
+			expectError: nil,
+		},
+		{
+			name: "Error - product not found",
+			mockStore: &mockProductStore{
+				error: ErrProductNotFound,
+			},
+			productID:   mockID,
+			quantity:    15,
+			version:     1,
+			expected:    nil,
+			expectError: ErrProductNotFound,
+		},
+		{
+			name: "Error - store error",
+			mockStore: &mockProductStore{
+				error: ErrStoreError,
+			},
+			productID:   mockID,
+			quantity:    15,
+			version:     1,
+			expected:    nil,
+			expectError: ErrStoreError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
+			// when
+			updated, err := service.UpdateStock(context.Background(), tc.productID, tc.quantity, tc.version, nil)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, updated)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, updated)
+		})
+	}
+}
+
+func Test_ProductService_AdjustStock(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	testCases := []struct {
+		name        string
+		mockStore   *mockProductStore
+		delta       int32
+		expected    *ProductDto
+		expectError error
+	}{
+		{
+			name: "Success - stock adjusted",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID, Name: "Toy", StockQuantity: 12, Version: 2},
+			},
+			delta:    -3,
+			expected: &ProductDto{ID: mockID.String(), Name: "Toy", Stock: 12, Version: 2},
+		},
+		{
+			name:        "Error - insufficient stock",
+			mockStore:   &mockProductStore{error: producterrors.ErrInsufficientStock},
+			delta:       -100,
+			expectError: producterrors.ErrInsufficientStock,
+		},
+		{
+			name:        "Error - version conflict",
+			mockStore:   &mockProductStore{error: producterrors.ErrOptimisticLock},
+			delta:       5,
+			expectError: producterrors.ErrOptimisticLock,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
+			// when
+			updated, err := service.AdjustStock(context.Background(), mockID, tc.delta, 1)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, updated)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, updated)
+		})
+	}
+}
+
+func Test_ProductService_ReserveStock(t *testing.T) {
+	quantities := map[uuid.UUID]int32{uuid.New(): 2, uuid.New(): 1}
+	testCases := []struct {
+		name        string
+		mockStore   *mockProductStore
+		expectError error
+	}{
+		{name: "Success - stock reserved", mockStore: &mockProductStore{}},
+		{name: "Error - insufficient stock", mockStore: &mockProductStore{error: producterrors.ErrInsufficientStock}, expectError: producterrors.ErrInsufficientStock},
+		{name: "Error - product not found", mockStore: &mockProductStore{error: producterrors.ErrProductNotFound}, expectError: producterrors.ErrProductNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
+			// when
+			err := service.ReserveStock(context.Background(), quantities)
+			// then
+			assert.ErrorIs(t, err, tc.expectError)
+			assert.Equal(t, quantities, tc.mockStore.reserved)
+		})
+	}
+}
+
+func Test_ProductService_ReleaseStock(t *testing.T) {
+	quantities := map[uuid.UUID]int32{uuid.New(): 2, uuid.New(): 1}
+	testCases := []struct {
+		name        string
+		mockStore   *mockProductStore
+		expectError error
+	}{
+		{name: "Success - stock released", mockStore: &mockProductStore{}},
+		{name: "Error - product not found", mockStore: &mockProductStore{error: producterrors.ErrProductNotFound}, expectError: producterrors.ErrProductNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
+			// when
+			err := service.ReleaseStock(context.Background(), quantities)
+			// then
+			assert.ErrorIs(t, err, tc.expectError)
+			assert.Equal(t, quantities, tc.mockStore.released)
+		})
+	}
+}
+
+func Test_ProductService_DeleteByID(t *testing.T) {
+	ErrProductNotFound := errors.New("product not found")
+	ErrStoreError := errors.New("store error")
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	testCases := []struct {
+		name        string
+		mockStore   *mockProductStore
+		productID   uuid.UUID
+		expectError error
+	}{
+		{
+			name: "Success - product deleted",
+			mockStore: &mockProductStore{
+				error: nil,
+			},
+			productID:   mockID,
+			expectError: nil,
+		},
+		{
+			name: "Error - product not found",
+			mockStore: &mockProductStore{
+				error: ErrProductNotFound,
+			},
+			productID:   mockID,
+			expectError: ErrProductNotFound,
+		},
+		{
+			name: "Error - store error",
+			mockStore: &mockProductStore{
+				error: ErrStoreError,
+			},
+			productID:   mockID,
+			expectError: ErrStoreError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
+			// when
+			err := service.DeleteByID(context.Background(), tc.productID, 1)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func Test_ProductService_DeleteByID_SoftDelete(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	testCases := []struct {
+		name                string
+		cfg                 config.ProductsConfig
+		expectedSoftDeleted bool
+	}{
+		{
+			name:                "soft delete enabled - product marked as deleted",
+			cfg:                 config.ProductsConfig{SoftDelete: true},
+			expectedSoftDeleted: true,
+		},
+		{
+			name:                "soft delete disabled - product removed",
+			cfg:                 config.ProductsConfig{SoftDelete: false},
+			expectedSoftDeleted: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			store := &mockProductStore{}
+			service := NewService(store, audit.NoopRecorder{}, tc.cfg)
+			// when
+			err := service.DeleteByID(context.Background(), mockID, 1)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSoftDeleted, store.softDeleted)
+		})
+	}
+}
+
+func Test_ProductService_DeleteByID_Audit(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	actorID := "8f14e45f-ceea-467f-a0e6-7c2bdc1f0a6b"
+	testCases := []struct {
+		name      string
+		mockStore *mockProductStore
+		ctx       context.Context
+		expected  []events.AuditEvent
+	}{
+		{
+			name:      "Success - audit event published",
+			mockStore: &mockProductStore{},
+			ctx:       web.WithUser(context.Background(), web.User{ID: actorID}),
+			expected: []events.AuditEvent{
+				{Actor: actorID, Action: audit.ActionDelete, Resource: "product", ResourceID: mockID.String()},
+			},
+		},
+		{
+			name:      "Success - anonymous actor",
+			mockStore: &mockProductStore{},
+			ctx:       context.Background(),
+			expected: []events.AuditEvent{
+				{Actor: audit.AnonymousActor, Action: audit.ActionDelete, Resource: "product", ResourceID: mockID.String()},
+			},
+		},
+		{
+			name:      "Error - no audit event when delete fails",
+			mockStore: &mockProductStore{error: errors.New("store error")},
+			ctx:       context.Background(),
+			expected:  nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			publisher := &PublisherMock{}
+			recorder := audit.NewPublishingRecorder(publisher, slog.New(slog.DiscardHandler))
+			service := NewService(tc.mockStore, recorder, config.ProductsConfig{})
+			// when
+			_ = service.DeleteByID(tc.ctx, mockID, 1)
+			// then
+			require.Len(t, publisher.published, len(tc.expected))
+			for i, expected := range tc.expected {
+				event, ok := publisher.published[i].(events.AuditEvent)
+				require.True(t, ok)
+				assert.Equal(t, expected.Actor, event.Actor)
+				assert.Equal(t, expected.Action, event.Action)
+				assert.Equal(t, expected.Resource, event.Resource)
+				assert.Equal(t, expected.ResourceID, event.ResourceID)
+				assert.False(t, event.At.IsZero())
+			}
+		})
+	}
+}
+
+// contendedProductStore simulates a database where a stock update fails with a version conflict
+// if another update of the same product is in flight.
+type contendedProductStore struct {
+	mockProductStore
+	inFlight  atomic.Int32
+	conflicts atomic.Int32
+}
+
+func (m *contendedProductStore) UpdateStock(_ context.Context, id uuid.UUID, stock int32, version int32, _ *time.Time) (*db.Product, error) {
+	if err := m.update(); err != nil {
+		return nil, err
+	}
+	return &db.Product{ID: id, StockQuantity: stock, Version: version + 1}, nil
+}
+
+func (m *contendedProductStore) ReserveStock(context.Context, map[uuid.UUID]int32) error {
+	return m.update()
+}
+
+// update fails with ErrOptimisticLock if another update is in flight.
+func (m *contendedProductStore) update() error {
+	defer m.inFlight.Add(-1)
+	if m.inFlight.Add(1) > 1 {
+		m.conflicts.Add(1)
+		return producterrors.ErrOptimisticLock
+	}
+	time.Sleep(5 * time.Millisecond)
+	return nil
+}
+
+func Test_ProductService_UpdateStock_StockLock(t *testing.T) {
+	const workers = 10
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	// updateConcurrently runs concurrent stock updates of the same product and returns the number of version conflicts.
+	updateConcurrently := func(t *testing.T, cfg config.ProductsConfig) int32 {
+		t.Helper()
+		store := &contendedProductStore{}
+		service := NewService(store, audit.NoopRecorder{}, cfg)
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				_, _ = service.UpdateStock(context.Background(), mockID, 10, 1, nil)
+			}()
+		}
+		close(start)
+		wg.Wait()
+		return store.conflicts.Load()
+	}
+
+	// when
+	unlocked := updateConcurrently(t, config.ProductsConfig{StockLock: false})
+	locked := updateConcurrently(t, config.ProductsConfig{StockLock: true})
+
+	// then
+	assert.Positive(t, unlocked, "concurrent updates without the lock should conflict")
+	assert.Zero(t, locked, "updates serialized by the lock should not conflict")
+}
+
+func Test_ProductService_ReserveStock_StockLock(t *testing.T) {
+	const workers = 10
+	firstID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	secondID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	// reserveConcurrently runs concurrent reservations of the same products and returns the number of version conflicts.
+	reserveConcurrently := func(t *testing.T, cfg config.ProductsConfig) int32 {
+		t.Helper()
+		store := &contendedProductStore{}
+		service := NewService(store, audit.NoopRecorder{}, cfg)
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				_ = service.ReserveStock(context.Background(), map[uuid.UUID]int32{firstID: 1, secondID: 1})
+			}()
+		}
+		close(start)
+		wg.Wait()
+		return store.conflicts.Load()
+	}
+
+	// when
+	unlocked := reserveConcurrently(t, config.ProductsConfig{StockLock: false})
+	locked := reserveConcurrently(t, config.ProductsConfig{StockLock: true})
+
+	// then
+	assert.Positive(t, unlocked, "concurrent reservations without the lock should conflict")
+	assert.Zero(t, locked, "reservations serialized by the lock should neither conflict nor deadlock")
+}
+
+func Test_ProductService_UpdateStock_StockLockContextCancelled(t *testing.T) {
+	// given
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	service := NewService(&mockProductStore{}, audit.NoopRecorder{}, config.ProductsConfig{StockLock: true})
+	unlock, err := service.stockLocks.lock(context.Background(), mockID)
+	require.NoError(t, err)
+	defer unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// when
+	updated, err := service.UpdateStock(ctx, mockID, 10, 1, nil)
+
+	// then
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, updated)
+}
+
+func Test_ProductService_PriceHistory(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	week := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name        string
+		mockStore   *mockProductStore
+		interval    string
+		from        time.Time
+		to          time.Time
+		expected    *PriceHistoryDto
+		expectError error
+	}{
+		{
+			name: "Success - buckets aggregated",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID},
+				buckets: []db.AggregatePriceHistoryRow{{BucketStart: &week, MinPrice: 100, MaxPrice: 200, AvgPrice: 150, Changes: 2}},
+			},
+			interval: IntervalWeek,
+			from:     from,
+			to:       to,
+			expected: &PriceHistoryDto{
+				ProductID: mockID.String(),
+				Interval:  IntervalWeek,
+				From:      from,
+				To:        to,
+				Buckets:   []PriceBucketDto{{Start: week, MinPrice: 100, MaxPrice: 200, AvgPrice: 150, Changes: 2}},
+			},
+		},
+		{
+			name: "Success - no price changes",
+			mockStore: &mockProductStore{
+				product: db.Product{ID: mockID},
+				buckets: []db.AggregatePriceHistoryRow{},
+			},
+			interval: IntervalDay,
+			from:     from,
+			to:       to,
+			expected: &PriceHistoryDto{
+				ProductID: mockID.String(),
+				Interval:  IntervalDay,
+				From:      from,
+				To:        to,
+				Buckets:   []PriceBucketDto{},
+			},
+		},
+		{
+			name:        "Error - invalid interval",
+			mockStore:   &mockProductStore{},
+			interval:    "month",
+			from:        from,
+			to:          to,
+			expectError: producterrors.ErrInvalidInterval,
+		},
+		{
+			name:        "Error - from is not before to",
+			mockStore:   &mockProductStore{},
+			interval:    IntervalDay,
+			from:        to,
+			to:          from,
+			expectError: producterrors.ErrInvalidTimeRange,
+		},
+		{
+			name:        "Error - range exceeds the max range",
+			mockStore:   &mockProductStore{},
+			interval:    IntervalDay,
+			from:        from.AddDate(-1, 0, 0),
+			to:          to,
+			expectError: producterrors.ErrInvalidTimeRange,
+		},
+		{
+			name:        "Error - product not found",
+			mockStore:   &mockProductStore{error: producterrors.ErrProductNotFound},
+			interval:    IntervalDay,
+			from:        from,
+			to:          to,
+			expectError: producterrors.ErrProductNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{PriceHistoryMaxRange: 90 * 24 * time.Hour})
+			// when
+			history, err := service.PriceHistory(context.Background(), mockID, tc.interval, tc.from, tc.to)
+			// then
+			if tc.expectError != nil {
+				assert.ErrorIs(t, err, tc.expectError)
+				assert.Nil(t, history)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, history)
+		})
+	}
+}
+
+func Test_ProductService_PriceHistory_DefaultRange(t *testing.T) {
+	// given
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	maxRange := 30 * 24 * time.Hour
+	service := NewService(&mockProductStore{product: db.Product{ID: mockID}}, audit.NoopRecorder{}, config.ProductsConfig{PriceHistoryMaxRange: maxRange})
+
+	// when
+	history, err := service.PriceHistory(context.Background(), mockID, IntervalDay, time.Time{}, time.Time{})
+
+	// then
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), history.To, time.Second, "to should default to now")
+	assert.Equal(t, maxRange, history.To.Sub(history.From), "from should default to the max range before to")
+	assert.Equal(t, time.UTC, history.To.Location(), "the range should be in UTC")
+}
+
+func Test_ProductService_History(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	created := time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	testCases := []struct {
+		name        string
+		mockStore   *mockProductStore
+		expected    *ProductHistoryDto
+		expectError error
+	}{
+		{
+			name: "Success - changes converted",
+			mockStore: &mockProductStore{changes: []store.ProductChange{
+				{
+					Action:    audit.ActionCreate,
+					New:       &store.ProductValues{Name: "Toy", Price: 100, StockQuantity: 10},
+					Version:   1,
+					ChangedAt: created,
+				},
+				{
+					Action:    audit.ActionUpdate,
+					Old:       &store.ProductValues{Name: "Toy", Price: 100, StockQuantity: 10},
+					New:       &store.ProductValues{Name: "Toy", Price: 150, StockQuantity: 10},
+					Version:   2,
+					ChangedAt: updated,
+				},
+			}},
+			expected: &ProductHistoryDto{
+				ProductID: mockID.String(),
+				Changes: []ProductChangeDto{
+					{
+						Action:    audit.ActionCreate,
+						Version:   1,
+						ChangedAt: created,
+						New:       &ProductValuesDto{Name: "Toy", Price: 100, Stock: 10},
+					},
+					{
+						Action:    audit.ActionUpdate,
+						Version:   2,
+						ChangedAt: updated,
+						Old:       &ProductValuesDto{Name: "Toy", Price: 100, Stock: 10},
+						New:       &ProductValuesDto{Name: "Toy", Price: 150, Stock: 10},
+					},
+				},
+			},
+		},
+		{
+			name:        "Error - no changes recorded",
+			mockStore:   &mockProductStore{changes: []store.ProductChange{}},
+			expectError: producterrors.ErrProductNotFound,
+		},
+		{
+			name:        "Error - store failure",
+			mockStore:   &mockProductStore{error: errors.New("db error")},
+			expectError: errors.New("db error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			service := NewService(tc.mockStore, audit.NoopRecorder{}, config.ProductsConfig{})
+
+			// when
+			history, err := service.History(context.Background(), mockID)
+
+			// then
+			if tc.expectError != nil {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectError.Error())
+				assert.Nil(t, history)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, history)
+		})
+	}
+}
+
+// ptr returns a pointer to a copy of v.
+func ptr[T any](v T) *T {
+	return &v
+}
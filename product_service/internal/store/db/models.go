@@ -17,4 +17,28 @@ type Product struct {
 	StockQuantity int32      `json:"stock_quantity"`
 	Version       int32      `json:"version"`
 	CreatedAt     *time.Time `json:"created_at"`
+	RestockAt     *time.Time `json:"restock_at"`
+	DeletedAt     *time.Time `json:"deleted_at"`
+	Visibility    string     `json:"visibility"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+	Currency      string     `json:"currency"`
+	Description   *string    `json:"description"`
+	SearchVector  string     `json:"search_vector"`
+	Sku           *string    `json:"sku"`
+}
+
+type ProductAudit struct {
+	ID        int64      `json:"id"`
+	ProductID uuid.UUID  `json:"product_id"`
+	Action    string     `json:"action"`
+	OldValues []byte     `json:"old_values"`
+	NewValues []byte     `json:"new_values"`
+	Version   int32      `json:"version"`
+	ChangedAt *time.Time `json:"changed_at"`
+}
+
+type ProductPriceHistory struct {
+	ProductID  uuid.UUID  `json:"product_id"`
+	Price      int64      `json:"price"`
+	RecordedAt *time.Time `json:"recorded_at"`
 }
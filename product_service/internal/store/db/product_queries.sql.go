@@ -7,27 +7,196 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const addStock = `-- name: AddStock :one
+UPDATE products
+SET stock_quantity = stock_quantity + $1::int,
+    version        = version + 1,
+    updated_at     = NOW()
+WHERE id = $2 AND deleted_at IS NULL
+  AND stock_quantity::bigint + $1::int BETWEEN 0 AND 2147483647
+RETURNING id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
+`
+
+type AddStockParams struct {
+	Delta int32     `json:"delta"`
+	ID    uuid.UUID `json:"id"`
+}
+
+func (q *Queries) AddStock(ctx context.Context, arg AddStockParams) (Product, error) {
+	row := q.db.QueryRow(ctx, addStock, arg.Delta, arg.ID)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Price,
+		&i.StockQuantity,
+		&i.Version,
+		&i.CreatedAt,
+		&i.RestockAt,
+		&i.DeletedAt,
+		&i.Visibility,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Description,
+		&i.SearchVector,
+		&i.Sku,
+	)
+	return i, err
+}
+
+const adjustStock = `-- name: AdjustStock :one
+UPDATE products
+SET stock_quantity = stock_quantity + $1::int,
+    version        = version + 1,
+    updated_at     = NOW()
+WHERE id = $2 AND VERSION = $3 AND deleted_at IS NULL
+  AND stock_quantity::bigint + $1::int BETWEEN 0 AND 2147483647
+RETURNING id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
+`
+
+type AdjustStockParams struct {
+	Delta   int32     `json:"delta"`
+	ID      uuid.UUID `json:"id"`
+	Version int32     `json:"version"`
+}
+
+func (q *Queries) AdjustStock(ctx context.Context, arg AdjustStockParams) (Product, error) {
+	row := q.db.QueryRow(ctx, adjustStock,
+		arg.Delta,
+		arg.ID,
+		arg.Version,
+	)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Price,
+		&i.StockQuantity,
+		&i.Version,
+		&i.CreatedAt,
+		&i.RestockAt,
+		&i.DeletedAt,
+		&i.Visibility,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Description,
+		&i.SearchVector,
+		&i.Sku,
+	)
+	return i, err
+}
+
+const aggregatePriceHistory = `-- name: AggregatePriceHistory :many
+WITH buckets AS (SELECT b::timestamp                                                      AS bucket_start,
+                        GREATEST(b, $1::timestamp)                                AS window_start,
+                        LEAST(b + ('1 ' || $2::text)::interval, $3::timestamp) AS window_end
+                 FROM generate_series(date_trunc($2::text, $1::timestamp),
+                                      $3::timestamp - interval '1 microsecond',
+                                      ('1 ' || $2::text)::interval) AS b)
+SELECT buckets.bucket_start,
+       MIN(prices.price)::bigint                                                     AS min_price,
+       MAX(prices.price)::bigint                                                     AS max_price,
+       AVG(prices.price)::float8                                                     AS avg_price,
+       COUNT(prices.price) FILTER (WHERE prices.recorded_at >= buckets.window_start) AS changes
+FROM buckets
+         LEFT JOIN LATERAL ((SELECT h.price, h.recorded_at
+                             FROM product_price_history h
+                             WHERE h.product_id = $4
+                               AND h.recorded_at <= buckets.window_start
+                             ORDER BY h.recorded_at DESC
+                             LIMIT 1)
+                            UNION ALL
+                            (SELECT h.price, h.recorded_at
+                             FROM product_price_history h
+                             WHERE h.product_id = $4
+                               AND h.recorded_at > buckets.window_start
+                               AND h.recorded_at < buckets.window_end)) prices ON TRUE
+GROUP BY buckets.bucket_start
+HAVING COUNT(prices.price) > 0
+ORDER BY buckets.bucket_start
+`
+
+type AggregatePriceHistoryParams struct {
+	FromTime  *time.Time `json:"from_time"`
+	Bucket    string     `json:"bucket"`
+	ToTime    *time.Time `json:"to_time"`
+	ProductID uuid.UUID  `json:"product_id"`
+}
+
+type AggregatePriceHistoryRow struct {
+	BucketStart *time.Time `json:"bucket_start"`
+	MinPrice    int64      `json:"min_price"`
+	MaxPrice    int64      `json:"max_price"`
+	AvgPrice    float64    `json:"avg_price"`
+	Changes     int64      `json:"changes"`
+}
+
+func (q *Queries) AggregatePriceHistory(ctx context.Context, arg AggregatePriceHistoryParams) ([]AggregatePriceHistoryRow, error) {
+	rows, err := q.db.Query(ctx, aggregatePriceHistory,
+		arg.FromTime,
+		arg.Bucket,
+		arg.ToTime,
+		arg.ProductID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AggregatePriceHistoryRow{}
+	for rows.Next() {
+		var i AggregatePriceHistoryRow
+		if err := rows.Scan(
+			&i.BucketStart,
+			&i.MinPrice,
+			&i.MaxPrice,
+			&i.AvgPrice,
+			&i.Changes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const create = `-- name: Create :one
 INSERT INTO products (name,
+                      description,
                       price,
-                      stock_quantity
+                      stock_quantity,
+                      visibility,
+                      currency
                       )
-VALUES ($1, $2, $3)
-RETURNING id, name, price, stock_quantity, version, created_at
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
 `
 
 type CreateParams struct {
-	Name          string `json:"name"`
-	Price         int64  `json:"price"`
-	StockQuantity int32  `json:"stock_quantity"`
+	Name          string  `json:"name"`
+	Description   *string `json:"description"`
+	Price         int64   `json:"price"`
+	StockQuantity int32   `json:"stock_quantity"`
+	Visibility    string  `json:"visibility"`
+	Currency      string  `json:"currency"`
 }
 
 func (q *Queries) Create(ctx context.Context, arg CreateParams) (Product, error) {
-	row := q.db.QueryRow(ctx, create, arg.Name, arg.Price, arg.StockQuantity)
+	row := q.db.QueryRow(ctx, create,
+		arg.Name,
+		arg.Description,
+		arg.Price,
+		arg.StockQuantity,
+		arg.Visibility,
+		arg.Currency,
+	)
 	var i Product
 	err := row.Scan(
 		&i.ID,
@@ -36,6 +205,14 @@ func (q *Queries) Create(ctx context.Context, arg CreateParams) (Product, error)
 		&i.StockQuantity,
 		&i.Version,
 		&i.CreatedAt,
+		&i.RestockAt,
+		&i.DeletedAt,
+		&i.Visibility,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Description,
+		&i.SearchVector,
+		&i.Sku,
 	)
 	return i, err
 }
@@ -60,19 +237,22 @@ func (q *Queries) Delete(ctx context.Context, arg DeleteParams) (int64, error) {
 }
 
 const findAll = `-- name: FindAll :many
-SELECT id, name, price, stock_quantity, version, created_at
+SELECT id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
 FROM products
+WHERE deleted_at IS NULL
+  AND (visibility = 'public' OR $1::bool)
 ORDER BY created_at DESC
-LIMIT $1 OFFSET $2
+LIMIT $2 OFFSET $3
 `
 
 type FindAllParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+	IncludeInternal bool  `json:"include_internal"`
+	Lim             int32 `json:"lim"`
+	Off             int32 `json:"off"`
 }
 
 func (q *Queries) FindAll(ctx context.Context, arg FindAllParams) ([]Product, error) {
-	rows, err := q.db.Query(ctx, findAll, arg.Limit, arg.Offset)
+	rows, err := q.db.Query(ctx, findAll, arg.IncludeInternal, arg.Lim, arg.Off)
 	if err != nil {
 		return nil, err
 	}
@@ -87,6 +267,49 @@ func (q *Queries) FindAll(ctx context.Context, arg FindAllParams) ([]Product, er
 			&i.StockQuantity,
 			&i.Version,
 			&i.CreatedAt,
+			&i.RestockAt,
+			&i.DeletedAt,
+			&i.Visibility,
+			&i.UpdatedAt,
+			&i.Currency,
+			&i.Description,
+			&i.SearchVector,
+			&i.Sku,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findAuditByProductID = `-- name: FindAuditByProductID :many
+SELECT id, product_id, action, old_values, new_values, version, changed_at
+FROM product_audit
+WHERE product_id = $1
+ORDER BY id
+`
+
+func (q *Queries) FindAuditByProductID(ctx context.Context, productID uuid.UUID) ([]ProductAudit, error) {
+	rows, err := q.db.Query(ctx, findAuditByProductID, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ProductAudit{}
+	for rows.Next() {
+		var i ProductAudit
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.Action,
+			&i.OldValues,
+			&i.NewValues,
+			&i.Version,
+			&i.ChangedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -99,9 +322,9 @@ func (q *Queries) FindAll(ctx context.Context, arg FindAllParams) ([]Product, er
 }
 
 const findByID = `-- name: FindByID :one
-SELECT id, name, price, stock_quantity, version, created_at
+SELECT id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
 FROM products
-WHERE id = $1
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) FindByID(ctx context.Context, id uuid.UUID) (Product, error) {
@@ -114,12 +337,20 @@ func (q *Queries) FindByID(ctx context.Context, id uuid.UUID) (Product, error) {
 		&i.StockQuantity,
 		&i.Version,
 		&i.CreatedAt,
+		&i.RestockAt,
+		&i.DeletedAt,
+		&i.Visibility,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Description,
+		&i.SearchVector,
+		&i.Sku,
 	)
 	return i, err
 }
 
 const findByIDs = `-- name: FindByIDs :many
-SELECT id, name, price, stock_quantity, version, created_at FROM products
+SELECT id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku FROM products
 WHERE id = ANY($1::uuid[])
 `
 
@@ -139,6 +370,313 @@ func (q *Queries) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]Product, er
 			&i.StockQuantity,
 			&i.Version,
 			&i.CreatedAt,
+			&i.RestockAt,
+			&i.DeletedAt,
+			&i.Visibility,
+			&i.UpdatedAt,
+			&i.Currency,
+			&i.Description,
+			&i.SearchVector,
+			&i.Sku,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findFirstPage = `-- name: FindFirstPage :many
+SELECT id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
+FROM products
+WHERE deleted_at IS NULL
+  AND (visibility = 'public' OR $1::bool)
+ORDER BY created_at DESC, id DESC
+LIMIT $2
+`
+
+type FindFirstPageParams struct {
+	IncludeInternal bool  `json:"include_internal"`
+	Lim             int32 `json:"lim"`
+}
+
+func (q *Queries) FindFirstPage(ctx context.Context, arg FindFirstPageParams) ([]Product, error) {
+	rows, err := q.db.Query(ctx, findFirstPage, arg.IncludeInternal, arg.Lim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Product{}
+	for rows.Next() {
+		var i Product
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Price,
+			&i.StockQuantity,
+			&i.Version,
+			&i.CreatedAt,
+			&i.RestockAt,
+			&i.DeletedAt,
+			&i.Visibility,
+			&i.UpdatedAt,
+			&i.Currency,
+			&i.Description,
+			&i.SearchVector,
+			&i.Sku,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findPageAfter = `-- name: FindPageAfter :many
+SELECT id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
+FROM products
+WHERE deleted_at IS NULL
+  AND (created_at, id) < ($1::timestamp, $2::uuid)
+  AND (visibility = 'public' OR $3::bool)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type FindPageAfterParams struct {
+	CreatedAt       *time.Time `json:"created_at"`
+	ID              uuid.UUID  `json:"id"`
+	IncludeInternal bool       `json:"include_internal"`
+	Lim             int32      `json:"lim"`
+}
+
+func (q *Queries) FindPageAfter(ctx context.Context, arg FindPageAfterParams) ([]Product, error) {
+	rows, err := q.db.Query(ctx, findPageAfter,
+		arg.CreatedAt,
+		arg.ID,
+		arg.IncludeInternal,
+		arg.Lim,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Product{}
+	for rows.Next() {
+		var i Product
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Price,
+			&i.StockQuantity,
+			&i.Version,
+			&i.CreatedAt,
+			&i.RestockAt,
+			&i.DeletedAt,
+			&i.Visibility,
+			&i.UpdatedAt,
+			&i.Currency,
+			&i.Description,
+			&i.SearchVector,
+			&i.Sku,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findTakenNames = `-- name: FindTakenNames :many
+SELECT name FROM products
+WHERE lower(name) IN (SELECT lower(n) FROM unnest($1::text[]) AS n) AND deleted_at IS NULL
+`
+
+func (q *Queries) FindTakenNames(ctx context.Context, names []string) ([]string, error) {
+	rows, err := q.db.Query(ctx, findTakenNames, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockByID = `-- name: LockByID :one
+SELECT id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
+FROM products
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockByID(ctx context.Context, id uuid.UUID) (Product, error) {
+	row := q.db.QueryRow(ctx, lockByID, id)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Price,
+		&i.StockQuantity,
+		&i.Version,
+		&i.CreatedAt,
+		&i.RestockAt,
+		&i.DeletedAt,
+		&i.Visibility,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Description,
+		&i.SearchVector,
+		&i.Sku,
+	)
+	return i, err
+}
+
+const lockBySKU = `-- name: LockBySKU :one
+SELECT id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
+FROM products
+WHERE sku = $1::text AND deleted_at IS NULL
+FOR UPDATE
+`
+
+func (q *Queries) LockBySKU(ctx context.Context, sku string) (Product, error) {
+	row := q.db.QueryRow(ctx, lockBySKU, sku)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Price,
+		&i.StockQuantity,
+		&i.Version,
+		&i.CreatedAt,
+		&i.RestockAt,
+		&i.DeletedAt,
+		&i.Visibility,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Description,
+		&i.SearchVector,
+		&i.Sku,
+	)
+	return i, err
+}
+
+const lockSKU = `-- name: LockSKU :exec
+SELECT pg_advisory_xact_lock(hashtext($1::text))
+`
+
+func (q *Queries) LockSKU(ctx context.Context, sku string) error {
+	_, err := q.db.Exec(ctx, lockSKU, sku)
+	return err
+}
+
+const recordAudit = `-- name: RecordAudit :exec
+INSERT INTO product_audit (product_id, action, old_values, new_values, version)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type RecordAuditParams struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Action    string    `json:"action"`
+	OldValues []byte    `json:"old_values"`
+	NewValues []byte    `json:"new_values"`
+	Version   int32     `json:"version"`
+}
+
+func (q *Queries) RecordAudit(ctx context.Context, arg RecordAuditParams) error {
+	_, err := q.db.Exec(ctx, recordAudit,
+		arg.ProductID,
+		arg.Action,
+		arg.OldValues,
+		arg.NewValues,
+		arg.Version,
+	)
+	return err
+}
+
+const recordPrice = `-- name: RecordPrice :exec
+INSERT INTO product_price_history (product_id, price)
+SELECT $1::uuid, $2::bigint
+WHERE $2::bigint IS DISTINCT FROM (SELECT h.price
+                                       FROM product_price_history h
+                                       WHERE h.product_id = $1::uuid
+                                       ORDER BY h.recorded_at DESC
+                                       LIMIT 1)
+`
+
+type RecordPriceParams struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Price     int64     `json:"price"`
+}
+
+func (q *Queries) RecordPrice(ctx context.Context, arg RecordPriceParams) error {
+	_, err := q.db.Exec(ctx, recordPrice, arg.ProductID, arg.Price)
+	return err
+}
+
+const search = `-- name: Search :many
+SELECT id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
+FROM products
+WHERE deleted_at IS NULL
+  AND search_vector @@ plainto_tsquery('english', $1::text)
+  AND (visibility = 'public' OR $2::bool)
+ORDER BY ts_rank(search_vector, plainto_tsquery('english', $1::text)) DESC, created_at DESC, id DESC
+LIMIT $3 OFFSET $4
+`
+
+type SearchParams struct {
+	Query           string `json:"query"`
+	IncludeInternal bool   `json:"include_internal"`
+	Lim             int32  `json:"lim"`
+	Off             int32  `json:"off"`
+}
+
+func (q *Queries) Search(ctx context.Context, arg SearchParams) ([]Product, error) {
+	rows, err := q.db.Query(ctx, search,
+		arg.Query,
+		arg.IncludeInternal,
+		arg.Lim,
+		arg.Off,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Product{}
+	for rows.Next() {
+		var i Product
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Price,
+			&i.StockQuantity,
+			&i.Version,
+			&i.CreatedAt,
+			&i.RestockAt,
+			&i.DeletedAt,
+			&i.Visibility,
+			&i.UpdatedAt,
+			&i.Currency,
+			&i.Description,
+			&i.SearchVector,
+			&i.Sku,
 		); err != nil {
 			return nil, err
 		}
@@ -150,14 +688,52 @@ func (q *Queries) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]Product, er
 	return items, nil
 }
 
+const softDelete = `-- name: SoftDelete :one
+UPDATE products
+SET deleted_at = NOW(),
+    version    = version + 1,
+    updated_at = NOW()
+WHERE id = $1 AND VERSION = $2 AND deleted_at IS NULL
+RETURNING id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
+`
+
+type SoftDeleteParams struct {
+	ID      uuid.UUID `json:"id"`
+	Version int32     `json:"version"`
+}
+
+func (q *Queries) SoftDelete(ctx context.Context, arg SoftDeleteParams) (Product, error) {
+	row := q.db.QueryRow(ctx, softDelete, arg.ID, arg.Version)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Price,
+		&i.StockQuantity,
+		&i.Version,
+		&i.CreatedAt,
+		&i.RestockAt,
+		&i.DeletedAt,
+		&i.Visibility,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Description,
+		&i.SearchVector,
+		&i.Sku,
+	)
+	return i, err
+}
+
 const update = `-- name: Update :one
 UPDATE products
 SET name           = $2,
     price          = $3,
     stock_quantity = $4,
-    version        = version + 1
-WHERE id = $1 AND VERSION = $5
-RETURNING id, name, price, stock_quantity, version, created_at
+    description    = $6,
+    version        = version + 1,
+    updated_at     = NOW()
+WHERE id = $1 AND VERSION = $5 AND deleted_at IS NULL
+RETURNING id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
 `
 
 type UpdateParams struct {
@@ -166,6 +742,7 @@ type UpdateParams struct {
 	Price         int64     `json:"price"`
 	StockQuantity int32     `json:"stock_quantity"`
 	Version       int32     `json:"version"`
+	Description   *string   `json:"description"`
 }
 
 func (q *Queries) Update(ctx context.Context, arg UpdateParams) (Product, error) {
@@ -175,6 +752,7 @@ func (q *Queries) Update(ctx context.Context, arg UpdateParams) (Product, error)
 		arg.Price,
 		arg.StockQuantity,
 		arg.Version,
+		arg.Description,
 	)
 	var i Product
 	err := row.Scan(
@@ -184,6 +762,14 @@ func (q *Queries) Update(ctx context.Context, arg UpdateParams) (Product, error)
 		&i.StockQuantity,
 		&i.Version,
 		&i.CreatedAt,
+		&i.RestockAt,
+		&i.DeletedAt,
+		&i.Visibility,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Description,
+		&i.SearchVector,
+		&i.Sku,
 	)
 	return i, err
 }
@@ -191,19 +777,89 @@ func (q *Queries) Update(ctx context.Context, arg UpdateParams) (Product, error)
 const updateStock = `-- name: UpdateStock :one
 UPDATE products
 SET stock_quantity = $2,
-    version        = version + 1
-WHERE id = $1 AND VERSION = $3
-RETURNING id, name, price, stock_quantity, version, created_at
+    restock_at     = $4,
+    version        = version + 1,
+    updated_at     = NOW()
+WHERE id = $1 AND VERSION = $3 AND deleted_at IS NULL
+RETURNING id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
 `
 
 type UpdateStockParams struct {
-	ID            uuid.UUID `json:"id"`
-	StockQuantity int32     `json:"stock_quantity"`
-	Version       int32     `json:"version"`
+	ID            uuid.UUID  `json:"id"`
+	StockQuantity int32      `json:"stock_quantity"`
+	Version       int32      `json:"version"`
+	RestockAt     *time.Time `json:"restock_at"`
 }
 
 func (q *Queries) UpdateStock(ctx context.Context, arg UpdateStockParams) (Product, error) {
-	row := q.db.QueryRow(ctx, updateStock, arg.ID, arg.StockQuantity, arg.Version)
+	row := q.db.QueryRow(ctx, updateStock,
+		arg.ID,
+		arg.StockQuantity,
+		arg.Version,
+		arg.RestockAt,
+	)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Price,
+		&i.StockQuantity,
+		&i.Version,
+		&i.CreatedAt,
+		&i.RestockAt,
+		&i.DeletedAt,
+		&i.Visibility,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Description,
+		&i.SearchVector,
+		&i.Sku,
+	)
+	return i, err
+}
+
+const upsert = `-- name: Upsert :one
+INSERT INTO products (name,
+                      description,
+                      price,
+                      stock_quantity,
+                      visibility,
+                      currency,
+                      sku
+                      )
+VALUES ($1, $2, $3, $4, $5, $6, $7::text)
+ON CONFLICT (sku) WHERE deleted_at IS NULL DO UPDATE
+SET name           = EXCLUDED.name,
+    description    = EXCLUDED.description,
+    price          = EXCLUDED.price,
+    stock_quantity = EXCLUDED.stock_quantity,
+    version        = products.version + 1,
+    updated_at     = NOW()
+WHERE (products.name, products.description, products.price, products.stock_quantity)
+          IS DISTINCT FROM (EXCLUDED.name, EXCLUDED.description, EXCLUDED.price, EXCLUDED.stock_quantity)
+RETURNING id, name, price, stock_quantity, version, created_at, restock_at, deleted_at, visibility, updated_at, currency, description, search_vector, sku
+`
+
+type UpsertParams struct {
+	Name          string  `json:"name"`
+	Description   *string `json:"description"`
+	Price         int64   `json:"price"`
+	StockQuantity int32   `json:"stock_quantity"`
+	Visibility    string  `json:"visibility"`
+	Currency      string  `json:"currency"`
+	Sku           string  `json:"sku"`
+}
+
+func (q *Queries) Upsert(ctx context.Context, arg UpsertParams) (Product, error) {
+	row := q.db.QueryRow(ctx, upsert,
+		arg.Name,
+		arg.Description,
+		arg.Price,
+		arg.StockQuantity,
+		arg.Visibility,
+		arg.Currency,
+		arg.Sku,
+	)
 	var i Product
 	err := row.Scan(
 		&i.ID,
@@ -212,6 +868,14 @@ func (q *Queries) UpdateStock(ctx context.Context, arg UpdateStockParams) (Produ
 		&i.StockQuantity,
 		&i.Version,
 		&i.CreatedAt,
+		&i.RestockAt,
+		&i.DeletedAt,
+		&i.Visibility,
+		&i.UpdatedAt,
+		&i.Currency,
+		&i.Description,
+		&i.SearchVector,
+		&i.Sku,
 	)
 	return i, err
 }
@@ -11,13 +11,28 @@ import (
 )
 
 type Querier interface {
+	AddStock(ctx context.Context, arg AddStockParams) (Product, error)
+	AdjustStock(ctx context.Context, arg AdjustStockParams) (Product, error)
+	AggregatePriceHistory(ctx context.Context, arg AggregatePriceHistoryParams) ([]AggregatePriceHistoryRow, error)
 	Create(ctx context.Context, arg CreateParams) (Product, error)
 	Delete(ctx context.Context, arg DeleteParams) (int64, error)
 	FindAll(ctx context.Context, arg FindAllParams) ([]Product, error)
+	FindAuditByProductID(ctx context.Context, productID uuid.UUID) ([]ProductAudit, error)
 	FindByID(ctx context.Context, id uuid.UUID) (Product, error)
 	FindByIDs(ctx context.Context, ids []uuid.UUID) ([]Product, error)
+	FindFirstPage(ctx context.Context, arg FindFirstPageParams) ([]Product, error)
+	FindPageAfter(ctx context.Context, arg FindPageAfterParams) ([]Product, error)
+	FindTakenNames(ctx context.Context, names []string) ([]string, error)
+	LockByID(ctx context.Context, id uuid.UUID) (Product, error)
+	LockBySKU(ctx context.Context, sku string) (Product, error)
+	LockSKU(ctx context.Context, sku string) error
+	RecordAudit(ctx context.Context, arg RecordAuditParams) error
+	RecordPrice(ctx context.Context, arg RecordPriceParams) error
+	Search(ctx context.Context, arg SearchParams) ([]Product, error)
+	SoftDelete(ctx context.Context, arg SoftDeleteParams) (Product, error)
 	Update(ctx context.Context, arg UpdateParams) (Product, error)
 	UpdateStock(ctx context.Context, arg UpdateStockParams) (Product, error)
+	Upsert(ctx context.Context, arg UpsertParams) (Product, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abgdnv/gocommerce/pkg/bootstrap/migration"
+	"github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// TestMigrationUp_EmptyDatabase starts several instances at once against an empty database
+// and verifies the schema is created once, with every instance starting successfully.
+func TestMigrationUp_EmptyDatabase(t *testing.T) {
+	if os.Getenv(skipIntegrationTests) == "1" {
+		t.Skip("Skipping integration tests based on " + skipIntegrationTests + " env var")
+	}
+	// given
+	ctx := context.Background()
+	pgContainer, err := postgres.Run(ctx,
+		"postgres:17.5-alpine",
+		postgres.WithDatabase("products"),
+		postgres.WithUsername("user"),
+		postgres.WithPassword("password"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(5*time.Minute),
+		),
+	)
+	require.NoError(t, err, "Failed to run PostgreSQL container")
+	t.Cleanup(func() { _ = pgContainer.Terminate(ctx) })
+
+	host, err := pgContainer.Host(ctx)
+	require.NoError(t, err)
+	port, err := pgContainer.MappedPort(ctx, "5432/tcp")
+	require.NoError(t, err)
+	wd, _ := os.Getwd()
+	cfg := config.DatabaseConfig{
+		Host:        host,
+		Port:        port.Int(),
+		User:        "user",
+		Password:    "password",
+		Name:        "products",
+		SSLMode:     "disable",
+		AutoMigrate: true,
+		Migrations:  "file://" + filepath.Join(wd, "../../../deploy/charts/db-migrations/migrations/product"),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// when
+	const instances = 3
+	errs := make(chan error, instances)
+	var wg sync.WaitGroup
+	for range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- migration.Up(ctx, cfg, logger)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// then
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	dbPool, err := pgxpool.New(ctx, cfg.URI())
+	require.NoError(t, err)
+	t.Cleanup(dbPool.Close)
+	for _, table := range []string{"products", "product_price_history", "product_audit"} {
+		var exists bool
+		err := dbPool.QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = 'public' AND table_name = $1)",
+			table).Scan(&exists)
+		require.NoError(t, err)
+		assert.True(t, exists, "table %s should exist", table)
+	}
+	for _, index := range []string{"idx_products_name_unique", "idx_products_created_at_id"} {
+		var exists bool
+		err := dbPool.QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE schemaname = 'public' AND indexname = $1)",
+			index).Scan(&exists)
+		require.NoError(t, err)
+		assert.True(t, exists, "index %s should exist", index)
+	}
+	var dirty bool
+	require.NoError(t, dbPool.QueryRow(ctx, "SELECT dirty FROM schema_migrations").Scan(&dirty))
+	assert.False(t, dirty)
+
+	// when: the service restarts with nothing left to migrate
+	err = migration.Up(ctx, cfg, logger)
+
+	// then
+	assert.NoError(t, err)
+}
@@ -1,126 +1,695 @@
 package store
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"time"
 
+	"github.com/abgdnv/gocommerce/pkg/audit"
+	"github.com/abgdnv/gocommerce/pkg/telemetry"
 	perrors "github.com/abgdnv/gocommerce/product_service/internal/errors"
 	"github.com/abgdnv/gocommerce/product_service/internal/store/db"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // PgStore implements ProductStore using PostgreSQL as the data store.
 type PgStore struct {
-	db *pgxpool.Pool
-	q  *db.Queries
+	db           *pgxpool.Pool
+	q            *db.Queries
+	queryTimeout time.Duration
+	slowLog      *telemetry.SlowQueryLogger
+	// uniqueNames reports a violation of the unique index of the product names as ErrProductAlreadyExists
+	uniqueNames bool
 }
 
 // NewPgStore creates a new instance of ProductStore using a PostgreSQL connection pool.
-func NewPgStore(dbp *pgxpool.Pool) *PgStore {
+// Every query is bounded by queryTimeout and fails with ErrQueryTimeout when it expires.
+// A zero queryTimeout disables the timeout.
+// The queries slower than the threshold of slowLog are logged, a nil slowLog disables the log.
+// If uniqueNames is set, a product named like another live product is rejected with ErrProductAlreadyExists,
+// otherwise the violation of the unique index of the names is returned as is.
+func NewPgStore(dbp *pgxpool.Pool, queryTimeout time.Duration, slowLog *telemetry.SlowQueryLogger, uniqueNames bool) *PgStore {
 	return &PgStore{
-		db: dbp,
-		q:  db.New(dbp),
+		db:           dbp,
+		q:            db.New(dbp),
+		queryTimeout: queryTimeout,
+		slowLog:      slowLog,
+		uniqueNames:  uniqueNames,
 	}
 }
 
+// uniqueNameIndex is the name of the unique index of the names of the live products, created by the migrations.
+const uniqueNameIndex = "idx_products_name_unique"
+
+// pgUniqueViolation is the SQLSTATE of a unique constraint violation.
+const pgUniqueViolation = "23505"
+
 // FindByID retrieves a product by its unique identifier.
 // Returns ErrProductNotFound if no product exists with the given ID.
 func (p *PgStore) FindByID(ctx context.Context, id uuid.UUID) (*db.Product, error) {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	ctx, span := p.slowLog.StartDBSpan(ctx, "FindByID")
 	product, err := p.q.FindByID(ctx, id)
+	span.End(1, err)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, perrors.ErrProductNotFound
 		}
-		return nil, fmt.Errorf("failed to find product by ID: %w", err)
+		return nil, queryError(ctx, fmt.Errorf("failed to find product by ID: %w", err))
 	}
 	return &product, nil
 }
 
-// FindByIDs retrieves products by IDs
+// FindByIDs retrieves products by IDs, including the soft-deleted ones.
 // It returns a slice of products, which may be empty if no products exist.
 func (p *PgStore) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]db.Product, error) {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	ctx, span := p.slowLog.StartDBSpan(ctx, "FindByIDs")
 	products, err := p.q.FindByIDs(ctx, ids)
+	span.End(int64(len(products)), err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find all products: %w", err)
+		return nil, queryError(ctx, fmt.Errorf("failed to find all products: %w", err))
 	}
 	return products, nil
 }
 
-// FindAll retrieves all available products with pagination support.
+// FindTakenNames returns the names of the live products named like any of the names, ignoring the case.
+// It returns an empty slice if none of the names is taken.
+func (p *PgStore) FindTakenNames(ctx context.Context, names []string) ([]string, error) {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	ctx, span := p.slowLog.StartDBSpan(ctx, "FindTakenNames")
+	taken, err := p.q.FindTakenNames(ctx, names)
+	span.End(int64(len(taken)), err)
+	if err != nil {
+		return nil, queryError(ctx, fmt.Errorf("failed to find taken product names: %w", err))
+	}
+	return taken, nil
+}
+
+// FindAll retrieves all available products with pagination support, the internal ones only if includeInternal is set.
 // It returns a slice of products, which may be empty if no products exist.
-func (p *PgStore) FindAll(ctx context.Context, offset, limit int32) ([]db.Product, error) {
-	products, err := p.q.FindAll(ctx, db.FindAllParams{Limit: limit, Offset: offset})
+func (p *PgStore) FindAll(ctx context.Context, offset, limit int32, includeInternal bool) ([]db.Product, error) {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	ctx, span := p.slowLog.StartDBSpan(ctx, "FindAll")
+	products, err := p.q.FindAll(ctx, db.FindAllParams{IncludeInternal: includeInternal, Lim: limit, Off: offset})
+	span.End(int64(len(products)), err)
+	if err != nil {
+		return nil, queryError(ctx, fmt.Errorf("failed to find all products: %w", err))
+	}
+	return products, nil
+}
+
+// Search retrieves the available products matching the full-text query, ranked by relevance,
+// the internal ones only if includeInternal is set.
+func (p *PgStore) Search(ctx context.Context, query string, offset, limit int32, includeInternal bool) ([]db.Product, error) {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	ctx, span := p.slowLog.StartDBSpan(ctx, "Search")
+	products, err := p.q.Search(ctx, db.SearchParams{Query: query, IncludeInternal: includeInternal, Lim: limit, Off: offset})
+	span.End(int64(len(products)), err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find all products: %w", err)
+		return nil, queryError(ctx, fmt.Errorf("failed to search products: %w", err))
 	}
 	return products, nil
 }
 
-// Create adds a new product to the system.
-// Returns an error if the product cannot be created.
-func (p *PgStore) Create(ctx context.Context, name string, price int64, stock int32) (*db.Product, error) {
-	product, err := p.q.Create(ctx, db.CreateParams{
-		Name:          name,
-		Price:         price,
-		StockQuantity: stock,
+// FindAllByCursor retrieves products using keyset pagination on (created_at, id).
+// A nil createdAt means no cursor, so the first page is returned.
+func (p *PgStore) FindAllByCursor(ctx context.Context, createdAt *time.Time, id uuid.UUID, limit int32, includeInternal bool) ([]db.Product, error) {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	var products []db.Product
+	var err error
+	if createdAt == nil {
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "FindFirstPage")
+		products, err = p.q.FindFirstPage(spanCtx, db.FindFirstPageParams{IncludeInternal: includeInternal, Lim: limit})
+		span.End(int64(len(products)), err)
+	} else {
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "FindPageAfter")
+		products, err = p.q.FindPageAfter(spanCtx, db.FindPageAfterParams{
+			CreatedAt:       createdAt,
+			ID:              id,
+			IncludeInternal: includeInternal,
+			Lim:             limit,
+		})
+		span.End(int64(len(products)), err)
+	}
+	if err != nil {
+		return nil, queryError(ctx, fmt.Errorf("failed to find products by cursor: %w", err))
+	}
+	return products, nil
+}
+
+// Create adds a new product with an optional description, the visibility and the currency of its price to the system
+// and records its price in the price history. Returns an error if the product cannot be created.
+func (p *PgStore) Create(ctx context.Context, name string, description *string, price int64, stock int32, visibility, currency string) (*db.Product, error) {
+	var created *db.Product
+	err := p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "Create")
+		product, err := qtx.Create(spanCtx, db.CreateParams{
+			Name:          name,
+			Description:   description,
+			Price:         price,
+			StockQuantity: stock,
+			Visibility:    visibility,
+			Currency:      currency,
+		})
+		span.End(1, err)
+		if err != nil {
+			return p.duplicateNameError(fmt.Errorf("failed to create product: %w", err))
+		}
+		if err := p.recordPrice(ctx, qtx, product.ID, product.Price); err != nil {
+			return err
+		}
+		if err := p.recordAudit(ctx, qtx, audit.ActionCreate, product.ID, nil, &product); err != nil {
+			return err
+		}
+		created = &product
+		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create product: %w", err)
+		return nil, err
 	}
-	return &product, nil
+	return created, nil
 }
 
-// Update modifies an existing product's details.
-// Returns ErrProductNotFound if no product exists with the given ID and version.
-func (p *PgStore) Update(ctx context.Context, id uuid.UUID, name string, price int64, stock int32, version int32) (*db.Product, error) {
-	product, err := p.q.Update(ctx, db.UpdateParams{
-		ID:            id,
-		Name:          name,
-		Price:         price,
-		StockQuantity: stock,
-		Version:       version,
+// CreateBatch adds the products in a single transaction and records their prices in the price history.
+// Returns an error and creates none of the products if any of them cannot be created.
+func (p *PgStore) CreateBatch(ctx context.Context, products []db.CreateParams) ([]db.Product, error) {
+	created := make([]db.Product, 0, len(products))
+	err := p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		for i, params := range products {
+			spanCtx, span := p.slowLog.StartDBSpan(ctx, "Create")
+			product, err := qtx.Create(spanCtx, params)
+			span.End(1, err)
+			if err != nil {
+				return p.duplicateNameError(fmt.Errorf("failed to create product %d of the batch: %w", i, err))
+			}
+			if err := p.recordPrice(ctx, qtx, product.ID, product.Price); err != nil {
+				return err
+			}
+			if err := p.recordAudit(ctx, qtx, audit.ActionCreate, product.ID, nil, &product); err != nil {
+				return err
+			}
+			created = append(created, product)
+		}
+		return nil
 	})
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, perrors.ErrProductNotFound
+		return nil, err
+	}
+	return created, nil
+}
+
+// Upsert creates a product with the external SKU, or updates the live product with the SKU if it exists,
+// and reports whether the product was created. The visibility and the currency only apply to a created product,
+// an update with another currency is rejected with ErrCurrencyMismatch, since the price is in that currency.
+// An update with the current values of the product changes nothing and returns the product as is.
+// Concurrent upserts of a SKU are serialized by a transaction-level advisory lock of the SKU,
+// so the values of the product before an update are recorded in its audit log.
+func (p *PgStore) Upsert(ctx context.Context, sku, name string, description *string, price int64, stock int32, visibility, currency string) (*db.Product, bool, error) {
+	var upserted *db.Product
+	var created bool
+	err := p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "LockSKU")
+		err := qtx.LockSKU(spanCtx, sku)
+		span.End(1, err)
+		if err != nil {
+			return fmt.Errorf("failed to lock product SKU: %w", err)
+		}
+		spanCtx, span = p.slowLog.StartDBSpan(ctx, "LockBySKU")
+		old, err := qtx.LockBySKU(spanCtx, sku)
+		span.End(1, err)
+		created = errors.Is(err, pgx.ErrNoRows)
+		if err != nil && !created {
+			return fmt.Errorf("failed to lock product by SKU: %w", err)
+		}
+		if !created && old.Currency != currency {
+			return fmt.Errorf("%w: the product with SKU %s is priced in %s, not %s", perrors.ErrCurrencyMismatch, sku, old.Currency, currency)
+		}
+		spanCtx, span = p.slowLog.StartDBSpan(ctx, "Upsert")
+		product, err := qtx.Upsert(spanCtx, db.UpsertParams{
+			Name:          name,
+			Description:   description,
+			Price:         price,
+			StockQuantity: stock,
+			Visibility:    visibility,
+			Currency:      currency,
+			Sku:           sku,
+		})
+		span.End(1, err)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) && !created {
+				// the conflicting product already has the values, so it's left unchanged
+				upserted = &old
+				return nil
+			}
+			return p.duplicateNameError(fmt.Errorf("failed to upsert product: %w", err))
+		}
+		if err := p.recordPrice(ctx, qtx, product.ID, product.Price); err != nil {
+			return err
+		}
+		if created {
+			err = p.recordAudit(ctx, qtx, audit.ActionCreate, product.ID, nil, &product)
+		} else {
+			err = p.recordAudit(ctx, qtx, audit.ActionUpdate, product.ID, &old, &product)
+		}
+		if err != nil {
+			return err
+		}
+		upserted = &product
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return upserted, created, nil
+}
+
+// Update modifies an existing product's details and records a changed price in the price history.
+// A nil description clears the previous one.
+// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
+func (p *PgStore) Update(ctx context.Context, id uuid.UUID, name string, description *string, price int64, stock int32, version int32) (*db.Product, error) {
+	var updated *db.Product
+	err := p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		old, err := p.lockProduct(ctx, qtx, id)
+		if err != nil {
+			return err
+		}
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "Update")
+		product, err := qtx.Update(spanCtx, db.UpdateParams{
+			ID:            id,
+			Name:          name,
+			Price:         price,
+			StockQuantity: stock,
+			Version:       version,
+			Description:   description,
+		})
+		span.End(1, err)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return p.versionMismatchError(ctx, qtx, id)
+			}
+			return p.duplicateNameError(fmt.Errorf("failed to update product: %w", err))
 		}
-		return nil, fmt.Errorf("failed to update product: %w", err)
+		if err := p.recordPrice(ctx, qtx, product.ID, product.Price); err != nil {
+			return err
+		}
+		if err := p.recordAudit(ctx, qtx, audit.ActionUpdate, id, old, &product); err != nil {
+			return err
+		}
+		updated = &product
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return &product, nil
+	return updated, nil
 }
 
-// UpdateStock adjusts the stock quantity of a product.
-// Returns ErrProductNotFound if no product exists with the given ID and version.
-func (p *PgStore) UpdateStock(ctx context.Context, id uuid.UUID, stock int32, version int32) (*db.Product, error) {
-	product, err := p.q.UpdateStock(ctx, db.UpdateStockParams{
-		ID:            id,
-		StockQuantity: stock,
-		Version:       version,
+// UpdateStock adjusts the stock quantity and the expected restock time of a product.
+// A nil restockAt clears the restock time.
+// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
+func (p *PgStore) UpdateStock(ctx context.Context, id uuid.UUID, stock int32, version int32, restockAt *time.Time) (*db.Product, error) {
+	var updated *db.Product
+	err := p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		old, err := p.lockProduct(ctx, qtx, id)
+		if err != nil {
+			return err
+		}
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "UpdateStock")
+		product, err := qtx.UpdateStock(spanCtx, db.UpdateStockParams{
+			ID:            id,
+			StockQuantity: stock,
+			Version:       version,
+			RestockAt:     restockAt,
+		})
+		span.End(1, err)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return p.versionMismatchError(ctx, qtx, id)
+			}
+			return fmt.Errorf("failed to update product stock: %w", err)
+		}
+		if err := p.recordAudit(ctx, qtx, audit.ActionUpdateStock, id, old, &product); err != nil {
+			return err
+		}
+		updated = &product
+		return nil
 	})
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, perrors.ErrProductNotFound
+		return nil, err
+	}
+	return updated, nil
+}
+
+// AdjustStock adds the delta to the stock quantity of a product in a single statement, a negative delta reduces it.
+// Returns ErrProductNotFound if no product exists with the given ID, ErrOptimisticLock if its version differs,
+// ErrInsufficientStock if the stock quantity would go below zero, or ErrStockOverflow if it would exceed the range of int4.
+func (p *PgStore) AdjustStock(ctx context.Context, id uuid.UUID, delta int32, version int32) (*db.Product, error) {
+	var updated *db.Product
+	err := p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		old, err := p.lockProduct(ctx, qtx, id)
+		if err != nil {
+			return err
 		}
-		return nil, fmt.Errorf("failed to update product stock: %w", err)
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "AdjustStock")
+		product, err := qtx.AdjustStock(spanCtx, db.AdjustStockParams{
+			Delta:   delta,
+			ID:      id,
+			Version: version,
+		})
+		span.End(1, err)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				// the row is locked, so a live product of the expected version was only rejected by the stock check
+				if old.DeletedAt == nil && old.Version == version {
+					return stockCheckError(old, delta)
+				}
+				return p.versionMismatchError(ctx, qtx, id)
+			}
+			return fmt.Errorf("failed to adjust product stock: %w", err)
+		}
+		if err := p.recordAudit(ctx, qtx, audit.ActionUpdateStock, id, old, &product); err != nil {
+			return err
+		}
+		updated = &product
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return &product, nil
+	return updated, nil
+}
+
+// ReserveStock takes the quantities from the stock of the products in a single transaction, all or none.
+// Returns ErrProductNotFound if any of the products doesn't exist,
+// or ErrInsufficientStock if the stock quantity of any of the products is below its quantity.
+func (p *PgStore) ReserveStock(ctx context.Context, quantities map[uuid.UUID]int32) error {
+	return p.addStock(ctx, quantities, -1)
+}
+
+// ReleaseStock returns the quantities to the stock of the products in a single transaction, all or none.
+// Returns ErrProductNotFound if any of the products doesn't exist,
+// or ErrStockOverflow if the stock quantity of any of the products would exceed the range of int4.
+func (p *PgStore) ReleaseStock(ctx context.Context, quantities map[uuid.UUID]int32) error {
+	return p.addStock(ctx, quantities, 1)
+}
+
+// addStock adds the quantities times sign to the stock of the products and records the changes in the audit log.
+// The products are locked in the order of their IDs, so concurrent calls for the same products don't deadlock.
+func (p *PgStore) addStock(ctx context.Context, quantities map[uuid.UUID]int32, sign int32) error {
+	ids := slices.SortedFunc(maps.Keys(quantities), func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	return p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		for _, id := range ids {
+			old, err := p.lockProduct(ctx, qtx, id)
+			if err != nil {
+				return err
+			}
+			spanCtx, span := p.slowLog.StartDBSpan(ctx, "AddStock")
+			product, err := qtx.AddStock(spanCtx, db.AddStockParams{
+				Delta: sign * quantities[id],
+				ID:    id,
+			})
+			span.End(1, err)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					// the row is locked, so a live product was only rejected by the stock check
+					if old.DeletedAt == nil {
+						return stockCheckError(old, sign*quantities[id])
+					}
+					return perrors.ErrProductNotFound
+				}
+				return fmt.Errorf("failed to add product stock: %w", err)
+			}
+			if err := p.recordAudit(ctx, qtx, audit.ActionUpdateStock, id, old, &product); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// stockCheckError returns the error of the stock check which rejected adding the delta to the stock of the product:
+// ErrStockOverflow if the stock quantity would exceed the range of int4, or ErrInsufficientStock otherwise.
+func stockCheckError(product *db.Product, delta int32) error {
+	if int64(product.StockQuantity)+int64(delta) > math.MaxInt32 {
+		return perrors.ErrStockOverflow
+	}
+	return perrors.ErrInsufficientStock
 }
 
 // DeleteByID removes a product by its unique identifier.
-// Returns ErrProductNotFound if no product exists with the given ID and version.
+// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
 func (p *PgStore) DeleteByID(ctx context.Context, id uuid.UUID, version int32) error {
-	count, err := p.q.Delete(ctx, db.DeleteParams{
-		ID:      id,
-		Version: version,
+	return p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		old, err := p.lockProduct(ctx, qtx, id)
+		if err != nil {
+			return err
+		}
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "Delete")
+		count, err := qtx.Delete(spanCtx, db.DeleteParams{
+			ID:      id,
+			Version: version,
+		})
+		span.End(count, err)
+		if err != nil {
+			return fmt.Errorf("failed to delete product by ID: %w", err)
+		}
+		if count == 0 {
+			return p.versionMismatchError(ctx, qtx, id)
+		}
+		return p.recordAudit(ctx, qtx, audit.ActionDelete, id, old, nil)
+	})
+}
+
+// SoftDeleteByID marks a product as deleted, keeping it for the lookups by IDs.
+// Returns ErrProductNotFound if no live product exists with the given ID, or ErrOptimisticLock if its version differs.
+func (p *PgStore) SoftDeleteByID(ctx context.Context, id uuid.UUID, version int32) error {
+	return p.withTransaction(ctx, func(ctx context.Context, qtx *db.Queries) error {
+		old, err := p.lockProduct(ctx, qtx, id)
+		if err != nil {
+			return err
+		}
+		spanCtx, span := p.slowLog.StartDBSpan(ctx, "SoftDelete")
+		product, err := qtx.SoftDelete(spanCtx, db.SoftDeleteParams{
+			ID:      id,
+			Version: version,
+		})
+		span.End(1, err)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return p.versionMismatchError(ctx, qtx, id)
+			}
+			return fmt.Errorf("failed to soft delete product by ID: %w", err)
+		}
+		return p.recordAudit(ctx, qtx, audit.ActionDelete, id, old, &product)
+	})
+}
+
+// PriceHistory aggregates the prices a product had in [from, to) into buckets of the given interval.
+// A bucket is seeded with the last price recorded up to its start, which is in effect until the first change within it,
+// so every bucket in [from, to) is returned, except the buckets before the first recorded price of the product.
+// The changes of a bucket only count the prices recorded within it.
+func (p *PgStore) PriceHistory(ctx context.Context, id uuid.UUID, interval string, from, to time.Time) ([]db.AggregatePriceHistoryRow, error) {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	ctx, span := p.slowLog.StartDBSpan(ctx, "AggregatePriceHistory")
+	buckets, err := p.q.AggregatePriceHistory(ctx, db.AggregatePriceHistoryParams{
+		Bucket:    interval,
+		ProductID: id,
+		FromTime:  &from,
+		ToTime:    &to,
+	})
+	span.End(int64(len(buckets)), err)
+	if err != nil {
+		return nil, queryError(ctx, fmt.Errorf("failed to aggregate product price history: %w", err))
+	}
+	return buckets, nil
+}
+
+// History returns the changes of a product recorded in its audit log, oldest first.
+// It returns a slice of changes, which may be empty if no changes are recorded.
+func (p *PgStore) History(ctx context.Context, id uuid.UUID) ([]ProductChange, error) {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+	ctx, span := p.slowLog.StartDBSpan(ctx, "FindAuditByProductID")
+	rows, err := p.q.FindAuditByProductID(ctx, id)
+	span.End(int64(len(rows)), err)
+	if err != nil {
+		return nil, queryError(ctx, fmt.Errorf("failed to find product audit log: %w", err))
+	}
+	changes := make([]ProductChange, len(rows))
+	for i, row := range rows {
+		changes[i] = ProductChange{Action: row.Action, Version: row.Version, ChangedAt: *row.ChangedAt}
+		if changes[i].Old, err = decodeAuditValues(row.OldValues); err != nil {
+			return nil, err
+		}
+		if changes[i].New, err = decodeAuditValues(row.NewValues); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// recordPrice adds the price of a product to its price history, unless it's the last recorded price.
+func (p *PgStore) recordPrice(ctx context.Context, qtx *db.Queries, id uuid.UUID, price int64) error {
+	ctx, span := p.slowLog.StartDBSpan(ctx, "RecordPrice")
+	err := qtx.RecordPrice(ctx, db.RecordPriceParams{ProductID: id, Price: price})
+	span.End(1, err)
+	if err != nil {
+		return fmt.Errorf("failed to record product price: %w", err)
+	}
+	return nil
+}
+
+// recordAudit adds a change of a product to its audit log.
+// before is nil for a created product, after is nil for a deleted one.
+func (p *PgStore) recordAudit(ctx context.Context, qtx *db.Queries, action string, id uuid.UUID, before, after *db.Product) error {
+	params := db.RecordAuditParams{ProductID: id, Action: action}
+	var err error
+	if params.OldValues, err = encodeAuditValues(before); err != nil {
+		return err
+	}
+	if params.NewValues, err = encodeAuditValues(after); err != nil {
+		return err
+	}
+	if after != nil {
+		params.Version = after.Version
+	} else {
+		params.Version = before.Version
+	}
+	ctx, span := p.slowLog.StartDBSpan(ctx, "RecordAudit")
+	err = qtx.RecordAudit(ctx, params)
+	span.End(1, err)
+	if err != nil {
+		return fmt.Errorf("failed to record product audit: %w", err)
+	}
+	return nil
+}
+
+// encodeAuditValues encodes the values of a product for its audit log, a nil product is encoded as NULL.
+func encodeAuditValues(product *db.Product) ([]byte, error) {
+	if product == nil {
+		return nil, nil
+	}
+	values, err := json.Marshal(ProductValues{
+		Name:          product.Name,
+		Description:   product.Description,
+		Price:         product.Price,
+		StockQuantity: product.StockQuantity,
+		RestockAt:     product.RestockAt,
+		DeletedAt:     product.DeletedAt,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete product by ID: %w", err)
+		return nil, fmt.Errorf("failed to encode product audit values: %w", err)
+	}
+	return values, nil
+}
+
+// decodeAuditValues decodes the values of a product recorded in its audit log, NULL is decoded as nil.
+func decodeAuditValues(data []byte) (*ProductValues, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var values ProductValues
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to decode product audit values: %w", err)
+	}
+	return &values, nil
+}
+
+// lockProduct locks the row of a product, including a soft-deleted one, until the end of the transaction
+// and returns its values before the change.
+// Returns ErrProductNotFound if no product exists with the given ID.
+func (p *PgStore) lockProduct(ctx context.Context, qtx *db.Queries, id uuid.UUID) (*db.Product, error) {
+	ctx, span := p.slowLog.StartDBSpan(ctx, "LockByID")
+	product, err := qtx.LockByID(ctx, id)
+	span.End(1, err)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, perrors.ErrProductNotFound
+		}
+		return nil, fmt.Errorf("failed to lock product: %w", err)
 	}
-	if count == 0 {
+	return &product, nil
+}
+
+// versionMismatchError tells apart a missing product from an optimistic lock error after a versioned statement matched no rows.
+// A soft-deleted product is reported as missing.
+func (p *PgStore) versionMismatchError(ctx context.Context, q *db.Queries, id uuid.UUID) error {
+	spanCtx, span := p.slowLog.StartDBSpan(ctx, "FindByID")
+	_, err := q.FindByID(spanCtx, id)
+	span.End(1, err)
+	if errors.Is(err, pgx.ErrNoRows) {
 		return perrors.ErrProductNotFound
+	} else if err != nil {
+		return queryError(ctx, fmt.Errorf("failed to find product by ID: %w", err))
+	}
+	return perrors.ErrOptimisticLock
+}
+
+// duplicateNameError returns ErrProductAlreadyExists if the names are unique and err is a violation of the unique index
+// of the product names, otherwise err.
+func (p *PgStore) duplicateNameError(err error) error {
+	var pgErr *pgconn.PgError
+	if p.uniqueNames && errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation && pgErr.ConstraintName == uniqueNameIndex {
+		return fmt.Errorf("%w: %s", perrors.ErrProductAlreadyExists, pgErr.Detail)
+	}
+	return err
+}
+
+// withTransaction runs fn in a transaction bounded by the query timeout, fn must use the context it is given.
+// The transaction is rolled back if fn fails, and ErrQueryTimeout is returned if the query timeout expired.
+func (p *PgStore) withTransaction(ctx context.Context, fn func(ctx context.Context, qtx *db.Queries) error) error {
+	ctx, cancel := p.withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return queryError(ctx, fmt.Errorf("failed to begin transaction: %w", err))
+	}
+
+	if err := fn(ctx, p.q.WithTx(tx)); err != nil {
+		// Roll back with a context that is not canceled by the query timeout,
+		// so a timed out transaction is still rolled back and its connection released to the pool.
+		_ = tx.Rollback(context.WithoutCancel(ctx))
+		return queryError(ctx, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return queryError(ctx, fmt.Errorf("failed to commit transaction: %w", err))
 	}
 	return nil
 }
+
+// withQueryTimeout returns a context canceled with ErrQueryTimeout when the query timeout expires.
+func (p *PgStore) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, p.queryTimeout, perrors.ErrQueryTimeout)
+}
+
+// queryError returns ErrQueryTimeout if the query timeout of the context expired, otherwise err.
+func queryError(ctx context.Context, err error) error {
+	if errors.Is(context.Cause(ctx), perrors.ErrQueryTimeout) {
+		return perrors.ErrQueryTimeout
+	}
+	return err
+}
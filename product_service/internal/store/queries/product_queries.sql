@@ -1,33 +1,106 @@
 -- name: Create :one
 INSERT INTO products (name,
+                      description,
                       price,
-                      stock_quantity
+                      stock_quantity,
+                      visibility,
+                      currency
                       )
-VALUES ($1, $2, $3)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: LockSKU :exec
+SELECT pg_advisory_xact_lock(hashtext(@sku::text));
+
+-- name: LockBySKU :one
+SELECT *
+FROM products
+WHERE sku = @sku::text AND deleted_at IS NULL
+FOR UPDATE;
+
+-- name: Upsert :one
+INSERT INTO products (name,
+                      description,
+                      price,
+                      stock_quantity,
+                      visibility,
+                      currency,
+                      sku
+                      )
+VALUES (@name, @description, @price, @stock_quantity, @visibility, @currency, @sku::text)
+ON CONFLICT (sku) WHERE deleted_at IS NULL DO UPDATE
+SET name           = EXCLUDED.name,
+    description    = EXCLUDED.description,
+    price          = EXCLUDED.price,
+    stock_quantity = EXCLUDED.stock_quantity,
+    version        = products.version + 1,
+    updated_at     = NOW()
+WHERE (products.name, products.description, products.price, products.stock_quantity)
+          IS DISTINCT FROM (EXCLUDED.name, EXCLUDED.description, EXCLUDED.price, EXCLUDED.stock_quantity)
 RETURNING *;
 
 -- name: FindByID :one
 SELECT *
 FROM products
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
 
 -- name: FindByIDs :many
 SELECT * FROM products
 WHERE id = ANY(@ids::uuid[]);
 
+-- name: FindTakenNames :many
+SELECT name FROM products
+WHERE lower(name) IN (SELECT lower(n) FROM unnest(@names::text[]) AS n) AND deleted_at IS NULL;
+
 -- name: FindAll :many
 SELECT *
 FROM products
+WHERE deleted_at IS NULL
+  AND (visibility = 'public' OR @include_internal::bool)
 ORDER BY created_at DESC
-LIMIT $1 OFFSET $2;
+LIMIT @lim OFFSET @off;
+
+-- name: FindFirstPage :many
+SELECT *
+FROM products
+WHERE deleted_at IS NULL
+  AND (visibility = 'public' OR @include_internal::bool)
+ORDER BY created_at DESC, id DESC
+LIMIT @lim;
+
+-- name: FindPageAfter :many
+SELECT *
+FROM products
+WHERE deleted_at IS NULL
+  AND (created_at, id) < (@created_at::timestamp, @id::uuid)
+  AND (visibility = 'public' OR @include_internal::bool)
+ORDER BY created_at DESC, id DESC
+LIMIT @lim;
+
+-- name: Search :many
+SELECT *
+FROM products
+WHERE deleted_at IS NULL
+  AND search_vector @@ plainto_tsquery('english', @query::text)
+  AND (visibility = 'public' OR @include_internal::bool)
+ORDER BY ts_rank(search_vector, plainto_tsquery('english', @query::text)) DESC, created_at DESC, id DESC
+LIMIT @lim OFFSET @off;
+
+-- name: LockByID :one
+SELECT *
+FROM products
+WHERE id = $1
+FOR UPDATE;
 
 -- name: Update :one
 UPDATE products
 SET name           = $2,
     price          = $3,
     stock_quantity = $4,
-    version        = version + 1
-WHERE id = $1 AND VERSION = $5
+    description    = $6,
+    version        = version + 1,
+    updated_at     = NOW()
+WHERE id = $1 AND VERSION = $5 AND deleted_at IS NULL
 RETURNING *;
 
 -- name: Delete :execrows
@@ -35,9 +108,85 @@ DELETE
 FROM products
 WHERE id = $1 AND VERSION = $2;
 
+-- name: SoftDelete :one
+UPDATE products
+SET deleted_at = NOW(),
+    version    = version + 1,
+    updated_at = NOW()
+WHERE id = $1 AND VERSION = $2 AND deleted_at IS NULL
+RETURNING *;
+
 -- name: UpdateStock :one
 UPDATE products
 SET stock_quantity = $2,
-    version        = version + 1
-WHERE id = $1 AND VERSION = $3
+    restock_at     = $4,
+    version        = version + 1,
+    updated_at     = NOW()
+WHERE id = $1 AND VERSION = $3 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: AdjustStock :one
+UPDATE products
+SET stock_quantity = stock_quantity + @delta::int,
+    version        = version + 1,
+    updated_at     = NOW()
+WHERE id = @id AND VERSION = @version AND deleted_at IS NULL
+  AND stock_quantity::bigint + @delta::int BETWEEN 0 AND 2147483647
 RETURNING *;
+
+-- name: AddStock :one
+UPDATE products
+SET stock_quantity = stock_quantity + @delta::int,
+    version        = version + 1,
+    updated_at     = NOW()
+WHERE id = @id AND deleted_at IS NULL
+  AND stock_quantity::bigint + @delta::int BETWEEN 0 AND 2147483647
+RETURNING *;
+
+-- name: RecordPrice :exec
+INSERT INTO product_price_history (product_id, price)
+SELECT @product_id::uuid, @price::bigint
+WHERE @price::bigint IS DISTINCT FROM (SELECT h.price
+                                       FROM product_price_history h
+                                       WHERE h.product_id = @product_id::uuid
+                                       ORDER BY h.recorded_at DESC
+                                       LIMIT 1);
+
+-- name: AggregatePriceHistory :many
+WITH buckets AS (SELECT b::timestamp                                                      AS bucket_start,
+                        GREATEST(b, @from_time::timestamp)                                AS window_start,
+                        LEAST(b + ('1 ' || @bucket::text)::interval, @to_time::timestamp) AS window_end
+                 FROM generate_series(date_trunc(@bucket::text, @from_time::timestamp),
+                                      @to_time::timestamp - interval '1 microsecond',
+                                      ('1 ' || @bucket::text)::interval) AS b)
+SELECT buckets.bucket_start,
+       MIN(prices.price)::bigint                                                     AS min_price,
+       MAX(prices.price)::bigint                                                     AS max_price,
+       AVG(prices.price)::float8                                                     AS avg_price,
+       COUNT(prices.price) FILTER (WHERE prices.recorded_at >= buckets.window_start) AS changes
+FROM buckets
+         LEFT JOIN LATERAL ((SELECT h.price, h.recorded_at
+                             FROM product_price_history h
+                             WHERE h.product_id = @product_id
+                               AND h.recorded_at <= buckets.window_start
+                             ORDER BY h.recorded_at DESC
+                             LIMIT 1)
+                            UNION ALL
+                            (SELECT h.price, h.recorded_at
+                             FROM product_price_history h
+                             WHERE h.product_id = @product_id
+                               AND h.recorded_at > buckets.window_start
+                               AND h.recorded_at < buckets.window_end)) prices ON TRUE
+GROUP BY buckets.bucket_start
+HAVING COUNT(prices.price) > 0
+ORDER BY buckets.bucket_start;
+
+-- name: RecordAudit :exec
+INSERT INTO product_audit (product_id, action, old_values, new_values, version)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: FindAuditByProductID :many
+SELECT *
+FROM product_audit
+WHERE product_id = $1
+ORDER BY id;
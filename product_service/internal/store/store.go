@@ -3,6 +3,7 @@ package store
 
 import (
 	"context"
+	"time"
 
 	"github.com/abgdnv/gocommerce/product_service/internal/store/db"
 	"github.com/google/uuid"
@@ -10,32 +11,123 @@ import (
 
 // ProductStore is an interface for product storage operations.
 // It abstracts the underlying data store, allowing for different implementations (e.g., in-memory, database).
+// Every change of a product is recorded in its audit log together with the change itself, see History.
 type ProductStore interface {
-	// FindByID retrieves a single product by its unique identifier.
+	// FindByID retrieves a single product by its unique identifier, regardless of its visibility.
 	// Returns ErrProductNotFound if no product exists with the given ID.
 	FindByID(ctx context.Context, id uuid.UUID) (*db.Product, error)
 
-	// FindByIDs retrieves products by unique identifiers.
+	// FindByIDs retrieves products by unique identifiers, including the soft-deleted ones.
 	// Returns an empty slice if no products exist.
 	FindByIDs(ctx context.Context, id []uuid.UUID) ([]db.Product, error)
 
-	// FindAll returns all available products.
+	// FindTakenNames returns the names of the live products named like any of the names, ignoring the case,
+	// which the unique index of the names rejects for a new product. Returns an empty slice if none of them is taken.
+	FindTakenNames(ctx context.Context, names []string) ([]string, error)
+
+	// FindAll returns all available products, the internal ones only if includeInternal is set.
+	// Returns an empty slice if no products exist.
+	FindAll(ctx context.Context, offset, limit int32, includeInternal bool) ([]db.Product, error)
+
+	// FindAllByCursor returns up to limit products ordered by (created_at, id) descending,
+	// starting after the given keyset. A nil createdAt returns the first page.
+	// The internal products are returned only if includeInternal is set.
 	// Returns an empty slice if no products exist.
-	FindAll(ctx context.Context, offset, limit int32) ([]db.Product, error)
+	FindAllByCursor(ctx context.Context, createdAt *time.Time, id uuid.UUID, limit int32, includeInternal bool) ([]db.Product, error)
+
+	// Search returns the available products matching all the words of the query, the most relevant first,
+	// the internal ones only if includeInternal is set. Stop words are ignored, so a query of only stop words matches nothing.
+	// Returns an empty slice if no products match.
+	Search(ctx context.Context, query string, offset, limit int32, includeInternal bool) ([]db.Product, error)
+
+	// Create adds a new product with an optional description, the visibility and the currency of its price to the system
+	// and records its price in the price history. Returns ErrProductAlreadyExists if the product names are unique
+	// and another live product has the name, or another error if the product cannot be created.
+	Create(ctx context.Context, name string, description *string, price int64, stock int32, visibility, currency string) (*db.Product, error)
+
+	// CreateBatch adds the products in a single transaction and records their prices in the price history.
+	// Either all the products are created, in the given order, or none of them.
+	// Returns ErrProductAlreadyExists if the product names are unique and any of the names is taken.
+	CreateBatch(ctx context.Context, products []db.CreateParams) ([]db.Product, error)
+
+	// Upsert creates a product with the external SKU, or updates the details of the live product with the SKU,
+	// and reports whether the product was created. The visibility and the currency only apply to a created product.
+	// Upserting the current values of a product leaves it unchanged, so repeating an upsert is a no-op.
+	// Returns ErrProductAlreadyExists if the product names are unique and another live product has the name,
+	// or ErrCurrencyMismatch if the currency differs from the currency of the existing product.
+	Upsert(ctx context.Context, sku, name string, description *string, price int64, stock int32, visibility, currency string) (*db.Product, bool, error)
+
+	// Update modifies an existing product's details and records a changed price in the price history.
+	// A nil description clears the previous one.
+	// Returns ErrProductNotFound if no product exists with the given ID, ErrOptimisticLock if its version differs,
+	// or ErrProductAlreadyExists if the product names are unique and another live product has the new name.
+	Update(ctx context.Context, id uuid.UUID, name string, description *string, price int64, stock int32, version int32) (*db.Product, error)
+
+	// UpdateStock adjusts the stock quantity and the expected restock time of a product.
+	// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
+	UpdateStock(ctx context.Context, id uuid.UUID, stock int32, version int32, restockAt *time.Time) (*db.Product, error)
+
+	// AdjustStock adds the delta to the stock quantity of a product, a negative delta reduces it.
+	// Returns ErrProductNotFound if no product exists with the given ID, ErrOptimisticLock if its version differs,
+	// ErrInsufficientStock if the stock quantity would go below zero, or ErrStockOverflow if it would exceed the range of int4.
+	AdjustStock(ctx context.Context, id uuid.UUID, delta int32, version int32) (*db.Product, error)
 
-	// Create adds a new product to the system.
-	// Returns error if the product cannot be created.
-	Create(ctx context.Context, name string, price int64, stock int32) (*db.Product, error)
+	// ReserveStock takes the quantities from the stock of the products regardless of their versions, all or none.
+	// Returns ErrProductNotFound if any of the products doesn't exist,
+	// or ErrInsufficientStock if the stock quantity of any of the products is below its quantity.
+	ReserveStock(ctx context.Context, quantities map[uuid.UUID]int32) error
 
-	// Update modifies an existing product's details.
-	// Returns ErrProductNotFound if no product exists with the given ID and version.
-	Update(ctx context.Context, id uuid.UUID, name string, price int64, stock int32, version int32) (*db.Product, error)
+	// ReleaseStock returns the quantities taken by ReserveStock to the stock of the products, all or none.
+	// Returns ErrProductNotFound if any of the products doesn't exist,
+	// or ErrStockOverflow if the stock quantity of any of the products would exceed the range of int4.
+	ReleaseStock(ctx context.Context, quantities map[uuid.UUID]int32) error
 
-	// UpdateStock adjusts the stock quantity of a product.
-	// Returns ErrProductNotFound if no product exists with the given ID and version.
-	UpdateStock(ctx context.Context, id uuid.UUID, stock int32, version int32) (*db.Product, error)
+	// PriceHistory aggregates the prices a product had in [from, to) into buckets of the given interval ("day" or "week").
+	// Every bucket starts with the price in effect at its start, so a bucket without price changes carries the previous price.
+	// Only the buckets before the first recorded price of the product are omitted.
+	PriceHistory(ctx context.Context, id uuid.UUID, interval string, from, to time.Time) ([]db.AggregatePriceHistoryRow, error)
+
+	// History returns the changes of a product recorded in its audit log, oldest first, including the deletion.
+	// Returns an empty slice if no changes are recorded.
+	History(ctx context.Context, id uuid.UUID) ([]ProductChange, error)
 
 	// DeleteByID removes a product by its ID.
-	// Returns ErrProductNotFound if no product exists with the given ID.
+	// Returns ErrProductNotFound if no product exists with the given ID, or ErrOptimisticLock if its version differs.
 	DeleteByID(ctx context.Context, id uuid.UUID, version int32) error
+
+	// SoftDeleteByID marks a product as deleted. A soft-deleted product is only returned by FindByIDs.
+	// Returns ErrProductNotFound if no live product exists with the given ID, or ErrOptimisticLock if its version differs.
+	SoftDeleteByID(ctx context.Context, id uuid.UUID, version int32) error
+}
+
+// DefaultCurrency is the ISO 4217 code of the currency of the products created without one.
+const DefaultCurrency = "USD"
+
+// Visibility of the products. The internal products are hidden from the anonymous and customer callers.
+const (
+	VisibilityPublic   = "public"
+	VisibilityInternal = "internal"
+)
+
+// ProductChange is a change of a product recorded in its audit log.
+type ProductChange struct {
+	// Action is one of the audit actions, e.g. audit.ActionUpdate.
+	Action string
+	// Old is nil for a created product.
+	Old *ProductValues
+	// New is nil for a deleted product, a soft-deleted product has DeletedAt set instead.
+	New *ProductValues
+	// Version is the version of the product after the change, or the deleted version.
+	Version   int32
+	ChangedAt time.Time
+}
+
+// ProductValues are the values of a product before or after a change.
+type ProductValues struct {
+	Name          string     `json:"name"`
+	Description   *string    `json:"description,omitempty"`
+	Price         int64      `json:"price"`
+	StockQuantity int32      `json:"stock_quantity"`
+	RestockAt     *time.Time `json:"restock_at"`
+	DeletedAt     *time.Time `json:"deleted_at"`
 }
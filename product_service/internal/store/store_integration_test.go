@@ -1,14 +1,21 @@
 package store
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/abgdnv/gocommerce/pkg/telemetry"
 	perrors "github.com/abgdnv/gocommerce/product_service/internal/errors"
 	"github.com/abgdnv/gocommerce/product_service/internal/store/db"
 	"github.com/golang-migrate/migrate/v4"
@@ -100,7 +107,7 @@ func (s *ProductStoreSuite) SetupSuite() {
 	}
 	s.logger.Info("Migrations applied for E2E tests")
 
-	s.store = NewPgStore(s.dbPool)
+	s.store = NewPgStore(s.dbPool, 0, nil, true)
 	s.logger.Info("Initialization complete for ProductStoreSuite")
 }
 
@@ -124,7 +131,7 @@ func (s *ProductStoreSuite) TearDownSuite() {
 
 // SetupTest prepares the database for each test by truncating the products table.
 func (s *ProductStoreSuite) SetupTest() {
-	_, err := s.dbPool.Exec(s.ctx, "TRUNCATE TABLE products RESTART IDENTITY CASCADE")
+	_, err := s.dbPool.Exec(s.ctx, "TRUNCATE TABLE products, product_audit RESTART IDENTITY CASCADE")
 	require.NoError(s.T(), err, "Failed to truncate products table")
 }
 
@@ -141,7 +148,7 @@ func TestProductStoreIntegration(t *testing.T) {
 // createTestProduct is a helper function to create a product for testing purposes.
 func (s *ProductStoreSuite) createTestProduct(name string, price int64, stock int32) *db.Product {
 	s.T().Helper()
-	product, err := s.store.Create(s.ctx, name, price, stock)
+	product, err := s.store.Create(s.ctx, name, nil, price, stock, VisibilityPublic, DefaultCurrency)
 	require.NoError(s.T(), err, "createTestProduct helper failed to create product")
 	return product
 }
@@ -161,6 +168,7 @@ func (s *ProductStoreSuite) TestCreateAndFindByID() {
 	require.Equal(s.T(), toCreate.Price, created.Price)
 	require.Equal(s.T(), toCreate.StockQuantity, created.StockQuantity)
 	require.NotZero(s.T(), *created.CreatedAt, "CreatedAt should be set")
+	require.Equal(s.T(), DefaultCurrency, created.Currency)
 
 	// 3. Fetch the product by ID
 	fetched, err := s.store.FindByID(s.ctx, created.ID)
@@ -174,6 +182,239 @@ func (s *ProductStoreSuite) TestCreateAndFindByID() {
 	require.WithinDuration(s.T(), *created.CreatedAt, *fetched.CreatedAt, time.Second)
 }
 
+func (s *ProductStoreSuite) TestCreateBatch() {
+	// given
+	toCreate := []db.CreateParams{
+		{Name: "Google Pixel 8", Price: 69900, StockQuantity: 10, Visibility: VisibilityPublic, Currency: "USD"},
+		{Name: "Google Pixel 8 Pro", Price: 99900, StockQuantity: 5, Visibility: VisibilityInternal, Currency: "EUR"},
+	}
+
+	// when
+	created, err := s.store.CreateBatch(s.ctx, toCreate)
+
+	// then
+	require.NoError(s.T(), err)
+	require.Len(s.T(), created, len(toCreate))
+	for i, product := range created {
+		assert.Equal(s.T(), toCreate[i].Name, product.Name, "products should be created in the given order")
+		fetched, err := s.store.FindByID(s.ctx, product.ID)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), toCreate[i].Price, fetched.Price)
+		assert.Equal(s.T(), toCreate[i].Visibility, fetched.Visibility)
+		assert.Equal(s.T(), toCreate[i].Currency, fetched.Currency)
+		assert.Equal(s.T(), []int64{toCreate[i].Price}, s.priceHistory(product.ID))
+	}
+}
+
+func (s *ProductStoreSuite) TestCreateBatch_RolledBackOnFailure() {
+	// given: the second product's name exceeds the column length
+	toCreate := []db.CreateParams{
+		{Name: "Google Pixel 8a", Price: 49900, StockQuantity: 10, Visibility: VisibilityPublic, Currency: DefaultCurrency},
+		{Name: strings.Repeat("x", 256), Price: 99900, StockQuantity: 5, Visibility: VisibilityPublic, Currency: DefaultCurrency},
+	}
+
+	// when
+	created, err := s.store.CreateBatch(s.ctx, toCreate)
+
+	// then
+	require.Error(s.T(), err)
+	assert.Nil(s.T(), created)
+	products, err := s.store.FindAll(s.ctx, 0, 10, true)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), products, "no product of a failed batch should be created")
+}
+
+func (s *ProductStoreSuite) TestUniqueNames() {
+	// given
+	existing := s.createTestProduct("OnePlus 12", 79900, 10)
+	other := s.createTestProduct("OnePlus 12R", 49900, 10)
+	deleted := s.createTestProduct("OnePlus Open", 169900, 1)
+	require.NoError(s.T(), s.store.SoftDeleteByID(s.ctx, deleted.ID, deleted.Version))
+
+	// when
+	_, createErr := s.store.Create(s.ctx, "oneplus 12", nil, 79900, 5, VisibilityPublic, DefaultCurrency)
+	_, batchErr := s.store.CreateBatch(s.ctx, []db.CreateParams{
+		{Name: "OnePlus 13", Price: 89900, StockQuantity: 10, Visibility: VisibilityPublic, Currency: DefaultCurrency},
+		{Name: "OnePlus 12", Price: 79900, StockQuantity: 10, Visibility: VisibilityPublic, Currency: DefaultCurrency},
+	})
+	_, updateErr := s.store.Update(s.ctx, other.ID, existing.Name, nil, other.Price, other.StockQuantity, other.Version)
+	reused, reuseErr := s.store.Create(s.ctx, deleted.Name, nil, 159900, 1, VisibilityPublic, DefaultCurrency)
+
+	// then
+	require.ErrorIs(s.T(), createErr, perrors.ErrProductAlreadyExists, "the names should be compared ignoring the case")
+	require.ErrorIs(s.T(), batchErr, perrors.ErrProductAlreadyExists)
+	require.ErrorIs(s.T(), updateErr, perrors.ErrProductAlreadyExists)
+	require.NoError(s.T(), reuseErr, "the name of a soft-deleted product should be free")
+	assert.Equal(s.T(), deleted.Name, reused.Name)
+	products, err := s.store.FindAll(s.ctx, 0, 10, true)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), products, 3, "no product of a failed batch should be created")
+
+	// when: the duplicate names are not reported as such
+	_, err = NewPgStore(s.dbPool, 0, nil, false).Create(s.ctx, existing.Name, nil, 79900, 5, VisibilityPublic, DefaultCurrency)
+
+	// then
+	require.Error(s.T(), err, "the index of the migrations should reject the duplicate name")
+	assert.NotErrorIs(s.T(), err, perrors.ErrProductAlreadyExists)
+}
+
+func (s *ProductStoreSuite) TestFindTakenNames() {
+	// given
+	s.createTestProduct("Nothing Phone 2", 59900, 10)
+	deleted := s.createTestProduct("Nothing Phone 1", 39900, 1)
+	require.NoError(s.T(), s.store.SoftDeleteByID(s.ctx, deleted.ID, deleted.Version))
+
+	// when
+	taken, err := s.store.FindTakenNames(s.ctx, []string{"NOTHING PHONE 2", "Nothing Phone 1", "Nothing Phone 3"})
+
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"Nothing Phone 2"}, taken, "only the names of the live products should be taken, ignoring the case")
+}
+
+func (s *ProductStoreSuite) TestUpsert_Insert() {
+	// given
+	description := "A Samsung flagship"
+
+	// when
+	created, isNew, err := s.store.Upsert(s.ctx, "ERP-1001", "Galaxy S24", &description, 79900, 10, VisibilityInternal, "EUR")
+
+	// then
+	require.NoError(s.T(), err)
+	assert.True(s.T(), isNew, "a product with a new SKU should be created")
+	require.NotNil(s.T(), created.Sku)
+	assert.Equal(s.T(), "ERP-1001", *created.Sku)
+	assert.Equal(s.T(), int32(1), created.Version)
+	assert.Equal(s.T(), VisibilityInternal, created.Visibility)
+	assert.Equal(s.T(), "EUR", created.Currency)
+	fetched, err := s.store.FindByID(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), created.Sku, fetched.Sku)
+	assert.Equal(s.T(), []int64{79900}, s.priceHistory(created.ID))
+	changes, err := s.store.History(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), changes, 1)
+	assert.Equal(s.T(), "create", changes[0].Action)
+}
+
+func (s *ProductStoreSuite) TestUpsert_Update() {
+	// given
+	created, _, err := s.store.Upsert(s.ctx, "ERP-1002", "Galaxy S24+", nil, 99900, 10, VisibilityPublic, DefaultCurrency)
+	require.NoError(s.T(), err)
+
+	// when
+	updated, isNew, err := s.store.Upsert(s.ctx, "ERP-1002", "Galaxy S24 Plus", nil, 94900, 7, VisibilityInternal, DefaultCurrency)
+
+	// then
+	require.NoError(s.T(), err)
+	assert.False(s.T(), isNew, "the product with the SKU should be updated")
+	assert.Equal(s.T(), created.ID, updated.ID)
+	assert.Equal(s.T(), "Galaxy S24 Plus", updated.Name)
+	assert.Equal(s.T(), int64(94900), updated.Price)
+	assert.Equal(s.T(), int32(7), updated.StockQuantity)
+	assert.Equal(s.T(), created.Version+1, updated.Version)
+	assert.Equal(s.T(), VisibilityPublic, updated.Visibility, "the visibility should only apply to a created product")
+	assert.Equal(s.T(), []int64{99900, 94900}, s.priceHistory(created.ID))
+	changes, err := s.store.History(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), changes, 2)
+	assert.Equal(s.T(), "update", changes[1].Action)
+	assert.Equal(s.T(), &ProductValues{Name: "Galaxy S24+", Price: 99900, StockQuantity: 10}, changes[1].Old)
+	assert.Equal(s.T(), &ProductValues{Name: "Galaxy S24 Plus", Price: 94900, StockQuantity: 7}, changes[1].New)
+}
+
+func (s *ProductStoreSuite) TestUpsert_CurrencyMismatch() {
+	// given
+	created, _, err := s.store.Upsert(s.ctx, "ERP-1005", "Galaxy A55", nil, 44900, 10, VisibilityPublic, "EUR")
+	require.NoError(s.T(), err)
+
+	// when
+	_, _, err = s.store.Upsert(s.ctx, "ERP-1005", "Galaxy A55", nil, 47900, 10, VisibilityPublic, DefaultCurrency)
+
+	// then
+	require.ErrorIs(s.T(), err, perrors.ErrCurrencyMismatch, "the price in another currency should be rejected")
+	found, err := s.store.FindByID(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(44900), found.Price)
+	assert.Equal(s.T(), created.Version, found.Version)
+}
+
+func (s *ProductStoreSuite) TestUpsert_Unchanged() {
+	// given
+	created, _, err := s.store.Upsert(s.ctx, "ERP-1003", "Galaxy S24 Ultra", nil, 129900, 3, VisibilityPublic, DefaultCurrency)
+	require.NoError(s.T(), err)
+
+	// when
+	repeated, isNew, err := s.store.Upsert(s.ctx, "ERP-1003", "Galaxy S24 Ultra", nil, 129900, 3, VisibilityPublic, DefaultCurrency)
+
+	// then
+	require.NoError(s.T(), err)
+	assert.False(s.T(), isNew)
+	assert.Equal(s.T(), created.ID, repeated.ID)
+	assert.Equal(s.T(), created.Version, repeated.Version, "a repeated upsert should not change the product")
+	changes, err := s.store.History(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), changes, 1, "a repeated upsert should not be recorded")
+}
+
+func (s *ProductStoreSuite) TestUpsert_SoftDeletedSKU() {
+	// given
+	deleted, _, err := s.store.Upsert(s.ctx, "ERP-1004", "Galaxy Z Fold", nil, 179900, 1, VisibilityPublic, DefaultCurrency)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), s.store.SoftDeleteByID(s.ctx, deleted.ID, deleted.Version))
+
+	// when
+	created, isNew, err := s.store.Upsert(s.ctx, "ERP-1004", "Galaxy Z Fold 6", nil, 189900, 1, VisibilityPublic, DefaultCurrency)
+
+	// then
+	require.NoError(s.T(), err)
+	assert.True(s.T(), isNew, "the SKU of a soft-deleted product should be free")
+	assert.NotEqual(s.T(), deleted.ID, created.ID)
+}
+
+func (s *ProductStoreSuite) TestUpsert_ConcurrentRace() {
+	// given
+	const upserts = 10
+	var wg sync.WaitGroup
+	results := make(chan bool, upserts)
+	errs := make(chan error, upserts)
+
+	// when
+	for i := range upserts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, isNew, err := s.store.Upsert(s.ctx, "ERP-1005", "Galaxy A55", nil, 44900+int64(i), 10, VisibilityPublic, DefaultCurrency)
+			errs <- err
+			results <- isNew
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	close(results)
+
+	// then
+	for err := range errs {
+		require.NoError(s.T(), err)
+	}
+	created := 0
+	for isNew := range results {
+		if isNew {
+			created++
+		}
+	}
+	assert.Equal(s.T(), 1, created, "exactly one of the concurrent upserts should create the product")
+	var count int
+	require.NoError(s.T(), s.dbPool.QueryRow(s.ctx, "SELECT COUNT(*) FROM products WHERE sku = 'ERP-1005'").Scan(&count))
+	assert.Equal(s.T(), 1, count, "the concurrent upserts should not duplicate the product")
+	products, err := s.store.FindAll(s.ctx, 0, 10, true)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), products, 1)
+	changes, err := s.store.History(s.ctx, products[0].ID)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), changes, upserts, "every upsert of a different price should be recorded")
+}
+
 func (s *ProductStoreSuite) TestFindByID_NotFound() {
 	// Attempt to fetch a product that does not exist
 	_, err := s.store.FindByID(s.ctx, uuid.New())
@@ -217,7 +458,7 @@ func (s *ProductStoreSuite) TestListProducts() {
 	s.createTestProduct("Product A", 100, 10)
 	s.createTestProduct("Product B", 200, 20)
 
-	products, err := s.store.FindAll(s.ctx, 0, 10)
+	products, err := s.store.FindAll(s.ctx, 0, 10, true)
 
 	require.NoError(s.T(), err)
 	require.Len(s.T(), products, 2, "Should retrieve 2 products")
@@ -225,6 +466,194 @@ func (s *ProductStoreSuite) TestListProducts() {
 	assert.Equal(s.T(), "Product A", products[1].Name)
 }
 
+func (s *ProductStoreSuite) TestFindAll_Visibility() {
+	// given
+	public := s.createTestProduct("Public Product", 100, 10)
+	internal, err := s.store.Create(s.ctx, "Internal Product", nil, 200, 20, VisibilityInternal, DefaultCurrency)
+	require.NoError(s.T(), err)
+
+	testCases := []struct {
+		name            string
+		includeInternal bool
+		expected        []uuid.UUID
+	}{
+		{
+			name:            "internal products included",
+			includeInternal: true,
+			expected:        []uuid.UUID{internal.ID, public.ID},
+		},
+		{
+			name:     "internal products filtered",
+			expected: []uuid.UUID{public.ID},
+		},
+	}
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			// when
+			products, err := s.store.FindAll(s.ctx, 0, 10, tc.includeInternal)
+			require.NoError(s.T(), err)
+			firstPage, err := s.store.FindAllByCursor(s.ctx, nil, uuid.Nil, 10, tc.includeInternal)
+			require.NoError(s.T(), err)
+			last := firstPage[0]
+			nextPage, err := s.store.FindAllByCursor(s.ctx, last.CreatedAt, last.ID, 10, tc.includeInternal)
+			require.NoError(s.T(), err)
+
+			// then
+			assert.Equal(s.T(), tc.expected, productIDs(products))
+			assert.Equal(s.T(), tc.expected, productIDs(firstPage))
+			assert.Equal(s.T(), tc.expected[1:], productIDs(nextPage))
+		})
+	}
+
+	// the lookup by ID doesn't filter, the caller decides whether to disclose an internal product
+	found, err := s.store.FindByID(s.ctx, internal.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), VisibilityInternal, found.Visibility)
+}
+
+func (s *ProductStoreSuite) TestSearch_Relevance() {
+	// given
+	adjacent := s.createTestProduct("Wireless Headphones", 100, 10)
+	apart := s.createTestProduct("Wireless Charger for Noise Cancelling Headphones", 200, 20)
+	s.createTestProduct("Wireless Mouse", 300, 30)
+	s.createTestProduct("Headphones Stand", 400, 40)
+
+	// when
+	products, err := s.store.Search(s.ctx, "wireless headphones", 0, 10, false)
+
+	// then: all the words must match, the closer they are the more relevant the product
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []uuid.UUID{adjacent.ID, apart.ID}, productIDs(products))
+}
+
+func (s *ProductStoreSuite) TestSearch_StopWords() {
+	// given
+	headphones := s.createTestProduct("Wireless Headphones", 100, 10)
+	stand := s.createTestProduct("Headphones Stand", 200, 20)
+	s.createTestProduct("Wireless Mouse", 300, 30)
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected []uuid.UUID
+	}{
+		{
+			name:     "stop words ignored",
+			query:    "the headphones",
+			expected: []uuid.UUID{headphones.ID, stand.ID},
+		},
+		{
+			name:     "only stop words",
+			query:    "the and of",
+			expected: []uuid.UUID{},
+		},
+	}
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			// when
+			products, err := s.store.Search(s.ctx, tc.query, 0, 10, false)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.ElementsMatch(s.T(), tc.expected, productIDs(products))
+		})
+	}
+}
+
+func (s *ProductStoreSuite) TestSearch_Visibility() {
+	// given
+	public := s.createTestProduct("Public Headphones", 100, 10)
+	internal, err := s.store.Create(s.ctx, "Internal Headphones", nil, 200, 20, VisibilityInternal, DefaultCurrency)
+	require.NoError(s.T(), err)
+	deleted := s.createTestProduct("Deleted Headphones", 300, 30)
+	require.NoError(s.T(), s.store.SoftDeleteByID(s.ctx, deleted.ID, deleted.Version))
+
+	// when
+	publicOnly, err := s.store.Search(s.ctx, "headphones", 0, 10, false)
+	require.NoError(s.T(), err)
+	all, err := s.store.Search(s.ctx, "headphones", 0, 10, true)
+	require.NoError(s.T(), err)
+
+	// then: the soft-deleted products are never found
+	assert.ElementsMatch(s.T(), []uuid.UUID{public.ID}, productIDs(publicOnly))
+	assert.ElementsMatch(s.T(), []uuid.UUID{public.ID, internal.ID}, productIDs(all))
+}
+
+func (s *ProductStoreSuite) TestSearch_Description() {
+	// given
+	description := "A stand for headphones"
+	inDescription, err := s.store.Create(s.ctx, "Desk Stand", &description, 100, 10, VisibilityPublic, DefaultCurrency)
+	require.NoError(s.T(), err)
+	inName := s.createTestProduct("Wireless Headphones", 200, 20)
+
+	// when
+	products, err := s.store.Search(s.ctx, "headphones", 0, 10, false)
+
+	// then: the matches of the name are more relevant than the ones of the description
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []uuid.UUID{inName.ID, inDescription.ID}, productIDs(products))
+}
+
+// productIDs returns the IDs of the products in order.
+func productIDs(products []db.Product) []uuid.UUID {
+	ids := make([]uuid.UUID, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func (s *ProductStoreSuite) TestFindAllByCursor_PagesWithoutGapsOrDuplicates() {
+	// given: 50 products, half of them sharing the same created_at to exercise the id tie-breaker
+	const total = 50
+	created := make(map[uuid.UUID]bool, total)
+	for i := range total {
+		p := s.createTestProduct(fmt.Sprintf("Product %02d", i), int64(100+i), int32(i))
+		created[p.ID] = true
+	}
+	_, err := s.dbPool.Exec(s.ctx, `UPDATE products SET created_at = '2025-01-01 00:00:00' WHERE price % 2 = 0`)
+	require.NoError(s.T(), err, "Failed to align created_at")
+
+	// when: paging through all products with the keyset of the last item
+	seen := make(map[uuid.UUID]bool, total)
+	var pages int
+	var createdAt *time.Time
+	var id uuid.UUID
+	for {
+		page, err := s.store.FindAllByCursor(s.ctx, createdAt, id, 7, true)
+		require.NoError(s.T(), err)
+		if len(page) == 0 {
+			break
+		}
+		pages++
+		for i, p := range page {
+			require.False(s.T(), seen[p.ID], "product %s returned twice", p.ID)
+			seen[p.ID] = true
+			if i > 0 {
+				prev := page[i-1]
+				ordered := prev.CreatedAt.After(*p.CreatedAt) ||
+					(prev.CreatedAt.Equal(*p.CreatedAt) && prev.ID.String() > p.ID.String())
+				require.True(s.T(), ordered, "products should be ordered by (created_at, id) desc")
+			}
+		}
+		last := page[len(page)-1]
+		createdAt, id = last.CreatedAt, last.ID
+	}
+
+	// then
+	assert.Equal(s.T(), 8, pages, "50 products by 7 should give 8 pages")
+	assert.Equal(s.T(), created, seen, "all products should be returned exactly once")
+}
+
+func (s *ProductStoreSuite) TestFindAllByCursor_Empty() {
+	// when
+	products, err := s.store.FindAllByCursor(s.ctx, nil, uuid.Nil, 10, true)
+
+	// then
+	require.NoError(s.T(), err)
+	require.Len(s.T(), products, 0)
+}
+
 func (s *ProductStoreSuite) TestUpdateProduct() {
 	// Create a product to update
 	created := s.createTestProduct("Samsung Galaxy S23", 69900, 50)
@@ -237,7 +666,7 @@ func (s *ProductStoreSuite) TestUpdateProduct() {
 		StockQuantity: 30,
 		Version:       created.Version,
 	}
-	updated, err := s.store.Update(s.ctx, toUpdate.ID, toUpdate.Name, toUpdate.Price, toUpdate.StockQuantity, toUpdate.Version)
+	updated, err := s.store.Update(s.ctx, toUpdate.ID, toUpdate.Name, nil, toUpdate.Price, toUpdate.StockQuantity, toUpdate.Version)
 	require.NoError(s.T(), err, "Update should not return an error")
 
 	// Check that the updated product matches the new details
@@ -258,7 +687,7 @@ func (s *ProductStoreSuite) TestUpdateProduct_NotFound() {
 		StockQuantity: 0,
 		Version:       1,
 	}
-	_, err := s.store.Update(s.ctx, toUpdate.ID, toUpdate.Name, toUpdate.Price, toUpdate.StockQuantity, toUpdate.Version)
+	_, err := s.store.Update(s.ctx, toUpdate.ID, toUpdate.Name, nil, toUpdate.Price, toUpdate.StockQuantity, toUpdate.Version)
 	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "Expected ErrProductNotFound for non-existent product")
 }
 
@@ -274,8 +703,8 @@ func (s *ProductStoreSuite) TestUpdateProduct_WrongVersion() {
 		StockQuantity: 10,
 		Version:       created.Version + 1, // Incrementing the version to simulate a conflict
 	}
-	_, err := s.store.Update(s.ctx, toUpdate.ID, toUpdate.Name, toUpdate.Price, toUpdate.StockQuantity, toUpdate.Version)
-	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "Expected ErrProductNotFound for wrong version")
+	_, err := s.store.Update(s.ctx, toUpdate.ID, toUpdate.Name, nil, toUpdate.Price, toUpdate.StockQuantity, toUpdate.Version)
+	require.ErrorIs(s.T(), err, perrors.ErrOptimisticLock, "Expected ErrOptimisticLock for wrong version")
 }
 
 func (s *ProductStoreSuite) TestUpdateStock() {
@@ -284,20 +713,38 @@ func (s *ProductStoreSuite) TestUpdateStock() {
 
 	// Update the product's stock
 	newStock := int32(15)
-	updated, err := s.store.UpdateStock(s.ctx, created.ID, newStock, created.Version)
+	updated, err := s.store.UpdateStock(s.ctx, created.ID, newStock, created.Version, nil)
 	require.NoError(s.T(), err, "UpdateStock should not return an error")
 
 	// Check that the updated product has the new stock quantity
 	require.Equal(s.T(), created.ID, updated.ID)
 	require.Equal(s.T(), newStock, updated.StockQuantity)
 	require.Greater(s.T(), updated.Version, created.Version, "Version should be incremented after stock update")
+	require.Nil(s.T(), updated.RestockAt, "RestockAt should not be set")
+}
+
+func (s *ProductStoreSuite) TestUpdateStock_RestockAt() {
+	// Create a product to update stock
+	created := s.createTestProduct("Sony WH-1000XM5", 39900, 5)
+
+	// Set the expected restock time
+	restockAt := time.Now().Add(72 * time.Hour).UTC().Truncate(time.Microsecond)
+	updated, err := s.store.UpdateStock(s.ctx, created.ID, 0, created.Version, &restockAt)
+	require.NoError(s.T(), err, "UpdateStock should not return an error")
+	require.NotNil(s.T(), updated.RestockAt, "RestockAt should be set")
+	require.True(s.T(), restockAt.Equal(*updated.RestockAt), "RestockAt should match")
+
+	// Clear the restock time
+	cleared, err := s.store.UpdateStock(s.ctx, created.ID, 10, updated.Version, nil)
+	require.NoError(s.T(), err, "UpdateStock should not return an error")
+	require.Nil(s.T(), cleared.RestockAt, "RestockAt should be cleared")
 }
 
 func (s *ProductStoreSuite) TestUpdateStock_NotFound() {
 	// Attempt to update stock for a product that does not exist
 	nonExistentID := uuid.New()
 	newStock := int32(10)
-	_, err := s.store.UpdateStock(s.ctx, nonExistentID, newStock, 1)
+	_, err := s.store.UpdateStock(s.ctx, nonExistentID, newStock, 1, nil)
 	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "Expected ErrProductNotFound for non-existent product")
 }
 
@@ -308,8 +755,138 @@ func (s *ProductStoreSuite) TestUpdateStock_WrongVersion() {
 	// Attempt to update stock with an incorrect version
 	newStock := int32(25)
 	wrongVersion := created.Version + 1 // Incrementing the version to simulate a conflict
-	_, err := s.store.UpdateStock(s.ctx, created.ID, newStock, wrongVersion)
-	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "Expected ErrProductNotFound for wrong version")
+	_, err := s.store.UpdateStock(s.ctx, created.ID, newStock, wrongVersion, nil)
+	require.ErrorIs(s.T(), err, perrors.ErrOptimisticLock, "Expected ErrOptimisticLock for wrong version")
+}
+
+func (s *ProductStoreSuite) TestAdjustStock() {
+	// Create a product to adjust stock
+	created := s.createTestProduct("Nothing Phone 2", 59900, 20)
+
+	// Add to the stock
+	increased, err := s.store.AdjustStock(s.ctx, created.ID, 5, created.Version)
+	require.NoError(s.T(), err, "AdjustStock should not return an error")
+	require.Equal(s.T(), int32(25), increased.StockQuantity)
+	require.Greater(s.T(), increased.Version, created.Version, "Version should be incremented after stock adjustment")
+
+	// Take from the stock down to zero
+	decreased, err := s.store.AdjustStock(s.ctx, created.ID, -25, increased.Version)
+	require.NoError(s.T(), err, "AdjustStock should not return an error")
+	require.Equal(s.T(), int32(0), decreased.StockQuantity)
+	require.Greater(s.T(), decreased.Version, increased.Version, "Version should be incremented after stock adjustment")
+}
+
+func (s *ProductStoreSuite) TestAdjustStock_BelowZero() {
+	// Create a product to adjust stock
+	created := s.createTestProduct("Fairphone 5", 69900, 3)
+
+	// Attempt to take more than the stock
+	_, err := s.store.AdjustStock(s.ctx, created.ID, -4, created.Version)
+	require.ErrorIs(s.T(), err, perrors.ErrInsufficientStock, "Expected ErrInsufficientStock for a negative result")
+
+	// The stock and the version are unchanged
+	found, err := s.store.FindByID(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int32(3), found.StockQuantity)
+	require.Equal(s.T(), created.Version, found.Version)
+}
+
+func (s *ProductStoreSuite) TestAdjustStock_NegativeOverflow() {
+	// Create a product to adjust stock
+	created := s.createTestProduct("Asus Zenfone 10", 69900, 3)
+
+	// The result is checked in bigint, so the smallest delta doesn't overflow the stock quantity
+	_, err := s.store.AdjustStock(s.ctx, created.ID, math.MinInt32, created.Version)
+	require.ErrorIs(s.T(), err, perrors.ErrInsufficientStock, "Expected ErrInsufficientStock for an overflowing delta")
+}
+
+func (s *ProductStoreSuite) TestAdjustStock_Overflow() {
+	// Create a product to adjust stock
+	created := s.createTestProduct("Nokia XR21", 49900, 3)
+
+	// The result doesn't fit into the int4 stock quantity
+	_, err := s.store.AdjustStock(s.ctx, created.ID, math.MaxInt32, created.Version)
+	require.ErrorIs(s.T(), err, perrors.ErrStockOverflow, "Expected ErrStockOverflow for a result above the range of int4")
+
+	// Releasing the stock is checked the same way
+	err = s.store.ReleaseStock(s.ctx, map[uuid.UUID]int32{created.ID: math.MaxInt32})
+	require.ErrorIs(s.T(), err, perrors.ErrStockOverflow, "Expected ErrStockOverflow for a result above the range of int4")
+
+	// The stock and the version are unchanged
+	found, err := s.store.FindByID(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int32(3), found.StockQuantity)
+	require.Equal(s.T(), created.Version, found.Version)
+}
+
+func (s *ProductStoreSuite) TestAdjustStock_WrongVersion() {
+	// Create a product to adjust stock
+	created := s.createTestProduct("Motorola Edge 40", 49900, 10)
+
+	// Attempt to adjust stock with an incorrect version, even if the stock would go below zero
+	_, err := s.store.AdjustStock(s.ctx, created.ID, 5, created.Version+1)
+	require.ErrorIs(s.T(), err, perrors.ErrOptimisticLock, "Expected ErrOptimisticLock for wrong version")
+	_, err = s.store.AdjustStock(s.ctx, created.ID, -50, created.Version+1)
+	require.ErrorIs(s.T(), err, perrors.ErrOptimisticLock, "Expected ErrOptimisticLock for wrong version")
+}
+
+func (s *ProductStoreSuite) TestAdjustStock_NotFound() {
+	// Attempt to adjust stock for a product that does not exist
+	_, err := s.store.AdjustStock(s.ctx, uuid.New(), 5, 1)
+	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "Expected ErrProductNotFound for non-existent product")
+}
+
+func (s *ProductStoreSuite) TestReserveStock() {
+	// Create the products to reserve stock of
+	first := s.createTestProduct("Xiaomi 13T", 49900, 10)
+	second := s.createTestProduct("Honor Magic 5", 59900, 4)
+
+	// Reserve stock of both products, the versions don't matter
+	err := s.store.ReserveStock(s.ctx, map[uuid.UUID]int32{first.ID: 3, second.ID: 4})
+	require.NoError(s.T(), err, "ReserveStock should not return an error")
+
+	found, err := s.store.FindByID(s.ctx, first.ID)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int32(7), found.StockQuantity)
+	require.Greater(s.T(), found.Version, first.Version, "Version should be incremented after reserving stock")
+	found, err = s.store.FindByID(s.ctx, second.ID)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int32(0), found.StockQuantity)
+
+	// Release the reserved stock
+	err = s.store.ReleaseStock(s.ctx, map[uuid.UUID]int32{first.ID: 3, second.ID: 4})
+	require.NoError(s.T(), err, "ReleaseStock should not return an error")
+
+	found, err = s.store.FindByID(s.ctx, first.ID)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int32(10), found.StockQuantity)
+	found, err = s.store.FindByID(s.ctx, second.ID)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int32(4), found.StockQuantity)
+}
+
+func (s *ProductStoreSuite) TestReserveStock_Insufficient() {
+	// Create the products to reserve stock of
+	first := s.createTestProduct("Vivo X90", 69900, 10)
+	second := s.createTestProduct("Realme GT 3", 39900, 1)
+
+	// Attempt to reserve more than the stock of the second product
+	err := s.store.ReserveStock(s.ctx, map[uuid.UUID]int32{first.ID: 3, second.ID: 2})
+	require.ErrorIs(s.T(), err, perrors.ErrInsufficientStock, "Expected ErrInsufficientStock for a negative result")
+
+	// None of the stock is reserved
+	found, err := s.store.FindByID(s.ctx, first.ID)
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int32(10), found.StockQuantity)
+	require.Equal(s.T(), first.Version, found.Version)
+}
+
+func (s *ProductStoreSuite) TestReserveStock_NotFound() {
+	// Attempt to reserve stock of a product that does not exist
+	err := s.store.ReserveStock(s.ctx, map[uuid.UUID]int32{uuid.New(): 1})
+	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "Expected ErrProductNotFound for non-existent product")
+	err = s.store.ReleaseStock(s.ctx, map[uuid.UUID]int32{uuid.New(): 1})
+	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "Expected ErrProductNotFound for non-existent product")
 }
 
 func (s *ProductStoreSuite) TestDeleteByID() {
@@ -339,5 +916,409 @@ func (s *ProductStoreSuite) TestDeleteByID_WrongVersion() {
 	// Attempt to delete the product with an incorrect version
 	wrongVersion := created.Version + 1 // Incrementing the version to simulate a conflict
 	err := s.store.DeleteByID(s.ctx, created.ID, wrongVersion)
-	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "Expected ErrProductNotFound for wrong version")
+	require.ErrorIs(s.T(), err, perrors.ErrOptimisticLock, "Expected ErrOptimisticLock for wrong version")
+}
+
+func (s *ProductStoreSuite) TestSoftDeleteByID() {
+	// given
+	live := s.createTestProduct("Xiaomi 13", 59900, 10)
+	deleted := s.createTestProduct("Xiaomi 13 Pro", 89900, 5)
+
+	// when
+	err := s.store.SoftDeleteByID(s.ctx, deleted.ID, deleted.Version)
+
+	// then
+	require.NoError(s.T(), err, "SoftDeleteByID should not return an error")
+	_, err = s.store.FindByID(s.ctx, deleted.ID)
+	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "a soft-deleted product should not be found by ID")
+	all, err := s.store.FindAll(s.ctx, 0, 10, true)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), all, 1, "a soft-deleted product should not be listed")
+	assert.Equal(s.T(), live.ID, all[0].ID)
+	_, err = s.store.Update(s.ctx, deleted.ID, deleted.Name, nil, deleted.Price, 1, deleted.Version+1)
+	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "a soft-deleted product should not be updated")
+
+	found, err := s.store.FindByIDs(s.ctx, []uuid.UUID{live.ID, deleted.ID})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), found, 2, "the lookup by IDs should return the soft-deleted product")
+	for _, product := range found {
+		assert.Equal(s.T(), product.ID == deleted.ID, product.DeletedAt != nil)
+	}
+}
+
+func (s *ProductStoreSuite) TestSoftDeleteByID_AlreadyDeleted() {
+	// given
+	created := s.createTestProduct("Vivo X90", 69900, 10)
+	require.NoError(s.T(), s.store.SoftDeleteByID(s.ctx, created.ID, created.Version))
+
+	// when
+	err := s.store.SoftDeleteByID(s.ctx, created.ID, created.Version+1)
+
+	// then
+	require.ErrorIs(s.T(), err, perrors.ErrProductNotFound, "Expected ErrProductNotFound for an already deleted product")
+}
+
+// priceHistory returns the recorded prices of a product in the order they were recorded.
+func (s *ProductStoreSuite) priceHistory(id uuid.UUID) []int64 {
+	s.T().Helper()
+	rows, err := s.dbPool.Query(s.ctx, "SELECT price FROM product_price_history WHERE product_id = $1 ORDER BY recorded_at", id)
+	require.NoError(s.T(), err, "Failed to query the price history")
+	defer rows.Close()
+	var prices []int64
+	for rows.Next() {
+		var price int64
+		require.NoError(s.T(), rows.Scan(&price))
+		prices = append(prices, price)
+	}
+	require.NoError(s.T(), rows.Err())
+	return prices
+}
+
+func (s *ProductStoreSuite) TestPriceHistory_RecordedOnPriceChange() {
+	// given
+	created := s.createTestProduct("Nothing Phone 2", 59900, 10)
+
+	// when: the price changes once, then only the name and the stock change
+	updated, err := s.store.Update(s.ctx, created.ID, created.Name, nil, 54900, 10, created.Version)
+	require.NoError(s.T(), err)
+	_, err = s.store.Update(s.ctx, created.ID, "Nothing Phone (2)", nil, 54900, 8, updated.Version)
+	require.NoError(s.T(), err)
+	_, err = s.store.UpdateStock(s.ctx, created.ID, 5, updated.Version+1, nil)
+	require.NoError(s.T(), err)
+
+	// then
+	assert.Equal(s.T(), []int64{59900, 54900}, s.priceHistory(created.ID), "only the initial and the changed price should be recorded")
+}
+
+func (s *ProductStoreSuite) TestPriceHistory_NotRecordedOnFailedUpdate() {
+	// given
+	created := s.createTestProduct("Fairphone 5", 69900, 10)
+
+	// when
+	_, err := s.store.Update(s.ctx, created.ID, created.Name, nil, 64900, 10, created.Version+1)
+
+	// then
+	require.ErrorIs(s.T(), err, perrors.ErrOptimisticLock)
+	assert.Equal(s.T(), []int64{69900}, s.priceHistory(created.ID), "the price of a failed update should not be recorded")
+}
+
+func (s *ProductStoreSuite) TestPriceHistory_Buckets() {
+	// given: a seeded price history, 2025-01-06 and 2025-01-13 are Mondays
+	created := s.createTestProduct("Asus Zenfone 10", 69900, 10)
+	other := s.createTestProduct("Asus ROG Phone 7", 99900, 10)
+	_, err := s.dbPool.Exec(s.ctx, "DELETE FROM product_price_history")
+	require.NoError(s.T(), err, "Failed to clear the price history")
+	seed := []struct {
+		id         uuid.UUID
+		price      int64
+		recordedAt string
+	}{
+		{created.ID, 50, "2025-01-05 23:59:59"}, // before the range, in effect at its start
+		{created.ID, 100, "2025-01-06 10:00:00"},
+		{created.ID, 200, "2025-01-06 15:00:00"},
+		{created.ID, 300, "2025-01-08 09:00:00"},
+		{created.ID, 400, "2025-01-13 09:00:00"},
+		{created.ID, 500, "2025-01-20 00:00:00"}, // the end of the range is exclusive
+		{other.ID, 1, "2025-01-06 12:00:00"},     // another product
+	}
+	for _, h := range seed {
+		_, err := s.dbPool.Exec(s.ctx, "INSERT INTO product_price_history (product_id, price, recorded_at) VALUES ($1, $2, $3)", h.id, h.price, h.recordedAt)
+		require.NoError(s.T(), err, "Failed to seed the price history")
+	}
+	from := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)
+	day := func(d int) *time.Time {
+		t := time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	// the days without price changes carry the price in effect at their start
+	unchanged := func(from, to int, price int64) []db.AggregatePriceHistoryRow {
+		var buckets []db.AggregatePriceHistoryRow
+		for d := from; d <= to; d++ {
+			buckets = append(buckets, db.AggregatePriceHistoryRow{BucketStart: day(d), MinPrice: price, MaxPrice: price, AvgPrice: float64(price)})
+		}
+		return buckets
+	}
+	var daily []db.AggregatePriceHistoryRow
+	daily = append(daily, db.AggregatePriceHistoryRow{BucketStart: day(6), MinPrice: 50, MaxPrice: 200, AvgPrice: 350.0 / 3, Changes: 2})
+	daily = append(daily, unchanged(7, 7, 200)...)
+	daily = append(daily, db.AggregatePriceHistoryRow{BucketStart: day(8), MinPrice: 200, MaxPrice: 300, AvgPrice: 250, Changes: 1})
+	daily = append(daily, unchanged(9, 12, 300)...)
+	daily = append(daily, db.AggregatePriceHistoryRow{BucketStart: day(13), MinPrice: 300, MaxPrice: 400, AvgPrice: 350, Changes: 1})
+	daily = append(daily, unchanged(14, 19, 400)...)
+
+	testCases := []struct {
+		name     string
+		interval string
+		from     time.Time
+		expected []db.AggregatePriceHistoryRow
+	}{
+		{
+			name:     "daily buckets",
+			interval: "day",
+			from:     from,
+			expected: daily,
+		},
+		{
+			name:     "weekly buckets",
+			interval: "week",
+			from:     from,
+			expected: []db.AggregatePriceHistoryRow{
+				{BucketStart: day(6), MinPrice: 50, MaxPrice: 300, AvgPrice: 162.5, Changes: 3},
+				{BucketStart: day(13), MinPrice: 300, MaxPrice: 400, AvgPrice: 350, Changes: 1},
+			},
+		},
+		{
+			name:     "first bucket seeded with the price at the start of the range",
+			interval: "week",
+			from:     time.Date(2025, 1, 8, 12, 0, 0, 0, time.UTC),
+			expected: []db.AggregatePriceHistoryRow{
+				{BucketStart: day(6), MinPrice: 300, MaxPrice: 300, AvgPrice: 300},
+				{BucketStart: day(13), MinPrice: 300, MaxPrice: 400, AvgPrice: 350, Changes: 1},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		s.Run(tc.name, func() {
+			// when
+			buckets, err := s.store.PriceHistory(s.ctx, created.ID, tc.interval, tc.from, to)
+
+			// then
+			require.NoError(s.T(), err)
+			require.Len(s.T(), buckets, len(tc.expected))
+			for i, expected := range tc.expected {
+				assert.True(s.T(), expected.BucketStart.Equal(*buckets[i].BucketStart), "bucket %d should start at %s, got %s", i, expected.BucketStart, buckets[i].BucketStart)
+				assert.Equal(s.T(), expected.MinPrice, buckets[i].MinPrice, "min price of bucket %d", i)
+				assert.Equal(s.T(), expected.MaxPrice, buckets[i].MaxPrice, "max price of bucket %d", i)
+				assert.InDelta(s.T(), expected.AvgPrice, buckets[i].AvgPrice, 0.001, "avg price of bucket %d", i)
+				assert.Equal(s.T(), expected.Changes, buckets[i].Changes, "changes of bucket %d", i)
+			}
+		})
+	}
+}
+
+func (s *ProductStoreSuite) TestPriceHistory_Empty() {
+	// given
+	created := s.createTestProduct("Motorola Edge 40", 49900, 10)
+
+	// when: the range ends before the product was created
+	buckets, err := s.store.PriceHistory(s.ctx, created.ID, "day", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	// then
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), buckets)
+}
+
+func (s *ProductStoreSuite) TestHistory_CreateRecorded() {
+	// when
+	created := s.createTestProduct("Pixel 9", 79900, 10)
+
+	// then: the audit row is written in the transaction of the creation
+	var (
+		action    string
+		oldValues []byte
+		newValues []byte
+		version   int32
+	)
+	err := s.dbPool.QueryRow(s.ctx, "SELECT action, old_values, new_values, version FROM product_audit WHERE product_id = $1", created.ID).
+		Scan(&action, &oldValues, &newValues, &version)
+	require.NoError(s.T(), err, "the creation should be recorded in the audit log")
+	assert.Equal(s.T(), "create", action)
+	assert.Nil(s.T(), oldValues, "a created product has no previous values")
+	var values ProductValues
+	require.NoError(s.T(), json.Unmarshal(newValues, &values))
+	assert.Equal(s.T(), ProductValues{Name: "Pixel 9", Price: 79900, StockQuantity: 10}, values)
+	assert.Equal(s.T(), created.Version, version)
+}
+
+func (s *ProductStoreSuite) TestHistory_UpdateRecorded() {
+	// given
+	created := s.createTestProduct("Pixel 8", 69900, 10)
+
+	// when
+	updated, err := s.store.Update(s.ctx, created.ID, "Pixel 8 Pro", nil, 99900, 5, created.Version)
+	require.NoError(s.T(), err)
+
+	// then
+	changes, err := s.store.History(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), changes, 2, "the creation and exactly one update should be recorded")
+	assert.Equal(s.T(), "create", changes[0].Action)
+	assert.Nil(s.T(), changes[0].Old)
+	assert.Equal(s.T(), &ProductValues{Name: "Pixel 8", Price: 69900, StockQuantity: 10}, changes[0].New)
+
+	change := changes[1]
+	assert.Equal(s.T(), "update", change.Action)
+	assert.Equal(s.T(), updated.Version, change.Version)
+	assert.Equal(s.T(), &ProductValues{Name: "Pixel 8", Price: 69900, StockQuantity: 10}, change.Old, "the values before the update should be recorded")
+	assert.Equal(s.T(), &ProductValues{Name: "Pixel 8 Pro", Price: 99900, StockQuantity: 5}, change.New, "the values after the update should be recorded")
+	assert.False(s.T(), change.ChangedAt.Before(changes[0].ChangedAt), "the changes should be ordered oldest first")
+}
+
+func (s *ProductStoreSuite) TestHistory_NotRecordedOnFailedUpdate() {
+	// given
+	created := s.createTestProduct("Pixel 7", 49900, 10)
+
+	// when
+	_, err := s.store.Update(s.ctx, created.ID, created.Name, nil, 44900, 10, created.Version+1)
+
+	// then
+	require.ErrorIs(s.T(), err, perrors.ErrOptimisticLock)
+	changes, err := s.store.History(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), changes, 1, "a failed update should not be recorded")
+	assert.Equal(s.T(), "create", changes[0].Action)
+}
+
+func (s *ProductStoreSuite) TestHistory_FailedAuditRollsBackUpdate() {
+	// given: recording a change fails
+	created := s.createTestProduct("Pixel 6", 39900, 10)
+	_, err := s.dbPool.Exec(s.ctx, `
+		CREATE FUNCTION fail_audit() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit unavailable';
+		END;
+		$$ LANGUAGE plpgsql;
+		CREATE TRIGGER failing_audit BEFORE INSERT ON product_audit FOR EACH ROW EXECUTE FUNCTION fail_audit();`)
+	require.NoError(s.T(), err, "Failed to create the failing trigger")
+	defer func() {
+		_, err := s.dbPool.Exec(s.ctx, "DROP TRIGGER failing_audit ON product_audit; DROP FUNCTION fail_audit()")
+		require.NoError(s.T(), err, "Failed to drop the failing trigger")
+	}()
+
+	// when
+	_, err = s.store.Update(s.ctx, created.ID, created.Name, nil, 34900, 10, created.Version)
+
+	// then
+	require.Error(s.T(), err)
+	found, err := s.store.FindByID(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), created.Price, found.Price, "the update should be rolled back with the audit")
+	assert.Equal(s.T(), created.Version, found.Version)
+	assert.Equal(s.T(), []int64{39900}, s.priceHistory(created.ID), "the price change should be rolled back with the audit")
+}
+
+func (s *ProductStoreSuite) TestHistory_StockUpdateAndDeleteRecorded() {
+	// given
+	created := s.createTestProduct("Pixel Fold", 179900, 10)
+	restockAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated, err := s.store.UpdateStock(s.ctx, created.ID, 0, created.Version, &restockAt)
+	require.NoError(s.T(), err)
+
+	// when
+	err = s.store.DeleteByID(s.ctx, created.ID, updated.Version)
+	require.NoError(s.T(), err)
+
+	// then: the history outlives the product
+	changes, err := s.store.History(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), changes, 3)
+	assert.Equal(s.T(), "update_stock", changes[1].Action)
+	assert.Equal(s.T(), int32(10), changes[1].Old.StockQuantity)
+	assert.Equal(s.T(), int32(0), changes[1].New.StockQuantity)
+	require.NotNil(s.T(), changes[1].New.RestockAt)
+	assert.True(s.T(), restockAt.Equal(*changes[1].New.RestockAt))
+
+	assert.Equal(s.T(), "delete", changes[2].Action)
+	assert.Equal(s.T(), updated.Version, changes[2].Version, "a deletion should record the deleted version")
+	assert.Equal(s.T(), int32(0), changes[2].Old.StockQuantity)
+	assert.Nil(s.T(), changes[2].New, "a deleted product has no values after the change")
+}
+
+func (s *ProductStoreSuite) TestHistory_SoftDeleteRecorded() {
+	// given
+	created := s.createTestProduct("Pixel Tablet", 49900, 10)
+
+	// when
+	err := s.store.SoftDeleteByID(s.ctx, created.ID, created.Version)
+	require.NoError(s.T(), err)
+
+	// then
+	changes, err := s.store.History(s.ctx, created.ID)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), changes, 2)
+	assert.Equal(s.T(), "delete", changes[1].Action)
+	assert.Equal(s.T(), created.Version+1, changes[1].Version)
+	assert.Nil(s.T(), changes[1].Old.DeletedAt)
+	require.NotNil(s.T(), changes[1].New)
+	assert.NotNil(s.T(), changes[1].New.DeletedAt, "a soft-deleted product should be recorded with its deletion time")
+}
+
+func (s *ProductStoreSuite) TestHistory_UnknownProduct() {
+	// when
+	changes, err := s.store.History(s.ctx, uuid.New())
+
+	// then
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), changes)
+}
+
+func (s *ProductStoreSuite) TestCreate_QueryTimeout() {
+	s.SetupTest()
+	// given: inserting a product takes longer than the query timeout
+	_, err := s.dbPool.Exec(s.ctx, `
+		CREATE FUNCTION slow_insert() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_sleep(1);
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+		CREATE TRIGGER slow_products BEFORE INSERT ON products FOR EACH ROW EXECUTE FUNCTION slow_insert();`)
+	require.NoError(s.T(), err, "Failed to create the slow trigger")
+	defer func() {
+		_, err := s.dbPool.Exec(s.ctx, "DROP TRIGGER slow_products ON products; DROP FUNCTION slow_insert()")
+		require.NoError(s.T(), err, "Failed to drop the slow trigger")
+	}()
+	store := NewPgStore(s.dbPool, 200*time.Millisecond, nil, true)
+
+	// when
+	start := time.Now()
+	product, err := store.Create(s.ctx, "Slow Product", nil, 100, 1, VisibilityPublic, DefaultCurrency)
+
+	// then
+	require.ErrorIs(s.T(), err, perrors.ErrQueryTimeout)
+	require.Less(s.T(), time.Since(start), time.Second, "Create should not wait for the slow query")
+	require.Nil(s.T(), product)
+	var count int
+	err = s.dbPool.QueryRow(s.ctx, "SELECT count(*) FROM products").Scan(&count)
+	require.NoError(s.T(), err, "The pool should be usable after the timeout")
+	require.Zero(s.T(), count, "The product should not be created")
+}
+
+func (s *ProductStoreSuite) TestCreate_SlowQueryLogged() {
+	s.SetupTest()
+	// given: inserting a product takes longer than the slow query threshold
+	_, err := s.dbPool.Exec(s.ctx, `
+		CREATE FUNCTION slow_insert() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_sleep(0.2);
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+		CREATE TRIGGER slow_products BEFORE INSERT ON products FOR EACH ROW EXECUTE FUNCTION slow_insert();`)
+	require.NoError(s.T(), err, "Failed to create the slow trigger")
+	defer func() {
+		_, err := s.dbPool.Exec(s.ctx, "DROP TRIGGER slow_products ON products; DROP FUNCTION slow_insert()")
+		require.NoError(s.T(), err, "Failed to drop the slow trigger")
+	}()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	store := NewPgStore(s.dbPool, 0, telemetry.NewSlowQueryLogger(100*time.Millisecond, logger), true)
+
+	// when
+	_, err = store.Create(s.ctx, "Slow Product", nil, 100, 1, VisibilityPublic, DefaultCurrency)
+	require.NoError(s.T(), err)
+	_, err = store.FindAll(s.ctx, 0, 10, true)
+	require.NoError(s.T(), err)
+
+	// then: only the slow insert is logged
+	var record map[string]any
+	dec := json.NewDecoder(&logs)
+	require.NoError(s.T(), dec.Decode(&record))
+	assert.Equal(s.T(), "WARN", record["level"])
+	assert.Equal(s.T(), "Slow database query", record["msg"])
+	assert.Equal(s.T(), "Create", record["query"])
+	assert.GreaterOrEqual(s.T(), record["duration"], float64(200*time.Millisecond))
+	assert.False(s.T(), dec.More(), "Only the slow query should be logged")
 }
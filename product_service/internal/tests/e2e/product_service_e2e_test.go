@@ -27,10 +27,15 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	pconfig "github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/money"
+	"github.com/abgdnv/gocommerce/pkg/web"
 	"github.com/abgdnv/gocommerce/product_service/internal/app"
+	"github.com/abgdnv/gocommerce/product_service/internal/config"
 	"github.com/abgdnv/gocommerce/product_service/internal/service"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -126,8 +131,8 @@ func (s *ProductServiceE2ESuite) SetupSuite() {
 	s.logger.Info("Migrations applied for E2E tests")
 
 	// 5. Set up the application configuration
-	deps := app.SetupDependencies(s.dbPool, s.logger)
-	appHandler := app.SetupHttpHandler(deps)
+	deps := app.SetupDependencies(s.dbPool, 0, 0, nil, config.ProductsConfig{UniqueNames: true, StockLock: true}, pconfig.AuditConfig{}, s.logger)
+	appHandler := app.SetupHttpHandler(deps, pconfig.TrailingSlashStrip, pconfig.DefaultBasePath)
 
 	s.server = httptest.NewServer(appHandler)
 	s.httpClient = s.server.Client() // Use the httptest server's client for requests
@@ -158,7 +163,7 @@ func (s *ProductServiceE2ESuite) TearDownSuite() {
 
 // SetupTest prepares the database for each test by truncating the products table.
 func (s *ProductServiceE2ESuite) SetupTest() {
-	_, err := s.dbPool.Exec(s.ctx, "TRUNCATE TABLE products RESTART IDENTITY CASCADE")
+	_, err := s.dbPool.Exec(s.ctx, "TRUNCATE TABLE products, product_audit RESTART IDENTITY CASCADE")
 	require.NoError(s.T(), err, "Failed to truncate products table")
 }
 
@@ -178,17 +183,20 @@ func TestProductServiceE2E(t *testing.T) {
 
 // createProductPayload is a struct used to represent the payload for creating a product.
 type createProductPayload struct {
-	Name  string `json:"name"`
-	Price int64  `json:"price"`
-	Stock int32  `json:"stock"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Price       int64  `json:"price"`
+	Stock       int32  `json:"stock"`
 }
 
 // updateProductPayload is a struct used to represent the payload for updating a product.
+// Price is a decimal string, unlike the deprecated integer of minor units of createProductPayload.
 type updateProductPayload struct {
-	Name    string `json:"name"`
-	Price   int64  `json:"price"`
-	Stock   int32  `json:"stock"`
-	Version int32  `json:"version"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Price       string `json:"price"`
+	Stock       int32  `json:"stock"`
+	Version     int32  `json:"version"`
 }
 
 // updateStockPayload is a struct used to represent the payload for updating the stock of a product.
@@ -246,6 +254,14 @@ func (s *ProductServiceE2ESuite) deleteByID(productID string, version int32) int
 	return statusCode
 }
 
+// parseTimestamp is a helper method to parse an RFC 3339 timestamp of a ProductDto, it fails the test if it's missing or malformed.
+func (s *ProductServiceE2ESuite) parseTimestamp(value string) time.Time {
+	s.T().Helper()
+	t, err := time.Parse(time.RFC3339Nano, value)
+	require.NoError(s.T(), err, "Failed to parse timestamp %q", value)
+	return t
+}
+
 // doAndDecodeProduct is a helper method to make an HTTP request to the product service and decode the response into a ProductDto.
 // Returns the ProductDto and the HTTP status code.
 func (s *ProductServiceE2ESuite) doAndDecodeProduct(method, url string, payload any) (service.ProductDto, int) {
@@ -275,6 +291,14 @@ func (s *ProductServiceE2ESuite) doAndDecodeProductList(method, url string, payl
 // doRequest is a helper method to make an HTTP request to the product service
 // Returns the response body as a byte slice and the HTTP status code.
 func (s *ProductServiceE2ESuite) doRequest(method, url string, payload any) ([]byte, int) {
+	s.T().Helper()
+	return s.doRequestWithHeaders(method, url, payload, nil)
+}
+
+// doRequestWithHeaders is a helper method to make an HTTP request with the headers to the product service,
+// e.g. the identity headers set by the API gateway.
+// Returns the response body as a byte slice and the HTTP status code.
+func (s *ProductServiceE2ESuite) doRequestWithHeaders(method, url string, payload any, headers map[string]string) ([]byte, int) {
 	s.T().Helper()
 	var body io.Reader
 	if payload != nil {
@@ -289,6 +313,9 @@ func (s *ProductServiceE2ESuite) doRequest(method, url string, payload any) ([]b
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 
 	resp, err := s.httpClient.Do(req)
 	require.NoError(s.T(), err, "HTTP request failed")
@@ -363,7 +390,7 @@ func (s *ProductServiceE2ESuite) TestFindAll_E2E() {
 		},
 		{
 			name:           "Find All Products - One Product",
-			createPayload:  createProductPayload{"Apple iPhone 15 Pro Max", int64(59900), int32(100)},
+			createPayload:  createProductPayload{Name: "Apple iPhone 15 Pro Max", Price: 59900, Stock: 100},
 			amount:         1,
 			offset:         0,
 			limit:          10,
@@ -372,7 +399,7 @@ func (s *ProductServiceE2ESuite) TestFindAll_E2E() {
 		},
 		{
 			name:           "Find All Products - Multiple Products",
-			createPayload:  createProductPayload{"Samsung Galaxy S23 Ultra", int64(119900), int32(50)},
+			createPayload:  createProductPayload{Name: "Samsung Galaxy S23 Ultra", Price: 119900, Stock: 50},
 			amount:         5,
 			offset:         0,
 			limit:          10,
@@ -381,7 +408,7 @@ func (s *ProductServiceE2ESuite) TestFindAll_E2E() {
 		},
 		{
 			name:           "Find All Products - Limit",
-			createPayload:  createProductPayload{"Google Pixel 8 Pro", int64(89900), int32(75)},
+			createPayload:  createProductPayload{Name: "Google Pixel 8 Pro", Price: 89900, Stock: 75},
 			amount:         5,
 			offset:         0,
 			limit:          3,
@@ -390,7 +417,7 @@ func (s *ProductServiceE2ESuite) TestFindAll_E2E() {
 		},
 		{
 			name:           "Find All Products - Offset",
-			createPayload:  createProductPayload{"Google Pixel 8 Pro", int64(89900), int32(75)},
+			createPayload:  createProductPayload{Name: "Google Pixel 8 Pro", Price: 89900, Stock: 75},
 			amount:         5,
 			offset:         3,
 			limit:          10,
@@ -416,7 +443,10 @@ func (s *ProductServiceE2ESuite) TestFindAll_E2E() {
 			s.SetupTest()
 			// given
 			for i := 0; i < tc.amount; i++ {
-				_, statusCode := s.createProduct(tc.createPayload)
+				payload := tc.createPayload
+				// the names of the live products are unique
+				payload.Name = fmt.Sprintf("%s %d", payload.Name, i)
+				_, statusCode := s.createProduct(payload)
 				require.Equal(t, http.StatusCreated, statusCode, "Expected HTTP 201 Created")
 			}
 
@@ -430,37 +460,104 @@ func (s *ProductServiceE2ESuite) TestFindAll_E2E() {
 	}
 }
 
+// TestInternalProduct_E2E tests that an internal product is only visible to the staff.
+func (s *ProductServiceE2ESuite) TestInternalProduct_E2E() {
+	customer := map[string]string{web.XUserId: uuid.NewString(), web.XUserRoles: "user"}
+	staff := map[string]string{web.XUserId: uuid.NewString(), web.XUserRoles: "user," + web.RoleAdmin}
+	testCases := []struct {
+		name           string
+		headers        map[string]string
+		expectedCode   int
+		expectedListed int
+	}{
+		{
+			name:           "anonymous caller",
+			expectedCode:   http.StatusNotFound,
+			expectedListed: 1,
+		},
+		{
+			name:           "customer",
+			headers:        customer,
+			expectedCode:   http.StatusNotFound,
+			expectedListed: 1,
+		},
+		{
+			name:           "staff member",
+			headers:        staff,
+			expectedCode:   http.StatusOK,
+			expectedListed: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			s.SetupTest()
+			// given
+			_, statusCode := s.createProduct(createProductPayload{Name: "Apple iPhone 15 Pro Max", Price: 59900, Stock: 100})
+			require.Equal(t, http.StatusCreated, statusCode)
+			internal, statusCode := s.doAndDecodeProduct(http.MethodPost, s.server.URL+productURL,
+				map[string]any{"name": "Apple iPhone 16 Prototype", "price": 99900, "stock": 1, "visibility": "internal"})
+			require.Equal(t, http.StatusCreated, statusCode)
+			require.Equal(t, "internal", internal.Visibility)
+
+			// when
+			body, findStatusCode := s.doRequestWithHeaders(http.MethodGet, s.server.URL+productURL+"/"+internal.ID, nil, tc.headers)
+			listBody, listStatusCode := s.doRequestWithHeaders(http.MethodGet, s.server.URL+productURL+"?offset=0&limit=10", nil, tc.headers)
+
+			// then
+			require.Equal(t, tc.expectedCode, findStatusCode, "Expected HTTP %d", tc.expectedCode)
+			if tc.expectedCode == http.StatusOK {
+				require.Equal(t, internal.ID, s.decodeProductResponse(body).ID)
+			}
+			require.Equal(t, http.StatusOK, listStatusCode)
+			require.Len(t, s.decodeProductListResponse(listBody), tc.expectedListed)
+		})
+	}
+}
+
 // TestCreateProduct_E2E tests the creation of products with various payloads.
 func (s *ProductServiceE2ESuite) TestCreateProduct_E2E() {
 	testCases := []struct {
-		name            string
-		payload         createProductPayload
-		expectedCode    int
-		expectedProduct service.ProductDto
+		name           string
+		payload        createProductPayload
+		expectedCode   int
+		expectedListed service.ProductDto
 	}{
 		{
-			name:            "Create Product - Empty Name",
-			payload:         createProductPayload{Name: "", Price: 100, Stock: 10},
-			expectedCode:    http.StatusBadRequest,
-			expectedProduct: service.ProductDto{},
+			name:           "Create Product - Empty Name",
+			payload:        createProductPayload{Name: "", Price: 100, Stock: 10},
+			expectedCode:   http.StatusBadRequest,
+			expectedListed: service.ProductDto{},
 		},
 		{
-			name:            "Create Product - Negative Price",
-			payload:         createProductPayload{Name: "Test Product", Price: -50, Stock: 10},
-			expectedCode:    http.StatusBadRequest,
-			expectedProduct: service.ProductDto{},
+			name:           "Create Product - Negative Price",
+			payload:        createProductPayload{Name: "Test Product", Price: -50, Stock: 10},
+			expectedCode:   http.StatusBadRequest,
+			expectedListed: service.ProductDto{},
 		},
 		{
-			name:            "Create Product - Negative Stock",
-			payload:         createProductPayload{Name: "Test Product", Price: 100, Stock: -1},
-			expectedCode:    http.StatusBadRequest,
-			expectedProduct: service.ProductDto{},
+			name:           "Create Product - Negative Stock",
+			payload:        createProductPayload{Name: "Test Product", Price: 100, Stock: -1},
+			expectedCode:   http.StatusBadRequest,
+			expectedListed: service.ProductDto{},
 		},
 		{
-			name:            "Create Product - Valid Product",
-			payload:         createProductPayload{Name: "Valid Product", Price: 100, Stock: 10},
-			expectedCode:    http.StatusCreated,
-			expectedProduct: service.ProductDto{Name: "Valid Product", Price: 100, Stock: 10, Version: 1},
+			name:           "Create Product - Valid Product",
+			payload:        createProductPayload{Name: "Valid Product", Price: 100, Stock: 10},
+			expectedCode:   http.StatusCreated,
+			expectedListed: service.ProductDto{Name: "Valid Product", Price: money.New(100, ""), Stock: 10, Version: 1},
+		},
+		{
+			name:           "Create Product - With Description",
+			payload:        createProductPayload{Name: "Described Product", Description: "A product with a description", Price: 100, Stock: 10},
+			expectedCode:   http.StatusCreated,
+			expectedListed: service.ProductDto{Name: "Described Product", Description: "A product with a description", Price: money.New(100, ""), Stock: 10, Version: 1},
+		},
+		{
+			name:           "Create Product - Description Too Long",
+			payload:        createProductPayload{Name: "Test Product", Description: strings.Repeat("a", 1001), Price: 100, Stock: 10},
+			expectedCode:   http.StatusBadRequest,
+			expectedListed: service.ProductDto{},
 		},
 	}
 
@@ -474,10 +571,13 @@ func (s *ProductServiceE2ESuite) TestCreateProduct_E2E() {
 			require.Equal(t, tc.expectedCode, statusCode)
 			if tc.expectedCode == http.StatusCreated {
 				require.NotZero(t, product.ID)
-				require.Equal(t, tc.expectedProduct.Name, product.Name)
-				require.Equal(t, tc.expectedProduct.Price, product.Price)
-				require.Equal(t, tc.expectedProduct.Stock, product.Stock)
-				require.Equal(t, tc.expectedProduct.Version, product.Version)
+				require.Equal(t, tc.expectedListed.Name, product.Name)
+				require.Equal(t, tc.expectedListed.Description, product.Description)
+				require.Equal(t, tc.expectedListed.Price, product.Price)
+				require.Equal(t, tc.expectedListed.Stock, product.Stock)
+				require.Equal(t, tc.expectedListed.Version, product.Version)
+				createdAt := s.parseTimestamp(product.CreatedAt)
+				require.Equal(t, createdAt, s.parseTimestamp(product.UpdatedAt), "a new product is last updated when created")
 
 				// Verify that the product can be fetched by ID
 				fetchedProduct, statusCode := s.FindByID(product.ID)
@@ -485,37 +585,112 @@ func (s *ProductServiceE2ESuite) TestCreateProduct_E2E() {
 				require.Equal(t, http.StatusOK, statusCode)
 				require.Equal(t, product.ID, fetchedProduct.ID)
 				require.Equal(t, product.Name, fetchedProduct.Name)
+				require.Equal(t, product.Description, fetchedProduct.Description)
 				require.Equal(t, product.Price, fetchedProduct.Price)
 				require.Equal(t, product.Stock, fetchedProduct.Stock)
 				require.Equal(t, product.Version, fetchedProduct.Version)
+				require.Equal(t, product.CreatedAt, fetchedProduct.CreatedAt)
+				require.Equal(t, product.UpdatedAt, fetchedProduct.UpdatedAt)
 
 			}
 		})
 	}
 }
 
+func (s *ProductServiceE2ESuite) TestCreateProduct_DuplicateName_E2E() {
+	// given
+	_, statusCode := s.createProduct(createProductPayload{Name: "Unique Product", Price: 100, Stock: 10})
+	require.Equal(s.T(), http.StatusCreated, statusCode)
+
+	// when
+	body, statusCode := s.doRequest(http.MethodPost, s.server.URL+productURL,
+		createProductPayload{Name: "Unique Product", Price: 200, Stock: 5})
+
+	// then
+	require.Equal(s.T(), http.StatusConflict, statusCode)
+	require.JSONEq(s.T(), `{"error":"A product with the same name already exists","code":"PRODUCT_ALREADY_EXISTS"}`, string(body))
+	products, _ := s.FindAllProducts(0, 10)
+	require.Len(s.T(), products, 1, "the duplicate product should not be created")
+}
+
+func (s *ProductServiceE2ESuite) TestCreateBatch_DryRun_E2E() {
+	// given
+	_, statusCode := s.createProduct(createProductPayload{Name: "Unique Product", Price: 100, Stock: 10})
+	require.Equal(s.T(), http.StatusCreated, statusCode)
+	rowsBefore := s.countRows()
+
+	// when
+	body, statusCode := s.doRequest(http.MethodPost, s.server.URL+productURL+"/batch?dry_run=true", []createProductPayload{
+		{Name: "New Product", Price: 200, Stock: 5},
+		{Name: "unique product", Price: 300, Stock: 1},
+		{Name: "NEW PRODUCT", Price: 400, Stock: 2},
+		{Name: "", Price: 500, Stock: 3},
+	})
+
+	// then
+	require.Equal(s.T(), http.StatusMultiStatus, statusCode)
+	require.JSONEq(s.T(), `{"created":1,"failed":3,"dry_run":true,"items":[
+		{"index":0,"status":201},
+		{"index":1,"status":409,"validation_errors":{"Name":"already exists"}},
+		{"index":2,"status":409,"validation_errors":{"Name":"already exists"}},
+		{"index":3,"status":400,"validation_errors":{"Name":"failed on rule: required"}}]}`, string(body))
+	require.Equal(s.T(), rowsBefore, s.countRows(), "a dry run should write no products, audit or price history")
+}
+
+// countRows is a helper method to count the rows of the products, their audit log and their price history.
+func (s *ProductServiceE2ESuite) countRows() [3]int {
+	s.T().Helper()
+	var counts [3]int
+	for i, table := range []string{"products", "product_audit", "product_price_history"} {
+		err := s.dbPool.QueryRow(s.ctx, "SELECT COUNT(*) FROM "+table).Scan(&counts[i])
+		require.NoError(s.T(), err, "Failed to count rows of %s", table)
+	}
+	return counts
+}
+
 func (s *ProductServiceE2ESuite) TestUpdateProduct_E2E() {
 
 	testCases := []struct {
-		name            string
-		createPayload   createProductPayload
-		updatePayload   updateProductPayload
-		expectedCode    int
-		expectedProduct service.ProductDto
+		name           string
+		createPayload  createProductPayload
+		updatePayload  updateProductPayload
+		expectedCode   int
+		expectedListed service.ProductDto
 	}{
 		{
-			name:            "Update Product - Valid Product",
-			createPayload:   createProductPayload{"Valid Product", int64(59900), int32(100)},
-			updatePayload:   updateProductPayload{"Valid Product Updated", int64(64900), int32(120), 1},
-			expectedCode:    http.StatusOK,
-			expectedProduct: service.ProductDto{Name: "Valid Product Updated", Price: 64900, Stock: 120, Version: 2},
+			name:           "Update Product - Valid Product",
+			createPayload:  createProductPayload{Name: "Valid Product", Price: 59900, Stock: 100},
+			updatePayload:  updateProductPayload{Name: "Valid Product Updated", Price: "649.00", Stock: 120, Version: 1},
+			expectedCode:   http.StatusOK,
+			expectedListed: service.ProductDto{Name: "Valid Product Updated", Price: money.New(64900, ""), Stock: 120, Version: 2},
 		},
 		{
-			name:            "Update Product - Product with wrong version",
-			createPayload:   createProductPayload{"Samsung Galaxy S23 Ultra", int64(119900), int32(50)},
-			updatePayload:   updateProductPayload{"Samsung Galaxy S23 Ultra Updated", int64(129900), int32(60), 2},
-			expectedCode:    http.StatusNotFound,
-			expectedProduct: service.ProductDto{},
+			name:           "Update Product - Description Added",
+			createPayload:  createProductPayload{Name: "Valid Product", Price: 59900, Stock: 100},
+			updatePayload:  updateProductPayload{Name: "Valid Product", Description: "Now with a description", Price: "599.00", Stock: 100, Version: 1},
+			expectedCode:   http.StatusOK,
+			expectedListed: service.ProductDto{Name: "Valid Product", Description: "Now with a description", Price: money.New(59900, ""), Stock: 100, Version: 2},
+		},
+		{
+			name:           "Update Product - Description Changed",
+			createPayload:  createProductPayload{Name: "Valid Product", Description: "The first description", Price: 59900, Stock: 100},
+			updatePayload:  updateProductPayload{Name: "Valid Product", Description: "The second description", Price: "599.00", Stock: 100, Version: 1},
+			expectedCode:   http.StatusOK,
+			expectedListed: service.ProductDto{Name: "Valid Product", Description: "The second description", Price: money.New(59900, ""), Stock: 100, Version: 2},
+		},
+		{
+			name:           "Update Product - Description Cleared",
+			createPayload:  createProductPayload{Name: "Valid Product", Description: "A description to clear", Price: 59900, Stock: 100},
+			updatePayload:  updateProductPayload{Name: "Valid Product", Price: "599.00", Stock: 100, Version: 1},
+			expectedCode:   http.StatusOK,
+			expectedListed: service.ProductDto{Name: "Valid Product", Price: money.New(59900, ""), Stock: 100, Version: 2},
+		},
+		{
+			name:           "Update Product - Product with wrong version",
+			createPayload:  createProductPayload{Name: "Samsung Galaxy S23 Ultra", Price: 119900, Stock: 50},
+			updatePayload:  updateProductPayload{Name: "Samsung Galaxy S23 Ultra Updated", Price: "1299.00", Stock: 60, Version: 2},
+			expectedCode:   http.StatusConflict,
+			expectedListed: service.ProductDto{},
 		},
 	}
 
@@ -533,10 +708,14 @@ func (s *ProductServiceE2ESuite) TestUpdateProduct_E2E() {
 			require.Equal(t, tc.expectedCode, statusCode)
 			if tc.expectedCode == http.StatusOK {
 				require.Equal(t, createdProduct.ID, updatedProduct.ID)
-				require.Equal(t, tc.expectedProduct.Name, updatedProduct.Name)
-				require.Equal(t, tc.expectedProduct.Price, updatedProduct.Price)
-				require.Equal(t, tc.expectedProduct.Stock, updatedProduct.Stock)
-				require.Equal(t, tc.expectedProduct.Version, updatedProduct.Version)
+				require.Equal(t, tc.expectedListed.Name, updatedProduct.Name)
+				require.Equal(t, tc.expectedListed.Description, updatedProduct.Description)
+				require.Equal(t, tc.expectedListed.Price, updatedProduct.Price)
+				require.Equal(t, tc.expectedListed.Stock, updatedProduct.Stock)
+				require.Equal(t, tc.expectedListed.Version, updatedProduct.Version)
+				require.Equal(t, createdProduct.CreatedAt, updatedProduct.CreatedAt, "the creation time shouldn't change")
+				updatedAt := s.parseTimestamp(updatedProduct.UpdatedAt)
+				require.True(t, updatedAt.After(s.parseTimestamp(createdProduct.UpdatedAt)), "the update time should advance")
 			}
 		})
 	}
@@ -553,15 +732,15 @@ func (s *ProductServiceE2ESuite) TestUpdateStock_E2E() {
 	}{
 		{
 			name:          "Update Stock - with valid version",
-			createPayload: createProductPayload{"Apple iPhone 15 Pro Max", int64(59900), int32(100)},
+			createPayload: createProductPayload{Name: "Apple iPhone 15 Pro Max", Price: 59900, Stock: 100},
 			updatePayload: updateStockPayload{int32(150), int32(1)},
 			expectedCode:  http.StatusOK,
 		},
 		{
 			name:          "Update Stock - with wrong version",
-			createPayload: createProductPayload{"Samsung Galaxy S23 Ultra", int64(119900), int32(50)},
+			createPayload: createProductPayload{Name: "Samsung Galaxy S23 Ultra", Price: 119900, Stock: 50},
 			updatePayload: updateStockPayload{int32(60), int32(2)},
-			expectedCode:  http.StatusNotFound,
+			expectedCode:  http.StatusConflict,
 		},
 	}
 
@@ -581,6 +760,8 @@ func (s *ProductServiceE2ESuite) TestUpdateStock_E2E() {
 				require.Equal(t, createdProduct.ID, updatedProduct.ID)
 				require.Equal(t, tc.updatePayload.Stock, updatedProduct.Stock)
 				require.Equal(t, tc.updatePayload.Version+1, updatedProduct.Version)
+				updatedAt := s.parseTimestamp(updatedProduct.UpdatedAt)
+				require.True(t, updatedAt.After(s.parseTimestamp(createdProduct.UpdatedAt)), "the update time should advance")
 			}
 		})
 	}
@@ -596,15 +777,15 @@ func (s *ProductServiceE2ESuite) TestDeleteProduct_E2E() {
 	}{
 		{
 			name:         "Delete Product - with valid version",
-			payload:      createProductPayload{"Apple iPhone 15 Pro Max", int64(59900), int32(100)},
+			payload:      createProductPayload{Name: "Apple iPhone 15 Pro Max", Price: 59900, Stock: 100},
 			version:      int32(1),
 			expectedCode: http.StatusNoContent,
 		},
 		{
 			name:         "Delete Product - with wrong version",
-			payload:      createProductPayload{"Samsung Galaxy S23 Ultra", int64(119900), int32(50)},
+			payload:      createProductPayload{Name: "Samsung Galaxy S23 Ultra", Price: 119900, Stock: 50},
 			version:      int32(2),
-			expectedCode: http.StatusNotFound,
+			expectedCode: http.StatusConflict,
 		},
 	}
 	for _, tc := range testCases {
@@ -622,3 +803,31 @@ func (s *ProductServiceE2ESuite) TestDeleteProduct_E2E() {
 		})
 	}
 }
+
+func (s *ProductServiceE2ESuite) TestProbes_E2E() {
+	testCases := []struct {
+		name         string
+		path         string
+		expectedCode int
+	}{
+		{
+			name:         "Liveness probe",
+			path:         "/livez",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Readiness probe - database is up",
+			path:         "/readyz",
+			expectedCode: http.StatusOK,
+		},
+	}
+	for _, tc := range testCases {
+		s.T().Run(tc.name, func(t *testing.T) {
+			// when
+			_, statusCode := s.doRequest(http.MethodGet, s.server.URL+tc.path, nil)
+
+			// then
+			require.Equal(t, tc.expectedCode, statusCode)
+		})
+	}
+}
@@ -3,9 +3,13 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"math"
+	"time"
 
 	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/product/v1"
+	producterrors "github.com/abgdnv/gocommerce/product_service/internal/errors"
 	"github.com/abgdnv/gocommerce/product_service/internal/service"
 	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
@@ -15,6 +19,8 @@ import (
 // ProductService defines the interface for the product service.
 type ProductService interface {
 	FindByIDs(ctx context.Context, ids []uuid.UUID) ([]service.ProductDto, error)
+	ReserveStock(ctx context.Context, quantities map[uuid.UUID]int32) error
+	ReleaseStock(ctx context.Context, quantities map[uuid.UUID]int32) error
 }
 
 type Server struct {
@@ -27,6 +33,8 @@ func NewServer(service ProductService) *Server {
 	return &Server{service: service}
 }
 
+// GetProduct returns the requested products, the soft-deleted ones are flagged with is_deleted rather than omitted,
+// so the caller can report every deleted product. Returns NotFound if any of the products doesn't exist at all.
 func (s *Server) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.GetProductResponse, error) {
 	slog.InfoContext(ctx, "received grpc request GetProduct", slog.Any("product_ids", req.Products))
 	ids := make([]uuid.UUID, 0, len(req.Products))
@@ -41,6 +49,9 @@ func (s *Server) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb
 	found, err := s.service.FindByIDs(ctx, ids)
 	if err != nil {
 		slog.ErrorContext(ctx, "service.FindByIDs failed", slog.Any("error", err))
+		if errors.Is(err, producterrors.ErrQueryTimeout) {
+			return nil, status.Errorf(codes.DeadlineExceeded, "database query timed out")
+		}
 		return nil, status.Errorf(codes.Internal, "internal server error")
 	}
 	if len(found) < len(ids) {
@@ -49,16 +60,123 @@ func (s *Server) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb
 
 	products := make([]*pb.Product, 0, len(req.Products))
 	for _, product := range found {
-		products = append(products, &pb.Product{
-			Id:            product.ID,
-			Name:          product.Name,
-			Price:         product.Price,
-			StockQuantity: product.Stock,
-			Version:       product.Version,
-		})
+		products = append(products, toProto(product))
 	}
 	slog.InfoContext(ctx, "send grpc response for GetProduct")
 	return &pb.GetProductResponse{
 		Products: products,
 	}, nil
 }
+
+// GetProductById returns a single product, a soft-deleted one is flagged with is_deleted like in GetProduct.
+// Returns NotFound if the product doesn't exist at all.
+func (s *Server) GetProductById(ctx context.Context, req *pb.GetProductByIdRequest) (*pb.GetProductByIdResponse, error) {
+	slog.InfoContext(ctx, "received grpc request GetProductById", slog.String("product_id", req.Id))
+	id, err := uuid.Parse(req.Id)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid product ID: %v", err)
+	}
+
+	found, err := s.service.FindByIDs(ctx, []uuid.UUID{id})
+	if err != nil {
+		slog.ErrorContext(ctx, "service.FindByIDs failed", slog.Any("error", err))
+		if errors.Is(err, producterrors.ErrQueryTimeout) {
+			return nil, status.Errorf(codes.DeadlineExceeded, "database query timed out")
+		}
+		return nil, status.Errorf(codes.Internal, "internal server error")
+	}
+	if len(found) == 0 {
+		return nil, status.Errorf(codes.NotFound, "product with ID %s is not found", id)
+	}
+
+	slog.InfoContext(ctx, "send grpc response for GetProductById")
+	return &pb.GetProductByIdResponse{
+		Product: toProto(found[0]),
+	}, nil
+}
+
+// ReserveStock takes the quantities of the items from the stock of the products, all or none.
+// Returns InvalidArgument if any of the items is invalid, NotFound if any of the products doesn't exist,
+// or FailedPrecondition if the stock of any of them is insufficient.
+func (s *Server) ReserveStock(ctx context.Context, req *pb.ReserveStockRequest) (*pb.ReserveStockResponse, error) {
+	slog.InfoContext(ctx, "received grpc request ReserveStock", slog.Int("items", len(req.Items)))
+	quantities, err := toQuantities(req.Items)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.service.ReserveStock(ctx, quantities); err != nil {
+		slog.ErrorContext(ctx, "service.ReserveStock failed", slog.Any("error", err))
+		return nil, stockError(err)
+	}
+	slog.InfoContext(ctx, "send grpc response for ReserveStock")
+	return &pb.ReserveStockResponse{}, nil
+}
+
+// ReleaseStock returns the quantities of the items taken by ReserveStock to the stock of the products, all or none.
+// Returns InvalidArgument if any of the items is invalid, or NotFound if any of the products doesn't exist.
+func (s *Server) ReleaseStock(ctx context.Context, req *pb.ReleaseStockRequest) (*pb.ReleaseStockResponse, error) {
+	slog.InfoContext(ctx, "received grpc request ReleaseStock", slog.Int("items", len(req.Items)))
+	quantities, err := toQuantities(req.Items)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.service.ReleaseStock(ctx, quantities); err != nil {
+		slog.ErrorContext(ctx, "service.ReleaseStock failed", slog.Any("error", err))
+		return nil, stockError(err)
+	}
+	slog.InfoContext(ctx, "send grpc response for ReleaseStock")
+	return &pb.ReleaseStockResponse{}, nil
+}
+
+// toQuantities sums up the quantities of the items per product.
+// Returns InvalidArgument if there are no items, or an item has an invalid product ID or a non-positive quantity.
+func toQuantities(items []*pb.StockItem) (map[uuid.UUID]int32, error) {
+	if len(items) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "no stock items")
+	}
+	quantities := make(map[uuid.UUID]int32, len(items))
+	for _, item := range items {
+		id, err := uuid.Parse(item.ProductId)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid product ID: %v", err)
+		}
+		if item.Quantity <= 0 || quantities[id] > math.MaxInt32-item.Quantity {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid quantity %d of product %s", item.Quantity, id)
+		}
+		quantities[id] += item.Quantity
+	}
+	return quantities, nil
+}
+
+// stockError maps an error of a stock update to its gRPC status.
+func stockError(err error) error {
+	switch {
+	case errors.Is(err, producterrors.ErrProductNotFound):
+		return status.Errorf(codes.NotFound, "at least one of the products is not found")
+	case errors.Is(err, producterrors.ErrInsufficientStock):
+		return status.Errorf(codes.FailedPrecondition, "insufficient stock of at least one of the products")
+	case errors.Is(err, producterrors.ErrStockOverflow):
+		return status.Errorf(codes.OutOfRange, "stock quantity of at least one of the products would exceed the maximum")
+	case errors.Is(err, producterrors.ErrQueryTimeout):
+		return status.Errorf(codes.DeadlineExceeded, "database query timed out")
+	}
+	return status.Errorf(codes.Internal, "internal server error")
+}
+
+// toProto converts the product to its gRPC representation.
+func toProto(product service.ProductDto) *pb.Product {
+	var restockAt string
+	if product.RestockAt != nil {
+		restockAt = product.RestockAt.UTC().Format(time.RFC3339)
+	}
+	return &pb.Product{
+		Id:            product.ID,
+		Name:          product.Name,
+		Price:         product.Price.Amount,
+		StockQuantity: product.Stock,
+		Version:       product.Version,
+		RestockAt:     restockAt,
+		IsDeleted:     product.Deleted,
+		Currency:      product.Currency,
+	}
+}
@@ -3,15 +3,23 @@ package grpc
 import (
 	"context"
 	"errors"
+	"math"
+	"net"
 	"testing"
+	"time"
 
 	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/product/v1"
+	"github.com/abgdnv/gocommerce/pkg/money"
+	producterrors "github.com/abgdnv/gocommerce/product_service/internal/errors"
 	"github.com/abgdnv/gocommerce/product_service/internal/service"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 type MockProductService struct {
@@ -29,21 +37,39 @@ func (m *MockProductService) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]
 	return product, args.Error(1)
 }
 
+func (m *MockProductService) ReserveStock(ctx context.Context, quantities map[uuid.UUID]int32) error {
+	args := m.Called(ctx, quantities)
+	return args.Error(0)
+}
+
+func (m *MockProductService) ReleaseStock(ctx context.Context, quantities map[uuid.UUID]int32) error {
+	args := m.Called(ctx, quantities)
+	return args.Error(0)
+}
+
 func TestProductService_GetProduct(t *testing.T) {
 	ctx := context.Background()
 	productID := uuid.New()
+	restockAt := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
 
 	testCases := []struct {
-		name         string
-		mockProducts []service.ProductDto
-		mockError    error
-		expectedCode codes.Code
+		name              string
+		mockProducts      []service.ProductDto
+		mockError         error
+		expectedCode      codes.Code
+		expectedRestockAt string
 	}{
 		{
 			name:         "success",
-			mockProducts: []service.ProductDto{{ID: productID.String(), Name: "Test Product", Price: 10.0, Stock: 5}},
+			mockProducts: []service.ProductDto{{ID: productID.String(), Name: "Test Product", Price: money.New(10, ""), Stock: 5}},
 			expectedCode: codes.OK,
 		},
+		{
+			name:              "success - with restock time",
+			mockProducts:      []service.ProductDto{{ID: productID.String(), Name: "Test Product", Price: money.New(10, ""), Stock: 0, RestockAt: &restockAt}},
+			expectedCode:      codes.OK,
+			expectedRestockAt: "2025-07-01T12:00:00Z",
+		},
 		{
 			name:         "not found",
 			mockProducts: []service.ProductDto{},
@@ -77,8 +103,9 @@ func TestProductService_GetProduct(t *testing.T) {
 				if len(tc.mockProducts) > 0 {
 					require.Equal(t, tc.mockProducts[0].ID, res.Products[0].Id)
 					require.Equal(t, tc.mockProducts[0].Name, res.Products[0].Name)
-					require.Equal(t, tc.mockProducts[0].Price, res.Products[0].Price)
+					require.Equal(t, tc.mockProducts[0].Price.Amount, res.Products[0].Price)
 					require.Equal(t, tc.mockProducts[0].Stock, res.Products[0].StockQuantity)
+					require.Equal(t, tc.expectedRestockAt, res.Products[0].RestockAt)
 				}
 			} else {
 				require.Nil(t, res)
@@ -91,6 +118,30 @@ func TestProductService_GetProduct(t *testing.T) {
 		})
 	}
 
+	t.Run("mixed live and deleted products", func(t *testing.T) {
+		// given
+		liveID, deletedID := uuid.New(), uuid.New()
+		mockSvc := new(MockProductService)
+		server := NewServer(mockSvc)
+		mockSvc.On("FindByIDs", mock.Anything, []uuid.UUID{liveID, deletedID}).Return([]service.ProductDto{
+			{ID: liveID.String(), Name: "Live Product", Price: money.New(100, ""), Stock: 5, Version: 1},
+			{ID: deletedID.String(), Name: "Deleted Product", Price: money.New(200, ""), Stock: 3, Version: 2, Deleted: true},
+		}, nil)
+
+		// when
+		res, err := server.GetProduct(ctx, &pb.GetProductRequest{Products: []string{liveID.String(), deletedID.String()}})
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, res.Products, 2)
+		deleted := make(map[string]bool, len(res.Products))
+		for _, product := range res.Products {
+			deleted[product.Id] = product.IsDeleted
+		}
+		require.Equal(t, map[string]bool{liveID.String(): false, deletedID.String(): true}, deleted)
+		mockSvc.AssertExpectations(t)
+	})
+
 	t.Run("invalid id format", func(t *testing.T) {
 		// given
 		mockSvc := new(MockProductService)
@@ -110,3 +161,226 @@ func TestProductService_GetProduct(t *testing.T) {
 	})
 
 }
+
+// newBufconnClient serves the product service over an in-memory connection and returns a client of it.
+func newBufconnClient(t *testing.T, svc ProductService) pb.ProductServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	pb.RegisterProductServiceServer(grpcServer, NewServer(svc))
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewProductServiceClient(conn)
+}
+
+func TestProductService_GetProductById(t *testing.T) {
+	ctx := context.Background()
+	productID := uuid.New()
+
+	testCases := []struct {
+		name         string
+		id           string
+		mockProducts []service.ProductDto
+		mockError    error
+		expectedCode codes.Code
+	}{
+		{
+			name:         "success",
+			id:           productID.String(),
+			mockProducts: []service.ProductDto{{ID: productID.String(), Name: "Test Product", Price: money.New(100, ""), Stock: 5, Version: 1}},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "deleted product is flagged",
+			id:           productID.String(),
+			mockProducts: []service.ProductDto{{ID: productID.String(), Name: "Test Product", Price: money.New(100, ""), Stock: 5, Version: 2, Deleted: true}},
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "unknown ID",
+			id:           productID.String(),
+			mockProducts: []service.ProductDto{},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "internal error",
+			id:           productID.String(),
+			mockError:    errors.New("internal error"),
+			expectedCode: codes.Internal,
+		},
+		{
+			name:         "invalid id format",
+			id:           "this-is-not-a-uuid",
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockSvc := new(MockProductService)
+			if tc.expectedCode != codes.InvalidArgument {
+				mockSvc.On("FindByIDs", mock.Anything, []uuid.UUID{productID}).Return(tc.mockProducts, tc.mockError)
+			}
+			client := newBufconnClient(t, mockSvc)
+
+			// when
+			res, err := client.GetProductById(ctx, &pb.GetProductByIdRequest{Id: tc.id})
+
+			// then
+			if tc.expectedCode == codes.OK {
+				require.NoError(t, err)
+				require.Equal(t, tc.mockProducts[0].ID, res.Product.Id)
+				require.Equal(t, tc.mockProducts[0].Name, res.Product.Name)
+				require.Equal(t, tc.mockProducts[0].Price.Amount, res.Product.Price)
+				require.Equal(t, tc.mockProducts[0].Stock, res.Product.StockQuantity)
+				require.Equal(t, tc.mockProducts[0].Deleted, res.Product.IsDeleted)
+			} else {
+				require.Error(t, err)
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, tc.expectedCode, st.Code())
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProductService_ReserveStock(t *testing.T) {
+	ctx := context.Background()
+	productID, otherID := uuid.New(), uuid.New()
+
+	testCases := []struct {
+		name               string
+		items              []*pb.StockItem
+		mockError          error
+		expectedQuantities map[uuid.UUID]int32
+		expectedCode       codes.Code
+	}{
+		{
+			name:               "success",
+			items:              []*pb.StockItem{{ProductId: productID.String(), Quantity: 2}, {ProductId: otherID.String(), Quantity: 1}},
+			expectedQuantities: map[uuid.UUID]int32{productID: 2, otherID: 1},
+			expectedCode:       codes.OK,
+		},
+		{
+			name:               "success - quantities of the same product are summed up",
+			items:              []*pb.StockItem{{ProductId: productID.String(), Quantity: 2}, {ProductId: productID.String(), Quantity: 3}},
+			expectedQuantities: map[uuid.UUID]int32{productID: 5},
+			expectedCode:       codes.OK,
+		},
+		{
+			name:               "insufficient stock",
+			items:              []*pb.StockItem{{ProductId: productID.String(), Quantity: 2}},
+			mockError:          producterrors.ErrInsufficientStock,
+			expectedQuantities: map[uuid.UUID]int32{productID: 2},
+			expectedCode:       codes.FailedPrecondition,
+		},
+		{
+			name:               "stock overflow",
+			items:              []*pb.StockItem{{ProductId: productID.String(), Quantity: 2}},
+			mockError:          producterrors.ErrStockOverflow,
+			expectedQuantities: map[uuid.UUID]int32{productID: 2},
+			expectedCode:       codes.OutOfRange,
+		},
+		{
+			name:               "not found",
+			items:              []*pb.StockItem{{ProductId: productID.String(), Quantity: 2}},
+			mockError:          producterrors.ErrProductNotFound,
+			expectedQuantities: map[uuid.UUID]int32{productID: 2},
+			expectedCode:       codes.NotFound,
+		},
+		{
+			name:               "internal error",
+			items:              []*pb.StockItem{{ProductId: productID.String(), Quantity: 2}},
+			mockError:          errors.New("internal error"),
+			expectedQuantities: map[uuid.UUID]int32{productID: 2},
+			expectedCode:       codes.Internal,
+		},
+		{name: "no items", expectedCode: codes.InvalidArgument},
+		{name: "invalid id format", items: []*pb.StockItem{{ProductId: "this-is-not-a-uuid", Quantity: 2}}, expectedCode: codes.InvalidArgument},
+		{name: "zero quantity", items: []*pb.StockItem{{ProductId: productID.String()}}, expectedCode: codes.InvalidArgument},
+		{
+			name:         "quantity overflow",
+			items:        []*pb.StockItem{{ProductId: productID.String(), Quantity: math.MaxInt32}, {ProductId: productID.String(), Quantity: 1}},
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockSvc := new(MockProductService)
+			server := NewServer(mockSvc)
+			if tc.expectedQuantities != nil {
+				mockSvc.On("ReserveStock", mock.Anything, tc.expectedQuantities).Return(tc.mockError)
+			}
+
+			// when
+			res, err := server.ReserveStock(ctx, &pb.ReserveStockRequest{Items: tc.items})
+
+			// then
+			if tc.expectedCode == codes.OK {
+				require.NoError(t, err)
+				require.NotNil(t, res)
+			} else {
+				require.Nil(t, res)
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, tc.expectedCode, st.Code())
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProductService_ReleaseStock(t *testing.T) {
+	ctx := context.Background()
+	productID := uuid.New()
+
+	testCases := []struct {
+		name         string
+		mockError    error
+		expectedCode codes.Code
+	}{
+		{name: "success", expectedCode: codes.OK},
+		{name: "not found", mockError: producterrors.ErrProductNotFound, expectedCode: codes.NotFound},
+		{name: "internal error", mockError: errors.New("internal error"), expectedCode: codes.Internal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockSvc := new(MockProductService)
+			server := NewServer(mockSvc)
+			mockSvc.On("ReleaseStock", mock.Anything, map[uuid.UUID]int32{productID: 2}).Return(tc.mockError)
+
+			// when
+			res, err := server.ReleaseStock(ctx, &pb.ReleaseStockRequest{Items: []*pb.StockItem{{ProductId: productID.String(), Quantity: 2}}})
+
+			// then
+			if tc.expectedCode == codes.OK {
+				require.NoError(t, err)
+				require.NotNil(t, res)
+			} else {
+				require.Nil(t, res)
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, tc.expectedCode, st.Code())
+			}
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}
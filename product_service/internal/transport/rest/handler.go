@@ -1,53 +1,85 @@
 // Package rest provides HTTP handlers for product-related operations.
+// Errors are reported as {"error": "<message>", "code": "<CODE>"}.
+//
+// A product is returned with its version as the ETag header. Updates, patches and deletions accept the ETag
+// as the If-Match header instead of the version of the request, and respond with 412 if it's stale rather than with 409.
 package rest
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/abgdnv/gocommerce/pkg/money"
 	"github.com/abgdnv/gocommerce/pkg/web"
+	"github.com/abgdnv/gocommerce/product_service/internal/config"
 	producterrors "github.com/abgdnv/gocommerce/product_service/internal/errors"
 	"github.com/abgdnv/gocommerce/product_service/internal/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator/v10"
 )
 
+// productsPath is the path of the product resources below the base path.
+const productsPath = "/products"
+
+// skuPattern matches the external SKUs of the products, e.g. ERP-1001.
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
 type Handler struct {
 	service  service.ProductService
+	cfg      config.ProductsConfig
+	basePath string
 	validate *validator.Validate
 	logger   *slog.Logger
 }
 
 // NewHandler creates a new instance of ProductAPI with the provided service.
-func NewHandler(service service.ProductService, logger *slog.Logger) *Handler {
+// The routes are registered below basePath, e.g. /api/v1.
+func NewHandler(service service.ProductService, cfg config.ProductsConfig, basePath string, logger *slog.Logger) *Handler {
+	validate := validator.New()
+	money.RegisterValidation(validate)
 	return &Handler{
 		service:  service,
-		validate: validator.New(),
+		cfg:      cfg,
+		basePath: basePath,
+		validate: validate,
 		logger:   logger.With("component", "rest"),
 	}
 }
 
 // RegisterRoutes registers the HTTP routes for the product service.
 func (h *Handler) RegisterRoutes(r *chi.Mux) {
-	r.Route("/api/v1/products", func(r chi.Router) {
+	r.Route(h.basePath+productsPath, func(r chi.Router) {
+		// the caller identity is optional, it's the actor of audit events and its roles reveal the internal products
+		r.Use(web.IdentityMiddleware)
+		// the write requests are rejected with 415 unless their bodies are JSON
+		r.Use(web.RequireJSON)
 		r.Get("/", h.FindAll)
+		r.Get("/search", h.Search)
 		r.Post("/", h.Create)
+		r.Post("/batch", h.CreateBatch)
+		r.Put("/by-sku/{sku}", h.UpsertBySKU)
 
 		r.Route("/{id}", func(r chi.Router) {
 			r.Get("/", h.FindByID)
 			r.Delete("/", h.DeleteByID)
 			r.Put("/", h.Update)
+			r.Patch("/", h.Patch)
 			r.Put("/stock", h.UpdateStock)
+			r.Post("/stock/adjust", h.AdjustStock)
+			r.Get("/price-history", h.PriceHistory)
+			r.Get("/history", h.History)
 		})
 	})
-
-	r.Get("/healthz", h.HealthCheck)
 }
 
 // FindByID retrieves a product by its ID.
+// An internal product is only found by the staff, it's not found for anyone else.
 func (h *Handler) FindByID(w http.ResponseWriter, r *http.Request) {
 	id, ok := web.ParseID(w, r, h.logger)
 	if !ok {
@@ -55,107 +87,283 @@ func (h *Handler) FindByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.logger.DebugContext(r.Context(), "Received request to find product by ID", "ID", id)
-	found, err := h.service.FindByID(r.Context(), id)
+	found, err := h.service.FindByID(r.Context(), id, includeInternal(r))
 	if err != nil {
 		if errors.Is(err, producterrors.ErrProductNotFound) {
 			h.logger.WarnContext(r.Context(), "Product not found", "ID", id)
-			web.RespondError(w, h.logger, http.StatusNotFound, fmt.Sprintf("Product with ID %s not found", id))
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Product with ID %s not found", id))
 			return
 		}
 		h.logger.ErrorContext(r.Context(), "Error retrieving product", "ID", id, "error", err)
-		web.RespondError(w, h.logger, http.StatusInternalServerError, fmt.Sprintf("Failed to retrieve product with ID %s", id))
+		h.respondServerError(w, err, fmt.Sprintf("Failed to retrieve product with ID %s", id))
 		return
 	}
 	h.logger.DebugContext(r.Context(), "Successfully retrieved product", "ID", found.ID, "Name", found.Name)
+	web.SetETag(w, found.Version)
 	web.RespondJSON(w, h.logger, http.StatusOK, found)
 
 }
 
 // FindAll retrieves a list of all products.
+// Uses offset pagination by default, or cursor pagination when the mode=cursor query parameter is set.
+// The internal products are only listed for the staff.
 func (h *Handler) FindAll(w http.ResponseWriter, r *http.Request) {
-	limit, ok := web.ParseValidateGt(r, w, h.logger, "limit", 0)
-	if !ok {
+	if r.URL.Query().Get("mode") == "cursor" {
+		limit, ok := web.ParseLimit(r, w, h.logger, 0)
+		if !ok {
+			return
+		}
+		h.findAllByCursor(w, r, limit)
 		return
 	}
-	offset, ok := web.ParseValidateGte(r, w, h.logger, "offset", 0)
+	page, ok := web.ParsePagination(r, w, h.logger, 0)
 	if !ok {
 		return
 	}
-	h.logger.DebugContext(r.Context(), "Received request to find all products", "limit", limit, "offset", offset)
-	list, err := h.service.FindAll(r.Context(), offset, limit)
+	h.logger.DebugContext(r.Context(), "Received request to find all products", "limit", page.Limit, "offset", page.Offset)
+	list, err := h.service.FindAll(r.Context(), page.Offset, page.Limit, includeInternal(r))
 	if err != nil {
 		h.logger.ErrorContext(r.Context(), "Error retrieving product list", "error", err)
-		web.RespondError(w, h.logger, http.StatusInternalServerError, "Failed to fetch products")
+		h.respondServerError(w, err, "Failed to fetch products")
 		return
 	}
 	h.logger.DebugContext(r.Context(), "Successfully retrieved product list", "count", len(list))
 	web.RespondJSON(w, h.logger, http.StatusOK, list)
 }
 
-// Create handles the creation of a new product.
-func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
-	var productCreateDto service.ProductCreateDto
-	if err := json.NewDecoder(r.Body).Decode(&productCreateDto); err != nil {
-		h.logger.ErrorContext(r.Context(), "Error decoding request body", "error", err)
-		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+// Search retrieves the products matching the words of the q query parameter, the most relevant first.
+// Uses offset pagination. The internal products are only found by the staff.
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		web.RespondError(w, h.logger, http.StatusBadRequest, "q url parameter is required")
 		return
 	}
-	h.logger.DebugContext(r.Context(), "Received request to create product", "product", productCreateDto)
-	if err := h.validate.Struct(productCreateDto); err != nil {
-		var validationErrors validator.ValidationErrors
-		if errors.As(err, &validationErrors) {
-			// If the error is a validation error, we can extract field-specific errors.
-			errorResponse := make(map[string]string)
-			for _, fieldErr := range validationErrors {
-				// fieldErr.Tag() returns "required", "max", etc.
-				errorResponse[fieldErr.Field()] = "failed on rule: " + fieldErr.Tag()
-			}
-			h.logger.WarnContext(r.Context(), "Validation errors occurred", "errors", errorResponse)
-			web.RespondJSON(w, h.logger, http.StatusBadRequest, map[string]any{"validation_errors": errorResponse})
+	page, ok := web.ParsePagination(r, w, h.logger, 0)
+	if !ok {
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to search products", "query", query, "limit", page.Limit, "offset", page.Offset)
+	list, err := h.service.Search(r.Context(), query, page.Offset, page.Limit, includeInternal(r))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "Error searching products", "query", query, "error", err)
+		h.respondServerError(w, err, "Failed to search products")
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Successfully searched products", "query", query, "count", len(list))
+	web.RespondJSON(w, h.logger, http.StatusOK, list)
+}
+
+// findAllByCursor retrieves a page of products after the cursor query parameter.
+// The response contains next_cursor, if there are more products to fetch.
+func (h *Handler) findAllByCursor(w http.ResponseWriter, r *http.Request, limit int32) {
+	cursor := r.URL.Query().Get("cursor")
+	h.logger.DebugContext(r.Context(), "Received request to find products by cursor", "limit", limit, "cursor", cursor)
+	page, err := h.service.FindAllByCursor(r.Context(), cursor, limit, includeInternal(r))
+	if err != nil {
+		if errors.Is(err, producterrors.ErrInvalidCursor) {
+			h.logger.WarnContext(r.Context(), "Invalid cursor", "cursor", cursor, "error", err)
+			h.respondError(w, http.StatusBadRequest, err, fmt.Sprintf("Invalid cursor: %s", cursor))
 			return
 		}
-		h.logger.ErrorContext(r.Context(), "Error validating request body", "error", err)
-		// If it's not a validation error, we can return a generic error.
-		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+		h.logger.ErrorContext(r.Context(), "Error retrieving product page", "error", err)
+		h.respondServerError(w, err, "Failed to fetch products")
 		return
 	}
+	h.logger.DebugContext(r.Context(), "Successfully retrieved product page", "count", len(page.Items))
+	web.RespondJSON(w, h.logger, http.StatusOK, page)
+}
+
+// Create handles the creation of a new product.
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	productCreateDto, err := web.DecodeAndValidate[service.ProductCreateDto](r, h.validate, h.cfg.StrictJSON)
+	if err != nil {
+		web.RespondValidationError(w, r, h.logger, err)
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to create product", "product", productCreateDto)
 
 	newProduct, err := h.service.Create(r.Context(), productCreateDto)
 	if err != nil {
+		if errors.Is(err, producterrors.ErrProductAlreadyExists) {
+			h.logger.WarnContext(r.Context(), "Product name already exists", "Name", productCreateDto.Name)
+			h.respondAlreadyExists(w, err)
+			return
+		}
 		h.logger.ErrorContext(r.Context(), "Error creating product", "error", err)
-		web.RespondError(w, h.logger, http.StatusInternalServerError, "Failed to create product")
+		h.respondServerError(w, err, "Failed to create product")
 		return
 	}
 	h.logger.InfoContext(r.Context(), "Product created successfully", "ID", newProduct.ID, "Name", newProduct.Name)
-	web.RespondJSON(w, h.logger, http.StatusCreated, newProduct)
+	web.RespondCreated(w, h.logger, h.location(newProduct.ID), newProduct)
 }
 
-func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
-	id, ok := web.ParseID(w, r, h.logger)
-	if !ok {
+// CreateBatch handles the creation of multiple products in a single request.
+// Every product is validated on its own and the valid ones are created in a single transaction.
+// Responds with 207 and the result of every product, with 413 if the batch exceeds the max size or the body limit,
+// or with 409 if the product names are unique and any of the names is taken.
+// With the dry_run=true query parameter nothing is created, the valid products are checked against the names
+// of the live products and each other instead, and a product with a taken name is reported with 409 in the results.
+func (h *Handler) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	var products []service.ProductCreateDto
+	if err := web.DecodeJSON(r.Body, &products, 0, h.cfg.StrictJSON); err != nil {
+		web.RespondValidationError(w, r, h.logger, err)
 		return
 	}
-	h.logger.DebugContext(r.Context(), "Received request to update product", "ID", id)
-	var productDTO service.ProductDto
-	if err := json.NewDecoder(r.Body).Decode(&productDTO); err != nil {
-		h.logger.ErrorContext(r.Context(), "Error decoding request body", "error", err)
-		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+	h.logger.DebugContext(r.Context(), "Received request to create product batch", "count", len(products), "dryRun", dryRun)
+	if len(products) == 0 {
+		web.RespondError(w, h.logger, http.StatusBadRequest, "Batch must contain at least one product")
+		return
+	}
+	if len(products) > h.cfg.BatchMaxSize {
+		h.logger.WarnContext(r.Context(), "Product batch too large", "count", len(products), "max", h.cfg.BatchMaxSize)
+		h.respondError(w, http.StatusRequestEntityTooLarge, producterrors.ErrBatchTooLarge,
+			fmt.Sprintf("Batch of %d products exceeds the max size of %d", len(products), h.cfg.BatchMaxSize))
 		return
 	}
 
-	if err := h.validate.Struct(productDTO); err != nil {
-		var validationErrors validator.ValidationErrors
-		if errors.As(err, &validationErrors) {
-			errorResponse := make(map[string]string)
-			for _, fieldErr := range validationErrors {
-				errorResponse[fieldErr.Field()] = "failed on rule: " + fieldErr.Tag()
+	result := service.ProductBatchResultDto{Items: make([]service.ProductBatchItemDto, len(products))}
+	valid := make([]service.ProductCreateDto, 0, len(products))
+	// validIndexes maps the valid products to their index in the batch
+	validIndexes := make([]int, 0, len(products))
+	for i, product := range products {
+		result.Items[i].Index = i
+		if fieldErrors := h.fieldErrors(product); fieldErrors != nil {
+			result.Items[i].Status = http.StatusBadRequest
+			result.Items[i].ValidationErrors = fieldErrors
+			continue
+		}
+		valid = append(valid, product)
+		validIndexes = append(validIndexes, i)
+	}
+
+	if dryRun {
+		h.checkBatch(w, r, valid, validIndexes, result)
+		return
+	}
+	if len(valid) > 0 {
+		created, err := h.service.CreateBatch(r.Context(), valid)
+		if err != nil {
+			if errors.Is(err, producterrors.ErrProductAlreadyExists) {
+				h.logger.WarnContext(r.Context(), "Product name of the batch already exists", "error", err)
+				h.respondAlreadyExists(w, err)
+				return
 			}
-			h.logger.WarnContext(r.Context(), "Validation errors occurred", "errors", errorResponse)
-			web.RespondJSON(w, h.logger, http.StatusBadRequest, map[string]any{"validation_errors": errorResponse})
+			h.logger.ErrorContext(r.Context(), "Error creating product batch", "error", err)
+			h.respondServerError(w, err, "Failed to create products")
 			return
 		}
-		h.logger.ErrorContext(r.Context(), "Error validating request body", "error", err)
-		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+		for i, product := range created {
+			result.Items[validIndexes[i]].Status = http.StatusCreated
+			result.Items[validIndexes[i]].ID = product.ID
+		}
+	}
+	result.Created = len(valid)
+	result.Failed = len(products) - len(valid)
+	h.logger.InfoContext(r.Context(), "Product batch processed", "created", result.Created, "failed", result.Failed)
+	web.RespondJSON(w, h.logger, http.StatusMultiStatus, result)
+}
+
+// checkBatch completes the result of a dry run of CreateBatch with the check of the valid products:
+// the ones CreateBatch would create are reported with 201 and no ID, the ones with a taken name with 409.
+func (h *Handler) checkBatch(w http.ResponseWriter, r *http.Request, valid []service.ProductCreateDto, validIndexes []int, result service.ProductBatchResultDto) {
+	result.DryRun = true
+	if len(valid) > 0 {
+		checks, err := h.service.CheckBatch(r.Context(), valid)
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "Error checking product batch", "error", err)
+			h.respondServerError(w, err, "Failed to check products")
+			return
+		}
+		for i, checkErr := range checks {
+			item := &result.Items[validIndexes[i]]
+			if checkErr != nil {
+				item.Status = http.StatusConflict
+				item.ValidationErrors = map[string]string{"Name": "already exists"}
+				continue
+			}
+			item.Status = http.StatusCreated
+			result.Created++
+		}
+	}
+	result.Failed = len(result.Items) - result.Created
+	h.logger.InfoContext(r.Context(), "Product batch checked", "created", result.Created, "failed", result.Failed)
+	web.RespondJSON(w, h.logger, http.StatusMultiStatus, result)
+}
+
+// UpsertBySKU handles the creation or the update of the product with the external SKU of the path,
+// so an import can be repeated safely. Responds with 201 if the product was created, or with 200 if it existed.
+// The visibility and the currency of the body only apply to a created product,
+// an update with another currency than the one of the product is rejected with 409.
+func (h *Handler) UpsertBySKU(w http.ResponseWriter, r *http.Request) {
+	sku := r.PathValue("sku")
+	if !skuPattern.MatchString(sku) {
+		h.logger.WarnContext(r.Context(), "Invalid product SKU", "SKU", sku)
+		h.respondError(w, http.StatusBadRequest, producterrors.ErrInvalidSKU,
+			fmt.Sprintf("Invalid SKU: %s, must be 1 to 64 letters, digits, dots, dashes or underscores", sku))
+		return
+	}
+	productCreateDto, err := web.DecodeAndValidate[service.ProductCreateDto](r, h.validate, h.cfg.StrictJSON)
+	if err != nil {
+		web.RespondValidationError(w, r, h.logger, err)
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to upsert product", "SKU", sku, "product", productCreateDto)
+
+	upserted, created, err := h.service.UpsertBySKU(r.Context(), sku, productCreateDto)
+	if err != nil {
+		if errors.Is(err, producterrors.ErrProductAlreadyExists) {
+			h.logger.WarnContext(r.Context(), "Product name already exists", "SKU", sku, "Name", productCreateDto.Name)
+			h.respondAlreadyExists(w, err)
+			return
+		}
+		if errors.Is(err, producterrors.ErrCurrencyMismatch) {
+			h.logger.WarnContext(r.Context(), "Product currency mismatch", "SKU", sku, "Currency", productCreateDto.Currency, "error", err)
+			h.respondError(w, http.StatusConflict, err, fmt.Sprintf("Product with SKU %s is priced in another currency", sku))
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "Error upserting product", "SKU", sku, "error", err)
+		h.respondServerError(w, err, fmt.Sprintf("Failed to upsert product with SKU %s", sku))
+		return
+	}
+	web.SetETag(w, upserted.Version)
+	if created {
+		h.logger.InfoContext(r.Context(), "Product created by SKU successfully", "ID", upserted.ID, "SKU", sku)
+		web.RespondCreated(w, h.logger, h.location(upserted.ID), upserted)
+		return
+	}
+	h.logger.InfoContext(r.Context(), "Product updated by SKU successfully", "ID", upserted.ID, "SKU", sku)
+	web.RespondJSON(w, h.logger, http.StatusOK, upserted)
+}
+
+// fieldErrors validates v and returns the failed rule of every invalid field, or nil if v is valid.
+func (h *Handler) fieldErrors(v any) map[string]string {
+	err := web.Validate(h.validate, v)
+	if err == nil {
+		return nil
+	}
+	var validationErr *web.ValidationError
+	if !errors.As(err, &validationErr) {
+		return map[string]string{"body": err.Error()}
+	}
+	return validationErr.Fields
+}
+
+// Update handles the update of a product. The version of the If-Match header, if set, replaces the one of the body.
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := web.ParseID(w, r, h.logger)
+	if !ok {
+		return
+	}
+	ifMatch, ok := web.ParseIfMatch(w, r, h.logger)
+	if !ok {
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to update product", "ID", id, "IfMatch", ifMatch)
+	productDTO, err := decodeVersioned(r, h.validate, h.cfg.StrictJSON, ifMatch, func(p *service.ProductDto) *int32 { return &p.Version })
+	if err != nil {
+		web.RespondValidationError(w, r, h.logger, err)
 		return
 	}
 
@@ -165,80 +373,173 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		if errors.Is(err, producterrors.ErrProductNotFound) {
 			h.logger.WarnContext(r.Context(), "Product not found for update", "ID", id)
-			web.RespondError(w, h.logger, http.StatusNotFound, fmt.Sprintf("Product with ID %s not found", id))
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Product with ID %s not found", id))
+			return
+		}
+		if errors.Is(err, producterrors.ErrOptimisticLock) {
+			h.logger.WarnContext(r.Context(), "Optimistic lock error during product update", "ID", id, "error", err)
+			h.respondVersionMismatch(w, err, id.String(), ifMatch)
+			return
+		}
+		if errors.Is(err, producterrors.ErrProductAlreadyExists) {
+			h.logger.WarnContext(r.Context(), "Product name already exists", "ID", id, "Name", productDTO.Name)
+			h.respondAlreadyExists(w, err)
 			return
 		}
 		h.logger.ErrorContext(r.Context(), "Error updating product", "ID", id, "error", err)
-		web.RespondError(w, h.logger, http.StatusInternalServerError, fmt.Sprintf("Failed to update product with ID %s", id))
+		h.respondServerError(w, err, fmt.Sprintf("Failed to update product with ID %s", id))
 		return
 	}
 	h.logger.InfoContext(r.Context(), "Product updated successfully", "ID", updated.ID, "Name", updated.Name)
+	web.SetETag(w, updated.Version)
 	web.RespondJSON(w, h.logger, http.StatusOK, updated)
 }
 
-func (h *Handler) UpdateStock(w http.ResponseWriter, r *http.Request) {
+// Patch handles the partial update of a product, only the fields provided in the body are modified.
+// The version of the If-Match header, if set, replaces the one of the body.
+func (h *Handler) Patch(w http.ResponseWriter, r *http.Request) {
 	id, ok := web.ParseID(w, r, h.logger)
 	if !ok {
 		return
 	}
-	h.logger.DebugContext(r.Context(), "Received request to update stock for product", "ID", id)
-	var stockUpdateDTO service.StockUpdateDto
-	if err := json.NewDecoder(r.Body).Decode(&stockUpdateDTO); err != nil {
-		h.logger.ErrorContext(r.Context(), "Error decoding request body", "error", err)
-		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+	ifMatch, ok := web.ParseIfMatch(w, r, h.logger)
+	if !ok {
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to patch product", "ID", id, "IfMatch", ifMatch)
+	patchDTO, err := decodeVersioned(r, h.validate, h.cfg.StrictJSON, ifMatch, func(p *service.ProductPatchDto) *int32 { return &p.Version })
+	if err != nil {
+		web.RespondValidationError(w, r, h.logger, err)
 		return
 	}
 
-	if err := h.validate.Struct(stockUpdateDTO); err != nil {
-		var validationErrors validator.ValidationErrors
-		if errors.As(err, &validationErrors) {
-			errorResponse := make(map[string]string)
-			for _, fieldErr := range validationErrors {
-				errorResponse[fieldErr.Field()] = "failed on rule: " + fieldErr.Tag()
-			}
-			h.logger.WarnContext(r.Context(), "Validation errors occurred", "errors", errorResponse)
-			web.RespondJSON(w, h.logger, http.StatusBadRequest, map[string]any{"validation_errors": errorResponse})
+	patched, err := h.service.Patch(r.Context(), id, patchDTO)
+	if err != nil {
+		if errors.Is(err, producterrors.ErrProductNotFound) {
+			h.logger.WarnContext(r.Context(), "Product not found for patch", "ID", id)
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Product with ID %s not found", id))
+			return
+		}
+		if errors.Is(err, producterrors.ErrOptimisticLock) {
+			h.logger.WarnContext(r.Context(), "Optimistic lock error during product patch", "ID", id, "error", err)
+			h.respondVersionMismatch(w, err, id.String(), ifMatch)
 			return
 		}
-		h.logger.ErrorContext(r.Context(), "Error validating request body", "error", err)
-		web.RespondError(w, h.logger, http.StatusBadRequest, "Invalid request body")
+		if errors.Is(err, producterrors.ErrProductAlreadyExists) {
+			h.logger.WarnContext(r.Context(), "Product name already exists", "ID", id, "error", err)
+			h.respondAlreadyExists(w, err)
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "Error patching product", "ID", id, "error", err)
+		h.respondServerError(w, err, fmt.Sprintf("Failed to update product with ID %s", id))
+		return
+	}
+	h.logger.InfoContext(r.Context(), "Product patched successfully", "ID", patched.ID, "Name", patched.Name)
+	web.SetETag(w, patched.Version)
+	web.RespondJSON(w, h.logger, http.StatusOK, patched)
+}
+
+func (h *Handler) UpdateStock(w http.ResponseWriter, r *http.Request) {
+	id, ok := web.ParseID(w, r, h.logger)
+	if !ok {
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to update stock for product", "ID", id)
+	stockUpdateDTO, err := web.DecodeAndValidate[service.StockUpdateDto](r, h.validate, h.cfg.StrictJSON)
+	if err != nil {
+		web.RespondValidationError(w, r, h.logger, err)
 		return
 	}
 
-	updated, err := h.service.UpdateStock(r.Context(), id, stockUpdateDTO.Stock, stockUpdateDTO.Version)
+	updated, err := h.service.UpdateStock(r.Context(), id, stockUpdateDTO.Stock, stockUpdateDTO.Version, stockUpdateDTO.RestockAt)
 	if err != nil {
 		if errors.Is(err, producterrors.ErrProductNotFound) {
 			h.logger.WarnContext(r.Context(), "Product not found for stock update", "ID", id)
-			web.RespondError(w, h.logger, http.StatusNotFound, fmt.Sprintf("Product with ID %s not found", id))
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Product with ID %s not found", id))
+			return
+		}
+		if errors.Is(err, producterrors.ErrOptimisticLock) {
+			h.logger.WarnContext(r.Context(), "Optimistic lock error during stock update", "ID", id, "error", err)
+			h.respondConflict(w, err, id.String())
 			return
 		}
 		h.logger.ErrorContext(r.Context(), "Error updating stock for product", "ID", id, "error", err)
-		web.RespondError(w, h.logger, http.StatusInternalServerError, fmt.Sprintf("Failed to update stock for product with ID %s", id))
+		h.respondServerError(w, err, fmt.Sprintf("Failed to update stock for product with ID %s", id))
 		return
 	}
 	h.logger.InfoContext(r.Context(), "Stock updated successfully for product", "ID", updated.ID, "NewStock", updated.Stock)
 	web.RespondJSON(w, h.logger, http.StatusOK, updated)
 }
 
-// DeleteByID deletes a product by its ID.
+// AdjustStock adds the delta of the body to the stock quantity of a product, a negative delta reduces it.
+// Responds with 400 if the stock quantity would go below zero or exceed the range of int4.
+func (h *Handler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	id, ok := web.ParseID(w, r, h.logger)
+	if !ok {
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to adjust stock for product", "ID", id)
+	stockAdjustDTO, err := web.DecodeAndValidate[service.StockAdjustDto](r, h.validate, h.cfg.StrictJSON)
+	if err != nil {
+		web.RespondValidationError(w, r, h.logger, err)
+		return
+	}
+
+	updated, err := h.service.AdjustStock(r.Context(), id, stockAdjustDTO.Delta, stockAdjustDTO.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, producterrors.ErrProductNotFound):
+			h.logger.WarnContext(r.Context(), "Product not found for stock adjustment", "ID", id)
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Product with ID %s not found", id))
+		case errors.Is(err, producterrors.ErrOptimisticLock):
+			h.logger.WarnContext(r.Context(), "Optimistic lock error during stock adjustment", "ID", id, "error", err)
+			h.respondConflict(w, err, id.String())
+		case errors.Is(err, producterrors.ErrInsufficientStock):
+			h.logger.WarnContext(r.Context(), "Insufficient stock for adjustment", "ID", id, "delta", stockAdjustDTO.Delta)
+			h.respondError(w, http.StatusBadRequest, err, fmt.Sprintf("Stock of product with ID %s can't go below zero", id))
+		case errors.Is(err, producterrors.ErrStockOverflow):
+			h.logger.WarnContext(r.Context(), "Stock overflow for adjustment", "ID", id, "delta", stockAdjustDTO.Delta)
+			h.respondError(w, http.StatusBadRequest, err, fmt.Sprintf("Stock of product with ID %s can't exceed %d", id, math.MaxInt32))
+		default:
+			h.logger.ErrorContext(r.Context(), "Error adjusting stock for product", "ID", id, "error", err)
+			h.respondServerError(w, err, fmt.Sprintf("Failed to adjust stock for product with ID %s", id))
+		}
+		return
+	}
+	h.logger.InfoContext(r.Context(), "Stock adjusted successfully for product", "ID", updated.ID, "Delta", stockAdjustDTO.Delta, "NewStock", updated.Stock)
+	web.RespondJSON(w, h.logger, http.StatusOK, updated)
+}
+
+// DeleteByID deletes a product by its ID and the version of the If-Match header, or the version query parameter.
 func (h *Handler) DeleteByID(w http.ResponseWriter, r *http.Request) {
 	id, ok := web.ParseID(w, r, h.logger)
 	if !ok {
 		return
 	}
-	version, ok := web.ParseValidateGte(r, w, h.logger, "version", 1)
+	ifMatch, ok := web.ParseIfMatch(w, r, h.logger)
 	if !ok {
 		return
 	}
+	version := ifMatch
+	if version == 0 {
+		if version, ok = web.ParseValidateGte(r, w, h.logger, "version", 1); !ok {
+			return
+		}
+	}
 	h.logger.DebugContext(r.Context(), "Received request to delete product", "ID", id, "Version", version)
 	if err := h.service.DeleteByID(r.Context(), id, version); err != nil {
 		if errors.Is(err, producterrors.ErrProductNotFound) {
 			h.logger.WarnContext(r.Context(), "Product not found for deletion", "ID", id)
-			web.RespondError(w, h.logger, http.StatusNotFound, fmt.Sprintf("Product with ID %s not found", id))
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Product with ID %s not found", id))
+			return
+		}
+		if errors.Is(err, producterrors.ErrOptimisticLock) {
+			h.logger.WarnContext(r.Context(), "Optimistic lock error during product deletion", "ID", id, "error", err)
+			h.respondVersionMismatch(w, err, id.String(), ifMatch)
 			return
 		}
 		h.logger.ErrorContext(r.Context(), "Error deleting product", "ID", id, "error", err)
-		web.RespondError(w, h.logger, http.StatusInternalServerError, fmt.Sprintf("Failed to delete product with ID %s", id))
+		h.respondServerError(w, err, fmt.Sprintf("Failed to delete product with ID %s", id))
 		return
 
 	}
@@ -246,7 +547,143 @@ func (h *Handler) DeleteByID(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// HealthCheck is a simple health check endpoint.
-func (h *Handler) HealthCheck(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
+// PriceHistory retrieves the min, max and average price of a product per day or week.
+// The interval query parameter defaults to day, the time range is set by the from and to RFC 3339 query parameters.
+// Longer periods are fetched page by page, using the from of the previous response as the next to.
+func (h *Handler) PriceHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := web.ParseID(w, r, h.logger)
+	if !ok {
+		return
+	}
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = service.IntervalDay
+	}
+	from, ok := h.parseTime(w, r, "from")
+	if !ok {
+		return
+	}
+	to, ok := h.parseTime(w, r, "to")
+	if !ok {
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to find product price history", "ID", id, "interval", interval, "from", from, "to", to)
+	history, err := h.service.PriceHistory(r.Context(), id, interval, from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, producterrors.ErrInvalidInterval):
+			h.logger.WarnContext(r.Context(), "Invalid price history interval", "interval", interval)
+			h.respondError(w, http.StatusBadRequest, err, fmt.Sprintf("Invalid interval: %s, must be day or week", interval))
+		case errors.Is(err, producterrors.ErrInvalidTimeRange):
+			h.logger.WarnContext(r.Context(), "Invalid price history time range", "from", from, "to", to, "error", err)
+			h.respondError(w, http.StatusBadRequest, err, fmt.Sprintf("Invalid time range, it must not exceed %s", h.cfg.PriceHistoryMaxRange))
+		case errors.Is(err, producterrors.ErrProductNotFound):
+			h.logger.WarnContext(r.Context(), "Product not found for price history", "ID", id)
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Product with ID %s not found", id))
+		default:
+			h.logger.ErrorContext(r.Context(), "Error retrieving product price history", "ID", id, "error", err)
+			h.respondServerError(w, err, fmt.Sprintf("Failed to retrieve price history of product with ID %s", id))
+		}
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Successfully retrieved product price history", "ID", id, "buckets", len(history.Buckets))
+	web.RespondJSON(w, h.logger, http.StatusOK, history)
+}
+
+// History retrieves the changes of a product, oldest first, including the changes of a deleted product.
+func (h *Handler) History(w http.ResponseWriter, r *http.Request) {
+	id, ok := web.ParseID(w, r, h.logger)
+	if !ok {
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Received request to find product history", "ID", id)
+	history, err := h.service.History(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, producterrors.ErrProductNotFound) {
+			h.logger.WarnContext(r.Context(), "Product not found for history", "ID", id)
+			h.respondError(w, http.StatusNotFound, err, fmt.Sprintf("Product with ID %s not found", id))
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "Error retrieving product history", "ID", id, "error", err)
+		h.respondServerError(w, err, fmt.Sprintf("Failed to retrieve history of product with ID %s", id))
+		return
+	}
+	h.logger.DebugContext(r.Context(), "Successfully retrieved product history", "ID", id, "changes", len(history.Changes))
+	web.RespondJSON(w, h.logger, http.StatusOK, history)
+}
+
+// parseTime parses the optional RFC 3339 time query parameter, a missing parameter is the zero time.
+// It responds with 400 and returns false if the parameter is malformed.
+func (h *Handler) parseTime(w http.ResponseWriter, r *http.Request, param string) (time.Time, bool) {
+	value := r.URL.Query().Get(param)
+	if value == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "Invalid time query parameter", "param", param, "value", value)
+		h.respondError(w, http.StatusBadRequest, producterrors.ErrInvalidTimeRange, fmt.Sprintf("Invalid %s: %s, must be an RFC 3339 time", param, value))
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// respondConflict responds with 409 to a request with a stale product version.
+func (h *Handler) respondConflict(w http.ResponseWriter, err error, id string) {
+	h.respondError(w, http.StatusConflict, err, fmt.Sprintf("Product with ID %s has been modified by another user", id))
+}
+
+// respondVersionMismatch responds to an optimistic lock error with 412 if the version was set by the If-Match header,
+// otherwise with 409 as respondConflict.
+func (h *Handler) respondVersionMismatch(w http.ResponseWriter, err error, id string, ifMatch int32) {
+	if ifMatch == 0 {
+		h.respondConflict(w, err, id)
+		return
+	}
+	h.respondError(w, http.StatusPreconditionFailed, err, fmt.Sprintf("Product with ID %s has been modified by another user", id))
+}
+
+// respondAlreadyExists responds with 409 to a product named like another live product, if the names are unique.
+func (h *Handler) respondAlreadyExists(w http.ResponseWriter, err error) {
+	h.respondError(w, http.StatusConflict, err, "A product with the same name already exists")
+}
+
+// respondServerError responds with 504 if a database query timed out, otherwise with 500 and the message.
+func (h *Handler) respondServerError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, producterrors.ErrQueryTimeout) {
+		h.respondError(w, http.StatusGatewayTimeout, err, "Database query timed out")
+		return
+	}
+	web.RespondError(w, h.logger, http.StatusInternalServerError, message)
+}
+
+// respondError responds with the status and the message, and the code of the product error err wraps.
+func (h *Handler) respondError(w http.ResponseWriter, status int, err error, message string) {
+	web.RespondAppError(w, h.logger, web.NewAppError(status, producterrors.Code(err), message))
+}
+
+// decodeVersioned decodes the JSON request body into a T and validates it like web.DecodeAndValidate.
+// A non-zero ifMatch replaces the version of the body before the validation, so the body may omit it.
+func decodeVersioned[T any](r *http.Request, validate *validator.Validate, strict bool, ifMatch int32, version func(*T) *int32) (T, error) {
+	var v T
+	if err := web.DecodeJSON(r.Body, &v, 0, strict); err != nil {
+		return v, fmt.Errorf("%w: %w", web.ErrInvalidBody, err)
+	}
+	if ifMatch != 0 {
+		*version(&v) = ifMatch
+	}
+	return v, web.Validate(validate, v)
+}
+
+// location returns the URL of the product with the given ID, or empty if the Location header is disabled.
+func (h *Handler) location(id string) string {
+	if !h.cfg.LocationHeader {
+		return ""
+	}
+	return h.basePath + productsPath + "/" + id
+}
+
+// includeInternal reports whether the caller may see the internal products, i.e. is a staff member.
+func includeInternal(r *http.Request) bool {
+	return web.HasRole(r.Context(), web.RoleAdmin)
 }
@@ -9,9 +9,15 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	pconfig "github.com/abgdnv/gocommerce/pkg/config"
+	"github.com/abgdnv/gocommerce/pkg/money"
+	"github.com/abgdnv/gocommerce/pkg/web"
+	"github.com/abgdnv/gocommerce/product_service/internal/config"
 	producterrors "github.com/abgdnv/gocommerce/product_service/internal/errors"
 	"github.com/abgdnv/gocommerce/product_service/internal/service"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
@@ -20,11 +26,18 @@ import (
 type mockProductService struct {
 	product  *service.ProductDto
 	products []service.ProductDto
+	page     *service.ProductPageDto
+	history  *service.PriceHistoryDto
+	changes  *service.ProductHistoryDto
 	error    error
+	// created reports the upsert created the product
+	created bool
+	// checks is the result of the check of a batch
+	checks []error
 }
 
 // Simulate finding a product by ID
-func (m mockProductService) FindByID(_ context.Context, _ uuid.UUID) (*service.ProductDto, error) {
+func (m mockProductService) FindByID(_ context.Context, _ uuid.UUID, _ bool) (*service.ProductDto, error) {
 	return m.product, m.error
 }
 
@@ -32,22 +45,76 @@ func (m mockProductService) FindByIDs(_ context.Context, _ []uuid.UUID) ([]servi
 	return m.products, m.error
 }
 
-func (m mockProductService) FindAll(_ context.Context, _, _ int32) ([]service.ProductDto, error) {
+func (m mockProductService) FindAll(_ context.Context, _, _ int32, _ bool) ([]service.ProductDto, error) {
 	return m.products, m.error
 }
 
+func (m mockProductService) Search(_ context.Context, _ string, _, _ int32, _ bool) ([]service.ProductDto, error) {
+	return m.products, m.error
+}
+
+func (m mockProductService) FindAllByCursor(_ context.Context, _ string, _ int32, _ bool) (*service.ProductPageDto, error) {
+	return m.page, m.error
+}
+
 // Simulate creating a product
 func (m mockProductService) Create(_ context.Context, _ service.ProductCreateDto) (*service.ProductDto, error) {
 	return m.product, m.error
 }
 
+// Simulate creating a batch of products
+func (m mockProductService) CreateBatch(_ context.Context, _ []service.ProductCreateDto) ([]service.ProductDto, error) {
+	return m.products, m.error
+}
+
+// Simulate checking a batch of products
+func (m mockProductService) CheckBatch(_ context.Context, _ []service.ProductCreateDto) ([]error, error) {
+	return m.checks, m.error
+}
+
+// Simulate upserting a product by SKU
+func (m mockProductService) UpsertBySKU(_ context.Context, _ string, _ service.ProductCreateDto) (*service.ProductDto, bool, error) {
+	return m.product, m.created, m.error
+}
+
 // Simulate updating a product
 func (m mockProductService) Update(_ context.Context, _ service.ProductDto) (*service.ProductDto, error) {
 	return m.product, m.error
 }
 
+// Simulate patching a product, the provided fields are applied to the product
+func (m mockProductService) Patch(_ context.Context, _ uuid.UUID, patch service.ProductPatchDto) (*service.ProductDto, error) {
+	if m.error != nil {
+		return nil, m.error
+	}
+	patched := *m.product
+	if patch.Name != nil {
+		patched.Name = *patch.Name
+	}
+	if patch.Price != nil {
+		patched.Price = *patch.Price
+	}
+	if patch.Stock != nil {
+		patched.Stock = *patch.Stock
+	}
+	return &patched, nil
+}
+
 // Simulate updating stock for a product
-func (m mockProductService) UpdateStock(_ context.Context, _ uuid.UUID, _ int32, _ int32) (*service.ProductDto, error) {
+func (m mockProductService) UpdateStock(_ context.Context, _ uuid.UUID, _ int32, _ int32, _ *time.Time) (*service.ProductDto, error) {
+	return m.product, m.error
+}
+
+// Simulate adjusting stock for a product
+func (m mockProductService) ReserveStock(_ context.Context, _ map[uuid.UUID]int32) error {
+	return m.error
+}
+
+func (m mockProductService) ReleaseStock(_ context.Context, _ map[uuid.UUID]int32) error {
+	return m.error
+}
+
+func (m mockProductService) AdjustStock(_ context.Context, _ uuid.UUID, _ int32, _ int32) (*service.ProductDto, error) {
 	return m.product, m.error
 }
 
@@ -56,6 +123,49 @@ func (m mockProductService) DeleteByID(_ context.Context, _ uuid.UUID, _ int32)
 	return m.error
 }
 
+// Simulate retrieving the price history of a product
+func (m mockProductService) PriceHistory(_ context.Context, _ uuid.UUID, _ string, _, _ time.Time) (*service.PriceHistoryDto, error) {
+	return m.history, m.error
+}
+
+// Simulate retrieving the changes of a product
+func (m mockProductService) History(_ context.Context, _ uuid.UUID) (*service.ProductHistoryDto, error) {
+	return m.changes, m.error
+}
+
+// versionCheckingService is a mockProductService that modifies its product only if the given version is current,
+// like the optimistic locking of the store.
+type versionCheckingService struct {
+	mockProductService
+}
+
+func (m versionCheckingService) Update(_ context.Context, product service.ProductDto) (*service.ProductDto, error) {
+	if product.Version != m.product.Version {
+		return nil, producterrors.ErrOptimisticLock
+	}
+	product.Version++
+	return &product, nil
+}
+
+func (m versionCheckingService) Patch(ctx context.Context, id uuid.UUID, patch service.ProductPatchDto) (*service.ProductDto, error) {
+	if patch.Version != m.product.Version {
+		return nil, producterrors.ErrOptimisticLock
+	}
+	patched, err := m.mockProductService.Patch(ctx, id, patch)
+	if err != nil {
+		return nil, err
+	}
+	patched.Version++
+	return patched, nil
+}
+
+func (m versionCheckingService) DeleteByID(_ context.Context, _ uuid.UUID, version int32) error {
+	if version != m.product.Version {
+		return producterrors.ErrOptimisticLock
+	}
+	return nil
+}
+
 func Test_ProductAPI_FindByID(t *testing.T) {
 	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
 	testCases := []struct {
@@ -68,12 +178,12 @@ func Test_ProductAPI_FindByID(t *testing.T) {
 		{
 			name: "Success - product found",
 			mockService: mockProductService{
-				product: &service.ProductDto{ID: mockID.String(), Name: "Product 1", Price: 100, Stock: 10, Version: 1},
+				product: &service.ProductDto{ID: mockID.String(), Name: "Product 1", Price: money.New(100, ""), Stock: 10, Version: 1},
 				error:   nil,
 			},
 			productID:    mockID.String(),
 			expectedCode: http.StatusOK,
-			expectedBody: `{"id":"` + mockID.String() + `","name":"Product 1","price":100,"stock":10, "version":1}`,
+			expectedBody: `{"id":"` + mockID.String() + `","name":"Product 1","price":"1.00","stock":10, "version":1}`,
 		},
 		{
 			name: "Error - invalid id",
@@ -83,7 +193,7 @@ func Test_ProductAPI_FindByID(t *testing.T) {
 			},
 			productID:    "123-invalid-id",
 			expectedCode: http.StatusBadRequest,
-			expectedBody: `{"error":"Invalid ID: 123-invalid-id"}`,
+			expectedBody: `{"error":"Invalid ID: 123-invalid-id","code":"BAD_REQUEST"}`,
 		},
 		{
 			name: "Error - product not found",
@@ -93,7 +203,7 @@ func Test_ProductAPI_FindByID(t *testing.T) {
 			},
 			productID:    mockID.String(),
 			expectedCode: http.StatusNotFound,
-			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found"}`,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found","code":"PRODUCT_NOT_FOUND"}`,
 		},
 		{
 			name: "Error - service error",
@@ -103,7 +213,17 @@ func Test_ProductAPI_FindByID(t *testing.T) {
 			},
 			productID:    mockID.String(),
 			expectedCode: http.StatusInternalServerError,
-			expectedBody: `{"error":"Failed to retrieve product with ID ` + mockID.String() + `"}`,
+			expectedBody: `{"error":"Failed to retrieve product with ID ` + mockID.String() + `","code":"INTERNAL_ERROR"}`,
+		},
+		{
+			name: "Error - query timeout",
+			mockService: mockProductService{
+				product: nil,
+				error:   producterrors.ErrQueryTimeout,
+			},
+			productID:    mockID.String(),
+			expectedCode: http.StatusGatewayTimeout,
+			expectedBody: `{"error":"Database query timed out","code":"QUERY_TIMEOUT"}`,
 		},
 	}
 
@@ -111,7 +231,7 @@ func Test_ProductAPI_FindByID(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
 			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-			api := NewHandler(&tc.mockService, logger)
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
 			req := httptest.NewRequest(http.MethodGet, "/api/v1/products/"+tc.productID, nil)
 			req.SetPathValue("id", tc.productID)
 			rr := httptest.NewRecorder()
@@ -143,13 +263,13 @@ func Test_ProductAPI_FindAll(t *testing.T) {
 			name: "Success - products found",
 			mockService: mockProductService{
 				products: []service.ProductDto{
-					{ID: "1", Name: "Product 1", Price: 100, Stock: 10, Version: 1},
-					{ID: "2", Name: "Product 2", Price: 200, Stock: 20, Version: 1},
+					{ID: "1", Name: "Product 1", Price: money.New(100, ""), Stock: 10, Version: 1},
+					{ID: "2", Name: "Product 2", Price: money.New(200, ""), Stock: 20, Version: 1},
 				},
 				error: nil,
 			},
 			expectedCode: http.StatusOK,
-			expectedBody: `[{"id":"1","name":"Product 1","price":100,"stock":10,"version":1},{"id":"2","name":"Product 2","price":200,"stock":20,"version":1}]`,
+			expectedBody: `[{"id":"1","name":"Product 1","price":"1.00","stock":10,"version":1},{"id":"2","name":"Product 2","price":"2.00","stock":20,"version":1}]`,
 		},
 		{
 			name: "Success - no products",
@@ -167,7 +287,7 @@ func Test_ProductAPI_FindAll(t *testing.T) {
 				error:    ErrServiceUnavailable,
 			},
 			expectedCode: http.StatusInternalServerError,
-			expectedBody: `{"error":"Failed to fetch products"}`,
+			expectedBody: `{"error":"Failed to fetch products","code":"INTERNAL_ERROR"}`,
 		},
 		{
 			name: "Error - no limit provided",
@@ -176,7 +296,7 @@ func Test_ProductAPI_FindAll(t *testing.T) {
 				error:    nil,
 			},
 			expectedCode: http.StatusBadRequest,
-			expectedBody: `{"error":"limit url parameter is required"}`,
+			expectedBody: `{"error":"limit url parameter is required","code":"BAD_REQUEST"}`,
 			noLimit:      true,
 		},
 		{
@@ -186,7 +306,7 @@ func Test_ProductAPI_FindAll(t *testing.T) {
 				error:    nil,
 			},
 			expectedCode: http.StatusBadRequest,
-			expectedBody: `{"error":"offset url parameter is required"}`,
+			expectedBody: `{"error":"offset url parameter is required","code":"BAD_REQUEST"}`,
 			noOffset:     true,
 		},
 		{
@@ -196,7 +316,7 @@ func Test_ProductAPI_FindAll(t *testing.T) {
 				error:    nil,
 			},
 			expectedCode:    http.StatusBadRequest,
-			expectedBody:    `{"error":"Invalid offset number: not-a-number"}`,
+			expectedBody:    `{"error":"Invalid offset number: not-a-number","code":"BAD_REQUEST"}`,
 			OffsetNotNumber: true,
 		},
 	}
@@ -205,7 +325,7 @@ func Test_ProductAPI_FindAll(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
 			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-			api := NewHandler(&tc.mockService, logger)
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
 
 			params := make([]string, 0, 2)
 			if !tc.noOffset {
@@ -234,54 +354,59 @@ func Test_ProductAPI_FindAll(t *testing.T) {
 	}
 }
 
-func Test_ProductAPI_Create(t *testing.T) {
-	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+func Test_ProductAPI_FindAll_CursorMode(t *testing.T) {
 	testCases := []struct {
 		name         string
 		mockService  mockProductService
-		requestBody  string
+		query        string
 		expectedCode int
 		expectedBody string
 	}{
 		{
-			name: "Success - product created",
+			name: "Success - first page",
 			mockService: mockProductService{
-				product: &service.ProductDto{ID: mockID.String(), Name: "New Product", Price: 150, Stock: 5, Version: 1},
-				error:   nil,
+				page: &service.ProductPageDto{
+					Items:      []service.ProductDto{{ID: "1", Name: "Product 1", Price: money.New(100, ""), Stock: 10, Version: 1}},
+					NextCursor: "next",
+				},
 			},
-			requestBody:  `{"name":"New Product","price":150,"stock":5}`,
-			expectedCode: http.StatusCreated,
-			expectedBody: `{"id":"` + mockID.String() + `","name":"New Product","price":150,"stock":5, "version":1}`,
+			query:        "mode=cursor&limit=1",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"items":[{"id":"1","name":"Product 1","price":"1.00","stock":10,"version":1}],"next_cursor":"next"}`,
 		},
 		{
-			name: "Error - validation failed",
+			name: "Success - last page",
 			mockService: mockProductService{
-				product: nil,
-				error:   nil,
+				page: &service.ProductPageDto{Items: []service.ProductDto{}},
 			},
-			requestBody:  `{"name":"","price":-100,"stock":-5}`,
-			expectedCode: http.StatusBadRequest,
-			expectedBody: `{"validation_errors":{"Name":"failed on rule: required","Price":"failed on rule: min","Stock":"failed on rule: min"}}`,
+			query:        "mode=cursor&limit=1&cursor=next",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"items":[]}`,
 		},
 		{
-			name: "Error - invalid json",
+			name: "Error - invalid cursor",
 			mockService: mockProductService{
-				product: nil,
-				error:   nil,
+				error: producterrors.ErrInvalidCursor,
 			},
-			requestBody:  `invalid json`,
+			query:        "mode=cursor&limit=1&cursor=broken",
 			expectedCode: http.StatusBadRequest,
-			expectedBody: `{"error":"Invalid request body"}`,
+			expectedBody: `{"error":"Invalid cursor: broken","code":"INVALID_CURSOR"}`,
 		},
 		{
 			name: "Error - service error",
 			mockService: mockProductService{
-				product: nil,
-				error:   errors.New("service unavailable"),
+				error: errors.New("service unavailable"),
 			},
-			requestBody:  `{"name":"Another Product","price":200,"stock":10}`,
+			query:        "mode=cursor&limit=1",
 			expectedCode: http.StatusInternalServerError,
-			expectedBody: `{"error":"Failed to create product"}`,
+			expectedBody: `{"error":"Failed to fetch products","code":"INTERNAL_ERROR"}`,
+		},
+		{
+			name:         "Error - no limit provided",
+			mockService:  mockProductService{},
+			query:        "mode=cursor",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"limit url parameter is required","code":"BAD_REQUEST"}`,
 		},
 	}
 
@@ -289,13 +414,13 @@ func Test_ProductAPI_Create(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
 			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-			api := NewHandler(&tc.mockService, logger)
-			req := httptest.NewRequest(http.MethodPost, "/api/v1/products", nil)
-			req.Body = io.NopCloser(strings.NewReader(tc.requestBody))
-			req.Header.Set("Content-Type", "application/json")
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/products?"+tc.query, nil)
 			rr := httptest.NewRecorder()
+
 			// when
-			api.Create(rr, req)
+			api.FindAll(rr, req)
+
 			// then
 			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
 			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
@@ -304,85 +429,74 @@ func Test_ProductAPI_Create(t *testing.T) {
 	}
 }
 
-func Test_ProductAPI_Update(t *testing.T) {
-	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+func Test_ProductAPI_Search(t *testing.T) {
 	testCases := []struct {
 		name         string
 		mockService  mockProductService
-		productID    string
-		requestBody  string
+		query        string
 		expectedCode int
 		expectedBody string
 	}{
 		{
-			name: "Success - product updated",
+			name: "Success - products found",
 			mockService: mockProductService{
-				product: &service.ProductDto{ID: mockID.String(), Name: "Updated Product", Price: 200, Stock: 15, Version: 1},
-				error:   nil,
+				products: []service.ProductDto{{ID: "1", Name: "Wireless Headphones", Price: money.New(100, ""), Stock: 10, Version: 1}},
 			},
-			productID:    mockID.String(),
-			requestBody:  `{"name":"Updated Product","price":200,"stock":15,"version":1}`,
+			query:        "q=wireless+headphones&offset=0&limit=10",
 			expectedCode: http.StatusOK,
-			expectedBody: `{"id":"` + mockID.String() + `","name":"Updated Product","price":200,"stock":15, "version":1}`,
+			expectedBody: `[{"id":"1","name":"Wireless Headphones","price":"1.00","stock":10,"version":1}]`,
 		},
 		{
-			name: "Error - validation failed",
+			name: "Success - no products match",
 			mockService: mockProductService{
-				product: nil,
-				error:   nil,
+				products: []service.ProductDto{},
 			},
-			productID:    mockID.String(),
-			requestBody:  `{"name":"","price":-100,"stock":-5,"version":1}`,
-			expectedCode: http.StatusBadRequest,
-			expectedBody: `{"validation_errors":{"Name":"failed on rule: required","Price":"failed on rule: min","Stock":"failed on rule: min"}}`,
+			query:        "q=the&offset=0&limit=10",
+			expectedCode: http.StatusOK,
+			expectedBody: `[]`,
 		},
 		{
-			name: "Error - invalid json",
+			name: "Error - service error",
 			mockService: mockProductService{
-				product: nil,
-				error:   nil,
+				error: errors.New("service unavailable"),
 			},
-			productID:    mockID.String(),
-			requestBody:  `invalid json`,
+			query:        "q=headphones&offset=0&limit=10",
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Failed to search products","code":"INTERNAL_ERROR"}`,
+		},
+		{
+			name:         "Error - no query provided",
+			mockService:  mockProductService{},
+			query:        "offset=0&limit=10",
 			expectedCode: http.StatusBadRequest,
-			expectedBody: `{"error":"Invalid request body"}`,
+			expectedBody: `{"error":"q url parameter is required","code":"BAD_REQUEST"}`,
 		},
 		{
-			name: "Error - product not found",
-			mockService: mockProductService{
-				product: nil,
-				error:   producterrors.ErrProductNotFound,
-			},
-			productID:    mockID.String(),
-			requestBody:  `{"name":"Nonexistent Product","price":100,"stock":10,"version":1}`,
-			expectedCode: http.StatusNotFound,
-			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found"}`,
+			name:         "Error - blank query",
+			mockService:  mockProductService{},
+			query:        "q=++&offset=0&limit=10",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"q url parameter is required","code":"BAD_REQUEST"}`,
 		},
 		{
-			name: "Error - service error",
-			mockService: mockProductService{
-				product: nil,
-				error:   errors.New("service unavailable"),
-			},
-			productID:    mockID.String(),
-			requestBody:  `{"name":"Another Product","price":150,"stock":5,"version":1}`,
-			expectedCode: http.StatusInternalServerError,
-			expectedBody: `{"error":"Failed to update product with ID ` + mockID.String() + `"}`,
+			name:         "Error - no limit provided",
+			mockService:  mockProductService{},
+			query:        "q=headphones&offset=0",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"limit url parameter is required","code":"BAD_REQUEST"}`,
 		},
 	}
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
 			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-			api := NewHandler(&tc.mockService, logger)
-			req := httptest.NewRequest(http.MethodPut, "/api/v1/products/"+tc.productID, nil)
-			req.Body = io.NopCloser(strings.NewReader(tc.requestBody))
-			req.Header.Set("Content-Type", "application/json")
-			req.SetPathValue("id", tc.productID)
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/products/search?"+tc.query, nil)
 			rr := httptest.NewRecorder()
 
 			// when
-			api.Update(rr, req)
+			api.Search(rr, req)
 
 			// then
 			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
@@ -390,29 +504,47 @@ func Test_ProductAPI_Update(t *testing.T) {
 			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
 		})
 	}
-
 }
 
-func Test_ProductAPI_UpdateStock(t *testing.T) {
+func Test_ProductAPI_Create(t *testing.T) {
 	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
 	testCases := []struct {
 		name         string
 		mockService  mockProductService
-		productID    string
 		requestBody  string
 		expectedCode int
 		expectedBody string
 	}{
 		{
-			name: "Success - stock updated",
+			name: "Success - product created",
 			mockService: mockProductService{
-				product: &service.ProductDto{ID: mockID.String(), Name: "Product 1", Price: 100, Stock: 30, Version: 1},
+				product: &service.ProductDto{ID: mockID.String(), Name: "New Product", Price: money.New(150, ""), Stock: 5, Version: 1},
 				error:   nil,
 			},
-			productID:    mockID.String(),
-			requestBody:  `{"stock":30,"version":1}`,
-			expectedCode: http.StatusOK,
-			expectedBody: `{"id":"` + mockID.String() + `","name":"Product 1","price":100,"stock":30, "version":1}`,
+			requestBody:  `{"name":"New Product","price":150,"stock":5}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":"` + mockID.String() + `","name":"New Product","price":"1.50","stock":5, "version":1}`,
+		},
+		{
+			name: "Success - product priced in EUR",
+			mockService: mockProductService{
+				product: &service.ProductDto{ID: mockID.String(), Name: "New Product", Price: money.New(150, "EUR"), Stock: 5, Version: 1, Currency: "EUR"},
+			},
+			requestBody:  `{"name":"New Product","price":"1.50","stock":5,"currency":"EUR"}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":"` + mockID.String() + `","name":"New Product","price":"1.50","stock":5, "version":1,"currency":"EUR"}`,
+		},
+		{
+			name:         "Error - unsupported currency",
+			requestBody:  `{"name":"New Product","price":150,"stock":5,"currency":"XYZ"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"validation_errors":{"Currency":"failed on rule: iso4217"},"code":"VALIDATION_FAILED"}`,
+		},
+		{
+			name:         "Error - currency without hundredths",
+			requestBody:  `{"name":"New Product","price":"500","stock":5,"currency":"JPY"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"validation_errors":{"Currency":"failed on rule: money_currency"},"code":"VALIDATION_FAILED"}`,
 		},
 		{
 			name: "Error - validation failed",
@@ -420,59 +552,64 @@ func Test_ProductAPI_UpdateStock(t *testing.T) {
 				product: nil,
 				error:   nil,
 			},
-			productID:    mockID.String(),
-			requestBody:  `{"stock":-10,"version":1}`,
+			requestBody:  `{"name":"","price":-100,"stock":-5}`,
 			expectedCode: http.StatusBadRequest,
-			expectedBody: `{"validation_errors":{"Stock":"failed on rule: min"}}`,
+			expectedBody: `{"validation_errors":{"Name":"failed on rule: required","Price":"failed on rule: min","Stock":"failed on rule: min"},"code":"VALIDATION_FAILED"}`,
 		},
 		{
-			name: "Error - service error",
+			name: "Error - invalid json",
 			mockService: mockProductService{
 				product: nil,
-				error:   errors.New("service unavailable"),
+				error:   nil,
 			},
-			productID:    mockID.String(),
-			requestBody:  `{"stock":25,"version":1}`,
-			expectedCode: http.StatusInternalServerError,
-			expectedBody: `{"error":"Failed to update stock for product with ID ` + mockID.String() + `"}`,
+			requestBody:  `invalid json`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid request body","code":"BAD_REQUEST"}`,
 		},
 		{
-			name: "Error - product not found",
+			name:         "Error - price with fractions of cents",
+			requestBody:  `{"name":"New Product","price":"1.505","stock":5}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid request body","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:         "Error - fractional number price",
+			requestBody:  `{"name":"New Product","price":1.5,"stock":5}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid request body","code":"BAD_REQUEST"}`,
+		},
+		{
+			name: "Error - name already exists",
 			mockService: mockProductService{
-				product: nil,
-				error:   producterrors.ErrProductNotFound,
+				error: producterrors.ErrProductAlreadyExists,
 			},
-			productID:    mockID.String(),
-			requestBody:  `{"stock":50,"version":1}`,
-			expectedCode: http.StatusNotFound,
-			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found"}`,
+			requestBody:  `{"name":"New Product","price":150,"stock":5}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"A product with the same name already exists","code":"PRODUCT_ALREADY_EXISTS"}`,
 		},
 		{
-			name: "Error - invalid json",
+			name: "Error - service error",
 			mockService: mockProductService{
 				product: nil,
-				error:   nil,
+				error:   errors.New("service unavailable"),
 			},
-			productID:    mockID.String(),
-			requestBody:  `invalid json`,
-			expectedCode: http.StatusBadRequest,
-			expectedBody: `{"error":"Invalid request body"}`,
+			requestBody:  `{"name":"Another Product","price":200,"stock":10}`,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Failed to create product","code":"INTERNAL_ERROR"}`,
 		},
 	}
+
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
 			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-			api := NewHandler(&tc.mockService, logger)
-			req := httptest.NewRequest(http.MethodPut, "/api/v1/products/"+tc.productID+"/stock", nil)
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/products", nil)
 			req.Body = io.NopCloser(strings.NewReader(tc.requestBody))
 			req.Header.Set("Content-Type", "application/json")
-			req.SetPathValue("id", tc.productID)
 			rr := httptest.NewRecorder()
-
 			// when
-			api.UpdateStock(rr, req)
-
+			api.Create(rr, req)
 			// then
 			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
 			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
@@ -481,55 +618,35 @@ func Test_ProductAPI_UpdateStock(t *testing.T) {
 	}
 }
 
-func Test_ProductAPI_DeleteByID(t *testing.T) {
+func Test_ProductAPI_Create_LocationHeader(t *testing.T) {
 	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
 	testCases := []struct {
-		name         string
-		mockService  mockProductService
-		productID    string
-		expectedCode int
-		expectedBody string
-		urlParams    string
+		name             string
+		cfg              config.ProductsConfig
+		mockService      mockProductService
+		expectedCode     int
+		expectedLocation string
 	}{
 		{
-			name: "Success - product deleted",
-			mockService: mockProductService{
-				error: nil,
-			},
-			productID:    mockID.String(),
-			expectedCode: http.StatusNoContent,
-			expectedBody: "",
-			urlParams:    "?version=1",
-		},
-		{
-			name: "Error - product not found",
-			mockService: mockProductService{
-				error: producterrors.ErrProductNotFound,
-			},
-			productID:    mockID.String(),
-			expectedCode: http.StatusNotFound,
-			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found"}`,
-			urlParams:    "?version=1",
+			name:             "Location header is set when enabled",
+			cfg:              config.ProductsConfig{LocationHeader: true},
+			mockService:      mockProductService{product: &service.ProductDto{ID: mockID.String(), Name: "New Product", Price: money.New(150, ""), Stock: 5, Version: 1}},
+			expectedCode:     http.StatusCreated,
+			expectedLocation: "/api/v1/products/" + mockID.String(),
 		},
 		{
-			name: "Error - service error",
-			mockService: mockProductService{
-				error: errors.New("service unavailable"),
-			},
-			productID:    mockID.String(),
-			expectedCode: http.StatusInternalServerError,
-			expectedBody: `{"error":"Failed to delete product with ID ` + mockID.String() + `"}`,
-			urlParams:    "?version=1",
+			name:             "Location header is omitted when disabled",
+			cfg:              config.ProductsConfig{LocationHeader: false},
+			mockService:      mockProductService{product: &service.ProductDto{ID: mockID.String(), Name: "New Product", Price: money.New(150, ""), Stock: 5, Version: 1}},
+			expectedCode:     http.StatusCreated,
+			expectedLocation: "",
 		},
 		{
-			name: "Error - version url parameter is required",
-			mockService: mockProductService{
-				error: nil,
-			},
-			productID:    mockID.String(),
-			expectedCode: http.StatusBadRequest,
-			expectedBody: `{"error":"version url parameter is required"}`,
-			urlParams:    "", // No version provided
+			name:             "Location header is omitted on error",
+			cfg:              config.ProductsConfig{LocationHeader: true},
+			mockService:      mockProductService{error: errors.New("service unavailable")},
+			expectedCode:     http.StatusInternalServerError,
+			expectedLocation: "",
 		},
 	}
 
@@ -537,32 +654,1226 @@ func Test_ProductAPI_DeleteByID(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// given
 			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-			api := NewHandler(&tc.mockService, logger)
-			req := httptest.NewRequest(http.MethodDelete, "/api/v1/products/"+tc.productID+tc.urlParams, nil)
-			req.SetPathValue("id", tc.productID)
+			api := NewHandler(&tc.mockService, tc.cfg, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/products", strings.NewReader(`{"name":"New Product","price":150,"stock":5}`))
+			req.Header.Set("Content-Type", "application/json")
 			rr := httptest.NewRecorder()
-
 			// when
-			api.DeleteByID(rr, req)
-
+			api.Create(rr, req)
 			// then
 			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
-			assert.Equal(t, tc.expectedBody, rr.Body.String(), "response body should match")
+			assert.Equal(t, tc.expectedLocation, rr.Header().Get("Location"), "Location header should match")
 		})
 	}
 }
 
-func Test_ProductAPI_HealthCheck(t *testing.T) {
-	// given
-	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
-	api := NewHandler(nil, logger) // No service needed for health check
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/healthz", nil)
-	rr := httptest.NewRecorder()
-
-	// when
-	api.HealthCheck(rr, req)
-
-	// then
-	assert.Equal(t, http.StatusOK, rr.Code, "status code should be 200 OK")
-	assert.Empty(t, rr.Body.String(), "response body should be empty")
+func Test_ProductAPI_UpsertBySKU(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	product := &service.ProductDto{ID: mockID.String(), Name: "New Product", Price: money.New(150, ""), Stock: 5, Version: 1, SKU: "ERP-1001"}
+	testCases := []struct {
+		name             string
+		mockService      mockProductService
+		sku              string
+		requestBody      string
+		expectedCode     int
+		expectedBody     string
+		expectedLocation string
+	}{
+		{
+			name:             "Success - product created",
+			mockService:      mockProductService{product: product, created: true},
+			sku:              "ERP-1001",
+			requestBody:      `{"name":"New Product","price":"1.50","stock":5}`,
+			expectedCode:     http.StatusCreated,
+			expectedBody:     `{"id":"` + mockID.String() + `","name":"New Product","price":"1.50","stock":5,"version":1,"sku":"ERP-1001"}`,
+			expectedLocation: "/api/v1/products/" + mockID.String(),
+		},
+		{
+			name:         "Success - product updated",
+			mockService:  mockProductService{product: product},
+			sku:          "ERP-1001",
+			requestBody:  `{"name":"New Product","price":"1.50","stock":5}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"` + mockID.String() + `","name":"New Product","price":"1.50","stock":5,"version":1,"sku":"ERP-1001"}`,
+		},
+		{
+			name:         "Error - invalid SKU",
+			sku:          "ERP%201001",
+			requestBody:  `{"name":"New Product","price":"1.50","stock":5}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid SKU: ERP 1001, must be 1 to 64 letters, digits, dots, dashes or underscores","code":"INVALID_SKU"}`,
+		},
+		{
+			name:         "Error - SKU too long",
+			sku:          strings.Repeat("A", 65),
+			requestBody:  `{"name":"New Product","price":"1.50","stock":5}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid SKU: ` + strings.Repeat("A", 65) + `, must be 1 to 64 letters, digits, dots, dashes or underscores","code":"INVALID_SKU"}`,
+		},
+		{
+			name:         "Error - validation failed",
+			sku:          "ERP-1001",
+			requestBody:  `{"name":"","price":"1.50","stock":5}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"validation_errors":{"Name":"failed on rule: required"},"code":"VALIDATION_FAILED"}`,
+		},
+		{
+			name:         "Error - name already exists",
+			mockService:  mockProductService{error: producterrors.ErrProductAlreadyExists},
+			sku:          "ERP-1001",
+			requestBody:  `{"name":"New Product","price":"1.50","stock":5}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"A product with the same name already exists","code":"PRODUCT_ALREADY_EXISTS"}`,
+		},
+		{
+			name:         "Error - currency mismatch",
+			mockService:  mockProductService{error: producterrors.ErrCurrencyMismatch},
+			sku:          "ERP-1001",
+			requestBody:  `{"name":"New Product","price":"1.50","stock":5,"currency":"EUR"}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"Product with SKU ERP-1001 is priced in another currency","code":"CURRENCY_MISMATCH"}`,
+		},
+		{
+			name:         "Error - service error",
+			mockService:  mockProductService{error: errors.New("service unavailable")},
+			sku:          "ERP-1001",
+			requestBody:  `{"name":"New Product","price":"1.50","stock":5}`,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Failed to upsert product with SKU ERP-1001","code":"INTERNAL_ERROR"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.ProductsConfig{LocationHeader: true}, pconfig.DefaultBasePath, logger)
+			router := chi.NewRouter()
+			api.RegisterRoutes(router)
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/products/by-sku/"+tc.sku, strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			// when
+			router.ServeHTTP(rr, req)
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+			assert.Equal(t, tc.expectedLocation, rr.Header().Get("Location"), "Location header should match")
+			if tc.expectedCode < http.StatusBadRequest {
+				assert.Equal(t, `"1"`, rr.Header().Get("ETag"), "ETag header should match")
+			}
+		})
+	}
+}
+
+func Test_ProductAPI_CreateBatch(t *testing.T) {
+	firstID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	secondID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174001")
+	testCases := []struct {
+		name         string
+		mockService  mockProductService
+		query        string
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Success - all products created",
+			mockService: mockProductService{
+				products: []service.ProductDto{
+					{ID: firstID.String(), Name: "Toy", Price: money.New(100, ""), Stock: 10, Version: 1},
+					{ID: secondID.String(), Name: "Ball", Price: money.New(50, ""), Stock: 5, Version: 1},
+				},
+			},
+			requestBody:  `[{"name":"Toy","price":100,"stock":10},{"name":"Ball","price":50,"stock":5}]`,
+			expectedCode: http.StatusMultiStatus,
+			expectedBody: `{"created":2,"failed":0,"items":[
+				{"index":0,"status":201,"id":"` + firstID.String() + `"},
+				{"index":1,"status":201,"id":"` + secondID.String() + `"}]}`,
+		},
+		{
+			name: "Partial - invalid products are reported, valid ones created",
+			mockService: mockProductService{
+				products: []service.ProductDto{
+					{ID: firstID.String(), Name: "Toy", Price: money.New(100, ""), Stock: 10, Version: 1},
+					{ID: secondID.String(), Name: "Ball", Price: money.New(50, ""), Stock: 5, Version: 1},
+				},
+			},
+			requestBody:  `[{"name":"Toy","price":100,"stock":10},{"name":"","price":-1,"stock":5},{"name":"Ball","price":50,"stock":5}]`,
+			expectedCode: http.StatusMultiStatus,
+			expectedBody: `{"created":2,"failed":1,"items":[
+				{"index":0,"status":201,"id":"` + firstID.String() + `"},
+				{"index":1,"status":400,"validation_errors":{"Name":"failed on rule: required","Price":"failed on rule: min"}},
+				{"index":2,"status":201,"id":"` + secondID.String() + `"}]}`,
+		},
+		{
+			name: "Partial - no valid products, the service is not called",
+			mockService: mockProductService{
+				error: errors.New("must not be called"),
+			},
+			requestBody:  `[{"name":"","price":100,"stock":10}]`,
+			expectedCode: http.StatusMultiStatus,
+			expectedBody: `{"created":0,"failed":1,"items":[
+				{"index":0,"status":400,"validation_errors":{"Name":"failed on rule: required"}}]}`,
+		},
+		{
+			name: "Dry run - products checked, none created",
+			mockService: mockProductService{
+				products: []service.ProductDto{{ID: firstID.String(), Name: "Toy", Price: money.New(100, ""), Stock: 10, Version: 1}},
+				checks:   []error{nil, producterrors.ErrProductAlreadyExists},
+			},
+			query:        "?dry_run=true",
+			requestBody:  `[{"name":"Toy","price":100,"stock":10},{"name":"","price":-1,"stock":5},{"name":"Ball","price":50,"stock":5}]`,
+			expectedCode: http.StatusMultiStatus,
+			expectedBody: `{"created":1,"failed":2,"dry_run":true,"items":[
+				{"index":0,"status":201},
+				{"index":1,"status":400,"validation_errors":{"Name":"failed on rule: required","Price":"failed on rule: min"}},
+				{"index":2,"status":409,"validation_errors":{"Name":"already exists"}}]}`,
+		},
+		{
+			name: "Dry run - service error",
+			mockService: mockProductService{
+				error: errors.New("service unavailable"),
+			},
+			query:        "?dry_run=true",
+			requestBody:  `[{"name":"Toy","price":100,"stock":10}]`,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Failed to check products","code":"INTERNAL_ERROR"}`,
+		},
+		{
+			name:         "Error - batch too large",
+			requestBody:  `[{"name":"A","price":1,"stock":1},{"name":"B","price":1,"stock":1},{"name":"C","price":1,"stock":1},{"name":"D","price":1,"stock":1}]`,
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: `{"error":"Batch of 4 products exceeds the max size of 3","code":"BATCH_TOO_LARGE"}`,
+		},
+		{
+			name:         "Error - empty batch",
+			requestBody:  `[]`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Batch must contain at least one product","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:         "Error - invalid json",
+			requestBody:  `{"name":"Toy","price":100,"stock":10}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid request body","code":"BAD_REQUEST"}`,
+		},
+		{
+			name: "Error - service error",
+			mockService: mockProductService{
+				error: errors.New("service unavailable"),
+			},
+			requestBody:  `[{"name":"Toy","price":100,"stock":10}]`,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Failed to create products","code":"INTERNAL_ERROR"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.ProductsConfig{BatchMaxSize: 3}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/products/batch"+tc.query, strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			// when
+			api.CreateBatch(rr, req)
+			// then
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_ProductAPI_BodyTooLarge(t *testing.T) {
+	const maxBodyBytes = 1024
+	mockID := "123e4567-e89b-12d3-a456-426614174000"
+	name := strings.Repeat("a", maxBodyBytes)
+	expectedBody := `{"error":"Request body too large: maximum is 1024 bytes","code":"REQUEST_ENTITY_TOO_LARGE"}`
+	mockService := mockProductService{error: errors.New("must not be called")}
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	api := NewHandler(&mockService, config.ProductsConfig{BatchMaxSize: 3}, pconfig.DefaultBasePath, logger)
+
+	testCases := []struct {
+		name        string
+		method      string
+		target      string
+		handler     http.HandlerFunc
+		requestBody string
+	}{
+		{
+			name:        "Create",
+			method:      http.MethodPost,
+			target:      "/api/v1/products",
+			handler:     api.Create,
+			requestBody: `{"name":"` + name + `","price":100,"stock":10}`,
+		},
+		{
+			name:        "CreateBatch",
+			method:      http.MethodPost,
+			target:      "/api/v1/products/batch",
+			handler:     api.CreateBatch,
+			requestBody: `[{"name":"` + name + `","price":100,"stock":10}]`,
+		},
+		{
+			name:        "Update",
+			method:      http.MethodPut,
+			target:      "/api/v1/products/" + mockID,
+			handler:     api.Update,
+			requestBody: `{"name":"` + name + `","price":100,"stock":10,"version":1}`,
+		},
+		{
+			name:        "UpdateStock",
+			method:      http.MethodPut,
+			target:      "/api/v1/products/" + mockID + "/stock",
+			handler:     api.UpdateStock,
+			requestBody: `{"stock":10,"version":1,"pad":"` + name + `"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.requestBody))
+			req.SetPathValue("id", mockID)
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			// when
+			web.MaxBodySizeMiddleware(maxBodyBytes)(tc.handler).ServeHTTP(rr, req)
+			// then
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "status code should match")
+			assert.JSONEq(t, expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_ProductAPI_StrictJSON(t *testing.T) {
+	mockID := "123e4567-e89b-12d3-a456-426614174000"
+	expectedBody := `{"error":"Unknown field in request body: \"stok\"","code":"BAD_REQUEST"}`
+	mockService := mockProductService{error: errors.New("must not be called")}
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	api := NewHandler(&mockService, config.ProductsConfig{BatchMaxSize: 3, StrictJSON: true}, pconfig.DefaultBasePath, logger)
+
+	testCases := []struct {
+		name        string
+		method      string
+		handler     http.HandlerFunc
+		requestBody string
+	}{
+		{name: "Create", method: http.MethodPost, handler: api.Create, requestBody: `{"name":"test","price":100,"stok":10}`},
+		{name: "CreateBatch", method: http.MethodPost, handler: api.CreateBatch, requestBody: `[{"name":"test","price":100,"stok":10}]`},
+		{name: "Update", method: http.MethodPut, handler: api.Update, requestBody: `{"name":"test","price":100,"stok":10,"version":1}`},
+		{name: "Patch", method: http.MethodPatch, handler: api.Patch, requestBody: `{"stok":10,"version":1}`},
+		{name: "UpdateStock", method: http.MethodPut, handler: api.UpdateStock, requestBody: `{"stok":10,"version":1}`},
+		{name: "AdjustStock", method: http.MethodPost, handler: api.AdjustStock, requestBody: `{"delta":1,"stok":10,"version":1}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest(tc.method, "/api/v1/products/"+mockID, strings.NewReader(tc.requestBody))
+			req.SetPathValue("id", mockID)
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			// when
+			tc.handler.ServeHTTP(rr, req)
+			// then
+			assert.Equal(t, http.StatusBadRequest, rr.Code, "status code should match")
+			assert.JSONEq(t, expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_ProductAPI_LenientJSON(t *testing.T) {
+	// given
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	mockService := mockProductService{product: &service.ProductDto{ID: mockID.String(), Name: "test", Price: money.New(100, ""), Stock: 10, Version: 1}}
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	api := NewHandler(&mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/products", strings.NewReader(`{"name":"test","price":100,"stock":10,"stok":10}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	// when
+	api.Create(rr, req)
+
+	// then
+	assert.Equal(t, http.StatusCreated, rr.Code, "unknown fields should be ignored unless strict JSON is enabled")
+}
+
+func Test_ProductAPI_BasePath(t *testing.T) {
+	mockID := "123e4567-e89b-12d3-a456-426614174000"
+	testCases := []struct {
+		name             string
+		basePath         string
+		method           string
+		target           string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{name: "default base path", basePath: pconfig.DefaultBasePath, method: http.MethodGet, target: "/api/v1/products/" + mockID, expectedCode: http.StatusOK},
+		{name: "custom base path", basePath: "/api/v2", method: http.MethodGet, target: "/api/v2/products/" + mockID, expectedCode: http.StatusOK},
+		{name: "default base path of a custom one", basePath: "/api/v2", method: http.MethodGet, target: "/api/v1/products/" + mockID, expectedCode: http.StatusNotFound},
+		{name: "nested base path", basePath: "/shop/api/v2", method: http.MethodGet, target: "/shop/api/v2/products/" + mockID, expectedCode: http.StatusOK},
+		{
+			name:             "location below the custom base path",
+			basePath:         "/api/v2",
+			method:           http.MethodPost,
+			target:           "/api/v2/products",
+			expectedCode:     http.StatusCreated,
+			expectedLocation: "/api/v2/products/" + mockID,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockService := mockProductService{product: &service.ProductDto{ID: mockID, Name: "Product 1", Price: money.New(100, ""), Stock: 30, Version: 1}}
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&mockService, config.ProductsConfig{LocationHeader: true}, tc.basePath, logger)
+			router := chi.NewRouter()
+			api.RegisterRoutes(router)
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(`{"name":"Product 1","price":100,"stock":30}`))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			// when
+			router.ServeHTTP(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			assert.Equal(t, tc.expectedLocation, rr.Header().Get("Location"))
+		})
+	}
+}
+
+func Test_ProductAPI_RequireJSON(t *testing.T) {
+	mockID := "123e4567-e89b-12d3-a456-426614174000"
+	mockService := mockProductService{product: &service.ProductDto{ID: mockID, Name: "Product 1", Price: money.New(100, ""), Stock: 30, Version: 1}}
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	api := NewHandler(&mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+	router := chi.NewRouter()
+	api.RegisterRoutes(router)
+
+	testCases := []struct {
+		name         string
+		method       string
+		target       string
+		contentType  string
+		requestBody  string
+		expectedCode int
+	}{
+		{
+			name:         "Create with JSON",
+			method:       http.MethodPost,
+			target:       "/api/v1/products",
+			contentType:  "application/json",
+			requestBody:  `{"name":"Product 1","price":100,"stock":30}`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Create with form data",
+			method:       http.MethodPost,
+			target:       "/api/v1/products",
+			contentType:  "application/x-www-form-urlencoded",
+			requestBody:  "name=Product+1&price=100&stock=30",
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:         "UpdateStock with JSON and charset",
+			method:       http.MethodPut,
+			target:       "/api/v1/products/" + mockID + "/stock",
+			contentType:  "application/json; charset=utf-8",
+			requestBody:  `{"stock":30,"version":1}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "UpdateStock without content type",
+			method:       http.MethodPut,
+			target:       "/api/v1/products/" + mockID + "/stock",
+			requestBody:  `{"stock":30,"version":1}`,
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:         "Patch with plain text",
+			method:       http.MethodPatch,
+			target:       "/api/v1/products/" + mockID,
+			contentType:  "text/plain",
+			requestBody:  `{"stock":30,"version":1}`,
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.requestBody))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			rr := httptest.NewRecorder()
+			// when
+			router.ServeHTTP(rr, req)
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			if tc.expectedCode == http.StatusUnsupportedMediaType {
+				assert.Contains(t, rr.Body.String(), `"code":"UNSUPPORTED_MEDIA_TYPE"`)
+			}
+		})
+	}
+}
+
+func Test_ProductAPI_FindByID_ETag(t *testing.T) {
+	// given
+	mockID := "123e4567-e89b-12d3-a456-426614174000"
+	mockService := mockProductService{product: &service.ProductDto{ID: mockID, Name: "Product 1", Price: money.New(100, ""), Stock: 30, Version: 7}}
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	api := NewHandler(&mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/products/"+mockID, nil)
+	req.SetPathValue("id", mockID)
+	rr := httptest.NewRecorder()
+
+	// when
+	api.FindByID(rr, req)
+
+	// then
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"7"`, rr.Header().Get("ETag"), "the ETag should be the version of the product")
+}
+
+func Test_ProductAPI_IfMatch(t *testing.T) {
+	mockID := "123e4567-e89b-12d3-a456-426614174000"
+	current := &service.ProductDto{ID: mockID, Name: "Product 1", Price: money.New(100, ""), Stock: 30, Version: 3}
+	conflictBody := `{"error":"Product with ID ` + mockID + ` has been modified by another user","code":"OPTIMISTIC_LOCK"}`
+
+	testCases := []struct {
+		name         string
+		method       string
+		query        string
+		ifMatch      string
+		requestBody  string
+		expectedCode int
+		expectedETag string
+		expectedBody string
+	}{
+		{
+			name:         "PUT - matching If-Match without a version in the body",
+			method:       http.MethodPut,
+			ifMatch:      `"3"`,
+			requestBody:  `{"name":"Product 2","price":200,"stock":10}`,
+			expectedCode: http.StatusOK,
+			expectedETag: `"4"`,
+		},
+		{
+			name:         "PUT - matching If-Match overrides a stale version in the body",
+			method:       http.MethodPut,
+			ifMatch:      `"3"`,
+			requestBody:  `{"name":"Product 2","price":200,"stock":10,"version":1}`,
+			expectedCode: http.StatusOK,
+			expectedETag: `"4"`,
+		},
+		{
+			name:         "PUT - mismatching If-Match",
+			method:       http.MethodPut,
+			ifMatch:      `"2"`,
+			requestBody:  `{"name":"Product 2","price":200,"stock":10,"version":3}`,
+			expectedCode: http.StatusPreconditionFailed,
+			expectedBody: conflictBody,
+		},
+		{
+			name:         "PUT - stale version in the body without If-Match",
+			method:       http.MethodPut,
+			requestBody:  `{"name":"Product 2","price":200,"stock":10,"version":2}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: conflictBody,
+		},
+		{
+			name:         "PUT - malformed If-Match",
+			method:       http.MethodPut,
+			ifMatch:      `W/"3"`,
+			requestBody:  `{"name":"Product 2","price":200,"stock":10,"version":3}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid If-Match header: W/\"3\"","code":"BAD_REQUEST"}`,
+		},
+		{
+			name:         "PATCH - matching If-Match",
+			method:       http.MethodPatch,
+			ifMatch:      `"3"`,
+			requestBody:  `{"price":250}`,
+			expectedCode: http.StatusOK,
+			expectedETag: `"4"`,
+		},
+		{
+			name:         "PATCH - mismatching If-Match",
+			method:       http.MethodPatch,
+			ifMatch:      `"4"`,
+			requestBody:  `{"price":250}`,
+			expectedCode: http.StatusPreconditionFailed,
+			expectedBody: conflictBody,
+		},
+		{
+			name:         "PATCH - any version falls back to the body",
+			method:       http.MethodPatch,
+			ifMatch:      "*",
+			requestBody:  `{"price":250,"version":3}`,
+			expectedCode: http.StatusOK,
+			expectedETag: `"4"`,
+		},
+		{
+			name:         "DELETE - matching If-Match without a version parameter",
+			method:       http.MethodDelete,
+			ifMatch:      `"3"`,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "DELETE - mismatching If-Match",
+			method:       http.MethodDelete,
+			ifMatch:      `"1"`,
+			query:        "?version=3",
+			expectedCode: http.StatusPreconditionFailed,
+			expectedBody: conflictBody,
+		},
+		{
+			name:         "DELETE - version parameter without If-Match",
+			method:       http.MethodDelete,
+			query:        "?version=3",
+			expectedCode: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockService := versionCheckingService{mockProductService{product: current}}
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(tc.method, "/api/v1/products/"+mockID+tc.query, strings.NewReader(tc.requestBody))
+			req.SetPathValue("id", mockID)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+
+			// when
+			switch tc.method {
+			case http.MethodPut:
+				api.Update(rr, req)
+			case http.MethodPatch:
+				api.Patch(rr, req)
+			case http.MethodDelete:
+				api.DeleteByID(rr, req)
+			}
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.Equal(t, tc.expectedETag, rr.Header().Get("ETag"), "ETag should match")
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+			}
+		})
+	}
+}
+
+func Test_ProductAPI_Update(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	testCases := []struct {
+		name         string
+		mockService  mockProductService
+		productID    string
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Success - product updated",
+			mockService: mockProductService{
+				product: &service.ProductDto{ID: mockID.String(), Name: "Updated Product", Price: money.New(200, ""), Stock: 15, Version: 1},
+				error:   nil,
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"name":"Updated Product","price":200,"stock":15,"version":1}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"` + mockID.String() + `","name":"Updated Product","price":"2.00","stock":15, "version":1}`,
+		},
+		{
+			name: "Error - validation failed",
+			mockService: mockProductService{
+				product: nil,
+				error:   nil,
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"name":"","price":-100,"stock":-5,"version":1}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"validation_errors":{"Name":"failed on rule: required","Price":"failed on rule: min","Stock":"failed on rule: min"},"code":"VALIDATION_FAILED"}`,
+		},
+		{
+			name: "Error - invalid json",
+			mockService: mockProductService{
+				product: nil,
+				error:   nil,
+			},
+			productID:    mockID.String(),
+			requestBody:  `invalid json`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid request body","code":"BAD_REQUEST"}`,
+		},
+		{
+			name: "Error - product not found",
+			mockService: mockProductService{
+				product: nil,
+				error:   producterrors.ErrProductNotFound,
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"name":"Nonexistent Product","price":100,"stock":10,"version":1}`,
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found","code":"PRODUCT_NOT_FOUND"}`,
+		},
+		{
+			name: "Error - version conflict",
+			mockService: mockProductService{
+				error: producterrors.ErrOptimisticLock,
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"name":"Stale Product","price":100,"stock":10,"version":1}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` has been modified by another user","code":"OPTIMISTIC_LOCK"}`,
+		},
+		{
+			name: "Error - name already exists",
+			mockService: mockProductService{
+				error: producterrors.ErrProductAlreadyExists,
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"name":"Taken Product","price":100,"stock":10,"version":1}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"A product with the same name already exists","code":"PRODUCT_ALREADY_EXISTS"}`,
+		},
+		{
+			name: "Error - service error",
+			mockService: mockProductService{
+				product: nil,
+				error:   errors.New("service unavailable"),
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"name":"Another Product","price":150,"stock":5,"version":1}`,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Failed to update product with ID ` + mockID.String() + `","code":"INTERNAL_ERROR"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/products/"+tc.productID, nil)
+			req.Body = io.NopCloser(strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.SetPathValue("id", tc.productID)
+			rr := httptest.NewRecorder()
+
+			// when
+			api.Update(rr, req)
+
+			// then
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+
+}
+
+func Test_ProductAPI_Patch(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	current := &service.ProductDto{ID: mockID.String(), Name: "Toy", Price: money.New(100, ""), Stock: 10, Version: 1}
+	testCases := []struct {
+		name         string
+		mockService  mockProductService
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Success - only the price patched",
+			mockService:  mockProductService{product: current},
+			requestBody:  `{"price":"1.50","version":1}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"` + mockID.String() + `","name":"Toy","price":"1.50","stock":10,"version":1}`,
+		},
+		{
+			name:         "Success - only the name patched",
+			mockService:  mockProductService{product: current},
+			requestBody:  `{"name":"Renamed Toy","version":1}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"` + mockID.String() + `","name":"Renamed Toy","price":"1.00","stock":10,"version":1}`,
+		},
+		{
+			name:         "Success - no-op patch",
+			mockService:  mockProductService{product: current},
+			requestBody:  `{"version":1}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"` + mockID.String() + `","name":"Toy","price":"1.00","stock":10,"version":1}`,
+		},
+		{
+			name:         "Error - provided fields validated",
+			mockService:  mockProductService{product: current},
+			requestBody:  `{"name":"","stock":-1,"version":1}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"validation_errors":{"Name":"failed on rule: min","Stock":"failed on rule: min"},"code":"VALIDATION_FAILED"}`,
+		},
+		{
+			name:         "Error - zero price and stock rejected like in a full update",
+			mockService:  mockProductService{product: current},
+			requestBody:  `{"price":"0.00","stock":0,"version":1}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"validation_errors":{"Price":"failed on rule: min","Stock":"failed on rule: min"},"code":"VALIDATION_FAILED"}`,
+		},
+		{
+			name:         "Error - version missing",
+			mockService:  mockProductService{product: current},
+			requestBody:  `{"price":150}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"validation_errors":{"Version":"failed on rule: required"},"code":"VALIDATION_FAILED"}`,
+		},
+		{
+			name:         "Error - product not found",
+			mockService:  mockProductService{error: producterrors.ErrProductNotFound},
+			requestBody:  `{"price":150,"version":1}`,
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found","code":"PRODUCT_NOT_FOUND"}`,
+		},
+		{
+			name:         "Error - version conflict",
+			mockService:  mockProductService{error: producterrors.ErrOptimisticLock},
+			requestBody:  `{"price":150,"version":1}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` has been modified by another user","code":"OPTIMISTIC_LOCK"}`,
+		},
+		{
+			name:         "Error - name already exists",
+			mockService:  mockProductService{error: producterrors.ErrProductAlreadyExists},
+			requestBody:  `{"name":"Taken Product","version":1}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"A product with the same name already exists","code":"PRODUCT_ALREADY_EXISTS"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1/products/"+mockID.String(), strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.SetPathValue("id", mockID.String())
+			rr := httptest.NewRecorder()
+
+			// when
+			api.Patch(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_ProductAPI_UpdateStock(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	testCases := []struct {
+		name         string
+		mockService  mockProductService
+		productID    string
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Success - stock updated",
+			mockService: mockProductService{
+				product: &service.ProductDto{ID: mockID.String(), Name: "Product 1", Price: money.New(100, ""), Stock: 30, Version: 1},
+				error:   nil,
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"stock":30,"version":1}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"` + mockID.String() + `","name":"Product 1","price":"1.00","stock":30, "version":1}`,
+		},
+		{
+			name: "Error - validation failed",
+			mockService: mockProductService{
+				product: nil,
+				error:   nil,
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"stock":-10,"version":1}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"validation_errors":{"Stock":"failed on rule: min"},"code":"VALIDATION_FAILED"}`,
+		},
+		{
+			name: "Error - service error",
+			mockService: mockProductService{
+				product: nil,
+				error:   errors.New("service unavailable"),
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"stock":25,"version":1}`,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Failed to update stock for product with ID ` + mockID.String() + `","code":"INTERNAL_ERROR"}`,
+		},
+		{
+			name: "Error - product not found",
+			mockService: mockProductService{
+				product: nil,
+				error:   producterrors.ErrProductNotFound,
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"stock":50,"version":1}`,
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found","code":"PRODUCT_NOT_FOUND"}`,
+		},
+		{
+			name: "Error - version conflict",
+			mockService: mockProductService{
+				error: producterrors.ErrOptimisticLock,
+			},
+			productID:    mockID.String(),
+			requestBody:  `{"stock":50,"version":1}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` has been modified by another user","code":"OPTIMISTIC_LOCK"}`,
+		},
+		{
+			name: "Error - invalid json",
+			mockService: mockProductService{
+				product: nil,
+				error:   nil,
+			},
+			productID:    mockID.String(),
+			requestBody:  `invalid json`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid request body","code":"BAD_REQUEST"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/products/"+tc.productID+"/stock", nil)
+			req.Body = io.NopCloser(strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.SetPathValue("id", tc.productID)
+			rr := httptest.NewRecorder()
+
+			// when
+			api.UpdateStock(rr, req)
+
+			// then
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_ProductAPI_AdjustStock(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	testCases := []struct {
+		name         string
+		mockService  mockProductService
+		requestBody  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Success - stock adjusted",
+			mockService: mockProductService{
+				product: &service.ProductDto{ID: mockID.String(), Name: "Product 1", Price: money.New(100, ""), Stock: 25, Version: 2},
+			},
+			requestBody:  `{"delta":-5,"version":1}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"id":"` + mockID.String() + `","name":"Product 1","price":"1.00","stock":25, "version":2}`,
+		},
+		{
+			name:         "Error - zero delta",
+			requestBody:  `{"delta":0,"version":1}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"validation_errors":{"Delta":"failed on rule: required"},"code":"VALIDATION_FAILED"}`,
+		},
+		{
+			name:         "Error - insufficient stock",
+			mockService:  mockProductService{error: producterrors.ErrInsufficientStock},
+			requestBody:  `{"delta":-50,"version":1}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Stock of product with ID ` + mockID.String() + ` can't go below zero","code":"INSUFFICIENT_STOCK"}`,
+		},
+		{
+			name:         "Error - stock overflow",
+			mockService:  mockProductService{error: producterrors.ErrStockOverflow},
+			requestBody:  `{"delta":2147483647,"version":1}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Stock of product with ID ` + mockID.String() + ` can't exceed 2147483647","code":"STOCK_OVERFLOW"}`,
+		},
+		{
+			name:         "Error - product not found",
+			mockService:  mockProductService{error: producterrors.ErrProductNotFound},
+			requestBody:  `{"delta":5,"version":1}`,
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found","code":"PRODUCT_NOT_FOUND"}`,
+		},
+		{
+			name:         "Error - version conflict",
+			mockService:  mockProductService{error: producterrors.ErrOptimisticLock},
+			requestBody:  `{"delta":5,"version":1}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` has been modified by another user","code":"OPTIMISTIC_LOCK"}`,
+		},
+		{
+			name:         "Error - service error",
+			mockService:  mockProductService{error: errors.New("service unavailable")},
+			requestBody:  `{"delta":5,"version":1}`,
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Failed to adjust stock for product with ID ` + mockID.String() + `","code":"INTERNAL_ERROR"}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/products/"+mockID.String()+"/stock/adjust", strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.SetPathValue("id", mockID.String())
+			rr := httptest.NewRecorder()
+
+			// when
+			api.AdjustStock(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_ProductAPI_DeleteByID(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	testCases := []struct {
+		name         string
+		mockService  mockProductService
+		productID    string
+		expectedCode int
+		expectedBody string
+		urlParams    string
+	}{
+		{
+			name: "Success - product deleted",
+			mockService: mockProductService{
+				error: nil,
+			},
+			productID:    mockID.String(),
+			expectedCode: http.StatusNoContent,
+			expectedBody: "",
+			urlParams:    "?version=1",
+		},
+		{
+			name: "Error - product not found",
+			mockService: mockProductService{
+				error: producterrors.ErrProductNotFound,
+			},
+			productID:    mockID.String(),
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found","code":"PRODUCT_NOT_FOUND"}`,
+			urlParams:    "?version=1",
+		},
+		{
+			name: "Error - version conflict",
+			mockService: mockProductService{
+				error: producterrors.ErrOptimisticLock,
+			},
+			productID:    mockID.String(),
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` has been modified by another user","code":"OPTIMISTIC_LOCK"}`,
+			urlParams:    "?version=1",
+		},
+		{
+			name: "Error - service error",
+			mockService: mockProductService{
+				error: errors.New("service unavailable"),
+			},
+			productID:    mockID.String(),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Failed to delete product with ID ` + mockID.String() + `","code":"INTERNAL_ERROR"}`,
+			urlParams:    "?version=1",
+		},
+		{
+			name: "Error - version url parameter is required",
+			mockService: mockProductService{
+				error: nil,
+			},
+			productID:    mockID.String(),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"version url parameter is required","code":"BAD_REQUEST"}`,
+			urlParams:    "", // No version provided
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/products/"+tc.productID+tc.urlParams, nil)
+			req.SetPathValue("id", tc.productID)
+			rr := httptest.NewRecorder()
+
+			// when
+			api.DeleteByID(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.Equal(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_ProductAPI_PriceHistory(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	week := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name         string
+		mockService  mockProductService
+		query        string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Success - weekly buckets",
+			mockService: mockProductService{
+				history: &service.PriceHistoryDto{
+					ProductID: mockID.String(),
+					Interval:  service.IntervalWeek,
+					From:      from,
+					To:        to,
+					Buckets:   []service.PriceBucketDto{{Start: week, MinPrice: 100, MaxPrice: 200, AvgPrice: 150, Changes: 2}},
+				},
+			},
+			query:        "?interval=week&from=2025-01-01T00:00:00Z&to=2025-01-15T00:00:00Z",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"product_id":"` + mockID.String() + `","interval":"week","from":"2025-01-01T00:00:00Z","to":"2025-01-15T00:00:00Z",
+				"buckets":[{"start":"2025-01-06T00:00:00Z","min_price":100,"max_price":200,"avg_price":150,"changes":2}]}`,
+		},
+		{
+			name:         "Error - malformed from",
+			query:        "?from=yesterday",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid from: yesterday, must be an RFC 3339 time","code":"INVALID_TIME_RANGE"}`,
+		},
+		{
+			name:         "Error - invalid interval",
+			mockService:  mockProductService{error: producterrors.ErrInvalidInterval},
+			query:        "?interval=month",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid interval: month, must be day or week","code":"INVALID_INTERVAL"}`,
+		},
+		{
+			name:         "Error - invalid time range",
+			mockService:  mockProductService{error: producterrors.ErrInvalidTimeRange},
+			query:        "?from=2024-01-01T00:00:00Z&to=2025-01-15T00:00:00Z",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":"Invalid time range, it must not exceed 720h0m0s","code":"INVALID_TIME_RANGE"}`,
+		},
+		{
+			name:         "Error - product not found",
+			mockService:  mockProductService{error: producterrors.ErrProductNotFound},
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found","code":"PRODUCT_NOT_FOUND"}`,
+		},
+		{
+			name:         "Error - query timeout",
+			mockService:  mockProductService{error: producterrors.ErrQueryTimeout},
+			expectedCode: http.StatusGatewayTimeout,
+			expectedBody: `{"error":"Database query timed out","code":"QUERY_TIMEOUT"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.ProductsConfig{PriceHistoryMaxRange: 30 * 24 * time.Hour}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/products/"+mockID.String()+"/price-history"+tc.query, nil)
+			req.SetPathValue("id", mockID.String())
+			rr := httptest.NewRecorder()
+
+			// when
+			api.PriceHistory(rr, req)
+
+			// then
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
+}
+
+func Test_ProductAPI_History(t *testing.T) {
+	mockID, _ := uuid.Parse("123e4567-e89b-12d3-a456-426614174000")
+	created := time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)
+	deleted := created.Add(time.Hour)
+	testCases := []struct {
+		name         string
+		mockService  mockProductService
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Success - created and deleted",
+			mockService: mockProductService{
+				changes: &service.ProductHistoryDto{
+					ProductID: mockID.String(),
+					Changes: []service.ProductChangeDto{
+						{Action: "create", Version: 1, ChangedAt: created, New: &service.ProductValuesDto{Name: "Toy", Price: 100, Stock: 10}},
+						{Action: "delete", Version: 1, ChangedAt: deleted, Old: &service.ProductValuesDto{Name: "Toy", Price: 100, Stock: 10}},
+					},
+				},
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"product_id":"` + mockID.String() + `","changes":[
+				{"action":"create","version":1,"changed_at":"2025-01-06T10:00:00Z","new":{"name":"Toy","price":100,"stock":10}},
+				{"action":"delete","version":1,"changed_at":"2025-01-06T11:00:00Z","old":{"name":"Toy","price":100,"stock":10}}]}`,
+		},
+		{
+			name:         "Error - product not found",
+			mockService:  mockProductService{error: producterrors.ErrProductNotFound},
+			expectedCode: http.StatusNotFound,
+			expectedBody: `{"error":"Product with ID ` + mockID.String() + ` not found","code":"PRODUCT_NOT_FOUND"}`,
+		},
+		{
+			name:         "Error - internal server error",
+			mockService:  mockProductService{error: errors.New("db error")},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `{"error":"Failed to retrieve history of product with ID ` + mockID.String() + `","code":"INTERNAL_ERROR"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+			api := NewHandler(&tc.mockService, config.ProductsConfig{}, pconfig.DefaultBasePath, logger)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/products/"+mockID.String()+"/history", nil)
+			req.SetPathValue("id", mockID.String())
+			rr := httptest.NewRecorder()
+
+			// when
+			api.History(rr, req)
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code, "status code should match")
+			assert.JSONEq(t, tc.expectedBody, rr.Body.String(), "response body should match")
+		})
+	}
 }
@@ -2,24 +2,21 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"log/slog"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/Nerzal/gocloak/v13"
 	"github.com/abgdnv/gocommerce/pkg/bootstrap"
 	"github.com/abgdnv/gocommerce/pkg/config/configloader"
+	"github.com/abgdnv/gocommerce/pkg/server"
 	"github.com/abgdnv/gocommerce/pkg/telemetry"
 	"github.com/abgdnv/gocommerce/user_service/internal/app"
 	"github.com/abgdnv/gocommerce/user_service/internal/config"
-	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
@@ -45,10 +42,9 @@ func run(ctx context.Context) error {
 	if cfgErr != nil {
 		return fmt.Errorf("failed to load configuration: %w", cfgErr)
 	}
-	log.Printf("Configuration loaded: %v", cfg)
-
-	logger := bootstrap.NewLogger(cfg.Log.Level)
+	logger := bootstrap.NewLogger(cfg.Log.Level, cfg.Log.Format, cfg.Log.AddSource)
 	slog.SetDefault(logger)
+	bootstrap.LogEffectiveConfig(logger, cfg)
 
 	// create tracer provider
 	tracerProvider, err := telemetry.NewTracerProvider(ctx, serviceName, cfg.Telemetry)
@@ -57,98 +53,60 @@ func run(ctx context.Context) error {
 		return err
 	}
 
-	pprofServer, grpcServer, grpcHealth, err := setupServers(ctx, logger, cfg)
+	pprofServer, grpcServer, grpcHealth, healthUpdater, err := setupServers(ctx, logger, cfg)
 	if err != nil {
 		return err
 	}
 
-	g, gCtx := errgroup.WithContext(ctx)
-
-	// Start the gRPC server
-	g.Go(func() error {
-		grpcAddr := ":" + cfg.GRPC.Port
-		lis, err := net.Listen("tcp", grpcAddr)
-		if err != nil {
-			return fmt.Errorf("failed to listen on gRPC port: %w", err)
-		}
-		logger.Info("gRPC server listening", slog.String("addr", grpcAddr))
-		return grpcServer.Serve(lis)
-	})
-	// gracefully shutdown gRPC server on context cancellation
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down gRPC server...")
-		grpcHealth.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
-		stopped := make(chan struct{})
-		go func() {
-			grpcServer.GracefulStop()
-			grpcHealth.Shutdown()
-			close(stopped)
-		}()
-		select {
-		case <-stopped:
-			logger.Info("gRPC server stopped gracefully.")
-			return nil
-		case <-time.After(cfg.Shutdown.Timeout):
-			logger.Warn("gRPC server graceful stop timed out. Forcing stop.")
-			grpcServer.Stop()
-			return fmt.Errorf("grpc server graceful stop timed out")
-		}
-	})
-
-	// Start the pprof server if enabled
+	// components are shut down in reverse order: health updates and status first, then servers and tracer provider
+	components := []bootstrap.Component{
+		&bootstrap.FuncComponent{
+			ComponentName: "tracer provider",
+			ShutdownFn:    tracerProvider.Shutdown,
+		},
+		bootstrap.NewGRPCServerComponent("gRPC server", grpcServer, ":"+cfg.GRPC.Port, logger),
+		&bootstrap.FuncComponent{
+			ComponentName: "gRPC health server",
+			ShutdownFn: func(_ context.Context) error {
+				grpcHealth.Shutdown()
+				return nil
+			},
+		},
+		&bootstrap.FuncComponent{
+			ComponentName: "health updater",
+			StartFn:       healthUpdater.Run,
+		},
+	}
 	if cfg.PProf.Enabled {
-		g.Go(func() error {
-			logger.Info("Pprof server listening", slog.String("addr", pprofServer.Addr))
-			if err := pprofServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-				return fmt.Errorf("pprof server failed: %w", err)
-			}
-			return nil
-		})
-		// gracefully shutdown pprof server on context cancellation
-		g.Go(func() error {
-			<-gCtx.Done()
-			logger.Info("Shutting down pprof server...")
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-			defer cancel()
-			return pprofServer.Shutdown(shutdownCtx)
-		})
+		components = append(components, bootstrap.NewHTTPServerComponent("pprof server", pprofServer, logger))
 	}
-	// gracefully shutdown tracer provider
-	g.Go(func() error {
-		<-gCtx.Done()
-		logger.Info("Shutting down tracer provider")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
-		defer cancel()
-		if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
-			return fmt.Errorf("failed to shutdown tracer provider: %v", err)
-		}
-		return nil
-	})
 
-	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
-		return fmt.Errorf("errgroup encountered an error: %w", err)
+	if err := bootstrap.RunServers(ctx, components, cfg.Shutdown.Timeout, logger); err != nil {
+		return fmt.Errorf("failed to run servers: %w", err)
 	}
 	return nil
 }
 
 // setupServers initializes the HTTP, pprof, and gRPC servers with the provided database pool, logger, and configuration.
-func setupServers(ctx context.Context, logger *slog.Logger, cfg *config.Config) (*http.Server, *grpc.Server, *health.Server, error) {
+// The health updater keeps the gRPC health status in sync with the availability of Keycloak.
+func setupServers(ctx context.Context, logger *slog.Logger, cfg *config.Config) (*http.Server, *grpc.Server, *health.Server, *app.HealthUpdater, error) {
 	client := gocloak.NewClient(cfg.IdP.URL)
 	//fail-fast
 	_, err := client.LoginClient(ctx, cfg.IdP.ClientID, cfg.IdP.Secret, cfg.IdP.Realm)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("login failed: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("login failed: %w", err)
 	}
 	deps := app.SetupDependencies(logger, client, cfg.IdP.ClientID, cfg.IdP.Secret, cfg.IdP.Realm)
-	grpcServer := app.SetupGrpcServer(deps, cfg.GRPC.ReflectionEnabled)
-	pprofServer := &http.Server{
-		Addr: cfg.PProf.Addr,
+	grpcServer, err := app.SetupGrpcServer(deps, cfg.GRPC)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to create gRPC server: %w", err)
 	}
+	pprofServer := server.NewPProfServer(cfg.PProf)
 
 	healthServer := health.NewServer()
 	grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
 	healthServer.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthUpdater := app.NewHealthUpdater(client, cfg.IdP, cfg.Health.Interval, healthServer, logger)
 
-	return pprofServer, grpcServer, healthServer, nil
+	return pprofServer, grpcServer, healthServer, healthUpdater, nil
 }
@@ -9,7 +9,6 @@ require (
 	github.com/abgdnv/gocommerce/pkg v0.0.0-20250729103738-5f97b90ff4b1
 	github.com/go-playground/validator/v10 v10.27.0
 	github.com/stretchr/testify v1.10.0
-	golang.org/x/sync v0.16.0
 	google.golang.org/grpc v1.74.2
 )
 
@@ -73,6 +72,7 @@ require (
 	go.yaml.in/yaml/v3 v3.0.4 // indirect
 	golang.org/x/crypto v0.40.0 // indirect
 	golang.org/x/net v0.42.0 // indirect
+	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/text v0.27.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250603155806-513f23925822 // indirect
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/abgdnv/gocommerce/user_service/internal/config"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// LoginClient is the subset of the gocloak client used to check that Keycloak is reachable.
+type LoginClient interface {
+	LoginClient(ctx context.Context, clientID, clientSecret, realm string, scopes ...string) (*gocloak.JWT, error)
+}
+
+// StatusSetter sets the serving status reported by the gRPC health server, *health.Server implements it.
+type StatusSetter interface {
+	SetServingStatus(service string, servingStatus grpc_health_v1.HealthCheckResponse_ServingStatus)
+}
+
+// HealthUpdater periodically logs in to Keycloak with the client credentials and updates the serving status:
+// NOT_SERVING while the login fails, SERVING once it succeeds again.
+type HealthUpdater struct {
+	client   LoginClient
+	idp      config.IdP
+	interval time.Duration
+	status   StatusSetter
+	logger   *slog.Logger
+	serving  bool
+}
+
+// NewHealthUpdater creates a new HealthUpdater, which checks Keycloak every interval.
+func NewHealthUpdater(client LoginClient, idp config.IdP, interval time.Duration, status StatusSetter, logger *slog.Logger) *HealthUpdater {
+	return &HealthUpdater{
+		client:   client,
+		idp:      idp,
+		interval: interval,
+		status:   status,
+		logger:   logger.With("component", "health"),
+		serving:  true,
+	}
+}
+
+// Run checks Keycloak right away and then every interval until the context is cancelled.
+func (u *HealthUpdater) Run(ctx context.Context) error {
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+	for {
+		u.check(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check logs in to Keycloak and sets the serving status of the server, logging only the status transitions.
+// A single check is limited by the interval, so a hanging Keycloak doesn't delay the next check.
+func (u *HealthUpdater) check(ctx context.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx, u.interval)
+	defer cancel()
+	_, err := u.client.LoginClient(checkCtx, u.idp.ClientID, u.idp.Secret, u.idp.Realm)
+	if ctx.Err() != nil {
+		// the service is shutting down, the health server reports NOT_SERVING then
+		return
+	}
+	if err != nil {
+		if u.serving {
+			u.logger.ErrorContext(ctx, "Keycloak is not reachable, reporting NOT_SERVING", "error", err)
+		}
+		u.serving = false
+		u.status.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+		return
+	}
+	if !u.serving {
+		u.logger.InfoContext(ctx, "Keycloak is reachable again, reporting SERVING")
+	}
+	u.serving = true
+	u.status.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/abgdnv/gocommerce/user_service/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// MockLoginClient is a mock implementation of the LoginClient interface.
+type MockLoginClient struct {
+	mock.Mock
+}
+
+func (m *MockLoginClient) LoginClient(ctx context.Context, clientID, clientSecret, realm string, _ ...string) (*gocloak.JWT, error) {
+	args := m.Called(ctx, clientID, clientSecret, realm)
+	token, _ := args.Get(0).(*gocloak.JWT)
+	return token, args.Error(1)
+}
+
+func TestHealthUpdater_check(t *testing.T) {
+	// given
+	idp := config.IdP{URL: "http://keycloak:8080", Realm: "gocommerce", ClientID: "gocommerce-api", Secret: "secret"}
+	client := new(MockLoginClient)
+	login := func(err error) {
+		var token *gocloak.JWT
+		if err == nil {
+			token = &gocloak.JWT{AccessToken: "token"}
+		}
+		client.On("LoginClient", mock.Anything, idp.ClientID, idp.Secret, idp.Realm).Return(token, err).Once()
+	}
+	server := health.NewServer()
+	updater := NewHealthUpdater(client, idp, time.Second, server, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	steps := []struct {
+		name     string
+		loginErr error
+		expected grpc_health_v1.HealthCheckResponse_ServingStatus
+	}{
+		{name: "keycloak is reachable", expected: grpc_health_v1.HealthCheckResponse_SERVING},
+		{name: "keycloak fails", loginErr: errors.New("connection refused"), expected: grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{name: "keycloak still fails", loginErr: errors.New("connection refused"), expected: grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{name: "keycloak recovers", expected: grpc_health_v1.HealthCheckResponse_SERVING},
+	}
+	for _, step := range steps {
+		login(step.loginErr)
+
+		// when
+		updater.check(context.Background())
+
+		// then
+		resp, err := server.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		require.NoError(t, err, step.name)
+		assert.Equal(t, step.expected, resp.Status, step.name)
+	}
+	client.AssertExpectations(t)
+}
+
+func TestHealthUpdater_Run(t *testing.T) {
+	// given
+	idp := config.IdP{URL: "http://keycloak:8080", Realm: "gocommerce", ClientID: "gocommerce-api", Secret: "secret"}
+	client := new(MockLoginClient)
+	client.On("LoginClient", mock.Anything, idp.ClientID, idp.Secret, idp.Realm).Return(nil, errors.New("connection refused"))
+	server := health.NewServer()
+	server.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+	updater := NewHealthUpdater(client, idp, 10*time.Millisecond, server, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	// when
+	go func() { done <- updater.Run(ctx) }()
+
+	// then
+	assert.Eventually(t, func() bool {
+		resp, err := server.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		return err == nil && resp.Status == grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 10*time.Millisecond, "the status should flip to NOT_SERVING when keycloak fails")
+	cancel()
+	assert.NoError(t, <-done, "Run should stop when the context is cancelled")
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/Nerzal/gocloak/v13"
 	pb "github.com/abgdnv/gocommerce/pkg/api/gen/go/user/v1"
+	pconfig "github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/abgdnv/gocommerce/pkg/server"
 	"github.com/abgdnv/gocommerce/user_service/internal/service"
 	grpcImpl "github.com/abgdnv/gocommerce/user_service/internal/transport/grpc"
@@ -26,12 +27,12 @@ func SetupDependencies(logger *slog.Logger, gocloak *gocloak.GoCloak, clientID,
 }
 
 // SetupGrpcServer initializes the gRPC server
-func SetupGrpcServer(deps *Dependencies, reflectionEnabled bool) *grpc.Server {
+func SetupGrpcServer(deps *Dependencies, cfg pconfig.GrpcServerConfig) (*grpc.Server, error) {
 	// Service registration function for gRPC server
 	userRegisterFunc := func(s *grpc.Server) {
 		userGRPCServer := grpcImpl.NewServer(deps.UserService)
 		pb.RegisterUserServiceServer(s, userGRPCServer)
 	}
-	// create a new gRPC server with reflection if enabled
-	return server.NewGRPCServer(reflectionEnabled, userRegisterFunc)
+	// create a new gRPC server with TLS, reflection and the connection age limit if configured
+	return server.NewGRPCServer(cfg, deps.Logger, userRegisterFunc)
 }
@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/abgdnv/gocommerce/pkg/config"
 	"github.com/abgdnv/gocommerce/pkg/config/configloader"
@@ -11,12 +13,33 @@ import (
 var _ configloader.Validator = (*Config)(nil)
 
 type Config struct {
+	// Env is the deployment environment, config.EnvProduction enforces the production guards.
+	// It's normalized with config.NormalizeEnv, so it's config.EnvProduction if not set.
+	Env       string                  `koanf:"env"`
 	Log       config.LogConfig        `koanf:"log"`
 	PProf     config.PProfConfig      `koanf:"pprof"`
 	GRPC      config.GrpcServerConfig `koanf:"grpc"`
 	IdP       IdP                     `koanf:"idp"`
 	Telemetry config.TelemetryConfig  `koanf:"telemetry"`
 	Shutdown  config.ShutdownConfig   `koanf:"shutdown"`
+	Health    HealthConfig            `koanf:"health"`
+}
+
+// HealthConfig holds the settings of the gRPC health status updates.
+type HealthConfig struct {
+	// Interval is the time between the checks of Keycloak, the service reports NOT_SERVING while it is not reachable.
+	Interval time.Duration `koanf:"interval"`
+}
+
+func (c *HealthConfig) Validate() error {
+	if c.Interval == 0 {
+		log.Println("Using default value for health interval")
+		c.Interval = 10 * time.Second
+	}
+	if c.Interval < 0 {
+		return fmt.Errorf("health interval must not be negative")
+	}
+	return nil
 }
 
 type IdP struct {
@@ -44,11 +67,15 @@ func (c *IdP) Validate() error {
 
 func (c *Config) String() string {
 	var b strings.Builder
+	b.WriteString(fmt.Sprintf("\nenv: %s\n", c.Env))
 	b.WriteString("\n--- Identity Provider ---\n")
 	b.WriteString(fmt.Sprintf("  idp.url: %s\n", c.IdP.URL))
 	b.WriteString(fmt.Sprintf("  idp.realm: %s\n", c.IdP.Realm))
 	b.WriteString(fmt.Sprintf("  idp.clientid: %s\n", c.IdP.ClientID))
+	b.WriteString(fmt.Sprintf("  idp.secret: %s\n", config.Mask(c.IdP.Secret)))
 	b.WriteString(c.GRPC.String())
+	b.WriteString("\n--- Health ---\n")
+	b.WriteString(fmt.Sprintf("  interval: %s\n", c.Health.Interval))
 	b.WriteString(c.Log.String())
 	b.WriteString(c.PProf.String())
 	b.WriteString(c.Telemetry.String())
@@ -67,6 +94,13 @@ func (c *Config) Validate() error {
 	if err := c.GRPC.Validate(); err != nil {
 		return err
 	}
+	if strings.TrimSpace(c.Env) == "" {
+		log.Println("Using default value for env:", config.EnvProduction)
+	}
+	c.Env = config.NormalizeEnv(c.Env)
+	if err := c.GRPC.ValidateEnv(c.Env); err != nil {
+		return err
+	}
 	if err := c.IdP.Validate(); err != nil {
 		return err
 	}
@@ -76,5 +110,8 @@ func (c *Config) Validate() error {
 	if err := c.Shutdown.Validate(); err != nil {
 		return err
 	}
+	if err := c.Health.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
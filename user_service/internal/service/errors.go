@@ -5,5 +5,6 @@ import "errors"
 var (
 	ErrUserAlreadyExists    = errors.New("user already exists")
 	ErrInvalidUserData      = errors.New("invalid user data")
+	ErrUserNotFound         = errors.New("user not found")
 	ErrIdPInteractionFailed = errors.New("identity provider interaction failed")
 )
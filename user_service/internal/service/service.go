@@ -17,6 +17,7 @@ type GoCloakClient interface {
 	CreateUser(ctx context.Context, token, realm string, user gocloak.User) (string, error)
 	SetPassword(ctx context.Context, token, userID, realm, password string, temporary bool) error
 	DeleteUser(ctx context.Context, token, realm, userID string) error
+	GetUserByID(ctx context.Context, token, realm, userID string) (*gocloak.User, error)
 }
 
 type UserService struct {
@@ -39,6 +40,12 @@ func (u *CreateUserDto) String() string {
 	return fmt.Sprintf("UserName: %s, FirstName: %s, LastName: %s, Email: %s", u.UserName, u.FirstName, u.LastName, u.Email)
 }
 
+// UserDto represents a Keycloak user as seen by other services, e.g. the recipient of the notifications of an order.
+type UserDto struct {
+	ID    string
+	Email string
+}
+
 func NewService(gocloak GoCloakClient, realm, clientID, secret string) *UserService {
 	return &UserService{
 		gocloak:  gocloak,
@@ -94,3 +101,28 @@ func (u *UserService) Register(ctx context.Context, userDto CreateUserDto) (*str
 
 	return &userID, nil
 }
+
+// GetUser returns the Keycloak user with the given ID.
+// Returns ErrUserNotFound if no user exists with the given ID.
+func (u *UserService) GetUser(ctx context.Context, userID string) (*UserDto, error) {
+	if userID == "" {
+		return nil, ErrInvalidUserData
+	}
+	token, err := u.gocloak.LoginClient(ctx, u.clientID, u.secret, u.realm)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to login", "error", err)
+		return nil, fmt.Errorf("%w: failed to login to Keycloak: %v", ErrIdPInteractionFailed, err)
+	}
+
+	user, err := u.gocloak.GetUserByID(ctx, token.AccessToken, u.realm, userID)
+	if err != nil {
+		var apiErr *gocloak.APIError
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil, ErrUserNotFound
+		}
+		slog.ErrorContext(ctx, "Failed to get user", "error", err)
+		return nil, fmt.Errorf("%w: failed to get user: %v", ErrIdPInteractionFailed, err)
+	}
+
+	return &UserDto{ID: gocloak.PString(user.ID), Email: gocloak.PString(user.Email)}, nil
+}
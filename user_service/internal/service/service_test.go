@@ -21,6 +21,9 @@ type mockGoCloakClient struct {
 
 	setPwdErr    error
 	deleteCalled bool
+
+	user    *gocloak.User
+	userErr error
 }
 
 func (m *mockGoCloakClient) LoginClient(context.Context, string, string, string, ...string) (*gocloak.JWT, error) {
@@ -40,6 +43,10 @@ func (m *mockGoCloakClient) DeleteUser(context.Context, string, string, string)
 	return nil
 }
 
+func (m *mockGoCloakClient) GetUserByID(context.Context, string, string, string) (*gocloak.User, error) {
+	return m.user, m.userErr
+}
+
 // TestUserService_Register tests the Register method of the UserService
 func TestUserService_Register(t *testing.T) {
 	ctx := context.Background()
@@ -145,3 +152,79 @@ func TestUserService_Register(t *testing.T) {
 		})
 	}
 }
+
+// TestUserService_GetUser tests the GetUser method of the UserService
+func TestUserService_GetUser(t *testing.T) {
+	ctx := context.Background()
+	successToken := &gocloak.JWT{AccessToken: "token"}
+
+	// given
+	tests := []struct {
+		name        string
+		mock        *mockGoCloakClient
+		userID      string
+		expected    *UserDto
+		expectedErr error
+	}{
+		{
+			name: "success",
+			mock: &mockGoCloakClient{
+				loginToken: successToken,
+				user:       &gocloak.User{ID: gocloak.StringP("uid"), Email: gocloak.StringP("jdoe@example.com")},
+			},
+			userID:   "uid",
+			expected: &UserDto{ID: "uid", Email: "jdoe@example.com"},
+		},
+		{
+			name:        "empty id",
+			mock:        &mockGoCloakClient{},
+			expectedErr: ErrInvalidUserData,
+		},
+		{
+			name: "login error",
+			mock: &mockGoCloakClient{
+				loginErr: errors.New("login fail"),
+			},
+			userID:      "uid",
+			expectedErr: ErrIdPInteractionFailed,
+		},
+		{
+			name: "user not found",
+			mock: &mockGoCloakClient{
+				loginToken: successToken,
+				userErr:    &gocloak.APIError{Code: http.StatusNotFound},
+			},
+			userID:      "uid",
+			expectedErr: ErrUserNotFound,
+		},
+		{
+			name: "get error",
+			mock: &mockGoCloakClient{
+				loginToken: successToken,
+				userErr:    errors.New("fail"),
+			},
+			userID:      "uid",
+			expectedErr: ErrIdPInteractionFailed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			svc := NewService(tc.mock, "realm", "client", "secret")
+
+			// when
+			user, err := svc.GetUser(ctx, tc.userID)
+
+			// then
+			if tc.expectedErr != nil {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Nil(t, user)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, user)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 // UserService defines the interface for the user service.
 type UserService interface {
 	Register(ctx context.Context, user service.CreateUserDto) (*string, error)
+	GetUser(ctx context.Context, userID string) (*service.UserDto, error)
 }
 
 type Server struct {
@@ -52,3 +53,20 @@ func (s *Server) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.Reg
 	slog.InfoContext(ctx, "send grpc response", "userID", *userID)
 	return &pb.RegisterResponse{Id: *userID}, nil
 }
+
+// GetUser returns the Keycloak user with the given ID
+func (s *Server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.GetUserResponse, error) {
+	slog.InfoContext(ctx, "received grpc request GetUser", slog.Any("id", req.Id))
+	user, err := s.service.GetUser(ctx, req.Id)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidUserData) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		slog.ErrorContext(ctx, "service.GetUser failed", "error", err)
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &pb.GetUserResponse{Id: user.ID, Email: user.Email}, nil
+}
@@ -27,6 +27,12 @@ func (m *MockUserService) Register(ctx context.Context, user service.CreateUserD
 	return result, args.Error(1)
 }
 
+func (m *MockUserService) GetUser(ctx context.Context, userID string) (*service.UserDto, error) {
+	args := m.Called(ctx, userID)
+	user, _ := args.Get(0).(*service.UserDto)
+	return user, args.Error(1)
+}
+
 func TestServer_Register(t *testing.T) {
 	ctx := context.Background()
 	req := &pb.RegisterRequest{
@@ -101,3 +107,63 @@ func TestServer_Register(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_GetUser(t *testing.T) {
+	ctx := context.Background()
+	user := &service.UserDto{ID: "123456", Email: "jdoe@example.com"}
+
+	// given
+	testCases := []struct {
+		name         string
+		retUser      *service.UserDto
+		retErr       error
+		expectedCode codes.Code
+	}{
+		{
+			name:         "success",
+			retUser:      user,
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "not found",
+			retErr:       service.ErrUserNotFound,
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "invalid id",
+			retErr:       service.ErrInvalidUserData,
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name:         "internal error",
+			retErr:       service.ErrIdPInteractionFailed,
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// given
+			mockSvc := new(MockUserService)
+			server := NewServer(mockSvc)
+			mockSvc.On("GetUser", mock.Anything, user.ID).Return(tc.retUser, tc.retErr)
+
+			// when
+			res, err := server.GetUser(ctx, &pb.GetUserRequest{Id: user.ID})
+
+			// then
+			if tc.expectedCode == codes.OK {
+				require.NoError(t, err)
+				require.Equal(t, user.ID, res.Id)
+				require.Equal(t, user.Email, res.Email)
+			} else {
+				require.Nil(t, res)
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, tc.expectedCode, st.Code())
+			}
+
+			mockSvc.AssertExpectations(t)
+		})
+	}
+}